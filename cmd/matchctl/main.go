@@ -0,0 +1,151 @@
+// Command matchctl dumps and restores the matching queue for incident
+// recovery. Take a dump before an emergency Redis flush or migration, then
+// restore it afterwards to re-enqueue sessions that are still connected.
+//
+//	matchctl dump -o queue.json
+//	matchctl restore -i queue.json [-dry-run] [-recreate-sessions]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/matching"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "dump":
+		runDump(os.Args[2:])
+	case "restore":
+		runRestore(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: matchctl <command> [flags]
+
+Commands:
+  dump      Write the matching queue and session metadata to a file
+  restore   Re-enqueue still-connected sessions from a dump
+
+Run "matchctl <command> -h" for command flags. Redis is read from REDIS_ADDR
+(default localhost:6379).`)
+}
+
+func runDump(args []string) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	out := fs.String("o", "-", "output file (- for stdout)")
+	fs.Parse(args)
+
+	rdb := connectRedis()
+	defer rdb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	snap, err := matching.NewQueue(rdb).Snapshot(ctx)
+	if err != nil {
+		log.Fatalf("dump failed: %v", err)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snap); err != nil {
+		log.Fatalf("failed to write snapshot: %v", err)
+	}
+	log.Printf("dumped %d queued sessions, %d popularity scores", len(snap.Entries), len(snap.Popularity))
+}
+
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	in := fs.String("i", "-", "input file (- for stdin)")
+	dryRun := fs.Bool("dry-run", false, "report what would be restored without writing")
+	recreate := fs.Bool("recreate-sessions", false, "recreate missing session hashes when the owning wsserver is alive (after a full flush)")
+	fs.Parse(args)
+
+	r := io.Reader(os.Stdin)
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatalf("failed to open %s: %v", *in, err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var snap matching.Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		log.Fatalf("failed to read snapshot: %v", err)
+	}
+
+	rdb := connectRedis()
+	defer rdb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	age := time.Since(time.UnixMilli(snap.TakenAt)).Round(time.Second)
+	log.Printf("restoring snapshot taken %s ago with %d entries (dry_run=%v)", age, len(snap.Entries), *dryRun)
+
+	result, err := matching.NewQueue(rdb).Restore(ctx, &snap, matching.RestoreOptions{
+		DryRun:           *dryRun,
+		RecreateSessions: *recreate,
+	})
+	if err != nil {
+		log.Printf("restore stopped early: %v", err)
+	}
+
+	log.Printf("  restored:           %d", result.Restored)
+	log.Printf("  already queued:     %d", result.AlreadyQueued)
+	log.Printf("  disconnected:       %d", result.Disconnected)
+	log.Printf("  not matching:       %d", result.NotMatching)
+	log.Printf("  server down:        %d", result.ServerDown)
+	log.Printf("  sessions recreated: %d", result.SessionsRecreated)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func connectRedis() *redis.Client {
+	redisAddr := "localhost:6379"
+	if v := os.Getenv("REDIS_ADDR"); v != "" {
+		redisAddr = v
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: redisAddr})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		log.Fatalf("failed to connect to Redis at %s: %v", redisAddr, err)
+	}
+	return rdb
+}
@@ -2,15 +2,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/config"
+	"github.com/whisper/chat-app/internal/health"
+	"github.com/whisper/chat-app/internal/maintenance"
 	"github.com/whisper/chat-app/internal/matching"
 	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/metrics"
 )
 
 func main() {
@@ -31,26 +38,95 @@ func main() {
 	cancel()
 
 	// NATS setup.
-	natsConfig := messaging.DefaultNATSConfig()
-	if v := os.Getenv("NATS_URL"); v != "" {
-		natsConfig.URL = v
-	}
+	natsConfig := messaging.NATSConfigFromEnv()
 	natsConfig.Name = "whisper-matcher"
+	// MATCH_REGIONS limits this matcher to a comma-separated set of regions;
+	// unset consumes match traffic from every region.
+	if v := os.Getenv("MATCH_REGIONS"); v != "" {
+		for _, region := range strings.Split(v, ",") {
+			if region = strings.TrimSpace(region); region != "" {
+				natsConfig.MatchRegions = append(natsConfig.MatchRegions, region)
+			}
+		}
+	}
 
-	natsClient, err := messaging.NewNATSClient(natsConfig)
+	// MESSAGE_BUS=redis carries service traffic over Redis Pub/Sub instead
+	// of NATS, for single-box deployments.
+	bus, err := messaging.OpenBus(os.Getenv("MESSAGE_BUS"), natsConfig, rdb)
 	if err != nil {
-		log.Fatalf("failed to connect to NATS: %v", err)
+		log.Fatalf("failed to connect to the message bus: %v", err)
+	}
+
+	// Reloadable settings (log level, feature flags); re-applied on SIGHUP.
+	reloader := config.NewReloader(os.Getenv("CONFIG_FILE"))
+	if err := reloader.Reload(); err != nil {
+		log.Fatalf("failed to load CONFIG_FILE: %v", err)
+	}
+	reloadCtx, reloadCancel := context.WithCancel(context.Background())
+	defer reloadCancel()
+	reloader.WatchSIGHUP(reloadCtx)
+
+	// Tier thresholds. These are the startup values; operators can retune a
+	// running matcher through PUT /admin/matching/tiers on any wsserver.
+	tiers := matching.DefaultTierConfig()
+	for name, d := range map[string]*time.Duration{
+		"MATCH_TIER1_MAX_WAIT": &tiers.Tier1MaxWait,
+		"MATCH_TIER2_MAX_WAIT": &tiers.Tier2MaxWait,
+		"MATCH_TIER3_MAX_WAIT": &tiers.Tier3MaxWait,
+		"MATCH_TIMEOUT":        &tiers.MatchTimeout,
+	} {
+		if v := os.Getenv(name); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				log.Fatalf("invalid %s: %v", name, err)
+			}
+			*d = parsed
+		}
+	}
+	if err := tiers.Validate(); err != nil {
+		log.Fatalf("invalid matching tiers: %v", err)
+	}
+
+	// Maintenance mode, switched on through /admin/maintenance on any
+	// wsserver, pauses pairing until it is switched off.
+	maintenanceMode := maintenance.NewMode(rdb, bus)
+	if err := maintenanceMode.Start(reloadCtx); err != nil {
+		log.Fatalf("failed to start maintenance mode: %v", err)
 	}
 
 	// Start matching service.
-	svc := matching.NewService(rdb, natsClient)
+	svc := matching.NewService(rdb, bus, tiers)
+	svc.SetPaused(maintenanceMode.Active)
 	if err := svc.Start(); err != nil {
 		log.Fatalf("failed to start matching service: %v", err)
 	}
 
+	// Metrics endpoint for queue size, wait times per tier, timeouts and
+	// match loop duration, and a deep health check of Redis and the bus.
+	metricsAddr := ":9091"
+	if v := os.Getenv("METRICS_ADDR"); v != "" {
+		metricsAddr = v
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/health/deep", health.Handler("matcher", health.Redis(rdb), health.Bus(bus)))
+	metricsServer := &http.Server{Addr: metricsAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics server: %v", err)
+		}
+	}()
+
 	log.Printf("Whisper matching service running")
 	log.Printf("  redis_addr: %s", redisAddr)
 	log.Printf("  nats_url:   %s", natsConfig.URL)
+	log.Printf("  metrics:    %s", metricsAddr)
+	log.Printf("  tiers:      %s", tiers)
+	if len(natsConfig.MatchRegions) > 0 {
+		log.Printf("  regions:    %s", strings.Join(natsConfig.MatchRegions, ","))
+	} else {
+		log.Printf("  regions:    all")
+	}
 
 	// Graceful shutdown.
 	sigCh := make(chan os.Signal, 1)
@@ -58,7 +134,11 @@ func main() {
 	sig := <-sigCh
 	log.Printf("received signal %v, shutting down...", sig)
 
+	reloadCancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_ = metricsServer.Shutdown(shutdownCtx)
+	shutdownCancel()
 	svc.Stop()
-	natsClient.Close()
+	bus.Close()
 	rdb.Close()
 }
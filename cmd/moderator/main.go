@@ -2,15 +2,23 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/config"
+	"github.com/whisper/chat-app/internal/database"
+	"github.com/whisper/chat-app/internal/health"
 	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/metrics"
 	"github.com/whisper/chat-app/internal/moderation"
 )
 
@@ -32,61 +40,132 @@ func main() {
 	cancel()
 
 	// NATS setup.
-	natsConfig := messaging.DefaultNATSConfig()
-	if v := os.Getenv("NATS_URL"); v != "" {
-		natsConfig.URL = v
-	}
+	natsConfig := messaging.NATSConfigFromEnv()
 	natsConfig.Name = "whisper-moderator"
 
-	natsClient, err := messaging.NewNATSClient(natsConfig)
+	// MESSAGE_BUS=redis carries service traffic over Redis Pub/Sub instead
+	// of NATS, for single-box deployments.
+	bus, err := messaging.OpenBus(os.Getenv("MESSAGE_BUS"), natsConfig, rdb)
 	if err != nil {
-		log.Fatalf("failed to connect to NATS: %v", err)
+		log.Fatalf("failed to connect to the message bus: %v", err)
 	}
 
-	// Initialize content filter.
-	filter := moderation.NewFilter()
-
-	// Subscribe to moderation check requests.
-	err = natsClient.SubscribeModerationCheck(func(data []byte) {
-		var req moderation.ModerationRequest
-		if err := json.Unmarshal(data, &req); err != nil {
-			log.Printf("[moderator] failed to unmarshal request: %v", err)
-			return
+	// Initialize the Redis-backed content filter so runtime blocklist changes
+	// made through the admin API are picked up.
+	filterCtx, filterCancel := context.WithCancel(context.Background())
+	defer filterCancel()
+	filter := moderation.NewDynamicFilter(rdb, bus)
+	// BLOCKLIST_DIR holds extra per-language blocklists, one <lang>.txt file
+	// per language.
+	if dir := os.Getenv("BLOCKLIST_DIR"); dir != "" {
+		lists, err := moderation.LoadBlocklistDir(dir)
+		if err != nil {
+			log.Fatalf("failed to load BLOCKLIST_DIR: %v", err)
 		}
+		filter.SetLanguageLists(lists)
+	}
+	if err := filter.Start(filterCtx); err != nil {
+		log.Fatalf("failed to start content filter: %v", err)
+	}
+
+	svc := moderation.NewService(bus, filter)
+
+	// MODERATION_DRY_RUN=true flags messages without enforcing them, for
+	// trying out a new blocklist; moderation_dry_run in CONFIG_FILE turns
+	// it on without a restart.
+	dryRun := os.Getenv("MODERATION_DRY_RUN") == "true"
 
-		result := filter.Check(req.Text)
-
-		if result.Blocked {
-			log.Printf("[moderator] FLAGGED session=%s chat=%s reason=%s term=%q",
-				req.SessionID, req.ChatID, result.Reason, result.Term)
-
-			resp := moderation.ModerationResult{
-				SessionID: req.SessionID,
-				ChatID:    req.ChatID,
-				Blocked:   true,
-				Reason:    result.Reason,
-				Term:      result.Term,
-			}
-			respData, err := json.Marshal(resp)
-			if err != nil {
-				log.Printf("[moderator] failed to marshal result: %v", err)
-				return
-			}
-			if err := natsClient.PublishModerationResult(req.SessionID, respData); err != nil {
-				log.Printf("[moderator] failed to publish result: %v", err)
-			}
+	// Reloadable settings (log level, feature flags, dry-run mode); SIGHUP
+	// also resyncs the content filter blocklist from Redis.
+	reloader := config.NewReloader(os.Getenv("CONFIG_FILE"))
+	reloader.OnReload(func(*config.Settings) error {
+		ctx, cancel := context.WithTimeout(filterCtx, 5*time.Second)
+		defer cancel()
+		return filter.Reload(ctx)
+	})
+	reloader.OnReload(func(s *config.Settings) error {
+		svc.SetDryRun(dryRun || s.ModerationDryRun)
+		return nil
+	})
+	if err := reloader.Reload(); err != nil {
+		log.Fatalf("failed to load CONFIG_FILE: %v", err)
+	}
+	reloader.WatchSIGHUP(filterCtx)
+
+	// Flagged messages are written to the audit log when DATABASE_URL points
+	// at PostgreSQL. The wsserver owns the schema and runs the migrations.
+	var db *sql.DB
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		var dialect database.Dialect
+		db, dialect, err = database.Open(databaseURL)
+		if err != nil {
+			log.Fatalf("failed to open database connection: %v", err)
+		}
+		if dialect == database.Postgres {
+			svc.EnableAudit(audit.NewLogger(db), rdb)
 		} else {
-			log.Printf("[moderator] CLEAN session=%s chat=%s",
-				req.SessionID, req.ChatID)
+			log.Printf("[audit] %s has no audit log; flagged messages are not recorded", dialect)
+			db.Close()
+			db = nil
 		}
-	})
-	if err != nil {
+	}
+
+	// Start consuming moderation checks.
+	if err := svc.Start(); err != nil {
 		log.Fatalf("failed to subscribe to moderation checks: %v", err)
 	}
 
+	// Metrics endpoint for checks, flags by reason, check latency and the
+	// check backlog, a health endpoint for the same monitoring as the
+	// wsserver, and a deep health check of every dependency.
+	metricsAddr := ":9092"
+	if v := os.Getenv("METRICS_ADDR"); v != "" {
+		metricsAddr = v
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	deepChecks := []health.Check{health.Redis(rdb), health.Bus(bus)}
+	if db != nil {
+		deepChecks = append(deepChecks, health.Database("postgres", db))
+	}
+	mux.Handle("/health/deep", health.Handler("moderator", deepChecks...))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		pending, dropped := bus.ModerationCheckBacklog()
+		resp := struct {
+			Status        string `json:"status"`
+			Redis         bool   `json:"redis"`
+			NATS          bool   `json:"nats"`
+			PendingChecks int    `json:"pending_checks"`
+			DroppedChecks int    `json:"dropped_checks"`
+		}{
+			Status:        "ok",
+			Redis:         rdb.Ping(ctx).Err() == nil,
+			NATS:          bus.Connected(),
+			PendingChecks: pending,
+			DroppedChecks: dropped,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !resp.Redis || !resp.NATS {
+			resp.Status = "unhealthy"
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	metricsServer := &http.Server{Addr: metricsAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics server: %v", err)
+		}
+	}()
+
 	log.Printf("Whisper moderation service running")
 	log.Printf("  redis_addr: %s", redisAddr)
 	log.Printf("  nats_url:   %s", natsConfig.URL)
+	log.Printf("  metrics:    %s", metricsAddr)
+	log.Printf("  audit_log:  %v", db != nil)
+	log.Printf("  dry_run:    %v", dryRun || reloader.Current().ModerationDryRun)
 
 	// Graceful shutdown.
 	sigCh := make(chan os.Signal, 1)
@@ -94,6 +173,14 @@ func main() {
 	sig := <-sigCh
 	log.Printf("received signal %v, shutting down...", sig)
 
-	natsClient.Close()
+	filterCancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_ = metricsServer.Shutdown(shutdownCtx)
+	shutdownCancel()
+	svc.Stop()
+	bus.Close()
 	rdb.Close()
+	if db != nil {
+		db.Close()
+	}
 }
@@ -0,0 +1,98 @@
+// Command reportkeys manages the master keys that encrypt report and
+// evidence transcripts at rest. Generate a key, put it first in
+// REPORT_ENCRYPTION_KEYS on every wsserver (keeping the old ones after it),
+// then rotate to encrypt plaintext transcripts and re-wrap the rest:
+//
+//	reportkeys genkey -id 2024-06
+//	reportkeys rotate
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/whisper/chat-app/internal/database"
+	"github.com/whisper/chat-app/internal/report"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "genkey":
+		runGenKey(os.Args[2:])
+	case "rotate":
+		runRotate(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: reportkeys <command> [flags]
+
+Commands:
+  genkey   Print a new master key as <id>:<base64 key>
+  rotate   Encrypt plaintext transcripts and re-wrap data keys with the first
+           key in REPORT_ENCRYPTION_KEYS
+
+Run "reportkeys <command> -h" for command flags. The database is read from
+DATABASE_URL and the keys from REPORT_ENCRYPTION_KEYS, as for the wsserver.`)
+}
+
+func runGenKey(args []string) {
+	fs := flag.NewFlagSet("genkey", flag.ExitOnError)
+	id := fs.String("id", time.Now().UTC().Format("2006-01-02"), "key ID stored with every transcript it wraps")
+	fs.Parse(args)
+
+	key, err := report.NewKey(*id)
+	if err != nil {
+		log.Fatalf("failed to generate key: %v", err)
+	}
+	fmt.Println(key)
+}
+
+func runRotate(args []string) {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+	timeout := fs.Duration("timeout", time.Hour, "give up after this long; an interrupted rotation can be run again")
+	fs.Parse(args)
+
+	keys, err := report.ParseKeys(os.Getenv("REPORT_ENCRYPTION_KEYS"))
+	if err != nil {
+		log.Fatalf("invalid REPORT_ENCRYPTION_KEYS: %v", err)
+	}
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatalf("DATABASE_URL is not set")
+	}
+	db, dialect, err := database.Open(databaseURL)
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	log.Printf("rotating transcripts to key %s", keys.CurrentKeyID())
+	result, err := report.NewEncryptedStore(db, dialect, keys).RotateKeys(ctx)
+	if err != nil {
+		log.Fatalf("rotation stopped: %v (%d encrypted, %d re-wrapped so far)", err, result.Encrypted, result.Rewrapped)
+	}
+	out, _ := json.Marshal(result)
+	fmt.Println(string(out))
+	log.Printf("done: %d encrypted, %d re-wrapped, %d already current; keys other than %s can be removed",
+		result.Encrypted, result.Rewrapped, result.Current, keys.CurrentKeyID())
+}
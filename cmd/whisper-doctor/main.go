@@ -0,0 +1,170 @@
+// Command whisper-doctor queries the /health/deep endpoint of every Whisper
+// service and prints a consolidated readiness report: whether each service
+// is reachable and whether it can reach Redis, the message bus and the
+// database, with latencies. It exits 1 if anything is not ready, so it can
+// also gate scripts.
+//
+//	whisper-doctor
+//	whisper-doctor -wsserver http://ws-1:8080,http://ws-2:8080 -matcher "" -json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/whisper/chat-app/internal/health"
+)
+
+// statusUnreachable marks a service whose endpoint could not be queried.
+const statusUnreachable = "unreachable"
+
+// target is one service instance to query.
+type target struct {
+	Service string `json:"service"`
+	URL     string `json:"url"`
+}
+
+// result is the outcome of querying a target.
+type result struct {
+	target
+	Report health.Report `json:"report"`
+	Error  string        `json:"error,omitempty"`
+}
+
+func main() {
+	wsservers := flag.String("wsserver", "http://localhost:8080", "comma-separated wsserver base URLs (INTERNAL_ADDR if set)")
+	matchers := flag.String("matcher", "http://localhost:9091", "comma-separated matcher metrics base URLs")
+	moderators := flag.String("moderator", "http://localhost:9092", "comma-separated moderator metrics base URLs")
+	timeout := flag.Duration("timeout", 5*time.Second, "timeout per service")
+	asJSON := flag.Bool("json", false, "print the reports as JSON")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, `Usage: whisper-doctor [flags]
+
+Queries /health/deep on every service and prints a readiness report. Pass
+an empty URL list to skip a service.
+
+Flags:`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var targets []target
+	for _, s := range []struct{ service, urls string }{
+		{"wsserver", *wsservers},
+		{"matcher", *matchers},
+		{"moderator", *moderators},
+	} {
+		for _, u := range strings.Split(s.urls, ",") {
+			if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+				targets = append(targets, target{Service: s.service, URL: u})
+			}
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Fprintln(os.Stderr, "no services to check")
+		os.Exit(2)
+	}
+
+	results := query(targets, *timeout)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(results)
+	} else {
+		printReport(os.Stdout, results)
+	}
+
+	for _, r := range results {
+		if r.Report.Status != health.StatusOK {
+			os.Exit(1)
+		}
+	}
+}
+
+// query fetches the report of every target concurrently, in target order.
+func query(targets []target, timeout time.Duration) []result {
+	client := &http.Client{Timeout: timeout}
+	results := make([]result, len(targets))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report, err := fetch(client, t)
+			results[i] = result{target: t, Report: report}
+			if err != nil {
+				results[i].Report = health.Report{Service: t.Service, Status: statusUnreachable}
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// fetch queries the /health/deep endpoint of a target. A 503 still carries
+// a report naming the failed checks.
+func fetch(client *http.Client, t target) (health.Report, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL+"/health/deep", nil)
+	if err != nil {
+		return health.Report{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return health.Report{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return health.Report{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var report health.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return health.Report{}, fmt.Errorf("invalid report: %w", err)
+	}
+	return report, nil
+}
+
+// printReport writes the results as a table, one row per check, followed
+// by a summary line.
+func printReport(out io.Writer, results []result) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tURL\tSTATUS\tCHECK\tLATENCY\tERROR")
+
+	ready := 0
+	for _, r := range results {
+		if r.Report.Status == health.StatusOK {
+			ready++
+		}
+		if r.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t%s\n", r.Service, r.URL, r.Report.Status, r.Error)
+			continue
+		}
+		for i, c := range r.Report.Checks {
+			service, url, status := r.Service, r.URL, r.Report.Status
+			if i > 0 {
+				service, url, status = "", "", ""
+			}
+			checkStatus := "ok"
+			if !c.OK {
+				checkStatus = "FAIL"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s %s\t%.1fms\t%s\n", service, url, status, c.Name, checkStatus, c.LatencyMs, c.Error)
+		}
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\n%d/%d services ready\n", ready, len(results))
+}
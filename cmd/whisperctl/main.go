@@ -0,0 +1,516 @@
+// Command whisperctl runs common operator tasks against a wsserver's admin
+// API: banning fingerprints, inspecting sessions, chats, the matching queue
+// and a session's match timeline, listing recent reports and disconnecting
+// sessions. Reads print a
+// table, or the API response with -json.
+//
+//	whisperctl ban -duration 24h -reason spam 3f2a9c...
+//	whisperctl session 6b1d...
+//	whisperctl queue -limit 20 -json
+//	whisperctl timeline 6b1d...
+//	whisperctl disconnect -reason "abusive nickname" 6b1d...
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "ban":
+		runBan(os.Args[2:])
+	case "unban":
+		runUnban(os.Args[2:])
+	case "session":
+		runSession(os.Args[2:])
+	case "chat":
+		runChat(os.Args[2:])
+	case "queue":
+		runQueue(os.Args[2:])
+	case "timeline":
+		runTimeline(os.Args[2:])
+	case "reports":
+		runReports(os.Args[2:])
+	case "disconnect":
+		runDisconnect(os.Args[2:])
+	case "maintenance":
+		runMaintenance(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: whisperctl <command> [flags] [argument]
+
+Commands:
+  ban <fingerprint>         Ban a fingerprint
+  unban <fingerprint>       Lift a fingerprint ban
+  session <session_id>      Show a session
+  chat <chat_id>            Show a chat
+  queue                     List the matching queue, longest waiting first
+  timeline <session_id>     Show a session's match lifecycle events
+  reports                   List the most recent reports
+  disconnect <session_id>   Close a session's connection
+  maintenance [on|off]      Show or switch maintenance mode
+
+Run "whisperctl <command> -h" for command flags. The admin API is read from
+WHISPER_ADMIN_URL (default http://localhost:8080, the wsserver INTERNAL_ADDR)
+and authenticated with ADMIN_TOKEN.`)
+}
+
+// client calls the admin API of one wsserver.
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// commonFlags adds the flags every command takes to fs.
+func commonFlags(fs *flag.FlagSet) (*client, *bool) {
+	c := &client{
+		baseURL: "http://localhost:8080",
+		token:   os.Getenv("ADMIN_TOKEN"),
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+	if v := os.Getenv("WHISPER_ADMIN_URL"); v != "" {
+		c.baseURL = v
+	}
+	fs.StringVar(&c.baseURL, "url", c.baseURL, "wsserver admin base URL (WHISPER_ADMIN_URL)")
+	asJSON := fs.Bool("json", false, "print the API response as JSON")
+	return c, asJSON
+}
+
+// actorFlag adds the -actor flag of commands recorded in the audit log.
+func actorFlag(fs *flag.FlagSet) *string {
+	return fs.String("actor", os.Getenv("USER"), "operator name recorded in the audit log")
+}
+
+// parseArg parses fs and returns its single positional argument.
+func parseArg(fs *flag.FlagSet, args []string, name string) string {
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatalf("%s: expected exactly one %s", fs.Name(), name)
+	}
+	return fs.Arg(0)
+}
+
+// do sends a request with an optional JSON body and returns the response
+// body. Non-2xx responses are returned as errors carrying the API's message.
+func (c *client) do(method, path string, body interface{}) ([]byte, error) {
+	if c.token == "" {
+		return nil, fmt.Errorf("ADMIN_TOKEN is not set")
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.http.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.baseURL, "/")+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+		}
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return data, nil
+}
+
+// get fetches path and decodes it into v. With asJSON the response is
+// printed as is and v is left empty, and get reports false.
+func (c *client) get(path string, asJSON bool, v interface{}) bool {
+	data, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		log.Fatalf("GET %s: %v", path, err)
+	}
+	if asJSON {
+		printJSON(data)
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Fatalf("GET %s: invalid response: %v", path, err)
+	}
+	return true
+}
+
+// printJSON writes an API response to stdout, indented.
+func printJSON(data []byte) {
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		os.Stdout.Write(data)
+		return
+	}
+	out.WriteByte('\n')
+	out.WriteTo(os.Stdout)
+}
+
+func runBan(args []string) {
+	fs := flag.NewFlagSet("ban", flag.ExitOnError)
+	c, asJSON := commonFlags(fs)
+	actor := actorFlag(fs)
+	duration := fs.Duration("duration", 24*time.Hour, "ban length; 0 bans until lifted")
+	reason := fs.String("reason", "", "reason shown to the user and recorded in the audit log")
+	fp := parseArg(fs, args, "fingerprint")
+
+	path := "/admin/bans/" + url.PathEscape(fp)
+	data, err := c.do(http.MethodPost, path, map[string]interface{}{
+		"duration_seconds": int(duration.Seconds()),
+		"actor":            *actor,
+		"reason":           *reason,
+	})
+	if err != nil {
+		log.Fatalf("POST %s: %v", path, err)
+	}
+	if *asJSON {
+		printJSON(data)
+		return
+	}
+	length := "until lifted"
+	if *duration > 0 {
+		length = "for " + duration.String()
+	}
+	fmt.Printf("banned %s %s\n", fp, length)
+}
+
+func runUnban(args []string) {
+	fs := flag.NewFlagSet("unban", flag.ExitOnError)
+	c, _ := commonFlags(fs)
+	actor := actorFlag(fs)
+	reason := fs.String("reason", "", "reason recorded in the audit log")
+	fp := parseArg(fs, args, "fingerprint")
+
+	path := "/admin/bans/" + url.PathEscape(fp)
+	if _, err := c.do(http.MethodDelete, path, map[string]string{"actor": *actor, "reason": *reason}); err != nil {
+		log.Fatalf("DELETE %s: %v", path, err)
+	}
+	fmt.Printf("lifted the ban on %s\n", fp)
+}
+
+func runDisconnect(args []string) {
+	fs := flag.NewFlagSet("disconnect", flag.ExitOnError)
+	c, _ := commonFlags(fs)
+	actor := actorFlag(fs)
+	reason := fs.String("reason", "", "reason recorded in the audit log")
+	sid := parseArg(fs, args, "session ID")
+
+	path := "/admin/sessions/" + url.PathEscape(sid) + "/disconnect"
+	if _, err := c.do(http.MethodPost, path, map[string]string{"actor": *actor, "reason": *reason}); err != nil {
+		log.Fatalf("POST %s: %v", path, err)
+	}
+	fmt.Printf("disconnected %s\n", sid)
+}
+
+func runMaintenance(args []string) {
+	fs := flag.NewFlagSet("maintenance", flag.ExitOnError)
+	c, asJSON := commonFlags(fs)
+	actor := fs.String("actor", os.Getenv("USER"), "operator name shown in the maintenance state and recorded in the audit log")
+	reason := fs.String("reason", "", "reason recorded in the audit log (off)")
+	message := fs.String("message", "", "message shown to clients (on)")
+	eta := fs.Duration("for", 0, "expected length, sent to clients as an ETA; 0 if unknown (on)")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		log.Fatalf("maintenance: expected on, off or nothing")
+	}
+
+	const path = "/admin/maintenance"
+	switch fs.Arg(0) {
+	case "on":
+		var etaUnix int64
+		if *eta > 0 {
+			etaUnix = time.Now().Add(*eta).Unix()
+		}
+		data, err := c.do(http.MethodPut, path, map[string]interface{}{
+			"message": *message,
+			"eta":     etaUnix,
+			"actor":   *actor,
+		})
+		if err != nil {
+			log.Fatalf("PUT %s: %v", path, err)
+		}
+		if *asJSON {
+			printJSON(data)
+			return
+		}
+		fmt.Println("maintenance mode is on")
+	case "off":
+		if _, err := c.do(http.MethodDelete, path, map[string]string{"actor": *actor, "reason": *reason}); err != nil {
+			log.Fatalf("DELETE %s: %v", path, err)
+		}
+		fmt.Println("maintenance mode is off")
+	case "":
+		var state struct {
+			Active    bool   `json:"active"`
+			Message   string `json:"message"`
+			ETA       int64  `json:"eta"`
+			StartedAt int64  `json:"started_at"`
+			Actor     string `json:"actor"`
+		}
+		if !c.get(path, *asJSON, &state) {
+			return
+		}
+		if !state.Active {
+			fmt.Println("maintenance mode is off")
+			return
+		}
+		printFields([][2]string{
+			{"Active", "yes"},
+			{"Started", unixTime(state.StartedAt)},
+			{"ETA", dash(unixTime(state.ETA))},
+			{"Actor", state.Actor},
+			{"Message", state.Message},
+		})
+	default:
+		log.Fatalf("maintenance: unknown action %q (want on or off)", fs.Arg(0))
+	}
+}
+
+func runSession(args []string) {
+	fs := flag.NewFlagSet("session", flag.ExitOnError)
+	c, asJSON := commonFlags(fs)
+	sid := parseArg(fs, args, "session ID")
+
+	var sess struct {
+		ID          string `json:"id"`
+		Status      string `json:"status"`
+		ChatID      string `json:"chat_id"`
+		Server      string `json:"server"`
+		Region      string `json:"region"`
+		Fingerprint string `json:"fingerprint"`
+		Platform    string `json:"platform"`
+		AppVersion  string `json:"app_version"`
+		Locale      string `json:"locale"`
+		Country     string `json:"country"`
+		GeoRegion   string `json:"geo_region"`
+		Policy      string `json:"policy"`
+		CreatedAt   int64  `json:"created_at"`
+		LastActive  int64  `json:"last_active"`
+		DetachedAt  int64  `json:"detached_at"`
+	}
+	if !c.get("/admin/sessions/"+url.PathEscape(sid), *asJSON, &sess) {
+		return
+	}
+	printFields([][2]string{
+		{"id", sess.ID},
+		{"status", sess.Status},
+		{"chat", sess.ChatID},
+		{"server", sess.Server},
+		{"region", sess.Region},
+		{"location", strings.Trim(sess.Country+"-"+sess.GeoRegion, "-")},
+		{"fingerprint", sess.Fingerprint},
+		{"policy", sess.Policy},
+		{"client", strings.TrimSpace(strings.Join([]string{sess.Platform, sess.AppVersion, sess.Locale}, " "))},
+		{"created", unixTime(sess.CreatedAt)},
+		{"last active", unixTime(sess.LastActive)},
+		{"detached", unixTime(sess.DetachedAt)},
+	})
+}
+
+func runChat(args []string) {
+	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+	c, asJSON := commonFlags(fs)
+	chatID := parseArg(fs, args, "chat ID")
+
+	var cs struct {
+		ChatID         string   `json:"chat_id"`
+		Status         string   `json:"status"`
+		UserA          string   `json:"user_a"`
+		UserB          string   `json:"user_b"`
+		Tier           string   `json:"tier"`
+		Interests      []string `json:"interests"`
+		Policy         string   `json:"policy"`
+		CreatedAt      int64    `json:"created_at"`
+		AcceptDeadline int64    `json:"accept_deadline"`
+		ActivatedAt    int64    `json:"activated_at"`
+	}
+	if !c.get("/admin/chats/"+url.PathEscape(chatID), *asJSON, &cs) {
+		return
+	}
+	printFields([][2]string{
+		{"chat", cs.ChatID},
+		{"status", cs.Status},
+		{"user a", cs.UserA},
+		{"user b", cs.UserB},
+		{"tier", cs.Tier},
+		{"interests", strings.Join(cs.Interests, ", ")},
+		{"policy", cs.Policy},
+		{"created", unixTime(cs.CreatedAt)},
+		{"accept by", unixTime(cs.AcceptDeadline)},
+		{"activated", unixTime(cs.ActivatedAt)},
+	})
+}
+
+func runQueue(args []string) {
+	fs := flag.NewFlagSet("queue", flag.ExitOnError)
+	c, asJSON := commonFlags(fs)
+	limit := fs.Int("limit", 50, "maximum entries to list (at most 500)")
+	fs.Parse(args)
+
+	var entries []struct {
+		SessionID   string   `json:"session_id"`
+		Interests   []string `json:"interests"`
+		Server      string   `json:"server"`
+		Region      string   `json:"region"`
+		Policy      string   `json:"policy"`
+		WaitSeconds int64    `json:"wait_seconds"`
+	}
+	if !c.get("/admin/matching/queue?limit="+strconv.Itoa(*limit), *asJSON, &entries) {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SESSION\tWAIT\tSERVER\tREGION\tPOLICY\tINTERESTS")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%ds\t%s\t%s\t%s\t%s\n", e.SessionID, e.WaitSeconds, e.Server, dash(e.Region), dash(e.Policy), strings.Join(e.Interests, ","))
+	}
+	w.Flush()
+	fmt.Printf("\n%d queued\n", len(entries))
+}
+
+func runTimeline(args []string) {
+	fs := flag.NewFlagSet("timeline", flag.ExitOnError)
+	c, asJSON := commonFlags(fs)
+	sid := parseArg(fs, args, "session ID")
+
+	var timeline struct {
+		Events []struct {
+			Type      string   `json:"type"`
+			At        int64    `json:"at"`
+			Tier      string   `json:"tier"`
+			ChatID    string   `json:"chat_id"`
+			Partner   string   `json:"partner"`
+			WaitMs    int64    `json:"wait_ms"`
+			Interests []string `json:"interests"`
+			Detail    string   `json:"detail"`
+		} `json:"events"`
+	}
+	if !c.get("/admin/matching/events/"+url.PathEscape(sid), *asJSON, &timeline) {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tEVENT\tTIER\tWAIT\tCHAT\tPARTNER\tDETAIL")
+	for _, e := range timeline.Events {
+		wait := "-"
+		if e.WaitMs > 0 {
+			wait = (time.Duration(e.WaitMs) * time.Millisecond).Round(100 * time.Millisecond).String()
+		}
+		detail := e.Detail
+		if len(e.Interests) > 0 {
+			detail = strings.TrimSpace(detail + " [" + strings.Join(e.Interests, ",") + "]")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", time.UnixMilli(e.At).Local().Format("2006-01-02 15:04:05.000"),
+			e.Type, dash(e.Tier), wait, dash(e.ChatID), dash(e.Partner), dash(detail))
+	}
+	w.Flush()
+}
+
+func runReports(args []string) {
+	fs := flag.NewFlagSet("reports", flag.ExitOnError)
+	c, asJSON := commonFlags(fs)
+	limit := fs.Int("limit", 20, "maximum reports to list (at most 100)")
+	fs.Parse(args)
+
+	var reports []struct {
+		ID                  int64     `json:"id"`
+		ReporterFingerprint string    `json:"reporter_fingerprint"`
+		ReportedFingerprint string    `json:"reported_fingerprint"`
+		ChatID              string    `json:"chat_id"`
+		Reason              string    `json:"reason"`
+		Category            string    `json:"category"`
+		NeedsReview         bool      `json:"needs_review"`
+		CreatedAt           time.Time `json:"created_at"`
+	}
+	if !c.get("/admin/reports?limit="+strconv.Itoa(*limit), *asJSON, &reports) {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tCATEGORY\tREASON\tREPORTED\tREPORTER\tCHAT\tREVIEW")
+	for _, r := range reports {
+		review := ""
+		if r.NeedsReview {
+			review = "yes"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.CreatedAt.Local().Format(time.DateTime),
+			r.Category, r.Reason, r.ReportedFingerprint, r.ReporterFingerprint, r.ChatID, review)
+	}
+	w.Flush()
+}
+
+// printFields writes label/value pairs as an aligned two-column list,
+// leaving out empty values.
+func printFields(fields [][2]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range fields {
+		if f[1] != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", f[0], f[1])
+		}
+	}
+	w.Flush()
+}
+
+// unixTime formats a unix timestamp in local time with its age, or returns
+// "" for zero.
+func unixTime(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	t := time.Unix(ts, 0)
+	if d := time.Since(t); d >= 0 {
+		return fmt.Sprintf("%s (%s ago)", t.Local().Format(time.DateTime), d.Round(time.Second))
+	}
+	return fmt.Sprintf("%s (in %s)", t.Local().Format(time.DateTime), time.Until(t).Round(time.Second))
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
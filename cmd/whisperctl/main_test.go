@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// request is a call the fake admin API received.
+type request struct {
+	method, path, auth string
+	body               map[string]interface{}
+}
+
+// fakeAPI serves status and response for every request and records what it
+// received. WHISPER_ADMIN_URL and ADMIN_TOKEN point commands at it.
+func fakeAPI(t *testing.T, status int, response string) *[]request {
+	t.Helper()
+	var got []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{method: r.Method, path: r.URL.RequestURI(), auth: r.Header.Get("Authorization")}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			if err := json.Unmarshal(data, &req.body); err != nil {
+				t.Errorf("%s %s: invalid body %q", r.Method, r.URL, data)
+			}
+		}
+		got = append(got, req)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("WHISPER_ADMIN_URL", srv.URL+"/")
+	t.Setenv("ADMIN_TOKEN", "secret")
+	return &got
+}
+
+// captureStdout returns what fn writes to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestBan(t *testing.T) {
+	got := fakeAPI(t, http.StatusCreated, `{"fingerprint":"fp-1","banned":true}`)
+
+	out := captureStdout(t, func() {
+		runBan([]string{"-duration", "1h", "-reason", "spam", "-actor", "alice", "fp-1"})
+	})
+	if out != "banned fp-1 for 1h0m0s\n" {
+		t.Errorf("output %q", out)
+	}
+	if len(*got) != 1 {
+		t.Fatalf("%d requests, want 1", len(*got))
+	}
+	req := (*got)[0]
+	if req.method != "POST" || req.path != "/admin/bans/fp-1" || req.auth != "Bearer secret" {
+		t.Errorf("request %s %s auth %q", req.method, req.path, req.auth)
+	}
+	if req.body["duration_seconds"] != float64(3600) || req.body["actor"] != "alice" || req.body["reason"] != "spam" {
+		t.Errorf("body %v", req.body)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	got := fakeAPI(t, http.StatusNoContent, "")
+
+	out := captureStdout(t, func() {
+		runDisconnect([]string{"-actor", "alice", "-reason", "abuse", "s/1"})
+	})
+	if out != "disconnected s/1\n" {
+		t.Errorf("output %q", out)
+	}
+	req := (*got)[0]
+	if req.method != "POST" || req.path != "/admin/sessions/s%2F1/disconnect" || req.body["actor"] != "alice" {
+		t.Errorf("request %s %s %v", req.method, req.path, req.body)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	got := fakeAPI(t, http.StatusOK, `[{"session_id":"s1","interests":["music","chess"],"server":"ws-1","wait_seconds":42}]`)
+
+	out := captureStdout(t, func() { runQueue([]string{"-limit", "5"}) })
+	if (*got)[0].path != "/admin/matching/queue?limit=5" {
+		t.Errorf("path %s", (*got)[0].path)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "SESSION") || lines[3] != "1 queued" {
+		t.Fatalf("output %q", out)
+	}
+	if f := strings.Fields(lines[1]); len(f) != 6 || f[0] != "s1" || f[1] != "42s" || f[3] != "-" || f[5] != "music,chess" {
+		t.Errorf("row %q", lines[1])
+	}
+}
+
+func TestQueue_JSON(t *testing.T) {
+	fakeAPI(t, http.StatusOK, `[{"session_id":"s1"}]`)
+
+	out := captureStdout(t, func() { runQueue([]string{"-json"}) })
+	if out != "[\n  {\n    \"session_id\": \"s1\"\n  }\n]\n" {
+		t.Errorf("output %q", out)
+	}
+}
+
+func TestSession(t *testing.T) {
+	fakeAPI(t, http.StatusOK, `{"id":"s1","status":"chatting","server":"ws-1","country":"DE","platform":"ios","app_version":"2.4.0"}`)
+
+	out := captureStdout(t, func() { runSession([]string{"s1"}) })
+	for _, want := range []string{"id:", "s1", "status:", "chatting", "location:", "DE", "client:", "ios 2.4.0"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q lacks %q", out, want)
+		}
+	}
+	if strings.Contains(out, "chat:") || strings.Contains(out, "detached:") {
+		t.Errorf("output %q shows empty fields", out)
+	}
+}
+
+func TestReports(t *testing.T) {
+	got := fakeAPI(t, http.StatusOK, `[{"id":7,"reported_fingerprint":"fp-b","reporter_fingerprint":"fp-a","chat_id":"c1",
+		"reason":"spam","category":"spam","needs_review":true,"created_at":"2026-01-02T03:04:05Z"}]`)
+
+	out := captureStdout(t, func() { runReports(nil) })
+	if (*got)[0].path != "/admin/reports?limit=20" {
+		t.Errorf("path %s", (*got)[0].path)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "7 ") || !strings.Contains(lines[1], "fp-b") || !strings.HasSuffix(lines[1], "yes") {
+		t.Errorf("output %q", out)
+	}
+}
+
+func TestClientDo_Errors(t *testing.T) {
+	fakeAPI(t, http.StatusNotFound, `{"error":"session not found"}`)
+	c, _ := commonFlags(flag.NewFlagSet("test", flag.ContinueOnError))
+	if _, err := c.do("GET", "/admin/sessions/s1", nil); err == nil || err.Error() != "404 Not Found: session not found" {
+		t.Errorf("API error = %v", err)
+	}
+
+	fakeAPI(t, http.StatusBadGateway, `<html>`)
+	c, _ = commonFlags(flag.NewFlagSet("test", flag.ContinueOnError))
+	if _, err := c.do("GET", "/admin/sessions/s1", nil); err == nil || err.Error() != "unexpected status 502 Bad Gateway" {
+		t.Errorf("non-JSON error = %v", err)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "")
+	c, _ = commonFlags(flag.NewFlagSet("test", flag.ContinueOnError))
+	if _, err := c.do("GET", "/admin/sessions/s1", nil); err == nil {
+		t.Error("request without ADMIN_TOKEN succeeded")
+	}
+}
+
+func TestMaintenanceOff(t *testing.T) {
+	got := fakeAPI(t, http.StatusNoContent, "")
+
+	out := captureStdout(t, func() { runMaintenance([]string{"-actor", "alice", "-reason", "done", "off"}) })
+	if out != "maintenance mode is off\n" {
+		t.Errorf("output %q", out)
+	}
+	req := (*got)[0]
+	if req.method != "DELETE" || req.path != "/admin/maintenance" || req.body["actor"] != "alice" || req.body["reason"] != "done" {
+		t.Errorf("request %s %s %v", req.method, req.path, req.body)
+	}
+}
@@ -1,5 +1,7 @@
 import { WebSocketClient } from './websocket.svelte';
 import type {
+	ConfigMsg,
+	ContentPolicy,
 	MatchingStartedMsg,
 	MatchFoundMsg,
 	MatchAcceptedMsg,
@@ -8,8 +10,17 @@ import type {
 	ServerChatMsg,
 	ServerTypingMsg,
 	PartnerLeftMsg,
+	ChatSummaryMsg,
+	ErrorMsg,
+	RematchRequestedMsg,
+	ChatGapMsg,
 	BannedMsg,
-	RateLimitedMsg
+	RateLimitedMsg,
+	PartnerReadyMsg,
+	ServerShutdownMsg,
+	SessionCreatedMsg,
+	WaitingRoomMsg,
+	MaintenanceMsg
 } from './websocket.svelte';
 
 // Determine WebSocket URL based on environment
@@ -26,6 +37,8 @@ export interface ChatMessage {
 	from: 'me' | 'partner';
 	text: string;
 	ts: number;
+	translated?: string;
+	seq?: number;
 }
 
 /**
@@ -37,15 +50,41 @@ export class AppState {
 	chatId = $state<string | null>(null);
 	sharedInterests = $state<string[]>([]);
 	acceptDeadline = $state(0);
+	// Match preview: how many partner interests are not shared, and whether
+	// the partner already accepted.
+	partnerOtherInterests = $state(0);
+	partnerReady = $state(false);
 	matchTimeout = $state(0);
 	messages = $state<ChatMessage[]>([]);
 	partnerTyping = $state(false);
 	partnerLeft = $state(false);
+	partnerNickname = $state('');
+	partnerAvatarSeed = $state('');
+	summary = $state<ChatSummaryMsg | null>(null);
+	lostEvents = $state(0);
+	// Rematch of the ended chat: whether we asked, whether the partner did,
+	// and whether the offer is gone.
+	rematchRequested = $state(false);
+	partnerWantsRematch = $state(false);
+	rematchUnavailable = $state(false);
 	isBanned = $state(false);
 	banDuration = $state(0);
 	banReason = $state('');
 	isRateLimited = $state(false);
 	rateLimitRetryAfter = $state(0);
+	/** Unix time the server will close this connection for a restart; 0 when it is not shutting down. */
+	serverShutdownAt = $state(0);
+	/** Place in the server's waiting room; 0 once admitted. */
+	waitingPosition = $state(0);
+	/** Maintenance in progress; new matches are refused until it ends. */
+	maintenance = $state<MaintenanceMsg | null>(null);
+	// Server limits; the defaults apply until the config message arrives.
+	maxMessageChars = $state(2000);
+	maxMessageGraphemes = $state(0); // 0 when not limited
+	maxNicknameChars = $state(24);
+	typingDebounceMs = $state(2000);
+	// Feature flags from the server; a flag it did not send counts as on.
+	features = $state<Record<string, boolean>>({});
 
 	private unsubs: (() => void)[] = [];
 
@@ -55,6 +94,14 @@ export class AppState {
 
 	private wireEvents() {
 		this.unsubs.push(
+			ws.on<ConfigMsg>('config', (msg) => {
+				this.maxMessageChars = msg.max_message_chars;
+				this.maxMessageGraphemes = msg.max_message_graphemes ?? 0;
+				this.maxNicknameChars = msg.max_nickname_chars;
+				this.typingDebounceMs = msg.typing_debounce_ms;
+				this.features = msg.features ?? {};
+			}),
+
 			ws.on<MatchingStartedMsg>('matching_started', (msg) => {
 				this.screen = 'matching';
 				this.matchTimeout = msg.timeout;
@@ -65,6 +112,14 @@ export class AppState {
 				this.chatId = msg.chat_id;
 				this.sharedInterests = msg.shared_interests || [];
 				this.acceptDeadline = msg.accept_deadline;
+				this.partnerOtherInterests = msg.partner_other_interests ?? 0;
+				this.partnerReady = false;
+			}),
+
+			ws.on<PartnerReadyMsg>('partner_ready', (msg) => {
+				if (msg.chat_id === this.chatId) {
+					this.partnerReady = true;
+				}
 			}),
 
 			ws.on<MatchAcceptedMsg>('match_accepted', (msg) => {
@@ -73,6 +128,11 @@ export class AppState {
 				this.messages = [];
 				this.partnerTyping = false;
 				this.partnerLeft = false;
+				this.lostEvents = 0;
+				this.summary = null;
+				this.resetRematch();
+				this.partnerNickname = msg.partner_nickname || '';
+				this.partnerAvatarSeed = msg.partner_avatar_seed || '';
 			}),
 
 			ws.on<MatchDeclinedMsg>('match_declined', () => {
@@ -87,7 +147,27 @@ export class AppState {
 
 			ws.on<ServerChatMsg>('message', (msg) => {
 				if (this.screen === 'chatting') {
-					this.messages = [...this.messages, { from: 'partner', text: msg.text, ts: msg.ts }];
+					if (msg.nickname) {
+						this.partnerNickname = msg.nickname;
+						this.partnerAvatarSeed = msg.avatar_seed || '';
+					}
+					const entry: ChatMessage = {
+						from: 'partner',
+						text: msg.text,
+						ts: msg.ts,
+						translated: msg.translated,
+						seq: msg.seq
+					};
+					// A message that was overtaken in transit goes before the
+					// partner messages sent after it.
+					const later =
+						msg.seq === undefined
+							? -1
+							: this.messages.findIndex((m) => m.seq !== undefined && m.seq > msg.seq!);
+					this.messages =
+						later === -1
+							? [...this.messages, entry]
+							: [...this.messages.slice(0, later), entry, ...this.messages.slice(later)];
 				}
 			}),
 
@@ -100,6 +180,29 @@ export class AppState {
 				this.screen = 'chat_ended';
 			}),
 
+			ws.on<ChatSummaryMsg>('chat_summary', (msg) => {
+				this.summary = msg;
+			}),
+
+			ws.on<ChatGapMsg>('chat_gap', (msg) => {
+				if (msg.chat_id === this.chatId) {
+					this.lostEvents += msg.missing;
+				}
+			}),
+
+			ws.on<RematchRequestedMsg>('rematch_requested', (msg) => {
+				if (this.screen === 'chat_ended' && msg.chat_id === this.chatId) {
+					this.partnerWantsRematch = true;
+				}
+			}),
+
+			ws.on<ErrorMsg>('error', (msg) => {
+				if (msg.code === 'rematch_unavailable') {
+					this.rematchRequested = false;
+					this.rematchUnavailable = true;
+				}
+			}),
+
 			ws.on<BannedMsg>('banned', (msg) => {
 				this.isBanned = true;
 				this.banDuration = msg.duration;
@@ -107,6 +210,25 @@ export class AppState {
 				this.screen = 'banned';
 			}),
 
+			ws.on<ServerShutdownMsg>('server_shutdown', (msg) => {
+				this.serverShutdownAt = msg.deadline;
+			}),
+
+			ws.on<WaitingRoomMsg>('waiting_room', (msg) => {
+				this.waitingPosition = msg.position;
+			}),
+
+			ws.on<MaintenanceMsg>('maintenance', (msg) => {
+				this.maintenance = msg.active ? msg : null;
+			}),
+
+			// A new connection is on a server that is not shutting down, and
+			// out of the waiting room.
+			ws.on<SessionCreatedMsg>('session_created', () => {
+				this.serverShutdownAt = 0;
+				this.waitingPosition = 0;
+			}),
+
 			ws.on<RateLimitedMsg>('rate_limited', (msg) => {
 				this.isRateLimited = true;
 				this.rateLimitRetryAfter = msg.retry_after;
@@ -120,12 +242,13 @@ export class AppState {
 
 	// ----- Actions -----
 
-	startMatching(interests: string[]) {
+	startMatching(interests: string[], translate = false, policy?: ContentPolicy) {
 		ws.connect();
+		const language = translate ? navigator.language : undefined;
 		// Wait for connection before sending find_match
 		const checkAndSend = () => {
 			if (ws.state === 'connected') {
-				ws.findMatch(interests);
+				ws.findMatch(interests, language, policy);
 			} else {
 				setTimeout(checkAndSend, 100);
 			}
@@ -139,18 +262,23 @@ export class AppState {
 		this.resetChat();
 	}
 
-	acceptMatch() {
+	// An empty nickname lets the server generate one.
+	acceptMatch(nickname = '') {
 		if (this.chatId) {
-			ws.acceptMatch(this.chatId);
+			ws.acceptMatch(this.chatId, nickname.trim() || undefined);
 		}
 	}
 
-	declineMatch() {
+	// With reRoll the server re-queues us with the same interests and
+	// answers with matching_started, which switches the screen.
+	declineMatch(reRoll = false) {
 		if (this.chatId) {
-			ws.declineMatch(this.chatId);
+			ws.declineMatch(this.chatId, reRoll);
 		}
-		this.screen = 'idle';
 		this.resetChat();
+		if (!reRoll) {
+			this.screen = 'idle';
+		}
 	}
 
 	sendMessage(text: string) {
@@ -163,6 +291,19 @@ export class AppState {
 		}
 	}
 
+	// Mirrors the server's invalid_message length checks: code points, and
+	// user-perceived characters when the server limits those.
+	messageTooLong(text: string): boolean {
+		if ([...text].length > this.maxMessageChars) {
+			return true;
+		}
+		if (this.maxMessageGraphemes > 0) {
+			const graphemes = [...new Intl.Segmenter().segment(text)].length;
+			return graphemes > this.maxMessageGraphemes;
+		}
+		return false;
+	}
+
 	sendTyping(isTyping: boolean) {
 		if (this.chatId) {
 			ws.sendTyping(this.chatId, isTyping);
@@ -177,6 +318,20 @@ export class AppState {
 		this.screen = 'chat_ended';
 	}
 
+	// Both users have chat.RematchWindow (2 minutes) after the end to ask;
+	// the server then starts a new chat and sends match_accepted.
+	requestRematch() {
+		if (!this.chatId) {
+			return;
+		}
+		if (this.partnerWantsRematch) {
+			ws.acceptRematch(this.chatId);
+		} else {
+			ws.requestRematch(this.chatId);
+		}
+		this.rematchRequested = true;
+	}
+
 	findNewMatch() {
 		this.resetChat();
 		this.screen = 'idle';
@@ -190,6 +345,17 @@ export class AppState {
 		this.messages = [];
 		this.partnerTyping = false;
 		this.partnerLeft = false;
+		this.partnerNickname = '';
+		this.partnerAvatarSeed = '';
+		this.summary = null;
+		this.lostEvents = 0;
+		this.resetRematch();
+	}
+
+	private resetRematch() {
+		this.rematchRequested = false;
+		this.partnerWantsRematch = false;
+		this.rematchUnavailable = false;
 	}
 
 	destroy() {
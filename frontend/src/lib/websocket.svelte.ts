@@ -3,9 +3,14 @@ import { getFingerprint } from './fingerprint';
 // WebSocket connection states
 export type ConnectionState = 'disconnected' | 'connecting' | 'connected' | 'reconnecting';
 
+// Content policy preferences for find_match; a chat with a strict user also
+// filters profanity.
+export type ContentPolicy = 'strict' | 'standard';
+
 // Message types matching the Go protocol
 export type MessageType =
 	| 'set_fingerprint'
+	| 'client_info'
 	| 'find_match'
 	| 'cancel_match'
 	| 'accept_match'
@@ -14,37 +19,90 @@ export type MessageType =
 	| 'typing'
 	| 'end_chat'
 	| 'report'
+	| 'rematch_request'
+	| 'rematch_accept'
 	| 'ping'
 	| 'session_created'
+	| 'config'
 	| 'matching_started'
 	| 'match_found'
+	| 'partner_ready'
 	| 'match_accepted'
 	| 'match_declined'
 	| 'match_timeout'
 	| 'partner_left'
+	| 'chat_summary'
+	| 'chat_gap'
+	| 'rematch_requested'
 	| 'rate_limited'
 	| 'banned'
 	| 'error'
+	| 'server_shutdown'
+	| 'waiting_room'
+	| 'maintenance'
 	| 'pong';
 
 // Server message interfaces
 export interface SessionCreatedMsg {
 	type: 'session_created';
 	session_id: string;
+	/** Secret for resuming this session (?resume=&token=) or keeping it alive over HTTP. */
+	session_token?: string;
+	/** True when the connection resumed the session it asked for. */
+	resumed?: boolean;
+}
+/** Limits the server enforces, sent after session_created and on config reloads. */
+export interface ConfigMsg {
+	type: 'config';
+	/** Code points. */
+	max_message_chars: number;
+	/** User-perceived characters; absent when not limited. */
+	max_message_graphemes?: number;
+	max_message_bytes: number;
+	max_nickname_chars: number;
+	typing_debounce_ms: number;
+	accept_deadline: number;
+	/** Keyed by rule name, as in RateLimitedMsg.limit. Window is in seconds. */
+	rate_limits: Record<string, { limit: number; window: number }>;
+	/** Feature flags evaluated for this browser; sent again after set_fingerprint. */
+	features?: Record<string, boolean>;
 }
 export interface MatchingStartedMsg {
 	type: 'matching_started';
 	timeout: number;
+	priority?: boolean;
 }
 export interface MatchFoundMsg {
 	type: 'match_found';
 	chat_id: string;
 	shared_interests: string[];
 	accept_deadline: number;
+	/** How the pair was found: 'exact' | 'overlap' | 'single' | 'random'. */
+	match_tier?: string;
+	/** Seconds the partner waited in the queue. */
+	partner_wait: number;
+	/** The broadest tier the partner's wait reached. */
+	partner_wait_tier?: string;
+	/** The partner's deployment region, when matching is partitioned. */
+	partner_region?: string;
+	/** How many of the partner's interests are not shared (never which). */
+	partner_other_interests: number;
+	/** The content policy the chat's messages are filtered with. */
+	policy?: ContentPolicy;
+}
+export interface PartnerReadyMsg {
+	type: 'partner_ready';
+	chat_id: string;
 }
 export interface MatchAcceptedMsg {
 	type: 'match_accepted';
 	chat_id: string;
+	icebreaker?: string;
+	/** Our own and the partner's ephemeral identity for this chat. */
+	nickname?: string;
+	avatar_seed?: string;
+	partner_nickname?: string;
+	partner_avatar_seed?: string;
 }
 export interface MatchDeclinedMsg {
 	type: 'match_declined';
@@ -57,6 +115,15 @@ export interface ServerChatMsg {
 	from: string;
 	text: string;
 	ts: number;
+	/** Text translated into our declared language, when translation is on. */
+	translated?: string;
+	/** Language of text, set alongside translated. */
+	lang?: string;
+	/** The sender's nickname and avatar seed for this chat. */
+	nickname?: string;
+	avatar_seed?: string;
+	/** Position among the chat's events; messages may arrive out of order. */
+	seq?: number;
 }
 export interface ServerTypingMsg {
 	type: 'typing';
@@ -64,20 +131,82 @@ export interface ServerTypingMsg {
 }
 export interface PartnerLeftMsg {
 	type: 'partner_left';
+	/** Set when the server ended the chat itself. */
+	reason?: 'inactivity' | 'moderated';
+}
+/** Sent to both users when a chat ends; counts are from our side. */
+export interface ChatSummaryMsg {
+	type: 'chat_summary';
+	chat_id: string;
+	/** Seconds from both accepting to the end. */
+	duration: number;
+	messages_sent: number;
+	messages_received: number;
+	shared_interests: string[];
+}
+/** Chat events, such as partner messages, that were lost in transit. */
+export interface ChatGapMsg {
+	type: 'chat_gap';
+	chat_id: string;
+	missing: number;
+}
+/** The partner from an ended chat wants to chat again; answer with rematch_accept. */
+export interface RematchRequestedMsg {
+	type: 'rematch_requested';
+	chat_id: string;
 }
 export interface RateLimitedMsg {
 	type: 'rate_limited';
 	retry_after: number;
+	limit?: string;
 }
 export interface BannedMsg {
 	type: 'banned';
 	duration: number;
 	reason: string;
 }
+export type ErrorCategory =
+	| 'protocol'
+	| 'validation'
+	| 'state'
+	| 'unavailable'
+	| 'moderation'
+	| 'capacity';
 export interface ErrorMsg {
 	type: 'error';
 	code: string;
 	message: string;
+	category?: ErrorCategory;
+	/** HTTP-style status of the code, e.g. 409 for invalid_chat. */
+	status?: number;
+	/** Whether repeating the same request later may succeed. */
+	retryable: boolean;
+	/** The offending tags of an invalid_interests error. */
+	rejected?: { tag: string; reason: string }[];
+	/** Why an invalid_message error refused the text, and the limits it must fit. */
+	reason?: string;
+	limits?: { max_chars: number; max_graphemes?: number; max_bytes: number };
+}
+export interface ServerShutdownMsg {
+	type: 'server_shutdown';
+	/** Unix time (seconds) at which the server closes the connection with code 1001. */
+	deadline: number;
+	seconds_remaining: number;
+}
+/** Sent instead of session_created while the server is full, and every few seconds after. */
+export interface WaitingRoomMsg {
+	type: 'waiting_room';
+	/** Place in line, from 1. */
+	position: number;
+	waiting: number;
+}
+/** Sent after config while maintenance mode is on, and whenever it is switched on or off. */
+export interface MaintenanceMsg {
+	type: 'maintenance';
+	active: boolean;
+	message?: string;
+	/** Unix time (seconds) the maintenance should end; absent when unknown. */
+	eta?: number;
 }
 export interface PongMsg {
 	type: 'pong';
@@ -85,17 +214,24 @@ export interface PongMsg {
 
 export type ServerMessage =
 	| SessionCreatedMsg
+	| ConfigMsg
 	| MatchingStartedMsg
 	| MatchFoundMsg
+	| PartnerReadyMsg
 	| MatchAcceptedMsg
 	| MatchDeclinedMsg
 	| MatchTimeoutMsg
 	| ServerChatMsg
 	| ServerTypingMsg
 	| PartnerLeftMsg
+	| ChatSummaryMsg
+	| ChatGapMsg
+	| RematchRequestedMsg
 	| RateLimitedMsg
 	| BannedMsg
 	| ErrorMsg
+	| ServerShutdownMsg
+	| WaitingRoomMsg
 	| PongMsg;
 
 const PING_INTERVAL_MS = 25_000;
@@ -104,6 +240,13 @@ const BASE_RECONNECT_MS = 1000;
 const MAX_RECONNECT_MS = 30_000;
 const JITTER_MS = 5000;
 
+// Close codes after which reconnecting would be refused again (see
+// ARCHITECTURE.md Appendix A): banned, and too many sessions from this browser.
+// An idle timeout is not retried either; the next action reconnects.
+const CLOSE_POLICY_VIOLATION = 1008;
+const CLOSE_TOO_MANY_SESSIONS = 4002;
+const CLOSE_IDLE_TIMEOUT = 4003;
+
 export class WebSocketClient {
 	// Reactive state using Svelte 5 runes
 	private _state = $state<ConnectionState>('disconnected');
@@ -130,9 +273,11 @@ export class WebSocketClient {
 	constructor(url: string) {
 		this.url = url;
 
-		// Internal handler: capture session_id on session_created, then send fingerprint.
+		// Internal handler: capture session_id on session_created, then
+		// describe the client and send the fingerprint.
 		this.on<SessionCreatedMsg>('session_created', (msg) => {
 			this._sessionId = msg.session_id;
+			this.sendClientInfo();
 			this.sendFingerprint();
 		});
 	}
@@ -158,8 +303,16 @@ export class WebSocketClient {
 			this.handleMessage(event);
 		});
 
-		ws.addEventListener('close', () => {
+		ws.addEventListener('close', (event: CloseEvent) => {
 			this.cleanup();
+			if (
+				event.code === CLOSE_POLICY_VIOLATION ||
+				event.code === CLOSE_TOO_MANY_SESSIONS ||
+				event.code === CLOSE_IDLE_TIMEOUT
+			) {
+				this._state = 'disconnected';
+				return;
+			}
 			if (!this.intentionalDisconnect) {
 				this.scheduleReconnect();
 			}
@@ -220,6 +373,16 @@ export class WebSocketClient {
 
 	// ----- Convenience methods for client messages -----
 
+	/** Tell the server which app and locale this is, for bug reports. */
+	private sendClientInfo(): void {
+		this.send({
+			type: 'client_info',
+			platform: 'web',
+			app_version: __APP_VERSION__,
+			locale: typeof navigator !== 'undefined' ? navigator.language : ''
+		});
+	}
+
 	/** Send browser fingerprint to the server for ban enforcement. */
 	private sendFingerprint(): void {
 		getFingerprint().then((fingerprint) => {
@@ -227,20 +390,24 @@ export class WebSocketClient {
 		});
 	}
 
-	findMatch(interests: string[]): void {
-		this.send({ type: 'find_match', interests });
+	/**
+	 * Join the queue. A language opts in to translating partner messages; a
+	 * content policy only pairs us with users whose preference agrees.
+	 */
+	findMatch(interests: string[], language?: string, policy?: ContentPolicy): void {
+		this.send({ type: 'find_match', interests, language, policy });
 	}
 
 	cancelMatch(): void {
 		this.send({ type: 'cancel_match' });
 	}
 
-	acceptMatch(chatId: string): void {
-		this.send({ type: 'accept_match', chat_id: chatId });
+	acceptMatch(chatId: string, nickname?: string): void {
+		this.send({ type: 'accept_match', chat_id: chatId, ...(nickname && { nickname }) });
 	}
 
-	declineMatch(chatId: string): void {
-		this.send({ type: 'decline_match', chat_id: chatId });
+	declineMatch(chatId: string, reRoll = false): void {
+		this.send({ type: 'decline_match', chat_id: chatId, ...(reRoll && { re_roll: true }) });
 	}
 
 	sendMessage(chatId: string, text: string): void {
@@ -255,8 +422,17 @@ export class WebSocketClient {
 		this.send({ type: 'end_chat', chat_id: chatId });
 	}
 
-	report(chatId: string, reason: string): void {
-		this.send({ type: 'report', chat_id: chatId, reason });
+	/** Ask to chat again with the partner from the ended chat chatId. */
+	requestRematch(chatId: string): void {
+		this.send({ type: 'rematch_request', chat_id: chatId });
+	}
+
+	acceptRematch(chatId: string): void {
+		this.send({ type: 'rematch_accept', chat_id: chatId });
+	}
+
+	report(chatId: string, reason: string, details = ''): void {
+		this.send({ type: 'report', chat_id: chatId, reason, ...(details ? { details } : {}) });
 	}
 
 	// ----- Private methods -----
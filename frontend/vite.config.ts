@@ -3,6 +3,10 @@ import { defineConfig } from 'vite';
 
 export default defineConfig({
 	plugins: [sveltekit()],
+	define: {
+		// Sent to the server in client_info.
+		__APP_VERSION__: JSON.stringify(process.env.npm_package_version ?? '')
+	},
 	server: {
 		proxy: {
 			'/ws': {
@@ -0,0 +1,128 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/whisper/chat-app/internal/appeal"
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/ban"
+)
+
+// appealListLimit caps how many appeals are returned per status.
+const appealListLimit = 100
+
+// appealDecisionRequest is the body accepted by the appeal decision
+// endpoint. Decision is "lift" or "uphold"; Note is shown to the user.
+type appealDecisionRequest struct {
+	Actor    string `json:"actor"`
+	Decision string `json:"decision"`
+	Note     string `json:"note"`
+}
+
+// RegisterAppeals mounts the ban appeal endpoints:
+//
+//	GET  /admin/appeals                 appeals by ?status= (default pending), oldest first
+//	GET  /admin/appeals/{id}            one appeal
+//	POST /admin/appeals/{id}/decision   {"actor", "decision", "note"} lift or uphold the ban
+//
+// Lifting an appeal lifts the ban. Either decision is recorded in the audit
+// log and delivered to the user the next time they connect.
+func (h *Handler) RegisterAppeals(appealStore *appeal.Store, banStore *ban.Store, auditLog *audit.Logger) {
+	h.mux.HandleFunc("GET /admin/appeals", func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		switch status {
+		case "":
+			status = appeal.StatusPending
+		case appeal.StatusPending, appeal.StatusLifted, appeal.StatusUpheld:
+		default:
+			writeError(w, http.StatusBadRequest, "status must be pending, lifted or upheld")
+			return
+		}
+		list, err := appealStore.List(r.Context(), status, appealListLimit)
+		if err != nil {
+			log.Printf("[admin] appeals list: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load appeals")
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+
+	h.mux.HandleFunc("GET /admin/appeals/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid appeal id")
+			return
+		}
+		a, err := appealStore.Get(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, appeal.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "appeal not found")
+				return
+			}
+			log.Printf("[admin] appeal get: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load appeal")
+			return
+		}
+		writeJSON(w, http.StatusOK, a)
+	})
+
+	h.mux.HandleFunc("POST /admin/appeals/{id}/decision", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid appeal id")
+			return
+		}
+		var req appealDecisionRequest
+		if err := decodeJSON(r, &req); err != nil || req.Actor == "" {
+			writeError(w, http.StatusBadRequest, "actor and decision are required")
+			return
+		}
+		status, err := appeal.ParseDecision(req.Decision)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		a, err := appealStore.Decide(r.Context(), id, status, req.Actor, req.Note)
+		if err != nil {
+			switch {
+			case errors.Is(err, appeal.ErrNotFound):
+				writeError(w, http.StatusNotFound, "appeal not found")
+			case errors.Is(err, appeal.ErrDecided):
+				writeError(w, http.StatusConflict, "appeal already decided")
+			default:
+				log.Printf("[admin] appeal decide: %v", err)
+				writeError(w, http.StatusInternalServerError, "failed to decide appeal")
+			}
+			return
+		}
+		if status == appeal.StatusLifted {
+			if err := banStore.Unban(r.Context(), a.Fingerprint); err != nil {
+				// The decision is recorded; lifting the ban by hand finishes it.
+				log.Printf("[admin] appeal %d unban fp=%s: %v", id, a.Fingerprint, err)
+				writeError(w, http.StatusInternalServerError, "appeal lifted but the ban could not be removed; retry with DELETE /admin/bans/{fingerprint}")
+				return
+			}
+		}
+
+		event := &audit.Event{
+			Action:            audit.ActionAppealDecided,
+			Actor:             audit.AdminActor(req.Actor),
+			TargetFingerprint: a.Fingerprint,
+			Reason:            req.Note,
+			Context: map[string]interface{}{
+				"appeal_id":  a.ID,
+				"decision":   status,
+				"ban_reason": a.BanReason,
+			},
+		}
+		if err := auditLog.Record(r.Context(), event); err != nil {
+			log.Printf("[audit] failed to record appeal %d decision actor=%s: %v", id, req.Actor, err)
+		}
+		log.Printf("[admin] appeal %d %s fp=%s actor=%s", id, status, a.Fingerprint, req.Actor)
+		writeJSON(w, http.StatusOK, a)
+	})
+}
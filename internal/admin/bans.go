@@ -0,0 +1,274 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/ban"
+)
+
+// auditListLimit caps how many audit events are returned per fingerprint.
+const auditListLimit = 200
+
+// banStatusResponse describes the current ban on a fingerprint.
+type banStatusResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Banned      bool   `json:"banned"`
+	Remaining   int    `json:"remaining_seconds,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// banRequest is the body accepted by the fingerprint ban endpoint. A zero
+// duration bans until lifted.
+type banRequest struct {
+	DurationSeconds int    `json:"duration_seconds"`
+	Actor           string `json:"actor"`
+	Reason          string `json:"reason"`
+}
+
+// unbanRequest is the body accepted by the unban endpoint. Actor names the
+// operator lifting the ban and is written to the audit log.
+type unbanRequest struct {
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+}
+
+// networkBanRequest is the body accepted by the network ban endpoints.
+// Network is an IP address or CIDR range; a zero duration bans until lifted.
+type networkBanRequest struct {
+	Network         string `json:"network"`
+	DurationSeconds int    `json:"duration_seconds"`
+	Actor           string `json:"actor"`
+	Reason          string `json:"reason"`
+}
+
+// banList is the import/export document.
+type banList struct {
+	Bans []ban.Entry `json:"bans"`
+}
+
+// importRequest is the body accepted by the import endpoint.
+type importRequest struct {
+	Actor string      `json:"actor"`
+	Bans  []ban.Entry `json:"bans"`
+}
+
+// RegisterBans mounts the ban management and audit endpoints:
+//
+//	GET    /admin/bans/{fingerprint}               current ban, if any
+//	POST   /admin/bans/{fingerprint}               {"duration_seconds", "actor", "reason"} ban a fingerprint
+//	DELETE /admin/bans/{fingerprint}               {"actor", "reason"} lift a ban
+//	POST   /admin/bans/networks                    {"network", "duration_seconds", "actor", "reason"} ban an IP or CIDR
+//	DELETE /admin/bans/networks                    {"network", "actor", "reason"} lift a network ban
+//	GET    /admin/bans/export                      all active bans as {"bans": [...]}
+//	POST   /admin/bans/import                      {"actor", "bans": [...]} apply an exported list
+//	GET    /admin/fingerprints/{fingerprint}/audit audit events, newest first
+//
+// Every ban, unban, network ban and import is recorded in the audit log. A
+// fingerprint ban is enforced when a client next sends set_fingerprint, as it
+// does on connecting; live sessions keep their connection until disconnected.
+func (h *Handler) RegisterBans(banStore *ban.Store, auditLog *audit.Logger) {
+	h.mux.HandleFunc("GET /admin/bans/{fingerprint}", func(w http.ResponseWriter, r *http.Request) {
+		fp := r.PathValue("fingerprint")
+		banned, remaining, reason, err := banStore.IsBanned(r.Context(), fp)
+		if err != nil {
+			log.Printf("[admin] ban lookup: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load ban")
+			return
+		}
+		writeJSON(w, http.StatusOK, banStatusResponse{
+			Fingerprint: fp,
+			Banned:      banned,
+			Remaining:   remaining,
+			Reason:      reason,
+		})
+	})
+
+	h.mux.HandleFunc("POST /admin/bans/{fingerprint}", func(w http.ResponseWriter, r *http.Request) {
+		var req banRequest
+		if err := decodeJSON(r, &req); err != nil || req.Actor == "" || req.DurationSeconds < 0 {
+			writeError(w, http.StatusBadRequest, "actor and a non-negative duration_seconds are required")
+			return
+		}
+		fp := r.PathValue("fingerprint")
+		duration := time.Duration(req.DurationSeconds) * time.Second
+		if err := banStore.Ban(r.Context(), fp, duration, req.Reason); err != nil {
+			log.Printf("[admin] ban: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to ban fingerprint")
+			return
+		}
+
+		recordAudit(r.Context(), auditLog, &audit.Event{
+			Action:            audit.ActionAdminBan,
+			Actor:             audit.AdminActor(req.Actor),
+			TargetFingerprint: fp,
+			Reason:            req.Reason,
+			Context: map[string]interface{}{
+				"duration_seconds": req.DurationSeconds,
+			},
+		})
+		log.Printf("[admin] fingerprint banned fp=%s actor=%s duration=%s reason=%q", fp, req.Actor, duration, req.Reason)
+		writeJSON(w, http.StatusCreated, banStatusResponse{
+			Fingerprint: fp,
+			Banned:      true,
+			Remaining:   req.DurationSeconds,
+			Reason:      req.Reason,
+		})
+	})
+
+	h.mux.HandleFunc("DELETE /admin/bans/{fingerprint}", func(w http.ResponseWriter, r *http.Request) {
+		var req unbanRequest
+		if err := decodeJSON(r, &req); err != nil || req.Actor == "" {
+			writeError(w, http.StatusBadRequest, "actor is required")
+			return
+		}
+		fp := r.PathValue("fingerprint")
+
+		banned, remaining, banReason, err := banStore.IsBanned(r.Context(), fp)
+		if err != nil {
+			log.Printf("[admin] ban lookup: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load ban")
+			return
+		}
+		if !banned {
+			writeError(w, http.StatusNotFound, "fingerprint is not banned")
+			return
+		}
+		if err := banStore.Unban(r.Context(), fp); err != nil {
+			log.Printf("[admin] unban: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to lift ban")
+			return
+		}
+
+		event := &audit.Event{
+			Action:            audit.ActionAdminUnban,
+			Actor:             audit.AdminActor(req.Actor),
+			TargetFingerprint: fp,
+			Reason:            req.Reason,
+			Context: map[string]interface{}{
+				"ban_reason":        banReason,
+				"remaining_seconds": remaining,
+			},
+		}
+		if err := auditLog.Record(r.Context(), event); err != nil {
+			log.Printf("[audit] failed to record unban fp=%s actor=%s: %v", fp, req.Actor, err)
+		}
+		log.Printf("[admin] ban lifted fp=%s actor=%s reason=%q", fp, req.Actor, req.Reason)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	h.mux.HandleFunc("POST /admin/bans/networks", func(w http.ResponseWriter, r *http.Request) {
+		var req networkBanRequest
+		if err := decodeJSON(r, &req); err != nil || req.Actor == "" || req.DurationSeconds < 0 {
+			writeError(w, http.StatusBadRequest, "network, actor and a non-negative duration_seconds are required")
+			return
+		}
+		prefix, err := ban.ParseNetwork(req.Network)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		duration := time.Duration(req.DurationSeconds) * time.Second
+		if err := banStore.BanNetwork(r.Context(), prefix, duration, req.Reason); err != nil {
+			log.Printf("[admin] network ban: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to ban network")
+			return
+		}
+
+		event := &audit.Event{
+			Action: audit.ActionAdminBan,
+			Actor:  audit.AdminActor(req.Actor),
+			Reason: req.Reason,
+			Context: map[string]interface{}{
+				"network":          prefix.String(),
+				"duration_seconds": req.DurationSeconds,
+			},
+		}
+		if err := auditLog.Record(r.Context(), event); err != nil {
+			log.Printf("[audit] failed to record network ban %s actor=%s: %v", prefix, req.Actor, err)
+		}
+		log.Printf("[admin] network banned %s actor=%s duration=%s reason=%q", prefix, req.Actor, duration, req.Reason)
+		writeJSON(w, http.StatusCreated, struct {
+			Network string `json:"network"`
+		}{prefix.String()})
+	})
+
+	h.mux.HandleFunc("DELETE /admin/bans/networks", func(w http.ResponseWriter, r *http.Request) {
+		var req networkBanRequest
+		if err := decodeJSON(r, &req); err != nil || req.Actor == "" {
+			writeError(w, http.StatusBadRequest, "network and actor are required")
+			return
+		}
+		prefix, err := ban.ParseNetwork(req.Network)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := banStore.UnbanNetwork(r.Context(), prefix); err != nil {
+			log.Printf("[admin] network unban: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to lift ban")
+			return
+		}
+
+		event := &audit.Event{
+			Action:  audit.ActionAdminUnban,
+			Actor:   audit.AdminActor(req.Actor),
+			Reason:  req.Reason,
+			Context: map[string]interface{}{"network": prefix.String()},
+		}
+		if err := auditLog.Record(r.Context(), event); err != nil {
+			log.Printf("[audit] failed to record network unban %s actor=%s: %v", prefix, req.Actor, err)
+		}
+		log.Printf("[admin] network ban lifted %s actor=%s reason=%q", prefix, req.Actor, req.Reason)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	h.mux.HandleFunc("GET /admin/bans/export", func(w http.ResponseWriter, r *http.Request) {
+		entries, err := banStore.Export(r.Context())
+		if err != nil {
+			log.Printf("[admin] ban export: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to export bans")
+			return
+		}
+		writeJSON(w, http.StatusOK, banList{Bans: entries})
+	})
+
+	h.mux.HandleFunc("POST /admin/bans/import", func(w http.ResponseWriter, r *http.Request) {
+		var req importRequest
+		if err := decodeJSON(r, &req); err != nil || req.Actor == "" {
+			writeError(w, http.StatusBadRequest, "actor and bans are required")
+			return
+		}
+		result, err := banStore.Import(r.Context(), req.Bans)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		event := &audit.Event{
+			Action: audit.ActionAdminBanImport,
+			Actor:  audit.AdminActor(req.Actor),
+			Context: map[string]interface{}{
+				"imported": result.Imported,
+				"skipped":  result.Skipped,
+			},
+		}
+		if err := auditLog.Record(r.Context(), event); err != nil {
+			log.Printf("[audit] failed to record ban import actor=%s: %v", req.Actor, err)
+		}
+		log.Printf("[admin] bans imported actor=%s imported=%d skipped=%d", req.Actor, result.Imported, result.Skipped)
+		writeJSON(w, http.StatusOK, result)
+	})
+
+	h.mux.HandleFunc("GET /admin/fingerprints/{fingerprint}/audit", func(w http.ResponseWriter, r *http.Request) {
+		events, err := auditLog.ListByFingerprint(r.Context(), r.PathValue("fingerprint"), auditListLimit)
+		if err != nil {
+			log.Printf("[admin] audit list: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load audit log")
+			return
+		}
+		writeJSON(w, http.StatusOK, events)
+	})
+}
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/ban"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func newBanHandler(t *testing.T) (*Handler, *ban.Store) {
+	t.Helper()
+	bans := ban.NewStore(testutil.Redis(t))
+	h := NewHandler(testToken)
+	h.RegisterBans(bans, nil)
+	return h, bans
+}
+
+func TestBanFingerprint(t *testing.T) {
+	h, bans := newBanHandler(t)
+
+	rec := serve(t, h, "POST", "/admin/bans/fp-1", `{"duration_seconds":3600,"actor":"alice","reason":"spam"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got banStatusResponse
+	decode(t, rec, &got)
+	if got != (banStatusResponse{Fingerprint: "fp-1", Banned: true, Remaining: 3600, Reason: "spam"}) {
+		t.Errorf("response = %+v", got)
+	}
+	banned, remaining, reason, err := bans.IsBanned(context.Background(), "fp-1")
+	if err != nil || !banned || reason != "spam" || remaining <= 0 || remaining > 3600 {
+		t.Errorf("IsBanned(fp-1) = %v, %d, %q, %v", banned, remaining, reason, err)
+	}
+
+	rec = serve(t, h, "GET", "/admin/bans/fp-1", "")
+	decode(t, rec, &got)
+	if rec.Code != http.StatusOK || !got.Banned || got.Reason != "spam" {
+		t.Errorf("GET: status %d, %+v", rec.Code, got)
+	}
+}
+
+func TestBanFingerprint_Rejected(t *testing.T) {
+	h, bans := newBanHandler(t)
+
+	for name, body := range map[string]string{
+		"no actor":          `{"duration_seconds":60,"reason":"spam"}`,
+		"negative duration": `{"duration_seconds":-1,"actor":"alice"}`,
+		"invalid JSON":      `{`,
+	} {
+		if rec := serve(t, h, "POST", "/admin/bans/fp-1", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, rec.Code)
+		}
+	}
+	if banned, _, _, _ := bans.IsBanned(context.Background(), "fp-1"); banned {
+		t.Error("a rejected request banned the fingerprint")
+	}
+}
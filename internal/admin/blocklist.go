@@ -0,0 +1,111 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/whisper/chat-app/internal/moderation"
+)
+
+// termRequest is the body accepted by the blocklist and allowlist mutation
+// endpoints. The language and match options only apply to the blocklist.
+type termRequest struct {
+	Term          string `json:"term"`
+	Language      string `json:"language"`
+	WordBoundary  bool   `json:"word_boundary"`
+	CaseSensitive bool   `json:"case_sensitive"`
+}
+
+// RegisterBlocklist mounts the dynamic blocklist endpoints:
+//
+//	GET    /admin/blocklist  list runtime additions, removals and options (default and per language) and the allowlist
+//	POST   /admin/blocklist  {"term", "language", "word_boundary", "case_sensitive"} block a term
+//	DELETE /admin/blocklist  {"term", "language"} unblock a term
+//	GET    /admin/allowlist  list words and phrases that are never blocked
+//	POST   /admin/allowlist  {"term": "..."} never block a word or phrase
+//	DELETE /admin/allowlist  {"term": "..."} remove it from the allowlist
+//
+// A term with a language only applies to messages in that language; see
+// moderation.Filter.CheckLanguage. Changes are propagated to every wsserver and moderator within seconds.
+func (h *Handler) RegisterBlocklist(filter *moderation.DynamicFilter) {
+	h.mux.HandleFunc("GET /admin/blocklist", func(w http.ResponseWriter, r *http.Request) {
+		lists, err := filter.Lists(r.Context())
+		if err != nil {
+			log.Printf("[admin] blocklist list: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load blocklist")
+			return
+		}
+		writeJSON(w, http.StatusOK, lists)
+	})
+
+	h.mux.HandleFunc("POST /admin/blocklist", func(w http.ResponseWriter, r *http.Request) {
+		var req termRequest
+		if err := decodeJSON(r, &req); err != nil || req.Term == "" {
+			writeError(w, http.StatusBadRequest, "term is required")
+			return
+		}
+		opts := moderation.TermOptions{WordBoundary: req.WordBoundary, CaseSensitive: req.CaseSensitive}
+		if err := filter.AddLanguageTerm(r.Context(), req.Language, req.Term, opts); err != nil {
+			log.Printf("[admin] blocklist add: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to add term")
+			return
+		}
+		log.Printf("[admin] blocklist term added: %q language=%q %+v", req.Term, req.Language, opts)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	h.mux.HandleFunc("DELETE /admin/blocklist", func(w http.ResponseWriter, r *http.Request) {
+		var req termRequest
+		if err := decodeJSON(r, &req); err != nil || req.Term == "" {
+			writeError(w, http.StatusBadRequest, "term is required")
+			return
+		}
+		if err := filter.RemoveLanguageTerm(r.Context(), req.Language, req.Term); err != nil {
+			log.Printf("[admin] blocklist remove: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to remove term")
+			return
+		}
+		log.Printf("[admin] blocklist term removed: %q language=%q", req.Term, req.Language)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	h.mux.HandleFunc("GET /admin/allowlist", func(w http.ResponseWriter, r *http.Request) {
+		lists, err := filter.Lists(r.Context())
+		if err != nil {
+			log.Printf("[admin] allowlist list: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load allowlist")
+			return
+		}
+		writeJSON(w, http.StatusOK, lists.Allowed)
+	})
+
+	h.mux.HandleFunc("POST /admin/allowlist", func(w http.ResponseWriter, r *http.Request) {
+		var req termRequest
+		if err := decodeJSON(r, &req); err != nil || req.Term == "" {
+			writeError(w, http.StatusBadRequest, "term is required")
+			return
+		}
+		if err := filter.AllowTerm(r.Context(), req.Term); err != nil {
+			log.Printf("[admin] allowlist add: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to allow term")
+			return
+		}
+		log.Printf("[admin] allowlist term added: %q", req.Term)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	h.mux.HandleFunc("DELETE /admin/allowlist", func(w http.ResponseWriter, r *http.Request) {
+		var req termRequest
+		if err := decodeJSON(r, &req); err != nil || req.Term == "" {
+			writeError(w, http.StatusBadRequest, "term is required")
+			return
+		}
+		if err := filter.DisallowTerm(r.Context(), req.Term); err != nil {
+			log.Printf("[admin] allowlist remove: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to remove term")
+			return
+		}
+		log.Printf("[admin] allowlist term removed: %q", req.Term)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
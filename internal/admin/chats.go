@@ -0,0 +1,251 @@
+package admin
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/ban"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/report"
+	"github.com/whisper/chat-app/internal/session"
+)
+
+// terminateRequest is the body accepted by the terminate endpoint.
+// BanSessionID optionally names the participant to ban; a zero
+// BanDurationSeconds bans until lifted.
+type terminateRequest struct {
+	Actor              string `json:"actor"`
+	Reason             string `json:"reason"`
+	BanSessionID       string `json:"ban_session_id"`
+	BanDurationSeconds int    `json:"ban_duration_seconds"`
+}
+
+// terminateResponse reports what the terminate endpoint did.
+type terminateResponse struct {
+	ChatID            string `json:"chat_id"`
+	EvidenceID        int64  `json:"evidence_id"`
+	MessagesCaptured  int    `json:"messages_captured"`
+	BannedFingerprint string `json:"banned_fingerprint,omitempty"`
+}
+
+// chatResponse is a chat as shown to operators. Messages are never included;
+// capture them as evidence by terminating the chat.
+type chatResponse struct {
+	ChatID         string   `json:"chat_id"`
+	Status         string   `json:"status"`
+	UserA          string   `json:"user_a"`
+	UserB          string   `json:"user_b"`
+	Tier           string   `json:"tier,omitempty"`
+	Interests      []string `json:"interests,omitempty"`
+	Policy         string   `json:"policy"`
+	CreatedAt      int64    `json:"created_at"`
+	AcceptDeadline int64    `json:"accept_deadline,omitempty"`
+	ActivatedAt    int64    `json:"activated_at,omitempty"`
+}
+
+// ChatControl is what the chat termination endpoint needs from the server
+// it runs in: the chat and session stores, and the parts of a chat that live
+// in that server's memory and subscriptions.
+type ChatControl struct {
+	Chats    *chat.Store
+	Sessions *session.Store
+	// Transcript returns the retained messages of a chat, oldest first.
+	Transcript func(ctx context.Context, chatID string) []chat.BufferedMessage
+	// End deletes the chat and sends both participants partner_left with
+	// reason "moderated". If the chat cannot be deleted, it returns the
+	// error and tells no one.
+	End func(ctx context.Context, cs *chat.ChatSession) error
+	// Banned sends a session the banned message and disconnects it,
+	// wherever it is connected.
+	Banned func(ctx context.Context, sessionID string, duration time.Duration, reason string)
+}
+
+// RegisterChats mounts the chat moderation endpoints:
+//
+//	GET  /admin/chats/{chat_id}            participants and state of a live chat
+//	POST /admin/chats/{chat_id}/terminate  {"actor", "reason", "ban_session_id", "ban_duration_seconds"} end a chat
+//	GET  /admin/chats/{chat_id}/evidence   transcripts captured when the chat was terminated
+//
+// Terminating captures the chat's transcript as evidence, bans the named
+// participant if any, tells both participants the chat was ended by a
+// moderator and deletes it. The steps run in that order and stop at the
+// first failure, so a failed call leaves the chat running and can be
+// retried; evidence captured by a failed call is kept.
+func (h *Handler) RegisterChats(ctl ChatControl, reportStore report.Store, banStore *ban.Store, auditLog *audit.Logger) {
+	h.mux.HandleFunc("GET /admin/chats/{chat_id}", func(w http.ResponseWriter, r *http.Request) {
+		cs, err := ctl.Chats.Get(r.Context(), r.PathValue("chat_id"))
+		if err != nil {
+			log.Printf("[admin] chat lookup: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load chat")
+			return
+		}
+		if cs == nil {
+			writeError(w, http.StatusNotFound, "chat not found")
+			return
+		}
+		resp := chatResponse{
+			ChatID:      cs.ChatID,
+			Status:      cs.Status,
+			UserA:       cs.UserA,
+			UserB:       cs.UserB,
+			Tier:        cs.Tier,
+			Interests:   cs.Interests,
+			Policy:      cs.Policy,
+			CreatedAt:   cs.CreatedAt,
+			ActivatedAt: cs.ActivatedAt,
+		}
+		if cs.Status == chat.StatusPendingAccept {
+			resp.AcceptDeadline = cs.AcceptDeadline
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	h.mux.HandleFunc("POST /admin/chats/{chat_id}/terminate", func(w http.ResponseWriter, r *http.Request) {
+		var req terminateRequest
+		if err := decodeJSON(r, &req); err != nil || req.Actor == "" || req.BanDurationSeconds < 0 {
+			writeError(w, http.StatusBadRequest, "actor and a non-negative ban_duration_seconds are required")
+			return
+		}
+		ctx := r.Context()
+		chatID := r.PathValue("chat_id")
+
+		cs, err := ctl.Chats.Get(ctx, chatID)
+		if err != nil {
+			log.Printf("[admin] chat lookup: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load chat")
+			return
+		}
+		if cs == nil {
+			writeError(w, http.StatusNotFound, "chat not found")
+			return
+		}
+		if req.BanSessionID != "" && !cs.IsParticipant(req.BanSessionID) {
+			writeError(w, http.StatusBadRequest, "ban_session_id is not in this chat")
+			return
+		}
+
+		fpA, fpB := participantFingerprint(ctx, ctl.Sessions, cs.UserA), participantFingerprint(ctx, ctl.Sessions, cs.UserB)
+		var bannedFP string
+		switch req.BanSessionID {
+		case "":
+		case cs.UserA:
+			bannedFP = fpA
+		default:
+			bannedFP = fpB
+		}
+		if req.BanSessionID != "" && bannedFP == "" {
+			writeError(w, http.StatusConflict, "participant has no fingerprint to ban")
+			return
+		}
+
+		evidence := &report.Evidence{
+			ChatID:            chatID,
+			Actor:             audit.AdminActor(req.Actor),
+			Reason:            req.Reason,
+			FingerprintA:      fpA,
+			FingerprintB:      fpB,
+			BannedFingerprint: bannedFP,
+			Messages:          anonymise(cs, ctl.Transcript(ctx, chatID)),
+		}
+		if err := reportStore.CreateEvidence(ctx, evidence); err != nil {
+			log.Printf("[admin] capture evidence chat=%s: %v", chatID, err)
+			writeError(w, http.StatusInternalServerError, "failed to capture evidence")
+			return
+		}
+
+		duration := time.Duration(req.BanDurationSeconds) * time.Second
+		if bannedFP != "" {
+			if err := banStore.Ban(ctx, bannedFP, duration, req.Reason); err != nil {
+				log.Printf("[admin] ban fp=%s: %v", bannedFP, err)
+				writeError(w, http.StatusInternalServerError, "failed to ban participant")
+				return
+			}
+			recordAudit(ctx, auditLog, &audit.Event{
+				Action:            audit.ActionAdminBan,
+				Actor:             audit.AdminActor(req.Actor),
+				TargetFingerprint: bannedFP,
+				Reason:            req.Reason,
+				Context: map[string]interface{}{
+					"chat_id":          chatID,
+					"duration_seconds": req.BanDurationSeconds,
+				},
+			})
+		}
+
+		if err := ctl.End(ctx, cs); err != nil {
+			log.Printf("[admin] end chat=%s: %v", chatID, err)
+			writeError(w, http.StatusInternalServerError, "failed to end chat")
+			return
+		}
+		if bannedFP != "" {
+			ctl.Banned(ctx, req.BanSessionID, duration, req.Reason)
+		}
+
+		recordAudit(ctx, auditLog, &audit.Event{
+			Action:            audit.ActionAdminTerminate,
+			Actor:             audit.AdminActor(req.Actor),
+			TargetFingerprint: bannedFP,
+			Reason:            req.Reason,
+			Context: map[string]interface{}{
+				"chat_id":           chatID,
+				"evidence_id":       evidence.ID,
+				"messages_captured": len(evidence.Messages),
+				"fingerprint_a":     fpA,
+				"fingerprint_b":     fpB,
+			},
+		})
+		log.Printf("[admin] chat %s terminated actor=%s evidence=%d banned_fp=%q reason=%q",
+			chatID, req.Actor, evidence.ID, bannedFP, req.Reason)
+		writeJSON(w, http.StatusOK, terminateResponse{
+			ChatID:            chatID,
+			EvidenceID:        evidence.ID,
+			MessagesCaptured:  len(evidence.Messages),
+			BannedFingerprint: bannedFP,
+		})
+	})
+
+	h.mux.HandleFunc("GET /admin/chats/{chat_id}/evidence", func(w http.ResponseWriter, r *http.Request) {
+		list, err := reportStore.ListEvidence(r.Context(), r.PathValue("chat_id"))
+		if err != nil {
+			log.Printf("[admin] evidence list: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load evidence")
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+}
+
+// participantFingerprint returns the fingerprint of a chat participant, or
+// "" if its session is gone or never sent one.
+func participantFingerprint(ctx context.Context, sessions *session.Store, sessionID string) string {
+	sess, err := sessions.Get(ctx, sessionID)
+	if err != nil || sess == nil {
+		return ""
+	}
+	return sess.Fingerprint
+}
+
+// anonymise labels the senders of a chat's messages "user_a" and "user_b",
+// as in the message snapshots attached to reports.
+func anonymise(cs *chat.ChatSession, messages []chat.BufferedMessage) []report.MessageEntry {
+	entries := make([]report.MessageEntry, len(messages))
+	for i, m := range messages {
+		from := "user_b"
+		if m.From == cs.UserA {
+			from = "user_a"
+		}
+		entries[i] = report.MessageEntry{From: from, Text: m.Text, Ts: m.Ts}
+	}
+	return entries
+}
+
+// recordAudit writes an audit event, logging rather than failing the
+// request if it cannot be stored.
+func recordAudit(ctx context.Context, auditLog *audit.Logger, event *audit.Event) {
+	if err := auditLog.Record(ctx, event); err != nil {
+		log.Printf("[audit] failed to record %s actor=%s: %v", event.Action, event.Actor, err)
+	}
+}
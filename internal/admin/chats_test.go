@@ -0,0 +1,252 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/ban"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/report"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestAnonymise(t *testing.T) {
+	cs := &chat.ChatSession{ChatID: "chat-1", UserA: "sess-a", UserB: "sess-b"}
+	entries := anonymise(cs, []chat.BufferedMessage{
+		{From: "sess-b", Text: "hey", Ts: 1},
+		{From: "sess-a", Text: "hello", Ts: 2},
+	})
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].From != "user_b" || entries[0].Text != "hey" || entries[0].Ts != 1 {
+		t.Errorf("entry 0 = %+v", entries[0])
+	}
+	if entries[1].From != "user_a" || entries[1].Text != "hello" {
+		t.Errorf("entry 1 = %+v", entries[1])
+	}
+}
+
+// fakeReports stores evidence in memory. Other report.Store methods are not
+// used by the chat endpoints and panic.
+type fakeReports struct {
+	report.Store
+	evidence []report.Evidence
+	err      error
+}
+
+func (f *fakeReports) CreateEvidence(ctx context.Context, e *report.Evidence) error {
+	if f.err != nil {
+		return f.err
+	}
+	e.ID = int64(len(f.evidence) + 1)
+	f.evidence = append(f.evidence, *e)
+	return nil
+}
+
+func (f *fakeReports) ListEvidence(ctx context.Context, chatID string) ([]report.Evidence, error) {
+	var list []report.Evidence
+	for _, e := range f.evidence {
+		if e.ChatID == chatID {
+			list = append(list, e)
+		}
+	}
+	return list, f.err
+}
+
+// banCall records a ChatControl.Banned call.
+type banCall struct {
+	sessionID string
+	duration  time.Duration
+}
+
+// chatFixture serves the chat endpoints over Redis: chat "c1" is active
+// between sessions "a" (fingerprint "fp-a") and "b" (no fingerprint).
+type chatFixture struct {
+	h       *Handler
+	chats   *chat.Store
+	bans    *ban.Store
+	reports *fakeReports
+	ended   []string
+	banned  []banCall
+	endErr  error
+}
+
+func newChatFixture(t *testing.T) *chatFixture {
+	t.Helper()
+	rdb := testutil.Redis(t)
+	ctx := context.Background()
+	f := &chatFixture{
+		h:       NewHandler(testToken),
+		chats:   chat.NewStore(rdb),
+		bans:    ban.NewStore(rdb),
+		reports: &fakeReports{},
+	}
+	sessions := session.NewStoreWithClient(rdb, "ws-1")
+	for sid, fp := range map[string]string{"a": "fp-a", "b": ""} {
+		if _, err := sessions.Create(ctx, sid); err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		if fp != "" {
+			sessions.SetFingerprint(ctx, sid, fp)
+		}
+	}
+	if err := f.chats.CreatePending(ctx, "c1", "a", "b", "", "exact", []string{"music"}, chat.PolicyStandard); err != nil {
+		t.Fatalf("create chat: %v", err)
+	}
+
+	f.h.RegisterChats(ChatControl{
+		Chats:    f.chats,
+		Sessions: sessions,
+		Transcript: func(ctx context.Context, chatID string) []chat.BufferedMessage {
+			return []chat.BufferedMessage{{From: "b", Text: "you are awful", Ts: 1}}
+		},
+		End: func(ctx context.Context, cs *chat.ChatSession) error {
+			if f.endErr != nil {
+				return f.endErr
+			}
+			f.ended = append(f.ended, cs.ChatID)
+			return f.chats.Delete(ctx, cs.ChatID)
+		},
+		Banned: func(ctx context.Context, sid string, duration time.Duration, reason string) {
+			f.banned = append(f.banned, banCall{sid, duration})
+		},
+	}, f.reports, f.bans, nil)
+	return f
+}
+
+func TestGetChat(t *testing.T) {
+	f := newChatFixture(t)
+
+	rec := serve(t, f.h, "GET", "/admin/chats/c1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got chatResponse
+	decode(t, rec, &got)
+	if got.ChatID != "c1" || got.UserA != "a" || got.UserB != "b" || got.Status != chat.StatusPendingAccept ||
+		got.Tier != "exact" || got.AcceptDeadline == 0 || len(got.Interests) != 1 {
+		t.Errorf("chat = %+v", got)
+	}
+
+	if rec := serve(t, f.h, "GET", "/admin/chats/missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing chat: status %d, want 404", rec.Code)
+	}
+}
+
+func TestTerminateChat(t *testing.T) {
+	f := newChatFixture(t)
+
+	rec := serve(t, f.h, "POST", "/admin/chats/c1/terminate", `{"actor":"alice","reason":"threats"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got terminateResponse
+	decode(t, rec, &got)
+	if got.ChatID != "c1" || got.EvidenceID != 1 || got.MessagesCaptured != 1 || got.BannedFingerprint != "" {
+		t.Errorf("response = %+v", got)
+	}
+	if len(f.ended) != 1 || len(f.banned) != 0 {
+		t.Errorf("ended %v, banned %v; want the chat ended and no one banned", f.ended, f.banned)
+	}
+	e := f.reports.evidence[0]
+	if e.Actor != "admin:alice" || e.FingerprintA != "fp-a" || e.Messages[0].From != "user_b" {
+		t.Errorf("evidence = %+v", e)
+	}
+	if cs, _ := f.chats.Get(context.Background(), "c1"); cs != nil {
+		t.Error("the chat was not deleted")
+	}
+
+	if rec := serve(t, f.h, "POST", "/admin/chats/c1/terminate", `{"actor":"alice"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("terminating again: status %d, want 404", rec.Code)
+	}
+}
+
+func TestTerminateChat_BanSession(t *testing.T) {
+	f := newChatFixture(t)
+
+	rec := serve(t, f.h, "POST", "/admin/chats/c1/terminate",
+		`{"actor":"alice","reason":"threats","ban_session_id":"a","ban_duration_seconds":3600}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got terminateResponse
+	decode(t, rec, &got)
+	if got.BannedFingerprint != "fp-a" {
+		t.Errorf("banned fingerprint %q, want fp-a", got.BannedFingerprint)
+	}
+	banned, remaining, reason, err := f.bans.IsBanned(context.Background(), "fp-a")
+	if err != nil || !banned || reason != "threats" || remaining <= 0 || remaining > 3600 {
+		t.Errorf("IsBanned(fp-a) = %v, %d, %q, %v", banned, remaining, reason, err)
+	}
+	if len(f.banned) != 1 || f.banned[0] != (banCall{"a", time.Hour}) {
+		t.Errorf("Banned calls = %+v, want session a for 1h", f.banned)
+	}
+	if f.reports.evidence[0].BannedFingerprint != "fp-a" {
+		t.Errorf("evidence does not record the ban: %+v", f.reports.evidence[0])
+	}
+}
+
+func TestTerminateChat_Rejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		setup  func(f *chatFixture)
+		status int
+	}{
+		{"no actor", "/admin/chats/c1/terminate", `{"reason":"x"}`, nil, http.StatusBadRequest},
+		{"negative duration", "/admin/chats/c1/terminate", `{"actor":"alice","ban_duration_seconds":-1}`, nil, http.StatusBadRequest},
+		{"missing chat", "/admin/chats/nope/terminate", `{"actor":"alice"}`, nil, http.StatusNotFound},
+		{"ban outsider", "/admin/chats/c1/terminate", `{"actor":"alice","ban_session_id":"z"}`, nil, http.StatusBadRequest},
+		{"ban without fingerprint", "/admin/chats/c1/terminate", `{"actor":"alice","ban_session_id":"b"}`, nil, http.StatusConflict},
+		{
+			"evidence fails", "/admin/chats/c1/terminate", `{"actor":"alice","ban_session_id":"a"}`,
+			func(f *chatFixture) { f.reports.err = errors.New("db down") }, http.StatusInternalServerError,
+		},
+		{
+			"end fails", "/admin/chats/c1/terminate", `{"actor":"alice","ban_session_id":"a"}`,
+			func(f *chatFixture) { f.endErr = errors.New("redis down") }, http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newChatFixture(t)
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			rec := serve(t, f.h, "POST", tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if len(f.ended) != 0 || len(f.banned) != 0 {
+				t.Errorf("ended %v, banned %v; want neither", f.ended, f.banned)
+			}
+			if tt.status != http.StatusInternalServerError || f.endErr != nil {
+				return
+			}
+			if banned, _, _, _ := f.bans.IsBanned(context.Background(), "fp-a"); banned {
+				t.Error("banned although the evidence was not captured")
+			}
+		})
+	}
+}
+
+func TestChatEvidence(t *testing.T) {
+	f := newChatFixture(t)
+	serve(t, f.h, "POST", "/admin/chats/c1/terminate", `{"actor":"alice"}`)
+
+	rec := serve(t, f.h, "GET", "/admin/chats/c1/evidence", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var list []report.Evidence
+	decode(t, rec, &list)
+	if len(list) != 1 || list[0].ChatID != "c1" {
+		t.Errorf("evidence = %+v", list)
+	}
+}
@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/whisper/chat-app/internal/featureflag"
+)
+
+// featureRequest is the body accepted by the feature flag update endpoint.
+type featureRequest struct {
+	Percent       int      `json:"percent"`
+	Allow         []string `json:"allow"`
+	MinAppVersion string   `json:"min_app_version"`
+}
+
+// featuresResponse lists the stored rollouts alongside the flags the
+// wsserver evaluates and their built-in defaults.
+type featuresResponse struct {
+	Flags []featureflag.Flag `json:"flags"`
+	Known map[string]bool    `json:"known"`
+}
+
+// RegisterFeatures mounts the feature flag endpoints:
+//
+//	GET    /admin/features         stored rollouts and built-in defaults
+//	PUT    /admin/features/{name}  {"percent", "allow", "min_app_version"} roll a flag out
+//	DELETE /admin/features/{name}  return a flag to its default
+//
+// Changes reach every wsserver within seconds, and connected clients are
+// sent their features again.
+func (h *Handler) RegisterFeatures(flags *featureflag.Set) {
+	h.mux.HandleFunc("GET /admin/features", func(w http.ResponseWriter, r *http.Request) {
+		list, err := flags.List(r.Context())
+		if err != nil {
+			log.Printf("[admin] feature list: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load feature flags")
+			return
+		}
+		writeJSON(w, http.StatusOK, featuresResponse{Flags: list, Known: featureflag.Known})
+	})
+
+	h.mux.HandleFunc("PUT /admin/features/{name}", func(w http.ResponseWriter, r *http.Request) {
+		var req featureRequest
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid feature flag")
+			return
+		}
+		flag := featureflag.Flag{
+			Name:          r.PathValue("name"),
+			Percent:       req.Percent,
+			Allow:         req.Allow,
+			MinAppVersion: req.MinAppVersion,
+		}
+		if err := flag.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := flags.Put(r.Context(), flag); err != nil {
+			log.Printf("[admin] feature put: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to store feature flag")
+			return
+		}
+		log.Printf("[admin] feature %s rolled out to %d%% (+%d allowlisted, min app version %q)",
+			flag.Name, flag.Percent, len(flag.Allow), flag.MinAppVersion)
+		writeJSON(w, http.StatusOK, flag)
+	})
+
+	h.mux.HandleFunc("DELETE /admin/features/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		found, err := flags.Delete(r.Context(), name)
+		if err != nil {
+			log.Printf("[admin] feature delete: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to delete feature flag")
+			return
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "feature flag not found")
+			return
+		}
+		log.Printf("[admin] feature %s returned to its default", name)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
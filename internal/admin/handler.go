@@ -0,0 +1,73 @@
+// Package admin implements the operator-facing HTTP API used to manage a
+// running Whisper deployment. All endpoints live under /admin/ and require a
+// bearer token configured through ADMIN_TOKEN; feature areas register their
+// routes on a shared Handler.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Handler is the admin API root. It authenticates every request against the
+// configured token before routing it to a registered endpoint.
+type Handler struct {
+	token string
+	mux   *http.ServeMux
+}
+
+// NewHandler creates an admin Handler that accepts requests carrying
+// "Authorization: Bearer <token>". The token must not be empty.
+func NewHandler(token string) *Handler {
+	return &Handler{
+		token: token,
+		mux:   http.NewServeMux(),
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// authorized reports whether the request carries the admin bearer token.
+// The comparison is constant-time to avoid leaking the token via timing.
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	provided, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(h.token)) == 1
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[admin] encode response: %v", err)
+	}
+}
+
+// writeError sends a JSON error body of the form {"error": "..."}.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{Error: message})
+}
+
+// decodeJSON decodes the request body into v, limiting the body to 1 MiB.
+func decodeJSON(r *http.Request, v interface{}) error {
+	return json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,52 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "test-token"
+
+// serve sends an authorized request to h and returns the recorded response.
+// A non-empty body is sent as the request body.
+func serve(t *testing.T, h *Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode unmarshals a recorded JSON response into v.
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+}
+
+func TestHandler_RequiresToken(t *testing.T) {
+	h := NewHandler(testToken)
+	h.mux.HandleFunc("GET /admin/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, auth := range []string{"", "Bearer wrong", "Basic " + testToken} {
+		req := httptest.NewRequest("GET", "/admin/ping", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", auth, rec.Code)
+		}
+	}
+	if rec := serve(t, h, "GET", "/admin/ping", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("valid token: status %d, want 204", rec.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/maintenance"
+)
+
+// maintenanceRequest is the body accepted by the maintenance switch-on
+// endpoint.
+type maintenanceRequest struct {
+	Message string `json:"message"`
+	ETA     int64  `json:"eta"` // unix seconds; 0 if unknown
+	Actor   string `json:"actor"`
+}
+
+// maintenanceOffRequest is the body accepted by the maintenance switch-off
+// endpoint.
+type maintenanceOffRequest struct {
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+}
+
+// RegisterMaintenance mounts the maintenance mode endpoints:
+//
+//	GET    /admin/maintenance  current state ({"active": false} when off)
+//	PUT    /admin/maintenance  {"message", "eta", "actor"} switch it on, or
+//	                           update the message and ETA
+//	DELETE /admin/maintenance  {"actor", "reason"} switch it off
+//
+// The state is stored in Redis and reaches every wsserver and matcher within
+// seconds. While it is on, find_match is refused and the matcher stops
+// pairing; chats in progress are not affected. Switching it on, updating it
+// and switching it off are recorded in the audit log.
+func (h *Handler) RegisterMaintenance(mode *maintenance.Mode, auditLog *audit.Logger) {
+	h.mux.HandleFunc("GET /admin/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		if err := mode.Reload(r.Context()); err != nil {
+			log.Printf("[admin] maintenance get: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load maintenance state")
+			return
+		}
+		writeJSON(w, http.StatusOK, mode.State())
+	})
+
+	h.mux.HandleFunc("PUT /admin/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		var req maintenanceRequest
+		if err := decodeJSON(r, &req); err != nil || req.Actor == "" {
+			writeError(w, http.StatusBadRequest, "actor is required")
+			return
+		}
+		now := time.Now()
+		state := maintenance.State{Message: req.Message, ETA: req.ETA, Actor: req.Actor}
+		if err := state.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.ETA != 0 && req.ETA <= now.Unix() {
+			writeError(w, http.StatusBadRequest, "eta must be in the future")
+			return
+		}
+		state, err := mode.Enable(r.Context(), state, now)
+		if err != nil {
+			log.Printf("[admin] maintenance put: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to store maintenance state")
+			return
+		}
+		recordAudit(r.Context(), auditLog, &audit.Event{
+			Action: audit.ActionAdminMaintenanceOn,
+			Actor:  audit.AdminActor(req.Actor),
+			Reason: state.Message,
+			Context: map[string]interface{}{
+				"eta":        state.ETA,
+				"started_at": state.StartedAt,
+			},
+		})
+		log.Printf("[admin] maintenance on actor=%s eta=%d", state.Actor, state.ETA)
+		writeJSON(w, http.StatusOK, state)
+	})
+
+	h.mux.HandleFunc("DELETE /admin/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		var req maintenanceOffRequest
+		if err := decodeJSON(r, &req); err != nil || req.Actor == "" {
+			writeError(w, http.StatusBadRequest, "actor is required")
+			return
+		}
+		found, err := mode.Disable(r.Context())
+		if err != nil {
+			log.Printf("[admin] maintenance delete: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to clear maintenance state")
+			return
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "maintenance mode is not on")
+			return
+		}
+		recordAudit(r.Context(), auditLog, &audit.Event{
+			Action: audit.ActionAdminMaintenanceOff,
+			Actor:  audit.AdminActor(req.Actor),
+			Reason: req.Reason,
+		})
+		log.Printf("[admin] maintenance off actor=%s reason=%q", req.Actor, req.Reason)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
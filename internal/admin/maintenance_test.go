@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/maintenance"
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestMaintenance(t *testing.T) {
+	rdb := testutil.Redis(t)
+	bus := messaging.NewRedisBus(rdb, messaging.DefaultRedisBusConfig())
+	t.Cleanup(bus.Close)
+	mode := maintenance.NewMode(rdb, bus)
+	h := NewHandler(testToken)
+	h.RegisterMaintenance(mode, nil)
+
+	eta := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"on without actor", "PUT", `{"message":"upgrading","eta":` + eta + `}`, http.StatusBadRequest},
+		{"on with a past eta", "PUT", `{"actor":"alice","eta":1}`, http.StatusBadRequest},
+		{"on", "PUT", `{"message":"upgrading","eta":` + eta + `,"actor":"alice"}`, http.StatusOK},
+		{"off without actor", "DELETE", `{"reason":"done"}`, http.StatusBadRequest},
+		{"off", "DELETE", `{"actor":"alice","reason":"done"}`, http.StatusNoContent},
+		{"off again", "DELETE", `{"actor":"alice"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, tt.method, "/admin/maintenance", tt.body)
+		if rec.Code != tt.status {
+			t.Fatalf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+		}
+		if tt.name != "on" {
+			continue
+		}
+		var state maintenance.State
+		decode(t, rec, &state)
+		if !state.Active || state.Actor != "alice" || state.Message != "upgrading" {
+			t.Errorf("state = %+v", state)
+		}
+		if err := mode.Reload(context.Background()); err != nil || !mode.Active() {
+			t.Errorf("maintenance is not on after PUT: %v", err)
+		}
+	}
+	if err := mode.Reload(context.Background()); err != nil || mode.Active() {
+		t.Errorf("maintenance is still on after DELETE: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/whisper/chat-app/internal/matching"
+	"github.com/whisper/chat-app/internal/messaging"
+)
+
+// queueListLimit is the default and maximum number of queue entries listed.
+const queueListLimit = 500
+
+// queueEntryResponse is a queued session as shown to operators.
+type queueEntryResponse struct {
+	SessionID   string   `json:"session_id"`
+	Interests   []string `json:"interests"`
+	Server      string   `json:"server"`
+	Region      string   `json:"region,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	Policy      string   `json:"policy,omitempty"`
+	JoinedAt    int64    `json:"joined_at"` // unix milliseconds
+	WaitSeconds int64    `json:"wait_seconds"`
+}
+
+// RegisterMatching mounts the matcher tuning and inspection endpoints:
+//
+//	GET /admin/matching/queue?limit=N  queued sessions, longest waiting first (default and max 500)
+//	GET /admin/matching/tiers  thresholds the matcher last reported
+//	PUT /admin/matching/tiers  {"tier1_max_wait_ms", "tier2_max_wait_ms",
+//	                            "tier3_max_wait_ms", "match_timeout_ms"}
+//
+// Updates are broadcast on match.tiers and applied by every matcher on its
+// next pass. They last until the matcher restarts; set the MATCH_TIER*
+// variables to make them permanent.
+func (h *Handler) RegisterMatching(bus messaging.Bus, matchStatus *matching.StatusTracker, queue *matching.Queue) {
+	h.mux.HandleFunc("GET /admin/matching/queue", func(w http.ResponseWriter, r *http.Request) {
+		limit := queueListLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > queueListLimit {
+				writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(queueListLimit))
+				return
+			}
+			limit = n
+		}
+		entries, err := queue.ListQueued(r.Context(), limit)
+		if err != nil {
+			log.Printf("[admin] queue list: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load queue")
+			return
+		}
+		now := time.Now().UnixMilli()
+		list := make([]queueEntryResponse, len(entries))
+		for i, e := range entries {
+			joined := int64(e.JoinedAt)
+			list[i] = queueEntryResponse{
+				SessionID:   e.SessionID,
+				Interests:   e.Interests,
+				Server:      e.Server,
+				Region:      e.Region,
+				Fingerprint: e.Fingerprint,
+				Policy:      e.Policy,
+				JoinedAt:    joined,
+				WaitSeconds: (now - joined) / 1000,
+			}
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+
+	h.mux.HandleFunc("GET /admin/matching/tiers", func(w http.ResponseWriter, r *http.Request) {
+		stats, ok := matchStatus.Stats()
+		if !ok || stats.Tiers == nil {
+			writeError(w, http.StatusServiceUnavailable, "no stats received from the matcher yet")
+			return
+		}
+		writeJSON(w, http.StatusOK, stats.Tiers)
+	})
+
+	h.mux.HandleFunc("PUT /admin/matching/tiers", func(w http.ResponseWriter, r *http.Request) {
+		var tiers matching.TierConfig
+		if err := decodeJSON(r, &tiers); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid tiers")
+			return
+		}
+		if err := tiers.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		data, _ := json.Marshal(tiers)
+		if err := bus.PublishMatchTiers(data); err != nil {
+			log.Printf("[admin] publish tiers: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to publish tiers")
+			return
+		}
+		log.Printf("[admin] matching tiers set to %s", tiers)
+		writeJSON(w, http.StatusAccepted, tiers)
+	})
+}
+
+// matchEventsResponse is a session's match timeline as shown to support.
+type matchEventsResponse struct {
+	SessionID string           `json:"session_id"`
+	Events    []matching.Event `json:"events"`
+}
+
+// RegisterMatchEvents mounts the match timeline endpoint:
+//
+//	GET /admin/matching/events/{session_id}  match lifecycle events of a session, oldest first
+//
+// The timeline records queueing, each matching pass that found nobody and
+// the highest tier it tried, matches, accepts, declines and timeouts, so
+// support can answer why a user was not matched. It outlives the session by
+// matching.EventsTTL and holds up to matching.EventsMaxLen events.
+func (h *Handler) RegisterMatchEvents(events *matching.EventLog) {
+	h.mux.HandleFunc("GET /admin/matching/events/{session_id}", func(w http.ResponseWriter, r *http.Request) {
+		sid := r.PathValue("session_id")
+		list, err := events.Timeline(r.Context(), sid)
+		if err != nil {
+			log.Printf("[admin] match events: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load match events")
+			return
+		}
+		if len(list) == 0 {
+			writeError(w, http.StatusNotFound, "no match events for this session")
+			return
+		}
+		writeJSON(w, http.StatusOK, matchEventsResponse{SessionID: sid, Events: list})
+	})
+}
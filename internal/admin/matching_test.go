@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/matching"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestListQueue(t *testing.T) {
+	ctx := context.Background()
+	queue := matching.NewQueue(testutil.Redis(t))
+	clock := matching.NewManualClock(time.Now().Add(-time.Minute))
+	queue.SetClock(clock)
+	if err := queue.EnqueueFrom(ctx, "s1", "ws-1", "eu", []string{"music"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	clock.Advance(30 * time.Second)
+	if err := queue.EnqueueFrom(ctx, "s2", "ws-2", "", []string{"chess"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	h := NewHandler(testToken)
+	h.RegisterMatching(nil, matching.NewStatusTracker(), queue)
+
+	rec := serve(t, h, "GET", "/admin/matching/queue", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got []queueEntryResponse
+	decode(t, rec, &got)
+	if len(got) != 2 || got[0].SessionID != "s1" || got[1].SessionID != "s2" {
+		t.Fatalf("queue = %+v, want s1 then s2", got)
+	}
+	if e := got[0]; e.Server != "ws-1" || e.Region != "eu" || len(e.Interests) != 1 || e.WaitSeconds < 59 {
+		t.Errorf("entry = %+v", e)
+	}
+
+	rec = serve(t, h, "GET", "/admin/matching/queue?limit=1", "")
+	decode(t, rec, &got)
+	if len(got) != 1 || got[0].SessionID != "s1" {
+		t.Errorf("limit=1: %+v, want s1 only", got)
+	}
+	for _, limit := range []string{"0", "501", "x"} {
+		if rec := serve(t, h, "GET", "/admin/matching/queue?limit="+limit, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s: status %d, want 400", limit, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/whisper/chat-app/internal/notes"
+	"github.com/whisper/chat-app/internal/report"
+)
+
+// reportListLimit caps how many reports are returned per fingerprint.
+const reportListLimit = 100
+
+// noteRequest is the body accepted by the note creation endpoint.
+type noteRequest struct {
+	Author string   `json:"author"`
+	Note   string   `json:"note"`
+	Tags   []string `json:"tags"`
+}
+
+// fingerprintReportsResponse pairs the reports filed against a fingerprint
+// with the moderator notes attached to it.
+type fingerprintReportsResponse struct {
+	Fingerprint string          `json:"fingerprint"`
+	Reports     []report.Record `json:"reports"`
+	Notes       []notes.Note    `json:"notes"`
+}
+
+// timelineEntry is one event in a fingerprint's moderation timeline. Exactly
+// one of Report or Note is set, as indicated by Kind.
+type timelineEntry struct {
+	Kind   string         `json:"kind"` // "report" or "note"
+	At     time.Time      `json:"at"`
+	Report *report.Record `json:"report,omitempty"`
+	Note   *notes.Note    `json:"note,omitempty"`
+}
+
+// RegisterNotes mounts the moderator notes endpoints:
+//
+//	GET    /admin/fingerprints/{fingerprint}/notes     list notes
+//	POST   /admin/fingerprints/{fingerprint}/notes     {"author", "note", "tags"} add a note
+//	DELETE /admin/notes/{id}                           delete a note
+//	GET    /admin/fingerprints/{fingerprint}/reports   reports with notes alongside
+//	GET    /admin/fingerprints/{fingerprint}/timeline  reports and notes, newest first
+//
+// noteStore is nil when the database cannot hold notes (MySQL, SQLite): the
+// notes endpoints are then not mounted and the history endpoints return
+// reports only.
+func (h *Handler) RegisterNotes(noteStore *notes.Store, reportStore report.Store) {
+	if noteStore != nil {
+		h.registerNoteEdits(noteStore)
+	}
+
+	h.mux.HandleFunc("GET /admin/fingerprints/{fingerprint}/reports", func(w http.ResponseWriter, r *http.Request) {
+		fp := r.PathValue("fingerprint")
+		reports, noteList, ok := loadFingerprintHistory(w, r, fp, noteStore, reportStore)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, fingerprintReportsResponse{
+			Fingerprint: fp,
+			Reports:     reports,
+			Notes:       noteList,
+		})
+	})
+
+	h.mux.HandleFunc("GET /admin/fingerprints/{fingerprint}/timeline", func(w http.ResponseWriter, r *http.Request) {
+		reports, noteList, ok := loadFingerprintHistory(w, r, r.PathValue("fingerprint"), noteStore, reportStore)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, buildTimeline(reports, noteList))
+	})
+}
+
+// registerNoteEdits mounts the endpoints that list, add and delete notes.
+func (h *Handler) registerNoteEdits(noteStore *notes.Store) {
+	h.mux.HandleFunc("GET /admin/fingerprints/{fingerprint}/notes", func(w http.ResponseWriter, r *http.Request) {
+		list, err := noteStore.ListByFingerprint(r.Context(), r.PathValue("fingerprint"))
+		if err != nil {
+			log.Printf("[admin] notes list: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load notes")
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+
+	h.mux.HandleFunc("POST /admin/fingerprints/{fingerprint}/notes", func(w http.ResponseWriter, r *http.Request) {
+		var req noteRequest
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		note := &notes.Note{
+			Fingerprint: r.PathValue("fingerprint"),
+			Author:      req.Author,
+			Text:        req.Note,
+			Tags:        req.Tags,
+		}
+		if err := noteStore.Create(r.Context(), note); err != nil {
+			log.Printf("[admin] notes create: %v", err)
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("[admin] note %d added fp=%s author=%s tags=%v", note.ID, note.Fingerprint, note.Author, note.Tags)
+		writeJSON(w, http.StatusCreated, note)
+	})
+
+	h.mux.HandleFunc("DELETE /admin/notes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid note id")
+			return
+		}
+		if err := noteStore.Delete(r.Context(), id); err != nil {
+			if errors.Is(err, notes.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "note not found")
+				return
+			}
+			log.Printf("[admin] notes delete: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to delete note")
+			return
+		}
+		log.Printf("[admin] note %d deleted", id)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// loadFingerprintHistory fetches the reports and notes for a fingerprint,
+// writing an error response and returning ok=false on failure.
+func loadFingerprintHistory(w http.ResponseWriter, r *http.Request, fp string, noteStore *notes.Store, reportStore report.Store) ([]report.Record, []notes.Note, bool) {
+	reports, err := reportStore.ListAgainst(r.Context(), fp, reportListLimit)
+	if err != nil {
+		log.Printf("[admin] reports list: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load reports")
+		return nil, nil, false
+	}
+	if noteStore == nil {
+		return reports, []notes.Note{}, true
+	}
+	noteList, err := noteStore.ListByFingerprint(r.Context(), fp)
+	if err != nil {
+		log.Printf("[admin] notes list: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to load notes")
+		return nil, nil, false
+	}
+	return reports, noteList, true
+}
+
+// buildTimeline merges reports and notes into a single list ordered newest
+// first. Entries with the same timestamp keep reports ahead of notes.
+func buildTimeline(reports []report.Record, noteList []notes.Note) []timelineEntry {
+	entries := make([]timelineEntry, 0, len(reports)+len(noteList))
+	for i := range reports {
+		entries = append(entries, timelineEntry{Kind: "report", At: reports[i].CreatedAt, Report: &reports[i]})
+	}
+	for i := range noteList {
+		entries = append(entries, timelineEntry{Kind: "note", At: noteList[i].CreatedAt, Note: &noteList[i]})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].At.After(entries[j].At)
+	})
+	return entries
+}
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/notes"
+	"github.com/whisper/chat-app/internal/report"
+)
+
+func TestBuildTimeline_NewestFirst(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	reports := []report.Record{
+		{ID: 1, Reason: "spam", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: 2, Reason: "harassment", CreatedAt: base},
+	}
+	noteList := []notes.Note{
+		{ID: 10, Text: "verified false positive", CreatedAt: base.Add(time.Hour)},
+		{ID: 11, Text: "suspected ban evader", CreatedAt: base},
+	}
+
+	entries := buildTimeline(reports, noteList)
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+
+	want := []struct {
+		kind string
+		id   int64
+	}{
+		{"report", 1},
+		{"note", 10},
+		{"report", 2},
+		{"note", 11},
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Kind != w.kind {
+			t.Errorf("entry %d: expected kind %q, got %q", i, w.kind, e.Kind)
+			continue
+		}
+		var id int64
+		if e.Report != nil {
+			id = e.Report.ID
+		} else if e.Note != nil {
+			id = e.Note.ID
+		}
+		if id != w.id {
+			t.Errorf("entry %d: expected id %d, got %d", i, w.id, id)
+		}
+	}
+}
+
+func TestBuildTimeline_Empty(t *testing.T) {
+	entries := buildTimeline(nil, nil)
+	if entries == nil || len(entries) != 0 {
+		t.Errorf("expected empty non-nil timeline, got %v", entries)
+	}
+}
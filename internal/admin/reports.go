@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/whisper/chat-app/internal/report"
+)
+
+// RegisterReports mounts the report listing and review queue endpoints:
+//
+//	GET  /admin/reports?limit=N           most recent reports, newest first (default and max 100)
+//	GET  /admin/reports/review            unresolved reports escalated to review, oldest first
+//	POST /admin/reports/{id}/resolve      remove a report from the review queue
+func (h *Handler) RegisterReports(reportStore report.Store) {
+	h.mux.HandleFunc("GET /admin/reports", func(w http.ResponseWriter, r *http.Request) {
+		limit := reportListLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > reportListLimit {
+				writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(reportListLimit))
+				return
+			}
+			limit = n
+		}
+		list, err := reportStore.ListRecent(r.Context(), limit)
+		if err != nil {
+			log.Printf("[admin] report list: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load reports")
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+
+	h.mux.HandleFunc("GET /admin/reports/review", func(w http.ResponseWriter, r *http.Request) {
+		list, err := reportStore.ListNeedsReview(r.Context(), reportListLimit)
+		if err != nil {
+			log.Printf("[admin] review list: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load review queue")
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+
+	h.mux.HandleFunc("POST /admin/reports/{id}/resolve", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid report id")
+			return
+		}
+		if err := reportStore.ResolveReview(r.Context(), id); err != nil {
+			if errors.Is(err, report.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "report not awaiting review")
+				return
+			}
+			log.Printf("[admin] review resolve: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to resolve report")
+			return
+		}
+		log.Printf("[admin] report %d resolved", id)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/report"
+)
+
+// recentReports serves ListRecent from a fixed list, newest first.
+type recentReports struct {
+	report.Store
+	records []report.Record
+	err     error
+	limit   int
+}
+
+func (r *recentReports) ListRecent(ctx context.Context, limit int) ([]report.Record, error) {
+	r.limit = limit
+	if limit < len(r.records) {
+		return r.records[:limit], r.err
+	}
+	return r.records, r.err
+}
+
+func TestListReports(t *testing.T) {
+	reports := &recentReports{records: []report.Record{
+		{ID: 2, ReportedFingerprint: "fp-b", Category: report.CategoryHarassment},
+		{ID: 1, ReportedFingerprint: "fp-a"},
+	}}
+	h := NewHandler(testToken)
+	h.RegisterReports(reports)
+
+	rec := serve(t, h, "GET", "/admin/reports", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got []report.Record
+	decode(t, rec, &got)
+	if len(got) != 2 || got[0].ID != 2 || got[0].ReportedFingerprint != "fp-b" || reports.limit != reportListLimit {
+		t.Errorf("reports = %+v (limit %d)", got, reports.limit)
+	}
+
+	rec = serve(t, h, "GET", "/admin/reports?limit=1", "")
+	decode(t, rec, &got)
+	if len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("limit=1: %+v, want report 2 only", got)
+	}
+	for _, limit := range []string{"0", "101", "x"} {
+		if rec := serve(t, h, "GET", "/admin/reports?limit="+limit, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s: status %d, want 400", limit, rec.Code)
+		}
+	}
+
+	reports.err = errors.New("db down")
+	if rec := serve(t, h, "GET", "/admin/reports", ""); rec.Code != http.StatusInternalServerError {
+		t.Errorf("store error: status %d, want 500", rec.Code)
+	}
+}
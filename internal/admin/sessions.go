@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/session"
+)
+
+// sessionResponse is a session as shown to operators. The session token is
+// never included.
+type sessionResponse struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	ChatID      string `json:"chat_id,omitempty"`
+	Server      string `json:"server"`
+	Region      string `json:"region,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Platform    string `json:"platform,omitempty"`
+	AppVersion  string `json:"app_version,omitempty"`
+	Locale      string `json:"locale,omitempty"`
+	Country     string `json:"country,omitempty"`
+	GeoRegion   string `json:"geo_region,omitempty"`
+	Policy      string `json:"policy,omitempty"`
+	CreatedAt   int64  `json:"created_at"`
+	LastActive  int64  `json:"last_active"`
+	DetachedAt  int64  `json:"detached_at,omitempty"`
+}
+
+// RegisterSessions mounts the session lookup endpoint:
+//
+//	GET /admin/sessions/{session_id}  state and declared client of a live session
+//
+// platform, app_version and locale are what the client sent in client_info,
+// for correlating bug reports with the app they came from. country and
+// geo_region are where the client's IP is located, when wsserver has a GeoIP
+// database.
+func (h *Handler) RegisterSessions(sessions *session.Store) {
+	h.mux.HandleFunc("GET /admin/sessions/{session_id}", func(w http.ResponseWriter, r *http.Request) {
+		sess, err := sessions.Get(r.Context(), r.PathValue("session_id"))
+		if err != nil {
+			log.Printf("[admin] session lookup: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load session")
+			return
+		}
+		if sess == nil {
+			writeError(w, http.StatusNotFound, "session not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, sessionResponse{
+			ID:          sess.ID,
+			Status:      sess.Status,
+			ChatID:      sess.ChatID,
+			Server:      sess.Server,
+			Region:      sess.Region,
+			Fingerprint: sess.Fingerprint,
+			Platform:    sess.Platform,
+			AppVersion:  sess.AppVersion,
+			Locale:      sess.Locale,
+			Country:     sess.Country,
+			GeoRegion:   sess.GeoRegion,
+			Policy:      sess.Policy,
+			CreatedAt:   sess.CreatedAt,
+			LastActive:  sess.LastActive,
+			DetachedAt:  sess.DetachedAt,
+		})
+	})
+}
+
+// disconnectRequest is the body accepted by the disconnect endpoint.
+type disconnectRequest struct {
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+}
+
+// RegisterDisconnect mounts the endpoint that closes a live session:
+//
+//	POST /admin/sessions/{session_id}/disconnect  {"actor", "reason"} close its connection
+//
+// disconnect tells the client it was disconnected and closes its connection,
+// wherever it is connected; the client is not expected to reconnect on its
+// own. The disconnect is recorded in the audit log against the session's
+// fingerprint.
+func (h *Handler) RegisterDisconnect(sessions *session.Store, disconnect func(ctx context.Context, sessionID string), auditLog *audit.Logger) {
+	h.mux.HandleFunc("POST /admin/sessions/{session_id}/disconnect", func(w http.ResponseWriter, r *http.Request) {
+		var req disconnectRequest
+		if err := decodeJSON(r, &req); err != nil || req.Actor == "" {
+			writeError(w, http.StatusBadRequest, "actor is required")
+			return
+		}
+		sid := r.PathValue("session_id")
+		sess, err := sessions.Get(r.Context(), sid)
+		if err != nil {
+			log.Printf("[admin] session lookup: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load session")
+			return
+		}
+		if sess == nil {
+			writeError(w, http.StatusNotFound, "session not found")
+			return
+		}
+
+		disconnect(r.Context(), sid)
+		recordAudit(r.Context(), auditLog, &audit.Event{
+			Action:            audit.ActionAdminDisconnect,
+			Actor:             audit.AdminActor(req.Actor),
+			TargetFingerprint: sess.Fingerprint,
+			Reason:            req.Reason,
+			Context: map[string]interface{}{
+				"session_id": sid,
+				"server":     sess.Server,
+			},
+		})
+		log.Printf("[admin] session %s disconnected actor=%s server=%s reason=%q", sid, req.Actor, sess.Server, req.Reason)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestGetSession(t *testing.T) {
+	sessions := session.NewStoreWithClient(testutil.Redis(t), "ws-1")
+	if _, err := sessions.Create(context.Background(), "s1"); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	h := NewHandler(testToken)
+	h.RegisterSessions(sessions)
+
+	rec := serve(t, h, "GET", "/admin/sessions/s1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got sessionResponse
+	decode(t, rec, &got)
+	if got.ID != "s1" || got.Server != "ws-1" || got.CreatedAt == 0 {
+		t.Errorf("session = %+v", got)
+	}
+	if rec := serve(t, h, "GET", "/admin/sessions/missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing session: status %d, want 404", rec.Code)
+	}
+}
+
+func TestDisconnectSession(t *testing.T) {
+	sessions := session.NewStoreWithClient(testutil.Redis(t), "ws-1")
+	if _, err := sessions.Create(context.Background(), "s1"); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	var disconnected []string
+	h := NewHandler(testToken)
+	h.RegisterDisconnect(sessions, func(ctx context.Context, sid string) {
+		disconnected = append(disconnected, sid)
+	}, nil)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"no actor", "/admin/sessions/s1/disconnect", `{"reason":"abuse"}`, http.StatusBadRequest},
+		{"unknown session", "/admin/sessions/missing/disconnect", `{"actor":"alice"}`, http.StatusNotFound},
+		{"disconnected", "/admin/sessions/s1/disconnect", `{"actor":"alice","reason":"abuse"}`, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		if rec := serve(t, h, "POST", tt.path, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+		}
+	}
+	if len(disconnected) != 1 || disconnected[0] != "s1" {
+		t.Errorf("disconnected %v, want [s1]", disconnected)
+	}
+}
@@ -0,0 +1,64 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/whisper/chat-app/internal/stats"
+)
+
+// RegisterStats mounts the product statistics endpoints:
+//
+//	GET /admin/stats/tiers?days=N  chat outcomes per matching tier (default 7 days)
+//
+// The tier report compares random (tier-4) pairings against interest-based
+// ones: how many chats ended within 30 seconds and how many were reported.
+func (h *Handler) RegisterStats(tierStats *stats.TierStore) {
+	h.mux.HandleFunc("GET /admin/stats/tiers", func(w http.ResponseWriter, r *http.Request) {
+		days := 7
+		if v := r.URL.Query().Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > stats.MaxSummaryDays {
+				writeError(w, http.StatusBadRequest, "days must be between 1 and "+strconv.Itoa(stats.MaxSummaryDays))
+				return
+			}
+			days = n
+		}
+		summary, err := tierStats.Summary(r.Context(), days, time.Now())
+		if err != nil {
+			log.Printf("[admin] tier stats: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load tier stats")
+			return
+		}
+		writeJSON(w, http.StatusOK, summary)
+	})
+}
+
+// RegisterDailyStats mounts the detailed daily statistics endpoint:
+//
+//	GET /admin/stats?days=N  daily summaries with chat outcomes, reports per
+//	                         category and bans by kind (default 30 days)
+//
+// Like the public /api/stats it reads only the daily summary tables.
+func (h *Handler) RegisterDailyStats(daily *stats.DailyStore) {
+	h.mux.HandleFunc("GET /admin/stats", func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := stats.DayRange(r.URL.Query().Get("days"), time.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		days, err := daily.Days(r.Context(), from, to)
+		if err != nil {
+			log.Printf("[admin] daily stats: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to load daily stats")
+			return
+		}
+		writeJSON(w, http.StatusOK, stats.DailyReport[stats.Day]{
+			From: from.Format(time.DateOnly),
+			To:   to.Format(time.DateOnly),
+			Days: days,
+		})
+	})
+}
@@ -0,0 +1,108 @@
+// Package alert tells operators when abuse picks up faster than usual. The
+// wsserver counts reports, bans and content filter blocks in per-minute
+// Redis buckets shared by every instance; a Monitor sums them over each
+// rule's window and raises an Alert when the count crosses the rule's fixed
+// threshold or jumps to a multiple of its recent average, e.g. a sudden 10x
+// spike in blocked URLs from a spam-bot campaign.
+//
+//	Key: alert:count:<counter>:<unix minute>  (integer, expires after Retention)
+//	Key: alert:fired:<rule>                   (set while the rule cools down)
+//
+// Every wsserver runs a Monitor; the fired key makes sure only one of them
+// notifies per cooldown.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyCountPrefix = "alert:count:"
+	keyFiredPrefix = "alert:fired:"
+
+	// Retention is how long counter buckets are kept. A rule's window times
+	// its baseline windows plus one, and the minute in progress, must fit
+	// in it.
+	Retention = 24 * time.Hour
+)
+
+// Counters recorded by the wsserver.
+const (
+	CounterReports      = "reports"
+	CounterBans         = "bans"
+	CounterFilterBlocks = "filter_blocks" // + "." + FilterResult.TermCategory() for the per-category counter
+)
+
+// Triggers an Alert may report.
+const (
+	TriggerThreshold = "threshold"
+	TriggerSpike     = "spike"
+)
+
+// Counters records abuse events in per-minute Redis buckets.
+type Counters struct {
+	rdb *redis.Client
+}
+
+// NewCounters creates Counters backed by Redis.
+func NewCounters(rdb *redis.Client) *Counters {
+	return &Counters{rdb: rdb}
+}
+
+// Incr counts one event for each of the named counters at the given time.
+func (c *Counters) Incr(ctx context.Context, at time.Time, names ...string) error {
+	minute := at.Unix() / 60
+	pipe := c.rdb.Pipeline()
+	for _, name := range names {
+		key := countKey(name, minute)
+		pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, Retention)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("alert: count: %w", err)
+	}
+	return nil
+}
+
+// Sum returns the events counted for name in the window ending with the
+// last complete minute before now, followed by the counts of the windows
+// before it, newest first. The minute of now is still filling up, so it is
+// left out rather than compared, part-counted, against full windows. The
+// window is rounded up to whole minutes.
+func (c *Counters) Sum(ctx context.Context, name string, window time.Duration, windows int, now time.Time) ([]int64, error) {
+	minutes := windowMinutes(window)
+	last := now.Unix()/60 - 1
+	keys := make([]string, 0, minutes*int64(windows))
+	for m := last; m > last-minutes*int64(windows); m-- {
+		keys = append(keys, countKey(name, m))
+	}
+
+	vals, err := c.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("alert: sum %s: %w", name, err)
+	}
+	sums := make([]int64, windows)
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		n, _ := strconv.ParseInt(s, 10, 64)
+		sums[int64(i)/minutes] += n
+	}
+	return sums, nil
+}
+
+func countKey(name string, minute int64) string {
+	return keyCountPrefix + name + ":" + strconv.FormatInt(minute, 10)
+}
+
+// windowMinutes returns the whole minutes in window, at least one.
+func windowMinutes(window time.Duration) int64 {
+	return max(int64((window+time.Minute-1)/time.Minute), 1)
+}
@@ -0,0 +1,222 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m, testutil.ServiceRedis)
+}
+
+// at is a fixed minute boundary, so tests control which bucket events land
+// in. The monitor evaluates complete minutes, so events counted in the
+// minute of at are seen from next on.
+var (
+	at   = time.Unix(1_800_000_000-1_800_000_000%60, 0)
+	next = at.Add(time.Minute)
+)
+
+func incr(t *testing.T, c *Counters, when time.Time, n int, names ...string) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := c.Incr(context.Background(), when, names...); err != nil {
+			t.Fatalf("Incr: %v", err)
+		}
+	}
+}
+
+func TestCounters_Sum(t *testing.T) {
+	c := NewCounters(testutil.Redis(t))
+	incr(t, c, at, 3, "bans")
+	incr(t, c, at.Add(-30*time.Second), 2, "bans", "reports")
+	incr(t, c, at.Add(-2*time.Minute), 4, "bans")
+	incr(t, c, at.Add(-5*time.Minute), 1, "bans")
+
+	sums, err := c.Sum(context.Background(), "bans", 2*time.Minute, 3, next.Add(59*time.Second))
+	if err != nil {
+		t.Fatalf("Sum: %v", err)
+	}
+	if want := []int64{5, 4, 1}; len(sums) != 3 || sums[0] != want[0] || sums[1] != want[1] || sums[2] != want[2] {
+		t.Errorf("Sum = %v, want %v", sums, want)
+	}
+
+	sums, _ = c.Sum(context.Background(), "reports", time.Minute, 1, next)
+	if sums[0] != 0 {
+		t.Errorf("reports in the last minute = %d, want 0 (counted the minute before)", sums[0])
+	}
+
+	sums, _ = c.Sum(context.Background(), "bans", time.Minute, 1, at.Add(30*time.Second))
+	if sums[0] != 2 {
+		t.Errorf("bans = %d, want 2: the minute in progress must not be summed", sums[0])
+	}
+}
+
+func TestMonitor_Threshold(t *testing.T) {
+	rdb := testutil.Redis(t)
+	config := Config{Interval: time.Minute, Cooldown: time.Hour, Rules: []Rule{
+		{Name: "bans_per_hour", Counter: CounterBans, Window: time.Hour, Threshold: 5},
+	}}
+	m := NewMonitor(rdb, config)
+	incr(t, m.counters, at.Add(-10*time.Minute), 4, CounterBans)
+
+	if alerts, err := m.Evaluate(context.Background(), next); err != nil || len(alerts) != 0 {
+		t.Fatalf("below threshold: got %+v, %v", alerts, err)
+	}
+
+	incr(t, m.counters, at, 1, CounterBans)
+	alerts, err := m.Evaluate(context.Background(), next)
+	if err != nil || len(alerts) != 1 {
+		t.Fatalf("at threshold: got %+v, %v; want one alert", alerts, err)
+	}
+	if a := alerts[0]; a.Trigger != TriggerThreshold || a.Count != 5 || a.Threshold != 5 || a.Window != "1h0m0s" || a.Text == "" {
+		t.Errorf("alert = %+v", a)
+	}
+}
+
+func TestMonitor_Spike(t *testing.T) {
+	rdb := testutil.Redis(t)
+	config := Config{Interval: time.Minute, Cooldown: time.Hour, Rules: []Rule{
+		{Name: "url_blocks_per_minute", Counter: "filter_blocks.url", Window: time.Minute, SpikeFactor: 10, MinCount: 20, Baseline: 5},
+	}}
+	m := NewMonitor(rdb, config)
+	// Baseline of 3 per minute.
+	for i := 1; i <= 5; i++ {
+		incr(t, m.counters, at.Add(-time.Duration(i)*time.Minute), 3, "filter_blocks.url")
+	}
+
+	incr(t, m.counters, at, 29, "filter_blocks.url")
+	if alerts, _ := m.Evaluate(context.Background(), next); len(alerts) != 0 {
+		t.Fatalf("29 against a baseline of 3 alerted: %+v", alerts)
+	}
+
+	incr(t, m.counters, at, 1, "filter_blocks.url")
+	alerts, err := m.Evaluate(context.Background(), next)
+	if err != nil || len(alerts) != 1 {
+		t.Fatalf("10x spike: got %+v, %v; want one alert", alerts, err)
+	}
+	if a := alerts[0]; a.Trigger != TriggerSpike || a.Count != 30 || a.Baseline != 3 {
+		t.Errorf("alert = %+v", a)
+	}
+}
+
+func TestMonitor_SpikeNeedsMinCount(t *testing.T) {
+	rdb := testutil.Redis(t)
+	config := Config{Interval: time.Minute, Cooldown: time.Hour, Rules: []Rule{
+		{Name: "reports_per_hour", Counter: CounterReports, Window: time.Hour, SpikeFactor: 10, MinCount: 20, Baseline: 2},
+	}}
+	m := NewMonitor(rdb, config)
+
+	// A quiet baseline counts as one per window: 15 is a spike but below
+	// MinCount.
+	incr(t, m.counters, at, 15, CounterReports)
+	if alerts, _ := m.Evaluate(context.Background(), next); len(alerts) != 0 {
+		t.Fatalf("alerted below MinCount: %+v", alerts)
+	}
+	incr(t, m.counters, at, 5, CounterReports)
+	if alerts, _ := m.Evaluate(context.Background(), next); len(alerts) != 1 {
+		t.Fatalf("got %+v, want one alert at MinCount", alerts)
+	}
+}
+
+func TestMonitor_CooldownSharedAcrossInstances(t *testing.T) {
+	rdb := testutil.Redis(t)
+	config := Config{Interval: time.Minute, Cooldown: time.Hour, Rules: []Rule{
+		{Name: "bans_per_hour", Counter: CounterBans, Window: time.Hour, Threshold: 1},
+	}}
+	serverA, serverB := NewMonitor(rdb, config), NewMonitor(rdb, config)
+	incr(t, serverA.counters, at, 1, CounterBans)
+
+	if alerts, _ := serverA.Evaluate(context.Background(), next); len(alerts) != 1 {
+		t.Fatalf("first instance: got %+v, want one alert", alerts)
+	}
+	if alerts, _ := serverB.Evaluate(context.Background(), next); len(alerts) != 0 {
+		t.Errorf("second instance alerted during the cooldown: %+v", alerts)
+	}
+	if alerts, _ := serverA.Evaluate(context.Background(), next.Add(time.Minute)); len(alerts) != 0 {
+		t.Errorf("first instance alerted again during the cooldown: %+v", alerts)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"no counter", Rule{Name: "x", Window: time.Minute, Threshold: 1}},
+		{"cannot alert", Rule{Name: "x", Counter: "c", Window: time.Minute}},
+		{"spike without baseline", Rule{Name: "x", Counter: "c", Window: time.Minute, SpikeFactor: 10}},
+		{"beyond retention", Rule{Name: "x", Counter: "c", Window: 6 * time.Hour, SpikeFactor: 10, Baseline: 4}},
+	}
+	for _, tt := range tests {
+		config := Config{Interval: time.Minute, Rules: []Rule{tt.rule}}
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+type fakePublisher struct {
+	subject string
+	data    []byte
+}
+
+func (p *fakePublisher) Publish(subject string, data []byte) error {
+	p.subject, p.data = subject, data
+	return nil
+}
+
+func TestBusNotifier(t *testing.T) {
+	p := &fakePublisher{}
+	a := Alert{Rule: "bans_per_hour", Trigger: TriggerThreshold, Count: 7, Text: "bans"}
+	if err := NewBusNotifier(p, "alerts.abuse").Notify(context.Background(), a); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	var got Alert
+	if err := json.Unmarshal(p.data, &got); err != nil || p.subject != "alerts.abuse" || got.Rule != a.Rule || got.Count != 7 {
+		t.Errorf("published %s %s (%v)", p.subject, p.data, err)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	a := Alert{Rule: "url_blocks_per_minute", Trigger: TriggerSpike, Count: 40, Text: "url_blocks_per_minute: spike"}
+	if err := NewWebhookNotifier(srv.Client(), srv.URL).Notify(context.Background(), a); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got["text"] != a.Text || got["rule"] != a.Rule || got["trigger"] != TriggerSpike {
+		t.Errorf("webhook body = %v", got)
+	}
+}
+
+func TestWebhookNotifier_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	err := NewWebhookNotifier(srv.Client(), srv.URL).Notify(context.Background(), Alert{Rule: "x"})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Notify = %v, want a 404 error", err)
+	}
+}
@@ -0,0 +1,213 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/whisper/chat-app/internal/metrics"
+)
+
+// Rule raises an Alert when a counter's events in Window reach Threshold, or
+// reach SpikeFactor times the average of the Baseline windows before it.
+type Rule struct {
+	Name    string
+	Counter string
+	Window  time.Duration
+
+	// Threshold is the absolute count that alerts; 0 disables it.
+	Threshold int64
+
+	// SpikeFactor is the multiple of the baseline average that alerts; 0
+	// disables spike detection. A quiet baseline counts as one event per
+	// window, and a spike needs at least MinCount events, so a handful of
+	// events after a silent night do not alert.
+	SpikeFactor float64
+	MinCount    int64
+	Baseline    int
+}
+
+// Config holds the monitor settings.
+type Config struct {
+	// Interval is how often the rules are evaluated.
+	Interval time.Duration
+
+	// Cooldown is how long a rule stays quiet after alerting.
+	Cooldown time.Duration
+
+	Rules []Rule
+}
+
+// DefaultConfig returns the built-in rules: reports and bans per hour, and
+// filter blocks and blocked URLs per minute.
+func DefaultConfig() Config {
+	return Config{
+		Interval: time.Minute,
+		Cooldown: 15 * time.Minute,
+		Rules: []Rule{
+			{Name: "reports_per_hour", Counter: CounterReports, Window: time.Hour, Threshold: 200, SpikeFactor: 10, MinCount: 20, Baseline: 6},
+			{Name: "bans_per_hour", Counter: CounterBans, Window: time.Hour, Threshold: 100, SpikeFactor: 10, MinCount: 10, Baseline: 6},
+			{Name: "filter_blocks_per_minute", Counter: CounterFilterBlocks, Window: time.Minute, Threshold: 500, SpikeFactor: 10, MinCount: 50, Baseline: 30},
+			{Name: "url_blocks_per_minute", Counter: CounterFilterBlocks + ".url", Window: time.Minute, SpikeFactor: 10, MinCount: 20, Baseline: 30},
+		},
+	}
+}
+
+// Validate checks that every rule can alert and fits in Retention.
+func (c Config) Validate() error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("alert: interval must be positive")
+	}
+	for _, r := range c.Rules {
+		if r.Name == "" || r.Counter == "" {
+			return fmt.Errorf("alert: rule needs a name and a counter")
+		}
+		if r.Threshold <= 0 && r.SpikeFactor <= 0 {
+			return fmt.Errorf("alert: rule %s has neither a threshold nor a spike factor", r.Name)
+		}
+		if r.SpikeFactor > 0 && r.Baseline < 1 {
+			return fmt.Errorf("alert: rule %s needs a baseline for spike detection", r.Name)
+		}
+		if span := time.Duration(windowMinutes(r.Window)*int64(r.Baseline+1)) * time.Minute; span > Retention {
+			return fmt.Errorf("alert: rule %s spans %v, beyond the %v retention", r.Name, span, Retention)
+		}
+	}
+	return nil
+}
+
+// Alert describes a rule that fired. Text is a one-line summary, so a chat
+// webhook such as Slack's can show it as is.
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Counter   string    `json:"counter"`
+	Trigger   string    `json:"trigger"`
+	Count     int64     `json:"count"`
+	Threshold int64     `json:"threshold,omitempty"`
+	Baseline  float64   `json:"baseline"`
+	Window    string    `json:"window"`
+	At        time.Time `json:"at"`
+	Text      string    `json:"text"`
+}
+
+// Notifier delivers alerts to operators.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Monitor evaluates the rules against Counters and notifies on alerts.
+type Monitor struct {
+	rdb       *redis.Client
+	counters  *Counters
+	config    Config
+	notifiers []Notifier
+}
+
+// NewMonitor creates a Monitor that sends alerts to every notifier.
+func NewMonitor(rdb *redis.Client, config Config, notifiers ...Notifier) *Monitor {
+	return &Monitor{rdb: rdb, counters: NewCounters(rdb), config: config, notifiers: notifiers}
+}
+
+// Run evaluates the rules every Interval until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			alerts, err := m.Evaluate(ctx, now)
+			if err != nil {
+				log.Printf("[alert] evaluate: %v", err)
+			}
+			for _, a := range alerts {
+				m.notify(ctx, a)
+			}
+		}
+	}
+}
+
+// Evaluate returns the alerts due at now. A rule that fires starts its
+// cooldown across all instances, so it is only returned by the first
+// Evaluate to see it. Rules that cannot be read are skipped and reported in
+// the error.
+func (m *Monitor) Evaluate(ctx context.Context, now time.Time) ([]Alert, error) {
+	var alerts []Alert
+	var firstErr error
+	for _, r := range m.config.Rules {
+		a, ok, err := m.check(ctx, r, now)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		first, err := m.rdb.SetNX(ctx, keyFiredPrefix+r.Name, now.Unix(), m.config.Cooldown).Result()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("alert: cooldown %s: %w", r.Name, err)
+			}
+			continue
+		}
+		if first {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts, firstErr
+}
+
+// check reports whether r fires at now.
+func (m *Monitor) check(ctx context.Context, r Rule, now time.Time) (Alert, bool, error) {
+	sums, err := m.counters.Sum(ctx, r.Counter, r.Window, r.Baseline+1, now)
+	if err != nil {
+		return Alert{}, false, err
+	}
+	count := sums[0]
+	var baseline float64
+	for _, s := range sums[1:] {
+		baseline += float64(s)
+	}
+	if r.Baseline > 0 {
+		baseline /= float64(r.Baseline)
+	}
+
+	a := Alert{
+		Rule:     r.Name,
+		Counter:  r.Counter,
+		Count:    count,
+		Baseline: baseline,
+		Window:   (time.Duration(windowMinutes(r.Window)) * time.Minute).String(),
+		At:       now,
+	}
+	switch {
+	case r.Threshold > 0 && count >= r.Threshold:
+		a.Trigger, a.Threshold = TriggerThreshold, r.Threshold
+		a.Text = fmt.Sprintf("%s: %d %s in %s (threshold %d)", r.Name, count, r.Counter, a.Window, r.Threshold)
+	case r.SpikeFactor > 0 && count >= r.MinCount && float64(count) >= r.SpikeFactor*max(baseline, 1):
+		a.Trigger = TriggerSpike
+		a.Text = fmt.Sprintf("%s: %d %s in %s, %.1fx the recent average of %.1f", r.Name, count, r.Counter, a.Window, float64(count)/max(baseline, 1), baseline)
+	default:
+		return Alert{}, false, nil
+	}
+	return a, true, nil
+}
+
+// notify logs an alert and hands it to every notifier. Failures are logged.
+func (m *Monitor) notify(ctx context.Context, a Alert) {
+	metrics.AbuseAlertsTotal.WithLabelValues(a.Rule, a.Trigger).Inc()
+	log.Printf("[alert] %s", a.Text)
+	for _, n := range m.notifiers {
+		notifyCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		if err := n.Notify(notifyCtx, a); err != nil {
+			log.Printf("[alert] notify %s: %v", a.Rule, err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,76 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Publisher is the part of messaging.Bus a BusNotifier needs.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// BusNotifier publishes alerts as JSON on a bus subject, for operators'
+// own tooling to subscribe to.
+type BusNotifier struct {
+	bus     Publisher
+	subject string
+}
+
+// NewBusNotifier creates a notifier publishing on subject.
+func NewBusNotifier(bus Publisher, subject string) *BusNotifier {
+	return &BusNotifier{bus: bus, subject: subject}
+}
+
+// Notify publishes a.
+func (n *BusNotifier) Notify(_ context.Context, a Alert) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("alert: marshal: %w", err)
+	}
+	if err := n.bus.Publish(n.subject, data); err != nil {
+		return fmt.Errorf("alert: publish: %w", err)
+	}
+	return nil
+}
+
+// WebhookNotifier POSTs alerts as JSON to a URL. The body's "text" field
+// makes it a valid Slack or Mattermost incoming webhook message.
+type WebhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+// NewWebhookNotifier creates a notifier posting to url with client.
+func NewWebhookNotifier(client *http.Client, url string) *WebhookNotifier {
+	return &WebhookNotifier{client: client, url: url}
+}
+
+// Notify posts a and fails on a non-2xx response.
+func (n *WebhookNotifier) Notify(ctx context.Context, a Alert) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("alert: marshal: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("alert: webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alert: webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("alert: webhook: status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
@@ -0,0 +1,433 @@
+// Package app holds the wsserver's client message handlers, apart from the
+// process wiring in cmd/wsserver. Every dependency reaches a handler through
+// Deps as a narrow interface, so the handlers can be tested with fakes
+// instead of Redis, NATS and live connections.
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/ban"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/matching"
+	"github.com/whisper/chat-app/internal/moderation"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/internal/report"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/ws"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// Conn is the client a message came from.
+type Conn interface {
+	SessionID() string
+	WriteMessage(data []byte) error
+	// ReceivedAt is when the message being handled was read.
+	ReceivedAt() time.Time
+}
+
+// ConnOf adapts a server connection to Conn.
+func ConnOf(conn *ws.Connection) Conn {
+	return wsConn{conn}
+}
+
+// ChatStore reads and updates chats. *chat.Store implements it.
+type ChatStore interface {
+	Get(ctx context.Context, chatID string) (*chat.ChatSession, error)
+	Delete(ctx context.Context, chatID string) error
+	SetIdentity(ctx context.Context, chatID, sessionID string, id chat.Identity) error
+	AcceptMatch(ctx context.Context, chatID, sessionID string) (int, error)
+	TouchActivity(ctx context.Context, chatID string, at time.Time) error
+	CountMessage(ctx context.Context, chatID, sessionID string) error
+	Summarize(ctx context.Context, cs *chat.ChatSession, now time.Time) (*chat.Summary, error)
+	OfferRematch(ctx context.Context, cs *chat.ChatSession, fpA, fpB string) error
+}
+
+// SessionStore reads and updates sessions. *session.Store implements it.
+type SessionStore interface {
+	Get(ctx context.Context, sessionID string) (*session.Session, error)
+	UpdateStatus(ctx context.Context, sessionID string, status string) error
+	SetLanguage(ctx context.Context, sessionID string, language string) error
+	SetPolicy(ctx context.Context, sessionID string, policy string) error
+	SetClientInfo(ctx context.Context, sessionID string, info session.ClientInfo) (bool, error)
+}
+
+// RateLimiter limits actions per identifier. *ratelimit.Limiter implements
+// it.
+type RateLimiter interface {
+	Allow(ctx context.Context, identifier string, rule ratelimit.Rule) (bool, error)
+	AllowCost(ctx context.Context, identifier string, count, budget ratelimit.Rule, cost int) (ratelimit.Result, error)
+}
+
+// ContentFilter reports whether text sent by a session must be blocked.
+// kind names what is checked ("chat_meta", "share_card") for logs and audit.
+type ContentFilter interface {
+	Blocked(ctx context.Context, sessionID, kind, text string) bool
+}
+
+// ContentFilterFunc adapts a function to ContentFilter.
+type ContentFilterFunc func(ctx context.Context, sessionID, kind, text string) bool
+
+// Blocked calls f.
+func (f ContentFilterFunc) Blocked(ctx context.Context, sessionID, kind, text string) bool {
+	return f(ctx, sessionID, kind, text)
+}
+
+// MessageFilter checks a chat message of a session against the content
+// filter of the sender's language. The result is Blocked only if the block
+// is to be enforced; a blocked result has been logged and audited.
+type MessageFilter interface {
+	CheckMessage(ctx context.Context, sessionID, text, lang string) moderation.FilterResult
+}
+
+// MessageFilterFunc adapts a function to MessageFilter.
+type MessageFilterFunc func(ctx context.Context, sessionID, text, lang string) moderation.FilterResult
+
+// CheckMessage calls f.
+func (f MessageFilterFunc) CheckMessage(ctx context.Context, sessionID, text, lang string) moderation.FilterResult {
+	return f(ctx, sessionID, text, lang)
+}
+
+// InterestFilter drops offensive interest tags. *moderation.DynamicFilter
+// implements it.
+type InterestFilter interface {
+	CheckInterests(interests []string) []string
+}
+
+// StrictFilter checks text against the blocklist strict chats refuse.
+// *moderation.StrictFilter implements it.
+type StrictFilter interface {
+	Check(text string) moderation.FilterResult
+}
+
+// Features reports whether a feature flag is on for a session.
+type Features interface {
+	Enabled(ctx context.Context, sessionID, flag string) bool
+}
+
+// FeaturesFunc adapts a function to Features.
+type FeaturesFunc func(ctx context.Context, sessionID, flag string) bool
+
+// Enabled calls f.
+func (f FeaturesFunc) Enabled(ctx context.Context, sessionID, flag string) bool {
+	return f(ctx, sessionID, flag)
+}
+
+// ClientConfig builds the config message for a session, with its features
+// evaluated for what the session has declared so far.
+type ClientConfig interface {
+	ConfigMessage(ctx context.Context, sessionID string) []byte
+}
+
+// ClientConfigFunc adapts a function to ClientConfig.
+type ClientConfigFunc func(ctx context.Context, sessionID string) []byte
+
+// ConfigMessage calls f.
+func (f ClientConfigFunc) ConfigMessage(ctx context.Context, sessionID string) []byte {
+	return f(ctx, sessionID)
+}
+
+// ChatPublisher delivers an event to both participants of a chat.
+type ChatPublisher interface {
+	PublishChatEvent(chatID string, event chat.ChatEvent) error
+}
+
+// ChatPublisherFunc adapts a function to ChatPublisher.
+type ChatPublisherFunc func(chatID string, event chat.ChatEvent) error
+
+// PublishChatEvent calls f.
+func (f ChatPublisherFunc) PublishChatEvent(chatID string, event chat.ChatEvent) error {
+	return f(chatID, event)
+}
+
+// Bus carries match notifications and moderation requests between
+// servers. messaging.Bus implements it.
+type Bus interface {
+	PublishMatchNotify(sessionID string, data []byte) error
+	UnsubscribeMatchNotify(sessionID string) error
+	PublishModerationRequest(data []byte) error
+}
+
+// Matchmaking puts sessions into the matching queue.
+type Matchmaking interface {
+	// Admit applies the gates every entry into the queue passes: the match
+	// rate limit, shutdown, maintenance and the match policy. A refused
+	// client is told why.
+	Admit(ctx context.Context, conn Conn) bool
+	// Enqueue queues conn's session with already filtered interests and
+	// subscribes it to the match result. priority places it ahead of the
+	// rest of the queue.
+	Enqueue(ctx context.Context, conn Conn, interests []string, priority bool)
+}
+
+// MatchmakingFuncs adapts a pair of functions to Matchmaking.
+type MatchmakingFuncs struct {
+	AdmitFunc   func(ctx context.Context, conn Conn) bool
+	EnqueueFunc func(ctx context.Context, conn Conn, interests []string, priority bool)
+}
+
+// Admit calls m.AdmitFunc.
+func (m MatchmakingFuncs) Admit(ctx context.Context, conn Conn) bool {
+	return m.AdmitFunc(ctx, conn)
+}
+
+// Enqueue calls m.EnqueueFunc.
+func (m MatchmakingFuncs) Enqueue(ctx context.Context, conn Conn, interests []string, priority bool) {
+	m.EnqueueFunc(ctx, conn, interests, priority)
+}
+
+// Subscriptions routes an active chat's traffic to a local session.
+type Subscriptions interface {
+	// JoinChat subscribes sessionID to the events of chatID and to its
+	// moderation results, and records the chat on the session.
+	JoinChat(ctx context.Context, sessionID, chatID string)
+	// LeaveChat undoes JoinChat.
+	LeaveChat(ctx context.Context, sessionID string)
+}
+
+// SubscriptionFuncs adapts a pair of functions to Subscriptions.
+type SubscriptionFuncs struct {
+	JoinFunc  func(ctx context.Context, sessionID, chatID string)
+	LeaveFunc func(ctx context.Context, sessionID string)
+}
+
+// JoinChat calls s.JoinFunc.
+func (s SubscriptionFuncs) JoinChat(ctx context.Context, sessionID, chatID string) {
+	s.JoinFunc(ctx, sessionID, chatID)
+}
+
+// LeaveChat calls s.LeaveFunc.
+func (s SubscriptionFuncs) LeaveChat(ctx context.Context, sessionID string) {
+	s.LeaveFunc(ctx, sessionID)
+}
+
+// MatchEvents records a session's match timeline. *matching.EventLog
+// implements it.
+type MatchEvents interface {
+	Record(ctx context.Context, sessionID string, e matching.Event)
+}
+
+// ChatStats counts chats per match tier. *stats.TierStore implements it.
+type ChatStats interface {
+	RecordStarted(ctx context.Context, cs *chat.ChatSession, now time.Time) error
+	RecordEnded(ctx context.Context, cs *chat.ChatSession, now time.Time) error
+	RecordReported(ctx context.Context, cs *chat.ChatSession, now time.Time) error
+}
+
+// MessageBuffer keeps the last messages of a chat as report context.
+// *chat.MessageBuffer implements it.
+type MessageBuffer interface {
+	Add(ctx context.Context, chatID string, msg chat.BufferedMessage) error
+	Get(ctx context.Context, chatID string) ([]chat.BufferedMessage, error)
+	Remove(ctx context.Context, chatID string) error
+}
+
+// History persists whole chats for transcripts. *chat.HistoryStore
+// implements it.
+type History interface {
+	Append(ctx context.Context, chatID string, entry chat.HistoryEntry) error
+	Delete(ctx context.Context, chatID string) error
+}
+
+// Offenses escalates the response to a session's filtered messages.
+// *moderation.Enforcer implements it.
+type Offenses interface {
+	Offend(ctx context.Context, sessionID string) (moderation.Enforcement, error)
+	Muted(ctx context.Context, sessionID string) (time.Duration, error)
+}
+
+// Bans counts reports and bans fingerprints. *ban.Store implements it.
+type Bans interface {
+	FileReport(ctx context.Context, chatID, reporterID string, weight int64) (*ban.ReportResult, error)
+	Escalate(ctx context.Context, fingerprint string, reason string) (time.Duration, error)
+}
+
+// ReportStore persists reports. report.Store implements it.
+type ReportStore interface {
+	Create(ctx context.Context, r *report.Report) error
+	WeightRecent(ctx context.Context, reportedFingerprint string, window time.Duration) (int, error)
+}
+
+// Auditor records moderation actions. Failures are the auditor's to log;
+// they never block the action.
+type Auditor interface {
+	Record(ctx context.Context, event *audit.Event)
+}
+
+// AuditorFunc adapts a function to Auditor.
+type AuditorFunc func(ctx context.Context, event *audit.Event)
+
+// Record calls f.
+func (f AuditorFunc) Record(ctx context.Context, event *audit.Event) {
+	f(ctx, event)
+}
+
+// Deliverer writes a frame to a session wherever it is connected. A
+// non-zero closeCode closes the connection after the frame.
+type Deliverer interface {
+	Deliver(ctx context.Context, sessionID string, data []byte, closeCode ws.CloseCode, closeReason string)
+}
+
+// DelivererFunc adapts a function to Deliverer.
+type DelivererFunc func(ctx context.Context, sessionID string, data []byte, closeCode ws.CloseCode, closeReason string)
+
+// Deliver calls f.
+func (f DelivererFunc) Deliver(ctx context.Context, sessionID string, data []byte, closeCode ws.CloseCode, closeReason string) {
+	f(ctx, sessionID, data, closeCode, closeReason)
+}
+
+// BotDetector watches sent messages for automated senders and acts on
+// them.
+type BotDetector interface {
+	Observe(ctx context.Context, sessionID, chatID, text string)
+}
+
+// BotDetectorFunc adapts a function to BotDetector.
+type BotDetectorFunc func(ctx context.Context, sessionID, chatID, text string)
+
+// Observe calls f.
+func (f BotDetectorFunc) Observe(ctx context.Context, sessionID, chatID, text string) {
+	f(ctx, sessionID, chatID, text)
+}
+
+// Deps holds everything the handlers use.
+type Deps struct {
+	Chats         ChatStore
+	Sessions      SessionStore
+	Limiter       RateLimiter
+	Filter        ContentFilter
+	MessageFilter MessageFilter
+	Interests     InterestFilter
+	Strict        StrictFilter
+	Features      Features
+	Config        ClientConfig
+	Publisher     ChatPublisher
+	Bus           Bus
+	Matchmaking   Matchmaking
+	Subscriptions Subscriptions
+	Events        MatchEvents
+	Stats         ChatStats
+	Buffer        MessageBuffer
+	History       History // nil when chat history is off
+	Offenses      Offenses
+	Bans          Bans
+	Reports       ReportStore
+	Audit         Auditor
+	Deliverer     Deliverer
+	Bots          BotDetector // nil when bot detection is off
+
+	// ServerName stamps the origin of sent messages for latency metrics.
+	ServerName string
+	// MessageLimits bounds chat messages; they are advertised to clients
+	// in invalid_message errors.
+	MessageLimits chat.MessageLimits
+	// Inactivity, when enabled, has chat activity recorded for the
+	// inactivity monitor.
+	Inactivity chat.InactivityConfig
+	// Translation tags messages with the sender's language so the
+	// receiving server can translate them.
+	Translation bool
+
+	// ClientVersions are the released app versions, as "major.minor", that
+	// get their own metric label; see session.VersionLabel.
+	ClientVersions []string
+}
+
+// Handlers handles client messages.
+type Handlers struct {
+	deps Deps
+
+	// async runs work that must not hold up the dispatcher worker.
+	async func(fn func())
+}
+
+// New creates Handlers with the given dependencies.
+func New(deps Deps) *Handlers {
+	return &Handlers{
+		deps:  deps,
+		async: func(fn func()) { go fn() },
+	}
+}
+
+// Register adds the handlers to d.
+func (h *Handlers) Register(d *ws.MessageDispatcher) {
+	d.Register(protocol.TypeClientInfo, handle(h.ClientInfo))
+	d.Register(protocol.TypeFindMatch, handle(h.FindMatch))
+	d.Register(protocol.TypeAcceptMatch, handle(h.AcceptMatch))
+	d.Register(protocol.TypeDeclineMatch, handle(h.DeclineMatch))
+	d.Register(protocol.TypeMessage, handle(h.Message))
+	d.Register(protocol.TypeTyping, handle(h.Typing))
+	d.Register(protocol.TypeChatMeta, handle(h.ChatMeta))
+	d.Register(protocol.TypeReact, handle(h.React))
+	d.Register(protocol.TypeShareCard, handle(h.ShareCard))
+	d.Register(protocol.TypeEndChat, handle(h.EndChat))
+	d.Register(protocol.TypeReport, handle(h.Report))
+}
+
+// handle adapts a typed handler to ws.MessageHandler.
+func handle[T any](fn func(ctx context.Context, conn Conn, msg T)) ws.MessageHandler {
+	return func(conn *ws.Connection, msg interface{}) {
+		m, ok := msg.(T)
+		if !ok {
+			return
+		}
+		fn(context.Background(), wsConn{conn}, m)
+	}
+}
+
+// wsConn adapts *ws.Connection to Conn.
+type wsConn struct {
+	*ws.Connection
+}
+
+func (c wsConn) SessionID() string {
+	return c.ID
+}
+
+// send writes a server message to conn.
+func send(conn Conn, msgType string, payload interface{}) {
+	resp, err := protocol.NewServerMessage(msgType, payload)
+	if err != nil {
+		return
+	}
+	conn.WriteMessage(resp)
+}
+
+// sendError writes an error message to conn.
+func sendError(conn Conn, code protocol.ErrorCode, message string) {
+	send(conn, protocol.TypeError, protocol.NewError(code, message))
+}
+
+// allow checks rule for identifier, telling conn when to retry if it is
+// limited. named adds the rule's name to the rate_limited message.
+func (h *Handlers) allow(ctx context.Context, conn Conn, identifier string, rule ratelimit.Rule, named bool) bool {
+	if allowed, _ := h.deps.Limiter.Allow(ctx, identifier, rule); allowed {
+		return true
+	}
+	msg := protocol.RateLimitedMsg{
+		RetryAfter: int(ratelimit.Effective(rule).Window.Seconds()),
+	}
+	if named {
+		msg.Limit = ratelimit.NameOf(rule)
+	}
+	send(conn, protocol.TypeRateLimited, msg)
+	return false
+}
+
+// featureEnabled reports whether flag is on for conn's session, telling the
+// client the feature is unavailable if not.
+func (h *Handlers) featureEnabled(ctx context.Context, conn Conn, flag string) bool {
+	if h.deps.Features.Enabled(ctx, conn.SessionID(), flag) {
+		return true
+	}
+	sendError(conn, protocol.ErrFeatureDisabled, "This feature is not available yet")
+	return false
+}
+
+// activeParticipant reports whether sessionID is in the active chat chatID.
+func (h *Handlers) activeParticipant(ctx context.Context, chatID, sessionID string) bool {
+	cs, _ := h.deps.Chats.Get(ctx, chatID)
+	return cs != nil && cs.Status == chat.StatusActive && cs.IsParticipant(sessionID)
+}
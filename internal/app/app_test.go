@@ -0,0 +1,486 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/ban"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/matching"
+	"github.com/whisper/chat-app/internal/moderation"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/internal/report"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/ws"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// fakeConn records the messages written to it.
+type fakeConn struct {
+	id   string
+	sent []map[string]interface{}
+}
+
+func (c *fakeConn) SessionID() string { return c.id }
+
+func (c *fakeConn) ReceivedAt() time.Time { return time.Unix(1700000000, 0) }
+
+func (c *fakeConn) WriteMessage(data []byte) error {
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	c.sent = append(c.sent, m)
+	return nil
+}
+
+// lastType returns the type of the last message written, or "" if none.
+func (c *fakeConn) lastType() string {
+	if len(c.sent) == 0 {
+		return ""
+	}
+	t, _ := c.sent[len(c.sent)-1]["type"].(string)
+	return t
+}
+
+// lastCode returns the error code of the last message written.
+func (c *fakeConn) lastCode() string {
+	if len(c.sent) == 0 {
+		return ""
+	}
+	code, _ := c.sent[len(c.sent)-1]["code"].(string)
+	return code
+}
+
+// fakeChats holds chats in memory. AcceptMatch answers accept[chatID].
+type fakeChats struct {
+	chats      map[string]*chat.ChatSession
+	accept     map[string]int
+	identities map[string]chat.Identity // session -> identity set
+	deleted    []string
+	touched    []string
+	counted    []string
+	rematches  []string // chats offered for a rematch
+}
+
+func (f *fakeChats) Get(ctx context.Context, chatID string) (*chat.ChatSession, error) {
+	return f.chats[chatID], nil
+}
+
+func (f *fakeChats) Delete(ctx context.Context, chatID string) error {
+	f.deleted = append(f.deleted, chatID)
+	delete(f.chats, chatID)
+	return nil
+}
+
+func (f *fakeChats) SetIdentity(ctx context.Context, chatID, sessionID string, id chat.Identity) error {
+	cs := f.chats[chatID]
+	if cs == nil || !cs.IsParticipant(sessionID) {
+		return chat.ErrNotParticipant
+	}
+	f.identities[sessionID] = id
+	return nil
+}
+
+func (f *fakeChats) AcceptMatch(ctx context.Context, chatID, sessionID string) (int, error) {
+	return f.accept[chatID], nil
+}
+
+func (f *fakeChats) TouchActivity(ctx context.Context, chatID string, at time.Time) error {
+	f.touched = append(f.touched, chatID)
+	return nil
+}
+
+func (f *fakeChats) CountMessage(ctx context.Context, chatID, sessionID string) error {
+	f.counted = append(f.counted, chatID)
+	return nil
+}
+
+func (f *fakeChats) Summarize(ctx context.Context, cs *chat.ChatSession, now time.Time) (*chat.Summary, error) {
+	return &chat.Summary{Duration: time.Minute, Messages: map[string]int{cs.UserA: 2, cs.UserB: 3}}, nil
+}
+
+func (f *fakeChats) OfferRematch(ctx context.Context, cs *chat.ChatSession, fpA, fpB string) error {
+	f.rematches = append(f.rematches, cs.ChatID)
+	return nil
+}
+
+// fakeLimiter denies the rules in deny and records the identifiers checked.
+type fakeLimiter struct {
+	deny    map[string]bool
+	checked []string
+}
+
+func (l *fakeLimiter) Allow(ctx context.Context, identifier string, rule ratelimit.Rule) (bool, error) {
+	l.checked = append(l.checked, identifier)
+	return !l.deny[rule.Key], nil
+}
+
+func (l *fakeLimiter) AllowCost(ctx context.Context, identifier string, count, budget ratelimit.Rule, cost int) (ratelimit.Result, error) {
+	l.checked = append(l.checked, identifier)
+	for _, rule := range []ratelimit.Rule{count, budget} {
+		if l.deny[rule.Key] {
+			return ratelimit.Result{Exceeded: rule, RetryAfter: rule.Window}, nil
+		}
+	}
+	return ratelimit.Result{Allowed: true}, nil
+}
+
+// fakeSessions holds sessions and the client info declared per session.
+type fakeSessions struct {
+	sessions map[string]*session.Session
+	info     map[string]session.ClientInfo
+}
+
+func (f *fakeSessions) Get(ctx context.Context, sessionID string) (*session.Session, error) {
+	return f.sessions[sessionID], nil
+}
+
+func (f *fakeSessions) UpdateStatus(ctx context.Context, sessionID string, status string) error {
+	if sess := f.sessions[sessionID]; sess != nil {
+		sess.Status = status
+	}
+	return nil
+}
+
+func (f *fakeSessions) SetLanguage(ctx context.Context, sessionID string, language string) error {
+	if sess := f.sessions[sessionID]; sess != nil {
+		sess.Language = language
+	}
+	return nil
+}
+
+func (f *fakeSessions) SetPolicy(ctx context.Context, sessionID string, policy string) error {
+	if sess := f.sessions[sessionID]; sess != nil {
+		sess.Policy = policy
+	}
+	return nil
+}
+
+func (f *fakeSessions) SetClientInfo(ctx context.Context, sessionID string, info session.ClientInfo) (bool, error) {
+	_, declared := f.info[sessionID]
+	f.info[sessionID] = info
+	return !declared, nil
+}
+
+type published struct {
+	chatID string
+	event  chat.ChatEvent
+}
+
+// fakeBus records match notifications and moderation requests.
+type fakeBus struct {
+	notified     []matching.MatchNotification
+	notifiedTo   []string
+	unsubscribed []string
+	moderation   []moderation.ModerationRequest
+}
+
+func (b *fakeBus) PublishMatchNotify(sessionID string, data []byte) error {
+	var notif matching.MatchNotification
+	json.Unmarshal(data, &notif)
+	b.notified = append(b.notified, notif)
+	b.notifiedTo = append(b.notifiedTo, sessionID)
+	return nil
+}
+
+func (b *fakeBus) UnsubscribeMatchNotify(sessionID string) error {
+	b.unsubscribed = append(b.unsubscribed, sessionID)
+	return nil
+}
+
+func (b *fakeBus) PublishModerationRequest(data []byte) error {
+	var req moderation.ModerationRequest
+	json.Unmarshal(data, &req)
+	b.moderation = append(b.moderation, req)
+	return nil
+}
+
+type enqueued struct {
+	interests []string
+	priority  bool
+}
+
+type recordedEvent struct {
+	sessionID string
+	event     matching.Event
+}
+
+// fakeEvents records match events.
+type fakeEvents struct {
+	recorded []recordedEvent
+}
+
+func (e *fakeEvents) Record(ctx context.Context, sessionID string, ev matching.Event) {
+	e.recorded = append(e.recorded, recordedEvent{sessionID, ev})
+}
+
+// fakeStats counts the chats recorded per stage.
+type fakeStats struct {
+	started, ended, reported int
+}
+
+func (s *fakeStats) RecordStarted(ctx context.Context, cs *chat.ChatSession, now time.Time) error {
+	s.started++
+	return nil
+}
+
+func (s *fakeStats) RecordEnded(ctx context.Context, cs *chat.ChatSession, now time.Time) error {
+	s.ended++
+	return nil
+}
+
+func (s *fakeStats) RecordReported(ctx context.Context, cs *chat.ChatSession, now time.Time) error {
+	s.reported++
+	return nil
+}
+
+// fakeBuffer holds buffered messages per chat.
+type fakeBuffer struct {
+	messages map[string][]chat.BufferedMessage
+	removed  []string
+}
+
+func (b *fakeBuffer) Add(ctx context.Context, chatID string, msg chat.BufferedMessage) error {
+	b.messages[chatID] = append(b.messages[chatID], msg)
+	return nil
+}
+
+func (b *fakeBuffer) Get(ctx context.Context, chatID string) ([]chat.BufferedMessage, error) {
+	return b.messages[chatID], nil
+}
+
+func (b *fakeBuffer) Remove(ctx context.Context, chatID string) error {
+	b.removed = append(b.removed, chatID)
+	delete(b.messages, chatID)
+	return nil
+}
+
+// fakeOffenses counts blocks and escalates at the default thresholds.
+type fakeOffenses struct {
+	count int
+	muted time.Duration
+	err   error
+}
+
+func (o *fakeOffenses) Offend(ctx context.Context, sessionID string) (moderation.Enforcement, error) {
+	if o.err != nil {
+		return moderation.Enforcement{}, o.err
+	}
+	o.count++
+	config := moderation.DefaultEnforcementConfig()
+	en := moderation.Enforcement{Action: moderation.EnforceWarn, Offenses: int64(o.count)}
+	if o.count >= config.MuteAfter {
+		en.Action, en.MuteFor = moderation.EnforceMute, config.MuteDuration
+	}
+	if o.count >= config.BanAfter {
+		en.Action = moderation.EnforceBan
+	}
+	return en, nil
+}
+
+func (o *fakeOffenses) Muted(ctx context.Context, sessionID string) (time.Duration, error) {
+	return o.muted, nil
+}
+
+// fakeBans answers FileReport with result and records escalations.
+type fakeBans struct {
+	result    *ban.ReportResult
+	err       error
+	escalated []string
+}
+
+func (b *fakeBans) FileReport(ctx context.Context, chatID, reporterID string, weight int64) (*ban.ReportResult, error) {
+	return b.result, b.err
+}
+
+func (b *fakeBans) Escalate(ctx context.Context, fingerprint string, reason string) (time.Duration, error) {
+	b.escalated = append(b.escalated, fingerprint)
+	return time.Hour, nil
+}
+
+// fakeReports stores reports and answers WeightRecent with weight.
+type fakeReports struct {
+	created []*report.Report
+	weight  int
+}
+
+func (r *fakeReports) Create(ctx context.Context, rep *report.Report) error {
+	r.created = append(r.created, rep)
+	return nil
+}
+
+func (r *fakeReports) WeightRecent(ctx context.Context, reportedFingerprint string, window time.Duration) (int, error) {
+	return r.weight, nil
+}
+
+type delivery struct {
+	sessionID string
+	msgType   string
+	closeCode ws.CloseCode
+}
+
+// fixture wires Handlers to fakes: session "s1" is in active chat "c1" with
+// "s2", "c2" has ended and "p1" is a match of "s1" and "s3" pending
+// acceptance. Every feature is on, matchmaking is open and nothing is
+// filtered unless a test says otherwise. Background work runs inline.
+type fixture struct {
+	h         *Handlers
+	conn      *fakeConn
+	chats     *fakeChats
+	limiter   *fakeLimiter
+	sessions  *fakeSessions
+	bus       *fakeBus
+	events    *fakeEvents
+	stats     *fakeStats
+	buffer    *fakeBuffer
+	history   []chat.HistoryEntry
+	offenses  *fakeOffenses
+	bans      *fakeBans
+	reports   *fakeReports
+	blocked   map[string]bool                    // text -> blocked
+	filtered  map[string]moderation.FilterResult // message text -> filter result
+	strict    map[string]bool                    // text -> refused in strict chats
+	disabled  map[string]bool                    // flag -> off
+	closed    bool                               // matchmaking refuses everyone
+	published []published
+	enqueued  []enqueued
+	joined    []string // chats joined
+	left      []string // sessions that left their chat
+	audited   []*audit.Event
+	delivered []delivery
+	observed  []string // texts seen by the bot detector
+}
+
+func newFixture() *fixture {
+	f := &fixture{
+		conn: &fakeConn{id: "s1"},
+		chats: &fakeChats{
+			chats: map[string]*chat.ChatSession{
+				"c1": {ChatID: "c1", UserA: "s1", UserB: "s2", Status: chat.StatusActive},
+				"c2": {ChatID: "c2", UserA: "s1", UserB: "s2", Status: chat.StatusEnded},
+				"p1": {ChatID: "p1", UserA: "s1", UserB: "s3", Status: chat.StatusPendingAccept},
+			},
+			accept:     map[string]int{},
+			identities: map[string]chat.Identity{},
+		},
+		limiter: &fakeLimiter{deny: map[string]bool{}},
+		sessions: &fakeSessions{
+			sessions: map[string]*session.Session{
+				"s1": {ID: "s1", Status: session.StatusChatting, Fingerprint: "fp1", Interests: "music,films"},
+				"s2": {ID: "s2", Status: session.StatusChatting, Fingerprint: "fp2"},
+				"s3": {ID: "s3", Status: session.StatusMatching, Fingerprint: "fp3"},
+			},
+			info: map[string]session.ClientInfo{},
+		},
+		bus:      &fakeBus{},
+		events:   &fakeEvents{},
+		stats:    &fakeStats{},
+		buffer:   &fakeBuffer{messages: map[string][]chat.BufferedMessage{}},
+		offenses: &fakeOffenses{},
+		bans:     &fakeBans{},
+		reports:  &fakeReports{},
+		blocked:  map[string]bool{},
+		filtered: map[string]moderation.FilterResult{},
+		strict:   map[string]bool{},
+		disabled: map[string]bool{},
+	}
+	f.h = New(Deps{
+		Chats:    f.chats,
+		Sessions: f.sessions,
+		Limiter:  f.limiter,
+		Filter: ContentFilterFunc(func(ctx context.Context, sessionID, kind, text string) bool {
+			return f.blocked[text]
+		}),
+		MessageFilter: MessageFilterFunc(func(ctx context.Context, sessionID, text, lang string) moderation.FilterResult {
+			return f.filtered[text]
+		}),
+		Interests: interestFilter(func(interests []string) []string {
+			var clean []string
+			for _, tag := range interests {
+				if !f.blocked[tag] {
+					clean = append(clean, tag)
+				}
+			}
+			return clean
+		}),
+		Strict: strictFilter(func(text string) moderation.FilterResult {
+			return moderation.FilterResult{Blocked: f.strict[text], Reason: moderation.ReasonStrictPolicy}
+		}),
+		Features: FeaturesFunc(func(ctx context.Context, sessionID, flag string) bool {
+			return !f.disabled[flag]
+		}),
+		Config: ClientConfigFunc(func(ctx context.Context, sessionID string) []byte {
+			msg, _ := protocol.NewServerMessage(protocol.TypeConfig, protocol.ConfigMsg{})
+			return msg
+		}),
+		Publisher: ChatPublisherFunc(func(chatID string, event chat.ChatEvent) error {
+			f.published = append(f.published, published{chatID, event})
+			return nil
+		}),
+		Bus: f.bus,
+		Matchmaking: MatchmakingFuncs{
+			AdmitFunc: func(ctx context.Context, conn Conn) bool {
+				if f.closed {
+					send(conn, protocol.TypeServiceUnavailable, protocol.ServiceUnavailableMsg{Reason: "closed"})
+				}
+				return !f.closed
+			},
+			EnqueueFunc: func(ctx context.Context, conn Conn, interests []string, priority bool) {
+				f.enqueued = append(f.enqueued, enqueued{interests, priority})
+			},
+		},
+		Subscriptions: SubscriptionFuncs{
+			JoinFunc: func(ctx context.Context, sessionID, chatID string) {
+				f.joined = append(f.joined, chatID)
+			},
+			LeaveFunc: func(ctx context.Context, sessionID string) {
+				f.left = append(f.left, sessionID)
+			},
+		},
+		Events:   f.events,
+		Stats:    f.stats,
+		Buffer:   f.buffer,
+		History:  historyFunc(func(entry chat.HistoryEntry) { f.history = append(f.history, entry) }),
+		Offenses: f.offenses,
+		Bans:     f.bans,
+		Reports:  f.reports,
+		Audit: AuditorFunc(func(ctx context.Context, event *audit.Event) {
+			f.audited = append(f.audited, event)
+		}),
+		Deliverer: DelivererFunc(func(ctx context.Context, sessionID string, data []byte, closeCode ws.CloseCode, closeReason string) {
+			var m struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal(data, &m)
+			f.delivered = append(f.delivered, delivery{sessionID, m.Type, closeCode})
+		}),
+		Bots: BotDetectorFunc(func(ctx context.Context, sessionID, chatID, text string) {
+			f.observed = append(f.observed, text)
+		}),
+		MessageLimits: chat.DefaultMessageLimits(),
+	})
+	f.h.async = func(fn func()) { fn() }
+	return f
+}
+
+type interestFilter func(interests []string) []string
+
+func (fn interestFilter) CheckInterests(interests []string) []string { return fn(interests) }
+
+type strictFilter func(text string) moderation.FilterResult
+
+func (fn strictFilter) Check(text string) moderation.FilterResult { return fn(text) }
+
+// historyFunc appends to history through fn; deletes are ignored.
+type historyFunc func(entry chat.HistoryEntry)
+
+func (fn historyFunc) Append(ctx context.Context, chatID string, entry chat.HistoryEntry) error {
+	fn(entry)
+	return nil
+}
+
+func (fn historyFunc) Delete(ctx context.Context, chatID string) error { return nil }
+
+var errRedis = errors.New("redis: connection refused")
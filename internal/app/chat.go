@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// EndChat ends a chat the session takes part in (CHAT-4). The partner gets
+// partner_left and both users get the chat's summary.
+func (h *Handlers) EndChat(ctx context.Context, conn Conn, msg protocol.EndChatMsg) {
+	sid := conn.SessionID()
+	chatID := msg.ChatID
+
+	cs, _ := h.deps.Chats.Get(ctx, chatID)
+	if cs == nil || !cs.IsParticipant(sid) {
+		return
+	}
+
+	// Publish partner_left with the summary the partner's server forwards
+	// after it; the ender gets theirs directly.
+	summary := h.SummarizeChat(ctx, cs)
+	h.OfferRematch(ctx, cs)
+	h.deps.Publisher.PublishChatEvent(chatID, chat.ChatEvent{Type: "partner_left", From: sid, Summary: summary})
+	if summary != nil {
+		send(conn, protocol.TypeChatSummary, ChatSummary(sid, chatID, summary))
+	}
+
+	metrics.ActiveChats.Dec()
+	if err := h.deps.Stats.RecordEnded(ctx, cs, time.Now()); err != nil {
+		log.Printf("[stats] record chat end chat=%s: %v", chatID, err)
+	}
+
+	// Cleanup.
+	h.deps.Subscriptions.LeaveChat(ctx, sid)
+	h.deps.Chats.Delete(ctx, chatID)
+	if h.deps.History != nil {
+		h.deps.History.Delete(ctx, chatID)
+	}
+	h.deps.Buffer.Remove(ctx, chatID) // MOD-6: Clean up message buffer.
+
+	log.Printf("end_chat from session=%s chat=%s", sid, chatID)
+}
+
+// SummarizeChat builds the end-of-chat summary of cs. It must run before
+// the chat is deleted; on failure the chat ends without one.
+func (h *Handlers) SummarizeChat(ctx context.Context, cs *chat.ChatSession) *chat.Summary {
+	summary, err := h.deps.Chats.Summarize(ctx, cs, time.Now())
+	if err != nil {
+		log.Printf("[summary] chat=%s: %v", cs.ChatID, err)
+		return nil
+	}
+	return summary
+}
+
+// OfferRematch lets the users of cs, an active chat that is ending, ask to
+// chat again for chat.RematchWindow. Fingerprints recognize a user who
+// reconnected in the meantime. It must run before the chat is deleted.
+func (h *Handlers) OfferRematch(ctx context.Context, cs *chat.ChatSession) {
+	if cs.Status != chat.StatusActive {
+		return
+	}
+	var fpA, fpB string
+	if sess, _ := h.deps.Sessions.Get(ctx, cs.UserA); sess != nil {
+		fpA = sess.Fingerprint
+	}
+	if sess, _ := h.deps.Sessions.Get(ctx, cs.UserB); sess != nil {
+		fpB = sess.Fingerprint
+	}
+	if err := h.deps.Chats.OfferRematch(ctx, cs, fpA, fpB); err != nil {
+		log.Printf("[rematch] offer chat=%s: %v", cs.ChatID, err)
+	}
+}
+
+// ChatSummary builds the chat_summary message telling sid how chatID went,
+// from sid's side.
+func ChatSummary(sid, chatID string, summary *chat.Summary) protocol.ChatSummaryMsg {
+	sent, received := summary.Counts(sid)
+	interests := summary.Interests
+	if interests == nil {
+		interests = []string{}
+	}
+	return protocol.ChatSummaryMsg{
+		ChatID:           chatID,
+		Duration:         int(summary.Duration / time.Second),
+		MessagesSent:     sent,
+		MessagesReceived: received,
+		SharedInterests:  interests,
+	}
+}
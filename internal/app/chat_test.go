@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+func TestEndChat(t *testing.T) {
+	tests := []struct {
+		name     string
+		sender   string
+		chatID   string
+		ended    bool
+		rematch  bool // offered for a rematch
+		wantType string
+	}{
+		{name: "active chat", sender: "s1", chatID: "c1", ended: true, rematch: true, wantType: protocol.TypeChatSummary},
+		{name: "pending match", sender: "s1", chatID: "p1", ended: true, wantType: protocol.TypeChatSummary},
+		{name: "not a participant", sender: "s3", chatID: "c1"},
+		{name: "unknown chat", sender: "s1", chatID: "nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			f.conn.id = tt.sender
+			f.h.EndChat(context.Background(), f.conn, protocol.EndChatMsg{ChatID: tt.chatID})
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if !tt.ended {
+				if len(f.published) != 0 || len(f.chats.deleted) != 0 || len(f.left) != 0 {
+					t.Errorf("published=%v deleted=%v left=%v, want nothing", f.published, f.chats.deleted, f.left)
+				}
+				return
+			}
+			if len(f.published) != 1 {
+				t.Fatalf("published %d events, want 1", len(f.published))
+			}
+			ev := f.published[0].event
+			if ev.Type != "partner_left" || ev.From != tt.sender || ev.Summary == nil {
+				t.Errorf("published %+v, want partner_left with a summary", ev)
+			}
+			if got := f.conn.sent[len(f.conn.sent)-1]; got["messages_sent"] != 2.0 || got["messages_received"] != 3.0 {
+				t.Errorf("chat_summary %v, want 2 sent and 3 received", got)
+			}
+			if !reflect.DeepEqual(f.left, []string{tt.sender}) {
+				t.Errorf("left %v, want [%s]", f.left, tt.sender)
+			}
+			if !reflect.DeepEqual(f.chats.deleted, []string{tt.chatID}) || !reflect.DeepEqual(f.buffer.removed, []string{tt.chatID}) {
+				t.Errorf("deleted=%v buffer removed=%v, want [%s]", f.chats.deleted, f.buffer.removed, tt.chatID)
+			}
+			if f.stats.ended != 1 {
+				t.Errorf("recorded %d chat ends, want 1", f.stats.ended)
+			}
+			if rematch := len(f.chats.rematches) == 1; rematch != tt.rematch {
+				t.Errorf("rematch offers %v, want %v", f.chats.rematches, tt.rematch)
+			}
+		})
+	}
+}
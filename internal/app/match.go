@@ -0,0 +1,222 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/matching"
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/translation"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// FindMatch enters the session into the matching queue.
+func (h *Handlers) FindMatch(ctx context.Context, conn Conn, msg protocol.FindMatchMsg) {
+	sid := conn.SessionID()
+	if !h.deps.Matchmaking.Admit(ctx, conn) {
+		return
+	}
+
+	// Reject malformed interest lists outright, naming the bad tags,
+	// before they reach the content filter or Redis.
+	var invalid *protocol.InterestsError
+	if err := protocol.ValidateInterests(msg.Interests); errors.As(err, &invalid) {
+		log.Printf("[find_match] invalid interests session=%s rejected=%d", sid, len(invalid.Rejected))
+		errMsg := protocol.NewError(protocol.ErrInvalidInterests,
+			fmt.Sprintf("Up to %d interests of at most %d lowercase letters, digits or hyphens", protocol.MaxInterests, protocol.MaxInterestLength))
+		errMsg.Rejected = invalid.Rejected
+		send(conn, protocol.TypeError, errMsg)
+		return
+	}
+
+	// ABUSE-2: Filter offensive interest tags.
+	interests := h.deps.Interests.CheckInterests(msg.Interests)
+	if len(interests) != len(msg.Interests) {
+		log.Printf("[filter] interests filtered session=%s original=%d clean=%d", sid, len(msg.Interests), len(interests))
+	}
+
+	// Translation is opt-in: the declared language (or its absence)
+	// replaces any previous one. Unparseable tags opt out.
+	language := ""
+	if msg.Language != "" {
+		if l, err := translation.NormalizeLanguage(msg.Language); err == nil {
+			language = l
+		}
+	}
+	h.deps.Sessions.SetLanguage(ctx, sid, language)
+
+	// The content policy preference likewise replaces any previous one;
+	// an unknown policy states none. Any two users are paired; the chat
+	// takes the stricter policy.
+	h.deps.Sessions.SetPolicy(ctx, sid, chat.NormalizePolicy(msg.Policy))
+
+	h.deps.Matchmaking.Enqueue(ctx, conn, interests, false)
+	log.Printf("find_match from session=%s interests=%v", sid, interests)
+}
+
+// AcceptMatch accepts a proposed match (MATCH-7). The chat starts once both
+// participants have accepted.
+func (h *Handlers) AcceptMatch(ctx context.Context, conn Conn, msg protocol.AcceptMatchMsg) {
+	sid := conn.SessionID()
+	chatID := msg.ChatID
+
+	// Record this participant's identity before accepting, so whichever
+	// side accepts last sees both in the chat.
+	identity := chat.NewIdentity()
+	if msg.Nickname != "" {
+		if nickname, ok := h.checkNickname(ctx, conn, msg.Nickname); ok {
+			identity.Nickname = nickname
+		}
+	}
+	if err := h.deps.Chats.SetIdentity(ctx, chatID, sid, identity); err != nil && !errors.Is(err, chat.ErrNotParticipant) {
+		log.Printf("accept_match: %v", err)
+	}
+
+	result, err := h.deps.Chats.AcceptMatch(ctx, chatID, sid)
+	if err != nil {
+		log.Printf("accept_match: %v", err)
+		return
+	}
+
+	switch result {
+	case 1:
+		// Both accepted — activate chat.
+		h.deps.Events.Record(ctx, sid, matching.Event{Type: matching.EventAccepted, ChatID: chatID, Detail: "chat started"})
+		metrics.ActiveChats.Inc()
+		if h.deps.Inactivity.Enabled() {
+			// Silence is measured from activation until the first message.
+			if err := h.deps.Chats.TouchActivity(ctx, chatID, time.Now()); err != nil {
+				log.Printf("[inactivity] touch chat=%s: %v", chatID, err)
+			}
+		}
+		h.deps.Subscriptions.JoinChat(ctx, sid, chatID)
+
+		cs, _ := h.deps.Chats.Get(ctx, chatID)
+		if cs != nil {
+			if err := h.deps.Stats.RecordStarted(ctx, cs, time.Now()); err != nil {
+				log.Printf("[stats] record chat start chat=%s: %v", chatID, err)
+			}
+		}
+		send(conn, protocol.TypeMatchAccepted, MatchAccepted(chatID, sid, cs))
+
+		// Notify partner via NATS.
+		if cs != nil {
+			h.notifyMatch(cs.GetPartner(sid), "accepted", chatID)
+		}
+
+		_ = h.deps.Bus.UnsubscribeMatchNotify(sid)
+		log.Printf("accept_match from session=%s chat=%s (both accepted)", sid, chatID)
+
+	case 0:
+		// Waiting for partner — tell them we are ready; our notification
+		// handler fires once they accept too.
+		h.deps.Events.Record(ctx, sid, matching.Event{Type: matching.EventAccepted, ChatID: chatID, Detail: "waiting for partner"})
+		if cs, _ := h.deps.Chats.Get(ctx, chatID); cs != nil {
+			h.notifyMatch(cs.GetPartner(sid), "partner_ready", chatID)
+		}
+		log.Printf("accept_match from session=%s chat=%s (waiting for partner)", sid, chatID)
+
+	default:
+		if result == -1 || result == -2 {
+			h.deps.Events.Record(ctx, sid, matching.Event{Type: matching.EventAccepted, ChatID: chatID, Detail: "rejected, the match is no longer pending"})
+		}
+		log.Printf("accept_match from session=%s chat=%s error_code=%d", sid, chatID, result)
+	}
+}
+
+// DeclineMatch declines a proposed match (MATCH-7). With ReRoll the session
+// goes straight back into the queue.
+func (h *Handlers) DeclineMatch(ctx context.Context, conn Conn, msg protocol.DeclineMatchMsg) {
+	sid := conn.SessionID()
+	chatID := msg.ChatID
+
+	cs, _ := h.deps.Chats.Get(ctx, chatID)
+	if cs == nil {
+		return
+	}
+	partnerID := cs.GetPartner(sid)
+
+	// Delete the pending chat.
+	h.deps.Chats.Delete(ctx, chatID)
+	detail := ""
+	if msg.ReRoll {
+		detail = "re-roll"
+	}
+	h.deps.Events.Record(ctx, sid, matching.Event{Type: matching.EventDeclined, ChatID: chatID, Partner: partnerID, Detail: detail})
+	h.deps.Events.Record(ctx, partnerID, matching.Event{Type: matching.EventDeclined, ChatID: chatID, Partner: sid, Detail: "by partner"})
+
+	h.notifyMatch(partnerID, "declined", chatID)
+
+	// Reset own state.
+	_ = h.deps.Bus.UnsubscribeMatchNotify(sid)
+
+	// A re-roll goes straight back into the queue with the same
+	// interests, through the same gates as find_match. Priority
+	// placement is limited per fingerprint so reconnecting does not
+	// refill it; beyond the limit the user is queued normally.
+	if msg.ReRoll && h.deps.Matchmaking.Admit(ctx, conn) {
+		if sess, _ := h.deps.Sessions.Get(ctx, sid); sess != nil {
+			var interests []string
+			if sess.Interests != "" {
+				interests = strings.Split(sess.Interests, ",")
+			}
+			key := sid
+			if sess.Fingerprint != "" {
+				key = sess.Fingerprint
+			}
+			priority, _ := h.deps.Limiter.Allow(ctx, key, ratelimit.RuleReRoll)
+			metrics.MatchReRollsTotal.WithLabelValues(strconv.FormatBool(priority)).Inc()
+			h.deps.Matchmaking.Enqueue(ctx, conn, interests, priority)
+			log.Printf("decline_match from session=%s chat=%s re_roll priority=%t", sid, chatID, priority)
+			return
+		}
+	}
+	h.deps.Sessions.UpdateStatus(ctx, sid, session.StatusIdle)
+
+	log.Printf("decline_match from session=%s chat=%s", sid, chatID)
+}
+
+// notifyMatch tells sessionID, wherever it is connected, about a change to
+// its pending match.
+func (h *Handlers) notifyMatch(sessionID, notifType, chatID string) {
+	notif, _ := json.Marshal(matching.MatchNotification{Type: notifType, ChatID: chatID})
+	h.deps.Bus.PublishMatchNotify(sessionID, notif)
+}
+
+// checkNickname validates a client-chosen nickname and runs it through the
+// content filter. On rejection the client is told why and ok is false; the
+// caller falls back to a generated nickname.
+func (h *Handlers) checkNickname(ctx context.Context, conn Conn, raw string) (nickname string, ok bool) {
+	nickname = chat.NormalizeNickname(raw)
+	if err := chat.ValidateNickname(nickname); err != nil {
+		sendError(conn, protocol.ErrInvalidNickname, err.Error())
+		return "", false
+	}
+	if h.deps.Filter.Blocked(ctx, conn.SessionID(), "nickname", nickname) {
+		sendError(conn, protocol.ErrInvalidNickname, "Nickname contains prohibited content")
+		return "", false
+	}
+	return nickname, true
+}
+
+// MatchAccepted builds the match_accepted message for sid, carrying the
+// icebreaker and both participants' identities when the chat was found.
+func MatchAccepted(chatID, sid string, cs *chat.ChatSession) protocol.MatchAcceptedMsg {
+	msg := protocol.MatchAcceptedMsg{ChatID: chatID}
+	if cs != nil {
+		self, partner := cs.IdentityOf(sid), cs.IdentityOf(cs.GetPartner(sid))
+		msg.Icebreaker = cs.Icebreaker
+		msg.Nickname, msg.AvatarSeed = self.Nickname, self.AvatarSeed
+		msg.PartnerNickname, msg.PartnerAvatarSeed = partner.Nickname, partner.AvatarSeed
+	}
+	return msg
+}
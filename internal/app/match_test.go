@@ -0,0 +1,252 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+func TestFindMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		msg        protocol.FindMatchMsg
+		setup      func(f *fixture)
+		wantType   string // last message to the sender, "" for none
+		wantCode   protocol.ErrorCode
+		queued     []string // interests queued with, nil for not queued
+		wantLang   string
+		wantPolicy string
+	}{
+		{
+			name:       "queued",
+			msg:        protocol.FindMatchMsg{Interests: []string{"music", "films"}, Language: "es-MX", Policy: chat.PolicyStrict},
+			queued:     []string{"music", "films"},
+			wantLang:   "es",
+			wantPolicy: chat.PolicyStrict,
+		},
+		{
+			name:       "unknown language and policy",
+			msg:        protocol.FindMatchMsg{Interests: []string{"music"}, Language: "?", Policy: "relaxed"},
+			queued:     []string{"music"},
+			wantPolicy: chat.PolicyStandard,
+		},
+		{
+			name:       "offensive interest dropped",
+			msg:        protocol.FindMatchMsg{Interests: []string{"music", "slur"}},
+			setup:      func(f *fixture) { f.blocked["slur"] = true },
+			queued:     []string{"music"},
+			wantPolicy: chat.PolicyStandard,
+		},
+		{
+			name:     "invalid interests",
+			msg:      protocol.FindMatchMsg{Interests: []string{"Not A Tag!"}},
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrInvalidInterests,
+		},
+		{
+			name:     "matchmaking closed",
+			msg:      protocol.FindMatchMsg{Interests: []string{"music"}},
+			setup:    func(f *fixture) { f.closed = true },
+			wantType: protocol.TypeServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			f.h.FindMatch(context.Background(), f.conn, tt.msg)
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if got := f.conn.lastCode(); got != string(tt.wantCode) {
+				t.Errorf("error code %q, want %q", got, tt.wantCode)
+			}
+			if tt.queued == nil {
+				if len(f.enqueued) != 0 {
+					t.Fatalf("queued %+v, want nothing", f.enqueued)
+				}
+				return
+			}
+			if len(f.enqueued) != 1 || !reflect.DeepEqual(f.enqueued[0].interests, tt.queued) || f.enqueued[0].priority {
+				t.Fatalf("queued %+v, want %v without priority", f.enqueued, tt.queued)
+			}
+			sess := f.sessions.sessions["s1"]
+			if sess.Language != tt.wantLang || sess.Policy != tt.wantPolicy {
+				t.Errorf("session language=%q policy=%q, want %q %q", sess.Language, sess.Policy, tt.wantLang, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestAcceptMatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		msg          protocol.AcceptMatchMsg
+		setup        func(f *fixture)
+		wantType     string
+		wantCode     protocol.ErrorCode
+		wantNotify   string // notification to the partner, "" for none
+		wantJoined   bool
+		wantNickname string // nickname recorded for the sender, "" for generated
+	}{
+		{
+			name:       "both accepted",
+			msg:        protocol.AcceptMatchMsg{ChatID: "p1"},
+			setup:      func(f *fixture) { f.chats.accept["p1"] = 1 },
+			wantType:   protocol.TypeMatchAccepted,
+			wantNotify: "accepted",
+			wantJoined: true,
+		},
+		{
+			name:       "waiting for partner",
+			msg:        protocol.AcceptMatchMsg{ChatID: "p1"},
+			wantNotify: "partner_ready",
+		},
+		{
+			name:  "no longer pending",
+			msg:   protocol.AcceptMatchMsg{ChatID: "p1"},
+			setup: func(f *fixture) { f.chats.accept["p1"] = -1 },
+		},
+		{
+			name:         "nickname",
+			msg:          protocol.AcceptMatchMsg{ChatID: "p1", Nickname: "  Night   Owl "},
+			wantNotify:   "partner_ready",
+			wantNickname: "Night Owl",
+		},
+		{
+			name:       "nickname filtered",
+			msg:        protocol.AcceptMatchMsg{ChatID: "p1", Nickname: "slur"},
+			setup:      func(f *fixture) { f.blocked["slur"] = true },
+			wantType:   protocol.TypeError,
+			wantCode:   protocol.ErrInvalidNickname,
+			wantNotify: "partner_ready",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			f.h.AcceptMatch(context.Background(), f.conn, tt.msg)
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if got := f.conn.lastCode(); got != string(tt.wantCode) {
+				t.Errorf("error code %q, want %q", got, tt.wantCode)
+			}
+			if tt.wantNotify == "" {
+				if len(f.bus.notified) != 0 {
+					t.Errorf("notified %+v, want nothing", f.bus.notified)
+				}
+			} else if len(f.bus.notified) != 1 || f.bus.notified[0].Type != tt.wantNotify || f.bus.notifiedTo[0] != "s3" {
+				t.Errorf("notified %+v to %v, want %q to s3", f.bus.notified, f.bus.notifiedTo, tt.wantNotify)
+			}
+			if joined := len(f.joined) == 1; joined != tt.wantJoined {
+				t.Errorf("joined %v, want %v", f.joined, tt.wantJoined)
+			}
+			if tt.wantJoined && f.stats.started != 1 {
+				t.Errorf("recorded %d chat starts, want 1", f.stats.started)
+			}
+			id, ok := f.chats.identities["s1"]
+			if !ok {
+				t.Fatal("no identity recorded")
+			}
+			if tt.wantNickname != "" && id.Nickname != tt.wantNickname {
+				t.Errorf("nickname %q, want %q", id.Nickname, tt.wantNickname)
+			}
+			if tt.wantNickname == "" && id.Nickname == "slur" {
+				t.Error("filtered nickname recorded")
+			}
+		})
+	}
+}
+
+func TestDeclineMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		msg        protocol.DeclineMatchMsg
+		setup      func(f *fixture)
+		wantType   string
+		queued     bool
+		priority   bool
+		wantStatus string
+	}{
+		{
+			name:       "declined",
+			msg:        protocol.DeclineMatchMsg{ChatID: "p1"},
+			wantStatus: session.StatusIdle,
+		},
+		{
+			name:     "re-roll",
+			msg:      protocol.DeclineMatchMsg{ChatID: "p1", ReRoll: true},
+			queued:   true,
+			priority: true,
+		},
+		{
+			name:   "re-roll beyond the priority limit",
+			msg:    protocol.DeclineMatchMsg{ChatID: "p1", ReRoll: true},
+			setup:  func(f *fixture) { f.limiter.deny[ratelimit.RuleReRoll.Key] = true },
+			queued: true,
+		},
+		{
+			name:       "re-roll refused by a match gate",
+			msg:        protocol.DeclineMatchMsg{ChatID: "p1", ReRoll: true},
+			setup:      func(f *fixture) { f.closed = true },
+			wantType:   protocol.TypeServiceUnavailable,
+			wantStatus: session.StatusIdle,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			f.h.DeclineMatch(context.Background(), f.conn, tt.msg)
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if !reflect.DeepEqual(f.chats.deleted, []string{"p1"}) {
+				t.Errorf("deleted %v, want [p1]", f.chats.deleted)
+			}
+			if len(f.bus.notified) != 1 || f.bus.notified[0].Type != "declined" || f.bus.notifiedTo[0] != "s3" {
+				t.Errorf("notified %+v to %v, want declined to s3", f.bus.notified, f.bus.notifiedTo)
+			}
+			if len(f.events.recorded) != 2 {
+				t.Errorf("recorded %d match events, want 2", len(f.events.recorded))
+			}
+			if queued := len(f.enqueued) == 1; queued != tt.queued {
+				t.Fatalf("queued %+v, want %v", f.enqueued, tt.queued)
+			}
+			if tt.queued {
+				got := f.enqueued[0]
+				if !reflect.DeepEqual(got.interests, []string{"music", "films"}) || got.priority != tt.priority {
+					t.Errorf("queued %+v, want [music films] priority=%v", got, tt.priority)
+				}
+			}
+			if tt.wantStatus != "" && f.sessions.sessions["s1"].Status != tt.wantStatus {
+				t.Errorf("status %q, want %q", f.sessions.sessions["s1"].Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDeclineMatch_UnknownChat(t *testing.T) {
+	f := newFixture()
+	f.h.DeclineMatch(context.Background(), f.conn, protocol.DeclineMatchMsg{ChatID: "nope", ReRoll: true})
+
+	if len(f.chats.deleted) != 0 || len(f.bus.notified) != 0 || len(f.enqueued) != 0 {
+		t.Errorf("deleted=%v notified=%v queued=%v, want nothing", f.chats.deleted, f.bus.notified, f.enqueued)
+	}
+}
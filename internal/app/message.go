@@ -0,0 +1,245 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/internal/moderation"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/internal/ws"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// botTimeout bounds the bot detection of a sent message, which runs after
+// the sender's frame has been handled.
+const botTimeout = 3 * time.Second
+
+// Message sends a chat message to the partner (CHAT-2, CHAT-7) and hands it
+// to asynchronous moderation.
+func (h *Handlers) Message(ctx context.Context, conn Conn, msg protocol.ChatMsg) {
+	sid := conn.SessionID()
+
+	// ABUSE-1: Rate limit messages (5 and one maximum-size frame of text
+	// per 10 seconds per session).
+	if res, _ := h.deps.Limiter.AllowCost(ctx, sid, ratelimit.RuleMessage, ratelimit.RuleMessageBytes, len(msg.Text)); !res.Allowed {
+		limit := ratelimit.NameOf(res.Exceeded)
+		log.Printf("[ratelimit] message rejected session=%s limit=%s", sid, limit)
+		send(conn, protocol.TypeRateLimited, protocol.RateLimitedMsg{
+			RetryAfter: int(math.Ceil(res.RetryAfter.Seconds())),
+			Limit:      limit,
+		})
+		return
+	}
+
+	// Sessions muted for repeated filter blocks send nothing until the
+	// mute expires. Redis errors fail open.
+	if remaining, err := h.deps.Offenses.Muted(ctx, sid); err != nil {
+		log.Printf("[filter] mute check session=%s: %v", sid, err)
+	} else if remaining > 0 {
+		metrics.MessagesTotal.WithLabelValues("muted").Inc()
+		errMsg := protocol.NewError(protocol.ErrMuted, "You are muted for repeatedly sending prohibited content")
+		errMsg.RetryAfter = int(math.Ceil(remaining.Seconds()))
+		send(conn, protocol.TypeError, errMsg)
+		return
+	}
+
+	// CHAT-7: Validate message content.
+	if err := h.deps.MessageLimits.Validate(msg.Text); err != nil {
+		limits := h.deps.MessageLimits
+		errMsg := protocol.NewError(protocol.ErrInvalidMessage, err.Error())
+		errMsg.Limits = &protocol.MessageLimits{
+			MaxChars:     limits.MaxChars,
+			MaxGraphemes: limits.MaxGraphemes,
+			MaxBytes:     limits.MaxBytes,
+		}
+		var msgErr *chat.MessageError
+		if errors.As(err, &msgErr) {
+			errMsg.Reason = msgErr.Reason
+		}
+		send(conn, protocol.TypeError, errMsg)
+		return
+	}
+
+	// Validate chat ownership.
+	cs, err := h.deps.Chats.Get(ctx, msg.ChatID)
+	if err != nil || cs == nil || !cs.IsParticipant(sid) || cs.Status != chat.StatusActive {
+		log.Printf("[message] REJECTED session=%s chat=%s err=%v cs_nil=%v", sid, msg.ChatID, err, cs == nil)
+		if cs != nil {
+			log.Printf("[message]   status=%s isParticipant=%v", cs.Status, cs.IsParticipant(sid))
+		}
+		sendError(conn, protocol.ErrInvalidChat, "not in an active chat")
+		return
+	}
+
+	// ABUSE-2: Content filter check, with the blocklist of the sender's
+	// declared language and, in strict chats, the strict blocklist.
+	var lang string
+	if sess, _ := h.deps.Sessions.Get(ctx, sid); sess != nil {
+		lang = sess.Language
+	}
+	if result := h.deps.MessageFilter.CheckMessage(ctx, sid, msg.Text, lang); result.Blocked {
+		metrics.MessagesTotal.WithLabelValues("blocked").Inc()
+		h.enforceFilter(ctx, conn, result)
+		return
+	}
+	// The strict blocklist is language the platform allows; a strict chat
+	// only refuses it, without auditing, abuse counters or enforcement.
+	if cs.Policy == chat.PolicyStrict {
+		if result := h.deps.Strict.Check(msg.Text); result.Blocked {
+			log.Printf("[filter] message refused by strict policy session=%s chat=%s", sid, msg.ChatID)
+			errMsg := protocol.NewError(protocol.ErrMessageBlocked, "This chat does not allow profanity or crude language")
+			errMsg.Reason = moderation.ReasonStrictPolicy
+			send(conn, protocol.TypeError, errMsg)
+			return
+		}
+	}
+
+	log.Printf("[message] session=%s chat=%s text_len=%d", sid, msg.ChatID, len(msg.Text))
+	metrics.MessagesTotal.WithLabelValues("sent").Inc()
+
+	// CHAT-2: Publish message via NATS for delivery to partner.
+	now := time.Now().Unix()
+	messageID := uuid.New().String()
+	event := chat.ChatEvent{
+		Type:      "message",
+		From:      sid,
+		Text:      msg.Text,
+		Ts:        now,
+		MessageID: messageID,
+		SentAt:    conn.ReceivedAt().UnixNano(),
+		Origin:    h.deps.ServerName,
+	}
+	if id := cs.IdentityOf(sid); id.Nickname != "" {
+		event.Sender = &id
+	}
+	if h.deps.Translation {
+		event.Lang = lang
+	}
+	h.deps.Publisher.PublishChatEvent(msg.ChatID, event)
+	if err := h.deps.Chats.CountMessage(ctx, msg.ChatID, sid); err != nil {
+		log.Printf("[summary] count message chat=%s: %v", msg.ChatID, err)
+	}
+	if h.deps.Inactivity.Enabled() {
+		if err := h.deps.Chats.TouchActivity(ctx, msg.ChatID, time.Now()); err != nil {
+			log.Printf("[inactivity] touch chat=%s: %v", msg.ChatID, err)
+		}
+	}
+
+	// MOD-6: Buffer message for report context.
+	if err := h.deps.Buffer.Add(ctx, msg.ChatID, chat.BufferedMessage{
+		From: sid,
+		Text: msg.Text,
+		Ts:   now,
+	}); err != nil {
+		log.Printf("[report] buffer message chat=%s: %v", msg.ChatID, err)
+	}
+
+	if h.deps.History != nil {
+		if err := h.deps.History.Append(ctx, msg.ChatID, chat.HistoryEntry{
+			ID:   messageID,
+			From: sid,
+			Text: msg.Text,
+			Ts:   now,
+		}); err != nil {
+			log.Printf("[history] append failed chat=%s: %v", msg.ChatID, err)
+		}
+	}
+
+	// MOD-2: Async moderation check via NATS.
+	modData, _ := json.Marshal(moderation.ModerationRequest{
+		SessionID: sid,
+		ChatID:    msg.ChatID,
+		MessageID: messageID,
+		Text:      msg.Text,
+		Lang:      lang,
+		Ts:        now,
+	})
+	h.deps.Bus.PublishModerationRequest(modData)
+
+	// Bot detection takes Redis round trips per message; it runs off the
+	// dispatcher worker.
+	if h.deps.Bots != nil {
+		h.async(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), botTimeout)
+			defer cancel()
+			h.deps.Bots.Observe(ctx, sid, msg.ChatID, msg.Text)
+		})
+	}
+}
+
+// enforceFilter answers a blocked chat message of conn and escalates the
+// response to its repeated blocks: a warning naming the blocked category,
+// then a temporary mute, then a ban through the usual escalation.
+func (h *Handlers) enforceFilter(ctx context.Context, conn Conn, result moderation.FilterResult) {
+	sid := conn.SessionID()
+	en, err := h.deps.Offenses.Offend(ctx, sid)
+	if err != nil {
+		// Fail open to a warning rather than punish on a Redis error.
+		log.Printf("[filter] enforcement session=%s: %v", sid, err)
+		en = moderation.Enforcement{Action: moderation.EnforceWarn}
+	}
+
+	if en.Action == moderation.EnforceBan {
+		// The session is muted as well, which stands if it cannot be
+		// banned.
+		fp := ""
+		if sess, _ := h.deps.Sessions.Get(ctx, sid); sess != nil {
+			fp = sess.Fingerprint
+		}
+		if fp == "" {
+			en.Action = moderation.EnforceMute
+		} else if duration, err := h.deps.Bans.Escalate(ctx, fp, "content_filter"); err != nil {
+			log.Printf("[filter] ban fp=%s: %v", fp, err)
+			en.Action = moderation.EnforceMute
+		} else {
+			metrics.FilterEnforcementsTotal.WithLabelValues(en.Action).Inc()
+			log.Printf("[filter] session=%s fp=%s banned for %s after %d blocks", sid, fp, duration, en.Offenses)
+			h.deps.Audit.Record(ctx, &audit.Event{
+				Action:            audit.ActionBanApplied,
+				Actor:             audit.ActorSystem,
+				TargetFingerprint: fp,
+				Reason:            "content_filter",
+				Context: map[string]interface{}{
+					"duration_seconds": int(duration.Seconds()),
+					"trigger":          "content_filter",
+					"session_id":       sid,
+					"offenses":         en.Offenses,
+				},
+			})
+			h.ban(ctx, sid, duration, "content_filter")
+			return
+		}
+	}
+	metrics.FilterEnforcementsTotal.WithLabelValues(en.Action).Inc()
+
+	errMsg := protocol.NewError(protocol.ErrMessageBlocked, "Message contains prohibited content. Repeated violations will mute you.")
+	if en.Action == moderation.EnforceMute {
+		log.Printf("[filter] session=%s muted for %s after %d blocks", sid, en.MuteFor, en.Offenses)
+		errMsg = protocol.NewError(protocol.ErrMuted, "Message contains prohibited content. You are muted for repeated violations.")
+		errMsg.RetryAfter = int(math.Ceil(en.MuteFor.Seconds()))
+	}
+	errMsg.Reason = result.TermCategory()
+	send(conn, protocol.TypeError, errMsg)
+}
+
+// ban notifies sessionID of its ban and disconnects it, wherever it is
+// connected.
+func (h *Handlers) ban(ctx context.Context, sessionID string, duration time.Duration, reason string) {
+	resp, err := protocol.NewServerMessage(protocol.TypeBanned, protocol.BannedMsg{
+		Duration: int(duration.Seconds()),
+		Reason:   reason,
+	})
+	if err != nil {
+		return
+	}
+	h.deps.Deliverer.Deliver(ctx, sessionID, resp, ws.ClosePolicyViolation, "banned")
+}
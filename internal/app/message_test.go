@@ -0,0 +1,229 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/moderation"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/internal/ws"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+func TestMessage(t *testing.T) {
+	blocked := moderation.FilterResult{Blocked: true, Reason: "blocked_keyword", Term: "slur"}
+	tests := []struct {
+		name       string
+		msg        protocol.ChatMsg
+		setup      func(f *fixture)
+		wantType   string // last message to the sender, "" for none
+		wantCode   protocol.ErrorCode
+		wantReason string
+		sent       bool
+	}{
+		{
+			name: "sent",
+			msg:  protocol.ChatMsg{ChatID: "c1", Text: "hello"},
+			sent: true,
+		},
+		{
+			name:     "rate limited",
+			msg:      protocol.ChatMsg{ChatID: "c1", Text: "hello"},
+			setup:    func(f *fixture) { f.limiter.deny[ratelimit.RuleMessage.Key] = true },
+			wantType: protocol.TypeRateLimited,
+		},
+		{
+			name:     "byte budget spent",
+			msg:      protocol.ChatMsg{ChatID: "c1", Text: "hello"},
+			setup:    func(f *fixture) { f.limiter.deny[ratelimit.RuleMessageBytes.Key] = true },
+			wantType: protocol.TypeRateLimited,
+		},
+		{
+			name:     "muted",
+			msg:      protocol.ChatMsg{ChatID: "c1", Text: "hello"},
+			setup:    func(f *fixture) { f.offenses.muted = 30 * time.Second },
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrMuted,
+		},
+		{
+			name:       "too long",
+			msg:        protocol.ChatMsg{ChatID: "c1", Text: strings.Repeat("a", chat.MaxTextChars+1)},
+			wantType:   protocol.TypeError,
+			wantCode:   protocol.ErrInvalidMessage,
+			wantReason: chat.MessageTooManyChars,
+		},
+		{
+			name:     "chat ended",
+			msg:      protocol.ChatMsg{ChatID: "c2", Text: "hello"},
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrInvalidChat,
+		},
+		{
+			name:     "not a participant",
+			msg:      protocol.ChatMsg{ChatID: "p1", Text: "hello"},
+			setup:    func(f *fixture) { f.conn.id = "s2" },
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrInvalidChat,
+		},
+		{
+			name:       "filtered",
+			msg:        protocol.ChatMsg{ChatID: "c1", Text: "slur"},
+			setup:      func(f *fixture) { f.filtered["slur"] = blocked },
+			wantType:   protocol.TypeError,
+			wantCode:   protocol.ErrMessageBlocked,
+			wantReason: "word",
+		},
+		{
+			name: "refused in a strict chat",
+			msg:  protocol.ChatMsg{ChatID: "c1", Text: "damn"},
+			setup: func(f *fixture) {
+				f.chats.chats["c1"].Policy = chat.PolicyStrict
+				f.strict["damn"] = true
+			},
+			wantType:   protocol.TypeError,
+			wantCode:   protocol.ErrMessageBlocked,
+			wantReason: moderation.ReasonStrictPolicy,
+		},
+		{
+			name:  "allowed in a standard chat",
+			msg:   protocol.ChatMsg{ChatID: "c1", Text: "damn"},
+			setup: func(f *fixture) { f.strict["damn"] = true },
+			sent:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			f.h.Message(context.Background(), f.conn, tt.msg)
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if got := f.conn.lastCode(); got != string(tt.wantCode) {
+				t.Errorf("error code %q, want %q", got, tt.wantCode)
+			}
+			if tt.wantReason != "" {
+				if got, _ := f.conn.sent[len(f.conn.sent)-1]["reason"].(string); got != tt.wantReason {
+					t.Errorf("reason %q, want %q", got, tt.wantReason)
+				}
+			}
+			if got := len(f.published) == 1; got != tt.sent {
+				t.Fatalf("published = %+v, want %v", f.published, tt.sent)
+			}
+			if !tt.sent {
+				if len(f.bus.moderation) != 0 || len(f.buffer.messages) != 0 {
+					t.Errorf("moderation=%v buffer=%v for a message that was not sent", f.bus.moderation, f.buffer.messages)
+				}
+				return
+			}
+			ev := f.published[0].event
+			if ev.Type != "message" || ev.From != "s1" || ev.Text != tt.msg.Text || ev.MessageID == "" {
+				t.Errorf("published %+v", ev)
+			}
+			if len(f.chats.counted) != 1 || len(f.buffer.messages["c1"]) != 1 || len(f.history) != 1 {
+				t.Errorf("counted=%v buffered=%v history=%v, want one each", f.chats.counted, f.buffer.messages, f.history)
+			}
+			if len(f.bus.moderation) != 1 || f.bus.moderation[0].MessageID != ev.MessageID {
+				t.Errorf("moderation requests %+v, want one for %s", f.bus.moderation, ev.MessageID)
+			}
+			if len(f.observed) != 1 {
+				t.Errorf("bot detector saw %v, want the message", f.observed)
+			}
+		})
+	}
+}
+
+func TestMessage_FilterEscalation(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(f *fixture)
+		wantType  string
+		wantCode  protocol.ErrorCode
+		wantBan   bool
+		wantAudit int
+	}{
+		{
+			name:     "first block warns",
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrMessageBlocked,
+		},
+		{
+			name:     "repeated block mutes",
+			setup:    func(f *fixture) { f.offenses.count = 1 },
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrMuted,
+		},
+		{
+			name:      "persistent offender banned",
+			setup:     func(f *fixture) { f.offenses.count = 4 },
+			wantBan:   true,
+			wantAudit: 1,
+		},
+		{
+			name: "no fingerprint to ban",
+			setup: func(f *fixture) {
+				f.offenses.count = 4
+				f.sessions.sessions["s1"].Fingerprint = ""
+			},
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrMuted,
+		},
+		{
+			name:     "enforcement error warns",
+			setup:    func(f *fixture) { f.offenses.err = errRedis },
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrMessageBlocked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			f.filtered["slur"] = moderation.FilterResult{Blocked: true, Reason: "blocked_keyword", Term: "slur"}
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			f.h.Message(context.Background(), f.conn, protocol.ChatMsg{ChatID: "c1", Text: "slur"})
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if got := f.conn.lastCode(); got != string(tt.wantCode) {
+				t.Errorf("error code %q, want %q", got, tt.wantCode)
+			}
+			banned := len(f.delivered) == 1 && f.delivered[0] == delivery{"s1", protocol.TypeBanned, ws.ClosePolicyViolation}
+			if banned != tt.wantBan {
+				t.Errorf("delivered %+v, want ban=%v", f.delivered, tt.wantBan)
+			}
+			if tt.wantBan && (len(f.bans.escalated) != 1 || f.bans.escalated[0] != "fp1") {
+				t.Errorf("escalated %v, want [fp1]", f.bans.escalated)
+			}
+			if len(f.audited) != tt.wantAudit {
+				t.Errorf("audited %d events, want %d", len(f.audited), tt.wantAudit)
+			}
+			if len(f.published) != 0 {
+				t.Errorf("published a blocked message: %+v", f.published)
+			}
+		})
+	}
+}
+
+func TestMessage_BotDetectionOffWorker(t *testing.T) {
+	f := newFixture()
+	var deferred []func()
+	f.h.async = func(fn func()) { deferred = append(deferred, fn) }
+	f.h.Message(context.Background(), f.conn, protocol.ChatMsg{ChatID: "c1", Text: "hello"})
+
+	if len(f.observed) != 0 || len(deferred) != 1 {
+		t.Fatalf("observed=%v deferred=%d, want the detector deferred", f.observed, len(deferred))
+	}
+	deferred[0]()
+	if len(f.observed) != 1 {
+		t.Errorf("bot detector saw %v, want the message", f.observed)
+	}
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/featureflag"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// Typing relays a typing indicator to the chat (CHAT-3).
+func (h *Handlers) Typing(ctx context.Context, conn Conn, msg protocol.TypingMsg) {
+	h.deps.Publisher.PublishChatEvent(msg.ChatID, chat.ChatEvent{
+		Type:     "typing",
+		From:     conn.SessionID(),
+		IsTyping: msg.IsTyping,
+	})
+}
+
+// ChatMeta shares icebreaker and mood metadata with the partner.
+func (h *Handlers) ChatMeta(ctx context.Context, conn Conn, msg protocol.ChatMetaMsg) {
+	sid := conn.SessionID()
+	if !h.allow(ctx, conn, sid, ratelimit.RuleChatMeta, false) {
+		return
+	}
+	if err := chat.ValidateMeta(msg.Icebreaker, msg.Mood); err != nil {
+		sendError(conn, protocol.ErrInvalidMeta, err.Error())
+		return
+	}
+	if msg.Icebreaker != "" && h.deps.Filter.Blocked(ctx, sid, "chat_meta", msg.Icebreaker) {
+		sendError(conn, protocol.ErrMessageBlocked, "Icebreaker contains prohibited content")
+		return
+	}
+	if !h.activeParticipant(ctx, msg.ChatID, sid) {
+		return
+	}
+
+	h.deps.Publisher.PublishChatEvent(msg.ChatID, chat.ChatEvent{
+		Type:       "chat_meta",
+		From:       sid,
+		Icebreaker: msg.Icebreaker,
+		Mood:       msg.Mood,
+	})
+}
+
+// React relays an emoji reaction to one of the partner's messages.
+func (h *Handlers) React(ctx context.Context, conn Conn, msg protocol.ReactMsg) {
+	sid := conn.SessionID()
+	if !h.featureEnabled(ctx, conn, featureflag.Reactions) {
+		return
+	}
+	if !h.allow(ctx, conn, msg.ChatID+":"+sid, ratelimit.RuleReaction, true) {
+		return
+	}
+	if err := chat.ValidateReaction(msg.MessageID, msg.Emoji); err != nil {
+		sendError(conn, protocol.ErrInvalidReaction, err.Error())
+		return
+	}
+	if !h.activeParticipant(ctx, msg.ChatID, sid) {
+		return
+	}
+
+	h.deps.Publisher.PublishChatEvent(msg.ChatID, chat.ChatEvent{
+		Type:      "reaction",
+		From:      sid,
+		MessageID: msg.MessageID,
+		Emoji:     msg.Emoji,
+	})
+}
+
+// ShareCard relays an opt-in profile card to the partner. The card is never
+// stored.
+func (h *Handlers) ShareCard(ctx context.Context, conn Conn, msg protocol.ShareCardMsg) {
+	sid := conn.SessionID()
+	if !h.featureEnabled(ctx, conn, featureflag.ShareCard) {
+		return
+	}
+	if !h.allow(ctx, conn, sid, ratelimit.RuleShareCard, false) {
+		return
+	}
+
+	card := chat.NormalizeCard(chat.ProfileCard{
+		Nickname: msg.Nickname,
+		Pronouns: msg.Pronouns,
+		About:    msg.About,
+	})
+	if err := chat.ValidateCard(card); err != nil {
+		sendError(conn, protocol.ErrInvalidCard, err.Error())
+		return
+	}
+	if h.deps.Filter.Blocked(ctx, sid, "share_card", card.FilterText()) {
+		sendError(conn, protocol.ErrMessageBlocked, "Profile card contains prohibited content")
+		return
+	}
+	if !h.activeParticipant(ctx, msg.ChatID, sid) {
+		return
+	}
+
+	h.deps.Publisher.PublishChatEvent(msg.ChatID, chat.ChatEvent{
+		Type: "share_card",
+		From: sid,
+		Card: &card,
+	})
+}
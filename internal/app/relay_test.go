@@ -0,0 +1,243 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/featureflag"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+func TestTyping(t *testing.T) {
+	f := newFixture()
+	f.h.Typing(context.Background(), f.conn, protocol.TypingMsg{ChatID: "c1", IsTyping: true})
+
+	if len(f.published) != 1 {
+		t.Fatalf("published %d events, want 1", len(f.published))
+	}
+	got := f.published[0]
+	if got.chatID != "c1" || got.event.Type != "typing" || got.event.From != "s1" || !got.event.IsTyping {
+		t.Errorf("published %+v", got)
+	}
+}
+
+func TestChatMeta(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       protocol.ChatMetaMsg
+		setup     func(f *fixture)
+		wantType  string // last message to the sender, "" for none
+		wantCode  protocol.ErrorCode
+		published bool
+	}{
+		{
+			name:      "relayed",
+			msg:       protocol.ChatMetaMsg{ChatID: "c1", Icebreaker: "Tea, coffee, or neither?", Mood: "😀"},
+			published: true,
+		},
+		{
+			name:     "rate limited",
+			msg:      protocol.ChatMetaMsg{ChatID: "c1", Mood: "😀"},
+			setup:    func(f *fixture) { f.limiter.deny[ratelimit.RuleChatMeta.Key] = true },
+			wantType: protocol.TypeRateLimited,
+		},
+		{
+			name:     "empty",
+			msg:      protocol.ChatMetaMsg{ChatID: "c1"},
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrInvalidMeta,
+		},
+		{
+			name:     "icebreaker filtered",
+			msg:      protocol.ChatMetaMsg{ChatID: "c1", Icebreaker: "bad words"},
+			setup:    func(f *fixture) { f.blocked["bad words"] = true },
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrMessageBlocked,
+		},
+		{
+			name: "chat ended",
+			msg:  protocol.ChatMetaMsg{ChatID: "c2", Mood: "😀"},
+		},
+		{
+			name: "unknown chat",
+			msg:  protocol.ChatMetaMsg{ChatID: "nope", Mood: "😀"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			f.h.ChatMeta(context.Background(), f.conn, tt.msg)
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if got := f.conn.lastCode(); got != string(tt.wantCode) {
+				t.Errorf("error code %q, want %q", got, tt.wantCode)
+			}
+			if got := len(f.published) == 1; got != tt.published {
+				t.Fatalf("published = %v, want %v", f.published, tt.published)
+			}
+			if tt.published {
+				ev := f.published[0].event
+				if ev.Type != "chat_meta" || ev.From != "s1" || ev.Icebreaker != tt.msg.Icebreaker || ev.Mood != tt.msg.Mood {
+					t.Errorf("published %+v", ev)
+				}
+			}
+		})
+	}
+}
+
+func TestReact(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       protocol.ReactMsg
+		setup     func(f *fixture)
+		wantType  string
+		wantCode  protocol.ErrorCode
+		published bool
+	}{
+		{
+			name:      "relayed",
+			msg:       protocol.ReactMsg{ChatID: "c1", MessageID: "m1", Emoji: "👍"},
+			published: true,
+		},
+		{
+			name:      "cleared",
+			msg:       protocol.ReactMsg{ChatID: "c1", MessageID: "m1"},
+			published: true,
+		},
+		{
+			name:     "feature off",
+			msg:      protocol.ReactMsg{ChatID: "c1", MessageID: "m1", Emoji: "👍"},
+			setup:    func(f *fixture) { f.disabled[featureflag.Reactions] = true },
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrFeatureDisabled,
+		},
+		{
+			name:     "rate limited",
+			msg:      protocol.ReactMsg{ChatID: "c1", MessageID: "m1", Emoji: "👍"},
+			setup:    func(f *fixture) { f.limiter.deny[ratelimit.RuleReaction.Key] = true },
+			wantType: protocol.TypeRateLimited,
+		},
+		{
+			name:     "emoji not allowed",
+			msg:      protocol.ReactMsg{ChatID: "c1", MessageID: "m1", Emoji: "🍕"},
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrInvalidReaction,
+		},
+		{
+			name: "chat ended",
+			msg:  protocol.ReactMsg{ChatID: "c2", MessageID: "m1", Emoji: "👍"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			f.h.React(context.Background(), f.conn, tt.msg)
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if got := f.conn.lastCode(); got != string(tt.wantCode) {
+				t.Errorf("error code %q, want %q", got, tt.wantCode)
+			}
+			if got := len(f.published) == 1; got != tt.published {
+				t.Fatalf("published = %v, want %v", f.published, tt.published)
+			}
+			if tt.published {
+				ev := f.published[0].event
+				if ev.Type != "reaction" || ev.MessageID != "m1" || ev.Emoji != tt.msg.Emoji {
+					t.Errorf("published %+v", ev)
+				}
+			}
+		})
+	}
+}
+
+func TestReact_LimitedPerChat(t *testing.T) {
+	f := newFixture()
+	f.h.React(context.Background(), f.conn, protocol.ReactMsg{ChatID: "c1", MessageID: "m1", Emoji: "👍"})
+	if len(f.limiter.checked) != 1 || f.limiter.checked[0] != "c1:s1" {
+		t.Errorf("rate limited on %v, want [c1:s1]", f.limiter.checked)
+	}
+}
+
+func TestShareCard(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       protocol.ShareCardMsg
+		setup     func(f *fixture)
+		wantType  string
+		wantCode  protocol.ErrorCode
+		published bool
+	}{
+		{
+			name:      "relayed",
+			msg:       protocol.ShareCardMsg{ChatID: "c1", Nickname: "  Sam ", About: "jazz"},
+			published: true,
+		},
+		{
+			name:     "feature off",
+			msg:      protocol.ShareCardMsg{ChatID: "c1", Nickname: "Sam"},
+			setup:    func(f *fixture) { f.disabled[featureflag.ShareCard] = true },
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrFeatureDisabled,
+		},
+		{
+			name:     "rate limited",
+			msg:      protocol.ShareCardMsg{ChatID: "c1", Nickname: "Sam"},
+			setup:    func(f *fixture) { f.limiter.deny[ratelimit.RuleShareCard.Key] = true },
+			wantType: protocol.TypeRateLimited,
+		},
+		{
+			name:     "empty",
+			msg:      protocol.ShareCardMsg{ChatID: "c1", Nickname: "   "},
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrInvalidCard,
+		},
+		{
+			name:     "filtered",
+			msg:      protocol.ShareCardMsg{ChatID: "c1", Nickname: "Sam"},
+			setup:    func(f *fixture) { f.blocked[(chat.ProfileCard{Nickname: "Sam"}).FilterText()] = true },
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrMessageBlocked,
+		},
+		{
+			name: "not a participant",
+			msg:  protocol.ShareCardMsg{ChatID: "nope", Nickname: "Sam"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			f.h.ShareCard(context.Background(), f.conn, tt.msg)
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if got := f.conn.lastCode(); got != string(tt.wantCode) {
+				t.Errorf("error code %q, want %q", got, tt.wantCode)
+			}
+			if got := len(f.published) == 1; got != tt.published {
+				t.Fatalf("published = %v, want %v", f.published, tt.published)
+			}
+			if tt.published {
+				ev := f.published[0].event
+				if ev.Type != "share_card" || ev.Card == nil || ev.Card.Nickname != "Sam" || ev.Card.About != "jazz" {
+					t.Errorf("published %+v (card %+v)", ev, ev.Card)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/ban"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/internal/report"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// reportTimeout bounds the database work a report does after the reporter's
+// frame has been handled: the report record, audit events and the PostgreSQL
+// cross-check.
+const reportTimeout = 10 * time.Second
+
+// Report reports the chat partner for abuse (ABUSE-6). The report is
+// counted in Redis, which may ban the partner straight away; the report
+// record and the PostgreSQL cross-check run in the background.
+func (h *Handlers) Report(ctx context.Context, conn Conn, msg protocol.ReportMsg) {
+	sid := conn.SessionID()
+
+	// ABUSE-1: Rate limit reports (3 per 5 minutes per session).
+	if !h.allow(ctx, conn, sid, ratelimit.RuleReport, false) {
+		log.Printf("[ratelimit] report rejected session=%s", sid)
+		return
+	}
+
+	category, err := report.ParseCategory(msg.Reason)
+	if err == nil {
+		err = report.ValidateDetails(msg.Details)
+	}
+	if err != nil {
+		sendError(conn, protocol.ErrInvalidReason, err.Error())
+		return
+	}
+
+	// Resolve the partner and both fingerprints, count the report and apply
+	// any auto-ban (3 report points in 24h, weighted by category) in three
+	// Redis round trips.
+	res, err := h.deps.Bans.FileReport(ctx, msg.ChatID, sid, category.Weight())
+	if errors.Is(err, ban.ErrNotReportable) {
+		log.Printf("[report] invalid chat session=%s chat=%s", sid, msg.ChatID)
+		return
+	}
+	if err != nil {
+		// Fail open — the report was not counted, but don't crash.
+		log.Printf("[report] error tracking report session=%s chat=%s: %v", sid, msg.ChatID, err)
+		return
+	}
+	// Count the report for tier stats while the chat still exists: a ban
+	// disconnects the partner, which ends and deletes it.
+	if err := h.deps.Stats.RecordReported(ctx, &chat.ChatSession{ChatID: msg.ChatID, Tier: res.Tier}, time.Now()); err != nil {
+		log.Printf("[stats] record report chat=%s: %v", msg.ChatID, err)
+	}
+	if res.Banned {
+		h.ban(ctx, res.PartnerID, res.Duration, "multiple_reports")
+	}
+
+	// MOD-6: Capture buffered messages for the report now, before the chat
+	// moves on.
+	buffered, err := h.deps.Buffer.Get(ctx, msg.ChatID)
+	if err != nil {
+		log.Printf("[report] load message buffer chat=%s: %v", msg.ChatID, err)
+	}
+	messages := make([]report.MessageEntry, len(buffered))
+	for i, bm := range buffered {
+		messages[i] = report.MessageEntry{From: bm.From, Text: bm.Text, Ts: bm.Ts}
+	}
+
+	// The database work (report record, audit trail, PostgreSQL
+	// cross-check) runs off the dispatcher worker.
+	h.async(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+		defer cancel()
+		h.recordReport(ctx, sid, msg, category, res, messages)
+	})
+}
+
+// recordReport stores a report counted by Report, audits it and bans the
+// partner if the reports in PostgreSQL reach the threshold Redis missed.
+func (h *Handlers) recordReport(ctx context.Context, sid string, msg protocol.ReportMsg, category report.Category, res *ban.ReportResult, messages []report.MessageEntry) {
+	partnerID := res.PartnerID
+	if partnerID == "" || res.PartnerFingerprint == "" {
+		log.Printf("[report] partner session not found or missing fingerprint session=%s partner=%s", sid, partnerID)
+		return
+	}
+	partnerFP, reporterFP := res.PartnerFingerprint, res.ReporterFingerprint
+
+	// Store the report in PostgreSQL (if reporter fingerprint is available).
+	if reporterFP != "" {
+		r := &report.Report{
+			ReporterFingerprint: reporterFP,
+			ReportedFingerprint: partnerFP,
+			ChatID:              msg.ChatID,
+			Reason:              msg.Reason,
+			Category:            category,
+			Details:             msg.Details,
+			Messages:            messages,
+		}
+		if err := h.deps.Reports.Create(ctx, r); err != nil {
+			log.Printf("[report] failed to store in postgres: %v", err)
+			// Continue — the cross-check should still run even if PG write fails.
+		}
+	} else {
+		log.Printf("[report] reporter fingerprint empty, skipping postgres store session=%s", sid)
+	}
+	actor := audit.ActorSystem
+	if reporterFP != "" {
+		actor = audit.UserActor(reporterFP)
+	}
+	h.deps.Audit.Record(ctx, &audit.Event{
+		Action:            audit.ActionReportFiled,
+		Actor:             actor,
+		TargetFingerprint: partnerFP,
+		Reason:            string(category),
+		Context: map[string]interface{}{
+			"chat_id":           msg.ChatID,
+			"reporter_session":  sid,
+			"reported_session":  partnerID,
+			"messages_captured": len(messages),
+			"needs_review":      category.NeedsReview(),
+		},
+	})
+	metrics.ReportsTotal.WithLabelValues(string(category)).Inc()
+	if category.NeedsReview() {
+		log.Printf("[report] escalated to review category=%s fp=%s chat=%s", category, partnerFP, msg.ChatID)
+	}
+
+	banned := res.Banned
+	if banned {
+		h.deps.Audit.Record(ctx, &audit.Event{
+			Action:            audit.ActionBanApplied,
+			Actor:             audit.ActorSystem,
+			TargetFingerprint: partnerFP,
+			Reason:            "multiple_reports",
+			Context: map[string]interface{}{
+				"duration_seconds": int(res.Duration.Seconds()),
+				"trigger":          "report_threshold",
+				"chat_id":          msg.ChatID,
+			},
+		})
+	}
+
+	// ABUSE-8: PostgreSQL cross-check — catch bans that Redis missed (e.g.
+	// after a Redis restart that lost counters).
+	if !banned {
+		weight, err := h.deps.Reports.WeightRecent(ctx, partnerFP, 24*time.Hour)
+		if err != nil {
+			// Fail open — don't crash, just skip the PG check.
+			log.Printf("[report] pg cross-check failed fp=%s: %v", partnerFP, err)
+		} else if weight >= ban.AutoBanThreshold {
+			log.Printf("[report] pg cross-check triggered ban fp=%s pg_weight=%d (redis missed)", partnerFP, weight)
+			duration, err := h.deps.Bans.Escalate(ctx, partnerFP, "multiple_reports")
+			if err != nil {
+				log.Printf("[report] pg cross-check escalate failed fp=%s: %v", partnerFP, err)
+			} else {
+				banned = true
+				h.deps.Audit.Record(ctx, &audit.Event{
+					Action:            audit.ActionBanApplied,
+					Actor:             audit.ActorSystem,
+					TargetFingerprint: partnerFP,
+					Reason:            "multiple_reports",
+					Context: map[string]interface{}{
+						"duration_seconds": int(duration.Seconds()),
+						"trigger":          "postgres_cross_check",
+						"recent_weight":    weight,
+						"chat_id":          msg.ChatID,
+					},
+				})
+				h.ban(ctx, partnerID, duration, "multiple_reports")
+			}
+		}
+	}
+
+	log.Printf("[report] session=%s reported partner=%s fp=%s category=%s banned=%v",
+		sid, partnerID, partnerFP, category, banned)
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/ban"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/internal/report"
+	"github.com/whisper/chat-app/internal/ws"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+func TestReport(t *testing.T) {
+	counted := func() *ban.ReportResult {
+		return &ban.ReportResult{PartnerID: "s2", ReporterFingerprint: "fp1", PartnerFingerprint: "fp2"}
+	}
+	tests := []struct {
+		name        string
+		msg         protocol.ReportMsg
+		setup       func(f *fixture)
+		wantType    string
+		wantCode    protocol.ErrorCode
+		stored      bool
+		wantActions []string // audited actions, in order
+		wantBan     bool     // partner banned and disconnected
+	}{
+		{
+			name:        "filed",
+			msg:         protocol.ReportMsg{ChatID: "c1", Reason: string(report.CategorySpam), Details: "links"},
+			setup:       func(f *fixture) { f.bans.result = counted() },
+			stored:      true,
+			wantActions: []string{audit.ActionReportFiled},
+		},
+		{
+			name: "banned on the report threshold",
+			msg:  protocol.ReportMsg{ChatID: "c1", Reason: string(report.CategoryHarassment)},
+			setup: func(f *fixture) {
+				f.bans.result = counted()
+				f.bans.result.Banned, f.bans.result.Duration = true, time.Hour
+			},
+			stored:      true,
+			wantActions: []string{audit.ActionReportFiled, audit.ActionBanApplied},
+			wantBan:     true,
+		},
+		{
+			name: "banned by the PostgreSQL cross-check",
+			msg:  protocol.ReportMsg{ChatID: "c1", Reason: string(report.CategoryHarassment)},
+			setup: func(f *fixture) {
+				f.bans.result = counted()
+				f.reports.weight = ban.AutoBanThreshold
+			},
+			stored:      true,
+			wantActions: []string{audit.ActionReportFiled, audit.ActionBanApplied},
+			wantBan:     true,
+		},
+		{
+			name: "reporter without fingerprint",
+			msg:  protocol.ReportMsg{ChatID: "c1", Reason: string(report.CategorySpam)},
+			setup: func(f *fixture) {
+				f.bans.result = counted()
+				f.bans.result.ReporterFingerprint = ""
+			},
+			wantActions: []string{audit.ActionReportFiled},
+		},
+		{
+			name: "partner without fingerprint",
+			msg:  protocol.ReportMsg{ChatID: "c1", Reason: string(report.CategorySpam)},
+			setup: func(f *fixture) {
+				f.bans.result = counted()
+				f.bans.result.PartnerFingerprint = ""
+			},
+		},
+		{
+			name:     "rate limited",
+			msg:      protocol.ReportMsg{ChatID: "c1", Reason: string(report.CategorySpam)},
+			setup:    func(f *fixture) { f.limiter.deny[ratelimit.RuleReport.Key] = true },
+			wantType: protocol.TypeRateLimited,
+		},
+		{
+			name:     "unknown reason",
+			msg:      protocol.ReportMsg{ChatID: "c1", Reason: "boring"},
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrInvalidReason,
+		},
+		{
+			name:  "not reportable",
+			msg:   protocol.ReportMsg{ChatID: "c2", Reason: string(report.CategorySpam)},
+			setup: func(f *fixture) { f.bans.err = ban.ErrNotReportable },
+		},
+		{
+			name:  "redis error",
+			msg:   protocol.ReportMsg{ChatID: "c1", Reason: string(report.CategorySpam)},
+			setup: func(f *fixture) { f.bans.err = errRedis },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			f.buffer.messages["c1"] = []chat.BufferedMessage{{From: "s2", Text: "buy now", Ts: 1}}
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			f.h.Report(context.Background(), f.conn, tt.msg)
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if got := f.conn.lastCode(); got != string(tt.wantCode) {
+				t.Errorf("error code %q, want %q", got, tt.wantCode)
+			}
+			if stored := len(f.reports.created) == 1; stored != tt.stored {
+				t.Fatalf("stored %+v, want %v", f.reports.created, tt.stored)
+			}
+			if tt.stored {
+				r := f.reports.created[0]
+				if r.ReportedFingerprint != "fp2" || r.Category != report.Category(tt.msg.Reason) || len(r.Messages) != 1 {
+					t.Errorf("stored %+v", r)
+				}
+			}
+			var actions []string
+			for _, e := range f.audited {
+				actions = append(actions, e.Action)
+			}
+			if len(actions) != len(tt.wantActions) {
+				t.Fatalf("audited %v, want %v", actions, tt.wantActions)
+			}
+			for i := range actions {
+				if actions[i] != tt.wantActions[i] {
+					t.Errorf("audited %v, want %v", actions, tt.wantActions)
+				}
+			}
+			banned := len(f.delivered) == 1 && f.delivered[0] == delivery{"s2", protocol.TypeBanned, ws.ClosePolicyViolation}
+			if banned != tt.wantBan {
+				t.Errorf("delivered %+v, want ban=%v", f.delivered, tt.wantBan)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"log"
+
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// ClientInfo records the platform, app version and locale the client
+// declared on its session and sends the client its config again. Only a
+// session's first declaration is counted in the metrics.
+func (h *Handlers) ClientInfo(ctx context.Context, conn Conn, msg protocol.ClientInfoMsg) {
+	sid := conn.SessionID()
+	if !h.allow(ctx, conn, sid, ratelimit.RuleClientInfo, false) {
+		return
+	}
+	info, err := session.NormalizeClientInfo(msg.Platform, msg.AppVersion, msg.Locale)
+	if err != nil {
+		sendError(conn, protocol.ErrInvalidClientInfo, err.Error())
+		return
+	}
+	first, err := h.deps.Sessions.SetClientInfo(ctx, sid, info)
+	if err != nil {
+		log.Printf("client_info: failed for session=%s: %v", sid, err)
+		return
+	}
+
+	// Features may be gated by app version, so the client's config changes.
+	if msg := h.deps.Config.ConfigMessage(ctx, sid); msg != nil {
+		conn.WriteMessage(msg)
+	}
+
+	if !first {
+		return
+	}
+	platform := info.Platform
+	if platform == "" {
+		platform = "unknown"
+	}
+	metrics.ClientSessionsTotal.WithLabelValues(platform, session.VersionLabel(info.AppVersion, h.deps.ClientVersions)).Inc()
+}
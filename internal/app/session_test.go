@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/internal/ratelimit"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+func TestClientInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      protocol.ClientInfoMsg
+		setup    func(f *fixture)
+		wantType string
+		wantCode protocol.ErrorCode
+		want     *session.ClientInfo // stored info, nil for none
+	}{
+		{
+			name:     "stored",
+			msg:      protocol.ClientInfoMsg{Platform: "iOS", AppVersion: "v2.4.1", Locale: "en_us"},
+			wantType: protocol.TypeConfig,
+			want:     &session.ClientInfo{Platform: "ios", AppVersion: "2.4.1", Locale: "en-US"},
+		},
+		{
+			name:     "unknown platform",
+			msg:      protocol.ClientInfoMsg{Platform: "toaster", AppVersion: "1.0"},
+			wantType: protocol.TypeConfig,
+			want:     &session.ClientInfo{Platform: "other", AppVersion: "1.0"},
+		},
+		{
+			name:     "bad version",
+			msg:      protocol.ClientInfoMsg{Platform: "web", AppVersion: "latest"},
+			wantType: protocol.TypeError,
+			wantCode: protocol.ErrInvalidClientInfo,
+		},
+		{
+			name:     "rate limited",
+			msg:      protocol.ClientInfoMsg{Platform: "web", AppVersion: "1.0"},
+			setup:    func(f *fixture) { f.limiter.deny[ratelimit.RuleClientInfo.Key] = true },
+			wantType: protocol.TypeRateLimited,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			f.h.ClientInfo(context.Background(), f.conn, tt.msg)
+
+			if got := f.conn.lastType(); got != tt.wantType {
+				t.Errorf("sent %q, want %q", got, tt.wantType)
+			}
+			if got := f.conn.lastCode(); got != string(tt.wantCode) {
+				t.Errorf("error code %q, want %q", got, tt.wantCode)
+			}
+			got, stored := f.sessions.info["s1"]
+			if stored != (tt.want != nil) {
+				t.Fatalf("stored = %v, want %v", stored, tt.want != nil)
+			}
+			if tt.want != nil && got != *tt.want {
+				t.Errorf("stored %+v, want %+v", got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestClientInfo_CountsSessionOnce(t *testing.T) {
+	f := newFixture()
+	f.h.deps.ClientVersions = []string{"2.4"}
+	counter := metrics.ClientSessionsTotal.WithLabelValues("web", "2.4")
+	other := metrics.ClientSessionsTotal.WithLabelValues("web", "other")
+	before, beforeOther := testutil.ToFloat64(counter), testutil.ToFloat64(other)
+
+	f.h.ClientInfo(context.Background(), f.conn, protocol.ClientInfoMsg{Platform: "web", AppVersion: "2.4.1"})
+	f.h.ClientInfo(context.Background(), f.conn, protocol.ClientInfoMsg{Platform: "web", AppVersion: "2.4.2"})
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("counted %v times, want 1", got)
+	}
+
+	g := newFixture()
+	g.h.ClientInfo(context.Background(), g.conn, protocol.ClientInfoMsg{Platform: "web", AppVersion: "9000.1"})
+	if got := testutil.ToFloat64(other) - beforeOther; got != 1 {
+		t.Errorf("unknown release counted %v times as \"other\", want 1", got)
+	}
+}
@@ -0,0 +1,170 @@
+package appeal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/ban"
+	"github.com/whisper/chat-app/internal/session"
+)
+
+// maxRequestBytes bounds the body of an appeal submission.
+const maxRequestBytes = 16 << 10
+
+// fileRequest is the body accepted by the appeal endpoint.
+type fileRequest struct {
+	SessionID    string `json:"session_id"`
+	SessionToken string `json:"session_token"`
+	Fingerprint  string `json:"fingerprint"`
+	Statement    string `json:"statement"`
+}
+
+// Handler serves the public appeal endpoint:
+//
+//	POST /api/appeals  {"session_id", "session_token", "fingerprint", "statement"} appeal the current ban
+//
+// Only the banned session itself can appeal: the session token must match
+// and the fingerprint must be the one the session submitted, so nobody can
+// use up someone else's appeal. The ban's disconnect keeps the session for
+// session.AppealWindow, and a banned user who reconnects gets a new one as
+// soon as set_fingerprint is refused. A ban can be appealed once: the claim is kept in Redis
+// next to the ban and expires with it. The response is 201 with {"id",
+// "status"}, 403 if the session or fingerprint does not match, 404 if the
+// fingerprint is not banned and 429 if this ban was already appealed.
+type Handler struct {
+	store    *Store
+	bans     *ban.Store
+	sessions *session.Store
+	auditLog *audit.Logger
+}
+
+// NewHandler creates the public appeal endpoint. auditLog may be nil.
+func NewHandler(store *Store, bans *ban.Store, sessions *session.Store, auditLog *audit.Logger) *Handler {
+	return &Handler{store: store, bans: bans, sessions: sessions, auditLog: auditLog}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req fileRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil ||
+		req.SessionID == "" || req.SessionToken == "" || req.Fingerprint == "" {
+		writeError(w, http.StatusBadRequest, "session_id, session_token, fingerprint and statement are required")
+		return
+	}
+	statement, err := NormalizeStatement(req.Statement)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	sess, err := h.sessions.Authenticate(ctx, req.SessionID, req.SessionToken)
+	switch {
+	case errors.Is(err, session.ErrNotFound), errors.Is(err, session.ErrInvalidToken):
+		writeError(w, http.StatusForbidden, "invalid session")
+		return
+	case err != nil:
+		log.Printf("[appeal] session lookup session=%s: %v", req.SessionID, err)
+		writeError(w, http.StatusInternalServerError, "failed to file appeal")
+		return
+	}
+	if sess.Fingerprint != req.Fingerprint {
+		writeError(w, http.StatusForbidden, "fingerprint does not belong to this session")
+		return
+	}
+	banned, remaining, reason, err := h.bans.IsBanned(ctx, req.Fingerprint)
+	if err != nil {
+		log.Printf("[appeal] ban lookup: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to file appeal")
+		return
+	}
+	if !banned {
+		writeError(w, http.StatusNotFound, "fingerprint is not banned")
+		return
+	}
+
+	claimed, err := h.bans.ClaimAppeal(ctx, req.Fingerprint)
+	if errors.Is(err, ban.ErrNotBanned) { // expired since IsBanned
+		writeError(w, http.StatusNotFound, "fingerprint is not banned")
+		return
+	}
+	if err != nil {
+		log.Printf("[appeal] claim: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to file appeal")
+		return
+	}
+	if !claimed {
+		writeError(w, http.StatusTooManyRequests, "this ban has already been appealed")
+		return
+	}
+
+	a := &Appeal{Fingerprint: req.Fingerprint, BanReason: reason, Statement: statement}
+	if err := h.store.Create(ctx, a); err != nil {
+		// Give the claim back so the user can retry; an appeal that is still
+		// pending from an earlier ban keeps blocking new ones.
+		if relErr := h.bans.ReleaseAppeal(context.WithoutCancel(ctx), req.Fingerprint); relErr != nil {
+			log.Printf("[appeal] release claim fp=%s: %v", req.Fingerprint, relErr)
+		}
+		if errors.Is(err, ErrPending) {
+			writeError(w, http.StatusTooManyRequests, "an appeal is already pending")
+			return
+		}
+		log.Printf("[appeal] create: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to file appeal")
+		return
+	}
+
+	event := &audit.Event{
+		Action:            audit.ActionAppealFiled,
+		Actor:             audit.UserActor(req.Fingerprint),
+		TargetFingerprint: req.Fingerprint,
+		Reason:            reason,
+		Context: map[string]interface{}{
+			"appeal_id":         a.ID,
+			"remaining_seconds": remaining,
+		},
+	}
+	if err := h.auditLog.Record(ctx, event); err != nil {
+		log.Printf("[audit] failed to record appeal %d fp=%s: %v", a.ID, req.Fingerprint, err)
+	}
+	log.Printf("[appeal] appeal %d filed fp=%s ban_reason=%s", a.ID, req.Fingerprint, reason)
+
+	writeJSON(w, http.StatusCreated, struct {
+		ID     int64  `json:"id"`
+		Status string `json:"status"`
+	}{ID: a.ID, Status: a.Status})
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[appeal] encode response: %v", err)
+	}
+}
+
+// writeError sends a JSON error body of the form {"error": "..."}.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{Error: message})
+}
@@ -0,0 +1,120 @@
+package appeal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/ban"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// appealFixture is a handler over Redis-backed bans and sessions, with one
+// session that submitted fingerprint "fp-banned".
+type appealFixture struct {
+	h         *Handler
+	bans      *ban.Store
+	sessionID string
+	token     string
+}
+
+// newAppealFixture creates the fixture. store may be nil for requests that
+// are rejected before an appeal is filed.
+func newAppealFixture(t *testing.T, store *Store) *appealFixture {
+	t.Helper()
+	rdb := testutil.Redis(t)
+	ctx := context.Background()
+	sessions := session.NewStoreWithClient(rdb, "ws-test")
+	token, err := sessions.Create(ctx, "sess-1")
+	if err != nil {
+		t.Fatalf("Create session: %v", err)
+	}
+	if err := sessions.SetFingerprint(ctx, "sess-1", "fp-banned"); err != nil {
+		t.Fatalf("SetFingerprint: %v", err)
+	}
+	bans := ban.NewStore(rdb)
+	return &appealFixture{
+		h:         NewHandler(store, bans, sessions, nil),
+		bans:      bans,
+		sessionID: "sess-1",
+		token:     token,
+	}
+}
+
+// file posts body to the handler.
+func (f *appealFixture) file(body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	f.h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/appeals", strings.NewReader(body)))
+	return rec
+}
+
+// body returns an appeal request body with the fixture's session.
+func (f *appealFixture) body(token, fingerprint string) string {
+	return `{"session_id":"` + f.sessionID + `","session_token":"` + token +
+		`","fingerprint":"` + fingerprint + `","statement":"I was reported by mistake."}`
+}
+
+func TestHandler_Rejects(t *testing.T) {
+	f := newAppealFixture(t, nil)
+	if err := f.bans.Ban(context.Background(), "fp-banned", time.Hour, "spam"); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if err := f.bans.Ban(context.Background(), "fp-other", time.Hour, "spam"); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"no session", `{"fingerprint":"fp-banned","statement":"please"}`, http.StatusBadRequest},
+		{"no fingerprint", f.body(f.token, ""), http.StatusBadRequest},
+		{"no statement", strings.Replace(f.body(f.token, "fp-banned"), "I was reported by mistake.", "", 1), http.StatusBadRequest},
+		{"wrong token", f.body("not-the-token", "fp-banned"), http.StatusForbidden},
+		{"unknown session", strings.Replace(f.body(f.token, "fp-banned"), f.sessionID, "sess-gone", 1), http.StatusForbidden},
+		{"someone else's fingerprint", f.body(f.token, "fp-other"), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := f.file(tt.body); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	// A rejected request must not use up the ban's one appeal.
+	if claimed, err := f.bans.ClaimAppeal(context.Background(), "fp-other"); err != nil || !claimed {
+		t.Errorf("ClaimAppeal(fp-other) = %v, %v; want the appeal still available", claimed, err)
+	}
+}
+
+func TestHandler_NotBanned(t *testing.T) {
+	f := newAppealFixture(t, nil)
+	if rec := f.file(f.body(f.token, "fp-banned")); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestHandler_FilesOnce(t *testing.T) {
+	store := NewStore(testutil.Postgres(t))
+	f := newAppealFixture(t, store)
+	if err := f.bans.Ban(context.Background(), "fp-banned", time.Hour, "spam"); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+
+	if rec := f.file(f.body(f.token, "fp-banned")); rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+	if rec := f.file(f.body(f.token, "fp-banned")); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second appeal status = %d, want 429: %s", rec.Code, rec.Body)
+	}
+	pending, err := store.List(context.Background(), StatusPending, 10)
+	if err != nil || len(pending) != 1 || pending[0].Fingerprint != "fp-banned" || pending[0].BanReason != "spam" {
+		t.Errorf("pending appeals = %+v, %v", pending, err)
+	}
+}
@@ -0,0 +1,239 @@
+// Package appeal provides PostgreSQL-backed storage for ban appeals: a
+// banned user's statement asking for the ban to be lifted, and the
+// moderator's decision, which is delivered to the user when they next
+// connect.
+package appeal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// MaxStatementLength is the most characters an appeal statement may have.
+const MaxStatementLength = 2000
+
+// Appeal statuses, matching the CHECK constraint on ban_appeals.
+const (
+	StatusPending = "pending"
+	StatusLifted  = "lifted" // the ban was lifted
+	StatusUpheld  = "upheld" // the ban stays
+)
+
+var (
+	// ErrNotFound is returned when no appeal has the given ID.
+	ErrNotFound = errors.New("appeal: not found")
+
+	// ErrPending is returned by Create when the fingerprint already has a
+	// pending appeal.
+	ErrPending = errors.New("appeal: an appeal is already pending")
+
+	// ErrDecided is returned by Decide for an appeal that is not pending.
+	ErrDecided = errors.New("appeal: already decided")
+)
+
+// Appeal is a banned user's request to lift the ban on their fingerprint.
+type Appeal struct {
+	ID           int64      `json:"id"`
+	Fingerprint  string     `json:"fingerprint"`
+	BanReason    string     `json:"ban_reason"`
+	Statement    string     `json:"statement"`
+	Status       string     `json:"status"`
+	DecidedBy    string     `json:"decided_by,omitempty"`
+	DecisionNote string     `json:"decision_note,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	NotifiedAt   *time.Time `json:"notified_at,omitempty"`
+}
+
+// ParseDecision maps a moderator's decision, "lift" or "uphold", to the
+// status it gives an appeal.
+func ParseDecision(decision string) (string, error) {
+	switch decision {
+	case "lift":
+		return StatusLifted, nil
+	case "uphold":
+		return StatusUpheld, nil
+	}
+	return "", fmt.Errorf("appeal: decision must be \"lift\" or \"uphold\"")
+}
+
+// NormalizeStatement trims a statement and checks it is non-empty valid
+// UTF-8 of at most MaxStatementLength characters.
+func NormalizeStatement(statement string) (string, error) {
+	statement = strings.TrimSpace(statement)
+	if statement == "" {
+		return "", fmt.Errorf("appeal: statement is required")
+	}
+	if !utf8.ValidString(statement) {
+		return "", fmt.Errorf("appeal: statement contains invalid UTF-8")
+	}
+	if utf8.RuneCountInString(statement) > MaxStatementLength {
+		return "", fmt.Errorf("appeal: statement exceeds %d characters", MaxStatementLength)
+	}
+	return statement, nil
+}
+
+// Store manages ban appeals in PostgreSQL.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new appeal store backed by the given database handle.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// appealColumns lists the columns scanned by scanAppeal, in order.
+const appealColumns = `id, fingerprint, ban_reason, statement, status, decided_by,
+	decision_note, created_at, decided_at, notified_at`
+
+// Create validates and inserts a pending appeal, filling in its ID, Status
+// and CreatedAt. It returns ErrPending if the fingerprint already has one.
+func (s *Store) Create(ctx context.Context, a *Appeal) error {
+	statement, err := NormalizeStatement(a.Statement)
+	if err != nil {
+		return err
+	}
+	if a.Fingerprint == "" {
+		return fmt.Errorf("appeal: fingerprint is required")
+	}
+	a.Statement = statement
+	a.Status = StatusPending
+
+	const query = `
+		INSERT INTO ban_appeals (fingerprint, ban_reason, statement)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	err = s.db.QueryRowContext(ctx, query, a.Fingerprint, a.BanReason, a.Statement).Scan(&a.ID, &a.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		return ErrPending
+	}
+	if err != nil {
+		return fmt.Errorf("appeal: insert: %w", err)
+	}
+	return nil
+}
+
+// Get returns the appeal with the given ID, or ErrNotFound.
+func (s *Store) Get(ctx context.Context, id int64) (*Appeal, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+appealColumns+` FROM ban_appeals WHERE id = $1`, id)
+	a, err := scanAppeal(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("appeal: get: %w", err)
+	}
+	return a, nil
+}
+
+// List returns up to limit appeals with the given status, oldest first so
+// the moderation queue is worked in order.
+func (s *Store) List(ctx context.Context, status string, limit int) ([]Appeal, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+appealColumns+`
+		FROM ban_appeals
+		WHERE status = $1
+		ORDER BY created_at, id
+		LIMIT $2`, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("appeal: list: %w", err)
+	}
+	return collect(rows)
+}
+
+// Decide records a moderator's decision on a pending appeal and returns the
+// updated appeal. It returns ErrNotFound for an unknown ID and ErrDecided if
+// the appeal was already decided.
+func (s *Store) Decide(ctx context.Context, id int64, status, decidedBy, note string) (*Appeal, error) {
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE ban_appeals
+		SET status = $2, decided_by = $3, decision_note = $4, decided_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING `+appealColumns, id, status, decidedBy, note)
+	a, err := scanAppeal(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, getErr := s.Get(ctx, id); getErr != nil {
+			return nil, getErr
+		}
+		return nil, ErrDecided
+	}
+	if err != nil {
+		return nil, fmt.Errorf("appeal: decide: %w", err)
+	}
+	return a, nil
+}
+
+// TakeDecisions passes the decided appeals of fingerprint that the user has
+// not been told about yet to deliver, oldest first, and marks each one
+// delivered once deliver returns nil. It stops at the first failed
+// delivery, leaving that appeal and the later ones to be offered again on
+// the user's next connection. Two connections taking decisions at once may
+// both deliver one; a decision is never marked without being delivered.
+func (s *Store) TakeDecisions(ctx context.Context, fingerprint string, deliver func(Appeal) error) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+appealColumns+`
+		FROM ban_appeals
+		WHERE fingerprint = $1 AND status <> 'pending' AND notified_at IS NULL
+		ORDER BY id`, fingerprint)
+	if err != nil {
+		return fmt.Errorf("appeal: take decisions: %w", err)
+	}
+	decided, err := collect(rows)
+	if err != nil {
+		return err
+	}
+	for _, a := range decided {
+		if err := deliver(a); err != nil {
+			return fmt.Errorf("appeal: deliver decision %d: %w", a.ID, err)
+		}
+		if _, err := s.db.ExecContext(ctx, `UPDATE ban_appeals SET notified_at = NOW() WHERE id = $1 AND notified_at IS NULL`, a.ID); err != nil {
+			return fmt.Errorf("appeal: mark decision %d delivered: %w", a.ID, err)
+		}
+	}
+	return nil
+}
+
+// collect scans and closes rows.
+func collect(rows *sql.Rows) ([]Appeal, error) {
+	defer rows.Close()
+	appeals := []Appeal{}
+	for rows.Next() {
+		a, err := scanAppeal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("appeal: scan: %w", err)
+		}
+		appeals = append(appeals, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("appeal: scan: %w", err)
+	}
+	return appeals, nil
+}
+
+// scanAppeal scans one row of appealColumns.
+func scanAppeal(row interface{ Scan(...interface{}) error }) (*Appeal, error) {
+	var a Appeal
+	var decidedAt, notifiedAt sql.NullTime
+	err := row.Scan(&a.ID, &a.Fingerprint, &a.BanReason, &a.Statement, &a.Status, &a.DecidedBy,
+		&a.DecisionNote, &a.CreatedAt, &decidedAt, &notifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	if decidedAt.Valid {
+		a.DecidedAt = &decidedAt.Time
+	}
+	if notifiedAt.Valid {
+		a.NotifiedAt = &notifiedAt.Time
+	}
+	return &a, nil
+}
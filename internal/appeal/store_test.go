@@ -0,0 +1,79 @@
+package appeal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestNormalizeStatement(t *testing.T) {
+	got, err := NormalizeStatement("  I was reported by mistake.\n")
+	if err != nil || got != "I was reported by mistake." {
+		t.Errorf("NormalizeStatement = %q, %v", got, err)
+	}
+
+	// The limit counts characters, not bytes.
+	if _, err := NormalizeStatement(strings.Repeat("é", MaxStatementLength)); err != nil {
+		t.Errorf("statement of %d characters rejected: %v", MaxStatementLength, err)
+	}
+
+	for name, statement := range map[string]string{
+		"empty":    "",
+		"blank":    " \t\n",
+		"too long": strings.Repeat("a", MaxStatementLength+1),
+		"invalid":  "bad \xff byte",
+	} {
+		if _, err := NormalizeStatement(statement); err == nil {
+			t.Errorf("%s statement accepted", name)
+		}
+	}
+}
+
+func TestParseDecision(t *testing.T) {
+	for decision, want := range map[string]string{"lift": StatusLifted, "uphold": StatusUpheld} {
+		if got, err := ParseDecision(decision); err != nil || got != want {
+			t.Errorf("ParseDecision(%q) = %q, %v; want %q", decision, got, err, want)
+		}
+	}
+	for _, decision := range []string{"", "lifted", "LIFT", "pending"} {
+		if _, err := ParseDecision(decision); err == nil {
+			t.Errorf("ParseDecision(%q) accepted", decision)
+		}
+	}
+}
+
+func TestStore_TakeDecisions(t *testing.T) {
+	store := NewStore(testutil.Postgres(t))
+	ctx := context.Background()
+	a := &Appeal{Fingerprint: "fp-banned", BanReason: "spam", Statement: "please"}
+	if err := store.Create(ctx, a); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := store.Decide(ctx, a.ID, StatusLifted, "alice", ""); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+
+	// A failed delivery leaves the decision to be delivered again.
+	errDropped := errors.New("connection dropped")
+	err := store.TakeDecisions(ctx, "fp-banned", func(Appeal) error { return errDropped })
+	if !errors.Is(err, errDropped) {
+		t.Fatalf("TakeDecisions = %v, want the delivery error", err)
+	}
+	var delivered []int64
+	deliver := func(a Appeal) error {
+		delivered = append(delivered, a.ID)
+		return nil
+	}
+	if err := store.TakeDecisions(ctx, "fp-banned", deliver); err != nil || len(delivered) != 1 || delivered[0] != a.ID {
+		t.Fatalf("TakeDecisions delivered %v, %v; want appeal %d", delivered, err, a.ID)
+	}
+	if err := store.TakeDecisions(ctx, "fp-banned", deliver); err != nil || len(delivered) != 1 {
+		t.Errorf("delivered decision offered again: %v, %v", delivered, err)
+	}
+	if got, err := store.Get(ctx, a.ID); err != nil || got.NotifiedAt == nil {
+		t.Errorf("Get = %+v, %v; want it marked notified", got, err)
+	}
+}
@@ -0,0 +1,168 @@
+// Package audit records moderation and ban actions in an append-only
+// PostgreSQL table. Every ban, report, blocked message and admin action is
+// written with who did it, whom it affected and why, so a contested ban can
+// be traced back to the events behind it.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Actions recorded in the audit log, matching the CHECK constraint on the
+// audit_log table.
+const (
+	ActionBanApplied      = "ban_applied"
+	ActionReportFiled     = "report_filed"
+	ActionMessageBlocked  = "message_blocked"
+	ActionAdminUnban      = "admin_unban"
+	ActionAdminBan        = "admin_ban"            // ban placed through the admin API
+	ActionAdminBanImport  = "admin_ban_import"     // ban list imported through the admin API
+	ActionAppealFiled     = "appeal_filed"         // banned user asked for the ban to be lifted
+	ActionAppealDecided   = "appeal_decided"       // moderator lifted or upheld a ban on appeal
+	ActionAdminTerminate  = "admin_terminate_chat" // moderator ended a chat, capturing its transcript
+	ActionBotFlagged      = "bot_flagged"          // message behaviour marked the fingerprint as a probable bot
+	ActionAdminDisconnect = "admin_disconnect"     // operator closed a session's connection
+
+	ActionAdminMaintenanceOn  = "admin_maintenance_on"  // operator switched maintenance mode on or updated it
+	ActionAdminMaintenanceOff = "admin_maintenance_off" // operator switched maintenance mode off
+)
+
+// Actors for events not initiated by a person.
+const (
+	ActorSystem    = "system"    // automatic bans
+	ActorFilter    = "filter"    // synchronous content filter in wsserver
+	ActorModerator = "moderator" // asynchronous moderation worker
+)
+
+var validActions = map[string]bool{
+	ActionBanApplied:          true,
+	ActionReportFiled:         true,
+	ActionMessageBlocked:      true,
+	ActionAdminUnban:          true,
+	ActionAdminBan:            true,
+	ActionAdminBanImport:      true,
+	ActionAppealFiled:         true,
+	ActionAppealDecided:       true,
+	ActionAdminTerminate:      true,
+	ActionBotFlagged:          true,
+	ActionAdminDisconnect:     true,
+	ActionAdminMaintenanceOn:  true,
+	ActionAdminMaintenanceOff: true,
+}
+
+// Event is one audited action. Context carries action-specific details such
+// as the chat ID, ban duration or matched term.
+type Event struct {
+	ID                int64                  `json:"id"`
+	Action            string                 `json:"action"`
+	Actor             string                 `json:"actor"`
+	TargetFingerprint string                 `json:"target_fingerprint"`
+	Reason            string                 `json:"reason"`
+	Context           map[string]interface{} `json:"context,omitempty"`
+	CreatedAt         time.Time              `json:"created_at"`
+}
+
+// UserActor returns the actor string for an action taken by an end user,
+// identified by fingerprint.
+func UserActor(fingerprint string) string {
+	return "user:" + fingerprint
+}
+
+// AdminActor returns the actor string for an action taken through the admin
+// API by the named operator.
+func AdminActor(name string) string {
+	return "admin:" + name
+}
+
+// Logger writes audit events to PostgreSQL. A nil *Logger discards events,
+// so services without a database can share the same call sites.
+type Logger struct {
+	db *sql.DB
+}
+
+// NewLogger creates an audit logger backed by the given database handle.
+func NewLogger(db *sql.DB) *Logger {
+	return &Logger{db: db}
+}
+
+// Record validates and inserts an event, filling in its ID and CreatedAt.
+func (l *Logger) Record(ctx context.Context, event *Event) error {
+	if l == nil {
+		return nil
+	}
+	if !validActions[event.Action] {
+		return fmt.Errorf("audit: invalid action %q", event.Action)
+	}
+	if event.Actor == "" {
+		return fmt.Errorf("audit: actor is required")
+	}
+
+	contextJSON := []byte("{}")
+	if len(event.Context) > 0 {
+		var err error
+		contextJSON, err = json.Marshal(event.Context)
+		if err != nil {
+			return fmt.Errorf("audit: marshal context: %w", err)
+		}
+	}
+
+	const query = `
+		INSERT INTO audit_log (action, actor, target_fingerprint, reason, context)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	err := l.db.QueryRowContext(ctx, query,
+		event.Action,
+		event.Actor,
+		event.TargetFingerprint,
+		event.Reason,
+		contextJSON,
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("audit: insert: %w", err)
+	}
+	return nil
+}
+
+// ListByFingerprint returns up to limit events targeting a fingerprint,
+// newest first. A nil *Logger has no events.
+func (l *Logger) ListByFingerprint(ctx context.Context, fingerprint string, limit int) ([]Event, error) {
+	if l == nil {
+		return []Event{}, nil
+	}
+	const query = `
+		SELECT id, action, actor, target_fingerprint, reason, context, created_at
+		FROM audit_log
+		WHERE target_fingerprint = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := l.db.QueryContext(ctx, query, fingerprint, limit)
+	if err != nil {
+		return nil, fmt.Errorf("audit: list: %w", err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		var contextJSON []byte
+		if err := rows.Scan(&e.ID, &e.Action, &e.Actor, &e.TargetFingerprint, &e.Reason, &contextJSON, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("audit: scan: %w", err)
+		}
+		if len(contextJSON) > 0 {
+			if err := json.Unmarshal(contextJSON, &e.Context); err != nil {
+				return nil, fmt.Errorf("audit: unmarshal context: %w", err)
+			}
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("audit: list: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecord_NilLoggerDiscards(t *testing.T) {
+	var l *Logger
+	if err := l.Record(context.Background(), &Event{Action: ActionBanApplied, Actor: ActorSystem}); err != nil {
+		t.Errorf("nil logger should discard events, got %v", err)
+	}
+}
+
+func TestRecord_Validation(t *testing.T) {
+	// Validation runs before the database is touched, so a logger without a
+	// handle is enough to exercise it.
+	l := NewLogger(nil)
+	tests := []struct {
+		name  string
+		event Event
+	}{
+		{"unknown action", Event{Action: "deleted_everything", Actor: ActorSystem}},
+		{"empty action", Event{Actor: ActorSystem}},
+		{"missing actor", Event{Action: ActionReportFiled}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := l.Record(context.Background(), &tt.event); err == nil {
+				t.Errorf("expected validation error for %+v", tt.event)
+			}
+		})
+	}
+}
+
+func TestActors(t *testing.T) {
+	if got := UserActor("fp1"); got != "user:fp1" {
+		t.Errorf("UserActor = %q", got)
+	}
+	if got := AdminActor("alex"); got != "admin:alex" {
+		t.Errorf("AdminActor = %q", got)
+	}
+}
@@ -0,0 +1,55 @@
+package ban
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotBanned is returned by ClaimAppeal for a fingerprint without a ban.
+var ErrNotBanned = errors.New("ban: fingerprint is not banned")
+
+// ClaimAppeal marks the current ban on fingerprint as appealed. It returns
+// false if the ban was already appealed and ErrNotBanned if there is no ban.
+// The marker lives exactly as long as the ban.
+func (s *Store) ClaimAppeal(ctx context.Context, fingerprint string) (bool, error) {
+	n, err := claimAppealScript.Run(ctx, s.client,
+		[]string{BanPrefix + fingerprint, AppealPrefix + fingerprint}).Int()
+	if err != nil {
+		return false, fmt.Errorf("ban: claim appeal: %w", err)
+	}
+	if n < 0 {
+		return false, ErrNotBanned
+	}
+	return n == 1, nil
+}
+
+// ReleaseAppeal removes the appeal marker set by ClaimAppeal, for when the
+// appeal could not be recorded and the user should be able to retry.
+func (s *Store) ReleaseAppeal(ctx context.Context, fingerprint string) error {
+	return s.client.Del(ctx, AppealPrefix+fingerprint).Err()
+}
+
+var claimAppealScript = redis.NewScript(claimAppealLua)
+
+// claimAppealLua sets the appeal marker KEYS[2] unless it exists, expiring
+// it with the ban KEYS[1]. Returns -1 without a ban, 0 if the marker
+// already existed and 1 if it was set.
+const claimAppealLua = `
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+    return -1
+end
+local ok
+if ttl > 0 then
+    ok = redis.call('SET', KEYS[2], '1', 'NX', 'PX', ttl)
+else
+    ok = redis.call('SET', KEYS[2], '1', 'NX')
+end
+if ok then
+    return 1
+end
+return 0
+`
@@ -0,0 +1,253 @@
+package ban
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Network bans block IP addresses and CIDR ranges at WebSocket upgrade time.
+// Each banned network is a key holding the reason, with the ban duration as
+// its TTL, keyed by the masked prefix:
+//
+//	Key:   ban_net:<prefix>          e.g. ban_net:203.0.113.0/24
+//	Value: <reason>
+//	TTL:   ban duration
+//
+// A lookup cannot enumerate every covering range, so the prefix lengths in
+// use are indexed in a set ("4/24", "6/64", ...). Checking an address masks
+// it to each indexed length and fetches the candidate keys with one MGET;
+// the longest matching prefix wins.
+const (
+	NetBanPrefix  = "ban_net:"
+	NetLengthsKey = "ban_net_lengths"
+)
+
+// ParseNetwork parses an IP address or CIDR range. A bare address is a
+// single-host prefix; host bits of a CIDR are masked off.
+func ParseNetwork(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("ban: invalid network %q: %w", s, err)
+		}
+		if p.Addr().Is4In6() {
+			p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("ban: invalid network %q: %w", s, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// lengthMember is the NetLengthsKey member for a prefix: "<family>/<bits>".
+func lengthMember(p netip.Prefix) string {
+	family := "6"
+	if p.Addr().Is4() {
+		family = "4"
+	}
+	return family + "/" + strconv.Itoa(p.Bits())
+}
+
+// BanNetwork bans every address in the prefix for duration (0 = until
+// lifted).
+func (s *Store) BanNetwork(ctx context.Context, prefix netip.Prefix, duration time.Duration, reason string) error {
+	prefix = prefix.Masked()
+	pipe := s.client.TxPipeline()
+	pipe.SAdd(ctx, NetLengthsKey, lengthMember(prefix))
+	pipe.Set(ctx, NetBanPrefix+prefix.String(), reason, duration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// UnbanNetwork lifts a network ban. The prefix must match the banned prefix
+// exactly; lifting 10.0.0.0/8 does not lift 10.1.0.0/16.
+func (s *Store) UnbanNetwork(ctx context.Context, prefix netip.Prefix) error {
+	return s.client.Del(ctx, NetBanPrefix+prefix.Masked().String()).Err()
+}
+
+// IsIPBanned checks if an address is covered by a network ban. It returns
+// the same values as IsBanned, for the most specific matching ban.
+func (s *Store) IsIPBanned(ctx context.Context, ip netip.Addr) (bool, int, string, error) {
+	ip = ip.Unmap()
+	if !ip.IsValid() {
+		return false, 0, "", nil
+	}
+
+	members, err := s.client.SMembers(ctx, NetLengthsKey).Result()
+	if err != nil {
+		return false, 0, "", err
+	}
+	family := "6/"
+	if ip.Is4() {
+		family = "4/"
+	}
+	var lengths []int
+	for _, m := range members {
+		if bits, ok := strings.CutPrefix(m, family); ok {
+			if n, err := strconv.Atoi(bits); err == nil && n <= ip.BitLen() {
+				lengths = append(lengths, n)
+			}
+		}
+	}
+	if len(lengths) == 0 {
+		return false, 0, "", nil
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(lengths)))
+
+	keys := make([]string, len(lengths))
+	for i, n := range lengths {
+		p, _ := ip.Prefix(n)
+		keys[i] = NetBanPrefix + p.String()
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return false, 0, "", err
+	}
+
+	for i, v := range values {
+		reason, ok := v.(string)
+		if !ok {
+			continue
+		}
+		remaining := 0
+		if ttl, err := s.client.TTL(ctx, keys[i]).Result(); err == nil && ttl > 0 {
+			remaining = int(ttl.Seconds())
+		}
+		return true, remaining, reason, nil
+	}
+	return false, 0, "", nil
+}
+
+// Entry is one ban in an exported or imported ban list. Exactly one of
+// Fingerprint and Network is set. A zero ExpiresAt means the ban does not
+// expire.
+type Entry struct {
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Network     string    `json:"network,omitempty"`
+	Reason      string    `json:"reason"`
+	ExpiresAt   time.Time `json:"expires_at,omitzero"`
+}
+
+// ImportResult summarises an Import.
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"` // already expired at import time
+}
+
+// Export returns all active fingerprint and network bans. Expiry is
+// reported as an absolute time so the list can be imported elsewhere later.
+func (s *Store) Export(ctx context.Context) ([]Entry, error) {
+	now := time.Now()
+	entries := []Entry{}
+	for _, prefix := range []string{BanPrefix, NetBanPrefix} {
+		var cursor uint64
+		for {
+			keys, next, err := s.client.Scan(ctx, cursor, prefix+"*", 500).Result()
+			if err != nil {
+				return nil, fmt.Errorf("ban: export scan: %w", err)
+			}
+			batch, err := s.exportKeys(ctx, keys, prefix, now)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, batch...)
+			if cursor = next; cursor == 0 {
+				break
+			}
+		}
+	}
+	return entries, nil
+}
+
+func (s *Store) exportKeys(ctx context.Context, keys []string, prefix string, now time.Time) ([]Entry, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	pipe := s.client.Pipeline()
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		gets[i] = pipe.Get(ctx, key)
+		ttls[i] = pipe.PTTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("ban: export read: %w", err)
+	}
+
+	var entries []Entry
+	for i, key := range keys {
+		reason, err := gets[i].Result()
+		if err != nil {
+			continue // expired since the scan
+		}
+		e := Entry{Reason: reason}
+		if prefix == NetBanPrefix {
+			e.Network = strings.TrimPrefix(key, NetBanPrefix)
+		} else {
+			e.Fingerprint = strings.TrimPrefix(key, BanPrefix)
+		}
+		if ttl := ttls[i].Val(); ttl > 0 {
+			e.ExpiresAt = now.Add(ttl).Truncate(time.Second)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Import applies a ban list. Every entry is validated before any is written,
+// so a malformed list changes nothing. Entries that have already expired are
+// skipped; existing bans on the same fingerprint or network are replaced.
+func (s *Store) Import(ctx context.Context, entries []Entry) (ImportResult, error) {
+	prefixes := make([]netip.Prefix, len(entries))
+	for i, e := range entries {
+		switch {
+		case (e.Fingerprint == "") == (e.Network == ""):
+			return ImportResult{}, fmt.Errorf("ban: entry %d: exactly one of fingerprint and network is required", i)
+		case e.Network != "":
+			p, err := ParseNetwork(e.Network)
+			if err != nil {
+				return ImportResult{}, fmt.Errorf("ban: entry %d: %w", i, err)
+			}
+			prefixes[i] = p
+		}
+	}
+
+	now := time.Now()
+	var result ImportResult
+	pipe := s.client.TxPipeline()
+	for i, e := range entries {
+		var duration time.Duration
+		if !e.ExpiresAt.IsZero() {
+			if duration = e.ExpiresAt.Sub(now); duration < time.Second {
+				result.Skipped++
+				continue
+			}
+		}
+		if e.Network != "" {
+			pipe.SAdd(ctx, NetLengthsKey, lengthMember(prefixes[i]))
+			pipe.Set(ctx, NetBanPrefix+prefixes[i].String(), e.Reason, duration)
+		} else {
+			pipe.Set(ctx, BanPrefix+e.Fingerprint, e.Reason, duration)
+		}
+		result.Imported++
+	}
+	if result.Imported == 0 {
+		return result, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return ImportResult{}, fmt.Errorf("ban: import: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package ban
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"203.0.113.7", "203.0.113.7/32"},
+		{"203.0.113.7/24", "203.0.113.0/24"},
+		{"::ffff:203.0.113.7", "203.0.113.7/32"},
+		{"::ffff:203.0.113.7/120", "203.0.113.0/24"},
+		{"2001:db8::1/64", "2001:db8::/64"},
+	}
+	for _, tt := range tests {
+		got, err := ParseNetwork(tt.in)
+		if err != nil {
+			t.Errorf("ParseNetwork(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseNetwork(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "not-an-ip", "10.0.0.0/33"} {
+		if _, err := ParseNetwork(bad); err == nil {
+			t.Errorf("ParseNetwork(%q) expected error", bad)
+		}
+	}
+}
+
+func TestIsIPBanned_LongestPrefixWins(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	wide, _ := ParseNetwork("198.51.100.0/24")
+	narrow, _ := ParseNetwork("198.51.100.128/25")
+	if err := store.BanNetwork(ctx, wide, 0, "range"); err != nil {
+		t.Fatalf("BanNetwork(wide) error: %v", err)
+	}
+	if err := store.BanNetwork(ctx, narrow, time.Minute, "subrange"); err != nil {
+		t.Fatalf("BanNetwork(narrow) error: %v", err)
+	}
+
+	tests := []struct {
+		ip     string
+		banned bool
+		reason string
+	}{
+		{"198.51.100.200", true, "subrange"},
+		{"198.51.100.5", true, "range"},
+		{"::ffff:198.51.100.5", true, "range"},
+		{"198.51.101.5", false, ""},
+		{"2001:db8::1", false, ""},
+	}
+	for _, tt := range tests {
+		banned, _, reason, err := store.IsIPBanned(ctx, netip.MustParseAddr(tt.ip))
+		if err != nil {
+			t.Fatalf("IsIPBanned(%s) error: %v", tt.ip, err)
+		}
+		if banned != tt.banned || reason != tt.reason {
+			t.Errorf("IsIPBanned(%s) = %v %q, want %v %q", tt.ip, banned, reason, tt.banned, tt.reason)
+		}
+	}
+
+	if err := store.UnbanNetwork(ctx, narrow); err != nil {
+		t.Fatalf("UnbanNetwork() error: %v", err)
+	}
+	if _, _, reason, _ := store.IsIPBanned(ctx, netip.MustParseAddr("198.51.100.200")); reason != "range" {
+		t.Errorf("after unban reason = %q, want covering range", reason)
+	}
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	if err := store.Ban(ctx, "fp_export", time.Hour, "spam"); err != nil {
+		t.Fatalf("Ban() error: %v", err)
+	}
+	prefix, _ := ParseNetwork("192.0.2.0/24")
+	if err := store.BanNetwork(ctx, prefix, 0, "abuse"); err != nil {
+		t.Fatalf("BanNetwork() error: %v", err)
+	}
+
+	entries, err := store.Export(ctx)
+	if err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Export() returned %d entries, want 2: %+v", len(entries), entries)
+	}
+
+	store.client.FlushDB(ctx)
+	entries = append(entries, Entry{Fingerprint: "fp_expired", Reason: "old", ExpiresAt: time.Now().Add(-time.Hour)})
+	result, err := store.Import(ctx, entries)
+	if err != nil {
+		t.Fatalf("Import() error: %v", err)
+	}
+	if result.Imported != 2 || result.Skipped != 1 {
+		t.Errorf("Import() = %+v, want 2 imported, 1 skipped", result)
+	}
+
+	if banned, remaining, _, _ := store.IsBanned(ctx, "fp_export"); !banned || remaining <= 0 {
+		t.Errorf("fingerprint ban not restored with expiry: banned=%v remaining=%d", banned, remaining)
+	}
+	if banned, remaining, _, _ := store.IsIPBanned(ctx, netip.MustParseAddr("192.0.2.9")); !banned || remaining != 0 {
+		t.Errorf("network ban not restored as permanent: banned=%v remaining=%d", banned, remaining)
+	}
+}
+
+func TestImport_RejectsInvalidList(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	_, err := store.Import(ctx, []Entry{
+		{Fingerprint: "fp_valid", Reason: "spam"},
+		{Network: "not-a-network"},
+	})
+	if err == nil {
+		t.Fatal("Import() expected error for invalid network")
+	}
+	if banned, _, _, _ := store.IsBanned(ctx, "fp_valid"); banned {
+		t.Error("invalid list partially applied")
+	}
+}
@@ -0,0 +1,115 @@
+package ban
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/session"
+)
+
+// ErrNotReportable is returned by FileReport when the chat does not exist or
+// the reporter is not one of its participants.
+var ErrNotReportable = errors.New("ban: chat not found or reporter not a participant")
+
+// ReportResult is the outcome of FileReport.
+type ReportResult struct {
+	PartnerID           string // session reported; empty if the chat had no partner
+	ReporterFingerprint string // empty if the reporter never sent one
+	PartnerFingerprint  string // empty if unknown, in which case nothing was counted
+	Tier                string // matching tier of the chat, for tier stats
+
+	Banned   bool          // the report pushed the partner over AutoBanThreshold
+	Duration time.Duration // ban applied when Banned
+}
+
+// FileReport is the Redis side of an abuse report: it resolves the
+// reporter's partner in chatID and both fingerprints, adds weight to the
+// partner's report counter and, once the counter reaches AutoBanThreshold,
+// bans the partner like ReportAndCheckWeighted. The lookups take two round
+// trips; the counting and ban are one script.
+//
+// Nothing is counted when the partner's fingerprint is unknown; the result
+// then has an empty PartnerFingerprint.
+func (s *Store) FileReport(ctx context.Context, chatID, reporterID string, weight int64) (*ReportResult, error) {
+	c, err := s.client.HMGet(ctx, chat.ChatPrefix+chatID, "user_a", "user_b", "tier").Result()
+	if err != nil {
+		return nil, fmt.Errorf("ban: file report: %w", err)
+	}
+	userA, userB := str(c[0]), str(c[1])
+	result := &ReportResult{Tier: str(c[2])}
+	switch {
+	case userA == "" && userB == "":
+		return nil, ErrNotReportable
+	case reporterID == userA:
+		result.PartnerID = userB
+	case reporterID == userB:
+		result.PartnerID = userA
+	default:
+		return nil, ErrNotReportable
+	}
+	if result.PartnerID == "" {
+		return result, nil
+	}
+
+	pipe := s.client.Pipeline()
+	reporterFP := pipe.HGet(ctx, session.SessionPrefix+reporterID, "fingerprint")
+	partnerFP := pipe.HGet(ctx, session.SessionPrefix+result.PartnerID, "fingerprint")
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("ban: file report: %w", err)
+	}
+	result.ReporterFingerprint, result.PartnerFingerprint = reporterFP.Val(), partnerFP.Val()
+	if result.PartnerFingerprint == "" {
+		return result, nil
+	}
+
+	args := []interface{}{weight, int(ReportsTTL.Seconds()), AutoBanThreshold}
+	for _, d := range escalation {
+		args = append(args, d.Milliseconds())
+	}
+	ms, err := countReportScript.Run(ctx, s.client,
+		[]string{ReportsPrefix + result.PartnerFingerprint, BanPrefix + result.PartnerFingerprint},
+		args...,
+	).Int64()
+	if err != nil {
+		return nil, fmt.Errorf("ban: file report: %w", err)
+	}
+	if ms > 0 {
+		result.Banned = true
+		result.Duration = time.Duration(ms) * time.Millisecond
+	}
+	return result, nil
+}
+
+// str converts a reply element that may be nil to a string.
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+var countReportScript = redis.NewScript(countReportLua)
+
+// countReportLua increments the report counter KEYS[1] by ARGV[1], giving
+// it a TTL of ARGV[2] seconds when new. At ARGV[3] or more it sets the ban
+// key KEYS[2] for the escalation step of the count: ARGV[4] milliseconds
+// for the first offense, ARGV[5] for the second and so on, the last step
+// applying to every further offense. It returns the ban duration in
+// milliseconds, or 0 if there is no ban.
+const countReportLua = `
+local weight = tonumber(ARGV[1])
+local count = redis.call('INCRBY', KEYS[1], weight)
+if count == weight then
+    redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+if count < tonumber(ARGV[3]) then
+    return 0
+end
+local step = math.min(math.max(count, 1), #ARGV - 3)
+local ms = tonumber(ARGV[3 + step])
+redis.call('SET', KEYS[2], 'multiple_reports', 'PX', ms)
+return ms
+`
@@ -0,0 +1,88 @@
+package ban
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/session"
+)
+
+// seedReportChat stores a chat between sessions a and b with the given
+// fingerprints; an empty fingerprint leaves the session without one.
+func seedReportChat(t *testing.T, store *Store, chatID, fpA, fpB string) {
+	t.Helper()
+	ctx := context.Background()
+	rdb := store.client
+	if err := rdb.HSet(ctx, chat.ChatPrefix+chatID, "user_a", "a", "user_b", "b", "tier", "exact").Err(); err != nil {
+		t.Fatalf("seed chat: %v", err)
+	}
+	for sid, fp := range map[string]string{"a": fpA, "b": fpB} {
+		if err := rdb.HSet(ctx, session.SessionPrefix+sid, "id", sid, "fingerprint", fp).Err(); err != nil {
+			t.Fatalf("seed session: %v", err)
+		}
+	}
+}
+
+func TestFileReport_CountsAndBans(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	seedReportChat(t, store, "c1", "fp_a", "fp_b")
+
+	res, err := store.FileReport(ctx, "c1", "a", 1)
+	if err != nil {
+		t.Fatalf("FileReport: %v", err)
+	}
+	if res.PartnerID != "b" || res.ReporterFingerprint != "fp_a" || res.PartnerFingerprint != "fp_b" || res.Tier != "exact" {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if res.Banned {
+		t.Fatal("banned after one report")
+	}
+
+	res, err = store.FileReport(ctx, "c1", "a", AutoBanThreshold-1)
+	if err != nil {
+		t.Fatalf("FileReport: %v", err)
+	}
+	if !res.Banned || res.Duration != Ban24Hour {
+		t.Fatalf("expected a 24h ban at the threshold, got %+v", res)
+	}
+	banned, _, reason, err := store.IsBanned(ctx, "fp_b")
+	if err != nil || !banned || reason != "multiple_reports" {
+		t.Errorf("IsBanned(fp_b) = %v, %q, %v", banned, reason, err)
+	}
+	if n, _ := store.GetOffenseCount(ctx, "fp_b"); n != AutoBanThreshold {
+		t.Errorf("offense count = %d, want %d", n, AutoBanThreshold)
+	}
+}
+
+func TestFileReport_NotReportable(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	seedReportChat(t, store, "c1", "fp_a", "fp_b")
+
+	if _, err := store.FileReport(ctx, "missing", "a", 1); !errors.Is(err, ErrNotReportable) {
+		t.Errorf("missing chat: err = %v, want ErrNotReportable", err)
+	}
+	if _, err := store.FileReport(ctx, "c1", "stranger", 1); !errors.Is(err, ErrNotReportable) {
+		t.Errorf("stranger: err = %v, want ErrNotReportable", err)
+	}
+}
+
+func TestFileReport_UnknownPartnerFingerprint(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	seedReportChat(t, store, "c1", "fp_a", "")
+
+	res, err := store.FileReport(ctx, "c1", "a", AutoBanThreshold)
+	if err != nil {
+		t.Fatalf("FileReport: %v", err)
+	}
+	if res.PartnerID != "b" || res.PartnerFingerprint != "" || res.Banned {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if n, _ := store.GetOffenseCount(ctx, ""); n != 0 {
+		t.Errorf("counted a report against an empty fingerprint: %d", n)
+	}
+}
@@ -23,6 +23,10 @@ const (
 	// (used by the escalating ban system in ABUSE-6).
 	ReportsPrefix = "reports:"
 
+	// AppealPrefix is the Redis key prefix marking a ban as appealed. The
+	// marker expires with the ban, so each ban can be appealed once.
+	AppealPrefix = "appeal:"
+
 	// Escalating ban durations (ABUSE-6).
 	Ban15Min = 15 * time.Minute // 1st offense
 	Ban1Hour = 1 * time.Hour   // 2nd offense
@@ -86,26 +90,24 @@ func (s *Store) Ban(ctx context.Context, fingerprint string, duration time.Durat
 	return s.client.Set(ctx, key, reason, duration).Err()
 }
 
-// Unban removes a ban from a fingerprint immediately.
+// Unban removes a ban from a fingerprint immediately, along with its appeal
+// marker so a later ban can be appealed again.
 func (s *Store) Unban(ctx context.Context, fingerprint string) error {
-	key := BanPrefix + fingerprint
-	return s.client.Del(ctx, key).Err()
+	return s.client.Del(ctx, BanPrefix+fingerprint, AppealPrefix+fingerprint).Err()
 }
 
 // ---------------------------------------------------------------------------
 // Escalating ban system (ABUSE-6)
 // ---------------------------------------------------------------------------
 
+// escalation lists the ban durations by offense count, the last applying to
+// every further offense. FileReport hands it to its script, so the steps are
+// only set here.
+var escalation = []time.Duration{Ban15Min, Ban1Hour, Ban24Hour}
+
 // escalationDuration returns the ban duration for a given offense count.
 func escalationDuration(offenseCount int) time.Duration {
-	switch {
-	case offenseCount <= 1:
-		return Ban15Min
-	case offenseCount == 2:
-		return Ban1Hour
-	default:
-		return Ban24Hour
-	}
+	return escalation[min(max(offenseCount, 1), len(escalation))-1]
 }
 
 // GetOffenseCount returns the current offense/report counter for a fingerprint.
@@ -163,16 +165,22 @@ func (s *Store) Escalate(ctx context.Context, fingerprint string, reason string)
 // If the threshold is met or exceeded, Escalate is called to apply a ban with
 // escalating duration. Returns (banned, duration, error).
 func (s *Store) ReportAndCheck(ctx context.Context, fingerprint string, reason string) (bool, time.Duration, error) {
+	return s.ReportAndCheckWeighted(ctx, fingerprint, 1)
+}
+
+// ReportAndCheckWeighted is ReportAndCheck for a report that counts weight
+// times toward the threshold, so severe report categories ban sooner.
+func (s *Store) ReportAndCheckWeighted(ctx context.Context, fingerprint string, weight int64) (bool, time.Duration, error) {
 	key := ReportsPrefix + fingerprint
 
 	// Atomically increment the report counter.
-	count, err := s.client.Incr(ctx, key).Result()
+	count, err := s.client.IncrBy(ctx, key, weight).Result()
 	if err != nil {
 		return false, 0, fmt.Errorf("ban: report incr: %w", err)
 	}
 
 	// Set TTL only on first increment so the 24h window doesn't slide.
-	if count == 1 {
+	if count == weight {
 		if err := s.client.Expire(ctx, key, ReportsTTL).Err(); err != nil {
 			return false, 0, fmt.Errorf("ban: report expire: %w", err)
 		}
@@ -2,39 +2,18 @@ package ban
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/testutil"
 )
 
-// newTestStore creates a Store connected to a local Redis instance and flushes
-// all ban and report keys before returning.  Tests that call this helper
-// require a running Redis on localhost:6379.
+// newTestStore creates a Store on the package's test Redis with an empty
+// database. Tests are skipped if Redis is unavailable.
 func newTestStore(t *testing.T) *Store {
 	t.Helper()
-	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
-	ctx := context.Background()
-	if err := client.Ping(ctx).Err(); err != nil {
-		t.Skipf("redis not available: %v", err)
-	}
-	// Clean up any leftover test keys (both ban: and reports: prefixes).
-	for _, prefix := range []string{BanPrefix + "test_*", ReportsPrefix + "test_*"} {
-		iter := client.Scan(ctx, 0, prefix, 100).Iterator()
-		for iter.Next(ctx) {
-			client.Del(ctx, iter.Val())
-		}
-	}
-	t.Cleanup(func() {
-		for _, prefix := range []string{BanPrefix + "test_*", ReportsPrefix + "test_*"} {
-			iter := client.Scan(ctx, 0, prefix, 100).Iterator()
-			for iter.Next(ctx) {
-				client.Del(ctx, iter.Val())
-			}
-		}
-		client.Close()
-	})
-	return NewStore(client)
+	return NewStore(testutil.Redis(t))
 }
 
 func TestIsBanned_NotBanned(t *testing.T) {
@@ -332,6 +311,33 @@ func TestReportAndCheck_AutoBanAt3Reports(t *testing.T) {
 	}
 }
 
+func TestReportAndCheckWeighted_HeavyReportBansSooner(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	fp := "test_report_weighted"
+
+	// A weight-2 report stays below the threshold on its own.
+	banned, _, err := store.ReportAndCheckWeighted(ctx, fp, 2)
+	if err != nil {
+		t.Fatalf("ReportAndCheckWeighted() error: %v", err)
+	}
+	if banned {
+		t.Fatal("expected no ban after one weight-2 report")
+	}
+	if ttl := store.client.TTL(ctx, ReportsPrefix+fp).Val(); ttl <= 0 {
+		t.Errorf("expected report counter TTL to be set, got %v", ttl)
+	}
+
+	// One more ordinary report reaches it.
+	banned, _, err = store.ReportAndCheckWeighted(ctx, fp, 1)
+	if err != nil {
+		t.Fatalf("ReportAndCheckWeighted() error: %v", err)
+	}
+	if !banned {
+		t.Fatal("expected ban once the weighted count reached the threshold")
+	}
+}
+
 func TestReportAndCheck_SubsequentReportsStillBan(t *testing.T) {
 	store := newTestStore(t)
 	ctx := context.Background()
@@ -393,3 +399,34 @@ func TestGetOffenseCount_AfterEscalate(t *testing.T) {
 		t.Errorf("expected count=3, got %d", count)
 	}
 }
+
+func TestClaimAppeal_OncePerBan(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	fp := "test_appeal"
+
+	if _, err := store.ClaimAppeal(ctx, fp); !errors.Is(err, ErrNotBanned) {
+		t.Fatalf("ClaimAppeal without a ban: err = %v, want ErrNotBanned", err)
+	}
+
+	if err := store.Ban(ctx, fp, time.Minute, "spam"); err != nil {
+		t.Fatalf("Ban() error: %v", err)
+	}
+	if ok, err := store.ClaimAppeal(ctx, fp); err != nil || !ok {
+		t.Fatalf("first ClaimAppeal = %v, %v; want true", ok, err)
+	}
+	if ok, err := store.ClaimAppeal(ctx, fp); err != nil || ok {
+		t.Fatalf("second ClaimAppeal = %v, %v; want false", ok, err)
+	}
+
+	// Lifting the ban clears the marker, so the next ban can be appealed.
+	if err := store.Unban(ctx, fp); err != nil {
+		t.Fatalf("Unban() error: %v", err)
+	}
+	if err := store.Ban(ctx, fp, time.Minute, "spam"); err != nil {
+		t.Fatalf("Ban() error: %v", err)
+	}
+	if ok, err := store.ClaimAppeal(ctx, fp); err != nil || !ok {
+		t.Fatalf("ClaimAppeal after a new ban = %v, %v; want true", ok, err)
+	}
+}
@@ -0,0 +1,105 @@
+// Package block provides personal block lists: pairs of fingerprints that the
+// matcher must never pair again. Each fingerprint owns a sorted set of the
+// fingerprints it has blocked, scored by the Unix time the block expires:
+//
+//	Key:    block:<fingerprint>
+//	Member: <blocked fingerprint>
+//	Score:  expiry (unix seconds)
+//
+// A block is mutual: IsBlocked checks both directions, so the blocked user is
+// not matched with the blocker either.
+package block
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// BlockPrefix is the Redis key prefix for block lists.
+	BlockPrefix = "block:"
+
+	// DefaultTTL is how long a block lasts.
+	DefaultTTL = 30 * 24 * time.Hour
+
+	// MaxBlocks caps the size of a single block list. When exceeded, the
+	// blocks closest to expiry are dropped first.
+	MaxBlocks = 500
+)
+
+var (
+	// ErrMissingFingerprint is returned when either side of a block has no
+	// fingerprint.
+	ErrMissingFingerprint = errors.New("block: missing fingerprint")
+
+	// ErrSelfBlock is returned when a fingerprint tries to block itself.
+	ErrSelfBlock = errors.New("block: cannot block own fingerprint")
+)
+
+// Store manages block lists in Redis.
+type Store struct {
+	client *redis.Client
+}
+
+// NewStore creates a new block store using the provided Redis client.
+func NewStore(client *redis.Client) *Store {
+	return &Store{client: client}
+}
+
+// Add records that fingerprint blocked blockedFingerprint for ttl. Blocking
+// the same fingerprint again extends the block. Expired entries are pruned
+// and the list is trimmed to MaxBlocks.
+func (s *Store) Add(ctx context.Context, fingerprint, blockedFingerprint string, ttl time.Duration) error {
+	if fingerprint == "" || blockedFingerprint == "" {
+		return ErrMissingFingerprint
+	}
+	if fingerprint == blockedFingerprint {
+		return ErrSelfBlock
+	}
+
+	key := BlockPrefix + fingerprint
+	now := time.Now()
+	expiry := now.Add(ttl).Unix()
+
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(expiry), Member: blockedFingerprint})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Unix(), 10))
+	pipe.ZRemRangeByRank(ctx, key, 0, -(MaxBlocks + 1))
+	pipe.ExpireGT(ctx, key, ttl)
+	pipe.ExpireNX(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IsBlocked reports whether either fingerprint has an unexpired block on the
+// other. Empty fingerprints are never blocked. Redis errors are returned so
+// callers can decide how to handle them (the matcher fails open).
+func (s *Store) IsBlocked(ctx context.Context, a, b string) (bool, error) {
+	if a == "" || b == "" || a == b {
+		return false, nil
+	}
+
+	pipe := s.client.Pipeline()
+	ab := pipe.ZScore(ctx, BlockPrefix+a, b)
+	ba := pipe.ZScore(ctx, BlockPrefix+b, a)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+
+	now := float64(time.Now().Unix())
+	for _, cmd := range []*redis.FloatCmd{ab, ba} {
+		if expiry, err := cmd.Result(); err == nil && expiry > now {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Remove lifts a block placed by fingerprint on blockedFingerprint.
+func (s *Store) Remove(ctx context.Context, fingerprint, blockedFingerprint string) error {
+	return s.client.ZRem(ctx, BlockPrefix+fingerprint, blockedFingerprint).Err()
+}
@@ -0,0 +1,102 @@
+package block
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// newTestStore creates a Store on the package's test Redis.
+func newTestStore(t *testing.T) (*Store, *redis.Client) {
+	t.Helper()
+	client := testutil.Redis(t)
+	return NewStore(client), client
+}
+
+func TestAdd_IsBlockedBothDirections(t *testing.T) {
+	s, _ := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.Add(ctx, "test_alice", "test_bob", time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, pair := range [][2]string{{"test_alice", "test_bob"}, {"test_bob", "test_alice"}} {
+		blocked, err := s.IsBlocked(ctx, pair[0], pair[1])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !blocked {
+			t.Errorf("expected %s/%s to be blocked", pair[0], pair[1])
+		}
+	}
+
+	blocked, err := s.IsBlocked(ctx, "test_alice", "test_carol")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blocked {
+		t.Error("expected unrelated fingerprint not to be blocked")
+	}
+}
+
+func TestAdd_SetsKeyTTL(t *testing.T) {
+	s, client := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.Add(ctx, "test_alice", "test_bob", DefaultTTL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ttl := client.TTL(ctx, BlockPrefix+"test_alice").Val()
+	if ttl <= DefaultTTL-time.Minute || ttl > DefaultTTL {
+		t.Errorf("expected TTL close to %s, got %s", DefaultTTL, ttl)
+	}
+}
+
+func TestIsBlocked_ExpiredEntryIgnored(t *testing.T) {
+	s, client := newTestStore(t)
+	ctx := context.Background()
+
+	past := float64(time.Now().Add(-time.Minute).Unix())
+	client.ZAdd(ctx, BlockPrefix+"test_alice", redis.Z{Score: past, Member: "test_bob"})
+
+	blocked, err := s.IsBlocked(ctx, "test_alice", "test_bob")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blocked {
+		t.Error("expected expired block to be ignored")
+	}
+}
+
+func TestAdd_Invalid(t *testing.T) {
+	s, _ := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.Add(ctx, "", "test_bob", time.Hour); err != ErrMissingFingerprint {
+		t.Errorf("expected ErrMissingFingerprint, got %v", err)
+	}
+	if err := s.Add(ctx, "test_alice", "test_alice", time.Hour); err != ErrSelfBlock {
+		t.Errorf("expected ErrSelfBlock, got %v", err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	s, _ := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.Add(ctx, "test_alice", "test_bob", time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Remove(ctx, "test_alice", "test_bob"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	blocked, _ := s.IsBlocked(ctx, "test_alice", "test_bob")
+	if blocked {
+		t.Error("expected block to be lifted")
+	}
+}
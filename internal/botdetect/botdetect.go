@@ -0,0 +1,282 @@
+// Package botdetect flags fingerprints that probably belong to bots from how
+// they send chat messages, across every chat they are in. Each message adds
+// to sliding windows in Redis, and three signals are derived from them:
+//
+//   - regular_timing: the gaps between messages barely vary, as with a
+//     script sending on a timer
+//   - uniform_entropy: every message has nearly the same character entropy,
+//     as with a template filled in with small changes
+//   - repeated_message: the same text was sent in several different chats,
+//     as with advertising
+//
+// The windows, per fingerprint:
+//
+//	Key:   botdetect:msgs:<fp>         (sorted set)
+//	Score: send time, Unix milliseconds
+//	Value: "<ms>:<entropy>"
+//
+//	Key:   botdetect:repeat:<fp>:<hash> (set of chat IDs)
+//	Key:   botdetect:flagged:<fp>       (comma-separated signals)
+//
+// All keys expire with the window, so a fingerprint that goes quiet leaves
+// nothing behind.
+package botdetect
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyMsgsPrefix    = "botdetect:msgs:"
+	keyRepeatPrefix  = "botdetect:repeat:"
+	keyFlaggedPrefix = "botdetect:flagged:"
+
+	// maxSamples caps the messages kept per fingerprint.
+	maxSamples = 50
+)
+
+// Signals a Verdict may report.
+const (
+	SignalRegularTiming   = "regular_timing"
+	SignalUniformEntropy  = "uniform_entropy"
+	SignalRepeatedMessage = "repeated_message"
+)
+
+// signalWeight is how much each signal adds to a Verdict's score. Repeating
+// a message across chats is flagged on its own; the statistical signals only
+// together.
+var signalWeight = map[string]int{
+	SignalRegularTiming:   1,
+	SignalUniformEntropy:  1,
+	SignalRepeatedMessage: 2,
+}
+
+// Config holds the detection thresholds.
+type Config struct {
+	// Window is how far back messages are considered.
+	Window time.Duration
+
+	// MinSamples is how many messages in the window the timing and entropy
+	// signals need.
+	MinSamples int
+
+	// MaxTimingCV is the coefficient of variation (standard deviation over
+	// mean) of the gaps between messages at or below which timing counts
+	// as regular.
+	MaxTimingCV float64
+
+	// MaxEntropySpread is the standard deviation of message entropy, in
+	// bits per character, at or below which it counts as uniform.
+	MaxEntropySpread float64
+
+	// RepeatChats is in how many different chats the same message must be
+	// sent to count as repeated. Messages shorter than RepeatMinLength
+	// characters ("hi", "lol") are not tracked.
+	RepeatChats     int
+	RepeatMinLength int
+
+	// Threshold is the score at which a fingerprint is a probable bot.
+	Threshold int
+
+	// FlagTTL is how long a fingerprint stays flagged.
+	FlagTTL time.Duration
+}
+
+// DefaultConfig returns the built-in thresholds.
+func DefaultConfig() Config {
+	return Config{
+		Window:           10 * time.Minute,
+		MinSamples:       8,
+		MaxTimingCV:      0.1,
+		MaxEntropySpread: 0.05,
+		RepeatChats:      3,
+		RepeatMinLength:  12,
+		Threshold:        2,
+		FlagTTL:          24 * time.Hour,
+	}
+}
+
+// Verdict is the outcome of observing a message.
+type Verdict struct {
+	Bot     bool
+	Score   int
+	Signals []string
+}
+
+// Detector tracks message behaviour per fingerprint in Redis.
+type Detector struct {
+	rdb    *redis.Client
+	config Config
+}
+
+// NewDetector creates a Detector with the given thresholds.
+func NewDetector(rdb *redis.Client, config Config) *Detector {
+	return &Detector{rdb: rdb, config: config}
+}
+
+// Observe records a message sent by fingerprint in chatID at the given time
+// and returns the verdict on the fingerprint's recent messages. Messages of
+// sessions without a fingerprint are not tracked.
+func (d *Detector) Observe(ctx context.Context, fingerprint, chatID, text string, at time.Time) (Verdict, error) {
+	if fingerprint == "" {
+		return Verdict{}, nil
+	}
+	now := at.UnixMilli()
+	cutoff := now - d.config.Window.Milliseconds()
+	msgsKey := keyMsgsPrefix + fingerprint
+
+	pipe := d.rdb.TxPipeline()
+	pipe.ZAdd(ctx, msgsKey, redis.Z{
+		Score:  float64(now),
+		Member: fmt.Sprintf("%d:%.4f", now, Entropy(text)),
+	})
+	pipe.ZRemRangeByScore(ctx, msgsKey, "-inf", strconv.FormatInt(cutoff, 10))
+	pipe.ZRemRangeByRank(ctx, msgsKey, 0, -maxSamples-1)
+	pipe.Expire(ctx, msgsKey, d.config.Window)
+	samples := pipe.ZRange(ctx, msgsKey, 0, -1)
+
+	var chats *redis.IntCmd
+	if utf8.RuneCountInString(text) >= d.config.RepeatMinLength {
+		repeatKey := keyRepeatPrefix + fingerprint + ":" + textHash(text)
+		pipe.SAdd(ctx, repeatKey, chatID)
+		pipe.Expire(ctx, repeatKey, d.config.Window)
+		chats = pipe.SCard(ctx, repeatKey)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Verdict{}, fmt.Errorf("botdetect: observe: %w", err)
+	}
+
+	var signals []string
+	times, entropies := parseSamples(samples.Val())
+	if len(times) >= d.config.MinSamples {
+		if cv, ok := gapVariation(times); ok && cv <= d.config.MaxTimingCV {
+			signals = append(signals, SignalRegularTiming)
+		}
+		if stddev(entropies) <= d.config.MaxEntropySpread {
+			signals = append(signals, SignalUniformEntropy)
+		}
+	}
+	if chats != nil && chats.Val() >= int64(d.config.RepeatChats) {
+		signals = append(signals, SignalRepeatedMessage)
+	}
+
+	v := Verdict{Signals: signals}
+	for _, s := range signals {
+		v.Score += signalWeight[s]
+	}
+	v.Bot = v.Score >= d.config.Threshold
+	return v, nil
+}
+
+// Flag marks fingerprint as a probable bot for FlagTTL with the signals that
+// gave it away. It reports whether the fingerprint was not flagged already,
+// so the caller acts once per flag.
+func (d *Detector) Flag(ctx context.Context, fingerprint string, signals []string) (bool, error) {
+	ok, err := d.rdb.SetNX(ctx, keyFlaggedPrefix+fingerprint, strings.Join(signals, ","), d.config.FlagTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("botdetect: flag: %w", err)
+	}
+	return ok, nil
+}
+
+// Flagged returns the signals fingerprint was flagged for, or nil if it is
+// not flagged.
+func (d *Detector) Flagged(ctx context.Context, fingerprint string) ([]string, error) {
+	v, err := d.rdb.Get(ctx, keyFlaggedPrefix+fingerprint).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("botdetect: flagged: %w", err)
+	}
+	return strings.Split(v, ","), nil
+}
+
+// Entropy returns the Shannon entropy of text in bits per character.
+func Entropy(text string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range text {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// textHash identifies a message regardless of case and spacing.
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(strings.ToLower(text)), " ")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// parseSamples splits "<ms>:<entropy>" members into send times and
+// entropies, skipping malformed ones.
+func parseSamples(members []string) (times, entropies []float64) {
+	for _, m := range members {
+		ms, e, ok := strings.Cut(m, ":")
+		if !ok {
+			continue
+		}
+		t, err1 := strconv.ParseFloat(ms, 64)
+		h, err2 := strconv.ParseFloat(e, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		times = append(times, t)
+		entropies = append(entropies, h)
+	}
+	return times, entropies
+}
+
+// gapVariation returns the coefficient of variation of the gaps between
+// consecutive times, which are in ascending order. It is not ok when the
+// mean gap is zero.
+func gapVariation(times []float64) (float64, bool) {
+	gaps := make([]float64, 0, len(times)-1)
+	for i := 1; i < len(times); i++ {
+		gaps = append(gaps, times[i]-times[i-1])
+	}
+	m := mean(gaps)
+	if m <= 0 {
+		return 0, false
+	}
+	return stddev(gaps) / m, true
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+func stddev(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	m := mean(xs)
+	var sum float64
+	for _, x := range xs {
+		sum += (x - m) * (x - m)
+	}
+	return math.Sqrt(sum / float64(len(xs)))
+}
@@ -0,0 +1,159 @@
+package botdetect
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m, testutil.ServiceRedis)
+}
+
+func TestObserve_HumanIsNotFlagged(t *testing.T) {
+	d := NewDetector(testutil.Redis(t), DefaultConfig())
+	ctx := context.Background()
+
+	texts := []string{"hey", "how are you?", "lol same", "i've been listening to a lot of jazz lately", "you?", "nice!", "what city are you in", "ok", "haha that's wild"}
+	gaps := []time.Duration{0, 4 * time.Second, 11 * time.Second, 2 * time.Second, 30 * time.Second, 6 * time.Second, 15 * time.Second, 3 * time.Second, 9 * time.Second}
+	at := time.Now()
+	for i, text := range texts {
+		at = at.Add(gaps[i])
+		v, err := d.Observe(ctx, "fp-human", "chat-1", text, at)
+		if err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+		if v.Bot || len(v.Signals) > 0 {
+			t.Fatalf("message %d: got %+v, want no signals", i, v)
+		}
+	}
+}
+
+func TestObserve_RegularTemplatedBot(t *testing.T) {
+	d := NewDetector(testutil.Redis(t), DefaultConfig())
+	ctx := context.Background()
+
+	at := time.Now()
+	var v Verdict
+	for i := 0; i < 8; i++ {
+		at = at.Add(5 * time.Second)
+		var err error
+		v, err = d.Observe(ctx, "fp-bot", fmt.Sprintf("chat-%d", i), fmt.Sprintf("hello friend number %d, nice to meet you", i), at)
+		if err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+		if i < 7 && v.Bot {
+			t.Fatalf("flagged after %d messages, before MinSamples", i+1)
+		}
+	}
+	if !v.Bot || !slices.Contains(v.Signals, SignalRegularTiming) || !slices.Contains(v.Signals, SignalUniformEntropy) {
+		t.Errorf("got %+v, want bot with regular_timing and uniform_entropy", v)
+	}
+}
+
+func TestObserve_RepeatedAcrossChats(t *testing.T) {
+	d := NewDetector(testutil.Redis(t), DefaultConfig())
+	ctx := context.Background()
+
+	ad := "Visit my profile for free crypto giveaway"
+	at := time.Now()
+	for i, chatID := range []string{"chat-1", "chat-1", "chat-2", "chat-3"} {
+		at = at.Add(time.Duration(i+1) * 7 * time.Second)
+		// Case and spacing changes do not hide a repeat.
+		text := ad
+		if i%2 == 1 {
+			text = "  VISIT my profile   for free crypto giveaway"
+		}
+		v, err := d.Observe(ctx, "fp-ad", chatID, text, at)
+		if err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+		// The same chat twice is not a repeat across chats.
+		if want := i == 3; v.Bot != want || slices.Contains(v.Signals, SignalRepeatedMessage) != want {
+			t.Fatalf("message %d in %s: got %+v, want bot=%v", i, chatID, v, want)
+		}
+	}
+}
+
+func TestObserve_ShortMessagesAreNotRepeats(t *testing.T) {
+	d := NewDetector(testutil.Redis(t), DefaultConfig())
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		v, err := d.Observe(ctx, "fp-hi", fmt.Sprintf("chat-%d", i), "hi", time.Now())
+		if err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+		if slices.Contains(v.Signals, SignalRepeatedMessage) {
+			t.Fatalf("short greeting counted as repeated: %+v", v)
+		}
+	}
+}
+
+func TestObserve_WindowSlides(t *testing.T) {
+	config := DefaultConfig()
+	config.Window = time.Minute
+	d := NewDetector(testutil.Redis(t), config)
+	ctx := context.Background()
+
+	// Regular messages spread past the window never reach MinSamples.
+	at := time.Now()
+	for i := 0; i < 12; i++ {
+		at = at.Add(10 * time.Second)
+		v, err := d.Observe(ctx, "fp-slow", "chat-1", fmt.Sprintf("message number %d", i), at)
+		if err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+		if len(v.Signals) > 0 {
+			t.Fatalf("message %d: got %+v with only 6 messages per window", i, v)
+		}
+	}
+}
+
+func TestObserve_NoFingerprint(t *testing.T) {
+	d := NewDetector(testutil.Redis(t), DefaultConfig())
+	v, err := d.Observe(context.Background(), "", "chat-1", "Visit my profile for free crypto giveaway", time.Now())
+	if err != nil || v.Bot || v.Score != 0 {
+		t.Errorf("got %+v, %v; want zero verdict", v, err)
+	}
+}
+
+func TestFlag(t *testing.T) {
+	d := NewDetector(testutil.Redis(t), DefaultConfig())
+	ctx := context.Background()
+
+	if signals, err := d.Flagged(ctx, "fp-1"); err != nil || signals != nil {
+		t.Fatalf("Flagged before flagging = %v, %v", signals, err)
+	}
+	signals := []string{SignalRegularTiming, SignalUniformEntropy}
+	if first, err := d.Flag(ctx, "fp-1", signals); err != nil || !first {
+		t.Fatalf("first Flag = %v, %v; want true", first, err)
+	}
+	if first, err := d.Flag(ctx, "fp-1", []string{SignalRepeatedMessage}); err != nil || first {
+		t.Fatalf("second Flag = %v, %v; want false", first, err)
+	}
+	if got, err := d.Flagged(ctx, "fp-1"); err != nil || !slices.Equal(got, signals) {
+		t.Errorf("Flagged = %v, %v; want %v", got, err, signals)
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"", 0},
+		{"aaaa", 0},
+		{"abab", 1},
+		{"abcd", 2},
+	}
+	for _, tt := range tests {
+		if got := Entropy(tt.text); got != tt.want {
+			t.Errorf("Entropy(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -1,83 +1,84 @@
 package chat
 
-import "sync"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
 
-// MaxBufferMessages is the number of recent messages retained per chat.
-const MaxBufferMessages = 5
+	"github.com/redis/go-redis/v9"
+)
 
-// BufferedMessage represents a single message stored in the ring buffer.
+const (
+	// BufferPrefix is the Redis key prefix for report context buffers.
+	//   chat_buffer:<chat_id>  list of JSON BufferedMessage, oldest first
+	BufferPrefix = "chat_buffer:"
+
+	// MaxBufferMessages is the number of recent messages retained per chat.
+	MaxBufferMessages = 50
+)
+
+// BufferedMessage represents a single message kept for report context.
 type BufferedMessage struct {
 	From string `json:"from"` // session ID of sender
 	Text string `json:"text"`
 	Ts   int64  `json:"ts"`
 }
 
-// MessageBuffer stores the last N messages per chat in memory.
-// It is goroutine-safe and uses a ring buffer internally.
+// MessageBuffer keeps the last MaxBufferMessages messages of each chat in
+// Redis, so a report captures what both participants said whichever servers
+// they are connected to. A buffer expires with the chat (ChatTTLActive) and
+// is deleted when the chat ends.
 type MessageBuffer struct {
-	mu      sync.RWMutex
-	buffers map[string]*ringBuffer // chatID -> ring buffer
-}
-
-// ringBuffer is a fixed-size circular buffer of BufferedMessage.
-type ringBuffer struct {
-	items []BufferedMessage
-	pos   int
-	count int
+	rdb *redis.Client
 }
 
-// NewMessageBuffer creates a new empty MessageBuffer.
-func NewMessageBuffer() *MessageBuffer {
-	return &MessageBuffer{
-		buffers: make(map[string]*ringBuffer),
-	}
+// NewMessageBuffer creates a message buffer backed by Redis.
+func NewMessageBuffer(rdb *redis.Client) *MessageBuffer {
+	return &MessageBuffer{rdb: rdb}
 }
 
-// Add appends a message to the chat's ring buffer. If the buffer is full,
-// the oldest message is overwritten.
-func (mb *MessageBuffer) Add(chatID string, msg BufferedMessage) {
-	mb.mu.Lock()
-	defer mb.mu.Unlock()
-
-	rb, ok := mb.buffers[chatID]
-	if !ok {
-		rb = &ringBuffer{
-			items: make([]BufferedMessage, MaxBufferMessages),
-		}
-		mb.buffers[chatID] = rb
+// Add appends a message to the chat's buffer, dropping the oldest beyond
+// MaxBufferMessages.
+func (mb *MessageBuffer) Add(ctx context.Context, chatID string, msg BufferedMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("chat: marshal buffered message: %w", err)
 	}
 
-	rb.items[rb.pos] = msg
-	rb.pos = (rb.pos + 1) % MaxBufferMessages
-	if rb.count < MaxBufferMessages {
-		rb.count++
+	key := BufferPrefix + chatID
+	pipe := mb.rdb.Pipeline()
+	pipe.RPush(ctx, key, data)
+	pipe.LTrim(ctx, key, -MaxBufferMessages, -1)
+	pipe.Expire(ctx, key, ChatTTLActive)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("chat: buffer message: %w", err)
 	}
+	return nil
 }
 
-// Get returns the last N messages for a chat in chronological order
-// (oldest first). Returns an empty slice if the chat has no buffer.
-func (mb *MessageBuffer) Get(chatID string) []BufferedMessage {
-	mb.mu.RLock()
-	defer mb.mu.RUnlock()
-
-	rb, ok := mb.buffers[chatID]
-	if !ok {
-		return []BufferedMessage{}
+// Get returns the buffered messages of a chat in chronological order
+// (oldest first), or an empty slice if it has none. Unreadable entries are
+// skipped.
+func (mb *MessageBuffer) Get(ctx context.Context, chatID string) ([]BufferedMessage, error) {
+	raw, err := mb.rdb.LRange(ctx, BufferPrefix+chatID, 0, -1).Result()
+	if err != nil {
+		return []BufferedMessage{}, fmt.Errorf("chat: load buffer: %w", err)
 	}
-
-	result := make([]BufferedMessage, rb.count)
-	// The oldest message is at position (pos - count) mod MaxBufferMessages.
-	start := (rb.pos - rb.count + MaxBufferMessages) % MaxBufferMessages
-	for i := 0; i < rb.count; i++ {
-		result[i] = rb.items[(start+i)%MaxBufferMessages]
+	msgs := make([]BufferedMessage, 0, len(raw))
+	for _, r := range raw {
+		var m BufferedMessage
+		if err := json.Unmarshal([]byte(r), &m); err != nil {
+			continue
+		}
+		msgs = append(msgs, m)
 	}
-	return result
+	return msgs, nil
 }
 
 // Remove deletes the buffer for a chat (called when chat ends).
-func (mb *MessageBuffer) Remove(chatID string) {
-	mb.mu.Lock()
-	defer mb.mu.Unlock()
-
-	delete(mb.buffers, chatID)
+func (mb *MessageBuffer) Remove(ctx context.Context, chatID string) error {
+	if err := mb.rdb.Del(ctx, BufferPrefix+chatID).Err(); err != nil {
+		return fmt.Errorf("chat: delete buffer: %w", err)
+	}
+	return nil
 }
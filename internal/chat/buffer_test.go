@@ -1,53 +1,61 @@
 package chat
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
 )
 
 func TestAddAndGet(t *testing.T) {
-	mb := NewMessageBuffer()
+	mb := NewMessageBuffer(testutil.Redis(t))
+	ctx := context.Background()
 
-	mb.Add("chat1", BufferedMessage{From: "a", Text: "hello", Ts: 1})
-	mb.Add("chat1", BufferedMessage{From: "b", Text: "hi", Ts: 2})
-	mb.Add("chat1", BufferedMessage{From: "a", Text: "how are you?", Ts: 3})
+	mb.Add(ctx, "chat1", BufferedMessage{From: "a", Text: "hello", Ts: 1})
+	mb.Add(ctx, "chat1", BufferedMessage{From: "b", Text: "hi", Ts: 2})
+	mb.Add(ctx, "chat1", BufferedMessage{From: "a", Text: "how are you?", Ts: 3})
 
-	msgs := mb.Get("chat1")
+	msgs, err := mb.Get(ctx, "chat1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
 	if len(msgs) != 3 {
 		t.Fatalf("expected 3 messages, got %d", len(msgs))
 	}
 	if msgs[0].Text != "hello" {
 		t.Errorf("expected first message 'hello', got %q", msgs[0].Text)
 	}
-	if msgs[1].Text != "hi" {
-		t.Errorf("expected second message 'hi', got %q", msgs[1].Text)
+	if msgs[1].Text != "hi" || msgs[1].From != "b" || msgs[1].Ts != 2 {
+		t.Errorf("expected second message 'hi' from b at 2, got %+v", msgs[1])
 	}
 	if msgs[2].Text != "how are you?" {
 		t.Errorf("expected third message 'how are you?', got %q", msgs[2].Text)
 	}
 }
 
-func TestRingBufferWraparound(t *testing.T) {
-	mb := NewMessageBuffer()
+func TestBufferTrimsToMax(t *testing.T) {
+	mb := NewMessageBuffer(testutil.Redis(t))
+	ctx := context.Background()
 
-	// Add 7 messages; the buffer holds only 5.
-	for i := 1; i <= 7; i++ {
-		mb.Add("chat1", BufferedMessage{
+	// Add 7 more messages than the buffer holds.
+	for i := 1; i <= MaxBufferMessages+7; i++ {
+		mb.Add(ctx, "chat1", BufferedMessage{
 			From: "sender",
 			Text: fmt.Sprintf("msg-%d", i),
 			Ts:   int64(i),
 		})
 	}
 
-	msgs := mb.Get("chat1")
+	msgs, _ := mb.Get(ctx, "chat1")
 	if len(msgs) != MaxBufferMessages {
 		t.Fatalf("expected %d messages, got %d", MaxBufferMessages, len(msgs))
 	}
 
-	// Should contain messages 3 through 7 in order.
+	// Should contain messages 8 through MaxBufferMessages+7 in order.
 	for i, msg := range msgs {
-		expected := fmt.Sprintf("msg-%d", i+3)
+		expected := fmt.Sprintf("msg-%d", i+8)
 		if msg.Text != expected {
 			t.Errorf("index %d: expected %q, got %q", i, expected, msg.Text)
 		}
@@ -55,9 +63,12 @@ func TestRingBufferWraparound(t *testing.T) {
 }
 
 func TestGetNonExistentChat(t *testing.T) {
-	mb := NewMessageBuffer()
+	mb := NewMessageBuffer(testutil.Redis(t))
 
-	msgs := mb.Get("does-not-exist")
+	msgs, err := mb.Get(context.Background(), "does-not-exist")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
 	if msgs == nil {
 		t.Fatal("expected non-nil empty slice, got nil")
 	}
@@ -67,35 +78,40 @@ func TestGetNonExistentChat(t *testing.T) {
 }
 
 func TestRemove(t *testing.T) {
-	mb := NewMessageBuffer()
+	mb := NewMessageBuffer(testutil.Redis(t))
+	ctx := context.Background()
 
-	mb.Add("chat1", BufferedMessage{From: "a", Text: "hello", Ts: 1})
-	mb.Add("chat1", BufferedMessage{From: "b", Text: "hi", Ts: 2})
+	mb.Add(ctx, "chat1", BufferedMessage{From: "a", Text: "hello", Ts: 1})
+	mb.Add(ctx, "chat1", BufferedMessage{From: "b", Text: "hi", Ts: 2})
 
-	mb.Remove("chat1")
+	if err := mb.Remove(ctx, "chat1"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
 
-	msgs := mb.Get("chat1")
+	msgs, _ := mb.Get(ctx, "chat1")
 	if len(msgs) != 0 {
 		t.Fatalf("expected 0 messages after remove, got %d", len(msgs))
 	}
 }
 
 func TestRemoveNonExistent(t *testing.T) {
-	mb := NewMessageBuffer()
+	mb := NewMessageBuffer(testutil.Redis(t))
 
-	// Should not panic.
-	mb.Remove("does-not-exist")
+	if err := mb.Remove(context.Background(), "does-not-exist"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
 }
 
 func TestMultipleChats(t *testing.T) {
-	mb := NewMessageBuffer()
+	mb := NewMessageBuffer(testutil.Redis(t))
+	ctx := context.Background()
 
-	mb.Add("chat1", BufferedMessage{From: "a", Text: "c1-msg1", Ts: 1})
-	mb.Add("chat2", BufferedMessage{From: "b", Text: "c2-msg1", Ts: 2})
-	mb.Add("chat1", BufferedMessage{From: "b", Text: "c1-msg2", Ts: 3})
+	mb.Add(ctx, "chat1", BufferedMessage{From: "a", Text: "c1-msg1", Ts: 1})
+	mb.Add(ctx, "chat2", BufferedMessage{From: "b", Text: "c2-msg1", Ts: 2})
+	mb.Add(ctx, "chat1", BufferedMessage{From: "b", Text: "c1-msg2", Ts: 3})
 
-	msgs1 := mb.Get("chat1")
-	msgs2 := mb.Get("chat2")
+	msgs1, _ := mb.Get(ctx, "chat1")
+	msgs2, _ := mb.Get(ctx, "chat2")
 
 	if len(msgs1) != 2 {
 		t.Fatalf("chat1: expected 2 messages, got %d", len(msgs1))
@@ -111,35 +127,28 @@ func TestMultipleChats(t *testing.T) {
 	}
 }
 
-func TestExactlyMaxMessages(t *testing.T) {
-	mb := NewMessageBuffer()
+func TestBufferSharedAcrossServers(t *testing.T) {
+	rdb := testutil.Redis(t)
+	ctx := context.Background()
 
-	for i := 1; i <= MaxBufferMessages; i++ {
-		mb.Add("chat1", BufferedMessage{
-			From: "sender",
-			Text: fmt.Sprintf("msg-%d", i),
-			Ts:   int64(i),
-		})
-	}
+	// Each participant's server has its own MessageBuffer; a report sees
+	// both sides.
+	serverA, serverB := NewMessageBuffer(rdb), NewMessageBuffer(rdb)
+	serverA.Add(ctx, "chat1", BufferedMessage{From: "a", Text: "hello", Ts: 1})
+	serverB.Add(ctx, "chat1", BufferedMessage{From: "b", Text: "go away", Ts: 2})
 
-	msgs := mb.Get("chat1")
-	if len(msgs) != MaxBufferMessages {
-		t.Fatalf("expected %d messages, got %d", MaxBufferMessages, len(msgs))
-	}
-
-	for i, msg := range msgs {
-		expected := fmt.Sprintf("msg-%d", i+1)
-		if msg.Text != expected {
-			t.Errorf("index %d: expected %q, got %q", i, expected, msg.Text)
-		}
+	msgs, _ := serverA.Get(ctx, "chat1")
+	if len(msgs) != 2 || msgs[0].From != "a" || msgs[1].From != "b" {
+		t.Errorf("got %+v, want both participants' messages", msgs)
 	}
 }
 
 func TestConcurrentAccess(t *testing.T) {
-	mb := NewMessageBuffer()
+	mb := NewMessageBuffer(testutil.Redis(t))
+	ctx := context.Background()
 	chatID := "concurrent-chat"
-	goroutines := 100
-	messagesPerGoroutine := 20
+	goroutines := 20
+	messagesPerGoroutine := 10
 
 	var wg sync.WaitGroup
 	wg.Add(goroutines)
@@ -148,26 +157,20 @@ func TestConcurrentAccess(t *testing.T) {
 		go func(id int) {
 			defer wg.Done()
 			for m := 0; m < messagesPerGoroutine; m++ {
-				mb.Add(chatID, BufferedMessage{
+				mb.Add(ctx, chatID, BufferedMessage{
 					From: fmt.Sprintf("sender-%d", id),
 					Text: fmt.Sprintf("g%d-m%d", id, m),
 					Ts:   int64(id*messagesPerGoroutine + m),
 				})
-				// Interleave reads to stress the RWMutex.
-				_ = mb.Get(chatID)
+				_, _ = mb.Get(ctx, chatID)
 			}
 		}(g)
 	}
 
 	wg.Wait()
 
-	msgs := mb.Get(chatID)
+	msgs, _ := mb.Get(ctx, chatID)
 	if len(msgs) != MaxBufferMessages {
 		t.Fatalf("expected %d messages after concurrent writes, got %d", MaxBufferMessages, len(msgs))
 	}
-
-	// Verify chronological order (timestamps should be non-decreasing if
-	// the ring buffer is correct, but with concurrent writes exact ordering
-	// depends on goroutine scheduling). At minimum, we must get exactly 5
-	// messages and no panics.
 }
@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxCardNicknameChars = 32
+	MaxCardPronounsChars = 24
+	MaxCardAboutChars    = 140
+)
+
+// ProfileCard is an opt-in self-description a user can share with their
+// chat partner. It is relayed over the chat subject and never persisted.
+type ProfileCard struct {
+	Nickname string `json:"nickname,omitempty"`
+	Pronouns string `json:"pronouns,omitempty"`
+	About    string `json:"about,omitempty"`
+}
+
+// NormalizeCard trims surrounding whitespace from every field.
+func NormalizeCard(card ProfileCard) ProfileCard {
+	return ProfileCard{
+		Nickname: strings.TrimSpace(card.Nickname),
+		Pronouns: strings.TrimSpace(card.Pronouns),
+		About:    strings.TrimSpace(card.About),
+	}
+}
+
+// ValidateCard checks a normalized profile card. At least one field must be
+// set, each field must fit its length limit, and no field may contain
+// control characters (which also rules out line breaks).
+func ValidateCard(card ProfileCard) error {
+	if card.Nickname == "" && card.Pronouns == "" && card.About == "" {
+		return fmt.Errorf("card is empty")
+	}
+
+	fields := []struct {
+		name  string
+		value string
+		max   int
+	}{
+		{"nickname", card.Nickname, MaxCardNicknameChars},
+		{"pronouns", card.Pronouns, MaxCardPronounsChars},
+		{"about", card.About, MaxCardAboutChars},
+	}
+	for _, f := range fields {
+		if !utf8.ValidString(f.value) {
+			return fmt.Errorf("%s contains invalid UTF-8", f.name)
+		}
+		if utf8.RuneCountInString(f.value) > f.max {
+			return fmt.Errorf("%s exceeds %d character limit", f.name, f.max)
+		}
+		for _, r := range f.value {
+			if unicode.IsControl(r) {
+				return fmt.Errorf("%s contains control characters", f.name)
+			}
+		}
+	}
+	return nil
+}
+
+// FilterText returns the card's fields joined by newlines, for running the
+// content filter over the whole card at once.
+func (c ProfileCard) FilterText() string {
+	return strings.Join([]string{c.Nickname, c.Pronouns, c.About}, "\n")
+}
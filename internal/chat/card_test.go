@@ -0,0 +1,43 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCard(t *testing.T) {
+	tests := []struct {
+		name    string
+		card    ProfileCard
+		wantErr bool
+	}{
+		{"empty", ProfileCard{}, true},
+		{"nickname only", ProfileCard{Nickname: "Sam"}, false},
+		{"all fields", ProfileCard{Nickname: "Sam", Pronouns: "they/them", About: "Night owl, loves jazz"}, false},
+		{"nickname too long", ProfileCard{Nickname: strings.Repeat("a", MaxCardNicknameChars+1)}, true},
+		{"pronouns too long", ProfileCard{Pronouns: strings.Repeat("a", MaxCardPronounsChars+1)}, true},
+		{"about too long", ProfileCard{About: strings.Repeat("a", MaxCardAboutChars+1)}, true},
+		{"about at limit", ProfileCard{About: strings.Repeat("é", MaxCardAboutChars)}, false},
+		{"newline", ProfileCard{About: "line one\nline two"}, true},
+		{"invalid utf8", ProfileCard{Nickname: "\xff"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCard(tt.card)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCard(%+v) error = %v, wantErr %v", tt.card, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeCard(t *testing.T) {
+	got := NormalizeCard(ProfileCard{Nickname: "  Sam ", Pronouns: "\tthey/them", About: " hi "})
+	want := ProfileCard{Nickname: "Sam", Pronouns: "they/them", About: "hi"}
+	if got != want {
+		t.Errorf("NormalizeCard = %+v, want %+v", got, want)
+	}
+	if err := ValidateCard(NormalizeCard(ProfileCard{Nickname: "   "})); err == nil {
+		t.Error("expected whitespace-only card to be empty after normalization")
+	}
+}
@@ -3,9 +3,26 @@ package chat
 // ChatEvent is the payload published to NATS chat.<chat_id> subjects
 // for real-time communication between paired users.
 type ChatEvent struct {
-	Type     string `json:"type"`               // "message", "typing", "partner_left"
-	From     string `json:"from"`               // sender's session ID
-	Text     string `json:"text,omitempty"`      // for message events
-	IsTyping bool   `json:"is_typing,omitempty"` // for typing events
-	Ts       int64  `json:"ts,omitempty"`        // unix timestamp for messages
+	Type       string       `json:"type"`                 // "message", "typing", "partner_left", "retract", "chat_meta", "share_card", "inactivity_warning", "reaction"
+	From       string       `json:"from"`                 // sender's session ID
+	Text       string       `json:"text,omitempty"`       // for message events
+	IsTyping   bool         `json:"is_typing,omitempty"`  // for typing events
+	Ts         int64        `json:"ts,omitempty"`         // unix timestamp for messages
+	MessageID  string       `json:"message_id,omitempty"` // for message, retract and reaction events
+	Lang       string       `json:"lang,omitempty"`       // sender's declared language, for message events
+	Reason     string       `json:"reason,omitempty"`     // for retract and partner_left events
+	Icebreaker string       `json:"icebreaker,omitempty"` // for chat_meta events
+	Mood       string       `json:"mood,omitempty"`       // for chat_meta events
+	Card       *ProfileCard `json:"card,omitempty"`       // for share_card events
+	Emoji      string       `json:"emoji,omitempty"`      // for reaction events; empty clears the reaction
+	EndsAt     int64        `json:"ends_at,omitempty"`    // for inactivity_warning events
+	Sender     *Identity    `json:"sender,omitempty"`     // sender's nickname and avatar, for message events
+	Summary    *Summary     `json:"summary,omitempty"`    // how the chat went, for partner_left events
+	Seq        int64        `json:"seq,omitempty"`        // per-chat sequence number from Store.NextSeq; 0 if unsequenced
+	SentAt     int64        `json:"sent_at,omitempty"`    // unix nanos the sender's server read the message, for message events
+	Origin     string       `json:"origin,omitempty"`     // name of the sender's server, for message events
 }
+
+// EndReasonModerated is the partner_left reason sent to both users when a
+// moderator terminates their chat.
+const EndReasonModerated = "moderated"
@@ -0,0 +1,122 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// HistoryPrefix is the Redis key prefix for persisted chat history.
+	//   chat_history:<chat_id>            list of JSON HistoryEntry, oldest first
+	//   chat_history:<chat_id>:consent    set of session IDs that consented to export
+	//   chat_history:<chat_id>:retracted  set of message IDs retracted by moderation
+	HistoryPrefix = "chat_history:"
+
+	// MaxHistoryMessages caps how many messages are kept per chat.
+	MaxHistoryMessages = 500
+)
+
+// HistoryEntry is one persisted chat message.
+type HistoryEntry struct {
+	ID   string `json:"id"`
+	From string `json:"from"` // sender's session ID
+	Text string `json:"text"`
+	Ts   int64  `json:"ts"`
+}
+
+// HistoryStore persists the messages of active chats in Redis so that
+// participants can export their conversation. History lives only as long as
+// the chat (ChatTTLActive at most) and is deleted when the chat ends.
+type HistoryStore struct {
+	rdb *redis.Client
+}
+
+// NewHistoryStore creates a history store backed by Redis.
+func NewHistoryStore(rdb *redis.Client) *HistoryStore {
+	return &HistoryStore{rdb: rdb}
+}
+
+// Append records a message, trimming the history to MaxHistoryMessages.
+func (h *HistoryStore) Append(ctx context.Context, chatID string, entry HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("chat: marshal history entry: %w", err)
+	}
+
+	key := HistoryPrefix + chatID
+	pipe := h.rdb.Pipeline()
+	pipe.RPush(ctx, key, data)
+	pipe.LTrim(ctx, key, -MaxHistoryMessages, -1)
+	pipe.Expire(ctx, key, ChatTTLActive)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("chat: append history: %w", err)
+	}
+	return nil
+}
+
+// MarkRetracted excludes a message from future transcripts.
+func (h *HistoryStore) MarkRetracted(ctx context.Context, chatID, messageID string) error {
+	key := HistoryPrefix + chatID + ":retracted"
+	pipe := h.rdb.Pipeline()
+	pipe.SAdd(ctx, key, messageID)
+	pipe.Expire(ctx, key, ChatTTLActive)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("chat: mark retracted: %w", err)
+	}
+	return nil
+}
+
+// Consent records that sessionID agreed to export the chat. It reports
+// whether both participants have now consented, and whether this call is
+// the one that completed consent.
+func (h *HistoryStore) Consent(ctx context.Context, chatID, sessionID string) (both, completed bool, err error) {
+	key := HistoryPrefix + chatID + ":consent"
+	pipe := h.rdb.Pipeline()
+	added := pipe.SAdd(ctx, key, sessionID)
+	count := pipe.SCard(ctx, key)
+	pipe.Expire(ctx, key, ChatTTLActive)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, false, fmt.Errorf("chat: record consent: %w", err)
+	}
+
+	both = count.Val() >= 2
+	return both, both && added.Val() == 1, nil
+}
+
+// Transcript returns the chat's persisted messages, oldest first, excluding
+// retracted messages.
+func (h *HistoryStore) Transcript(ctx context.Context, chatID string) ([]HistoryEntry, error) {
+	pipe := h.rdb.Pipeline()
+	rawCmd := pipe.LRange(ctx, HistoryPrefix+chatID, 0, -1)
+	retractedCmd := pipe.SMembers(ctx, HistoryPrefix+chatID+":retracted")
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("chat: load history: %w", err)
+	}
+
+	retracted := make(map[string]bool)
+	for _, id := range retractedCmd.Val() {
+		retracted[id] = true
+	}
+
+	entries := make([]HistoryEntry, 0, len(rawCmd.Val()))
+	for _, raw := range rawCmd.Val() {
+		var e HistoryEntry
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			continue
+		}
+		if retracted[e.ID] {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Delete removes a chat's history, consent and retraction records.
+func (h *HistoryStore) Delete(ctx context.Context, chatID string) error {
+	key := HistoryPrefix + chatID
+	return h.rdb.Del(ctx, key, key+":consent", key+":retracted").Err()
+}
@@ -0,0 +1,65 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// newTestHistoryStore returns a HistoryStore on the package's test Redis,
+// skipping the test when Redis is not available.
+func newTestHistoryStore(t *testing.T, chatID string) *HistoryStore {
+	t.Helper()
+	return NewHistoryStore(testutil.Redis(t))
+}
+
+func TestHistoryStore_TranscriptExcludesRetracted(t *testing.T) {
+	const chatID = "test_history_chat"
+	h := newTestHistoryStore(t, chatID)
+	ctx := context.Background()
+
+	for _, e := range []HistoryEntry{
+		{ID: "m1", From: "a", Text: "hello", Ts: 1},
+		{ID: "m2", From: "b", Text: "bad", Ts: 2},
+		{ID: "m3", From: "a", Text: "bye", Ts: 3},
+	} {
+		if err := h.Append(ctx, chatID, e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if err := h.MarkRetracted(ctx, chatID, "m2"); err != nil {
+		t.Fatalf("MarkRetracted: %v", err)
+	}
+
+	entries, err := h.Transcript(ctx, chatID)
+	if err != nil {
+		t.Fatalf("Transcript: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != "m1" || entries[1].ID != "m3" {
+		t.Errorf("expected [m1 m3], got %+v", entries)
+	}
+}
+
+func TestHistoryStore_Consent(t *testing.T) {
+	const chatID = "test_history_consent"
+	h := newTestHistoryStore(t, chatID)
+	ctx := context.Background()
+
+	both, completed, err := h.Consent(ctx, chatID, "a")
+	if err != nil || both || completed {
+		t.Fatalf("first consent: both=%v completed=%v err=%v", both, completed, err)
+	}
+	both, completed, err = h.Consent(ctx, chatID, "a")
+	if err != nil || both || completed {
+		t.Fatalf("repeated consent: both=%v completed=%v err=%v", both, completed, err)
+	}
+	both, completed, err = h.Consent(ctx, chatID, "b")
+	if err != nil || !both || !completed {
+		t.Fatalf("second participant: both=%v completed=%v err=%v", both, completed, err)
+	}
+	both, completed, err = h.Consent(ctx, chatID, "b")
+	if err != nil || !both || completed {
+		t.Fatalf("after completion: both=%v completed=%v err=%v", both, completed, err)
+	}
+}
@@ -0,0 +1,124 @@
+package chat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNicknameChars limits a client-chosen nickname.
+const MaxNicknameChars = 24
+
+// ErrNotParticipant is returned when a session is not part of a chat.
+var ErrNotParticipant = errors.New("chat: not a participant")
+
+// Identity is the ephemeral name and avatar a participant is shown as for
+// the length of one chat. It is stored with the chat and discarded with it.
+type Identity struct {
+	Nickname   string `json:"nickname"`
+	AvatarSeed string `json:"avatar_seed"` // clients derive a deterministic avatar from it
+}
+
+var (
+	nicknameAdjectives = []string{
+		"Amber", "Brave", "Calm", "Clever", "Cosmic", "Curious", "Gentle", "Golden",
+		"Happy", "Jolly", "Lucky", "Mellow", "Misty", "Quiet", "Sunny", "Witty",
+	}
+	nicknameAnimals = []string{
+		"Badger", "Crane", "Dolphin", "Falcon", "Fox", "Heron", "Koala", "Lynx",
+		"Otter", "Owl", "Panda", "Puffin", "Robin", "Seal", "Tiger", "Wren",
+	}
+)
+
+// RandomNickname returns a generated "Adjective Animal" nickname.
+func RandomNickname() string {
+	return nicknameAdjectives[mrand.Intn(len(nicknameAdjectives))] + " " +
+		nicknameAnimals[mrand.Intn(len(nicknameAnimals))]
+}
+
+// NewAvatarSeed returns a random seed for a generated avatar.
+func NewAvatarSeed() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewIdentity returns a generated nickname and a fresh avatar seed.
+func NewIdentity() Identity {
+	return Identity{Nickname: RandomNickname(), AvatarSeed: NewAvatarSeed()}
+}
+
+// NormalizeNickname trims surrounding whitespace and collapses inner runs of
+// whitespace to a single space.
+func NormalizeNickname(nickname string) string {
+	return strings.Join(strings.Fields(nickname), " ")
+}
+
+// ValidateNickname checks a normalized client-chosen nickname: non-empty,
+// at most MaxNicknameChars and free of control characters. The content
+// filter is applied separately by the caller.
+func ValidateNickname(nickname string) error {
+	if nickname == "" {
+		return fmt.Errorf("nickname is empty")
+	}
+	if !utf8.ValidString(nickname) {
+		return fmt.Errorf("nickname contains invalid UTF-8")
+	}
+	if utf8.RuneCountInString(nickname) > MaxNicknameChars {
+		return fmt.Errorf("nickname exceeds %d character limit", MaxNicknameChars)
+	}
+	for _, r := range nickname {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("nickname contains control characters")
+		}
+	}
+	return nil
+}
+
+// IdentityOf returns the identity of a participant, or the zero Identity if
+// sessionID is not in the chat or chose none.
+func (cs *ChatSession) IdentityOf(sessionID string) Identity {
+	switch sessionID {
+	case cs.UserA:
+		return cs.IdentityA
+	case cs.UserB:
+		return cs.IdentityB
+	}
+	return Identity{}
+}
+
+// SetIdentity records a participant's identity on a chat. It returns
+// ErrNotParticipant if the chat does not exist or sessionID is not in it.
+func (s *Store) SetIdentity(ctx context.Context, chatID, sessionID string, id Identity) error {
+	n, err := s.identityScript.Run(ctx, s.rdb, []string{ChatPrefix + chatID}, sessionID, id.Nickname, id.AvatarSeed).Int()
+	if err != nil {
+		return fmt.Errorf("chat: set identity: %w", err)
+	}
+	if n == 0 {
+		return ErrNotParticipant
+	}
+	return nil
+}
+
+// setIdentityLua stores a nickname (ARGV[2]) and avatar seed (ARGV[3]) in
+// the chat hash (KEYS[1]) under the side of the participant ARGV[1]. It
+// returns 0 without writing if the chat is gone or ARGV[1] is not in it, so
+// no stray hash is created.
+const setIdentityLua = `
+local side
+if redis.call('HGET', KEYS[1], 'user_a') == ARGV[1] then
+    side = 'a'
+elseif redis.call('HGET', KEYS[1], 'user_b') == ARGV[1] then
+    side = 'b'
+else
+    return 0
+end
+redis.call('HSET', KEYS[1], 'nickname_' .. side, ARGV[2], 'avatar_' .. side, ARGV[3])
+return 1
+`
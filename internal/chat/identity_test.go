@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateNickname(t *testing.T) {
+	tests := []struct {
+		nickname string
+		wantErr  bool
+	}{
+		{"Sam", false},
+		{strings.Repeat("é", MaxNicknameChars), false},
+		{"", true},
+		{strings.Repeat("a", MaxNicknameChars+1), true},
+		{"tab\there", true},
+		{"\xff", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateNickname(tt.nickname); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateNickname(%q) error = %v, wantErr %v", tt.nickname, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNormalizeNickname(t *testing.T) {
+	if got := NormalizeNickname("  Night \t  Owl "); got != "Night Owl" {
+		t.Errorf("NormalizeNickname = %q, want %q", got, "Night Owl")
+	}
+}
+
+func TestNewIdentity(t *testing.T) {
+	id := NewIdentity()
+	if err := ValidateNickname(id.Nickname); err != nil {
+		t.Errorf("generated nickname %q invalid: %v", id.Nickname, err)
+	}
+	if len(id.AvatarSeed) != 16 || id.AvatarSeed == NewIdentity().AvatarSeed {
+		t.Errorf("unexpected avatar seed %q", id.AvatarSeed)
+	}
+}
+
+func TestStore_SetIdentity(t *testing.T) {
+	s, ctx := newTestStore(t)
+
+	if err := s.CreatePending(ctx, "chat-1", "alice", "bob", "", "exact", nil, PolicyStandard); err != nil {
+		t.Fatalf("CreatePending: %v", err)
+	}
+	alice := Identity{Nickname: "Sunny Otter", AvatarSeed: "a1"}
+	if err := s.SetIdentity(ctx, "chat-1", "alice", alice); err != nil {
+		t.Fatalf("SetIdentity: %v", err)
+	}
+	if err := s.SetIdentity(ctx, "chat-1", "mallory", alice); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("SetIdentity(non-participant) = %v, want ErrNotParticipant", err)
+	}
+	if err := s.SetIdentity(ctx, "chat-missing", "alice", alice); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("SetIdentity(missing chat) = %v, want ErrNotParticipant", err)
+	}
+	if n, _ := s.rdb.Exists(ctx, ChatPrefix+"chat-missing").Result(); n != 0 {
+		t.Error("SetIdentity created a hash for a missing chat")
+	}
+
+	cs, err := s.Get(ctx, "chat-1")
+	if err != nil || cs == nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := cs.IdentityOf("alice"); got != alice {
+		t.Errorf("IdentityOf(alice) = %+v, want %+v", got, alice)
+	}
+	if got := cs.IdentityOf("bob"); got != (Identity{}) {
+		t.Errorf("IdentityOf(bob) = %+v, want zero", got)
+	}
+}
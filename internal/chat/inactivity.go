@@ -0,0 +1,150 @@
+package chat
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ActivityKey       = "match:chat_activity" // ZSET chat_id -> unix time of the last message
+	idleWarnedPrefix  = "chat_idle_warned:"   // + <chat_id>, set once the inactivity warning went out
+	EndReasonInactive = "inactivity"          // partner_left reason when the monitor ends a chat
+
+	maxIdleBatch = 500
+)
+
+// InactivityConfig controls when silent chats are warned and ended.
+type InactivityConfig struct {
+	WarnAfter time.Duration // silence before inactivity_warning is sent; 0 disables the monitor
+	Grace     time.Duration // further silence after the warning before the chat is ended
+}
+
+// Enabled reports whether the inactivity monitor should run.
+func (c InactivityConfig) Enabled() bool {
+	return c.WarnAfter > 0
+}
+
+// TouchActivity records a message in the chat at the given time and clears
+// any pending inactivity warning.
+func (s *Store) TouchActivity(ctx context.Context, chatID string, at time.Time) error {
+	pipe := s.rdb.Pipeline()
+	pipe.ZAdd(ctx, ActivityKey, redis.Z{Score: float64(at.Unix()), Member: chatID})
+	pipe.Del(ctx, idleWarnedPrefix+chatID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IdleChats returns chats whose last message is older than before, with the
+// unix time of that message.
+func (s *Store) IdleChats(ctx context.Context, before time.Time, limit int64) ([]redis.Z, error) {
+	return s.rdb.ZRangeByScoreWithScores(ctx, ActivityKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(before.Unix(), 10),
+		Count: limit,
+	}).Result()
+}
+
+// ClaimIdleWarning reports whether the caller is the first to warn about the
+// current stretch of silence in a chat. The claim is cleared by
+// TouchActivity and otherwise lapses after ttl.
+func (s *Store) ClaimIdleWarning(ctx context.Context, chatID string, ttl time.Duration) (bool, error) {
+	return s.rdb.SetNX(ctx, idleWarnedPrefix+chatID, 1, ttl).Result()
+}
+
+// ClaimIdleEnd removes a chat from activity tracking if it has still been
+// silent since before. Exactly one caller gets true and should end the chat;
+// a message that arrived in the meantime makes it return false.
+func (s *Store) ClaimIdleEnd(ctx context.Context, chatID string, before time.Time) (bool, error) {
+	n, err := s.idleEndScript.Run(ctx, s.rdb, []string{ActivityKey}, chatID, before.Unix()).Int()
+	return n == 1, err
+}
+
+// claimIdleEndLua removes ARGV[1] from the activity set (KEYS[1]) only if its
+// last activity is at or before ARGV[2].
+const claimIdleEndLua = `
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score and tonumber(score) <= tonumber(ARGV[2]) then
+    return redis.call('ZREM', KEYS[1], ARGV[1])
+end
+return 0
+`
+
+// InactivityMonitor periodically looks for silent chats. Every wsserver may
+// run one; warnings and endings are claimed in Redis so each happens once.
+type InactivityMonitor struct {
+	store    *Store
+	cfg      InactivityConfig
+	interval time.Duration
+}
+
+// NewInactivityMonitor creates a monitor. The check interval is derived from
+// the configured durations so warnings go out reasonably close to schedule.
+func NewInactivityMonitor(store *Store, cfg InactivityConfig) *InactivityMonitor {
+	interval := cfg.WarnAfter / 10
+	if cfg.Grace > 0 && cfg.Grace/4 < interval {
+		interval = cfg.Grace / 4
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+	return &InactivityMonitor{store: store, cfg: cfg, interval: interval}
+}
+
+// Run checks for silent chats until ctx is cancelled. warn is called once
+// per silent stretch with the time the chat will be ended; end is called
+// once when the grace period has passed without a message.
+func (m *InactivityMonitor) Run(ctx context.Context, warn func(ctx context.Context, chatID string, endsAt time.Time), end func(ctx context.Context, chatID string)) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(ctx, time.Now(), warn, end)
+		}
+	}
+}
+
+func (m *InactivityMonitor) check(ctx context.Context, now time.Time, warn func(context.Context, string, time.Time), end func(context.Context, string)) {
+	idle, err := m.store.IdleChats(ctx, now.Add(-m.cfg.WarnAfter), maxIdleBatch)
+	if err != nil {
+		log.Printf("[inactivity] list idle chats: %v", err)
+		return
+	}
+
+	endBefore := now.Add(-m.cfg.WarnAfter - m.cfg.Grace)
+	for _, z := range idle {
+		chatID, _ := z.Member.(string)
+		lastActive := time.Unix(int64(z.Score), 0)
+
+		if !lastActive.After(endBefore) {
+			claimed, err := m.store.ClaimIdleEnd(ctx, chatID, endBefore)
+			if err != nil {
+				log.Printf("[inactivity] claim end chat=%s: %v", chatID, err)
+				continue
+			}
+			if claimed {
+				end(ctx, chatID)
+			}
+			continue
+		}
+
+		claimed, err := m.store.ClaimIdleWarning(ctx, chatID, m.cfg.Grace+m.interval)
+		if err != nil {
+			log.Printf("[inactivity] claim warning chat=%s: %v", chatID, err)
+			continue
+		}
+		if claimed {
+			warn(ctx, chatID, lastActive.Add(m.cfg.WarnAfter+m.cfg.Grace))
+		}
+	}
+}
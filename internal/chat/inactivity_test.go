@@ -0,0 +1,85 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewInactivityMonitor_Interval(t *testing.T) {
+	tests := []struct {
+		cfg  InactivityConfig
+		want time.Duration
+	}{
+		{InactivityConfig{WarnAfter: 10 * time.Minute, Grace: 2 * time.Minute}, 30 * time.Second},
+		{InactivityConfig{WarnAfter: 2 * time.Minute, Grace: 2 * time.Minute}, 12 * time.Second},
+		{InactivityConfig{WarnAfter: 10 * time.Minute, Grace: 20 * time.Second}, 5 * time.Second},
+		{InactivityConfig{WarnAfter: 2 * time.Second}, time.Second},
+	}
+	for _, tt := range tests {
+		if got := NewInactivityMonitor(nil, tt.cfg).interval; got != tt.want {
+			t.Errorf("interval for %+v = %s, want %s", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestInactivityMonitor_WarnsThenEnds(t *testing.T) {
+	s, ctx := newTestStore(t)
+	m := NewInactivityMonitor(s, InactivityConfig{WarnAfter: 5 * time.Minute, Grace: time.Minute})
+
+	start := time.Unix(1700000000, 0)
+	if err := s.TouchActivity(ctx, "chat-1", start); err != nil {
+		t.Fatalf("TouchActivity: %v", err)
+	}
+
+	var warned, ended []string
+	var endsAt time.Time
+	warn := func(_ context.Context, chatID string, at time.Time) {
+		warned = append(warned, chatID)
+		endsAt = at
+	}
+	end := func(_ context.Context, chatID string) { ended = append(ended, chatID) }
+
+	m.check(ctx, start.Add(4*time.Minute), warn, end)
+	if len(warned) != 0 || len(ended) != 0 {
+		t.Fatalf("chat acted on before WarnAfter: warned=%v ended=%v", warned, ended)
+	}
+
+	// Two checks during the grace period warn only once.
+	m.check(ctx, start.Add(5*time.Minute), warn, end)
+	m.check(ctx, start.Add(5*time.Minute+30*time.Second), warn, end)
+	if len(warned) != 1 || !endsAt.Equal(start.Add(6*time.Minute)) {
+		t.Fatalf("expected one warning ending at +6m, got %v ending %s", warned, endsAt)
+	}
+
+	// A message resets the silence and the warning.
+	start = start.Add(5*time.Minute + 40*time.Second)
+	if err := s.TouchActivity(ctx, "chat-1", start); err != nil {
+		t.Fatalf("TouchActivity: %v", err)
+	}
+	m.check(ctx, start.Add(6*time.Minute), warn, end)
+	if len(ended) != 1 || ended[0] != "chat-1" {
+		t.Fatalf("expected chat-1 to be ended, got %v", ended)
+	}
+
+	// Ending removes the chat from tracking.
+	m.check(ctx, start.Add(7*time.Minute), warn, end)
+	if len(ended) != 1 {
+		t.Fatalf("chat ended twice: %v", ended)
+	}
+}
+
+func TestStore_ClaimIdleEndSkipsRecentActivity(t *testing.T) {
+	s, ctx := newTestStore(t)
+
+	now := time.Unix(1700000000, 0)
+	if err := s.TouchActivity(ctx, "chat-1", now); err != nil {
+		t.Fatalf("TouchActivity: %v", err)
+	}
+	if claimed, err := s.ClaimIdleEnd(ctx, "chat-1", now.Add(-time.Second)); err != nil || claimed {
+		t.Fatalf("ClaimIdleEnd on active chat = %v, %v; want false", claimed, err)
+	}
+	if claimed, err := s.ClaimIdleEnd(ctx, "chat-1", now); err != nil || !claimed {
+		t.Fatalf("ClaimIdleEnd on idle chat = %v, %v; want true", claimed, err)
+	}
+}
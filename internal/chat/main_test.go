@@ -0,0 +1,11 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m, testutil.ServiceRedis)
+}
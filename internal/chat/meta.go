@@ -0,0 +1,67 @@
+package chat
+
+import (
+	"fmt"
+	"math/rand"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxIcebreakerChars = 200 // max character count of a selected icebreaker prompt
+	MaxMoodBytes       = 32  // enough for a multi-codepoint emoji sequence
+	MaxMoodRunes       = 8
+)
+
+// Icebreakers is the pool of conversation starters the matcher suggests when
+// a chat is created.
+var Icebreakers = []string{
+	"What's something you've been really into lately?",
+	"If you could live anywhere for a year, where would it be?",
+	"What's the best thing that happened to you this week?",
+	"What's a hobby you've always wanted to try?",
+	"What song have you had on repeat recently?",
+	"What's a small thing that always makes your day better?",
+	"If you could master any skill instantly, what would it be?",
+	"What's the last thing that made you laugh out loud?",
+	"Tea, coffee, or neither?",
+	"What's a movie or show you'd recommend to anyone?",
+}
+
+// RandomIcebreaker returns a random prompt from Icebreakers.
+func RandomIcebreaker() string {
+	return Icebreakers[rand.Intn(len(Icebreakers))]
+}
+
+// ValidateMeta checks a chat_meta payload. At least one of icebreaker or
+// mood must be set. The icebreaker is a short line of text; the mood must be
+// a short emoji sequence with no letters, digits or control characters.
+func ValidateMeta(icebreaker, mood string) error {
+	if icebreaker == "" && mood == "" {
+		return fmt.Errorf("chat_meta is empty")
+	}
+
+	if icebreaker != "" {
+		if !utf8.ValidString(icebreaker) {
+			return fmt.Errorf("icebreaker contains invalid UTF-8")
+		}
+		if utf8.RuneCountInString(icebreaker) > MaxIcebreakerChars {
+			return fmt.Errorf("icebreaker exceeds %d character limit", MaxIcebreakerChars)
+		}
+	}
+
+	if mood != "" {
+		if !utf8.ValidString(mood) {
+			return fmt.Errorf("mood contains invalid UTF-8")
+		}
+		if len(mood) > MaxMoodBytes || utf8.RuneCountInString(mood) > MaxMoodRunes {
+			return fmt.Errorf("mood is too long")
+		}
+		for _, r := range mood {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsControl(r) || unicode.IsSpace(r) {
+				return fmt.Errorf("mood must be an emoji")
+			}
+		}
+	}
+	return nil
+}
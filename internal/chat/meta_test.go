@@ -0,0 +1,49 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMeta(t *testing.T) {
+	tests := []struct {
+		name       string
+		icebreaker string
+		mood       string
+		wantErr    bool
+	}{
+		{"empty", "", "", true},
+		{"mood only", "", "😀", false},
+		{"zwj sequence", "", "👩‍💻", false},
+		{"icebreaker only", "What's your favourite book?", "", false},
+		{"both", "Tea or coffee?", "☕", false},
+		{"mood with letters", "", "happy", true},
+		{"mood with digits", "", "😀1", true},
+		{"mood too long", "", strings.Repeat("😀", MaxMoodRunes+1), true},
+		{"icebreaker too long", strings.Repeat("a", MaxIcebreakerChars+1), "", true},
+		{"invalid utf8", "\xff", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMeta(tt.icebreaker, tt.mood)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMeta(%q, %q) error = %v, wantErr %v", tt.icebreaker, tt.mood, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRandomIcebreaker(t *testing.T) {
+	valid := make(map[string]bool, len(Icebreakers))
+	for _, ib := range Icebreakers {
+		valid[ib] = true
+		if err := ValidateMeta(ib, ""); err != nil {
+			t.Errorf("built-in icebreaker %q is invalid: %v", ib, err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if ib := RandomIcebreaker(); !valid[ib] {
+			t.Fatalf("RandomIcebreaker returned unknown prompt %q", ib)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package chat
+
+// Content policies a user can ask for in find_match. Strict chats also block
+// the profanity and crude language the default filter lets through;
+// standard users have no preference. Any two users can be paired, and the
+// chat takes the stricter policy.
+const (
+	PolicyStandard = "standard"
+	PolicyStrict   = "strict"
+)
+
+// NormalizePolicy returns policy if it is a known content policy and
+// PolicyStandard otherwise, so an empty or unknown preference states none.
+func NormalizePolicy(policy string) string {
+	if policy == PolicyStrict {
+		return policy
+	}
+	return PolicyStandard
+}
+
+// AgreePolicy returns the content policy of a chat between users preferring
+// a and b: the stricter of the two.
+func AgreePolicy(a, b string) string {
+	if NormalizePolicy(a) == PolicyStrict || NormalizePolicy(b) == PolicyStrict {
+		return PolicyStrict
+	}
+	return PolicyStandard
+}
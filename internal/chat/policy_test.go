@@ -0,0 +1,43 @@
+package chat
+
+import "testing"
+
+func TestAgreePolicy(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"", "", PolicyStandard},
+		{PolicyStrict, PolicyStrict, PolicyStrict},
+		{PolicyStrict, "", PolicyStrict},
+		{PolicyStandard, PolicyStrict, PolicyStrict},
+		{PolicyStandard, PolicyStandard, PolicyStandard},
+		// "relaxed" was once a policy; it now states no preference.
+		{"relaxed", PolicyStrict, PolicyStrict},
+		{"relaxed", "anything", PolicyStandard},
+	}
+	for _, tt := range tests {
+		if got := AgreePolicy(tt.a, tt.b); got != tt.want {
+			t.Errorf("AgreePolicy(%q, %q) = %q; want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestStore_Policy(t *testing.T) {
+	s, ctx := newTestStore(t)
+
+	if err := s.CreatePending(ctx, "chat-1", "alice", "bob", "", "exact", nil, PolicyStrict); err != nil {
+		t.Fatalf("CreatePending: %v", err)
+	}
+	if cs, err := s.Get(ctx, "chat-1"); err != nil || cs == nil || cs.Policy != PolicyStrict {
+		t.Fatalf("Get = %+v, %v; want a strict chat", cs, err)
+	}
+
+	// Chats created before content policies read as standard.
+	if err := s.CreatePending(ctx, "chat-2", "carol", "dave", "", "exact", nil, ""); err != nil {
+		t.Fatalf("CreatePending: %v", err)
+	}
+	if cs, _ := s.Get(ctx, "chat-2"); cs == nil || cs.Policy != PolicyStandard {
+		t.Errorf("chat without a policy = %+v, want standard", cs)
+	}
+}
@@ -0,0 +1,36 @@
+package chat
+
+import "fmt"
+
+// MaxMessageIDBytes bounds the message ID a reaction may reference. Message
+// IDs are UUIDs; the bound only keeps junk out of relayed events.
+const MaxMessageIDBytes = 64
+
+// Reactions is the set of emoji a user may react to a message with. A fixed
+// set keeps reactions out of reach of the content filter and lets clients
+// render them as a compact picker.
+var Reactions = []string{"👍", "❤️", "😂", "😮", "😢", "🙏"}
+
+var allowedReactions = func() map[string]bool {
+	m := make(map[string]bool, len(Reactions))
+	for _, r := range Reactions {
+		m[r] = true
+	}
+	return m
+}()
+
+// ValidateReaction checks a react payload: the message ID must be set and
+// short, and the emoji must be one of Reactions or empty, which clears the
+// sender's reaction.
+func ValidateReaction(messageID, emoji string) error {
+	if messageID == "" {
+		return fmt.Errorf("message_id is required")
+	}
+	if len(messageID) > MaxMessageIDBytes {
+		return fmt.Errorf("message_id is too long")
+	}
+	if emoji != "" && !allowedReactions[emoji] {
+		return fmt.Errorf("emoji is not an allowed reaction")
+	}
+	return nil
+}
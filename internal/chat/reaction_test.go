@@ -0,0 +1,32 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateReaction(t *testing.T) {
+	tests := []struct {
+		name      string
+		messageID string
+		emoji     string
+		wantErr   bool
+	}{
+		{"allowed", "m1", "👍", false},
+		{"variation selector", "m1", "❤️", false},
+		{"clear", "m1", "", false},
+		{"missing message id", "", "👍", true},
+		{"long message id", strings.Repeat("a", MaxMessageIDBytes+1), "👍", true},
+		{"not allowed", "m1", "🍆", true},
+		{"text", "m1", "lol", true},
+		{"two emoji", "m1", "👍👍", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateReaction(tt.messageID, tt.emoji)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateReaction(%q, %q) error = %v, wantErr %v", tt.messageID, tt.emoji, err, tt.wantErr)
+			}
+		})
+	}
+}
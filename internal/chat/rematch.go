@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RematchPrefix = "rematch:"      // + <ended chat_id> -> hash of the offer
+	RematchWindow = 2 * time.Minute // how long after a chat ends both users can ask to chat again
+	TierRematch   = "rematch"       // tier recorded for chats created by a rematch
+)
+
+// Results of RequestRematch.
+const (
+	RematchExpired        = 0  // no offer for the chat, or the window passed
+	RematchWaiting        = 1  // recorded; the partner has not asked yet
+	RematchReady          = 2  // both asked; the offer is consumed
+	RematchNotParticipant = -1 // the requester was not in the chat
+)
+
+// RematchOffer describes the users of an ended chat who may chat again.
+// UserA and UserB are the sessions that last asked, which may differ from
+// the chat's if a user reconnected in between.
+type RematchOffer struct {
+	UserA     string
+	UserB     string
+	IdentityA Identity
+	IdentityB Identity
+	Interests []string
+}
+
+// OfferRematch records that the users of cs, an active chat that is ending,
+// may chat again within RematchWindow. Each side is recognized by session
+// or, after a reconnect, by browser fingerprint (fpA, fpB; empty if unknown).
+// It must run before the chat is deleted.
+func (s *Store) OfferRematch(ctx context.Context, cs *ChatSession, fpA, fpB string) error {
+	key := RematchPrefix + cs.ChatID
+	pipe := s.rdb.Pipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"user_a":     cs.UserA,
+		"user_b":     cs.UserB,
+		"fp_a":       fpA,
+		"fp_b":       fpB,
+		"nickname_a": cs.IdentityA.Nickname,
+		"avatar_a":   cs.IdentityA.AvatarSeed,
+		"nickname_b": cs.IdentityB.Nickname,
+		"avatar_b":   cs.IdentityB.AvatarSeed,
+		"interests":  strings.Join(cs.Interests, ","),
+	})
+	pipe.Expire(ctx, key, RematchWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("chat: offer rematch: %w", err)
+	}
+	return nil
+}
+
+// RequestRematch records that sessionID, with the given fingerprint, wants
+// to chat again with its partner from chatID. It returns RematchWaiting and
+// the partner's last known session until both have asked, then
+// RematchReady with the consumed offer; see the Rematch* results.
+func (s *Store) RequestRematch(ctx context.Context, chatID, sessionID, fingerprint string) (int, string, *RematchOffer, error) {
+	res, err := s.rematchScript.Run(ctx, s.rdb, []string{RematchPrefix + chatID}, sessionID, fingerprint).Slice()
+	if err != nil {
+		return RematchExpired, "", nil, fmt.Errorf("chat: request rematch: %w", err)
+	}
+	result, _ := res[0].(int64)
+	switch result {
+	case RematchWaiting:
+		partner, _ := res[1].(string)
+		return RematchWaiting, partner, nil, nil
+	case RematchReady:
+		fields := make(map[string]string, len(res)/2)
+		for i := 1; i+1 < len(res); i += 2 {
+			k, _ := res[i].(string)
+			v, _ := res[i+1].(string)
+			fields[k] = v
+		}
+		offer := &RematchOffer{
+			UserA:     fields["user_a"],
+			UserB:     fields["user_b"],
+			IdentityA: Identity{Nickname: fields["nickname_a"], AvatarSeed: fields["avatar_a"]},
+			IdentityB: Identity{Nickname: fields["nickname_b"], AvatarSeed: fields["avatar_b"]},
+			Interests: splitInterests(fields["interests"]),
+		}
+		return RematchReady, offer.partnerOf(sessionID), offer, nil
+	}
+	return int(result), "", nil, nil
+}
+
+func (o *RematchOffer) partnerOf(sessionID string) string {
+	if sessionID == o.UserA {
+		return o.UserB
+	}
+	return o.UserA
+}
+
+// CreateActive creates a chat that is active from the start, for users who
+// already agreed to it (a rematch), with the identities they had before.
+func (s *Store) CreateActive(ctx context.Context, chatID string, offer *RematchOffer) error {
+	key := ChatPrefix + chatID
+	now := time.Now()
+
+	pipe := s.rdb.Pipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"user_a":          offer.UserA,
+		"user_b":          offer.UserB,
+		"status":          StatusActive,
+		"created_at":      now.Unix(),
+		"accept_deadline": now.Unix(),
+		"accepted_a":      "true",
+		"accepted_b":      "true",
+		"tier":            TierRematch,
+		"interests":       strings.Join(offer.Interests, ","),
+		"activated_at":    now.Unix(),
+		"nickname_a":      offer.IdentityA.Nickname,
+		"avatar_a":        offer.IdentityA.AvatarSeed,
+		"nickname_b":      offer.IdentityB.Nickname,
+		"avatar_b":        offer.IdentityB.AvatarSeed,
+	})
+	pipe.Expire(ctx, key, ChatTTLActive)
+	pipe.ZAdd(ctx, ActiveKey, redis.Z{Score: float64(now.Add(ChatTTLActive).Unix()), Member: chatID})
+	pipe.Set(ctx, MemberPrefix+offer.UserA, chatID, ChatTTLActive)
+	pipe.Set(ctx, MemberPrefix+offer.UserB, chatID, ChatTTLActive)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("chat: create active: %w", err)
+	}
+	return nil
+}
+
+// requestRematchLua records a rematch request on the offer KEYS[1] from
+// session ARGV[1] with fingerprint ARGV[2]. The requester's side is the one
+// whose session or non-empty fingerprint matches; its session is updated so
+// the partner can reach it. Returns {0} if there is no offer, {-1} if the
+// requester is neither side, {1, partner session} while waiting, and {2,
+// field, value, ...} with the offer, now deleted, once both sides asked.
+const requestRematchLua = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+    return {0}
+end
+local o = redis.call('HMGET', KEYS[1], 'user_a', 'fp_a', 'user_b', 'fp_b')
+local side, other
+if ARGV[1] == o[1] or (ARGV[2] ~= '' and ARGV[2] == o[2]) then
+    side, other = 'a', 'b'
+elseif ARGV[1] == o[3] or (ARGV[2] ~= '' and ARGV[2] == o[4]) then
+    side, other = 'b', 'a'
+else
+    return {-1}
+end
+redis.call('HSET', KEYS[1], 'user_' .. side, ARGV[1], 'requested_' .. side, '1')
+if redis.call('HGET', KEYS[1], 'requested_' .. other) ~= '1' then
+    return {1, redis.call('HGET', KEYS[1], 'user_' .. other)}
+end
+local offer = redis.call('HGETALL', KEYS[1])
+redis.call('DEL', KEYS[1])
+local res = {2}
+for _, v in ipairs(offer) do
+    table.insert(res, v)
+end
+return res
+`
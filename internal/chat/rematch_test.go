@@ -0,0 +1,53 @@
+package chat
+
+import "testing"
+
+func TestStore_RequestRematch(t *testing.T) {
+	s, ctx := newTestStore(t)
+
+	cs := &ChatSession{
+		ChatID:    "chat-1",
+		UserA:     "alice",
+		UserB:     "bob",
+		Interests: []string{"music"},
+		IdentityA: Identity{Nickname: "Calm Otter", AvatarSeed: "a1"},
+		IdentityB: Identity{Nickname: "Witty Owl", AvatarSeed: "b1"},
+	}
+	if err := s.OfferRematch(ctx, cs, "fp-alice", "fp-bob"); err != nil {
+		t.Fatalf("OfferRematch: %v", err)
+	}
+
+	if result, _, _, err := s.RequestRematch(ctx, "chat-1", "mallory", "fp-mallory"); err != nil || result != RematchNotParticipant {
+		t.Fatalf("stranger: result %d, %v; want %d", result, err, RematchNotParticipant)
+	}
+	result, partner, _, err := s.RequestRematch(ctx, "chat-1", "alice", "fp-alice")
+	if err != nil || result != RematchWaiting || partner != "bob" {
+		t.Fatalf("first request = %d %q, %v; want waiting for bob", result, partner, err)
+	}
+
+	// Bob reconnected with a new session; his fingerprint identifies him.
+	result, partner, offer, err := s.RequestRematch(ctx, "chat-1", "bob-2", "fp-bob")
+	if err != nil || result != RematchReady || partner != "alice" || offer == nil {
+		t.Fatalf("second request = %d %q %v, %v; want ready with alice", result, partner, offer, err)
+	}
+	if offer.UserB != "bob-2" || offer.IdentityB.Nickname != "Witty Owl" || len(offer.Interests) != 1 {
+		t.Errorf("offer = %+v", offer)
+	}
+	if result, _, _, _ := s.RequestRematch(ctx, "chat-1", "alice", "fp-alice"); result != RematchExpired {
+		t.Errorf("expected the offer to be consumed, got %d", result)
+	}
+
+	if err := s.CreateActive(ctx, "chat-2", offer); err != nil {
+		t.Fatalf("CreateActive: %v", err)
+	}
+	got, err := s.Get(ctx, "chat-2")
+	if err != nil || got == nil {
+		t.Fatalf("Get: %v, %v", got, err)
+	}
+	if got.Status != StatusActive || got.Tier != TierRematch || got.UserB != "bob-2" || got.IdentityA.Nickname != "Calm Otter" {
+		t.Errorf("rematch chat = %+v", got)
+	}
+	if id, _ := s.ChatIDForSession(ctx, "bob-2"); id != "chat-2" {
+		t.Errorf("member index for bob-2 = %q, want chat-2", id)
+	}
+}
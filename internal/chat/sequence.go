@@ -0,0 +1,119 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SeqPrefix is the Redis key prefix for a chat's event counter:
+// chat_seq:<chat_id>.
+const SeqPrefix = "chat_seq:"
+
+// SeqWindow is how far behind the newest sequence number a tracker still
+// remembers which events it has not seen. Older events are treated as
+// duplicates.
+const SeqWindow = 256
+
+// NextSeq returns the next event sequence number for a chat, starting at 1.
+// Every event published on the chat subject takes one, whichever server
+// publishes it, so receivers can detect duplicates, reordering and loss.
+func (s *Store) NextSeq(ctx context.Context, chatID string) (int64, error) {
+	key := SeqPrefix + chatID
+	pipe := s.rdb.Pipeline()
+	seq := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ChatTTLActive)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("chat: next seq: %w", err)
+	}
+	return seq.Val(), nil
+}
+
+// CurrentSeq returns the sequence number of the chat's latest event, or 0
+// if it has none.
+func (s *Store) CurrentSeq(ctx context.Context, chatID string) (int64, error) {
+	seq, err := s.rdb.Get(ctx, SeqPrefix+chatID).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("chat: current seq: %w", err)
+	}
+	return seq, nil
+}
+
+// SeqVerdict is what a SeqTracker makes of an event's sequence number.
+type SeqVerdict int
+
+const (
+	SeqInOrder   SeqVerdict = iota // next expected event, or an unsequenced one
+	SeqGap                         // newer than expected; the events between are missing for now
+	SeqLate                        // an event previously reported missing arrived out of order
+	SeqDuplicate                   // already seen; drop it
+)
+
+// SeqTracker follows the sequence numbers of one chat as seen by one
+// receiver. It is goroutine-safe.
+type SeqTracker struct {
+	mu      sync.Mutex
+	last    int64
+	missing map[int64]struct{}
+}
+
+// NewSeqTracker creates a tracker that expects the event after base. Pass
+// CurrentSeq read before subscribing, so a receiver that joins mid-chat
+// (after a reconnect) does not report the events before it as missing.
+func NewSeqTracker(base int64) *SeqTracker {
+	return &SeqTracker{last: base, missing: make(map[int64]struct{})}
+}
+
+// Observe records seq and classifies it. For SeqGap it also returns the
+// sequence numbers skipped over, at most SeqWindow of them. An unsequenced
+// event (seq 0, from a publisher that could not reach Redis) is always
+// SeqInOrder.
+func (t *SeqTracker) Observe(seq int64) (SeqVerdict, []int64) {
+	if seq <= 0 {
+		return SeqInOrder, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if seq <= t.last {
+		if _, ok := t.missing[seq]; ok {
+			delete(t.missing, seq)
+			return SeqLate, nil
+		}
+		return SeqDuplicate, nil
+	}
+
+	var skipped []int64
+	for s := max(t.last+1, seq-SeqWindow); s < seq; s++ {
+		t.missing[s] = struct{}{}
+		skipped = append(skipped, s)
+	}
+	t.last = seq
+	for s := range t.missing {
+		if s <= seq-SeqWindow {
+			delete(t.missing, s)
+		}
+	}
+	if len(skipped) > 0 {
+		return SeqGap, skipped
+	}
+	return SeqInOrder, nil
+}
+
+// Missing returns how many of seqs have still not been seen.
+func (t *SeqTracker) Missing(seqs []int64) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, s := range seqs {
+		if _, ok := t.missing[s]; ok {
+			n++
+		}
+	}
+	return n
+}
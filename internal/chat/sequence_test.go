@@ -0,0 +1,63 @@
+package chat
+
+import "testing"
+
+func TestStore_NextSeq(t *testing.T) {
+	s, ctx := newTestStore(t)
+
+	if seq, err := s.CurrentSeq(ctx, "chat-1"); err != nil || seq != 0 {
+		t.Fatalf("CurrentSeq before any event = %d, %v; want 0", seq, err)
+	}
+	for want := int64(1); want <= 3; want++ {
+		seq, err := s.NextSeq(ctx, "chat-1")
+		if err != nil || seq != want {
+			t.Fatalf("NextSeq = %d, %v; want %d", seq, err, want)
+		}
+	}
+	if seq, _ := s.CurrentSeq(ctx, "chat-1"); seq != 3 {
+		t.Errorf("CurrentSeq = %d, want 3", seq)
+	}
+}
+
+func TestSeqTracker(t *testing.T) {
+	tr := NewSeqTracker(2)
+
+	steps := []struct {
+		seq  int64
+		want SeqVerdict
+	}{
+		{2, SeqDuplicate}, // before the tracker was created
+		{3, SeqInOrder},
+		{3, SeqDuplicate},
+		{6, SeqGap}, // 4 and 5 missing
+		{5, SeqLate},
+		{5, SeqDuplicate},
+		{0, SeqInOrder}, // unsequenced
+		{7, SeqInOrder},
+	}
+	for _, step := range steps {
+		if got, _ := tr.Observe(step.seq); got != step.want {
+			t.Errorf("Observe(%d) = %d, want %d", step.seq, got, step.want)
+		}
+	}
+	if n := tr.Missing([]int64{4, 5}); n != 1 {
+		t.Errorf("Missing = %d, want 1 (only 4 never arrived)", n)
+	}
+}
+
+func TestSeqTracker_GapReportsSkipped(t *testing.T) {
+	tr := NewSeqTracker(0)
+	verdict, skipped := tr.Observe(4)
+	if verdict != SeqGap || len(skipped) != 3 || skipped[0] != 1 || skipped[2] != 3 {
+		t.Fatalf("Observe(4) = %d %v, want a gap skipping 1-3", verdict, skipped)
+	}
+
+	// A jump beyond the window only remembers the window.
+	_, skipped = tr.Observe(4 + 2*SeqWindow)
+	if len(skipped) != SeqWindow {
+		t.Errorf("skipped %d, want %d", len(skipped), SeqWindow)
+	}
+	if v, _ := tr.Observe(2); v != SeqDuplicate {
+		t.Errorf("expected an event older than the window to count as a duplicate, got %d", v)
+	}
+}
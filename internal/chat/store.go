@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -12,8 +13,11 @@ import (
 const (
 	ChatPrefix     = "chat:"
 	PendingKey     = "match:pending_chats"
+	ActiveKey      = "match:active_chats" // ZSET chat_id -> expiry, for cluster-wide counts
+	MemberPrefix   = "chat_member:"       // + <session_id> -> chat_id, lives as long as the chat
 	ChatTTLPending = 60 * time.Second
 	ChatTTLActive  = 2 * time.Hour
+	AcceptWindow   = 15 * time.Second // how long both users have to accept a match
 
 	StatusPendingAccept = "pending_accept"
 	StatusActive        = "active"
@@ -30,6 +34,13 @@ type ChatSession struct {
 	AcceptDeadline int64
 	AcceptedA      bool
 	AcceptedB      bool
+	Icebreaker     string   // conversation starter suggested by the matcher
+	Tier           string   // matching tier that paired the users
+	Interests      []string // interests the users were matched on, if any
+	Policy         string   // content policy the users agreed on (Policy* constants)
+	ActivatedAt    int64    // unix time both users accepted; 0 while pending
+	IdentityA      Identity
+	IdentityB      Identity
 }
 
 // GetPartner returns the partner's session ID.
@@ -50,24 +61,39 @@ func (cs *ChatSession) IsParticipant(sessionID string) bool {
 
 // Store manages chat session state in Redis.
 type Store struct {
-	rdb          *redis.Client
-	acceptScript *redis.Script
+	rdb            *redis.Client
+	acceptScript   *redis.Script
+	unlinkScript   *redis.Script
+	idleEndScript  *redis.Script
+	identityScript *redis.Script
+	rematchScript  *redis.Script
+	expireScript   *redis.Script
+	markScript     *redis.Script
 }
 
 // NewStore creates a new chat store backed by Redis.
 func NewStore(rdb *redis.Client) *Store {
 	return &Store{
-		rdb:          rdb,
-		acceptScript: redis.NewScript(acceptMatchLua),
+		rdb:            rdb,
+		acceptScript:   redis.NewScript(acceptMatchLua),
+		unlinkScript:   redis.NewScript(unlinkMemberLua),
+		idleEndScript:  redis.NewScript(claimIdleEndLua),
+		identityScript: redis.NewScript(setIdentityLua),
+		rematchScript:  redis.NewScript(requestRematchLua),
+		expireScript:   redis.NewScript(claimExpiredPendingLua),
+		markScript:     redis.NewScript(markOnceLua),
 	}
 }
 
 // CreatePending creates a new chat session with pending_accept status.
-// Called by the matcher when a match is found.
-func (s *Store) CreatePending(ctx context.Context, chatID, userA, userB string) error {
+// Called by the matcher when a match is found. The icebreaker, if any, is
+// shown to both users once the chat is accepted; tier records which matching
+// tier paired them, interests what they had in common and policy the content
+// policy their messages are filtered with.
+func (s *Store) CreatePending(ctx context.Context, chatID, userA, userB, icebreaker, tier string, interests []string, policy string) error {
 	key := ChatPrefix + chatID
 	now := time.Now().Unix()
-	deadline := now + 15
+	deadline := now + int64(AcceptWindow/time.Second)
 
 	pipe := s.rdb.Pipeline()
 	pipe.HSet(ctx, key, map[string]interface{}{
@@ -78,13 +104,33 @@ func (s *Store) CreatePending(ctx context.Context, chatID, userA, userB string)
 		"accept_deadline": deadline,
 		"accepted_a":      "false",
 		"accepted_b":      "false",
+		"icebreaker":      icebreaker,
+		"tier":            tier,
+		"interests":       strings.Join(interests, ","),
+		"policy":          policy,
 	})
 	pipe.Expire(ctx, key, ChatTTLPending)
 	pipe.ZAdd(ctx, PendingKey, redis.Z{Score: float64(deadline), Member: chatID})
+	pipe.Set(ctx, MemberPrefix+userA, chatID, ChatTTLPending)
+	pipe.Set(ctx, MemberPrefix+userB, chatID, ChatTTLPending)
 	_, err := pipe.Exec(ctx)
 	return err
 }
 
+// ChatIDForSession returns the pending or active chat the session belongs
+// to, or "" if none. Unlike the session hash it survives the session's own
+// expiry, so cleanup can still find the chat.
+func (s *Store) ChatIDForSession(ctx context.Context, sessionID string) (string, error) {
+	chatID, err := s.rdb.Get(ctx, MemberPrefix+sessionID).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("chat: chat for session: %w", err)
+	}
+	return chatID, nil
+}
+
 // Get retrieves a chat session. Returns nil if not found.
 func (s *Store) Get(ctx context.Context, chatID string) (*ChatSession, error) {
 	key := ChatPrefix + chatID
@@ -98,6 +144,7 @@ func (s *Store) Get(ctx context.Context, chatID string) (*ChatSession, error) {
 
 	createdAt, _ := strconv.ParseInt(result["created_at"], 10, 64)
 	acceptDeadline, _ := strconv.ParseInt(result["accept_deadline"], 10, 64)
+	activatedAt, _ := strconv.ParseInt(result["activated_at"], 10, 64)
 
 	return &ChatSession{
 		ChatID:         chatID,
@@ -108,6 +155,13 @@ func (s *Store) Get(ctx context.Context, chatID string) (*ChatSession, error) {
 		AcceptDeadline: acceptDeadline,
 		AcceptedA:      result["accepted_a"] == "true",
 		AcceptedB:      result["accepted_b"] == "true",
+		Icebreaker:     result["icebreaker"],
+		Tier:           result["tier"],
+		Interests:      splitInterests(result["interests"]),
+		Policy:         NormalizePolicy(result["policy"]),
+		ActivatedAt:    activatedAt,
+		IdentityA:      Identity{Nickname: result["nickname_a"], AvatarSeed: result["avatar_a"]},
+		IdentityB:      Identity{Nickname: result["nickname_b"], AvatarSeed: result["avatar_b"]},
 	}, nil
 }
 
@@ -120,24 +174,105 @@ func (s *Store) Get(ctx context.Context, chatID string) (*ChatSession, error) {
 //	-3 = session not a participant
 func (s *Store) AcceptMatch(ctx context.Context, chatID, sessionID string) (int, error) {
 	key := ChatPrefix + chatID
-	result, err := s.acceptScript.Run(ctx, s.rdb, []string{key}, sessionID).Int()
+	now := time.Now()
+	expiresAt := now.Add(ChatTTLActive).Unix()
+	result, err := s.acceptScript.Run(ctx, s.rdb, []string{key, ActiveKey}, sessionID, chatID, expiresAt, now.Unix()).Int()
 	if err != nil {
 		return -1, fmt.Errorf("chat: accept match: %w", err)
 	}
+	if result == 1 {
+		// Keep the member index alive as long as the now-active chat.
+		users, err := s.rdb.HMGet(ctx, key, "user_a", "user_b").Result()
+		if err == nil {
+			pipe := s.rdb.Pipeline()
+			for _, u := range users {
+				if uid, ok := u.(string); ok && uid != "" {
+					pipe.Expire(ctx, MemberPrefix+uid, ChatTTLActive)
+				}
+			}
+			_, _ = pipe.Exec(ctx)
+		}
+	}
 	return result, nil
 }
 
-// Delete removes a chat session and its pending tracking entry.
+// ClaimExpiredPending ends a pending chat whose accept deadline has passed.
+// Exactly one caller gets the chat back, with who had accepted, and should
+// notify both users and Delete it; nil means the chat was accepted in time,
+// claimed by another caller or is gone. A late accept_match is rejected as
+// not pending.
+func (s *Store) ClaimExpiredPending(ctx context.Context, chatID string) (*ChatSession, error) {
+	n, err := s.expireScript.Run(ctx, s.rdb, []string{ChatPrefix + chatID}).Int()
+	if err != nil {
+		return nil, fmt.Errorf("chat: claim expired: %w", err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return s.Get(ctx, chatID)
+}
+
+// MarkOnce sets the marker field on the chat hash and reports whether it
+// was the first to, so work done for both participants happens once. A
+// chat that is gone, deleted or expired, is not recreated: MarkOnce reports
+// false.
+func (s *Store) MarkOnce(ctx context.Context, chatID, field string) (bool, error) {
+	first, err := s.markScript.Run(ctx, s.rdb, []string{ChatPrefix + chatID}, field).Bool()
+	if err != nil {
+		return false, fmt.Errorf("chat: mark %s: %w", field, err)
+	}
+	return first, nil
+}
+
+// Delete removes a chat session, its pending, active and inactivity tracking
+// entries and its members' index entries.
 func (s *Store) Delete(ctx context.Context, chatID string) error {
+	users, err := s.rdb.HMGet(ctx, ChatPrefix+chatID, "user_a", "user_b").Result()
+	if err != nil {
+		return fmt.Errorf("chat: delete: %w", err)
+	}
+
 	pipe := s.rdb.Pipeline()
-	pipe.Del(ctx, ChatPrefix+chatID)
+	for _, u := range users {
+		if uid, ok := u.(string); ok && uid != "" {
+			s.unlinkScript.Eval(ctx, pipe, []string{MemberPrefix + uid}, chatID)
+		}
+	}
+	pipe.Del(ctx, ChatPrefix+chatID, StatsPrefix+chatID, SeqPrefix+chatID)
 	pipe.ZRem(ctx, PendingKey, chatID)
-	_, err := pipe.Exec(ctx)
+	pipe.ZRem(ctx, ActiveKey, chatID)
+	pipe.ZRem(ctx, ActivityKey, chatID)
+	pipe.Del(ctx, idleWarnedPrefix+chatID)
+	_, err = pipe.Exec(ctx)
 	return err
 }
 
+// CountActive returns the number of active chats across all servers. Chats
+// whose TTL lapsed without an explicit Delete are pruned first.
+func (s *Store) CountActive(ctx context.Context) (int64, error) {
+	pipe := s.rdb.Pipeline()
+	pipe.ZRemRangeByScore(ctx, ActiveKey, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	card := pipe.ZCard(ctx, ActiveKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("chat: count active: %w", err)
+	}
+	return card.Val(), nil
+}
+
+// unlinkMemberLua deletes a member index entry (KEYS[1]) only if it still
+// points at the chat being deleted (ARGV[1]); the user may already be in a
+// newer chat.
+const unlinkMemberLua = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
 // acceptMatchLua atomically marks a user as accepted and checks if both have.
-// If both accepted, it sets status to active and extends TTL to 2 hours.
+// If both accepted, it sets status to active, stamps activated_at (ARGV[4]),
+// extends TTL to 2 hours and records the chat in the active set (KEYS[2])
+// scored by its expiry.
 const acceptMatchLua = `
 local key = KEYS[1]
 local session_id = ARGV[1]
@@ -161,10 +296,31 @@ local accepted_a = redis.call('HGET', key, 'accepted_a')
 local accepted_b = redis.call('HGET', key, 'accepted_b')
 
 if accepted_a == 'true' and accepted_b == 'true' then
-    redis.call('HSET', key, 'status', 'active')
+    redis.call('HSET', key, 'status', 'active', 'activated_at', ARGV[4])
     redis.call('EXPIRE', key, 7200)
+    redis.call('ZADD', KEYS[2], ARGV[3], ARGV[2])
     return 1
 end
 
 return 0
 `
+
+// claimExpiredPendingLua ends the chat in KEYS[1] if it is still waiting for
+// an accept.
+const claimExpiredPendingLua = `
+if redis.call('HGET', KEYS[1], 'status') == 'pending_accept' then
+    redis.call('HSET', KEYS[1], 'status', 'ended')
+    return 1
+end
+return 0
+`
+
+// markOnceLua sets field ARGV[1] of the chat hash KEYS[1] if the hash exists
+// and the field does not, returning 1 if it did. A bare HSETNX would
+// recreate an expired chat as a hash without a TTL.
+const markOnceLua = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+    return 0
+end
+return redis.call('HSETNX', KEYS[1], ARGV[1], 1)
+`
@@ -0,0 +1,95 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// newTestStore returns a Store on the package's test Redis, skipping the
+// test when Redis is not available.
+func newTestStore(t *testing.T) (*Store, context.Context) {
+	t.Helper()
+	return NewStore(testutil.Redis(t)), context.Background()
+}
+
+func TestStore_MemberIndex(t *testing.T) {
+	s, ctx := newTestStore(t)
+
+	if err := s.CreatePending(ctx, "chat-1", "alice", "bob", "", "exact", nil, PolicyStandard); err != nil {
+		t.Fatalf("CreatePending: %v", err)
+	}
+	for _, sid := range []string{"alice", "bob"} {
+		if got, err := s.ChatIDForSession(ctx, sid); err != nil || got != "chat-1" {
+			t.Fatalf("ChatIDForSession(%s) = %q, %v; want chat-1", sid, got, err)
+		}
+	}
+
+	// bob moves on to a newer chat before chat-1 is deleted.
+	if err := s.CreatePending(ctx, "chat-2", "bob", "carol", "", "exact", nil, PolicyStandard); err != nil {
+		t.Fatalf("CreatePending: %v", err)
+	}
+	if err := s.Delete(ctx, "chat-1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if got, _ := s.ChatIDForSession(ctx, "alice"); got != "" {
+		t.Errorf("expected alice to have no chat after delete, got %q", got)
+	}
+	if got, _ := s.ChatIDForSession(ctx, "bob"); got != "chat-2" {
+		t.Errorf("expected bob's newer chat to survive, got %q", got)
+	}
+}
+
+func TestStore_ClaimExpiredPending(t *testing.T) {
+	s, ctx := newTestStore(t)
+
+	for _, id := range []string{"chat-1", "chat-2"} {
+		if err := s.CreatePending(ctx, id, "alice-"+id, "bob-"+id, "", "exact", nil, PolicyStandard); err != nil {
+			t.Fatalf("CreatePending: %v", err)
+		}
+	}
+	s.AcceptMatch(ctx, "chat-1", "alice-chat-1")
+	s.AcceptMatch(ctx, "chat-2", "alice-chat-2")
+	s.AcceptMatch(ctx, "chat-2", "bob-chat-2")
+
+	cs, err := s.ClaimExpiredPending(ctx, "chat-1")
+	if err != nil || cs == nil || !cs.AcceptedA || cs.AcceptedB {
+		t.Fatalf("ClaimExpiredPending = %+v, %v; want chat-1 with only alice accepted", cs, err)
+	}
+	if again, _ := s.ClaimExpiredPending(ctx, "chat-1"); again != nil {
+		t.Error("chat-1 was claimed twice")
+	}
+	if n, _ := s.AcceptMatch(ctx, "chat-1", "bob-chat-1"); n != -2 {
+		t.Errorf("late AcceptMatch = %d, want -2 (not pending)", n)
+	}
+	for _, id := range []string{"chat-2", "missing"} {
+		if cs, err := s.ClaimExpiredPending(ctx, id); cs != nil || err != nil {
+			t.Errorf("ClaimExpiredPending(%s) = %+v, %v; want nil", id, cs, err)
+		}
+	}
+}
+
+func TestStore_MarkOnce(t *testing.T) {
+	s, ctx := newTestStore(t)
+	if err := s.CreatePending(ctx, "chat-1", "alice", "bob", "", "exact", nil, PolicyStandard); err != nil {
+		t.Fatalf("CreatePending: %v", err)
+	}
+
+	for i, want := range []bool{true, false} {
+		if first, err := s.MarkOnce(ctx, "chat-1", "ended"); err != nil || first != want {
+			t.Errorf("MarkOnce #%d = %v, %v; want %v", i+1, first, err, want)
+		}
+	}
+	if ttl := s.rdb.TTL(ctx, ChatPrefix+"chat-1").Val(); ttl <= 0 {
+		t.Errorf("marking dropped the chat's TTL: %s", ttl)
+	}
+
+	if first, err := s.MarkOnce(ctx, "gone", "ended"); err != nil || first {
+		t.Errorf("MarkOnce(gone) = %v, %v; want false", first, err)
+	}
+	if s.rdb.Exists(ctx, ChatPrefix+"gone").Val() != 0 {
+		t.Error("marking a chat that is gone recreated its hash")
+	}
+}
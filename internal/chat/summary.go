@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatsPrefix is the Redis key prefix for a chat's counters hash:
+// chat_stats:<chat_id> maps each participant's session ID to the number of
+// messages they sent. It is deleted with the chat.
+const StatsPrefix = "chat_stats:"
+
+// Summary describes a finished chat. It travels with the partner_left event
+// so every server can tell its own participant how the chat went.
+type Summary struct {
+	Duration  time.Duration  `json:"duration"`            // from both accepting to the end
+	Messages  map[string]int `json:"messages"`            // messages sent, by session ID
+	Interests []string       `json:"interests,omitempty"` // interests the users were matched on
+}
+
+// Counts returns the messages sessionID sent and received in the chat.
+func (s *Summary) Counts(sessionID string) (sent, received int) {
+	for sid, n := range s.Messages {
+		if sid == sessionID {
+			sent += n
+		} else {
+			received += n
+		}
+	}
+	return sent, received
+}
+
+// CountMessage records a message sent by sessionID in chatID.
+func (s *Store) CountMessage(ctx context.Context, chatID, sessionID string) error {
+	key := StatsPrefix + chatID
+	pipe := s.rdb.Pipeline()
+	pipe.HIncrBy(ctx, key, sessionID, 1)
+	pipe.Expire(ctx, key, ChatTTLActive)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("chat: count message: %w", err)
+	}
+	return nil
+}
+
+// Summarize builds the summary of cs ending at now from its counters. It
+// must be called before the chat is deleted.
+func (s *Store) Summarize(ctx context.Context, cs *ChatSession, now time.Time) (*Summary, error) {
+	counts, err := s.rdb.HGetAll(ctx, StatsPrefix+cs.ChatID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("chat: summarize: %w", err)
+	}
+	summary := &Summary{
+		Messages:  map[string]int{cs.UserA: 0, cs.UserB: 0},
+		Interests: cs.Interests,
+	}
+	for sid, v := range counts {
+		if cs.IsParticipant(sid) {
+			summary.Messages[sid], _ = strconv.Atoi(v)
+		}
+	}
+	if cs.ActivatedAt > 0 {
+		summary.Duration = now.Sub(time.Unix(cs.ActivatedAt, 0))
+	}
+	return summary, nil
+}
+
+// splitInterests parses the comma-separated interests field of a chat hash.
+func splitInterests(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
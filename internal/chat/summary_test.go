@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore_Summarize(t *testing.T) {
+	s, ctx := newTestStore(t)
+
+	if err := s.CreatePending(ctx, "chat-1", "alice", "bob", "", "exact", []string{"music", "go"}, PolicyStandard); err != nil {
+		t.Fatalf("CreatePending: %v", err)
+	}
+	for _, sid := range []string{"alice", "bob"} {
+		if _, err := s.AcceptMatch(ctx, "chat-1", sid); err != nil {
+			t.Fatalf("AcceptMatch(%s): %v", sid, err)
+		}
+	}
+	for _, sid := range []string{"alice", "alice", "bob", "mallory"} {
+		if err := s.CountMessage(ctx, "chat-1", sid); err != nil {
+			t.Fatalf("CountMessage: %v", err)
+		}
+	}
+
+	cs, err := s.Get(ctx, "chat-1")
+	if err != nil || cs == nil {
+		t.Fatalf("Get: %v, %v", cs, err)
+	}
+	summary, err := s.Summarize(ctx, cs, time.Unix(cs.ActivatedAt, 0).Add(14*time.Minute))
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if summary.Duration != 14*time.Minute {
+		t.Errorf("Duration = %s, want 14m", summary.Duration)
+	}
+	if len(summary.Interests) != 2 || summary.Interests[0] != "music" || summary.Interests[1] != "go" {
+		t.Errorf("Interests = %v, want [music go]", summary.Interests)
+	}
+	if sent, received := summary.Counts("alice"); sent != 2 || received != 1 {
+		t.Errorf("alice sent/received = %d/%d, want 2/1", sent, received)
+	}
+	if sent, received := summary.Counts("bob"); sent != 1 || received != 2 {
+		t.Errorf("bob sent/received = %d/%d, want 1/2 (non-participants ignored)", sent, received)
+	}
+
+	if err := s.Delete(ctx, "chat-1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if n := s.rdb.Exists(ctx, StatsPrefix+"chat-1").Val(); n != 0 {
+		t.Error("expected the counters to be deleted with the chat")
+	}
+}
@@ -2,27 +2,112 @@ package chat
 
 import (
 	"fmt"
+	"unicode"
 	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
 )
 
 const (
 	MaxMessageBytes = 4096 // 4KB max frame size
-	MaxTextChars    = 2000 // max character count
+	MaxTextChars    = 2000 // default max character count
 )
 
-// ValidateMessage checks that a chat message meets content requirements.
-func ValidateMessage(text string) error {
-	if len(text) == 0 {
-		return fmt.Errorf("message text is empty")
-	}
-	if len(text) > MaxMessageBytes {
-		return fmt.Errorf("message exceeds %d byte limit", MaxMessageBytes)
+// Reasons a message is rejected, as reported in MessageError.
+const (
+	MessageEmpty            = "empty"
+	MessageBlank            = "blank" // only whitespace or invisible characters
+	MessageInvalidUTF8      = "invalid_utf8"
+	MessageTooManyBytes     = "too_many_bytes"
+	MessageTooManyChars     = "too_many_chars"
+	MessageTooManyGraphemes = "too_many_graphemes"
+)
+
+// MessageLimits bound the text of a chat message. MaxChars counts code
+// points; MaxGraphemes counts user-perceived characters, so an emoji built
+// from several code points counts once. Both apply; zero disables one.
+// MaxBytes is always capped at MaxMessageBytes.
+type MessageLimits struct {
+	MaxBytes     int
+	MaxChars     int
+	MaxGraphemes int
+}
+
+// DefaultMessageLimits allows MaxTextChars code points in MaxMessageBytes.
+func DefaultMessageLimits() MessageLimits {
+	return MessageLimits{MaxBytes: MaxMessageBytes, MaxChars: MaxTextChars}
+}
+
+// MessageError describes why a message was rejected. Limit is the limit
+// that was exceeded, or zero.
+type MessageError struct {
+	Reason string
+	Limit  int
+}
+
+func (e *MessageError) Error() string {
+	switch e.Reason {
+	case MessageEmpty:
+		return "message text is empty"
+	case MessageBlank:
+		return "message has no visible content"
+	case MessageInvalidUTF8:
+		return "message contains invalid UTF-8"
+	case MessageTooManyBytes:
+		return fmt.Sprintf("message exceeds %d byte limit", e.Limit)
+	default: // MessageTooManyChars, MessageTooManyGraphemes
+		return fmt.Sprintf("message exceeds %d character limit", e.Limit)
 	}
-	if utf8.RuneCountInString(text) > MaxTextChars {
-		return fmt.Errorf("message exceeds %d character limit", MaxTextChars)
+}
+
+// ValidateMessage checks a chat message against DefaultMessageLimits.
+func ValidateMessage(text string) error {
+	return DefaultMessageLimits().Validate(text)
+}
+
+// Validate checks that a chat message is valid UTF-8 within the limits and
+// has visible content. It returns a *MessageError.
+func (l MessageLimits) Validate(text string) error {
+	maxBytes := MaxMessageBytes
+	if l.MaxBytes > 0 && l.MaxBytes < maxBytes {
+		maxBytes = l.MaxBytes
 	}
-	if !utf8.ValidString(text) {
-		return fmt.Errorf("message contains invalid UTF-8")
+	switch {
+	case len(text) == 0:
+		return &MessageError{Reason: MessageEmpty}
+	case len(text) > maxBytes:
+		return &MessageError{Reason: MessageTooManyBytes, Limit: maxBytes}
+	case !utf8.ValidString(text):
+		return &MessageError{Reason: MessageInvalidUTF8}
+	case l.MaxChars > 0 && utf8.RuneCountInString(text) > l.MaxChars:
+		return &MessageError{Reason: MessageTooManyChars, Limit: l.MaxChars}
+	case l.MaxGraphemes > 0 && GraphemeCount(text) > l.MaxGraphemes:
+		return &MessageError{Reason: MessageTooManyGraphemes, Limit: l.MaxGraphemes}
+	case isBlank(text):
+		return &MessageError{Reason: MessageBlank}
 	}
 	return nil
 }
+
+// GraphemeCount returns the number of user-perceived characters in s: its
+// Unicode extended grapheme clusters (UAX #29).
+func GraphemeCount(s string) int {
+	return uniseg.GraphemeClusterCount(s)
+}
+
+// isBlank reports whether text has nothing visible: only whitespace, format
+// characters such as zero-width spaces, and filler characters that render
+// as blanks.
+func isBlank(text string) bool {
+	for _, r := range text {
+		if unicode.IsSpace(r) || unicode.Is(unicode.Cf, r) || unicode.In(r, unicode.Mn, unicode.Me) {
+			continue
+		}
+		switch r {
+		case '\u115F', '\u1160', '\u2800', '\u3164', '\uFFA0': // Hangul fillers, blank Braille pattern
+			continue
+		}
+		return false
+	}
+	return true
+}
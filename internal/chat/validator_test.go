@@ -0,0 +1,92 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGraphemeCount(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"ascii", "hello", 5},
+		{"empty", "", 0},
+		{"combining accent", "e\u0301te\u0301", 3},
+		{"skin tone", "\U0001F44D\U0001F3FD", 1},
+		{"zwj family", "\U0001F468\u200D\U0001F469\u200D\U0001F467", 1},
+		{"variation selector", "\u2764\uFE0F", 1},
+		{"flags", "\U0001F1EF\U0001F1F5\U0001F1FA\U0001F1F8", 2},
+		{"odd regional indicator", "\U0001F1EF\U0001F1F5\U0001F1FA", 2},
+		{"subdivision flag", "\U0001F3F4\U000E0067\U000E0062\U000E0065\U000E006E\U000E0067\U000E007F", 1},
+		{"keycap", "1\uFE0F\u20E3", 1},
+		{"hangul jamo", "\u1100\u1161\u11A8", 1},
+		{"crlf", "a\r\nb", 3},
+		{"zwj between letters", "a\u200Db", 2},
+		{"zwj emoji with variation selectors", "\U0001F441\uFE0F\u200D\U0001F5E8\uFE0F", 1},
+		{"hangul jamo before syllable", "\u1100\uAC00", 1},
+		{"prepended number sign", "\u0600\u0661", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GraphemeCount(tt.text); got != tt.want {
+				t.Errorf("GraphemeCount(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageLimitsValidate(t *testing.T) {
+	thumbs := "\U0001F44D\U0001F3FD" // two code points, one grapheme
+	tests := []struct {
+		name   string
+		limits MessageLimits
+		text   string
+		reason string
+		limit  int
+	}{
+		{"ok", DefaultMessageLimits(), "hi there", "", 0},
+		{"empty", DefaultMessageLimits(), "", MessageEmpty, 0},
+		{"whitespace", DefaultMessageLimits(), " \t\n ", MessageBlank, 0},
+		{"zero width", DefaultMessageLimits(), "\u200B\u200B\uFEFF", MessageBlank, 0},
+		{"hangul filler", DefaultMessageLimits(), "\u3164", MessageBlank, 0},
+		{"lone combining mark", DefaultMessageLimits(), " \u0301", MessageBlank, 0},
+		{"invalid utf8", DefaultMessageLimits(), "a\xffb", MessageInvalidUTF8, 0},
+		{"too many bytes", DefaultMessageLimits(), strings.Repeat("a", MaxMessageBytes+1), MessageTooManyBytes, MaxMessageBytes},
+		{"byte cap below frame size", MessageLimits{MaxBytes: 10}, strings.Repeat("a", 11), MessageTooManyBytes, 10},
+		{"too many chars", MessageLimits{MaxChars: 3}, "abcd", MessageTooManyChars, 3},
+		{"emoji within graphemes", MessageLimits{MaxGraphemes: 3}, thumbs + thumbs + thumbs, "", 0},
+		{"emoji over graphemes", MessageLimits{MaxGraphemes: 3}, thumbs + thumbs + thumbs + thumbs, MessageTooManyGraphemes, 3},
+		{"emoji over chars", MessageLimits{MaxChars: 5, MaxGraphemes: 5}, thumbs + thumbs + thumbs, MessageTooManyChars, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Validate(tt.text)
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("Validate(%q) = %v, want nil", tt.text, err)
+				}
+				return
+			}
+			var msgErr *MessageError
+			if !errors.As(err, &msgErr) {
+				t.Fatalf("Validate(%q) = %v, want *MessageError", tt.text, err)
+			}
+			if msgErr.Reason != tt.reason || msgErr.Limit != tt.limit {
+				t.Errorf("Validate(%q) = {%s %d}, want {%s %d}", tt.text, msgErr.Reason, msgErr.Limit, tt.reason, tt.limit)
+			}
+		})
+	}
+}
+
+func TestValidateMessageDefaults(t *testing.T) {
+	if err := ValidateMessage(strings.Repeat("a", MaxTextChars)); err != nil {
+		t.Errorf("ValidateMessage(%d chars) = %v, want nil", MaxTextChars, err)
+	}
+	err := ValidateMessage(strings.Repeat("a", MaxTextChars+1))
+	if err == nil || err.Error() != "message exceeds 2000 character limit" {
+		t.Errorf("ValidateMessage(%d chars) = %v", MaxTextChars+1, err)
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/logging"
+	"github.com/whisper/chat-app/internal/ratelimit"
+)
+
+func writeSettings(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "whisper.json")
+	writeSettings(t, path, `{
+		"log_level": "warn",
+		"heartbeat": {"interval": "15s", "timeout": "5s"},
+		"rate_limits": {"message": {"limit": 8, "window": "10s"}},
+		"features": {"icebreakers": true},
+		"moderation_dry_run": true
+	}`)
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Level() != logging.LevelWarn {
+		t.Errorf("expected warn level, got %s", s.Level())
+	}
+	if time.Duration(s.Heartbeat.Interval) != 15*time.Second {
+		t.Errorf("unexpected heartbeat interval: %s", time.Duration(s.Heartbeat.Interval))
+	}
+	if r := s.RateLimitRules()["message"]; r.Limit != 8 || r.Window != 10*time.Second {
+		t.Errorf("unexpected message rule: %+v", r)
+	}
+	if !s.Feature("icebreakers") || s.Feature("missing") {
+		t.Errorf("unexpected feature flags: %v", s.Features)
+	}
+	if !s.ModerationDryRun {
+		t.Error("expected moderation dry run")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"syntax":        `{"log_level":`,
+		"unknown field": `{"log_levle": "info"}`,
+		"bad level":     `{"log_level": "loud"}`,
+		"bad duration":  `{"heartbeat": {"interval": "soon", "timeout": "5s"}}`,
+		"zero timeout":  `{"heartbeat": {"interval": "5s", "timeout": "0s"}}`,
+		"unknown rule":  `{"rate_limits": {"nope": {"limit": 1, "window": "1s"}}}`,
+		"zero limit":    `{"rate_limits": {"match": {"limit": 0, "window": "1s"}}}`,
+	}
+	dir := t.TempDir()
+	for name, body := range cases {
+		path := filepath.Join(dir, "whisper.json")
+		writeSettings(t, path, body)
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoad_EmptyPath(t *testing.T) {
+	s, err := Load("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Level() != logging.LevelInfo || s.Heartbeat != nil || len(s.RateLimits) != 0 {
+		t.Errorf("expected defaults, got %+v", s)
+	}
+}
+
+func TestHash_Stable(t *testing.T) {
+	a := &Settings{Features: map[string]bool{"a": true, "b": false}}
+	b := &Settings{Features: map[string]bool{"b": false, "a": true}}
+	if a.Hash() != b.Hash() {
+		t.Error("equal settings should hash identically")
+	}
+	if a.Hash() == (&Settings{}).Hash() {
+		t.Error("different settings should hash differently")
+	}
+}
+
+func TestReloader_KeepsPreviousOnInvalid(t *testing.T) {
+	defer ratelimit.SetOverrides(nil)
+	defer logging.SetLevel(logging.CurrentLevel())
+
+	path := filepath.Join(t.TempDir(), "whisper.json")
+	writeSettings(t, path, `{"log_level": "error", "rate_limits": {"match": {"limit": 2, "window": "1m"}}}`)
+
+	r := NewReloader(path)
+	applied := 0
+	r.OnReload(func(*Settings) error { applied++; return nil })
+	if err := r.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	good := r.Current().Hash()
+	if applied != 1 || logging.CurrentLevel() != logging.LevelError {
+		t.Fatalf("expected settings to be applied (applied=%d level=%s)", applied, logging.CurrentLevel())
+	}
+	if ratelimit.Effective(ratelimit.RuleMatch).Limit != 2 {
+		t.Error("expected match rate limit override to be applied")
+	}
+
+	writeSettings(t, path, `{"log_level": "debug", "rate_limits": {"match": {"limit": -1, "window": "1m"}}}`)
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected invalid config to be rejected")
+	}
+	if r.Current().Hash() != good || applied != 1 {
+		t.Error("invalid config must not replace the current settings")
+	}
+	if logging.CurrentLevel() != logging.LevelError || ratelimit.Effective(ratelimit.RuleMatch).Limit != 2 {
+		t.Error("invalid config must not change the log level or rate limits")
+	}
+}
+
+func TestReloader_ApplyError(t *testing.T) {
+	r := NewReloader("")
+	boom := errors.New("boom")
+	r.OnReload(func(*Settings) error { return boom })
+	if err := r.Reload(); !errors.Is(err, boom) {
+		t.Errorf("expected applier error, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDialect(t *testing.T) {
+	tests := map[string]Dialect{
+		"postgres://u:p@localhost:5432/whisper?sslmode=disable": Postgres,
+		"postgresql://localhost/whisper":                        Postgres,
+		"mysql://u:p@tcp(localhost:3306)/whisper":               MySQL,
+		"sqlite:///var/lib/whisper/whisper.db":                  SQLite,
+	}
+	for url, want := range tests {
+		if got, err := ParseDialect(url); err != nil || got != want {
+			t.Errorf("ParseDialect(%q) = %q, %v; want %q", url, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "whisper.db", "sqlserver://localhost"} {
+		if _, err := ParseDialect(bad); err == nil {
+			t.Errorf("ParseDialect(%q) expected error", bad)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	const query = `SELECT a FROM t WHERE b = $1 AND c = '$x' LIMIT $12`
+	if got := Rebind(Postgres, query); got != query {
+		t.Errorf("Rebind(postgres) = %q", got)
+	}
+	want := `SELECT a FROM t WHERE b = ? AND c = '$x' LIMIT ?`
+	if got := Rebind(SQLite, query); got != want {
+		t.Errorf("Rebind(sqlite) = %q, want %q", got, want)
+	}
+}
+
+func TestOpen_SQLiteMigrations(t *testing.T) {
+	url := "sqlite://" + filepath.Join(t.TempDir(), "whisper.db")
+	if err := RunMigrations(url, filepath.Join("..", "..", "migrations")); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+	db, dialect, err := Open(url)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+	if dialect != SQLite {
+		t.Errorf("dialect = %q, want sqlite", dialect)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM abuse_reports`).Scan(&n); err != nil {
+		t.Errorf("abuse_reports not created: %v", err)
+	}
+}
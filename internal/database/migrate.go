@@ -1,4 +1,6 @@
-// Package database provides PostgreSQL connection and migration helpers.
+// Package database provides connection and migration helpers for the SQL
+// database behind DATABASE_URL: PostgreSQL, or MySQL or SQLite for smaller
+// deployments.
 package database
 
 import (
@@ -6,16 +8,23 @@ import (
 	"fmt"
 
 	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/mysql"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
-// RunMigrations applies all pending up-migrations from the given directory
-// against the database specified by databaseURL. It returns nil if all
+// RunMigrations applies all pending up-migrations for the database specified
+// by databaseURL. migrationsPath is the root migrations directory; the
+// migrations for the URL's dialect are picked from it. It returns nil if all
 // migrations succeed or if there are no new migrations to apply.
 func RunMigrations(databaseURL, migrationsPath string) error {
+	dialect, err := ParseDialect(databaseURL)
+	if err != nil {
+		return err
+	}
 	m, err := migrate.New(
-		fmt.Sprintf("file://%s", migrationsPath),
+		fmt.Sprintf("file://%s", migrationsDir(migrationsPath, dialect)),
 		databaseURL,
 	)
 	if err != nil {
@@ -0,0 +1,320 @@
+// Package featureflag gates new behaviours behind flags that can be rolled
+// out gradually. A flag is stored in Redis as a rollout: a percentage of
+// browser fingerprints plus an allowlist of fingerprints that always get it.
+//
+//	Key:   featureflag:flags (hash)
+//	Field: <flag name>
+//	Value: JSON Flag
+//
+// A rollout can also require a minimum app version, as declared in
+// client_info, for features older clients do not understand.
+//
+// A fingerprint's bucket is a hash of the flag name and the fingerprint, so
+// raising the percentage only ever adds fingerprints, and the same user sees
+// the same answer on every wsserver. A flag with no rollout stored falls
+// back to its default: the CONFIG_FILE "features" setting if present,
+// otherwise the built-in default in Known.
+//
+// Like the dynamic blocklist, every instance reloads the flags when a change
+// is announced on the bus and additionally polls Redis, so all servers
+// converge within seconds.
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/session"
+)
+
+const (
+	// FlagsKey is the Redis hash of stored rollouts, keyed by flag name.
+	FlagsKey = "featureflag:flags"
+
+	// MaxAllow caps the allowlist of a single flag.
+	MaxAllow = 1000
+
+	// pollInterval is the fallback reload period in case a bus change
+	// notification was missed.
+	pollInterval = 10 * time.Second
+)
+
+// Flags evaluated by the wsserver.
+const (
+	Reactions = "reactions"  // react: emoji reactions to partner messages
+	ShareCard = "share_card" // share_card: opt-in profile cards
+	Rematch   = "rematch"    // rematch_request and rematch_accept
+)
+
+// Known maps the flags the wsserver evaluates to their state when neither
+// Redis nor CONFIG_FILE says otherwise. Features that shipped before flags
+// existed default to on, so gating them changes nothing until a rollout is
+// stored.
+var Known = map[string]bool{
+	Reactions: true,
+	ShareCard: true,
+	Rematch:   true,
+}
+
+// validName matches flag names: lowercase words joined by underscores.
+var validName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Flag is the rollout of one feature. Fingerprints in Allow always have it;
+// of the rest, Percent (0-100) of fingerprints do. Sessions that have not
+// sent a fingerprint only get flags rolled out to 100%. With MinAppVersion
+// set, sessions whose client declared an older app version, or none, never
+// have it.
+type Flag struct {
+	Name          string   `json:"name"`
+	Percent       int      `json:"percent"`
+	Allow         []string `json:"allow,omitempty"`
+	MinAppVersion string   `json:"min_app_version,omitempty"`
+}
+
+// Validate checks the flag name, percentage and allowlist size.
+func (f Flag) Validate() error {
+	if !validName.MatchString(f.Name) {
+		return fmt.Errorf("featureflag: invalid flag name %q", f.Name)
+	}
+	if f.Percent < 0 || f.Percent > 100 {
+		return fmt.Errorf("featureflag: percent must be between 0 and 100")
+	}
+	if len(f.Allow) > MaxAllow {
+		return fmt.Errorf("featureflag: allowlist is limited to %d fingerprints", MaxAllow)
+	}
+	if f.MinAppVersion != "" && !session.ValidVersion(f.MinAppVersion) {
+		return fmt.Errorf("featureflag: min_app_version %q is not a version number", f.MinAppVersion)
+	}
+	return nil
+}
+
+// Enabled reports whether the flag is on for sess, which is nil before the
+// connection has a session.
+func (f Flag) Enabled(sess *session.Session) bool {
+	if f.MinAppVersion != "" && (sess == nil || !sess.ClientVersionAtLeast(f.MinAppVersion)) {
+		return false
+	}
+	var fingerprint string
+	if sess != nil {
+		fingerprint = sess.Fingerprint
+	}
+	switch {
+	case f.Percent >= 100:
+		return true
+	case fingerprint == "":
+		return false
+	case slices.Contains(f.Allow, fingerprint):
+		return true
+	}
+	return bucket(f.Name, fingerprint) < f.Percent
+}
+
+// bucket places fingerprint in one of 100 buckets for the named flag.
+// Hashing the name too means each flag rolls out to a different subset.
+func bucket(name, fingerprint string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(fingerprint))
+	return int(h.Sum32() % 100)
+}
+
+// Set holds the stored rollouts and the CONFIG_FILE defaults. Enabled and
+// Evaluate are lock-free: both are swapped atomically on reload.
+type Set struct {
+	rdb      *redis.Client
+	bus      messaging.Bus
+	flags    atomic.Pointer[map[string]Flag]
+	defaults atomic.Pointer[map[string]bool]
+	onChange atomic.Pointer[func()]
+}
+
+// NewSet creates a Set with no rollouts stored. Call Start to load them and
+// begin watching for updates.
+func NewSet(rdb *redis.Client, bus messaging.Bus) *Set {
+	s := &Set{rdb: rdb, bus: bus}
+	s.flags.Store(&map[string]Flag{})
+	s.defaults.Store(&map[string]bool{})
+	return s
+}
+
+// Start loads the rollouts from Redis, subscribes to change notifications
+// and starts the fallback poll loop, which exits when ctx is cancelled. A
+// failed initial load is logged and the defaults stay in effect.
+func (s *Set) Start(ctx context.Context) error {
+	if err := s.Reload(ctx); err != nil {
+		log.Printf("[featureflag] initial load failed, using defaults: %v", err)
+	}
+
+	if err := s.bus.SubscribeFeatureFlagsUpdated(func() {
+		reloadCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := s.Reload(reloadCtx); err != nil {
+			log.Printf("[featureflag] reload failed: %v", err)
+		}
+	}); err != nil {
+		return fmt.Errorf("featureflag: subscribe updates: %w", err)
+	}
+
+	go s.pollLoop(ctx)
+	return nil
+}
+
+// pollLoop periodically reloads the rollouts from Redis.
+func (s *Set) pollLoop(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				log.Printf("[featureflag] poll failed: %v", err)
+			}
+		}
+	}
+}
+
+// OnChange registers fn to be called after a reload that changed any
+// rollout, e.g. to send connected clients their features again.
+func (s *Set) OnChange(fn func()) {
+	s.onChange.Store(&fn)
+}
+
+// Reload reads the rollouts from Redis and atomically replaces them.
+// Unreadable entries are logged and skipped.
+func (s *Set) Reload(ctx context.Context) error {
+	raw, err := s.rdb.HGetAll(ctx, FlagsKey).Result()
+	if err != nil {
+		return fmt.Errorf("featureflag: load flags: %w", err)
+	}
+	flags := make(map[string]Flag, len(raw))
+	for name, data := range raw {
+		var f Flag
+		if err := json.Unmarshal([]byte(data), &f); err != nil {
+			log.Printf("[featureflag] skipping unreadable flag %q: %v", name, err)
+			continue
+		}
+		f.Name = name
+		flags[name] = f
+	}
+
+	old := s.flags.Swap(&flags)
+	if fn := s.onChange.Load(); fn != nil && !maps.EqualFunc(*old, flags, equalFlags) {
+		(*fn)()
+	}
+	return nil
+}
+
+// SetDefaults replaces the CONFIG_FILE defaults, which apply to flags with
+// no rollout stored. It does not call OnChange: the config reload that
+// calls it sends clients their config again anyway.
+func (s *Set) SetDefaults(defaults map[string]bool) {
+	d := maps.Clone(defaults)
+	if d == nil {
+		d = map[string]bool{}
+	}
+	s.defaults.Store(&d)
+}
+
+// List returns the stored rollouts, sorted by name.
+func (s *Set) List(ctx context.Context) ([]Flag, error) {
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+	flags := *s.flags.Load()
+	list := make([]Flag, 0, len(flags))
+	for _, f := range flags {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Put stores the rollout of a flag on every instance, replacing any
+// previous one.
+func (s *Set) Put(ctx context.Context, f Flag) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("featureflag: marshal flag: %w", err)
+	}
+	if err := s.rdb.HSet(ctx, FlagsKey, f.Name, data).Err(); err != nil {
+		return fmt.Errorf("featureflag: store flag: %w", err)
+	}
+	return s.announce(ctx)
+}
+
+// Delete removes the rollout of a flag on every instance, returning it to
+// its default. It reports whether a rollout was stored.
+func (s *Set) Delete(ctx context.Context, name string) (bool, error) {
+	n, err := s.rdb.HDel(ctx, FlagsKey, name).Result()
+	if err != nil {
+		return false, fmt.Errorf("featureflag: delete flag: %w", err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+	return true, s.announce(ctx)
+}
+
+// announce reloads the local flags and notifies the other instances.
+func (s *Set) announce(ctx context.Context) error {
+	if err := s.Reload(ctx); err != nil {
+		return err
+	}
+	if err := s.bus.PublishFeatureFlagsUpdated(); err != nil {
+		return fmt.Errorf("featureflag: publish update: %w", err)
+	}
+	return nil
+}
+
+// Enabled reports whether the named flag is on for sess, which may be nil.
+func (s *Set) Enabled(name string, sess *session.Session) bool {
+	if f, ok := (*s.flags.Load())[name]; ok {
+		return f.Enabled(sess)
+	}
+	if on, ok := (*s.defaults.Load())[name]; ok {
+		return on
+	}
+	return Known[name]
+}
+
+// Evaluate returns the state of every known, configured and stored flag
+// for sess, as sent to clients in the config message.
+func (s *Set) Evaluate(sess *session.Session) map[string]bool {
+	flags, defaults := *s.flags.Load(), *s.defaults.Load()
+	features := make(map[string]bool, len(Known)+len(defaults)+len(flags))
+	for name := range Known {
+		features[name] = s.Enabled(name, sess)
+	}
+	for name := range defaults {
+		features[name] = s.Enabled(name, sess)
+	}
+	for name, f := range flags {
+		features[name] = f.Enabled(sess)
+	}
+	return features
+}
+
+// equalFlags reports whether two rollouts are the same.
+func equalFlags(a, b Flag) bool {
+	return a.Name == b.Name && a.Percent == b.Percent && slices.Equal(a.Allow, b.Allow) &&
+		a.MinAppVersion == b.MinAppVersion
+}
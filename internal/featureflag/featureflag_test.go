@@ -0,0 +1,159 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestFlag_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		flag    Flag
+		wantErr bool
+	}{
+		{"valid", Flag{Name: "group_chat", Percent: 10}, false},
+		{"full rollout", Flag{Name: "reactions", Percent: 100}, false},
+		{"uppercase name", Flag{Name: "GroupChat"}, true},
+		{"empty name", Flag{}, true},
+		{"negative percent", Flag{Name: "x", Percent: -1}, true},
+		{"percent over 100", Flag{Name: "x", Percent: 101}, true},
+		{"allowlist too long", Flag{Name: "x", Allow: make([]string, MaxAllow+1)}, true},
+		{"min version", Flag{Name: "x", MinAppVersion: "2.5"}, false},
+		{"bad min version", Flag{Name: "x", MinAppVersion: "latest"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.flag.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fp returns a session that has sent fingerprint.
+func fp(fingerprint string) *session.Session {
+	return &session.Session{Fingerprint: fingerprint}
+}
+
+func TestFlag_Enabled(t *testing.T) {
+	f := Flag{Name: "group_chat", Percent: 0, Allow: []string{"fp-tester"}}
+	if !f.Enabled(fp("fp-tester")) {
+		t.Error("expected an allowlisted fingerprint to have the flag")
+	}
+	if f.Enabled(fp("fp-other")) {
+		t.Error("expected a 0% rollout to exclude other fingerprints")
+	}
+	if f.Enabled(nil) {
+		t.Error("expected a session without a fingerprint not to have a partial rollout")
+	}
+	if !(Flag{Name: "group_chat", Percent: 100}).Enabled(nil) {
+		t.Error("expected a 100% rollout to include sessions without a fingerprint")
+	}
+}
+
+func TestFlag_EnabledMinAppVersion(t *testing.T) {
+	f := Flag{Name: "group_chat", Percent: 100, MinAppVersion: "2.5"}
+	tests := []struct {
+		sess *session.Session
+		want bool
+	}{
+		{&session.Session{AppVersion: "2.5.0"}, true},
+		{&session.Session{AppVersion: "3.0"}, true},
+		{&session.Session{AppVersion: "2.4.9"}, false},
+		{&session.Session{}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := f.Enabled(tt.sess); got != tt.want {
+			t.Errorf("Enabled(%+v) = %v, want %v", tt.sess, got, tt.want)
+		}
+	}
+}
+
+func TestFlag_EnabledRollsOutStably(t *testing.T) {
+	const n = 10000
+	enabled := func(percent int) map[string]bool {
+		f := Flag{Name: "group_chat", Percent: percent}
+		on := map[string]bool{}
+		for i := 0; i < n; i++ {
+			fp := fmt.Sprintf("fp-%d", i)
+			if f.Enabled(&session.Session{Fingerprint: fp}) {
+				on[fp] = true
+			}
+		}
+		return on
+	}
+
+	ten, fifty := enabled(10), enabled(50)
+	if len(ten) < n*8/100 || len(ten) > n*12/100 {
+		t.Errorf("10%% rollout enabled %d of %d fingerprints", len(ten), n)
+	}
+	for fp := range ten {
+		if !fifty[fp] {
+			t.Fatalf("raising the rollout to 50%% dropped %s", fp)
+		}
+	}
+}
+
+func newTestSet(t *testing.T) *Set {
+	t.Helper()
+	rdb := testutil.Redis(t)
+	bus := messaging.NewRedisBus(rdb, messaging.DefaultRedisBusConfig())
+	t.Cleanup(bus.Close)
+	return NewSet(rdb, bus)
+}
+
+func TestSet_PutDeleteAndDefaults(t *testing.T) {
+	s := newTestSet(t)
+	ctx := context.Background()
+
+	if !s.Enabled(Reactions, fp("fp-1")) {
+		t.Fatal("expected a known flag to use its built-in default")
+	}
+	if s.Enabled("group_chat", fp("fp-1")) {
+		t.Fatal("expected an unknown flag to be off")
+	}
+
+	s.SetDefaults(map[string]bool{Reactions: false, "group_chat": true})
+	if s.Enabled(Reactions, fp("fp-1")) || !s.Enabled("group_chat", fp("fp-1")) {
+		t.Fatal("expected CONFIG_FILE defaults to override built-in ones")
+	}
+
+	changed := 0
+	s.OnChange(func() { changed++ })
+	if err := s.Put(ctx, Flag{Name: Reactions, Allow: []string{"fp-1"}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if changed != 1 {
+		t.Errorf("OnChange called %d times, want 1", changed)
+	}
+	if !s.Enabled(Reactions, fp("fp-1")) || s.Enabled(Reactions, fp("fp-2")) {
+		t.Error("expected the stored rollout to override the defaults")
+	}
+	if got := s.Evaluate(fp("fp-1")); !got[Reactions] || !got["group_chat"] || !got[ShareCard] {
+		t.Errorf("Evaluate = %v", got)
+	}
+
+	list, err := s.List(ctx)
+	if err != nil || len(list) != 1 || list[0].Name != Reactions {
+		t.Fatalf("List = %v, %v", list, err)
+	}
+	if changed != 1 {
+		t.Errorf("an unchanged reload called OnChange; %d calls", changed)
+	}
+
+	if found, err := s.Delete(ctx, Reactions); err != nil || !found {
+		t.Fatalf("Delete = %v, %v", found, err)
+	}
+	if found, _ := s.Delete(ctx, Reactions); found {
+		t.Error("expected a second delete to find nothing")
+	}
+	if s.Enabled(Reactions, fp("fp-1")) {
+		t.Error("expected a deleted rollout to fall back to the CONFIG_FILE default")
+	}
+}
@@ -0,0 +1,98 @@
+package geoip
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+
+	"github.com/whisper/chat-app/internal/metrics"
+)
+
+// DefaultCacheSize is the number of addresses Cache remembers unless told
+// otherwise. Reconnects and tabs from the same network hit the same few
+// addresses, so a small cache absorbs most lookups.
+const DefaultCacheSize = 10000
+
+var (
+	lookupsCached   = metrics.GeoIPLookupsTotal.WithLabelValues("cached")
+	lookupsFound    = metrics.GeoIPLookupsTotal.WithLabelValues("found")
+	lookupsNotFound = metrics.GeoIPLookupsTotal.WithLabelValues("not_found")
+	lookupsFailed   = metrics.GeoIPLookupsTotal.WithLabelValues("error")
+)
+
+// Cache is a Locator that remembers the locations of the most recently
+// looked up addresses. Failed lookups are not cached. It is safe for
+// concurrent use.
+type Cache struct {
+	locator Locator
+	size    int
+
+	mu      sync.Mutex
+	order   *list.List // most recently used first; values are *cacheEntry
+	entries map[netip.Addr]*list.Element
+}
+
+type cacheEntry struct {
+	ip  netip.Addr
+	loc Location
+}
+
+// NewCache wraps locator with a cache of up to size addresses. A size below
+// one means DefaultCacheSize.
+func NewCache(locator Locator, size int) *Cache {
+	if size < 1 {
+		size = DefaultCacheSize
+	}
+	return &Cache{
+		locator: locator,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[netip.Addr]*list.Element),
+	}
+}
+
+// Locate returns the cached location of ip, looking it up on a miss.
+func (c *Cache) Locate(ip netip.Addr) (Location, error) {
+	ip = ip.Unmap()
+	c.mu.Lock()
+	if el, ok := c.entries[ip]; ok {
+		c.order.MoveToFront(el)
+		loc := el.Value.(*cacheEntry).loc
+		c.mu.Unlock()
+		lookupsCached.Inc()
+		return loc, nil
+	}
+	c.mu.Unlock()
+
+	loc, err := c.locator.Locate(ip)
+	if err != nil {
+		lookupsFailed.Inc()
+		return Location{}, err
+	}
+	if loc.Known() {
+		lookupsFound.Inc()
+	} else {
+		lookupsNotFound.Inc()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[ip]; ok {
+		// Looked up concurrently; keep the first answer.
+		c.order.MoveToFront(el)
+		return loc, nil
+	}
+	c.entries[ip] = c.order.PushFront(&cacheEntry{ip: ip, loc: loc})
+	if c.order.Len() > c.size {
+		oldest := c.order.Remove(c.order.Back()).(*cacheEntry)
+		delete(c.entries, oldest.ip)
+	}
+	return loc, nil
+}
+
+// Len returns the number of cached addresses.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
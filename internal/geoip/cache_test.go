@@ -0,0 +1,78 @@
+package geoip
+
+import (
+	"errors"
+	"net/netip"
+	"path/filepath"
+	"testing"
+)
+
+// fakeLocator answers from a fixed table and counts lookups.
+type fakeLocator struct {
+	table   map[netip.Addr]Location
+	fail    bool
+	lookups int
+}
+
+func (f *fakeLocator) Locate(ip netip.Addr) (Location, error) {
+	f.lookups++
+	if f.fail {
+		return Location{}, errors.New("broken database")
+	}
+	return f.table[ip], nil
+}
+
+func TestCache_RemembersRecentAddresses(t *testing.T) {
+	de := netip.MustParseAddr("203.0.113.1")
+	fr := netip.MustParseAddr("203.0.113.2")
+	private := netip.MustParseAddr("10.0.0.1")
+	f := &fakeLocator{table: map[netip.Addr]Location{de: {Country: "DE", Region: "BY"}, fr: {Country: "FR"}}}
+	c := NewCache(f, 2)
+
+	for i := 0; i < 3; i++ {
+		if loc, err := c.Locate(de); err != nil || loc != (Location{Country: "DE", Region: "BY"}) {
+			t.Fatalf("Locate(de) = %+v, %v", loc, err)
+		}
+	}
+	// An IPv4-mapped IPv6 address is the same client.
+	if loc, _ := c.Locate(netip.MustParseAddr("::ffff:203.0.113.1")); loc.Country != "DE" {
+		t.Errorf("mapped address located in %q, want DE", loc.Country)
+	}
+	if f.lookups != 1 {
+		t.Errorf("%d lookups for one address, want 1", f.lookups)
+	}
+
+	// Unknown addresses are cached too; the least recently used is evicted.
+	if loc, _ := c.Locate(private); loc.Known() {
+		t.Errorf("private address located in %q", loc.Country)
+	}
+	c.Locate(fr)
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+	c.Locate(de)
+	if f.lookups != 4 {
+		t.Errorf("%d lookups, want de looked up again after eviction", f.lookups)
+	}
+}
+
+func TestCache_DoesNotCacheErrors(t *testing.T) {
+	ip := netip.MustParseAddr("203.0.113.1")
+	f := &fakeLocator{fail: true}
+	c := NewCache(f, 0)
+
+	if _, err := c.Locate(ip); err == nil {
+		t.Fatal("expected the lookup error")
+	}
+	f.fail = false
+	f.table = map[netip.Addr]Location{ip: {Country: "NL"}}
+	if loc, err := c.Locate(ip); err != nil || loc.Country != "NL" {
+		t.Errorf("Locate after recovery = %+v, %v, want NL", loc, err)
+	}
+}
+
+func TestOpen_MissingDatabase(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("expected an error for a missing database")
+	}
+}
@@ -0,0 +1,83 @@
+// Package geoip locates client IP addresses by country and region. Lookups
+// go through the Locator interface so wsserver does not depend on the
+// database format; Open reads a MaxMind GeoIP2 or GeoLite2 City or Country
+// database (.mmdb), and Cache keeps recent answers in memory.
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is where an IP address is registered. Both fields are empty for
+// addresses the database does not know, such as private ones.
+type Location struct {
+	Country string // ISO 3166-1 alpha-2 country code, e.g. "DE"
+	Region  string // ISO 3166-2 subdivision code without the country, e.g. "BY"; empty in Country databases
+}
+
+// Known reports whether the country is known.
+func (l Location) Known() bool {
+	return l.Country != ""
+}
+
+// Locator looks up the location of an IP address.
+type Locator interface {
+	Locate(ip netip.Addr) (Location, error)
+}
+
+// Reader is a Locator backed by a MaxMind database file. It is safe for
+// concurrent use.
+type Reader struct {
+	db *maxminddb.Reader
+}
+
+// record holds the fields of a City or Country database entry we use.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+}
+
+// Open opens the MaxMind database at path.
+func Open(path string) (*Reader, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("geoip: open %s: %w", path, err)
+	}
+	return &Reader{db: db}, nil
+}
+
+// Locate returns the location of ip, or an empty Location if the database
+// has no entry for it.
+func (r *Reader) Locate(ip netip.Addr) (Location, error) {
+	if !ip.IsValid() {
+		return Location{}, nil
+	}
+	var rec record
+	if err := r.db.Lookup(net.IP(ip.Unmap().AsSlice()), &rec); err != nil {
+		return Location{}, fmt.Errorf("geoip: lookup %s: %w", ip, err)
+	}
+	loc := Location{Country: rec.Country.ISOCode}
+	if len(rec.Subdivisions) > 0 {
+		loc.Region = rec.Subdivisions[0].ISOCode
+	}
+	return loc, nil
+}
+
+// DatabaseType returns the type recorded in the database metadata, e.g.
+// "GeoLite2-City".
+func (r *Reader) DatabaseType() string {
+	return r.db.Metadata.DatabaseType
+}
+
+// Close releases the database file.
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,120 @@
+// Package health actively checks the dependencies of a service for its
+// /health/deep endpoint. Unlike the lightweight /health endpoints used by
+// load balancers, every request makes a round trip to each dependency and
+// reports its latency, so an operator can tell which one is down or slow.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/whisper/chat-app/internal/messaging"
+)
+
+// checkTimeout bounds each dependency check.
+const checkTimeout = 2 * time.Second
+
+// Status values of a Report.
+const (
+	StatusOK        = "ok"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Check is a named dependency check.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of one Check.
+type Result struct {
+	Name      string  `json:"name"`
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the response of /health/deep. Status is StatusOK only if every
+// check passed.
+type Report struct {
+	Service string   `json:"service"`
+	Status  string   `json:"status"`
+	Checks  []Result `json:"checks"`
+}
+
+// Run runs the checks concurrently, each bounded by checkTimeout, and
+// returns their results in the order given.
+func Run(ctx context.Context, service string, checks []Check) Report {
+	report := Report{Service: service, Status: StatusOK, Checks: make([]Result, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			start := time.Now()
+			err := c.Run(checkCtx)
+			result := Result{
+				Name:      c.Name,
+				OK:        err == nil,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			report.Checks[i] = result
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range report.Checks {
+		if !r.OK {
+			report.Status = StatusUnhealthy
+		}
+	}
+	return report
+}
+
+// Handler serves the Report of the checks as JSON, with status 503 if any
+// check failed.
+func Handler(service string, checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), service, checks)
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status != StatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Redis checks that Redis answers a ping.
+func Redis(rdb *redis.Client) Check {
+	return Check{Name: "redis", Run: func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}}
+}
+
+// Bus checks that the message bus server answers. It is named after the
+// bus: "nats", or "redis_bus" for a RedisBus.
+func Bus(bus messaging.Bus) Check {
+	name := "nats"
+	if _, ok := bus.(*messaging.RedisBus); ok {
+		name = "redis_bus"
+	}
+	return Check{Name: name, Run: bus.Ping}
+}
+
+// Database checks that the database named name, e.g. "postgres", accepts
+// connections.
+func Database(name string, db *sql.DB) Check {
+	return Check{Name: name, Run: db.PingContext}
+}
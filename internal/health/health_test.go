@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRun_ReportsEachCheckInOrder(t *testing.T) {
+	report := Run(context.Background(), "matcher", []Check{
+		{Name: "redis", Run: func(context.Context) error { return nil }},
+		{Name: "nats", Run: func(context.Context) error { return errors.New("no servers available") }},
+	})
+
+	if report.Service != "matcher" || report.Status != StatusUnhealthy {
+		t.Fatalf("expected unhealthy matcher, got %s %s", report.Service, report.Status)
+	}
+	if len(report.Checks) != 2 || report.Checks[0].Name != "redis" || report.Checks[1].Name != "nats" {
+		t.Fatalf("expected redis then nats, got %+v", report.Checks)
+	}
+	if !report.Checks[0].OK || report.Checks[0].Error != "" {
+		t.Errorf("expected redis ok, got %+v", report.Checks[0])
+	}
+	if report.Checks[1].OK || report.Checks[1].Error != "no servers available" {
+		t.Errorf("expected nats error, got %+v", report.Checks[1])
+	}
+}
+
+func TestRun_BoundsSlowChecks(t *testing.T) {
+	start := time.Now()
+	report := Run(context.Background(), "wsserver", []Check{
+		{Name: "postgres", Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	})
+
+	if elapsed := time.Since(start); elapsed > checkTimeout+time.Second {
+		t.Fatalf("check ran for %s", elapsed)
+	}
+	if report.Status != StatusUnhealthy || report.Checks[0].LatencyMs < float64(checkTimeout.Milliseconds()) {
+		t.Errorf("expected a timed out check, got %+v", report)
+	}
+}
+
+func TestHandler_StatusCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"healthy", nil, http.StatusOK},
+		{"unhealthy", errors.New("down"), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Handler("moderator", Check{Name: "redis", Run: func(context.Context) error { return tt.err }})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/deep", nil))
+
+			if rec.Code != tt.code {
+				t.Errorf("status = %d, want %d", rec.Code, tt.code)
+			}
+			var report Report
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil || report.Service != "moderator" {
+				t.Errorf("expected a moderator report, got %+v (%v)", report, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Package logging adds a process-wide log level on top of the standard log
+// package. Existing log.Printf call sites always print; chatty per-connection
+// lines go through Debugf so they can be silenced at runtime.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is a log severity. Higher values are more severe.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// String returns the lowercase name of the level.
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+// ParseLevel parses a level name ("debug", "info", "warn", "error").
+// The empty string maps to LevelInfo.
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return LevelInfo, nil
+	}
+	for l, name := range levelNames {
+		if name == s {
+			return l, nil
+		}
+	}
+	if s == "warning" {
+		return LevelWarn, nil
+	}
+	return 0, fmt.Errorf("logging: unknown level %q", s)
+}
+
+var current atomic.Int32
+
+func init() {
+	current.Store(int32(LevelInfo))
+}
+
+// SetLevel changes the process-wide log level.
+func SetLevel(l Level) {
+	current.Store(int32(l))
+}
+
+// CurrentLevel returns the process-wide log level.
+func CurrentLevel() Level {
+	return Level(current.Load())
+}
+
+// Enabled reports whether messages at level l are currently logged.
+func Enabled(l Level) bool {
+	return l >= CurrentLevel()
+}
+
+// Debugf logs through the standard logger when the debug level is enabled.
+func Debugf(format string, args ...interface{}) {
+	if Enabled(LevelDebug) {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,39 @@
+package logging
+
+import "testing"
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]Level{
+		"":        LevelInfo,
+		"debug":   LevelDebug,
+		"INFO":    LevelInfo,
+		" warn ":  LevelWarn,
+		"warning": LevelWarn,
+		"error":   LevelError,
+	}
+	for in, want := range cases {
+		got, err := ParseLevel(in)
+		if err != nil {
+			t.Errorf("ParseLevel(%q): unexpected error: %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseLevel(%q) = %s, want %s", in, got, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	defer SetLevel(CurrentLevel())
+
+	SetLevel(LevelWarn)
+	if Enabled(LevelInfo) {
+		t.Error("info should be disabled at warn level")
+	}
+	if !Enabled(LevelError) {
+		t.Error("error should be enabled at warn level")
+	}
+}
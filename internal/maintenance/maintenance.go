@@ -0,0 +1,232 @@
+// Package maintenance switches the service into maintenance mode on every
+// instance at once. While it is on, new connections are told when the
+// maintenance is expected to end, find_match is refused and the matcher
+// stops pairing, but chats already running continue until they finish.
+//
+// The state is stored in Redis so servers that start or restart during the
+// maintenance pick it up:
+//
+//	Key:   maintenance:state (string)
+//	Value: JSON State
+//
+// Like feature flags, every instance reloads the state when a change is
+// announced on the bus and additionally polls Redis, so all servers converge
+// within seconds.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/metrics"
+)
+
+const (
+	// StateKey is the Redis key holding the State while maintenance is on.
+	StateKey = "maintenance:state"
+
+	// MaxMessageChars caps the operator message shown to clients.
+	MaxMessageChars = 280
+
+	// RetryAfter is the reopen hint given to clients when the maintenance
+	// has no ETA, or has overrun it.
+	RetryAfter = time.Minute
+
+	// pollInterval is the fallback reload period in case a bus change
+	// notification was missed.
+	pollInterval = 10 * time.Second
+)
+
+// State describes a maintenance period. The zero value is maintenance off.
+type State struct {
+	Active    bool   `json:"active"`
+	Message   string `json:"message,omitempty"`
+	ETA       int64  `json:"eta,omitempty"` // unix seconds the maintenance is expected to end; 0 if unknown
+	StartedAt int64  `json:"started_at,omitempty"`
+	Actor     string `json:"actor,omitempty"`
+}
+
+// Validate checks the message length and ETA of a State being switched on.
+func (s State) Validate() error {
+	if utf8.RuneCountInString(s.Message) > MaxMessageChars {
+		return fmt.Errorf("maintenance: message is limited to %d characters", MaxMessageChars)
+	}
+	if s.ETA < 0 {
+		return errors.New("maintenance: eta must be a unix timestamp")
+	}
+	return nil
+}
+
+// ReopenAt returns when clients should try again: the ETA, or RetryAfter
+// from now when there is none or it has passed.
+func (s State) ReopenAt(now time.Time) time.Time {
+	if eta := time.Unix(s.ETA, 0); s.ETA > 0 && eta.After(now) {
+		return eta
+	}
+	return now.Add(RetryAfter)
+}
+
+// Mode holds the maintenance state last loaded from Redis. State and Active
+// are lock-free.
+type Mode struct {
+	rdb      *redis.Client
+	bus      messaging.Bus
+	state    atomic.Pointer[State]
+	onChange atomic.Pointer[func(State)]
+}
+
+// NewMode creates a Mode with maintenance off. Call Start to load the stored
+// state and begin watching for updates.
+func NewMode(rdb *redis.Client, bus messaging.Bus) *Mode {
+	m := &Mode{rdb: rdb, bus: bus}
+	m.state.Store(&State{})
+	return m
+}
+
+// Start loads the state from Redis, subscribes to change notifications and
+// starts the fallback poll loop, which exits when ctx is cancelled. A failed
+// initial load is logged and maintenance stays off until the next poll.
+func (m *Mode) Start(ctx context.Context) error {
+	if err := m.Reload(ctx); err != nil {
+		log.Printf("[maintenance] initial load failed, assuming off: %v", err)
+	}
+
+	if err := m.bus.SubscribeMaintenanceUpdated(func() {
+		reloadCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := m.Reload(reloadCtx); err != nil {
+			log.Printf("[maintenance] reload failed: %v", err)
+		}
+	}); err != nil {
+		return fmt.Errorf("maintenance: subscribe updates: %w", err)
+	}
+
+	go m.pollLoop(ctx)
+	return nil
+}
+
+// pollLoop periodically reloads the state from Redis.
+func (m *Mode) pollLoop(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Reload(ctx); err != nil {
+				log.Printf("[maintenance] poll failed: %v", err)
+			}
+		}
+	}
+}
+
+// OnChange registers fn to be called with the new state after a reload that
+// changed it, e.g. to tell connected clients.
+func (m *Mode) OnChange(fn func(State)) {
+	m.onChange.Store(&fn)
+}
+
+// Reload reads the state from Redis and atomically replaces it. A missing
+// key means maintenance is off; an unreadable one is an error and the
+// current state is kept.
+func (m *Mode) Reload(ctx context.Context) error {
+	var state State
+	data, err := m.rdb.Get(ctx, StateKey).Bytes()
+	switch {
+	case errors.Is(err, redis.Nil):
+	case err != nil:
+		return fmt.Errorf("maintenance: load state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("maintenance: unreadable state: %w", err)
+		}
+	}
+
+	old := m.state.Swap(&state)
+	if state.Active {
+		metrics.MaintenanceActive.Set(1)
+	} else {
+		metrics.MaintenanceActive.Set(0)
+	}
+	if *old != state {
+		if state.Active {
+			log.Printf("[maintenance] on (eta=%d actor=%s)", state.ETA, state.Actor)
+		} else if old.Active {
+			log.Printf("[maintenance] off")
+		}
+		if fn := m.onChange.Load(); fn != nil {
+			(*fn)(state)
+		}
+	}
+	return nil
+}
+
+// State returns the state last loaded.
+func (m *Mode) State() State {
+	return *m.state.Load()
+}
+
+// Active reports whether maintenance mode is on. A nil Mode is never on.
+func (m *Mode) Active() bool {
+	return m != nil && m.state.Load().Active
+}
+
+// Enable switches maintenance mode on for every instance, replacing the
+// message and ETA of one already in progress. StartedAt is set to now
+// unless maintenance was already on.
+func (m *Mode) Enable(ctx context.Context, state State, now time.Time) (State, error) {
+	if err := state.Validate(); err != nil {
+		return State{}, err
+	}
+	if err := m.Reload(ctx); err != nil {
+		return State{}, err
+	}
+	state.Active = true
+	state.StartedAt = now.Unix()
+	if current := m.State(); current.Active {
+		state.StartedAt = current.StartedAt
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return State{}, fmt.Errorf("maintenance: marshal state: %w", err)
+	}
+	if err := m.rdb.Set(ctx, StateKey, data, 0).Err(); err != nil {
+		return State{}, fmt.Errorf("maintenance: store state: %w", err)
+	}
+	return state, m.announce(ctx)
+}
+
+// Disable switches maintenance mode off for every instance. It reports
+// whether maintenance was on.
+func (m *Mode) Disable(ctx context.Context) (bool, error) {
+	n, err := m.rdb.Del(ctx, StateKey).Result()
+	if err != nil {
+		return false, fmt.Errorf("maintenance: delete state: %w", err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+	return true, m.announce(ctx)
+}
+
+// announce reloads the local state and notifies the other instances.
+func (m *Mode) announce(ctx context.Context) error {
+	if err := m.Reload(ctx); err != nil {
+		return err
+	}
+	if err := m.bus.PublishMaintenanceUpdated(); err != nil {
+		return fmt.Errorf("maintenance: publish update: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package maintenance
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestState_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   State
+		wantErr bool
+	}{
+		{"empty", State{}, false},
+		{"message and eta", State{Message: "Upgrading the database", ETA: 1_700_000_000}, false},
+		{"message too long", State{Message: strings.Repeat("x", MaxMessageChars+1)}, true},
+		{"negative eta", State{ETA: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.state.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestState_ReopenAt(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	if got := (State{ETA: now.Unix() + 600}).ReopenAt(now); got.Unix() != now.Unix()+600 {
+		t.Errorf("ReopenAt = %v, want the ETA", got)
+	}
+	for _, s := range []State{{}, {ETA: now.Unix() - 60}} {
+		if got := s.ReopenAt(now); !got.Equal(now.Add(RetryAfter)) {
+			t.Errorf("ReopenAt(%+v) = %v, want RetryAfter from now", s, got)
+		}
+	}
+}
+
+func TestMode_EnableDisable(t *testing.T) {
+	rdb := testutil.Redis(t)
+	bus := messaging.NewRedisBus(rdb, messaging.DefaultRedisBusConfig())
+	t.Cleanup(bus.Close)
+	m := NewMode(rdb, bus)
+	other := NewMode(rdb, bus)
+	ctx := context.Background()
+
+	var changes []State
+	m.OnChange(func(s State) { changes = append(changes, s) })
+
+	start := time.Unix(1_700_000_000, 0)
+	state, err := m.Enable(ctx, State{Message: "Back soon", ETA: start.Unix() + 600, Actor: "ops"}, start)
+	if err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	if !state.Active || state.StartedAt != start.Unix() || !m.Active() {
+		t.Fatalf("Enable = %+v, Active = %t", state, m.Active())
+	}
+
+	// Another instance picks the state up from Redis.
+	if err := other.Reload(ctx); err != nil || other.State() != state {
+		t.Fatalf("other instance loaded %+v, %v; want %+v", other.State(), err, state)
+	}
+
+	// Extending the ETA keeps the original start.
+	state, err = m.Enable(ctx, State{ETA: start.Unix() + 1200}, start.Add(time.Minute))
+	if err != nil || state.StartedAt != start.Unix() {
+		t.Fatalf("extended state = %+v, %v; want StartedAt kept", state, err)
+	}
+
+	if found, err := m.Disable(ctx); err != nil || !found {
+		t.Fatalf("Disable = %v, %v", found, err)
+	}
+	if found, _ := m.Disable(ctx); found {
+		t.Error("expected a second disable to find nothing")
+	}
+	if m.Active() {
+		t.Error("still active after Disable")
+	}
+	if len(changes) != 3 || changes[2].Active {
+		t.Errorf("OnChange saw %+v, want on, extended, off", changes)
+	}
+}
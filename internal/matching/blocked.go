@@ -0,0 +1,41 @@
+package matching
+
+import (
+	"context"
+	"log"
+)
+
+// excludedPair reports whether the queued user and the candidate must not be
+// paired: because of a personal block between their fingerprints, or because
+// only one of them is flagged as a probable bot, as flagged fingerprints are
+// only paired with each other. Lookup errors fail open so a Redis hiccup
+// never stalls matching.
+func (q *Queue) excludedPair(ctx context.Context, entry *QueueEntry, candidateID string) bool {
+	if entry == nil || entry.Fingerprint == "" {
+		return false
+	}
+	fp, err := q.rdb.HGet(ctx, keySessionPrefix+candidateID, "fingerprint").Result()
+	if err != nil || fp == "" {
+		return false
+	}
+	blocked, err := q.blocks.IsBlocked(ctx, entry.Fingerprint, fp)
+	if err != nil {
+		log.Printf("[matcher] block list check %s/%s: %v (failing open)", entry.SessionID, candidateID, err)
+		return false
+	}
+	if blocked {
+		return true
+	}
+	return q.flaggedBot(ctx, entry.Fingerprint) != q.flaggedBot(ctx, fp)
+}
+
+// flaggedBot reports whether fingerprint is flagged as a probable bot. A
+// failed lookup counts as not flagged.
+func (q *Queue) flaggedBot(ctx context.Context, fingerprint string) bool {
+	signals, err := q.bots.Flagged(ctx, fingerprint)
+	if err != nil {
+		log.Printf("[matcher] bot flag check fp=%s: %v (failing open)", fingerprint, err)
+		return false
+	}
+	return signals != nil
+}
@@ -0,0 +1,147 @@
+package matching
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The queue is sharded into buckets: the exact set of every distinct
+// interest list and the top-K set of every distinct top-K list, all listed
+// in keyBucketIndex. A user waiting for an exact partner can only find one
+// in their own buckets, so a matching pass pairs each bucket from a random
+// sample of its members instead of looking up every queued user in turn.
+// Only entries old enough for the broader tiers are scanned one by one.
+
+const (
+	// bucketSample is how many members of a bucket are paired at a time.
+	bucketSample = 64
+
+	// bucketWorkers is how many buckets a matching pass pairs concurrently.
+	bucketWorkers = 8
+)
+
+// Buckets returns the keys of the exact and top-K buckets with at least two
+// members, exact buckets first. Empty buckets are dropped from the index; a
+// bucket refilled at the same moment is indexed again by the next enqueue
+// into it, and its lone member is still found by the aged-entry scan.
+func (q *Queue) Buckets(ctx context.Context) (exact, topK []string, err error) {
+	keys, err := q.rdb.SMembers(ctx, keyBucketIndex).Result()
+	if err != nil || len(keys) == 0 {
+		return nil, nil, err
+	}
+
+	pipe := q.rdb.Pipeline()
+	cards := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cards[i] = pipe.SCard(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	var empty []interface{}
+	for i, key := range keys {
+		switch n := cards[i].Val(); {
+		case n == 0:
+			empty = append(empty, key)
+		case n < 2:
+		case strings.HasPrefix(key, keyExactPrefix):
+			exact = append(exact, key)
+		default:
+			topK = append(topK, key)
+		}
+	}
+	if len(empty) > 0 {
+		if err := q.rdb.SRem(ctx, keyBucketIndex, empty...).Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return exact, topK, nil
+}
+
+// PairBucket pairs up a random sample of up to bucketSample members of a
+// bucket, oldest in the queue first. Members no longer queued are removed
+// from the bucket, and members with a personal block between them are not
+// paired. It also returns the sample size: while it is bucketSample, the
+// bucket may have more members to pair once the pairs are dequeued.
+func (q *Queue) PairBucket(ctx context.Context, key string) ([]*MatchCandidate, int, error) {
+	members, err := q.rdb.SRandMemberN(ctx, key, bucketSample).Result()
+	if err != nil || len(members) < 2 {
+		return nil, len(members), err
+	}
+
+	// ZMSCORE reports members missing from the queue as 0.
+	scores, err := q.rdb.ZMScore(ctx, keyMatchQueue, members...).Result()
+	if err != nil {
+		return nil, len(members), err
+	}
+	score := make(map[string]float64, len(members))
+	queued := make([]string, 0, len(members))
+	var stale []interface{}
+	for i, sid := range members {
+		if scores[i] == 0 {
+			stale = append(stale, sid)
+			continue
+		}
+		score[sid] = scores[i]
+		queued = append(queued, sid)
+	}
+	if len(stale) > 0 {
+		if err := q.rdb.SRem(ctx, key, stale...).Err(); err != nil {
+			return nil, len(members), err
+		}
+	}
+	if len(queued) < 2 {
+		return nil, len(members), nil
+	}
+
+	entries, err := q.getEntries(ctx, queued)
+	if err != nil {
+		return nil, len(members), err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return score[entries[i].SessionID] < score[entries[j].SessionID]
+	})
+
+	exact := strings.HasPrefix(key, keyExactPrefix)
+	paired := make([]bool, len(entries))
+	var pairs []*MatchCandidate
+	for i, a := range entries {
+		if paired[i] {
+			continue
+		}
+		for j := i + 1; j < len(entries); j++ {
+			b := entries[j]
+			if paired[j] || q.excludedPair(ctx, a, b.SessionID) {
+				continue
+			}
+			shared := a.Interests // all interests match (exact)
+			if !exact {
+				shared = sharedInterests(a.Interests, b.Interests)
+			}
+			pairs = append(pairs, &MatchCandidate{
+				SessionA:        a.SessionID,
+				SessionB:        b.SessionID,
+				SharedInterests: shared,
+				Tier:            TierExact,
+			})
+			paired[i], paired[j] = true, true
+			break
+		}
+	}
+	return pairs, len(members), nil
+}
+
+// GetAgedQueued returns the session IDs queued with a score at or below
+// before (Unix milliseconds), oldest first. Priority entries are scored
+// MaxMatchTimeout early, so they are always included.
+func (q *Queue) GetAgedQueued(ctx context.Context, before float64) ([]string, error) {
+	return q.rdb.ZRangeByScore(ctx, keyMatchQueue, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprintf("%.0f", before),
+	}).Result()
+}
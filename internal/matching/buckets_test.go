@@ -0,0 +1,181 @@
+package matching
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/block"
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// enqueueAtTestUser enqueues a user joined ago before now.
+func enqueueAtTestUser(t testing.TB, q *Queue, ctx context.Context, sessionID string, interests []string, ago time.Duration) {
+	t.Helper()
+	joined := float64(time.Now().Add(-ago).UnixMilli())
+	if err := q.enqueueAt(ctx, sessionID, "", "", interests, joined, joined); err != nil {
+		t.Fatalf("failed to enqueue %s: %v", sessionID, err)
+	}
+}
+
+func TestBuckets_ListsBucketsWithTwoMembers(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueTestUser(t, q, ctx, "alice", []string{"music"})
+	enqueueTestUser(t, q, ctx, "bob", []string{"music"})
+	enqueueTestUser(t, q, ctx, "carol", []string{"gaming"})
+
+	exact, topK, err := q.Buckets(ctx)
+	if err != nil {
+		t.Fatalf("Buckets: %v", err)
+	}
+	want := keyExactPrefix + InterestsHash([]string{"music"})
+	if len(exact) != 1 || exact[0] != want || len(topK) != 0 {
+		t.Fatalf("expected exact bucket %s only, got %v %v", want, exact, topK)
+	}
+}
+
+func TestBuckets_PrunesEmptyBuckets(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueTestUser(t, q, ctx, "alice", []string{"music"})
+	if err := q.Dequeue(ctx, "alice"); err != nil {
+		t.Fatalf("Dequeue: %v", err)
+	}
+
+	if _, _, err := q.Buckets(ctx); err != nil {
+		t.Fatalf("Buckets: %v", err)
+	}
+	if n := q.rdb.SCard(ctx, keyBucketIndex).Val(); n != 0 {
+		t.Errorf("expected the empty bucket to be pruned, index has %d keys", n)
+	}
+}
+
+func TestPairBucket_PairsOldestFirst(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueAtTestUser(t, q, ctx, "newest", []string{"music"}, time.Second)
+	enqueueAtTestUser(t, q, ctx, "oldest", []string{"music"}, 3*time.Second)
+	enqueueAtTestUser(t, q, ctx, "middle", []string{"music"}, 2*time.Second)
+
+	pairs, sampled, err := q.PairBucket(ctx, keyExactPrefix+InterestsHash([]string{"music"}))
+	if err != nil {
+		t.Fatalf("PairBucket: %v", err)
+	}
+	if sampled != 3 || len(pairs) != 1 {
+		t.Fatalf("expected 1 pair from 3 members, got %d from %d", len(pairs), sampled)
+	}
+	if p := pairs[0]; p.SessionA != "oldest" || p.SessionB != "middle" || p.Tier != TierExact {
+		t.Errorf("expected oldest/middle exact pair, got %+v", p)
+	}
+}
+
+func TestPairBucket_RemovesStaleMembers(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueTestUser(t, q, ctx, "alice", []string{"music"})
+	enqueueTestUser(t, q, ctx, "bob", []string{"music"})
+	q.rdb.ZRem(ctx, keyMatchQueue, "bob")
+
+	key := keyExactPrefix + InterestsHash([]string{"music"})
+	pairs, _, err := q.PairBucket(ctx, key)
+	if err != nil {
+		t.Fatalf("PairBucket: %v", err)
+	}
+	if len(pairs) != 0 {
+		t.Fatalf("expected no pair with a stale member, got %+v", pairs[0])
+	}
+	if q.rdb.SIsMember(ctx, key, "bob").Val() {
+		t.Error("expected the stale member to be removed from the bucket")
+	}
+}
+
+func TestPairBucket_SkipsBlockedPair(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueWithFingerprint(t, q, ctx, "alice", "fp-alice", []string{"music"})
+	enqueueWithFingerprint(t, q, ctx, "bob", "fp-bob", []string{"music"})
+	if err := block.NewStore(q.rdb).Add(ctx, "fp-alice", "fp-bob", block.DefaultTTL); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+
+	pairs, _, err := q.PairBucket(ctx, keyExactPrefix+InterestsHash([]string{"music"}))
+	if err != nil {
+		t.Fatalf("PairBucket: %v", err)
+	}
+	if len(pairs) != 0 {
+		t.Errorf("expected blocked pair not to match, got %+v", pairs[0])
+	}
+}
+
+func TestPairBucket_TopKSharesIntersection(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	base := []string{"a", "b", "c", "d", "e"}
+	enqueueTestUser(t, q, ctx, "alice", append(base, "x"))
+	enqueueTestUser(t, q, ctx, "bob", append(base, "y"))
+
+	_, topK, err := q.Buckets(ctx)
+	if err != nil || len(topK) != 1 {
+		t.Fatalf("expected one top-K bucket, got %v (%v)", topK, err)
+	}
+	pairs, _, err := q.PairBucket(ctx, topK[0])
+	if err != nil || len(pairs) != 1 {
+		t.Fatalf("expected one pair, got %v (%v)", pairs, err)
+	}
+	if got := pairs[0].SharedInterests; len(got) != len(base) {
+		t.Errorf("expected the %d shared interests, got %v", len(base), got)
+	}
+}
+
+func TestProcessQueue_PairsBucketsAndScansAgedEntries(t *testing.T) {
+	rdb := testutil.Redis(t)
+	bus := messaging.NewRedisBus(rdb, messaging.DefaultRedisBusConfig())
+	t.Cleanup(bus.Close)
+	s := NewService(rdb, bus, DefaultTierConfig())
+	t.Cleanup(s.Stop)
+	q, ctx := s.queue, context.Background()
+
+	enqueueTestUser(t, q, ctx, "alice", []string{"music"})
+	enqueueTestUser(t, q, ctx, "bob", []string{"music"})
+	enqueueTestUser(t, q, ctx, "carol", []string{"gaming"})
+	enqueueAtTestUser(t, q, ctx, "dave", []string{"chess", "go"}, 15*time.Second)
+	enqueueAtTestUser(t, q, ctx, "erin", []string{"chess", "poker"}, 15*time.Second)
+
+	s.processQueue()
+
+	for sid, want := range map[string]bool{"alice": false, "bob": false, "carol": true, "dave": false, "erin": false} {
+		if queued, _ := q.IsQueued(ctx, sid); queued != want {
+			t.Errorf("%s queued = %v, want %v", sid, queued, want)
+		}
+	}
+}
+
+// benchmarkProcessQueue measures a matching pass over size queued users
+// who share no interests, so nobody is paired and every pass sees the full
+// queue. The users joined moments ago, as most of a large queue would have.
+func benchmarkProcessQueue(b *testing.B, size int) {
+	rdb := testutil.Redis(b)
+	bus := messaging.NewRedisBus(rdb, messaging.DefaultRedisBusConfig())
+	b.Cleanup(bus.Close)
+	s := NewService(rdb, bus, DefaultTierConfig())
+	b.Cleanup(s.Stop)
+	ctx := context.Background()
+
+	for i := 0; i < size; i++ {
+		tag := fmt.Sprintf("tag%d", i)
+		if err := s.queue.Enqueue(ctx, fmt.Sprintf("user-%d", i), []string{tag}); err != nil {
+			b.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.processQueue()
+	}
+}
+
+func BenchmarkProcessQueue_1k(b *testing.B)  { benchmarkProcessQueue(b, 1000) }
+func BenchmarkProcessQueue_10k(b *testing.B) { benchmarkProcessQueue(b, 10000) }
@@ -3,22 +3,35 @@ package matching
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/chat"
 	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/session"
 )
 
-const cleanupInterval = 5 * time.Second
+const (
+	cleanupInterval = 5 * time.Second
+
+	// acceptSweepInterval is how often pending chats are checked against
+	// their accept deadline, so an accepter waits at most this long past it.
+	acceptSweepInterval = time.Second
+)
 
 // StartCleanup runs background loops that remove stale entries from the
-// matching queue and expire pending chat sessions that exceeded their
-// accept deadline.
-func StartCleanup(ctx context.Context, queue *Queue, rdb *redis.Client, nats *messaging.NATSClient) {
+// matching queue, expire pending chat sessions that exceeded their accept
+// deadline, and decay interest popularity scores.
+// Removals and expired accept deadlines are recorded in events.
+func StartCleanup(ctx context.Context, queue *Queue, chats *chat.Store, events *EventLog, rdb *redis.Client, bus messaging.Bus) {
 	ticker := time.NewTicker(cleanupInterval)
 	defer ticker.Stop()
+	acceptTicker := time.NewTicker(acceptSweepInterval)
+	defer acceptTicker.Stop()
+	decayTicker := time.NewTicker(popularityDecayInterval)
+	defer decayTicker.Stop()
 
 	for {
 		select {
@@ -26,24 +39,32 @@ func StartCleanup(ctx context.Context, queue *Queue, rdb *redis.Client, nats *me
 			log.Println("[matcher] cleanup loop stopped")
 			return
 		case <-ticker.C:
-			cleanStaleEntries(ctx, queue, rdb)
-			cleanExpiredPendingChats(ctx, rdb, nats)
+			cleanStaleEntries(ctx, queue, events, rdb)
+		case <-acceptTicker.C:
+			sweepAcceptDeadlines(ctx, chats, events, rdb, bus)
+		case <-decayTicker.C:
+			if err := queue.DecayPopularity(ctx); err != nil {
+				log.Printf("[matcher] cleanup: failed to decay popularity: %v", err)
+			}
 		}
 	}
 }
 
 // cleanStaleEntries removes users from the match queue whose sessions
-// no longer exist in Redis (disconnected or expired).
-func cleanStaleEntries(ctx context.Context, queue *Queue, rdb *redis.Client) {
+// no longer exist in Redis (disconnected or expired), and entries owned by a
+// wsserver whose liveness key has expired (server crashed). Orphaned entries
+// are remembered so a reconnecting client can be told its match timed out.
+func cleanStaleEntries(ctx context.Context, queue *Queue, events *EventLog, rdb *redis.Client) {
 	sessionIDs, err := queue.GetAllQueued(ctx)
 	if err != nil {
 		log.Printf("[matcher] cleanup: failed to get queue: %v", err)
 		return
 	}
 
-	removed := 0
+	removed, orphaned := 0, 0
+	alive := make(map[string]bool) // server name -> liveness, cached per pass
 	for _, sid := range sessionIDs {
-		exists, err := rdb.Exists(ctx, "session:"+sid).Result()
+		exists, err := rdb.Exists(ctx, session.SessionPrefix+sid).Result()
 		if err != nil {
 			continue
 		}
@@ -52,49 +73,91 @@ func cleanStaleEntries(ctx context.Context, queue *Queue, rdb *redis.Client) {
 				log.Printf("[matcher] cleanup: failed to dequeue %s: %v", sid, err)
 			} else {
 				removed++
+				events.Record(ctx, sid, Event{Type: EventDropped, Detail: "session expired"})
 			}
+			continue
+		}
+
+		entry, err := queue.GetEntry(ctx, sid)
+		if err != nil || entry == nil || entry.Server == "" {
+			continue
+		}
+		serverAlive, checked := alive[entry.Server]
+		if !checked {
+			serverAlive, err = session.ServerAlive(ctx, rdb, entry.Server)
+			if err != nil {
+				continue
+			}
+			alive[entry.Server] = serverAlive
+		}
+		if serverAlive {
+			continue
+		}
+
+		if err := queue.Dequeue(ctx, sid); err != nil {
+			log.Printf("[matcher] cleanup: failed to dequeue orphan %s: %v", sid, err)
+			continue
+		}
+		if err := queue.MarkOrphaned(ctx, sid); err != nil {
+			log.Printf("[matcher] cleanup: failed to mark orphan %s: %v", sid, err)
 		}
+		events.Record(ctx, sid, Event{Type: EventDropped, Detail: "server " + entry.Server + " went away"})
+		orphaned++
 	}
 
 	if removed > 0 {
 		log.Printf("[matcher] cleanup: removed %d stale entries", removed)
 	}
+	if orphaned > 0 {
+		log.Printf("[matcher] cleanup: removed %d entries orphaned by dead servers", orphaned)
+	}
 }
 
-// cleanExpiredPendingChats removes chat sessions that exceeded the 15s
-// accept deadline without both users accepting. Notifies both users.
-func cleanExpiredPendingChats(ctx context.Context, rdb *redis.Client, nats *messaging.NATSClient) {
-	now := float64(time.Now().Unix())
-
-	chatIDs, err := rdb.ZRangeByScore(ctx, "match:pending_chats", &redis.ZRangeBy{
+// sweepAcceptDeadlines ends pending chats whose chat.AcceptWindow accept
+// deadline passed before both users accepted, and tells both users with a
+// timed_out notification. A user who did accept only waited on a partner who
+// never answered; its notification says so, and its wsserver queues it again
+// at the front, through the same gates as find_match.
+func sweepAcceptDeadlines(ctx context.Context, chats *chat.Store, events *EventLog, rdb *redis.Client, bus messaging.Bus) {
+	chatIDs, err := rdb.ZRangeByScore(ctx, chat.PendingKey, &redis.ZRangeBy{
 		Min: "0",
-		Max: fmt.Sprintf("%.0f", now),
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
 	}).Result()
 	if err != nil {
 		return
 	}
 
 	for _, chatID := range chatIDs {
-		status, _ := rdb.HGet(ctx, "chat:"+chatID, "status").Result()
-		if status == "pending_accept" {
-			userA, _ := rdb.HGet(ctx, "chat:"+chatID, "user_a").Result()
-			userB, _ := rdb.HGet(ctx, "chat:"+chatID, "user_b").Result()
+		cs, err := chats.ClaimExpiredPending(ctx, chatID)
+		if err != nil {
+			log.Printf("[matcher] accept deadline: claim chat=%s: %v", chatID, err)
+			continue
+		}
+		if cs == nil {
+			// Accepted in time, or already gone.
+			rdb.ZRem(ctx, chat.PendingKey, chatID)
+			continue
+		}
+		if err := chats.Delete(ctx, chatID); err != nil {
+			log.Printf("[matcher] accept deadline: delete chat=%s: %v", chatID, err)
+		}
 
-			// Notify both users the accept deadline expired.
-			notif, _ := json.Marshal(MatchNotification{Type: "timed_out", ChatID: chatID})
-			if userA != "" {
-				nats.PublishMatchNotify(userA, notif)
-			}
-			if userB != "" {
-				nats.PublishMatchNotify(userB, notif)
+		for _, user := range []struct {
+			sid      string
+			accepted bool
+		}{{cs.UserA, cs.AcceptedA}, {cs.UserB, cs.AcceptedB}} {
+			if user.sid == "" {
+				continue
 			}
+			notif, _ := json.Marshal(MatchNotification{Type: "timed_out", ChatID: chatID, Accepted: user.accepted})
+			bus.PublishMatchNotify(user.sid, notif)
 
-			// Delete the chat session.
-			rdb.Del(ctx, "chat:"+chatID)
-			log.Printf("[matcher] accept deadline expired for chat=%s", chatID)
+			e := Event{Type: EventAcceptTimeout, ChatID: chatID, Partner: cs.GetPartner(user.sid), Detail: "did not accept"}
+			if user.accepted {
+				e.Detail = "partner did not accept"
+			}
+			events.Record(ctx, user.sid, e)
 		}
-
-		// Remove from pending tracking set.
-		rdb.ZRem(ctx, "match:pending_chats", chatID)
+		log.Printf("[matcher] accept deadline expired for chat=%s (accepted: %t/%t)", chatID, cs.AcceptedA, cs.AcceptedB)
 	}
 }
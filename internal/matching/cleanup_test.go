@@ -0,0 +1,86 @@
+package matching
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestSweepAcceptDeadlines_NotifiesBothUsers(t *testing.T) {
+	rdb := testutil.Redis(t)
+	bus := messaging.NewRedisBus(rdb, messaging.DefaultRedisBusConfig())
+	t.Cleanup(bus.Close)
+	queue := NewQueue(rdb)
+	chats := chat.NewStore(rdb)
+	ctx := context.Background()
+
+	// Someone else was already waiting when the match was made.
+	if err := queue.Enqueue(ctx, "waiting", []string{"music"}); err != nil {
+		t.Fatal(err)
+	}
+	notifs := make(map[string]chan MatchNotification)
+	for _, sid := range []string{"accepter", "silent"} {
+		ch := make(chan MatchNotification, 1)
+		notifs[sid] = ch
+		if err := bus.SubscribeMatchNotify(sid, func(data []byte) {
+			var n MatchNotification
+			if err := json.Unmarshal(data, &n); err == nil {
+				ch <- n
+			}
+		}); err != nil {
+			t.Fatalf("SubscribeMatchNotify %s: %v", sid, err)
+		}
+	}
+
+	if err := chats.CreatePending(ctx, "chat-1", "accepter", "silent", "", TierOverlap, []string{"music"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := chats.AcceptMatch(ctx, "chat-1", "accepter"); err != nil || n != 0 {
+		t.Fatalf("AcceptMatch = %d, %v", n, err)
+	}
+
+	// Not due yet.
+	sweepAcceptDeadlines(ctx, chats, nil, rdb, bus)
+	if cs, _ := chats.Get(ctx, "chat-1"); cs == nil || cs.Status != chat.StatusPendingAccept {
+		t.Fatalf("chat swept before its deadline: %+v", cs)
+	}
+
+	rdb.ZAdd(ctx, chat.PendingKey, redis.Z{Score: float64(time.Now().Add(-time.Second).Unix()), Member: "chat-1"})
+	sweepAcceptDeadlines(ctx, chats, nil, rdb, bus)
+
+	for sid, accepted := range map[string]bool{"accepter": true, "silent": false} {
+		select {
+		case n := <-notifs[sid]:
+			if n.Type != "timed_out" || n.ChatID != "chat-1" || n.Accepted != accepted {
+				t.Errorf("%s got %+v, want timed_out with accepted=%t", sid, n, accepted)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no timed_out for %s", sid)
+		}
+	}
+	if cs, _ := chats.Get(ctx, "chat-1"); cs != nil {
+		t.Errorf("chat still exists: %+v", cs)
+	}
+	if id, _ := chats.ChatIDForSession(ctx, "accepter"); id != "" {
+		t.Errorf("accepter still linked to chat %q", id)
+	}
+	if n, err := chats.AcceptMatch(ctx, "chat-1", "silent"); n == 1 || err != nil {
+		t.Errorf("late AcceptMatch = %d, %v, want the chat gone", n, err)
+	}
+
+	// Requeueing is left to the accepter's wsserver, which puts it through
+	// the find_match gates.
+	queued, err := queue.GetAllQueued(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 || queued[0] != "waiting" {
+		t.Errorf("queue = %v, want only the user who was already waiting", queued)
+	}
+}
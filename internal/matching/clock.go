@@ -0,0 +1,46 @@
+package matching
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the matcher the time. Queue join times, tier escalation and
+// match timeouts are all read from it, so a test can substitute a
+// ManualClock and walk an entry through every tier threshold without
+// sleeping. Redis key TTLs and the matching loop's tick still use real time.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock, used unless SetClock is called.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// ManualClock is a Clock that only moves when told to. It is safe for
+// concurrent use.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock creates a ManualClock reading start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the clock's current time.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,164 @@
+package matching
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Match lifecycle event types recorded in a session's EventLog timeline.
+const (
+	EventEnqueued      = "enqueued"       // entered the queue; Detail "priority" for front placement
+	EventAttempt       = "attempt"        // a matching pass tried the tiers up to Tier without a match
+	EventMatchFound    = "match_found"    // paired with Partner in ChatID
+	EventAccepted      = "accepted"       // accepted the match
+	EventDeclined      = "declined"       // the match was declined, by this user or the partner (see Detail)
+	EventAcceptTimeout = "accept_timeout" // the accept deadline passed before both accepted
+	EventTimeout       = "timeout"        // no partner found within the match timeout
+	EventResumed       = "resumed"        // took over a search whose server went away, and was told to start over (see Detail)
+	EventCancelled     = "cancelled"      // left the queue on request
+	EventDropped       = "dropped"        // removed from the queue by cleanup (see Detail)
+)
+
+const (
+	// eventsPrefix + session ID is the Redis stream holding its timeline.
+	eventsPrefix = "match:events:"
+
+	// EventsMaxLen caps each timeline; older events are trimmed first. Even
+	// at one attempt per pass this covers several full searches.
+	EventsMaxLen = 200
+
+	// EventsTTL is how long a timeline is kept after its last event, long
+	// enough for a support request the next day.
+	EventsTTL = 48 * time.Hour
+)
+
+// Event is one step of a session's match lifecycle.
+type Event struct {
+	Type      string   `json:"type"`
+	At        int64    `json:"at"` // unix milliseconds, assigned by Redis
+	Tier      string   `json:"tier,omitempty"`
+	ChatID    string   `json:"chat_id,omitempty"`
+	Partner   string   `json:"partner,omitempty"`
+	WaitMs    int64    `json:"wait_ms,omitempty"` // time in the queue so far
+	Interests []string `json:"interests,omitempty"`
+	Detail    string   `json:"detail,omitempty"`
+}
+
+// EventLog records match lifecycle events per session in capped Redis
+// streams, so support can replay why a user was or was not matched. Both the
+// matcher and wsservers write to it. Recording is best effort: failures are
+// logged and never hold up matching.
+type EventLog struct {
+	rdb *redis.Client
+}
+
+// NewEventLog creates an event log backed by Redis.
+func NewEventLog(rdb *redis.Client) *EventLog {
+	return &EventLog{rdb: rdb}
+}
+
+// Record appends e to the timeline of sessionID. e.At is ignored. A nil
+// EventLog records nothing.
+func (l *EventLog) Record(ctx context.Context, sessionID string, e Event) {
+	if l == nil || sessionID == "" {
+		return
+	}
+	pipe := l.rdb.Pipeline()
+	l.add(ctx, pipe, sessionID, e)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[matcher] record %s event for %s: %v", e.Type, sessionID, err)
+	}
+}
+
+// add queues the commands appending e to the timeline of sessionID on pipe.
+func (l *EventLog) add(ctx context.Context, pipe redis.Pipeliner, sessionID string, e Event) {
+	values := []interface{}{"type", e.Type}
+	for _, f := range [][2]string{
+		{"tier", e.Tier},
+		{"chat_id", e.ChatID},
+		{"partner", e.Partner},
+		{"interests", strings.Join(e.Interests, ",")},
+		{"detail", e.Detail},
+	} {
+		if f[1] != "" {
+			values = append(values, f[0], f[1])
+		}
+	}
+	if e.WaitMs > 0 {
+		values = append(values, "wait_ms", e.WaitMs)
+	}
+
+	key := eventsPrefix + sessionID
+	pipe.XAdd(ctx, &redis.XAddArgs{Stream: key, MaxLen: EventsMaxLen, Approx: true, Values: values})
+	pipe.Expire(ctx, key, EventsTTL)
+}
+
+// EventBatch collects events to record them in one round trip, for callers
+// that record an event per queued session.
+type EventBatch struct {
+	log  *EventLog
+	pipe redis.Pipeliner
+	n    int
+}
+
+// Batch starts a batch of events on l. A batch of a nil EventLog records
+// nothing.
+func (l *EventLog) Batch() *EventBatch {
+	b := &EventBatch{log: l}
+	if l != nil {
+		b.pipe = l.rdb.Pipeline()
+	}
+	return b
+}
+
+// Record adds e to the timeline of sessionID when the batch is flushed.
+func (b *EventBatch) Record(ctx context.Context, sessionID string, e Event) {
+	if b.log == nil || sessionID == "" {
+		return
+	}
+	b.log.add(ctx, b.pipe, sessionID, e)
+	b.n++
+}
+
+// Flush records the batched events and empties the batch.
+func (b *EventBatch) Flush(ctx context.Context) {
+	if b.n == 0 {
+		return
+	}
+	if _, err := b.pipe.Exec(ctx); err != nil {
+		log.Printf("[matcher] record %d events: %v", b.n, err)
+	}
+	b.n = 0
+}
+
+// Timeline returns the recorded events of sessionID, oldest first, or none
+// if it has no timeline.
+func (l *EventLog) Timeline(ctx context.Context, sessionID string) ([]Event, error) {
+	msgs, err := l.rdb.XRange(ctx, eventsPrefix+sessionID, "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, len(msgs))
+	for i, msg := range msgs {
+		e := Event{}
+		e.At, _ = strconv.ParseInt(strings.SplitN(msg.ID, "-", 2)[0], 10, 64)
+		e.Type, _ = msg.Values["type"].(string)
+		e.Tier, _ = msg.Values["tier"].(string)
+		e.ChatID, _ = msg.Values["chat_id"].(string)
+		e.Partner, _ = msg.Values["partner"].(string)
+		e.Detail, _ = msg.Values["detail"].(string)
+		if v, ok := msg.Values["interests"].(string); ok && v != "" {
+			e.Interests = strings.Split(v, ",")
+		}
+		if v, ok := msg.Values["wait_ms"].(string); ok {
+			e.WaitMs, _ = strconv.ParseInt(v, 10, 64)
+		}
+		events[i] = e
+	}
+	return events, nil
+}
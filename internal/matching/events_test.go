@@ -0,0 +1,129 @@
+package matching
+
+import (
+	"context"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestEventLog_RecordAndTimeline(t *testing.T) {
+	rdb := testutil.Redis(t)
+	l := NewEventLog(rdb)
+	ctx := context.Background()
+
+	l.Record(ctx, "alice", Event{Type: EventEnqueued, Interests: []string{"music", "chess"}, Detail: "priority"})
+	l.Record(ctx, "alice", Event{Type: EventMatchFound, Tier: TierExact, ChatID: "chat-1", Partner: "bob", WaitMs: 1500})
+
+	events, err := l.Timeline(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if e := events[0]; e.Type != EventEnqueued || len(e.Interests) != 2 || e.Detail != "priority" || e.At == 0 {
+		t.Errorf("first event = %+v", e)
+	}
+	if e := events[1]; e.Type != EventMatchFound || e.Tier != TierExact || e.ChatID != "chat-1" || e.Partner != "bob" || e.WaitMs != 1500 || e.At < events[0].At {
+		t.Errorf("second event = %+v", e)
+	}
+	if ttl := rdb.TTL(ctx, eventsPrefix+"alice").Val(); ttl <= 0 || ttl > EventsTTL {
+		t.Errorf("timeline TTL = %v, want up to %v", ttl, EventsTTL)
+	}
+
+	if events, err := l.Timeline(ctx, "nobody"); err != nil || len(events) != 0 {
+		t.Errorf("Timeline of an unknown session = %v, %v, want none", events, err)
+	}
+
+	var disabled *EventLog
+	disabled.Record(ctx, "alice", Event{Type: EventCancelled}) // must not panic
+}
+
+func TestEventLog_Capped(t *testing.T) {
+	l := NewEventLog(testutil.Redis(t))
+	ctx := context.Background()
+
+	for i := 0; i < EventsMaxLen*2; i++ {
+		l.Record(ctx, "alice", Event{Type: EventAttempt, Tier: TierOverlap})
+	}
+	events, err := l.Timeline(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Trimming is approximate, but never keeps everything.
+	if len(events) < EventsMaxLen || len(events) >= EventsMaxLen*2 {
+		t.Errorf("kept %d events, want about %d", len(events), EventsMaxLen)
+	}
+}
+
+func TestService_RecordsAttemptsAndMatch(t *testing.T) {
+	tiers := DefaultTierConfig()
+	v := newVirtualService(t, tiers)
+	v.enqueue("alice", "music")
+	v.enqueue("bob", "hiking")
+
+	v.passAt(tiers.Tier1MaxWait)
+	v.passAt(tiers.Tier3MaxWait)
+	chatID := v.result("alice").ChatID
+
+	events, err := v.s.events.Timeline(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("alice timeline = %+v, want an attempt and the match", events)
+	}
+	if e := events[0]; e.Type != EventAttempt || e.Tier != TierOverlap || e.WaitMs != tiers.Tier1MaxWait.Milliseconds() {
+		t.Errorf("first event = %+v, want an overlap attempt after %v", e, tiers.Tier1MaxWait)
+	}
+	if e := events[1]; e.Type != EventMatchFound || e.Tier != TierRandom || e.Partner != "bob" || e.ChatID != chatID {
+		t.Errorf("second event = %+v, want the random match with bob", e)
+	}
+}
+
+func TestEventBatch(t *testing.T) {
+	l := NewEventLog(testutil.Redis(t))
+	ctx := context.Background()
+
+	b := l.Batch()
+	b.Record(ctx, "alice", Event{Type: EventAttempt, Tier: TierExact})
+	b.Record(ctx, "bob", Event{Type: EventAttempt, Tier: TierOverlap})
+	if events, _ := l.Timeline(ctx, "alice"); len(events) != 0 {
+		t.Fatalf("recorded before Flush: %+v", events)
+	}
+	b.Flush(ctx)
+	b.Flush(ctx) // empty: records nothing again
+
+	for sid, tier := range map[string]string{"alice": TierExact, "bob": TierOverlap} {
+		events, err := l.Timeline(ctx, sid)
+		if err != nil || len(events) != 1 || events[0].Type != EventAttempt || events[0].Tier != tier {
+			t.Errorf("%s timeline = %+v, %v; want one %s attempt", sid, events, err, tier)
+		}
+	}
+
+	var disabled *EventLog
+	nb := disabled.Batch()
+	nb.Record(ctx, "alice", Event{Type: EventAttempt}) // must not panic
+	nb.Flush(ctx)
+}
+
+func TestService_RecordsResume(t *testing.T) {
+	v := newVirtualService(t, DefaultTierConfig())
+	v.enqueue("alice", "music")
+
+	v.s.handleResumeRequest([]byte(`{"previous_session_id":"alice","session_id":"alice-2"}`))
+	if v.queued("alice") {
+		t.Fatal("the previous session is still queued")
+	}
+
+	ctx := context.Background()
+	events, err := v.s.events.Timeline(ctx, "alice-2")
+	if err != nil || len(events) != 1 || events[0].Type != EventResumed {
+		t.Errorf("alice-2 timeline = %+v, %v; want a resumed event", events, err)
+	}
+	events, err = v.s.events.Timeline(ctx, "alice")
+	if err != nil || len(events) != 1 || events[0].Type != EventDropped {
+		t.Errorf("alice timeline = %+v, %v; want a dropped event", events, err)
+	}
+}
@@ -2,6 +2,7 @@ package matching
 
 import (
 	"context"
+	"time"
 )
 
 // MatchCandidate represents a successful match between two users.
@@ -9,10 +10,34 @@ type MatchCandidate struct {
 	SessionA        string
 	SessionB        string
 	SharedInterests []string
+	Tier            string // which matching tier produced the pair (Tier* constants)
+	Policy          string // content policy both users agreed on (chat.Policy* constants)
+
+	// Filled in by the service once the pair is taken off the queue:
+	// how long each side waited, the broadest tier that wait reached, the
+	// region each joined from and how many of their interests are not
+	// shared, indexed A then B.
+	Waits          [2]time.Duration
+	WaitTiers      [2]string
+	Regions        [2]string
+	OtherInterests [2]int
 }
 
+// Matching tiers, recorded on each chat so outcomes can be compared per tier.
+const (
+	TierExact   = "exact"
+	TierOverlap = "overlap"
+	TierSingle  = "single"
+	TierRandom  = "random"
+)
+
+// Tiers lists every matching tier in escalation order.
+var Tiers = []string{TierExact, TierOverlap, TierSingle, TierRandom}
+
 // TryExactMatch attempts Tier 1 matching: find a user with an identical
-// interest set (same hash). Returns nil if no exact match is available.
+// interest set (same hash). For long interest lists it falls back to users
+// whose top-K most popular interests are identical. Returns nil if no exact
+// match is available.
 func (q *Queue) TryExactMatch(ctx context.Context, sessionID string) (*MatchCandidate, error) {
 	entry, err := q.GetEntry(ctx, sessionID)
 	if err != nil || entry == nil {
@@ -37,11 +62,57 @@ func (q *Queue) TryExactMatch(ctx context.Context, sessionID string) (*MatchCand
 		if !queued {
 			continue // stale entry, cleanup will remove it
 		}
+		if q.excludedPair(ctx, entry, candidateID) {
+			continue
+		}
 
 		return &MatchCandidate{
 			SessionA:        sessionID,
 			SessionB:        candidateID,
 			SharedInterests: entry.Interests, // all interests match (exact)
+			Tier:            TierExact,
+		}, nil
+	}
+
+	if entry.TopKHash != "" {
+		return q.tryTopKMatch(ctx, entry)
+	}
+
+	return nil, nil
+}
+
+// tryTopKMatch looks for a user indexed under the same top-K interest hash.
+// The shared interests reported are the full intersection of both lists,
+// which always includes the top-K tags.
+func (q *Queue) tryTopKMatch(ctx context.Context, entry *QueueEntry) (*MatchCandidate, error) {
+	candidates, err := q.GetTopKCandidates(ctx, entry.TopKHash)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidateID := range candidates {
+		if candidateID == entry.SessionID {
+			continue
+		}
+
+		queued, err := q.IsQueued(ctx, candidateID)
+		if err != nil || !queued {
+			continue
+		}
+		if q.excludedPair(ctx, entry, candidateID) {
+			continue
+		}
+
+		candidate, err := q.GetEntry(ctx, candidateID)
+		if err != nil || candidate == nil {
+			continue
+		}
+
+		return &MatchCandidate{
+			SessionA:        entry.SessionID,
+			SessionB:        candidateID,
+			SharedInterests: sharedInterests(entry.Interests, candidate.Interests),
+			Tier:            TierExact,
 		}, nil
 	}
 
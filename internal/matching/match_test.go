@@ -6,33 +6,17 @@ import (
 	"testing"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/block"
+	"github.com/whisper/chat-app/internal/botdetect"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/testutil"
 )
 
-// setupTestQueue creates a Queue connected to a test Redis instance.
-// Requires Redis running on localhost:6379. Tests are skipped if unavailable.
+// setupTestQueue creates a Queue on the package's test Redis with an empty
+// database. Tests are skipped if Redis is unavailable.
 func setupTestQueue(t *testing.T) (*Queue, context.Context) {
 	t.Helper()
-
-	rdb := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-		DB:   15, // use DB 15 for tests to avoid conflicts
-	})
-
-	ctx := context.Background()
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		t.Skipf("skipping: Redis not available: %v", err)
-	}
-
-	// Flush test DB before each test.
-	rdb.FlushDB(ctx)
-
-	t.Cleanup(func() {
-		rdb.FlushDB(ctx)
-		rdb.Close()
-	})
-
-	return NewQueue(rdb), ctx
+	return NewQueue(testutil.Redis(t)), context.Background()
 }
 
 // enqueueTestUser is a helper that enqueues a user with a specific join time offset.
@@ -228,6 +212,32 @@ func TestTryRandomMatch_FindsAnyCandidate(t *testing.T) {
 	}
 }
 
+func TestTryRandomMatch_PrefersSameCountry(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	for sid, country := range map[string]string{"alice": "DE", "bob": "FR", "carol": "DE", "dave": ""} {
+		q.rdb.HSet(ctx, session.SessionPrefix+sid, "country", country)
+	}
+	for _, sid := range []string{"alice", "bob", "carol", "dave"} {
+		enqueueTestUser(t, q, ctx, sid, []string{sid})
+	}
+	// Matching uses the country recorded in the queue entry, not the live
+	// session's.
+	q.rdb.HSet(ctx, session.SessionPrefix+"carol", "country", "FR")
+
+	if match, err := q.TryRandomMatch(ctx, "alice"); err != nil || match == nil || match.SessionB != "carol" {
+		t.Errorf("alice matched %+v, %v; want carol from the same country", match, err)
+	}
+	// Nobody else is from France, so the longest waiting user is taken.
+	if match, err := q.TryRandomMatch(ctx, "bob"); err != nil || match == nil || match.SessionB != "alice" {
+		t.Errorf("bob matched %+v, %v; want alice", match, err)
+	}
+	// Without a known country join order decides.
+	if match, err := q.TryRandomMatch(ctx, "dave"); err != nil || match == nil || match.SessionB != "alice" {
+		t.Errorf("dave matched %+v, %v; want alice", match, err)
+	}
+}
+
 func TestTryRandomMatch_NoOtherUsers(t *testing.T) {
 	q, ctx := setupTestQueue(t)
 
@@ -289,12 +299,14 @@ func TestTryRandomMatch_SkipsDequeuedCandidate(t *testing.T) {
 
 func TestTryRandomMatch_OldestFirstFairness(t *testing.T) {
 	q, ctx := setupTestQueue(t)
+	clock := NewManualClock(time.Now())
+	q.SetClock(clock)
 
 	// Enqueue in order: bob, charlie, alice requests match.
 	enqueueTestUser(t, q, ctx, "bob", []string{"sports"})
-	time.Sleep(10 * time.Millisecond) // ensure ordering
+	clock.Advance(10 * time.Millisecond)
 	enqueueTestUser(t, q, ctx, "charlie", []string{"music"})
-	time.Sleep(10 * time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
 	enqueueTestUser(t, q, ctx, "alice", []string{"gaming"})
 
 	match, err := q.TryRandomMatch(ctx, "alice")
@@ -331,11 +343,13 @@ func TestTryRandomMatch_PairsUsersWithNoSharedInterests(t *testing.T) {
 
 func TestTryRandomMatch_MultipleCandidates(t *testing.T) {
 	q, ctx := setupTestQueue(t)
+	clock := NewManualClock(time.Now())
+	q.SetClock(clock)
 
 	// Add several users.
 	for i := 0; i < 5; i++ {
 		enqueueTestUser(t, q, ctx, fmt.Sprintf("user-%d", i), []string{fmt.Sprintf("interest-%d", i)})
-		time.Sleep(5 * time.Millisecond)
+		clock.Advance(5 * time.Millisecond)
 	}
 
 	match, err := q.TryRandomMatch(ctx, "user-4")
@@ -354,30 +368,429 @@ func TestTryRandomMatch_MultipleCandidates(t *testing.T) {
 	}
 }
 
-// ---------- Tier timing constant tests ----------
+// ---------- Tier timing default tests ----------
+
+func TestDefaultTierConfig(t *testing.T) {
+	tiers := DefaultTierConfig()
+	if tiers.Tier1MaxWait != 10*time.Second {
+		t.Errorf("Tier1MaxWait should be 10s, got %v", tiers.Tier1MaxWait)
+	}
+	if tiers.Tier2MaxWait != 20*time.Second {
+		t.Errorf("Tier2MaxWait should be 20s, got %v", tiers.Tier2MaxWait)
+	}
+	if tiers.Tier3MaxWait != 25*time.Second {
+		t.Errorf("Tier3MaxWait should be 25s, got %v", tiers.Tier3MaxWait)
+	}
+	if tiers.MatchTimeout != 30*time.Second {
+		t.Errorf("MatchTimeout should be 30s, got %v", tiers.MatchTimeout)
+	}
+
+	// The defaults must pass their own validation (which checks ordering).
+	if err := tiers.Validate(); err != nil {
+		t.Errorf("default tiers invalid: %v", err)
+	}
+}
+
+// ---------- Top-K exact tier tests ----------
+
+func TestTryExactMatch_TopKMatchesLongLists(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	// Seed popularity so music/gaming/anime are the most popular tags.
+	for i := 0; i < 5; i++ {
+		enqueueTestUser(t, q, ctx, fmt.Sprintf("seed-%d", i), []string{"music", "gaming", "anime"})
+		q.Dequeue(ctx, fmt.Sprintf("seed-%d", i))
+	}
+
+	enqueueTestUser(t, q, ctx, "user-a", []string{"music", "gaming", "anime", "knitting", "chess"})
+	enqueueTestUser(t, q, ctx, "user-b", []string{"anime", "music", "gaming", "pottery"})
+
+	match, err := q.TryExactMatch(ctx, "user-a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if match == nil {
+		t.Fatal("expected a top-K match, got nil")
+	}
+	if match.SessionB != "user-b" {
+		t.Errorf("expected SessionB=user-b, got %s", match.SessionB)
+	}
+	if len(match.SharedInterests) != 3 {
+		t.Errorf("expected 3 shared interests, got %v", match.SharedInterests)
+	}
+}
+
+func TestEnqueue_TopKIndexedBeyondK(t *testing.T) {
+	tags := []string{"music", "gaming", "anime", "chess", "films", "books", "travel", "cooking", "hiking", "art"}
+	tests := []struct {
+		n       int
+		indexed bool
+	}{
+		{topKInterests, false},
+		{topKInterests + 1, true},
+		{len(tags), true},
+	}
+	for _, tt := range tests {
+		q, ctx := setupTestQueue(t)
+		enqueueTestUser(t, q, ctx, "user-a", tags[:tt.n])
+
+		entry, err := q.GetEntry(ctx, "user-a")
+		if err != nil || entry == nil {
+			t.Fatalf("GetEntry: %v", err)
+		}
+		if indexed := entry.TopKHash != ""; indexed != tt.indexed {
+			t.Errorf("%d interests: TopKHash = %q, want indexed=%v", tt.n, entry.TopKHash, tt.indexed)
+		}
+		if !tt.indexed {
+			continue
+		}
+		members, err := q.rdb.SMembers(ctx, keyTopKPrefix+entry.TopKHash).Result()
+		if err != nil || len(members) != 1 || members[0] != "user-a" {
+			t.Errorf("%d interests: top-K index = %v, %v; want [user-a]", tt.n, members, err)
+		}
+	}
+}
+
+func TestTryExactMatch_ShortListsNotTopKIndexed(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueTestUser(t, q, ctx, "user-a", []string{"music", "gaming", "anime"})
+	enqueueTestUser(t, q, ctx, "user-b", []string{"music", "gaming", "anime", "chess"})
+
+	entry, err := q.GetEntry(ctx, "user-a")
+	if err != nil || entry == nil {
+		t.Fatalf("GetEntry: %v", err)
+	}
+	if entry.TopKHash != "" {
+		t.Errorf("expected empty TopKHash for %d interests, got %q", len(entry.Interests), entry.TopKHash)
+	}
+
+	match, err := q.TryExactMatch(ctx, "user-a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if match != nil {
+		t.Errorf("expected no exact match, got %+v", match)
+	}
+}
+
+func TestEnqueuePriority_OrdersFirstWithoutBackdating(t *testing.T) {
+	q, ctx := setupTestQueue(t)
 
-func TestTierTimingConstants(t *testing.T) {
-	if tier1MaxWait != 10*time.Second {
-		t.Errorf("tier1MaxWait should be 10s, got %v", tier1MaxWait)
+	q.EnqueueFrom(ctx, "user-a", "ws-1", "", []string{"music"})
+	before := float64(time.Now().UnixMilli())
+	if err := q.EnqueuePriority(ctx, "user-b", "ws-1", "", []string{"gaming"}); err != nil {
+		t.Fatalf("EnqueuePriority: %v", err)
 	}
-	if tier2MaxWait != 20*time.Second {
-		t.Errorf("tier2MaxWait should be 20s, got %v", tier2MaxWait)
+
+	queued, err := q.GetAllQueued(ctx)
+	if err != nil {
+		t.Fatalf("GetAllQueued: %v", err)
 	}
-	if tier3MaxWait != 25*time.Second {
-		t.Errorf("tier3MaxWait should be 25s, got %v", tier3MaxWait)
+	if len(queued) != 2 || queued[0] != "user-b" {
+		t.Fatalf("expected user-b first, got %v", queued)
 	}
-	if matchTimeout != 30*time.Second {
-		t.Errorf("matchTimeout should be 30s, got %v", matchTimeout)
+
+	// The join time drives tier escalation and must not be backdated.
+	entry, _ := q.GetEntry(ctx, "user-b")
+	if entry == nil || entry.JoinedAt < before {
+		t.Errorf("expected JoinedAt >= %.0f, got %+v", before, entry)
 	}
+}
+
+// ---------- Orphan tracking tests ----------
+
+func TestEnqueueFrom_RecordsServer(t *testing.T) {
+	q, ctx := setupTestQueue(t)
 
-	// Verify tier ordering is correct.
-	if tier1MaxWait >= tier2MaxWait {
-		t.Error("tier1MaxWait should be less than tier2MaxWait")
+	if err := q.EnqueueFrom(ctx, "user-a", "ws-1", "eu", []string{"music"}); err != nil {
+		t.Fatalf("EnqueueFrom: %v", err)
+	}
+	entry, err := q.GetEntry(ctx, "user-a")
+	if err != nil || entry == nil {
+		t.Fatalf("GetEntry: %v", err)
+	}
+	if entry.Server != "ws-1" {
+		t.Errorf("expected Server=ws-1, got %q", entry.Server)
 	}
-	if tier2MaxWait >= tier3MaxWait {
-		t.Error("tier2MaxWait should be less than tier3MaxWait")
+	if entry.Region != "eu" {
+		t.Errorf("expected Region=eu, got %q", entry.Region)
+	}
+}
+
+func TestMarkAndClearOrphaned(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	if err := q.MarkOrphaned(ctx, "user-a"); err != nil {
+		t.Fatalf("MarkOrphaned: %v", err)
+	}
+	found, err := q.ClearOrphaned(ctx, "user-a")
+	if err != nil {
+		t.Fatalf("ClearOrphaned: %v", err)
+	}
+	if !found {
+		t.Error("expected orphan marker to exist")
+	}
+	found, _ = q.ClearOrphaned(ctx, "user-a")
+	if found {
+		t.Error("expected orphan marker to be cleared")
+	}
+}
+
+// ---------- Block list tests ----------
+
+// enqueueWithFingerprint stores a session fingerprint the way wsserver does
+// and then enqueues the user.
+func enqueueWithFingerprint(t *testing.T, q *Queue, ctx context.Context, sessionID, fingerprint string, interests []string) {
+	t.Helper()
+	if err := q.rdb.HSet(ctx, session.SessionPrefix+sessionID, "fingerprint", fingerprint).Err(); err != nil {
+		t.Fatalf("set fingerprint for %s: %v", sessionID, err)
+	}
+	enqueueTestUser(t, q, ctx, sessionID, interests)
+}
+
+func TestEnqueue_RecordsFingerprint(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueWithFingerprint(t, q, ctx, "alice", "fp-alice", []string{"music"})
+	entry, err := q.GetEntry(ctx, "alice")
+	if err != nil || entry == nil {
+		t.Fatalf("GetEntry: %v", err)
+	}
+	if entry.Fingerprint != "fp-alice" {
+		t.Errorf("expected Fingerprint=fp-alice, got %q", entry.Fingerprint)
+	}
+}
+
+func TestBlockList_ExcludedFromAllTiers(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueWithFingerprint(t, q, ctx, "alice", "fp-alice", []string{"music"})
+	enqueueWithFingerprint(t, q, ctx, "bob", "fp-bob", []string{"music"})
+
+	// bob blocked alice; the block is mutual.
+	if err := block.NewStore(q.rdb).Add(ctx, "fp-bob", "fp-alice", block.DefaultTTL); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+
+	tiers := map[string]func(context.Context, string) (*MatchCandidate, error){
+		"exact":   q.TryExactMatch,
+		"overlap": q.TryOverlapMatch,
+		"single":  q.TrySingleInterestMatch,
+		"random":  q.TryRandomMatch,
+	}
+	for name, try := range tiers {
+		match, err := try(ctx, "alice")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if match != nil {
+			t.Errorf("%s: expected blocked pair not to match, got %s/%s", name, match.SessionA, match.SessionB)
+		}
+	}
+}
+
+func TestBlockList_FallsThroughToNextCandidate(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueWithFingerprint(t, q, ctx, "alice", "fp-alice", []string{"music"})
+	enqueueWithFingerprint(t, q, ctx, "bob", "fp-bob", []string{"music"})
+	enqueueWithFingerprint(t, q, ctx, "carol", "fp-carol", []string{"music"})
+
+	if err := block.NewStore(q.rdb).Add(ctx, "fp-alice", "fp-bob", block.DefaultTTL); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		match, err := q.TryExactMatch(ctx, "alice")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if match == nil || match.SessionB != "carol" {
+			t.Fatalf("expected alice to be matched with carol, got %+v", match)
+		}
+	}
+}
+
+func TestBlockList_NoFingerprintStillMatches(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueTestUser(t, q, ctx, "alice", []string{"music"})
+	enqueueTestUser(t, q, ctx, "bob", []string{"music"})
+
+	match, err := q.TryExactMatch(ctx, "alice")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if match == nil {
+		t.Fatal("expected users without fingerprints to match")
+	}
+}
+
+func TestBotFlag_PairsFlaggedOnlyWithEachOther(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueWithFingerprint(t, q, ctx, "alice", "fp-alice", []string{"music"})
+	enqueueWithFingerprint(t, q, ctx, "bob", "fp-bob", []string{"music"})
+	bots := botdetect.NewDetector(q.rdb, botdetect.DefaultConfig())
+	if _, err := bots.Flag(ctx, "fp-alice", []string{botdetect.SignalRepeatedMessage}); err != nil {
+		t.Fatalf("flag: %v", err)
+	}
+
+	if match, err := q.TryExactMatch(ctx, "bob"); err != nil || match != nil {
+		t.Fatalf("expected a flagged bot not to be paired with a human, got %+v, %v", match, err)
+	}
+
+	enqueueWithFingerprint(t, q, ctx, "carol", "fp-carol", []string{"music"})
+	if _, err := bots.Flag(ctx, "fp-carol", []string{botdetect.SignalRepeatedMessage}); err != nil {
+		t.Fatalf("flag: %v", err)
+	}
+	match, err := q.TryExactMatch(ctx, "alice")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if match == nil || match.SessionB != "carol" {
+		t.Fatalf("expected flagged alice to be paired with flagged carol, got %+v", match)
+	}
+}
+
+// ---------- Snapshot/restore tests ----------
+
+// setMatchingSession creates a wsserver session hash in the matching state.
+func setMatchingSession(t *testing.T, q *Queue, ctx context.Context, sessionID, server string) {
+	t.Helper()
+	err := q.rdb.HSet(ctx, session.SessionPrefix+sessionID, map[string]interface{}{
+		"id":          sessionID,
+		"status":      session.StatusMatching,
+		"server":      server,
+		"fingerprint": "fp-" + sessionID,
+	}).Err()
+	if err != nil {
+		t.Fatalf("set session %s: %v", sessionID, err)
+	}
+}
+
+func TestSnapshotRestore_ReenqueuesConnectedSessions(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	q.rdb.Set(ctx, session.ServerAlivePrefix+"ws-1", 1, time.Minute)
+	for _, sid := range []string{"alice", "bob", "carol", "dave"} {
+		setMatchingSession(t, q, ctx, sid, "ws-1")
+		if err := q.EnqueueFrom(ctx, sid, "ws-1", "", []string{"music", sid}); err != nil {
+			t.Fatalf("EnqueueFrom %s: %v", sid, err)
+		}
+	}
+
+	snap, err := q.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(snap.Entries) != 4 {
+		t.Fatalf("expected 4 snapshot entries, got %d", len(snap.Entries))
+	}
+	if snap.Entries[0].Session["fingerprint"] != "fp-alice" {
+		t.Errorf("expected session hash in snapshot, got %v", snap.Entries[0].Session)
+	}
+
+	// Simulate a flush of the matching keys; bob then disconnects and carol
+	// gets matched before the restore runs.
+	for _, sid := range []string{"alice", "bob", "carol", "dave"} {
+		q.Dequeue(ctx, sid)
+	}
+	q.rdb.Del(ctx, session.SessionPrefix+"bob")
+	q.rdb.HSet(ctx, session.SessionPrefix+"carol", "status", session.StatusChatting)
+	q.Enqueue(ctx, "dave", []string{"music"})
+
+	result, err := q.Restore(ctx, snap, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	want := RestoreResult{Restored: 1, AlreadyQueued: 1, Disconnected: 1, NotMatching: 1}
+	if result != want {
+		t.Errorf("Restore result = %+v, want %+v", result, want)
+	}
+
+	entry, _ := q.GetEntry(ctx, "alice")
+	if entry == nil || entry.Server != "ws-1" || entry.Fingerprint != "fp-alice" {
+		t.Errorf("expected alice restored with server and fingerprint, got %+v", entry)
+	}
+}
+
+func TestRestore_DryRunAndRecreateSessions(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	q.rdb.Set(ctx, session.ServerAlivePrefix+"ws-1", 1, time.Minute)
+	setMatchingSession(t, q, ctx, "alice", "ws-1")
+	setMatchingSession(t, q, ctx, "bob", "ws-dead")
+	q.EnqueueFrom(ctx, "alice", "ws-1", "", []string{"music"})
+	q.EnqueueFrom(ctx, "bob", "ws-dead", "", []string{"music"})
+
+	snap, err := q.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	q.rdb.FlushDB(ctx)
+	q.rdb.Set(ctx, session.ServerAlivePrefix+"ws-1", 1, time.Minute)
+
+	result, err := q.Restore(ctx, snap, RestoreOptions{RecreateSessions: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("Restore dry run: %v", err)
+	}
+	if result.Restored != 1 || result.ServerDown != 1 || result.SessionsRecreated != 1 {
+		t.Errorf("unexpected dry run result %+v", result)
+	}
+	if size, _ := q.QueueSize(ctx); size != 0 {
+		t.Errorf("dry run should not enqueue, queue size %d", size)
+	}
+
+	if _, err := q.Restore(ctx, snap, RestoreOptions{RecreateSessions: true}); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	status, _ := q.rdb.HGet(ctx, session.SessionPrefix+"alice", "status").Result()
+	if status != session.StatusMatching {
+		t.Errorf("expected alice session recreated, status %q", status)
+	}
+	if queued, _ := q.IsQueued(ctx, "alice"); !queued {
+		t.Error("expected alice to be re-enqueued")
+	}
+	if queued, _ := q.IsQueued(ctx, "bob"); queued {
+		t.Error("bob's server is down; bob should not be re-enqueued")
+	}
+
+	snap.Version = 99
+	if _, err := q.Restore(ctx, snap, RestoreOptions{}); err == nil {
+		t.Error("expected error for unsupported snapshot version")
+	}
+}
+
+// ---------- ListQueued tests ----------
+
+func TestListQueued_OldestFirst(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+	clock := NewManualClock(time.Unix(1_700_000_000, 0))
+	q.SetClock(clock)
+
+	for _, sid := range []string{"alice", "bob", "carol"} {
+		enqueueTestUser(t, q, ctx, sid, []string{"music"})
+		clock.Advance(time.Second)
+	}
+
+	entries, err := q.ListQueued(ctx, 2)
+	if err != nil {
+		t.Fatalf("ListQueued: %v", err)
+	}
+	if len(entries) != 2 || entries[0].SessionID != "alice" || entries[1].SessionID != "bob" {
+		t.Fatalf("ListQueued(2) = %+v, want alice then bob", entries)
+	}
+	if len(entries[0].Interests) != 1 || entries[0].Interests[0] != "music" {
+		t.Errorf("alice's interests = %v", entries[0].Interests)
+	}
+
+	if err := q.Dequeue(ctx, "alice"); err != nil {
+		t.Fatalf("Dequeue: %v", err)
 	}
-	if tier3MaxWait >= matchTimeout {
-		t.Error("tier3MaxWait should be less than matchTimeout")
+	if entries, _ := q.ListQueued(ctx, 10); len(entries) != 2 {
+		t.Errorf("after dequeue: %d entries, want 2", len(entries))
 	}
 }
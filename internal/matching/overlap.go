@@ -58,6 +58,9 @@ func (q *Queue) TryOverlapMatch(ctx context.Context, sessionID string) (*MatchCa
 		if err != nil || !queued {
 			continue
 		}
+		if q.excludedPair(ctx, entry, candidate.id) {
+			continue
+		}
 
 		shared := make([]string, 0, candidate.count)
 		for tag := range candidateInterests[candidate.id] {
@@ -69,6 +72,7 @@ func (q *Queue) TryOverlapMatch(ctx context.Context, sessionID string) (*MatchCa
 			SessionA:        sessionID,
 			SessionB:        candidate.id,
 			SharedInterests: shared,
+			Tier:            TierOverlap,
 		}, nil
 	}
 
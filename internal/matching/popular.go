@@ -0,0 +1,81 @@
+package matching
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// keyPopularityDecayLock ensures only one matcher instance decays the
+	// popularity set per interval.
+	keyPopularityDecayLock = "match:popularity:decay_lock"
+
+	// popularityDecayInterval is how often popularity scores are decayed.
+	popularityDecayInterval = time.Minute
+
+	// popularityDecayFactor is applied to every score once per interval,
+	// giving tags a half-life of roughly 14 minutes so suggestions follow
+	// what is being queued now rather than all-time totals.
+	popularityDecayFactor = 0.95
+
+	// popularityMinScore is the score below which a tag is dropped.
+	popularityMinScore = 0.1
+
+	// MaxPopularInterests caps how many tags PopularInterests returns.
+	MaxPopularInterests = 50
+)
+
+// PopularInterest is a tag and its decayed queue popularity score.
+type PopularInterest struct {
+	Tag   string  `json:"tag"`
+	Score float64 `json:"score"`
+}
+
+// PopularInterests returns up to limit tags with the highest decayed
+// popularity, most popular first.
+func (q *Queue) PopularInterests(ctx context.Context, limit int) ([]PopularInterest, error) {
+	if limit <= 0 || limit > MaxPopularInterests {
+		limit = MaxPopularInterests
+	}
+
+	zs, err := q.rdb.ZRevRangeWithScores(ctx, keyPopularity, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return popularFromZ(zs), nil
+}
+
+// DecayPopularity multiplies every popularity score by popularityDecayFactor
+// and drops tags whose score fell below popularityMinScore. It is a no-op if
+// another matcher already decayed the set during the current interval.
+func (q *Queue) DecayPopularity(ctx context.Context) error {
+	acquired, err := q.rdb.SetNX(ctx, keyPopularityDecayLock, 1, popularityDecayInterval-time.Second).Result()
+	if err != nil || !acquired {
+		return err
+	}
+
+	pipe := q.rdb.TxPipeline()
+	pipe.ZUnionStore(ctx, keyPopularity, &redis.ZStore{
+		Keys:    []string{keyPopularity},
+		Weights: []float64{popularityDecayFactor},
+	})
+	pipe.ZRemRangeByScore(ctx, keyPopularity, "-inf", "("+strconv.FormatFloat(popularityMinScore, 'f', -1, 64))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// popularFromZ converts sorted set members into PopularInterest values.
+func popularFromZ(zs []redis.Z) []PopularInterest {
+	out := make([]PopularInterest, 0, len(zs))
+	for _, z := range zs {
+		tag, ok := z.Member.(string)
+		if !ok {
+			continue
+		}
+		out = append(out, PopularInterest{Tag: tag, Score: z.Score})
+	}
+	return out
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/whisper/chat-app/internal/chat"
 	"github.com/whisper/chat-app/internal/messaging"
 )
 
@@ -16,45 +18,71 @@ type MatchResult struct {
 	PartnerID       string   `json:"partner_id,omitempty"`
 	SharedInterests []string `json:"shared_interests,omitempty"`
 	AcceptDeadline  int      `json:"accept_deadline,omitempty"`
+
+	// What the user may know about the partner: the matching tier, how
+	// many seconds the partner waited and the broadest tier that wait
+	// reached, the region they joined from and how many of their interests
+	// are not shared (never which).
+	Tier                  string `json:"tier,omitempty"`
+	PartnerWait           int    `json:"partner_wait,omitempty"`
+	PartnerWaitTier       string `json:"partner_wait_tier,omitempty"`
+	PartnerRegion         string `json:"partner_region,omitempty"`
+	PartnerOtherInterests int    `json:"partner_other_interests,omitempty"`
+
+	// Policy is the content policy the chat's messages are filtered with.
+	Policy string `json:"policy,omitempty"`
 }
 
 // MatchNotification is sent via NATS match.notify.<session_id> for match lifecycle events.
 type MatchNotification struct {
-	Type   string `json:"type"`    // "accepted", "declined", "timed_out"
-	ChatID string `json:"chat_id"`
+	Type     string `json:"type"` // "accepted", "partner_ready", "declined", "timed_out"
+	ChatID   string `json:"chat_id"`
+	Accepted bool   `json:"accepted,omitempty"` // timed_out: the session had accepted, so it goes back into the queue
 }
 
 // PublishMatchFound publishes match results to both users via NATS.
-func PublishMatchFound(nats *messaging.NATSClient, chatID string, candidate *MatchCandidate) error {
-	deadline := 15 // 15 seconds to accept/decline
+func PublishMatchFound(bus messaging.Bus, chatID string, candidate *MatchCandidate) error {
+	deadline := int(chat.AcceptWindow / time.Second)
 
 	// Notify session A (partner = B).
 	msgA := MatchResult{
-		ChatID:          chatID,
-		PartnerID:       candidate.SessionB,
-		SharedInterests: candidate.SharedInterests,
-		AcceptDeadline:  deadline,
+		ChatID:                chatID,
+		PartnerID:             candidate.SessionB,
+		SharedInterests:       candidate.SharedInterests,
+		AcceptDeadline:        deadline,
+		Tier:                  candidate.Tier,
+		PartnerWait:           int(candidate.Waits[1] / time.Second),
+		PartnerRegion:         candidate.Regions[1],
+		PartnerWaitTier:       candidate.WaitTiers[1],
+		PartnerOtherInterests: candidate.OtherInterests[1],
+		Policy:                candidate.Policy,
 	}
 	dataA, err := json.Marshal(msgA)
 	if err != nil {
 		return fmt.Errorf("matching: marshal result for A: %w", err)
 	}
-	if err := nats.Publish(messaging.SubjectMatchFound+"."+candidate.SessionA, dataA); err != nil {
+	if err := bus.Publish(messaging.SubjectMatchFound+"."+candidate.SessionA, dataA); err != nil {
 		return fmt.Errorf("matching: publish match.found for %s: %w", candidate.SessionA, err)
 	}
 
 	// Notify session B (partner = A).
 	msgB := MatchResult{
-		ChatID:          chatID,
-		PartnerID:       candidate.SessionA,
-		SharedInterests: candidate.SharedInterests,
-		AcceptDeadline:  deadline,
+		ChatID:                chatID,
+		PartnerID:             candidate.SessionA,
+		SharedInterests:       candidate.SharedInterests,
+		AcceptDeadline:        deadline,
+		Tier:                  candidate.Tier,
+		PartnerWait:           int(candidate.Waits[0] / time.Second),
+		PartnerRegion:         candidate.Regions[0],
+		PartnerWaitTier:       candidate.WaitTiers[0],
+		PartnerOtherInterests: candidate.OtherInterests[0],
+		Policy:                candidate.Policy,
 	}
 	dataB, err := json.Marshal(msgB)
 	if err != nil {
 		return fmt.Errorf("matching: marshal result for B: %w", err)
 	}
-	if err := nats.Publish(messaging.SubjectMatchFound+"."+candidate.SessionB, dataB); err != nil {
+	if err := bus.Publish(messaging.SubjectMatchFound+"."+candidate.SessionB, dataB); err != nil {
 		return fmt.Errorf("matching: publish match.found for %s: %w", candidate.SessionB, err)
 	}
 
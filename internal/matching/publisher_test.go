@@ -0,0 +1,60 @@
+package matching
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestPublishMatchFound_TellsEachSideAboutThePartner(t *testing.T) {
+	bus := messaging.NewRedisBus(testutil.Redis(t), messaging.DefaultRedisBusConfig())
+	t.Cleanup(bus.Close)
+
+	results := make(map[string]chan MatchResult)
+	for _, sid := range []string{"user-a", "user-b"} {
+		ch := make(chan MatchResult, 1)
+		results[sid] = ch
+		if err := bus.SubscribeMatchFound(sid, func(data []byte) {
+			var r MatchResult
+			if err := json.Unmarshal(data, &r); err == nil {
+				ch <- r
+			}
+		}); err != nil {
+			t.Fatalf("SubscribeMatchFound %s: %v", sid, err)
+		}
+	}
+
+	candidate := &MatchCandidate{
+		SessionA:        "user-a",
+		SessionB:        "user-b",
+		SharedInterests: []string{"music"},
+		Tier:            TierOverlap,
+		Waits:           [2]time.Duration{12 * time.Second, 3500 * time.Millisecond},
+		WaitTiers:       [2]string{TierOverlap, TierExact},
+		Regions:         [2]string{"eu", "us"},
+		OtherInterests:  [2]int{2, 0},
+	}
+	if err := PublishMatchFound(bus, "chat-1", candidate); err != nil {
+		t.Fatalf("PublishMatchFound: %v", err)
+	}
+
+	want := map[string]MatchResult{
+		"user-a": {PartnerID: "user-b", Tier: TierOverlap, PartnerWait: 3, PartnerWaitTier: TierExact, PartnerRegion: "us"},
+		"user-b": {PartnerID: "user-a", Tier: TierOverlap, PartnerWait: 12, PartnerWaitTier: TierOverlap, PartnerRegion: "eu", PartnerOtherInterests: 2},
+	}
+	for sid, w := range want {
+		select {
+		case got := <-results[sid]:
+			if got.ChatID != "chat-1" || got.PartnerID != w.PartnerID || got.Tier != w.Tier ||
+				got.PartnerWait != w.PartnerWait || got.PartnerWaitTier != w.PartnerWaitTier ||
+				got.PartnerRegion != w.PartnerRegion || got.PartnerOtherInterests != w.PartnerOtherInterests {
+				t.Errorf("%s got %+v, want partner %+v", sid, got, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no match.found for %s", sid)
+		}
+	}
+}
@@ -9,35 +9,61 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/block"
+	"github.com/whisper/chat-app/internal/botdetect"
+	"github.com/whisper/chat-app/internal/session"
 )
 
 const (
 	// Redis key patterns for matching data structures.
-	keyMatchQueue     = "match:queue"        // Sorted set, score = join timestamp (ms)
+	keyMatchQueue     = "match:queue"        // Sorted set, score = join timestamp (ms), lowered for priority entries
 	keyExactPrefix    = "match:exact:"       // + <interests_hash> -> Set of session IDs
 	keyInterestPrefix = "match:interest:"    // + <tag> -> Set of session IDs
 	keySessionPrefix  = "match:session:"     // + <session_id> -> Hash
+	keyTopKPrefix     = "match:topk:"        // + <top_k_hash> -> Set of session IDs
+	keyPopularity     = "match:popularity"   // Sorted set, score = decayed count of times tag was queued
+	keyOrphanedPrefix = "match:orphaned:"    // + <session_id> -> marker for entries reaped with a dead server
+	keyBucketIndex    = "match:buckets"      // Set of exact and top-K set keys that may have members
 
 	// TTL for matching data structures (auto-expire stale keys).
 	matchKeyTTL = 60 * time.Second
+
+	// orphanTTL is how long a reconnecting client can still be told that its
+	// orphaned queue entry was dropped.
+	orphanTTL = 5 * time.Minute
 )
 
 // QueueEntry represents a user's state in the matching queue.
 type QueueEntry struct {
-	SessionID string
-	Interests []string
-	Hash      string  // SHA256 prefix of sorted interests
-	JoinedAt  float64 // Unix timestamp in milliseconds
+	SessionID   string
+	Interests   []string
+	Hash        string  // SHA256 prefix of sorted interests
+	TopKHash    string  // hash of the K most popular interests; empty for short lists
+	Server      string  // name of the wsserver that owns the client connection
+	Region      string  // bus region the request arrived from; empty when unpartitioned
+	Fingerprint string  // browser fingerprint at enqueue time, for block lists
+	Policy      string  // content policy preference at enqueue time, empty if none
+	Country     string  // GeoIP country of the client at enqueue time, empty if unknown
+	JoinedAt    float64 // Unix timestamp in milliseconds
 }
 
 // Queue manages the Redis data structures for the matching queue.
 type Queue struct {
-	rdb *redis.Client
+	rdb    *redis.Client
+	blocks *block.Store
+	bots   *botdetect.Detector
+	clock  Clock
 }
 
 // NewQueue creates a new matching queue backed by Redis.
 func NewQueue(rdb *redis.Client) *Queue {
-	return &Queue{rdb: rdb}
+	return &Queue{rdb: rdb, blocks: block.NewStore(rdb), bots: botdetect.NewDetector(rdb, botdetect.DefaultConfig()), clock: SystemClock{}}
+}
+
+// SetClock replaces the clock that stamps join times. It must be called
+// before the queue is used.
+func (q *Queue) SetClock(c Clock) {
+	q.clock = c
 }
 
 // InterestsHash computes a deterministic hash of the interest set.
@@ -52,19 +78,72 @@ func InterestsHash(interests []string) string {
 }
 
 // Enqueue adds a user to the matching queue and all associated data structures.
+// Users with more than topKInterests tags are additionally indexed by their
+// most popular tags so that long lists can still hit the exact tier.
 func (q *Queue) Enqueue(ctx context.Context, sessionID string, interests []string) error {
+	return q.EnqueueFrom(ctx, sessionID, "", "", interests)
+}
+
+// EnqueueFrom is like Enqueue but also records the wsserver that owns the
+// client connection, so entries can be reaped if that server dies, and the
+// region it publishes in, which the partner is told on a match.
+func (q *Queue) EnqueueFrom(ctx context.Context, sessionID, server, region string, interests []string) error {
+	now := float64(q.clock.Now().UnixMilli())
+	return q.enqueueAt(ctx, sessionID, server, region, interests, now, now)
+}
+
+// EnqueuePriority is like EnqueueFrom but places the entry ahead of everyone
+// currently waiting, so it is considered first on the next matching pass.
+// The recorded join time is still now, so tier escalation is unaffected.
+func (q *Queue) EnqueuePriority(ctx context.Context, sessionID, server, region string, interests []string) error {
+	now := float64(q.clock.Now().UnixMilli())
+	return q.enqueueAt(ctx, sessionID, server, region, interests, now, now-float64(MaxMatchTimeout.Milliseconds()))
+}
+
+// enqueueAt adds a queue entry with an explicit join time in Unix
+// milliseconds, which drives tier escalation, and queue score, which orders
+// the matching pass. The two only differ for priority entries.
+func (q *Queue) enqueueAt(ctx context.Context, sessionID, server, region string, interests []string, now, score float64) error {
 	hash := InterestsHash(interests)
-	now := float64(time.Now().UnixMilli())
+
+	// The fingerprint, content policy and country live on the wsserver
+	// session; snapshot them so block lists, policies and country
+	// preference can be checked without a session lookup per candidate.
+	values, err := q.rdb.HMGet(ctx, session.SessionPrefix+sessionID, "fingerprint", "policy", "country").Result()
+	if err != nil {
+		return err
+	}
+	fingerprint, _ := values[0].(string)
+	policy, _ := values[1].(string)
+	country, _ := values[2].(string)
+
+	scores, err := q.recordPopularity(ctx, interests)
+	if err != nil {
+		return err
+	}
+	topKHash := ""
+	if len(interests) > topKInterests {
+		topKHash = InterestsHash(TopKInterests(interests, scores, topKInterests))
+	}
 
 	pipe := q.rdb.Pipeline()
 
 	// Global sorted queue (score = timestamp for wait-time ordering).
-	pipe.ZAdd(ctx, keyMatchQueue, redis.Z{Score: now, Member: sessionID})
+	pipe.ZAdd(ctx, keyMatchQueue, redis.Z{Score: score, Member: sessionID})
 
 	// Exact-match set (all users with identical interest hash).
 	exactKey := keyExactPrefix + hash
 	pipe.SAdd(ctx, exactKey, sessionID)
 	pipe.Expire(ctx, exactKey, matchKeyTTL)
+	pipe.SAdd(ctx, keyBucketIndex, exactKey)
+
+	// Top-K set (users whose most popular interests are identical).
+	if topKHash != "" {
+		topKKey := keyTopKPrefix + topKHash
+		pipe.SAdd(ctx, topKKey, sessionID)
+		pipe.Expire(ctx, topKKey, matchKeyTTL)
+		pipe.SAdd(ctx, keyBucketIndex, topKKey)
+	}
 
 	// Per-interest sets (for overlap matching).
 	for _, tag := range interests {
@@ -76,13 +155,19 @@ func (q *Queue) Enqueue(ctx context.Context, sessionID string, interests []strin
 	// Session match metadata.
 	sessionKey := keySessionPrefix + sessionID
 	pipe.HSet(ctx, sessionKey, map[string]interface{}{
-		"interests": strings.Join(interests, ","),
-		"hash":      hash,
-		"joined_at": fmt.Sprintf("%.0f", now),
+		"interests":   strings.Join(interests, ","),
+		"hash":        hash,
+		"topk_hash":   topKHash,
+		"server":      server,
+		"region":      region,
+		"fingerprint": fingerprint,
+		"policy":      policy,
+		"country":     country,
+		"joined_at":   fmt.Sprintf("%.0f", now),
 	})
 	pipe.Expire(ctx, sessionKey, matchKeyTTL)
 
-	_, err := pipe.Exec(ctx)
+	_, err = pipe.Exec(ctx)
 	return err
 }
 
@@ -100,6 +185,9 @@ func (q *Queue) Dequeue(ctx context.Context, sessionID string) error {
 
 	pipe.ZRem(ctx, keyMatchQueue, sessionID)
 	pipe.SRem(ctx, keyExactPrefix+entry.Hash, sessionID)
+	if entry.TopKHash != "" {
+		pipe.SRem(ctx, keyTopKPrefix+entry.TopKHash, sessionID)
+	}
 
 	for _, tag := range entry.Interests {
 		pipe.SRem(ctx, keyInterestPrefix+tag, sessionID)
@@ -121,7 +209,32 @@ func (q *Queue) GetEntry(ctx context.Context, sessionID string) (*QueueEntry, er
 	if len(result) == 0 {
 		return nil, nil
 	}
+	return parseEntry(sessionID, result), nil
+}
 
+// getEntries retrieves the queue entries of several sessions in one round
+// trip. Sessions with no entry are left out.
+func (q *Queue) getEntries(ctx context.Context, sessionIDs []string) ([]*QueueEntry, error) {
+	pipe := q.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(sessionIDs))
+	for i, sid := range sessionIDs {
+		cmds[i] = pipe.HGetAll(ctx, keySessionPrefix+sid)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	entries := make([]*QueueEntry, 0, len(sessionIDs))
+	for i, cmd := range cmds {
+		if result := cmd.Val(); len(result) > 0 {
+			entries = append(entries, parseEntry(sessionIDs[i], result))
+		}
+	}
+	return entries, nil
+}
+
+// parseEntry builds a QueueEntry from its session metadata hash.
+func parseEntry(sessionID string, result map[string]string) *QueueEntry {
 	var interests []string
 	if result["interests"] != "" {
 		interests = strings.Split(result["interests"], ",")
@@ -133,11 +246,17 @@ func (q *Queue) GetEntry(ctx context.Context, sessionID string) (*QueueEntry, er
 	}
 
 	return &QueueEntry{
-		SessionID: sessionID,
-		Interests: interests,
-		Hash:      result["hash"],
-		JoinedAt:  joinedAt,
-	}, nil
+		SessionID:   sessionID,
+		Interests:   interests,
+		Hash:        result["hash"],
+		TopKHash:    result["topk_hash"],
+		Server:      result["server"],
+		Region:      result["region"],
+		Fingerprint: result["fingerprint"],
+		Policy:      result["policy"],
+		Country:     result["country"],
+		JoinedAt:    joinedAt,
+	}
 }
 
 // GetAllQueued returns all session IDs in the queue, ordered by join time (oldest first).
@@ -145,6 +264,16 @@ func (q *Queue) GetAllQueued(ctx context.Context) ([]string, error) {
 	return q.rdb.ZRange(ctx, keyMatchQueue, 0, -1).Result()
 }
 
+// ListQueued returns the entries of up to limit queued sessions, oldest
+// first. Sessions whose entry has expired are left out.
+func (q *Queue) ListQueued(ctx context.Context, limit int) ([]*QueueEntry, error) {
+	queued, err := q.rdb.ZRange(ctx, keyMatchQueue, 0, int64(limit)-1).Result()
+	if err != nil || len(queued) == 0 {
+		return nil, err
+	}
+	return q.getEntries(ctx, queued)
+}
+
 // IsQueued checks if a session is currently in the matching queue.
 func (q *Queue) IsQueued(ctx context.Context, sessionID string) (bool, error) {
 	_, err := q.rdb.ZScore(ctx, keyMatchQueue, sessionID).Result()
@@ -162,6 +291,11 @@ func (q *Queue) GetExactCandidates(ctx context.Context, hash string) ([]string,
 	return q.rdb.SMembers(ctx, keyExactPrefix+hash).Result()
 }
 
+// GetTopKCandidates returns all session IDs with the same top-K interest hash.
+func (q *Queue) GetTopKCandidates(ctx context.Context, topKHash string) ([]string, error) {
+	return q.rdb.SMembers(ctx, keyTopKPrefix+topKHash).Result()
+}
+
 // GetInterestCandidates returns all session IDs interested in the given tag.
 func (q *Queue) GetInterestCandidates(ctx context.Context, tag string) ([]string, error) {
 	return q.rdb.SMembers(ctx, keyInterestPrefix+tag).Result()
@@ -181,6 +315,9 @@ func (q *Queue) RefreshTTLs(ctx context.Context, sessionID string) error {
 
 	pipe := q.rdb.Pipeline()
 	pipe.Expire(ctx, keyExactPrefix+entry.Hash, matchKeyTTL)
+	if entry.TopKHash != "" {
+		pipe.Expire(ctx, keyTopKPrefix+entry.TopKHash, matchKeyTTL)
+	}
 	for _, tag := range entry.Interests {
 		pipe.Expire(ctx, keyInterestPrefix+tag, matchKeyTTL)
 	}
@@ -188,3 +325,19 @@ func (q *Queue) RefreshTTLs(ctx context.Context, sessionID string) error {
 	_, err = pipe.Exec(ctx)
 	return err
 }
+
+// MarkOrphaned records that a session's queue entry was dropped because its
+// wsserver died, so the client can be notified if it reconnects.
+func (q *Queue) MarkOrphaned(ctx context.Context, sessionID string) error {
+	return q.rdb.Set(ctx, keyOrphanedPrefix+sessionID, 1, orphanTTL).Err()
+}
+
+// ClearOrphaned removes the orphan marker for a session and reports whether
+// one existed.
+func (q *Queue) ClearOrphaned(ctx context.Context, sessionID string) (bool, error) {
+	n, err := q.rdb.Del(ctx, keyOrphanedPrefix+sessionID).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
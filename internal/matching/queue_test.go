@@ -2,6 +2,8 @@ package matching
 
 import (
 	"testing"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func TestInterestsHash_OrderIndependent(t *testing.T) {
@@ -127,3 +129,59 @@ func TestInterestsHash_Deterministic(t *testing.T) {
 		}
 	}
 }
+
+func TestTopKInterests_ByPopularity(t *testing.T) {
+	scores := map[string]float64{"music": 50, "gaming": 40, "anime": 30, "knitting": 2, "chess": 5}
+	got := TopKInterests([]string{"knitting", "chess", "music", "anime", "gaming"}, scores, 3)
+
+	want := []string{"anime", "gaming", "music"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("index %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestTopKInterests_TiesBrokenAlphabetically(t *testing.T) {
+	scores := map[string]float64{"b": 1, "a": 1, "d": 1, "c": 1}
+	got := TopKInterests([]string{"d", "c", "b", "a"}, scores, 3)
+
+	if InterestsHash(got) != InterestsHash([]string{"a", "b", "c"}) {
+		t.Errorf("expected [a b c], got %v", got)
+	}
+}
+
+func TestTopKInterests_ShortListAndDuplicates(t *testing.T) {
+	got := TopKInterests([]string{"music", "music"}, nil, 3)
+	if len(got) != 1 || got[0] != "music" {
+		t.Errorf("expected [music], got %v", got)
+	}
+}
+
+func TestSharedInterests(t *testing.T) {
+	got := sharedInterests([]string{"music", "gaming", "anime"}, []string{"anime", "chess", "music"})
+	if len(got) != 2 || got[0] != "anime" || got[1] != "music" {
+		t.Errorf("expected [anime music], got %v", got)
+	}
+}
+
+func TestPopularFromZ(t *testing.T) {
+	got := popularFromZ([]redis.Z{
+		{Member: "music", Score: 12.5},
+		{Member: 42, Score: 3}, // non-string members are skipped
+		{Member: "chess", Score: 0.4},
+	})
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 interests, got %v", got)
+	}
+	if got[0] != (PopularInterest{Tag: "music", Score: 12.5}) {
+		t.Errorf("unexpected first interest: %+v", got[0])
+	}
+	if got[1] != (PopularInterest{Tag: "chess", Score: 0.4}) {
+		t.Errorf("unexpected second interest: %+v", got[1])
+	}
+}
@@ -4,33 +4,90 @@ import (
 	"context"
 )
 
+// randomCountryWindow is how many of the longest waiting users the random
+// tier looks through for one in the user's own country before settling for
+// the longest waiting one.
+const randomCountryWindow = 32
+
 // TryRandomMatch attempts Tier 4 matching: pair with any other queued user
 // regardless of interests. The queue is ordered by join time (oldest first),
-// so picking the first non-self entry is fair. Returns nil if no other user
-// is queued.
+// so picking the first non-self entry is fair. When GeoIP located the user,
+// a user from the same country among the first randomCountryWindow entries
+// is preferred, as a shared country usually means a shared language and
+// time zone. Candidates the user has a personal block with are still
+// skipped. Returns nil if no other user is queued.
 func (q *Queue) TryRandomMatch(ctx context.Context, sessionID string) (*MatchCandidate, error) {
+	entry, err := q.GetEntry(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
 	allQueued, err := q.GetAllQueued(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, candidateID := range allQueued {
+	var countries map[string]string
+	if entry != nil && entry.Country != "" {
+		countries = q.queuedCountries(ctx, allQueued[:min(len(allQueued), randomCountryWindow)])
+	}
+
+	fallback := ""
+	for i, candidateID := range allQueued {
 		if candidateID == sessionID {
 			continue
 		}
+		if fallback != "" && i >= randomCountryWindow {
+			break
+		}
 
 		// Validate candidate is still in the queue.
 		queued, err := q.IsQueued(ctx, candidateID)
 		if err != nil || !queued {
 			continue
 		}
+		if q.excludedPair(ctx, entry, candidateID) {
+			continue
+		}
 
-		return &MatchCandidate{
-			SessionA:        sessionID,
-			SessionB:        candidateID,
-			SharedInterests: nil, // no shared interests (random pairing)
-		}, nil
+		if countries == nil || countries[candidateID] == entry.Country {
+			return randomCandidate(sessionID, candidateID), nil
+		}
+		if fallback == "" {
+			fallback = candidateID
+		}
 	}
 
+	if fallback != "" {
+		return randomCandidate(sessionID, fallback), nil
+	}
 	return nil, nil
 }
+
+// queuedCountries returns the country each queued session's entry recorded
+// at enqueue time, for those that have one. The live session is not
+// consulted, so a user is matched on where they were when they joined. A
+// lookup error leaves the result empty, so matching falls back to join
+// order.
+func (q *Queue) queuedCountries(ctx context.Context, sessionIDs []string) map[string]string {
+	entries, err := q.getEntries(ctx, sessionIDs)
+	if err != nil {
+		return map[string]string{}
+	}
+	countries := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if e.Country != "" {
+			countries[e.SessionID] = e.Country
+		}
+	}
+	return countries
+}
+
+func randomCandidate(sessionID, candidateID string) *MatchCandidate {
+	return &MatchCandidate{
+		SessionA:        sessionID,
+		SessionB:        candidateID,
+		SharedInterests: nil, // no shared interests (random pairing)
+		Tier:            TierRandom,
+	}
+}
@@ -4,26 +4,27 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/whisper/chat-app/internal/chat"
 	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/pkg/protocol"
 )
 
-const (
-	matchInterval = 2 * time.Second
-	tier1MaxWait  = 10 * time.Second // 0-10s: exact match only
-	tier2MaxWait  = 20 * time.Second // 10-20s: overlap matching
-	tier3MaxWait  = 25 * time.Second // 20-25s: single-interest fallback
-	matchTimeout  = 30 * time.Second // 25-30s: random matching, then give up
-)
+const matchInterval = 2 * time.Second
 
 // MatchRequest is the NATS payload sent by wsserver when a user starts matching.
 type MatchRequest struct {
 	SessionID string   `json:"session_id"`
 	Interests []string `json:"interests"`
+	Server    string   `json:"server,omitempty"`   // owning wsserver, for orphan detection
+	Region    string   `json:"region,omitempty"`   // the wsserver's bus region, shown to the partner
+	Priority  bool     `json:"priority,omitempty"` // re-roll: consider ahead of the rest of the queue
 }
 
 // CancelRequest is the NATS payload sent by wsserver when a user cancels.
@@ -31,41 +32,99 @@ type CancelRequest struct {
 	SessionID string `json:"session_id"`
 }
 
+// ResumeRequest is the NATS payload sent by wsserver when a reconnecting
+// client claims a session that was matching on a server that went away.
+type ResumeRequest struct {
+	PreviousSessionID string `json:"previous_session_id"`
+	SessionID         string `json:"session_id"`
+}
+
 // Service is the background matching service that pairs users based on
 // shared interests using tiered algorithms.
 type Service struct {
 	queue     *Queue
-	nats      *messaging.NATSClient
+	bus       messaging.Bus
 	rdb       *redis.Client
 	chatStore *chat.Store
+	events    *EventLog
+	latency   latencyWindow
+	tiers     atomic.Pointer[TierConfig]
+	clock     Clock
+	paused    func() bool
 	ctx       context.Context
 	cancel    context.CancelFunc
 }
 
-// NewService creates a new matching service.
-func NewService(rdb *redis.Client, nats *messaging.NATSClient) *Service {
+// NewService creates a new matching service using the given tier
+// thresholds, which must be valid (see TierConfig.Validate).
+func NewService(rdb *redis.Client, bus messaging.Bus, tiers TierConfig) *Service {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Service{
+	s := &Service{
 		queue:     NewQueue(rdb),
-		nats:      nats,
+		bus:       bus,
 		rdb:       rdb,
 		chatStore: chat.NewStore(rdb),
+		events:    NewEventLog(rdb),
+		clock:     SystemClock{},
 		ctx:       ctx,
 		cancel:    cancel,
 	}
+	s.tiers.Store(&tiers)
+	setTierMetrics(tiers)
+	return s
+}
+
+// SetClock replaces the clock that join times and waits are measured with,
+// for the service and its queue. It must be called before Start.
+func (s *Service) SetClock(c Clock) {
+	s.clock = c
+	s.queue.SetClock(c)
+}
+
+// SetPaused registers a check, e.g. maintenance mode, that pauses matching
+// while it reports true. It must be called before Start.
+func (s *Service) SetPaused(paused func() bool) {
+	s.paused = paused
+}
+
+// isPaused reports whether matching is paused.
+func (s *Service) isPaused() bool {
+	return s.paused != nil && s.paused()
+}
+
+// Tiers returns the tier thresholds in effect.
+func (s *Service) Tiers() TierConfig {
+	return *s.tiers.Load()
+}
+
+// SetTiers validates and applies new tier thresholds. They take effect on
+// the next matching pass, for users already waiting too.
+func (s *Service) SetTiers(tiers TierConfig) error {
+	if err := tiers.Validate(); err != nil {
+		return err
+	}
+	s.tiers.Store(&tiers)
+	setTierMetrics(tiers)
+	return nil
 }
 
-// Start subscribes to NATS subjects and starts the matching loop.
+// Start subscribes to the match subjects and starts the matching loop.
 func (s *Service) Start() error {
-	if err := s.nats.SubscribeMatchRequest(s.handleMatchRequest); err != nil {
+	if err := s.bus.SubscribeMatchRequest(s.handleMatchRequest); err != nil {
+		return err
+	}
+	if err := s.bus.SubscribeMatchCancel(s.handleCancelRequest); err != nil {
 		return err
 	}
-	if err := s.nats.SubscribeMatchCancel(s.handleCancelRequest); err != nil {
+	if err := s.bus.SubscribeMatchResume(s.handleResumeRequest); err != nil {
+		return err
+	}
+	if err := s.bus.SubscribeMatchTiers(s.handleTiersUpdate); err != nil {
 		return err
 	}
 
 	go s.matchLoop()
-	go StartCleanup(s.ctx, s.queue, s.rdb, s.nats)
+	go StartCleanup(s.ctx, s.queue, s.chatStore, s.events, s.rdb, s.bus)
 
 	log.Println("[matcher] service started")
 	return nil
@@ -84,14 +143,30 @@ func (s *Service) handleMatchRequest(data []byte) {
 		return
 	}
 
-	if err := s.queue.Enqueue(s.ctx, req.SessionID, req.Interests); err != nil {
+	// wsserver validates interests already; never let a bad producer
+	// write oversized keys.
+	if clean := protocol.SanitizeInterests(req.Interests); len(clean) != len(req.Interests) {
+		log.Printf("[matcher] dropped %d invalid interests from %s", len(req.Interests)-len(clean), req.SessionID)
+		req.Interests = clean
+	}
+
+	enqueue := s.queue.EnqueueFrom
+	if req.Priority {
+		enqueue = s.queue.EnqueuePriority
+	}
+	if err := enqueue(s.ctx, req.SessionID, req.Server, req.Region, req.Interests); err != nil {
 		log.Printf("[matcher] enqueue %s: %v", req.SessionID, err)
 		return
 	}
+	e := Event{Type: EventEnqueued, Interests: req.Interests}
+	if req.Priority {
+		e.Detail = "priority"
+	}
+	s.events.Record(s.ctx, req.SessionID, e)
 
 	size, _ := s.queue.QueueSize(s.ctx)
-	log.Printf("[matcher] enqueued %s with interests %v (queue size: %d)",
-		req.SessionID, req.Interests, size)
+	log.Printf("[matcher] enqueued %s with interests %v (queue size: %d, priority: %t)",
+		req.SessionID, req.Interests, size, req.Priority)
 }
 
 func (s *Service) handleCancelRequest(data []byte) {
@@ -106,13 +181,71 @@ func (s *Service) handleCancelRequest(data []byte) {
 		return
 	}
 
+	s.events.Record(s.ctx, req.SessionID, Event{Type: EventCancelled})
 	log.Printf("[matcher] dequeued %s (cancelled)", req.SessionID)
 }
 
-// matchLoop runs the core matching algorithm every 2 seconds.
+// handleResumeRequest settles the matching state of a session that was lost
+// with its wsserver. The wsserver has already verified that the reconnecting
+// client owns the previous session. If the previous session is still queued
+// (or was reaped as an orphan) it is removed and the client is told, via its
+// new session, that matching timed out so it can start over.
+func (s *Service) handleResumeRequest(data []byte) {
+	var req ResumeRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		log.Printf("[matcher] invalid resume request: %v", err)
+		return
+	}
+
+	queued, err := s.queue.IsQueued(s.ctx, req.PreviousSessionID)
+	if err != nil {
+		log.Printf("[matcher] resume: queue check %s: %v", req.PreviousSessionID, err)
+		return
+	}
+	orphaned, err := s.queue.ClearOrphaned(s.ctx, req.PreviousSessionID)
+	if err != nil {
+		log.Printf("[matcher] resume: orphan check %s: %v", req.PreviousSessionID, err)
+		return
+	}
+	if !queued && !orphaned {
+		return
+	}
+
+	if queued {
+		if err := s.queue.Dequeue(s.ctx, req.PreviousSessionID); err != nil {
+			log.Printf("[matcher] resume: dequeue %s: %v", req.PreviousSessionID, err)
+		}
+	}
+	s.publishTimeout(req.SessionID)
+	s.events.Record(s.ctx, req.PreviousSessionID, Event{Type: EventDropped, Detail: "resumed as " + req.SessionID})
+	s.events.Record(s.ctx, req.SessionID, Event{Type: EventResumed, Detail: "resumed from " + req.PreviousSessionID + ", whose server went away while matching"})
+
+	log.Printf("[matcher] resumed %s as %s (match_timeout sent)", req.PreviousSessionID, req.SessionID)
+}
+
+// handleTiersUpdate applies tier thresholds published on match.tiers. An
+// invalid update is logged and the current thresholds are kept.
+func (s *Service) handleTiersUpdate(data []byte) {
+	var tiers TierConfig
+	if err := json.Unmarshal(data, &tiers); err != nil {
+		log.Printf("[matcher] invalid tiers update: %v", err)
+		return
+	}
+	previous := s.Tiers()
+	if err := s.SetTiers(tiers); err != nil {
+		log.Printf("[matcher] tiers update rejected, keeping %s: %v", previous, err)
+		return
+	}
+	log.Printf("[matcher] tiers updated %s -> %s", previous, tiers)
+}
+
+// matchLoop runs the core matching algorithm every 2 seconds and publishes
+// queue statistics for wsservers every statsInterval.
 func (s *Service) matchLoop() {
 	ticker := time.NewTicker(matchInterval)
 	defer ticker.Stop()
+	statsTicker := time.NewTicker(statsInterval)
+	defer statsTicker.Stop()
 
 	for {
 		select {
@@ -121,20 +254,75 @@ func (s *Service) matchLoop() {
 			return
 		case <-ticker.C:
 			s.processQueue()
+		case <-statsTicker.C:
+			s.publishStats()
 		}
 	}
 }
 
-// processQueue iterates through all queued users and attempts to match them
-// using tiered algorithms based on wait time.
+// publishStats publishes the queue size and the median wait of recent
+// matches on match.stats.
+func (s *Service) publishStats() {
+	size, err := s.queue.QueueSize(s.ctx)
+	if err != nil {
+		return
+	}
+	now := s.clock.Now()
+	median, samples := s.latency.median(now)
+	tiers := s.Tiers()
+	data, _ := json.Marshal(MatchStats{
+		QueueSize:    size,
+		MedianWaitMs: median.Milliseconds(),
+		Samples:      samples,
+		Tiers:        &tiers,
+		Paused:       s.isPaused(),
+		Ts:           now.UnixMilli(),
+	})
+	if err := s.bus.PublishMatchStats(data); err != nil {
+		log.Printf("[matcher] publish stats: %v", err)
+	}
+}
+
+// processQueue runs one matching pass. Exact matches are made bucket by
+// bucket (see PairBucket); the users who have waited long enough for the
+// broader tiers, and priority entries, are then tried one by one using
+// tiered algorithms based on wait time. While matching is paused nobody is
+// paired, but users still time out.
 func (s *Service) processQueue() {
 	ctx := s.ctx
-	sessionIDs, err := s.queue.GetAllQueued(ctx)
+	start := time.Now()
+	defer func() {
+		metrics.MatchLoopDuration.Observe(time.Since(start).Seconds())
+	}()
+	now := s.clock.Now()
+
+	size, err := s.queue.QueueSize(ctx)
+	if err != nil {
+		log.Printf("[matcher] failed to get queue size: %v", err)
+		return
+	}
+	metrics.MatchQueueSize.Set(float64(size))
+
+	// One snapshot per pass, so an update never applies to half the queue.
+	tiers := s.Tiers()
+	paused := s.isPaused()
+
+	if !paused {
+		s.pairBuckets(ctx)
+	}
+
+	aged := float64(now.UnixMilli()) - float64(tiers.Tier1MaxWait.Milliseconds())
+	sessionIDs, err := s.queue.GetAgedQueued(ctx, aged)
 	if err != nil {
 		log.Printf("[matcher] failed to get queue: %v", err)
 		return
 	}
 
+	// Every aged session that stays queued gets an attempt event; record
+	// them together at the end of the pass.
+	attempts := s.events.Batch()
+	defer attempts.Flush(ctx)
+
 	for _, sid := range sessionIDs {
 		// Re-check: user may have been matched earlier in this cycle.
 		queued, err := s.queue.IsQueued(ctx, sid)
@@ -147,41 +335,49 @@ func (s *Service) processQueue() {
 			continue
 		}
 
-		waitMs := float64(time.Now().UnixMilli()) - entry.JoinedAt
+		waitMs := float64(s.clock.Now().UnixMilli()) - entry.JoinedAt
 		waitDuration := time.Duration(waitMs) * time.Millisecond
 
-		// MATCH-6: 30s timeout — no match found, give up.
-		if waitDuration >= matchTimeout {
-			s.handleTimeout(ctx, sid)
+		// MATCH-6: timeout — no match found, give up.
+		if waitDuration >= tiers.MatchTimeout {
+			s.handleTimeout(ctx, sid, tiers.MatchTimeout)
+			continue
+		}
+		if paused {
+			attempts.Record(ctx, sid, Event{Type: EventAttempt, WaitMs: waitDuration.Milliseconds(), Detail: "matching paused"})
 			continue
 		}
 
 		var match *MatchCandidate
 
 		// Tier 1: Exact match (always attempted).
+		tried := TierExact
 		match, err = s.queue.TryExactMatch(ctx, sid)
 		if err != nil {
 			log.Printf("[matcher] exact match error for %s: %v", sid, err)
 		}
 
-		// Tier 2: Overlap match (after 10s wait).
-		if match == nil && waitDuration >= tier1MaxWait {
+		// Tier 2: Overlap match (after Tier1MaxWait).
+		if match == nil && waitDuration >= tiers.Tier1MaxWait {
+			tried = TierOverlap
 			match, err = s.queue.TryOverlapMatch(ctx, sid)
 			if err != nil {
 				log.Printf("[matcher] overlap match error for %s: %v", sid, err)
 			}
 		}
 
-		// Tier 3: Single-interest fallback (after 20s wait).
-		if match == nil && waitDuration >= tier2MaxWait {
+		// Tier 3: Single-interest fallback (after Tier2MaxWait).
+		if match == nil && waitDuration >= tiers.Tier2MaxWait {
+			tried = TierSingle
 			match, err = s.queue.TrySingleInterestMatch(ctx, sid)
 			if err != nil {
 				log.Printf("[matcher] single-interest match error for %s: %v", sid, err)
 			}
 		}
 
-		// Tier 4: Random matching (after 25s wait).
-		if match == nil && waitDuration >= tier3MaxWait {
+		// Tier 4: Random matching (after Tier3MaxWait).
+		if match == nil && waitDuration >= tiers.Tier3MaxWait {
+			tried = TierRandom
 			match, err = s.queue.TryRandomMatch(ctx, sid)
 			if err != nil {
 				log.Printf("[matcher] random match error for %s: %v", sid, err)
@@ -189,7 +385,60 @@ func (s *Service) processQueue() {
 		}
 
 		if match != nil {
+			// The partner's attempt earlier in this pass goes first.
+			attempts.Flush(ctx)
 			s.handleMatch(ctx, match)
+			continue
+		}
+		attempts.Record(ctx, sid, Event{Type: EventAttempt, Tier: tried, WaitMs: waitDuration.Milliseconds()})
+	}
+}
+
+// pairBuckets makes the exact matches of a pass. A session is in at most one
+// exact and one top-K bucket, so the exact buckets are paired concurrently,
+// and then the top-K buckets, without two workers ever pairing the same
+// session.
+func (s *Service) pairBuckets(ctx context.Context) {
+	exact, topK, err := s.queue.Buckets(ctx)
+	if err != nil {
+		log.Printf("[matcher] failed to get buckets: %v", err)
+		return
+	}
+
+	for _, keys := range [][]string{exact, topK} {
+		work := make(chan string)
+		var wg sync.WaitGroup
+		for range min(bucketWorkers, len(keys)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for key := range work {
+					s.pairBucket(ctx, key)
+				}
+			}()
+		}
+		for _, key := range keys {
+			work <- key
+		}
+		close(work)
+		wg.Wait()
+	}
+}
+
+// pairBucket matches the members of one bucket, sampling again while a full
+// sample produced pairs.
+func (s *Service) pairBucket(ctx context.Context, key string) {
+	for {
+		pairs, sampled, err := s.queue.PairBucket(ctx, key)
+		if err != nil {
+			log.Printf("[matcher] pair bucket %s: %v", key, err)
+			return
+		}
+		for _, match := range pairs {
+			s.handleMatch(ctx, match)
+		}
+		if len(pairs) == 0 || sampled < bucketSample {
+			return
 		}
 	}
 }
@@ -197,6 +446,26 @@ func (s *Service) processQueue() {
 func (s *Service) handleMatch(ctx context.Context, match *MatchCandidate) {
 	chatID := uuid.New().String()
 
+	// Record how long both users waited, for the queue wait estimate, the
+	// per-tier wait histogram and the partner details in match_found.
+	now := s.clock.Now()
+	tiers := s.Tiers()
+	var policies [2]string
+	for i, sid := range []string{match.SessionA, match.SessionB} {
+		if entry, err := s.queue.GetEntry(ctx, sid); err == nil && entry != nil {
+			policies[i] = entry.Policy
+			wait := time.Duration(float64(now.UnixMilli())-entry.JoinedAt) * time.Millisecond
+			s.latency.record(wait, now)
+			metrics.MatchDuration.WithLabelValues(match.Tier).Observe(wait.Seconds())
+			match.Waits[i] = wait
+			match.WaitTiers[i] = tiers.WaitTier(wait)
+			match.Regions[i] = entry.Region
+			match.OtherInterests[i] = len(entry.Interests) - len(sharedInterests(entry.Interests, match.SharedInterests))
+		}
+	}
+	metrics.MatchesTotal.WithLabelValues(match.Tier).Inc()
+	match.Policy = chat.AgreePolicy(policies[0], policies[1])
+
 	// Remove both users from the queue.
 	if err := s.queue.Dequeue(ctx, match.SessionA); err != nil {
 		log.Printf("[matcher] dequeue %s: %v", match.SessionA, err)
@@ -205,29 +474,49 @@ func (s *Service) handleMatch(ctx context.Context, match *MatchCandidate) {
 		log.Printf("[matcher] dequeue %s: %v", match.SessionB, err)
 	}
 
-	// Create pending chat session in Redis (CHAT-6).
-	if err := s.chatStore.CreatePending(ctx, chatID, match.SessionA, match.SessionB); err != nil {
+	// Create pending chat session in Redis (CHAT-6), with a suggested
+	// icebreaker that both users see once the chat is accepted and the
+	// content policy its messages are filtered with.
+	if err := s.chatStore.CreatePending(ctx, chatID, match.SessionA, match.SessionB, chat.RandomIcebreaker(), match.Tier, match.SharedInterests, match.Policy); err != nil {
 		log.Printf("[matcher] create pending chat: %v", err)
 	}
 
-	// Publish match result to both users via NATS.
-	if err := PublishMatchFound(s.nats, chatID, match); err != nil {
+	// Publish match result to both users over the bus.
+	if err := PublishMatchFound(s.bus, chatID, match); err != nil {
 		log.Printf("[matcher] publish match: %v", err)
 	}
+
+	partners := [2]string{match.SessionB, match.SessionA}
+	for i, sid := range []string{match.SessionA, match.SessionB} {
+		s.events.Record(ctx, sid, Event{
+			Type:      EventMatchFound,
+			Tier:      match.Tier,
+			ChatID:    chatID,
+			Partner:   partners[i],
+			WaitMs:    match.Waits[i].Milliseconds(),
+			Interests: match.SharedInterests,
+		})
+	}
 }
 
 // handleTimeout removes a user from the queue and sends a timeout notification.
-func (s *Service) handleTimeout(ctx context.Context, sessionID string) {
+func (s *Service) handleTimeout(ctx context.Context, sessionID string, timeout time.Duration) {
 	if err := s.queue.Dequeue(ctx, sessionID); err != nil {
 		log.Printf("[matcher] timeout dequeue %s: %v", sessionID, err)
 	}
 
-	// Send timeout via match.found with Timeout flag.
+	s.publishTimeout(sessionID)
+	metrics.MatchTimeoutsTotal.Inc()
+	s.events.Record(ctx, sessionID, Event{Type: EventTimeout, WaitMs: timeout.Milliseconds()})
+
+	log.Printf("[matcher] timeout for %s (%s)", sessionID, timeout)
+}
+
+// publishTimeout sends a timeout result via match.found with the Timeout flag.
+func (s *Service) publishTimeout(sessionID string) {
 	msg := MatchResult{Timeout: true}
 	data, _ := json.Marshal(msg)
-	if err := s.nats.Publish(messaging.SubjectMatchFound+"."+sessionID, data); err != nil {
+	if err := s.bus.Publish(messaging.SubjectMatchFound+"."+sessionID, data); err != nil {
 		log.Printf("[matcher] publish timeout for %s: %v", sessionID, err)
 	}
-
-	log.Printf("[matcher] timeout for %s (30s)", sessionID)
 }
@@ -0,0 +1,270 @@
+package matching
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// virtualService is a Service on a ManualClock, driven one matching pass at a
+// time, with every match.found result it publishes collected per session.
+type virtualService struct {
+	t       *testing.T
+	s       *Service
+	clock   *ManualClock
+	bus     messaging.Bus
+	results map[string]chan MatchResult
+}
+
+func newVirtualService(t *testing.T, tiers TierConfig) *virtualService {
+	t.Helper()
+	rdb := testutil.Redis(t)
+	bus := messaging.NewRedisBus(rdb, messaging.DefaultRedisBusConfig())
+	t.Cleanup(bus.Close)
+	s := NewService(rdb, bus, tiers)
+	t.Cleanup(s.Stop)
+	clock := NewManualClock(time.Unix(1_700_000_000, 0))
+	s.SetClock(clock)
+	return &virtualService{t: t, s: s, clock: clock, bus: bus, results: make(map[string]chan MatchResult)}
+}
+
+// enqueue queues sid at the current virtual time and subscribes to its
+// results.
+func (v *virtualService) enqueue(sid string, interests ...string) {
+	v.t.Helper()
+	ch := make(chan MatchResult, 1)
+	v.results[sid] = ch
+	if err := v.bus.SubscribeMatchFound(sid, func(data []byte) {
+		var r MatchResult
+		if err := json.Unmarshal(data, &r); err == nil {
+			ch <- r
+		}
+	}); err != nil {
+		v.t.Fatalf("SubscribeMatchFound %s: %v", sid, err)
+	}
+	if err := v.s.queue.Enqueue(context.Background(), sid, interests); err != nil {
+		v.t.Fatalf("enqueue %s: %v", sid, err)
+	}
+}
+
+// setPolicy records the content policy sid asks for, as the wsserver does on
+// find_match before queueing it.
+func (v *virtualService) setPolicy(sid, policy string) {
+	v.t.Helper()
+	if err := v.s.queue.rdb.HSet(context.Background(), session.SessionPrefix+sid, "policy", policy).Err(); err != nil {
+		v.t.Fatalf("set policy %s: %v", sid, err)
+	}
+}
+
+// passAt advances the virtual clock to elapsed since the service was created
+// and runs one matching pass.
+func (v *virtualService) passAt(elapsed time.Duration) {
+	v.t.Helper()
+	start := time.Unix(1_700_000_000, 0)
+	if d := start.Add(elapsed).Sub(v.clock.Now()); d > 0 {
+		v.clock.Advance(d)
+	}
+	v.s.processQueue()
+}
+
+func (v *virtualService) queued(sid string) bool {
+	v.t.Helper()
+	queued, err := v.s.queue.IsQueued(context.Background(), sid)
+	if err != nil {
+		v.t.Fatalf("IsQueued %s: %v", sid, err)
+	}
+	return queued
+}
+
+// result waits for the match.found result of sid.
+func (v *virtualService) result(sid string) MatchResult {
+	v.t.Helper()
+	select {
+	case r := <-v.results[sid]:
+		return r
+	case <-time.After(2 * time.Second):
+		v.t.Fatalf("no match.found for %s", sid)
+		return MatchResult{}
+	}
+}
+
+func TestTierProgression_ExactMatchesImmediately(t *testing.T) {
+	v := newVirtualService(t, DefaultTierConfig())
+	v.enqueue("alice", "music", "chess")
+	v.enqueue("bob", "chess", "music")
+
+	v.passAt(0)
+
+	if r := v.result("alice"); r.PartnerID != "bob" || r.Tier != TierExact || r.PartnerWait != 0 {
+		t.Errorf("alice got %+v, want an exact match with bob", r)
+	}
+}
+
+func TestTierProgression_OverlapAtTier1(t *testing.T) {
+	tiers := DefaultTierConfig()
+	v := newVirtualService(t, tiers)
+	v.enqueue("alice", "music", "chess")
+	v.enqueue("bob", "music", "hiking")
+
+	v.passAt(0)
+	v.passAt(tiers.Tier1MaxWait - time.Millisecond)
+	if !v.queued("alice") || !v.queued("bob") {
+		t.Fatal("matched by overlap before Tier1MaxWait")
+	}
+
+	v.passAt(tiers.Tier1MaxWait)
+	if v.queued("alice") || v.queued("bob") {
+		t.Fatal("still queued at Tier1MaxWait")
+	}
+	r := v.result("alice")
+	if r.PartnerID != "bob" || r.Tier != TierOverlap || len(r.SharedInterests) != 1 || r.SharedInterests[0] != "music" {
+		t.Errorf("alice got %+v, want an overlap match with bob on music", r)
+	}
+	if r.PartnerWait != 10 || r.PartnerWaitTier != TierOverlap {
+		t.Errorf("partner wait = %ds (%s), want 10s (overlap)", r.PartnerWait, r.PartnerWaitTier)
+	}
+}
+
+func TestTierProgression_RandomAtTier3(t *testing.T) {
+	tiers := DefaultTierConfig()
+	v := newVirtualService(t, tiers)
+	v.enqueue("alice", "music")
+	v.enqueue("bob", "gardening")
+
+	for _, at := range []time.Duration{0, tiers.Tier1MaxWait, tiers.Tier2MaxWait, tiers.Tier3MaxWait - time.Millisecond} {
+		v.passAt(at)
+		if !v.queued("alice") || !v.queued("bob") {
+			t.Fatalf("matched at %v without shared interests before Tier3MaxWait", at)
+		}
+	}
+
+	v.passAt(tiers.Tier3MaxWait)
+	r := v.result("bob")
+	if r.Tier != TierRandom || len(r.SharedInterests) != 0 {
+		t.Errorf("bob got %+v, want a random match", r)
+	}
+	if r.PartnerID != "alice" || r.PartnerWaitTier != TierRandom {
+		t.Errorf("bob got partner %s (%s), want alice (random)", r.PartnerID, r.PartnerWaitTier)
+	}
+}
+
+func TestTierProgression_TimeoutAtMatchTimeout(t *testing.T) {
+	tiers := DefaultTierConfig()
+	v := newVirtualService(t, tiers)
+	v.enqueue("alice", "music")
+
+	v.passAt(tiers.MatchTimeout - time.Millisecond)
+	if !v.queued("alice") {
+		t.Fatal("timed out before MatchTimeout")
+	}
+
+	v.passAt(tiers.MatchTimeout)
+	if v.queued("alice") {
+		t.Fatal("still queued at MatchTimeout")
+	}
+	if r := v.result("alice"); !r.Timeout {
+		t.Errorf("alice got %+v, want a timeout", r)
+	}
+}
+
+func TestTierProgression_LateJoinerMatchesWaitingUser(t *testing.T) {
+	tiers := DefaultTierConfig()
+	v := newVirtualService(t, tiers)
+	v.enqueue("alice", "music", "chess")
+	v.passAt(4 * time.Second)
+	v.enqueue("bob", "music", "hiking")
+
+	// Alice reaches the overlap tier first and takes bob, who has waited
+	// 6 seconds.
+	v.passAt(tiers.Tier1MaxWait)
+	r := v.result("alice")
+	if r.PartnerID != "bob" || r.Tier != TierOverlap || r.PartnerWait != 6 || r.PartnerWaitTier != TierExact {
+		t.Errorf("alice got %+v, want overlap with bob after his 6s exact-only wait", r)
+	}
+	if r := v.result("bob"); r.PartnerWait != 10 {
+		t.Errorf("bob was told alice waited %ds, want 10s", r.PartnerWait)
+	}
+}
+
+func TestTierProgression_SetTiersAppliesToWaitingUsers(t *testing.T) {
+	v := newVirtualService(t, DefaultTierConfig())
+	v.enqueue("alice", "music")
+	v.passAt(6 * time.Second)
+
+	shorter := TierConfig{Tier1MaxWait: 2 * time.Second, Tier2MaxWait: 3 * time.Second, Tier3MaxWait: 4 * time.Second, MatchTimeout: 5 * time.Second}
+	if err := v.s.SetTiers(shorter); err != nil {
+		t.Fatalf("SetTiers: %v", err)
+	}
+	v.passAt(6 * time.Second)
+	if v.queued("alice") {
+		t.Fatal("alice, already past the new timeout, is still queued")
+	}
+	if r := v.result("alice"); !r.Timeout {
+		t.Errorf("alice got %+v, want a timeout", r)
+	}
+}
+
+func TestPaused_HoldsQueueButStillTimesOut(t *testing.T) {
+	tiers := DefaultTierConfig()
+	v := newVirtualService(t, tiers)
+	var paused bool
+	v.s.SetPaused(func() bool { return paused })
+	v.enqueue("alice", "music")
+	v.enqueue("bob", "music")
+
+	paused = true
+	v.passAt(0)
+	v.passAt(tiers.Tier3MaxWait)
+	if !v.queued("alice") || !v.queued("bob") {
+		t.Fatal("paired while paused")
+	}
+
+	v.enqueue("carol", "chess")
+	v.passAt(tiers.MatchTimeout)
+	if r := v.result("alice"); !r.Timeout {
+		t.Errorf("alice got %+v, want a timeout while paused", r)
+	}
+
+	paused = false
+	v.enqueue("dave", "chess")
+	v.passAt(tiers.MatchTimeout + time.Second)
+	if r := v.result("carol"); r.PartnerID != "dave" {
+		t.Errorf("carol got %+v, want dave once resumed", r)
+	}
+}
+
+func TestPolicy_ChatTakesStricterPolicy(t *testing.T) {
+	tiers := DefaultTierConfig()
+	v := newVirtualService(t, tiers)
+	// A user without a preference is paired with a strict one, and the
+	// chat takes the stricter policy.
+	v.setPolicy("alice", chat.PolicyStrict)
+	v.enqueue("alice", "music")
+	v.enqueue("carol", "music")
+	v.passAt(0)
+	r := v.result("alice")
+	if r.PartnerID != "carol" || r.Policy != chat.PolicyStrict {
+		t.Fatalf("alice got %+v, want carol under the strict policy", r)
+	}
+	if cs, err := v.s.chatStore.Get(context.Background(), r.ChatID); err != nil || cs == nil || cs.Policy != chat.PolicyStrict {
+		t.Errorf("chat = %+v, %v; want a strict chat", cs, err)
+	}
+}
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	c := NewManualClock(start)
+	if !c.Now().Equal(start) {
+		t.Fatalf("Now = %v, want %v", c.Now(), start)
+	}
+	c.Advance(1500 * time.Millisecond)
+	if got := c.Now().Sub(start); got != 1500*time.Millisecond {
+		t.Errorf("advanced by %v, want 1.5s", got)
+	}
+}
@@ -44,6 +44,9 @@ func (q *Queue) TrySingleInterestMatch(ctx context.Context, sessionID string) (*
 		if err != nil || !queued {
 			continue
 		}
+		if q.excludedPair(ctx, entry, candidateID) {
+			continue
+		}
 
 		sort.Strings(shared)
 
@@ -51,6 +54,7 @@ func (q *Queue) TrySingleInterestMatch(ctx context.Context, sessionID string) (*
 			SessionA:        sessionID,
 			SessionB:        candidateID,
 			SharedInterests: shared,
+			Tier:            TierSingle,
 		}, nil
 	}
 
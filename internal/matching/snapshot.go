@@ -0,0 +1,211 @@
+package matching
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/session"
+)
+
+// SnapshotVersion is the format version written by Queue.Snapshot. Restore
+// refuses snapshots written in any other format.
+const SnapshotVersion = 1
+
+// Snapshot is a point-in-time copy of the matching queue, taken so waiting
+// users can be re-enqueued after an emergency Redis flush or migration.
+type Snapshot struct {
+	Version    int                `json:"version"`
+	TakenAt    int64              `json:"taken_at"` // Unix milliseconds
+	Entries    []SnapshotEntry    `json:"entries"`  // oldest first
+	Popularity map[string]float64 `json:"popularity,omitempty"`
+}
+
+// SnapshotEntry is one queued session together with the wsserver session
+// hash it belonged to when the snapshot was taken.
+type SnapshotEntry struct {
+	SessionID   string            `json:"session_id"`
+	Interests   []string          `json:"interests"`
+	Server      string            `json:"server,omitempty"`
+	Region      string            `json:"region,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+	JoinedAt    int64             `json:"joined_at"` // Unix milliseconds
+	Session     map[string]string `json:"session,omitempty"`
+}
+
+// RestoreOptions controls how Restore treats snapshot entries.
+type RestoreOptions struct {
+	// DryRun reports what would be restored without writing to Redis.
+	DryRun bool
+
+	// RecreateSessions rewrites missing wsserver session hashes from the
+	// snapshot when the owning server is still alive. Use it after a full
+	// flush; the wsserver's disconnect handling cleans up sessions whose
+	// connection has gone away in the meantime.
+	RecreateSessions bool
+}
+
+// RestoreResult counts what happened to each snapshot entry.
+type RestoreResult struct {
+	Restored          int `json:"restored"`
+	AlreadyQueued     int `json:"already_queued"`
+	Disconnected      int `json:"disconnected"`
+	NotMatching       int `json:"not_matching"`
+	ServerDown        int `json:"server_down"`
+	SessionsRecreated int `json:"sessions_recreated"`
+}
+
+// Snapshot reads every queued session, its match metadata and its wsserver
+// session hash. Entries that leave the queue while the snapshot is being
+// taken are omitted.
+func (q *Queue) Snapshot(ctx context.Context) (*Snapshot, error) {
+	snap := &Snapshot{
+		Version: SnapshotVersion,
+		TakenAt: q.clock.Now().UnixMilli(),
+		Entries: []SnapshotEntry{},
+	}
+
+	sessionIDs, err := q.GetAllQueued(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("matching: snapshot queue: %w", err)
+	}
+	for _, sid := range sessionIDs {
+		entry, err := q.GetEntry(ctx, sid)
+		if err != nil {
+			return nil, fmt.Errorf("matching: snapshot entry %s: %w", sid, err)
+		}
+		if entry == nil {
+			continue
+		}
+		sess, err := q.rdb.HGetAll(ctx, session.SessionPrefix+sid).Result()
+		if err != nil {
+			return nil, fmt.Errorf("matching: snapshot session %s: %w", sid, err)
+		}
+		if len(sess) == 0 {
+			sess = nil
+		}
+		snap.Entries = append(snap.Entries, SnapshotEntry{
+			SessionID:   sid,
+			Interests:   entry.Interests,
+			Server:      entry.Server,
+			Region:      entry.Region,
+			Fingerprint: entry.Fingerprint,
+			JoinedAt:    int64(entry.JoinedAt),
+			Session:     sess,
+		})
+	}
+
+	popular, err := q.rdb.ZRangeWithScores(ctx, keyPopularity, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("matching: snapshot popularity: %w", err)
+	}
+	if len(popular) > 0 {
+		snap.Popularity = make(map[string]float64, len(popular))
+		for _, z := range popular {
+			snap.Popularity[z.Member.(string)] = z.Score
+		}
+	}
+
+	return snap, nil
+}
+
+// Restore re-enqueues snapshot entries whose session is still connected:
+// the session hash exists with status matching and its owning wsserver is
+// alive. Restored entries keep their relative order but get a fresh join
+// time, so they are not timed out for the wait spent during the outage.
+// Popularity scores are restored first, and only for tags that have none,
+// so top-K indexing of restored entries matches the pre-outage ranking.
+func (q *Queue) Restore(ctx context.Context, snap *Snapshot, opts RestoreOptions) (RestoreResult, error) {
+	var result RestoreResult
+	if snap.Version != SnapshotVersion {
+		return result, fmt.Errorf("matching: unsupported snapshot version %d", snap.Version)
+	}
+
+	if !opts.DryRun && len(snap.Popularity) > 0 {
+		members := make([]redis.Z, 0, len(snap.Popularity))
+		for tag, score := range snap.Popularity {
+			members = append(members, redis.Z{Score: score, Member: tag})
+		}
+		if err := q.rdb.ZAddNX(ctx, keyPopularity, members...).Err(); err != nil {
+			return result, fmt.Errorf("matching: restore popularity: %w", err)
+		}
+	}
+
+	alive := make(map[string]bool) // server name -> liveness, cached per restore
+	serverAlive := func(name string) (bool, error) {
+		if name == "" {
+			return true, nil
+		}
+		if v, ok := alive[name]; ok {
+			return v, nil
+		}
+		v, err := session.ServerAlive(ctx, q.rdb, name)
+		if err != nil {
+			return false, err
+		}
+		alive[name] = v
+		return v, nil
+	}
+
+	base := float64(q.clock.Now().UnixMilli()) - float64(len(snap.Entries))
+	for i, e := range snap.Entries {
+		queued, err := q.IsQueued(ctx, e.SessionID)
+		if err != nil {
+			return result, fmt.Errorf("matching: restore %s: %w", e.SessionID, err)
+		}
+		if queued {
+			result.AlreadyQueued++
+			continue
+		}
+
+		status, err := q.rdb.HGet(ctx, session.SessionPrefix+e.SessionID, "status").Result()
+		if err != nil && err != redis.Nil {
+			return result, fmt.Errorf("matching: restore %s: %w", e.SessionID, err)
+		}
+		recreate := false
+		if err == redis.Nil {
+			if !opts.RecreateSessions || e.Session["status"] != session.StatusMatching {
+				result.Disconnected++
+				continue
+			}
+			recreate = true
+		} else if status != session.StatusMatching {
+			result.NotMatching++
+			continue
+		}
+
+		up, err := serverAlive(e.Server)
+		if err != nil {
+			return result, fmt.Errorf("matching: restore %s: %w", e.SessionID, err)
+		}
+		if !up {
+			result.ServerDown++
+			continue
+		}
+
+		if opts.DryRun {
+			if recreate {
+				result.SessionsRecreated++
+			}
+			result.Restored++
+			continue
+		}
+
+		if recreate {
+			key := session.SessionPrefix + e.SessionID
+			pipe := q.rdb.TxPipeline()
+			pipe.HSet(ctx, key, e.Session)
+			pipe.Expire(ctx, key, session.SessionTTL)
+			if _, err := pipe.Exec(ctx); err != nil {
+				return result, fmt.Errorf("matching: recreate session %s: %w", e.SessionID, err)
+			}
+			result.SessionsRecreated++
+		}
+		if err := q.enqueueAt(ctx, e.SessionID, e.Server, e.Region, e.Interests, base+float64(i), base+float64(i)); err != nil {
+			return result, fmt.Errorf("matching: restore %s: %w", e.SessionID, err)
+		}
+		result.Restored++
+	}
+
+	return result, nil
+}
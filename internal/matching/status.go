@@ -0,0 +1,224 @@
+package matching
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// statsInterval is how often the matcher publishes MatchStats.
+	statsInterval = 5 * time.Second
+
+	// StatusInterval is how often wsservers send matching_status to clients
+	// waiting in the queue.
+	StatusInterval = 3 * time.Second
+
+	// latencyWindowSize is the number of recent match waits the estimate is
+	// computed from.
+	latencyWindowSize = 100
+
+	// latencyMaxAge drops samples from quiet periods so the estimate tracks
+	// current conditions.
+	latencyMaxAge = 10 * time.Minute
+)
+
+// MatchStats is published by the matcher on match.stats so wsservers can
+// tell waiting clients how long a match is likely to take.
+type MatchStats struct {
+	QueueSize    int64 `json:"queue_size"`
+	MedianWaitMs int64 `json:"median_wait_ms"`
+	Samples      int   `json:"samples"` // match waits behind the median; 0 means no estimate
+	Ts           int64 `json:"ts"`      // Unix milliseconds
+
+	// Tiers are the thresholds the publishing matcher is running with.
+	Tiers *TierConfig `json:"tiers,omitempty"`
+
+	// Paused is set while the matcher is not pairing anyone, e.g. during
+	// maintenance.
+	Paused bool `json:"paused,omitempty"`
+}
+
+// latencySample is one completed match wait.
+type latencySample struct {
+	wait time.Duration
+	at   time.Time
+}
+
+// latencyWindow keeps the most recent match waits in a ring buffer.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [latencyWindowSize]latencySample
+	next    int
+	count   int
+}
+
+// record adds a completed match wait observed at now.
+func (w *latencyWindow) record(wait time.Duration, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples[w.next] = latencySample{wait: wait, at: now}
+	w.next = (w.next + 1) % latencyWindowSize
+	if w.count < latencyWindowSize {
+		w.count++
+	}
+}
+
+// median returns the median of samples younger than latencyMaxAge and the
+// number of samples it was computed from.
+func (w *latencyWindow) median(now time.Time) (time.Duration, int) {
+	w.mu.Lock()
+	waits := make([]time.Duration, 0, w.count)
+	for i := 0; i < w.count; i++ {
+		if s := w.samples[i]; now.Sub(s.at) <= latencyMaxAge {
+			waits = append(waits, s.wait)
+		}
+	}
+	w.mu.Unlock()
+
+	if len(waits) == 0 {
+		return 0, 0
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	return waits[len(waits)/2], len(waits)
+}
+
+// Positions returns the 1-based queue position of each given session and the
+// current queue size. Sessions that are not queued are omitted.
+func (q *Queue) Positions(ctx context.Context, sessionIDs []string) (map[string]int64, int64, error) {
+	pipe := q.rdb.Pipeline()
+	sizeCmd := pipe.ZCard(ctx, keyMatchQueue)
+	rankCmds := make([]*redis.IntCmd, len(sessionIDs))
+	for i, sid := range sessionIDs {
+		rankCmds[i] = pipe.ZRank(ctx, keyMatchQueue, sid)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, 0, err
+	}
+
+	positions := make(map[string]int64, len(sessionIDs))
+	for i, cmd := range rankCmds {
+		rank, err := cmd.Result()
+		if err != nil {
+			continue // not queued (yet, or any more)
+		}
+		positions[sessionIDs[i]] = rank + 1
+	}
+	return positions, sizeCmd.Val(), nil
+}
+
+// Status is the queue feedback sent to one waiting client.
+type Status struct {
+	Position      int64
+	QueueSize     int64
+	EstimatedWait time.Duration // zero when the matcher has no recent samples
+	Paused        bool          // the matcher is not pairing anyone
+}
+
+// StatusTracker remembers which local sessions are waiting for a match and
+// periodically reports their queue position alongside the matcher's latest
+// wait estimate. It is used by wsserver.
+type StatusTracker struct {
+	mu      sync.Mutex
+	waiting map[string]struct{}
+	stats   atomic.Pointer[MatchStats]
+}
+
+// NewStatusTracker creates an empty tracker.
+func NewStatusTracker() *StatusTracker {
+	return &StatusTracker{waiting: make(map[string]struct{})}
+}
+
+// Add starts reporting status for a session.
+func (t *StatusTracker) Add(sessionID string) {
+	t.mu.Lock()
+	t.waiting[sessionID] = struct{}{}
+	t.mu.Unlock()
+}
+
+// Remove stops reporting status for a session.
+func (t *StatusTracker) Remove(sessionID string) {
+	t.mu.Lock()
+	delete(t.waiting, sessionID)
+	t.mu.Unlock()
+}
+
+// Len returns the number of tracked sessions.
+func (t *StatusTracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.waiting)
+}
+
+// SetStats stores the latest stats published by the matcher.
+func (t *StatusTracker) SetStats(stats MatchStats) {
+	t.stats.Store(&stats)
+}
+
+// Stats returns the latest stats published by the matcher, and false if
+// none have been received yet.
+func (t *StatusTracker) Stats() (MatchStats, bool) {
+	stats := t.stats.Load()
+	if stats == nil {
+		return MatchStats{}, false
+	}
+	return *stats, true
+}
+
+// MatchTimeout returns how long the matcher lets a session wait for a match,
+// as of its latest stats, or the default timeout before any have arrived.
+func (t *StatusTracker) MatchTimeout() time.Duration {
+	if stats := t.stats.Load(); stats != nil && stats.Tiers != nil && stats.Tiers.MatchTimeout > 0 {
+		return stats.Tiers.MatchTimeout
+	}
+	return DefaultTierConfig().MatchTimeout
+}
+
+// Run sends a Status to every tracked session each StatusInterval until ctx
+// is cancelled. Sessions that are not (or not yet) in the queue are skipped.
+func (t *StatusTracker) Run(ctx context.Context, queue *Queue, send func(sessionID string, status Status)) {
+	ticker := time.NewTicker(StatusInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.report(ctx, queue, send)
+		}
+	}
+}
+
+func (t *StatusTracker) report(ctx context.Context, queue *Queue, send func(string, Status)) {
+	t.mu.Lock()
+	ids := make([]string, 0, len(t.waiting))
+	for sid := range t.waiting {
+		ids = append(ids, sid)
+	}
+	t.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	positions, size, err := queue.Positions(ctx, ids)
+	if err != nil {
+		return
+	}
+
+	var estimate time.Duration
+	var paused bool
+	if stats := t.stats.Load(); stats != nil {
+		if stats.Samples > 0 && !stats.Paused {
+			estimate = time.Duration(stats.MedianWaitMs) * time.Millisecond
+		}
+		paused = stats.Paused
+	}
+	for sid, pos := range positions {
+		send(sid, Status{Position: pos, QueueSize: size, EstimatedWait: estimate, Paused: paused})
+	}
+}
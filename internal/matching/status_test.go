@@ -0,0 +1,101 @@
+package matching
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyWindow_Median(t *testing.T) {
+	var w latencyWindow
+	now := time.Now()
+
+	if _, n := w.median(now); n != 0 {
+		t.Fatalf("expected no samples, got %d", n)
+	}
+
+	for _, s := range []int{9, 1, 5, 3, 7} {
+		w.record(time.Duration(s)*time.Second, now)
+	}
+	median, n := w.median(now)
+	if n != 5 || median != 5*time.Second {
+		t.Errorf("median = %v over %d samples, want 5s over 5", median, n)
+	}
+}
+
+func TestLatencyWindow_DropsOldAndOverflowedSamples(t *testing.T) {
+	var w latencyWindow
+	now := time.Now()
+
+	w.record(time.Hour, now.Add(-latencyMaxAge-time.Second))
+	w.record(2*time.Second, now)
+	if median, n := w.median(now); n != 1 || median != 2*time.Second {
+		t.Errorf("expected stale sample ignored, got %v over %d", median, n)
+	}
+
+	for i := 0; i < latencyWindowSize+10; i++ {
+		w.record(time.Second, now)
+	}
+	if _, n := w.median(now); n != latencyWindowSize {
+		t.Errorf("expected window capped at %d samples, got %d", latencyWindowSize, n)
+	}
+}
+
+func TestQueuePositions(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	for _, sid := range []string{"alice", "bob", "carol"} {
+		enqueueTestUser(t, q, ctx, sid, []string{"music"})
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	positions, size, err := q.Positions(ctx, []string{"carol", "alice", "ghost"})
+	if err != nil {
+		t.Fatalf("Positions: %v", err)
+	}
+	if size != 3 {
+		t.Errorf("expected queue size 3, got %d", size)
+	}
+	if positions["alice"] != 1 || positions["carol"] != 3 {
+		t.Errorf("unexpected positions %v", positions)
+	}
+	if _, ok := positions["ghost"]; ok {
+		t.Error("unqueued session should be omitted")
+	}
+}
+
+func TestStatusTracker_ReportsTrackedSessions(t *testing.T) {
+	q, ctx := setupTestQueue(t)
+
+	enqueueTestUser(t, q, ctx, "alice", []string{"music"})
+	enqueueTestUser(t, q, ctx, "bob", []string{"art"})
+
+	tracker := NewStatusTracker()
+	tracker.Add("alice")
+	tracker.Add("bob")
+	tracker.Remove("bob")
+	tracker.SetStats(MatchStats{MedianWaitMs: 4000, Samples: 12})
+
+	got := make(map[string]Status)
+	tracker.report(ctx, q, func(sid string, st Status) { got[sid] = st })
+
+	if len(got) != 1 {
+		t.Fatalf("expected one status, got %v", got)
+	}
+	st := got["alice"]
+	if st.Position != 1 || st.QueueSize != 2 || st.EstimatedWait != 4*time.Second {
+		t.Errorf("unexpected status %+v", st)
+	}
+}
+
+func TestStatusTracker_MatchTimeout(t *testing.T) {
+	tr := NewStatusTracker()
+	if got := tr.MatchTimeout(); got != DefaultTierConfig().MatchTimeout {
+		t.Errorf("MatchTimeout before any stats = %v, want the default", got)
+	}
+	tiers := DefaultTierConfig()
+	tiers.MatchTimeout = 90 * time.Second
+	tr.SetStats(MatchStats{Tiers: &tiers})
+	if got := tr.MatchTimeout(); got != 90*time.Second {
+		t.Errorf("MatchTimeout = %v, want the matcher's 90s", got)
+	}
+}
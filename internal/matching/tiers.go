@@ -0,0 +1,119 @@
+package matching
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/whisper/chat-app/internal/metrics"
+)
+
+// MaxMatchTimeout bounds TierConfig.MatchTimeout. Priority entries are placed
+// this far ahead in the queue, so they stay ahead of anyone still waiting
+// whatever timeout is configured.
+const MaxMatchTimeout = 5 * time.Minute
+
+// TierConfig sets how long a user waits before each broader matching tier is
+// tried. Exact matching is always attempted; the other tiers join once the
+// wait reaches the preceding threshold, and the user is given up on at
+// MatchTimeout:
+//
+//	0 .. Tier1MaxWait             exact match only
+//	Tier1MaxWait .. Tier2MaxWait  + overlap matching
+//	Tier2MaxWait .. Tier3MaxWait  + single-interest fallback
+//	Tier3MaxWait .. MatchTimeout  + random matching, then give up
+type TierConfig struct {
+	Tier1MaxWait time.Duration
+	Tier2MaxWait time.Duration
+	Tier3MaxWait time.Duration
+	MatchTimeout time.Duration
+}
+
+// DefaultTierConfig returns the built-in 10s/20s/25s/30s thresholds.
+func DefaultTierConfig() TierConfig {
+	return TierConfig{
+		Tier1MaxWait: 10 * time.Second,
+		Tier2MaxWait: 20 * time.Second,
+		Tier3MaxWait: 25 * time.Second,
+		MatchTimeout: 30 * time.Second,
+	}
+}
+
+// Validate checks that the thresholds are positive, strictly increasing and
+// that MatchTimeout does not exceed MaxMatchTimeout. Thresholds shorter than
+// the 2s matching pass are allowed but take effect at the next pass.
+func (c TierConfig) Validate() error {
+	if c.Tier1MaxWait <= 0 {
+		return errors.New("matching: tier1 max wait must be positive")
+	}
+	if c.Tier2MaxWait <= c.Tier1MaxWait || c.Tier3MaxWait <= c.Tier2MaxWait || c.MatchTimeout <= c.Tier3MaxWait {
+		return fmt.Errorf("matching: tier thresholds must increase: %s", c)
+	}
+	if c.MatchTimeout > MaxMatchTimeout {
+		return fmt.Errorf("matching: match timeout %s exceeds %s", c.MatchTimeout, MaxMatchTimeout)
+	}
+	return nil
+}
+
+// WaitTier returns the broadest tier a user who waited wait was eligible
+// for, e.g. TierOverlap between Tier1MaxWait and Tier2MaxWait.
+func (c TierConfig) WaitTier(wait time.Duration) string {
+	switch {
+	case wait >= c.Tier3MaxWait:
+		return TierRandom
+	case wait >= c.Tier2MaxWait:
+		return TierSingle
+	case wait >= c.Tier1MaxWait:
+		return TierOverlap
+	}
+	return TierExact
+}
+
+// String formats the thresholds for logs, e.g. "10s/20s/25s/30s".
+func (c TierConfig) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s", c.Tier1MaxWait, c.Tier2MaxWait, c.Tier3MaxWait, c.MatchTimeout)
+}
+
+// tierConfigJSON is the wire form of TierConfig, in milliseconds like the
+// other durations the matcher publishes.
+type tierConfigJSON struct {
+	Tier1MaxWaitMs int64 `json:"tier1_max_wait_ms"`
+	Tier2MaxWaitMs int64 `json:"tier2_max_wait_ms"`
+	Tier3MaxWaitMs int64 `json:"tier3_max_wait_ms"`
+	MatchTimeoutMs int64 `json:"match_timeout_ms"`
+}
+
+// MarshalJSON encodes the thresholds as milliseconds.
+func (c TierConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tierConfigJSON{
+		Tier1MaxWaitMs: c.Tier1MaxWait.Milliseconds(),
+		Tier2MaxWaitMs: c.Tier2MaxWait.Milliseconds(),
+		Tier3MaxWaitMs: c.Tier3MaxWait.Milliseconds(),
+		MatchTimeoutMs: c.MatchTimeout.Milliseconds(),
+	})
+}
+
+// UnmarshalJSON decodes thresholds given in milliseconds. It does not
+// validate them.
+func (c *TierConfig) UnmarshalJSON(data []byte) error {
+	var v tierConfigJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = TierConfig{
+		Tier1MaxWait: time.Duration(v.Tier1MaxWaitMs) * time.Millisecond,
+		Tier2MaxWait: time.Duration(v.Tier2MaxWaitMs) * time.Millisecond,
+		Tier3MaxWait: time.Duration(v.Tier3MaxWaitMs) * time.Millisecond,
+		MatchTimeout: time.Duration(v.MatchTimeoutMs) * time.Millisecond,
+	}
+	return nil
+}
+
+// setTierMetrics publishes the thresholds in effect.
+func setTierMetrics(c TierConfig) {
+	metrics.MatchTierThresholdSeconds.WithLabelValues("tier1").Set(c.Tier1MaxWait.Seconds())
+	metrics.MatchTierThresholdSeconds.WithLabelValues("tier2").Set(c.Tier2MaxWait.Seconds())
+	metrics.MatchTierThresholdSeconds.WithLabelValues("tier3").Set(c.Tier3MaxWait.Seconds())
+	metrics.MatchTierThresholdSeconds.WithLabelValues("timeout").Set(c.MatchTimeout.Seconds())
+}
@@ -0,0 +1,66 @@
+package matching
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTierConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers TierConfig
+		ok    bool
+	}{
+		{"defaults", DefaultTierConfig(), true},
+		{"faster", TierConfig{2 * time.Second, 4 * time.Second, 6 * time.Second, 8 * time.Second}, true},
+		{"zero tier1", TierConfig{0, 4 * time.Second, 6 * time.Second, 8 * time.Second}, false},
+		{"equal tiers", TierConfig{5 * time.Second, 5 * time.Second, 6 * time.Second, 8 * time.Second}, false},
+		{"timeout before tier3", TierConfig{2 * time.Second, 4 * time.Second, 8 * time.Second, 6 * time.Second}, false},
+		{"timeout too long", TierConfig{time.Minute, 2 * time.Minute, 3 * time.Minute, 10 * time.Minute}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.tiers.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestTierConfigJSON(t *testing.T) {
+	data, err := json.Marshal(DefaultTierConfig())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"tier1_max_wait_ms":10000,"tier2_max_wait_ms":20000,"tier3_max_wait_ms":25000,"match_timeout_ms":30000}`
+	if string(data) != want {
+		t.Errorf("marshal = %s, want %s", data, want)
+	}
+
+	var got TierConfig
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != DefaultTierConfig() {
+		t.Errorf("round trip = %s, want %s", got, DefaultTierConfig())
+	}
+}
+
+func TestTierConfigWaitTier(t *testing.T) {
+	tiers := DefaultTierConfig()
+	tests := []struct {
+		wait time.Duration
+		want string
+	}{
+		{0, TierExact},
+		{9 * time.Second, TierExact},
+		{10 * time.Second, TierOverlap},
+		{20 * time.Second, TierSingle},
+		{25 * time.Second, TierRandom},
+		{time.Minute, TierRandom},
+	}
+	for _, tt := range tests {
+		if got := tiers.WaitTier(tt.wait); got != tt.want {
+			t.Errorf("WaitTier(%s) = %q, want %q", tt.wait, got, tt.want)
+		}
+	}
+}
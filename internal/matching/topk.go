@@ -0,0 +1,82 @@
+package matching
+
+import (
+	"context"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// topKInterests is the number of most popular interests hashed into the
+// secondary exact-tier index. Users who list many interests rarely share an
+// identical set, but often share their most mainstream tags.
+const topKInterests = 3
+
+// recordPopularity increments the global popularity counter of each tag and
+// returns the updated scores keyed by tag.
+func (q *Queue) recordPopularity(ctx context.Context, interests []string) (map[string]float64, error) {
+	if len(interests) == 0 {
+		return nil, nil
+	}
+
+	pipe := q.rdb.Pipeline()
+	cmds := make([]*redis.FloatCmd, len(interests))
+	for i, tag := range interests {
+		cmds[i] = pipe.ZIncrBy(ctx, keyPopularity, 1, tag)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]float64, len(interests))
+	for i, tag := range interests {
+		scores[tag] = cmds[i].Val()
+	}
+	return scores, nil
+}
+
+// TopKInterests returns the k interests with the highest popularity score.
+// Ties are broken alphabetically so that two users with the same tags always
+// produce the same canonical subset. The result is sorted alphabetically.
+func TopKInterests(interests []string, scores map[string]float64, k int) []string {
+	ranked := make([]string, 0, len(interests))
+	seen := make(map[string]bool, len(interests))
+	for _, tag := range interests {
+		if !seen[tag] {
+			seen[tag] = true
+			ranked = append(ranked, tag)
+		}
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		si, sj := scores[ranked[i]], scores[ranked[j]]
+		if si != sj {
+			return si > sj
+		}
+		return ranked[i] < ranked[j]
+	})
+
+	if len(ranked) > k {
+		ranked = ranked[:k]
+	}
+	sort.Strings(ranked)
+	return ranked
+}
+
+// sharedInterests returns the sorted intersection of two interest lists.
+func sharedInterests(a, b []string) []string {
+	inA := make(map[string]bool, len(a))
+	for _, tag := range a {
+		inA[tag] = true
+	}
+
+	shared := make([]string, 0, len(b))
+	for _, tag := range b {
+		if inA[tag] {
+			shared = append(shared, tag)
+			delete(inA, tag)
+		}
+	}
+	sort.Strings(shared)
+	return shared
+}
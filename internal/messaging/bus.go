@@ -0,0 +1,132 @@
+// Package messaging provides the pub/sub event bus shared by Whisper
+// services. Bus is implemented by NATSClient, the default, and by RedisBus
+// for single-box deployments that already run Redis and would rather not
+// run NATS. Both handle connection lifecycle, subject-based subscriptions
+// and convenience methods for chat, matchmaking and moderation channels.
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Bus is the event bus used by the wsserver, matcher and moderator. Every
+// subscriber to a subject receives every message published on it; there
+// are no queue groups, so partitioned consumers such as matchers split work
+// by region instead.
+type Bus interface {
+	// Region returns the region this bus publishes match and chat traffic
+	// in, or "" when subjects are not partitioned.
+	Region() string
+	// Connected reports whether the bus can currently publish.
+	Connected() bool
+	// Ping makes a round trip to the bus server.
+	Ping(ctx context.Context) error
+	// Close drops all subscriptions and releases the connection.
+	Close()
+
+	// Publish sends data to subject.
+	Publish(subject string, data []byte) error
+
+	SubscribeToChat(chatID, sessionID string, handler func(data []byte)) error
+	UnsubscribeFromChat(sessionID string) error
+	BridgeChat(chatID, sessionID, remoteRegion string) error
+	PublishChatMessage(chatID string, data []byte) error
+
+	PublishMatchRequest(data []byte) error
+	SubscribeMatchRequest(handler func(data []byte)) error
+	PublishMatchCancel(data []byte) error
+	SubscribeMatchCancel(handler func(data []byte)) error
+	PublishMatchResume(data []byte) error
+	SubscribeMatchResume(handler func(data []byte)) error
+	SubscribeMatchFound(sessionID string, handler func(data []byte)) error
+	UnsubscribeMatchFound(sessionID string) error
+	PublishMatchNotify(sessionID string, data []byte) error
+	SubscribeMatchNotify(sessionID string, handler func(data []byte)) error
+	UnsubscribeMatchNotify(sessionID string) error
+	PublishMatchStats(data []byte) error
+	SubscribeMatchStats(handler func(data []byte)) error
+	PublishMatchTiers(data []byte) error
+	SubscribeMatchTiers(handler func(data []byte)) error
+
+	PublishModerationRequest(data []byte) error
+	SubscribeModerationCheck(handler func(data []byte)) error
+	// ModerationCheckBacklog reports how many moderation check requests
+	// were received but not yet handled, and how many were dropped because
+	// that backlog was full.
+	ModerationCheckBacklog() (pending, dropped int)
+	PublishModerationResult(sessionID string, data []byte) error
+	SubscribeModerationResult(sessionID string, handler func(data []byte)) error
+	UnsubscribeModerationResult(sessionID string) error
+
+	PublishServerSend(serverName string, data []byte) error
+	SubscribeServerSend(serverName string, handler func(data []byte)) error
+
+	PublishBlocklistUpdated() error
+	SubscribeBlocklistUpdated(handler func()) error
+
+	PublishFeatureFlagsUpdated() error
+	SubscribeFeatureFlagsUpdated(handler func()) error
+
+	PublishMaintenanceUpdated() error
+	SubscribeMaintenanceUpdated(handler func()) error
+}
+
+var (
+	_ Bus = (*NATSClient)(nil)
+	_ Bus = (*RedisBus)(nil)
+)
+
+// Bus kinds accepted by ParseBusKind.
+const (
+	BusNATS  = "nats"
+	BusRedis = "redis"
+)
+
+// ParseBusKind validates a MESSAGE_BUS setting. Empty selects NATS.
+func ParseBusKind(kind string) (string, error) {
+	switch kind = strings.ToLower(strings.TrimSpace(kind)); kind {
+	case "", BusNATS:
+		return BusNATS, nil
+	case BusRedis:
+		return BusRedis, nil
+	}
+	return "", fmt.Errorf("messaging: unknown bus %q (want %q or %q)", kind, BusNATS, BusRedis)
+}
+
+// OpenBus connects the bus selected by kind, a MESSAGE_BUS setting. The
+// NATS bus uses natsConfig; the Redis bus runs on rdb and takes only Region
+// and MatchRegions from natsConfig.
+func OpenBus(kind string, natsConfig NATSConfig, rdb *redis.Client) (Bus, error) {
+	kind, err := ParseBusKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	if kind == BusRedis {
+		config := DefaultRedisBusConfig()
+		config.Region = natsConfig.Region
+		config.MatchRegions = natsConfig.MatchRegions
+		return NewRedisBus(rdb, config), nil
+	}
+	c, err := NewNATSClient(natsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// regionSubjects lists the subjects a match subscription covers: one per
+// region in regions, or the unpartitioned subject plus every region.
+func regionSubjects(subject string, regions []string) []string {
+	if len(regions) == 0 {
+		return []string{subject, subject + ".*"}
+	}
+	subjects := make([]string, 0, len(regions))
+	for _, region := range regions {
+		subjects = append(subjects, RegionalSubject(subject, region))
+	}
+	return subjects
+}
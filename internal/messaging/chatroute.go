@@ -0,0 +1,76 @@
+package messaging
+
+import "sync"
+
+// chatRoutes maps chat subjects to the local sessions in each chat, so a
+// server needs one bus subscription per chat however many of the chat's
+// participants it holds, and hands each event to every one of them.
+type chatRoutes struct {
+	mu       sync.RWMutex
+	chats    map[string]map[string]func(data []byte) // subject -> session -> handler
+	sessions map[string]string                       // session -> subject
+}
+
+func newChatRoutes() *chatRoutes {
+	return &chatRoutes{
+		chats:    make(map[string]map[string]func(data []byte)),
+		sessions: make(map[string]string),
+	}
+}
+
+// add routes subject to sessionID's handler and reports whether it is the
+// first session on subject, which needs a subscription. The session must
+// not be routed already; see remove.
+func (r *chatRoutes) add(subject, sessionID string, handler func(data []byte)) (first bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	handlers, ok := r.chats[subject]
+	if !ok {
+		handlers = make(map[string]func(data []byte), 2)
+		r.chats[subject] = handlers
+	}
+	handlers[sessionID] = handler
+	r.sessions[sessionID] = subject
+	return !ok
+}
+
+// remove stops routing to sessionID. It returns the subject the session was
+// routed from and whether it was the last session on it, whose subscription
+// is no longer needed; ok is false if the session was not routed.
+func (r *chatRoutes) remove(sessionID string) (subject string, last, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subject, ok = r.sessions[sessionID]
+	if !ok {
+		return "", false, false
+	}
+	delete(r.sessions, sessionID)
+	handlers := r.chats[subject]
+	delete(handlers, sessionID)
+	if len(handlers) == 0 {
+		delete(r.chats, subject)
+		return subject, true, true
+	}
+	return subject, false, true
+}
+
+// deliver hands data to every session routed from subject. Handlers run
+// outside the lock, so they may subscribe and unsubscribe.
+func (r *chatRoutes) deliver(subject string, data []byte) {
+	r.mu.RLock()
+	handlers := make([]func(data []byte), 0, len(r.chats[subject]))
+	for _, h := range r.chats[subject] {
+		handlers = append(handlers, h)
+	}
+	r.mu.RUnlock()
+	for _, h := range handlers {
+		h(data)
+	}
+}
+
+// len returns the number of chats routed.
+func (r *chatRoutes) len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.chats)
+}
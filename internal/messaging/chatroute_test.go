@@ -0,0 +1,46 @@
+package messaging
+
+import "testing"
+
+func TestChatRoutes(t *testing.T) {
+	r := newChatRoutes()
+	var got []string
+	handler := func(sid string) func([]byte) {
+		return func(data []byte) { got = append(got, sid+":"+string(data)) }
+	}
+
+	if !r.add("chat.c1", "s1", handler("s1")) {
+		t.Fatal("first session on a chat should need a subscription")
+	}
+	if r.add("chat.c1", "s2", handler("s2")) {
+		t.Fatal("second session on a chat should share the subscription")
+	}
+	r.add("chat.c2", "s3", handler("s3"))
+	if n := r.len(); n != 2 {
+		t.Fatalf("len = %d, want 2", n)
+	}
+
+	r.deliver("chat.c1", []byte("hi"))
+	if len(got) != 2 {
+		t.Fatalf("delivered to %v, want both sessions in c1", got)
+	}
+
+	if subject, last, ok := r.remove("s1"); !ok || last || subject != "chat.c1" {
+		t.Errorf("remove(s1) = %q, %v, %v; want chat.c1 still in use", subject, last, ok)
+	}
+	if subject, last, ok := r.remove("s2"); !ok || !last || subject != "chat.c1" {
+		t.Errorf("remove(s2) = %q, %v, %v; want chat.c1 released", subject, last, ok)
+	}
+	if _, _, ok := r.remove("s2"); ok {
+		t.Error("removing a session twice succeeded")
+	}
+
+	got = nil
+	r.deliver("chat.c1", []byte("late"))
+	if len(got) != 0 {
+		t.Errorf("delivered %v after every session left", got)
+	}
+	if n := r.len(); n != 1 {
+		t.Errorf("len = %d, want 1", n)
+	}
+}
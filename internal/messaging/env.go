@@ -0,0 +1,36 @@
+package messaging
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// NATSConfigFromEnv returns DefaultNATSConfig with the settings every
+// service shares overridden from the environment:
+//
+//	NATS_URL                         server URL
+//	NATS_RESUBSCRIBE_SLOW_CONSUMERS  "true" sets ResubscribeSlowConsumers
+//	NATS_PUBLISH_RETRY_QUEUE         PublishRetry.QueueSize; 0 disables retries
+//	NATS_PUBLISH_RETRY_MAX_AGE       PublishRetry.MaxAge, e.g. "30s"
+//
+// Invalid numbers and durations keep the default. Service-specific
+// settings, such as the client name or region, are left to the caller.
+func NATSConfigFromEnv() NATSConfig {
+	config := DefaultNATSConfig()
+	if v := os.Getenv("NATS_URL"); v != "" {
+		config.URL = v
+	}
+	config.ResubscribeSlowConsumers = os.Getenv("NATS_RESUBSCRIBE_SLOW_CONSUMERS") == "true"
+	if v := os.Getenv("NATS_PUBLISH_RETRY_QUEUE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			config.PublishRetry.QueueSize = n
+		}
+	}
+	if v := os.Getenv("NATS_PUBLISH_RETRY_MAX_AGE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			config.PublishRetry.MaxAge = d
+		}
+	}
+	return config
+}
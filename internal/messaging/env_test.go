@@ -0,0 +1,33 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNATSConfigFromEnv(t *testing.T) {
+	t.Setenv("NATS_URL", "nats://nats.internal:4222")
+	t.Setenv("NATS_RESUBSCRIBE_SLOW_CONSUMERS", "true")
+	t.Setenv("NATS_PUBLISH_RETRY_QUEUE", "0")
+	t.Setenv("NATS_PUBLISH_RETRY_MAX_AGE", "5s")
+
+	config := NATSConfigFromEnv()
+	if config.URL != "nats://nats.internal:4222" || !config.ResubscribeSlowConsumers {
+		t.Errorf("config = %+v", config)
+	}
+	if config.PublishRetry.QueueSize != 0 || config.PublishRetry.MaxAge != 5*time.Second {
+		t.Errorf("PublishRetry = %+v, want retries disabled and a 5s max age", config.PublishRetry)
+	}
+}
+
+func TestNATSConfigFromEnv_InvalidKeepsDefaults(t *testing.T) {
+	t.Setenv("NATS_URL", "")
+	t.Setenv("NATS_RESUBSCRIBE_SLOW_CONSUMERS", "")
+	t.Setenv("NATS_PUBLISH_RETRY_QUEUE", "-1")
+	t.Setenv("NATS_PUBLISH_RETRY_MAX_AGE", "soon")
+
+	config, def := NATSConfigFromEnv(), DefaultNATSConfig()
+	if config.URL != def.URL || config.ResubscribeSlowConsumers || config.PublishRetry != def.PublishRetry {
+		t.Errorf("config = %+v, want the defaults %+v", config, def)
+	}
+}
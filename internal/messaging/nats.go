@@ -1,33 +1,91 @@
-// Package messaging provides a NATS client wrapper for pub/sub messaging
-// across Whisper services. It handles connection lifecycle, subject-based
-// subscriptions, and convenience methods for chat and matchmaking channels.
 package messaging
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
+
+	"github.com/whisper/chat-app/internal/metrics"
 )
 
-// NATS subject patterns used across Whisper services.
+// Subject patterns used across Whisper services. RedisBus uses them as
+// Pub/Sub channel names.
 const (
-	SubjectMatchRequest = "match.request"
+	SubjectMatchRequest = "match.request"    // + .<region> when partitioned
 	SubjectMatchCancel  = "match.cancel"
+	SubjectMatchResume  = "match.resume"
 	SubjectMatchFound   = "match.found"      // + .<session_id>
 	SubjectMatchNotify  = "match.notify"     // + .<session_id> (lifecycle events)
-	SubjectChat         = "chat"             // + .<chat_id>
+	SubjectMatchStats   = "match.stats"      // periodic queue/latency stats from the matcher
+	SubjectMatchTiers   = "match.tiers"      // runtime tier threshold updates for every matcher
+	SubjectChat         = "chat"             // + [.<region>].<chat_id>
 	SubjectModeration       = "moderation.check"
 	SubjectModerationResult = "moderation.result"  // + .<session_id>
+	SubjectBlocklistUpdated = "moderation.blocklist.updated"
+	SubjectFeatureFlagsUpdated = "featureflag.updated"
+	SubjectMaintenanceUpdated = "maintenance.updated"
+	SubjectAlerts           = "alerts.abuse" // abuse velocity alerts for operators
+	SubjectServer           = "server" // + .<server_name>.send (frames for sessions on that server)
 )
 
-// NATSClient wraps the NATS connection with helper methods for pub/sub.
+// ModerationQueue is the queue group moderators consume check requests in.
+const ModerationQueue = "moderators"
+
+// bridgedHeader marks a chat event that a ChatBridge copied from another
+// region's chat subject, so the bridge on the other side does not copy it
+// back.
+const bridgedHeader = "Whisper-Bridged-From"
+
+// RegionalSubject appends a region token to a match subject, e.g.
+// "match.request" -> "match.request.eu-west". An empty region returns the
+// unpartitioned subject used by single-region deployments.
+func RegionalSubject(subject, region string) string {
+	if region == "" {
+		return subject
+	}
+	return subject + "." + region
+}
+
+// ChatSubject returns the subject carrying events for a chat in a region:
+// "chat.<region>.<chat_id>", or "chat.<chat_id>" when region is empty.
+func ChatSubject(region, chatID string) string {
+	if region == "" {
+		return SubjectChat + "." + chatID
+	}
+	return SubjectChat + "." + region + "." + chatID
+}
+
+// resubscribeCooldown limits how often a single subscription is recreated
+// after slow consumer errors, so a persistently slow handler cannot spin.
+const resubscribeCooldown = 30 * time.Second
+
+// NATSClient is the NATS implementation of Bus.
 type NATSClient struct {
-	conn *nats.Conn
-	mu   sync.Mutex
-	subs map[string]*nats.Subscription
+	conn     *nats.Conn
+	mu       sync.Mutex
+	subs     map[string]*nats.Subscription
+	handlers map[string]nats.MsgHandler // keyed like subs, for resubscription
+
+	resubscribeSlow bool
+	lastResub       map[string]time.Time
+
+	retry *retryPublisher // nil when publish retries are disabled
+
+	// chats routes each chat subject's events to the local sessions in the
+	// chat; the subject has one subscription while any are. chatMu orders
+	// subscribing and unsubscribing with changes to chats.
+	chats       *chatRoutes
+	chatMu      sync.Mutex
+	chatPending int // ChatPendingLimit
+
+	region       string   // scopes published match and chat subjects
+	matchRegions []string // regions whose match traffic this client consumes
 }
 
 // NATSConfig holds NATS connection settings.
@@ -36,6 +94,32 @@ type NATSConfig struct {
 	Name          string        // client name for identification
 	ReconnectWait time.Duration // time between reconnect attempts
 	MaxReconnects int           // max reconnect attempts (-1 for infinite)
+
+	// ResubscribeSlowConsumers recreates a subscription whose pending buffer
+	// overflowed, discarding the backlog so the handler catches up with live
+	// traffic. Messages already dropped by the client are not recovered.
+	ResubscribeSlowConsumers bool
+
+	// ChatPendingLimit caps the events buffered for one chat subscription
+	// while its handlers are busy. Further events are dropped and reported
+	// as a slow consumer, so one stuck chat cannot hold unbounded memory.
+	// 0 keeps the NATS client default (512K messages).
+	ChatPendingLimit int
+
+	// PublishRetry holds publishes that fail while NATS is unavailable and
+	// retries them in the background. QueueSize 0 disables retries.
+	PublishRetry PublishRetryConfig
+
+	// Region partitions match and chat subjects for geo-sharded
+	// deployments: match requests go to match.request.<region> and chat
+	// events to chat.<region>.<chat_id>. Empty keeps the unpartitioned
+	// subjects.
+	Region string
+
+	// MatchRegions restricts the match request, cancel and resume
+	// subscriptions to these regions. Empty subscribes to the unpartitioned
+	// subjects and every region.
+	MatchRegions []string
 }
 
 // DefaultNATSConfig returns sensible defaults.
@@ -45,12 +129,26 @@ func DefaultNATSConfig() NATSConfig {
 		Name:          "whisper",
 		ReconnectWait: 2 * time.Second,
 		MaxReconnects: -1, // infinite reconnects
+		PublishRetry:  DefaultPublishRetryConfig(),
+
+		ChatPendingLimit: 1024,
 	}
 }
 
 // NewNATSClient connects to NATS with the given config and returns a ready client.
 // It returns an error if the initial connection fails.
 func NewNATSClient(config NATSConfig) (*NATSClient, error) {
+	c := &NATSClient{
+		subs:            make(map[string]*nats.Subscription),
+		handlers:        make(map[string]nats.MsgHandler),
+		resubscribeSlow: config.ResubscribeSlowConsumers,
+		lastResub:       make(map[string]time.Time),
+		chats:           newChatRoutes(),
+		chatPending:     config.ChatPendingLimit,
+		region:          config.Region,
+		matchRegions:    config.MatchRegions,
+	}
+
 	opts := []nats.Option{
 		nats.Name(config.Name),
 		nats.ReconnectWait(config.ReconnectWait),
@@ -68,6 +166,7 @@ func NewNATSClient(config NATSConfig) (*NATSClient, error) {
 		nats.ClosedHandler(func(_ *nats.Conn) {
 			log.Printf("[nats] connection closed")
 		}),
+		nats.ErrorHandler(c.handleAsyncError),
 	}
 
 	nc, err := nats.Connect(config.URL, opts...)
@@ -77,66 +176,275 @@ func NewNATSClient(config NATSConfig) (*NATSClient, error) {
 
 	log.Printf("[nats] connected to %s", nc.ConnectedUrl())
 
-	return &NATSClient{
-		conn: nc,
-		subs: make(map[string]*nats.Subscription),
-	}, nil
+	c.conn = nc
+	if config.PublishRetry.QueueSize > 0 {
+		c.retry = newRetryPublisher(config.PublishRetry, nc.Publish)
+	}
+	return c, nil
 }
 
-// Publish sends data to the given NATS subject.
+// handleAsyncError is the nats.ErrorHandler. Asynchronous errors such as
+// slow consumers (the client dropped messages because a handler fell behind)
+// or permission violations are otherwise invisible; they are logged with the
+// affected subject and counted in whisper_nats_async_errors_total.
+func (c *NATSClient) handleAsyncError(_ *nats.Conn, sub *nats.Subscription, err error) {
+	kind := asyncErrorKind(err)
+	subject := ""
+	if sub != nil {
+		subject = sub.Subject
+	}
+	metrics.NATSAsyncErrorsTotal.WithLabelValues(kind, SubjectPattern(subject)).Inc()
+
+	if sub == nil {
+		log.Printf("[nats] async error kind=%s: %v", kind, err)
+		return
+	}
+
+	pendingMsgs, pendingBytes, _ := sub.Pending()
+	dropped, _ := sub.Dropped()
+	log.Printf("[nats] async error kind=%s subject=%s pending_msgs=%d pending_bytes=%d dropped=%d: %v",
+		kind, subject, pendingMsgs, pendingBytes, dropped, err)
+
+	if kind == "slow_consumer" && c.resubscribeSlow {
+		// The error handler runs on the connection's callback goroutine;
+		// resubscribe off it so the handler does not block other callbacks.
+		go c.resubscribe(sub)
+	}
+}
+
+// resubscribe replaces sub with a fresh subscription using the same handler,
+// at most once per resubscribeCooldown per key.
+func (c *NATSClient) resubscribe(sub *nats.Subscription) {
+	c.mu.Lock()
+	key := ""
+	for k, s := range c.subs {
+		if s == sub {
+			key = k
+			break
+		}
+	}
+	handler, ok := c.handlers[key]
+	if key == "" || !ok || time.Since(c.lastResub[key]) < resubscribeCooldown {
+		c.mu.Unlock()
+		return
+	}
+	c.lastResub[key] = time.Now()
+	c.mu.Unlock()
+
+	fresh, err := c.conn.QueueSubscribe(sub.Subject, sub.Queue, handler)
+	if err != nil {
+		log.Printf("[nats] resubscribe %s failed: %v", sub.Subject, err)
+		return
+	}
+	if msgs, bytes, err := sub.PendingLimits(); err == nil {
+		_ = fresh.SetPendingLimits(msgs, bytes)
+	}
+
+	c.mu.Lock()
+	if c.subs[key] != sub {
+		// Unsubscribed or replaced concurrently; drop the new subscription.
+		c.mu.Unlock()
+		_ = fresh.Unsubscribe()
+		return
+	}
+	c.subs[key] = fresh
+	c.mu.Unlock()
+
+	_ = sub.Unsubscribe()
+	log.Printf("[nats] resubscribed %s after slow consumer", sub.Subject)
+}
+
+// asyncErrorKind classifies an asynchronous NATS error for metrics labels.
+func asyncErrorKind(err error) string {
+	switch {
+	case errors.Is(err, nats.ErrSlowConsumer):
+		return "slow_consumer"
+	case errors.Is(err, nats.ErrPermissionViolation),
+		err != nil && strings.Contains(strings.ToLower(err.Error()), "permissions violation"):
+		return "permission_violation"
+	default:
+		return "other"
+	}
+}
+
+// perSessionSubjects are subject prefixes followed by a session or chat ID.
+var perSessionSubjects = []string{
+	SubjectMatchFound,
+	SubjectMatchNotify,
+	SubjectModerationResult,
+	SubjectChat,
+}
+
+// SubjectPattern collapses per-session and per-chat subjects such as
+// "chat.<chat_id>" into "chat.*" so they can be used as metric labels
+// without unbounded cardinality.
+func SubjectPattern(subject string) string {
+	for _, prefix := range perSessionSubjects {
+		if strings.HasPrefix(subject, prefix+".") {
+			return prefix + ".*"
+		}
+	}
+	return subject
+}
+
+// ServerSendSubject returns the subject a wsserver relays to its locally
+// connected sessions.
+func ServerSendSubject(serverName string) string {
+	return SubjectServer + "." + serverName + ".send"
+}
+
+// Publish sends data to the given NATS subject. If publish retries are
+// enabled, a publish that fails while NATS is unavailable is queued and
+// retried in the background instead of being lost.
 func (c *NATSClient) Publish(subject string, data []byte) error {
+	if c.retry != nil {
+		return c.retry.Publish(subject, data)
+	}
 	return c.conn.Publish(subject, data)
 }
 
 // Subscribe registers a handler for the given subject and stores the
 // subscription internally for later cleanup.
 func (c *NATSClient) Subscribe(subject string, handler func(msg *nats.Msg)) error {
-	sub, err := c.conn.Subscribe(subject, handler)
+	return c.subscribe(subject, subject, handler)
+}
+
+// subscribe subscribes handler to subject and stores the subscription and
+// handler under key.
+func (c *NATSClient) subscribe(key, subject string, handler nats.MsgHandler) error {
+	return c.subscribeQueue(key, subject, "", handler)
+}
+
+// subscribeQueue is subscribe in a queue group; an empty queue is a plain
+// subscription.
+func (c *NATSClient) subscribeQueue(key, subject, queue string, handler nats.MsgHandler) error {
+	sub, err := c.conn.QueueSubscribe(subject, queue, handler)
 	if err != nil {
 		return fmt.Errorf("nats subscribe %s: %w", subject, err)
 	}
 
 	c.mu.Lock()
-	c.subs[subject] = sub
+	c.subs[key] = sub
+	c.handlers[key] = handler
 	c.mu.Unlock()
 
 	return nil
 }
 
-// SubscribeToChat subscribes to the chat.<chatID> subject for a specific session.
-// The subscription is keyed by sessionID to allow multiple users on the same
-// server to subscribe to the same chat without overwriting each other.
+// Region returns the region this client publishes match and chat traffic
+// in, or "" when subjects are not partitioned.
+func (c *NATSClient) Region() string {
+	return c.region
+}
+
+// SubscribeToChat routes events on the chat subject of this client's region
+// to handler for a specific session. The client holds one subscription per
+// chat, shared by every local session in it, and drops it when the last
+// one unsubscribes. A session is in one chat at a time; subscribing it again
+// replaces its previous chat.
 func (c *NATSClient) SubscribeToChat(chatID string, sessionID string, handler func(data []byte)) error {
-	subject := SubjectChat + "." + chatID
-	key := "chatsub:" + sessionID
-	sub, err := c.conn.Subscribe(subject, func(msg *nats.Msg) {
-		handler(msg.Data)
+	subject := ChatSubject(c.region, chatID)
+
+	c.chatMu.Lock()
+	defer c.chatMu.Unlock()
+	c.removeChatRoute(sessionID)
+	if !c.chats.add(subject, sessionID, handler) {
+		return nil
+	}
+	err := c.subscribe("chat:"+subject, subject, func(msg *nats.Msg) {
+		c.chats.deliver(subject, msg.Data)
 	})
 	if err != nil {
-		return fmt.Errorf("nats subscribe %s: %w", subject, err)
+		c.chats.remove(sessionID)
+		return err
 	}
-
-	c.mu.Lock()
-	c.subs[key] = sub
-	c.mu.Unlock()
+	if c.chatPending > 0 {
+		c.mu.Lock()
+		sub := c.subs["chat:"+subject]
+		c.mu.Unlock()
+		_ = sub.SetPendingLimits(c.chatPending, -1)
+	}
+	metrics.ChatSubscriptions.Set(float64(c.chats.len()))
 	return nil
 }
 
-// UnsubscribeFromChat unsubscribes a session's chat subscription.
+// UnsubscribeFromChat stops routing chat events to a session and removes
+// the cross-region bridge started for it, if any.
 func (c *NATSClient) UnsubscribeFromChat(sessionID string) error {
-	key := "chatsub:" + sessionID
-	return c.unsubscribe(key)
+	_ = c.unsubscribe("chatbridge:" + sessionID)
+
+	c.chatMu.Lock()
+	defer c.chatMu.Unlock()
+	return c.removeChatRoute(sessionID)
+}
+
+// removeChatRoute stops routing to sessionID, unsubscribing from its chat
+// if no other local session is in it. c.chatMu must be held.
+func (c *NATSClient) removeChatRoute(sessionID string) error {
+	subject, last, ok := c.chats.remove(sessionID)
+	if !ok {
+		return fmt.Errorf("nats: session %s is not subscribed to a chat", sessionID)
+	}
+	if !last {
+		return nil
+	}
+	metrics.ChatSubscriptions.Set(float64(c.chats.len()))
+	return c.unsubscribe("chat:" + subject)
+}
+
+// BridgeChat copies events for a cross-region chat from the partner's region
+// subject into this client's region subject, so the local session sees them
+// on its normal chat subscription. The partner's server runs the mirror
+// bridge; events already copied by a bridge are not copied again. The bridge
+// is keyed by sessionID and removed by UnsubscribeFromChat.
+func (c *NATSClient) BridgeChat(chatID, sessionID, remoteRegion string) error {
+	if remoteRegion == c.region {
+		return nil
+	}
+	local := ChatSubject(c.region, chatID)
+	remote := ChatSubject(remoteRegion, chatID)
+	return c.subscribe("chatbridge:"+sessionID, remote, func(msg *nats.Msg) {
+		if msg.Header.Get(bridgedHeader) != "" {
+			return
+		}
+		out := nats.NewMsg(local)
+		out.Header.Set(bridgedHeader, remoteRegion)
+		out.Data = msg.Data
+		if err := c.conn.PublishMsg(out); err != nil {
+			log.Printf("[nats] bridge %s -> %s: %v", remote, local, err)
+		}
+	})
 }
 
-// PublishChatMessage publishes data to the chat.<chatID> subject.
+// PublishChatMessage publishes data to the chat subject of this client's
+// region.
 func (c *NATSClient) PublishChatMessage(chatID string, data []byte) error {
-	subject := SubjectChat + "." + chatID
-	return c.Publish(subject, data)
+	return c.Publish(ChatSubject(c.region, chatID), data)
 }
 
-// PublishMatchRequest publishes data to the match.request subject.
+// PublishMatchRequest publishes data to the match.request subject of this
+// client's region.
 func (c *NATSClient) PublishMatchRequest(data []byte) error {
-	return c.Publish(SubjectMatchRequest, data)
+	return c.Publish(RegionalSubject(SubjectMatchRequest, c.region), data)
+}
+
+// matchSubjects lists the subjects a match subscription covers: one per
+// configured match region, or the unpartitioned subject plus every region.
+func (c *NATSClient) matchSubjects(subject string) []string {
+	return regionSubjects(subject, c.matchRegions)
+}
+
+// subscribeMatch subscribes handler to every subject returned by
+// matchSubjects.
+func (c *NATSClient) subscribeMatch(subject string, handler func(data []byte)) error {
+	for _, s := range c.matchSubjects(subject) {
+		if err := c.Subscribe(s, func(msg *nats.Msg) {
+			handler(msg.Data)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // SubscribeMatchFound subscribes to the match.found.<sessionID> subject and
@@ -154,23 +462,34 @@ func (c *NATSClient) UnsubscribeMatchFound(sessionID string) error {
 	return c.unsubscribe(subject)
 }
 
-// SubscribeMatchRequest subscribes to match request messages from WS servers.
+// SubscribeMatchRequest subscribes to match request messages from WS servers
+// in the configured match regions.
 func (c *NATSClient) SubscribeMatchRequest(handler func(data []byte)) error {
-	return c.Subscribe(SubjectMatchRequest, func(msg *nats.Msg) {
-		handler(msg.Data)
-	})
+	return c.subscribeMatch(SubjectMatchRequest, handler)
 }
 
-// SubscribeMatchCancel subscribes to match cancellation messages from WS servers.
+// SubscribeMatchCancel subscribes to match cancellation messages from WS
+// servers in the configured match regions.
 func (c *NATSClient) SubscribeMatchCancel(handler func(data []byte)) error {
-	return c.Subscribe(SubjectMatchCancel, func(msg *nats.Msg) {
-		handler(msg.Data)
-	})
+	return c.subscribeMatch(SubjectMatchCancel, handler)
 }
 
-// PublishMatchCancel publishes a match cancellation request.
+// PublishMatchCancel publishes a match cancellation request in this client's
+// region.
 func (c *NATSClient) PublishMatchCancel(data []byte) error {
-	return c.Publish(SubjectMatchCancel, data)
+	return c.Publish(RegionalSubject(SubjectMatchCancel, c.region), data)
+}
+
+// PublishMatchResume publishes a matching-state resumption request after a
+// client reconnected to a new wsserver.
+func (c *NATSClient) PublishMatchResume(data []byte) error {
+	return c.Publish(RegionalSubject(SubjectMatchResume, c.region), data)
+}
+
+// SubscribeMatchResume subscribes to matching-state resumption requests in
+// the configured match regions.
+func (c *NATSClient) SubscribeMatchResume(handler func(data []byte)) error {
+	return c.subscribeMatch(SubjectMatchResume, handler)
 }
 
 // SubscribeMatchNotify subscribes to match lifecycle notifications for a session.
@@ -191,18 +510,70 @@ func (c *NATSClient) PublishMatchNotify(sessionID string, data []byte) error {
 	return c.Publish(SubjectMatchNotify+"."+sessionID, data)
 }
 
+// PublishMatchStats publishes the matcher's periodic queue statistics.
+func (c *NATSClient) PublishMatchStats(data []byte) error {
+	return c.Publish(SubjectMatchStats, data)
+}
+
+// SubscribeMatchStats subscribes to the matcher's periodic queue statistics.
+func (c *NATSClient) SubscribeMatchStats(handler func(data []byte)) error {
+	return c.Subscribe(SubjectMatchStats, func(msg *nats.Msg) {
+		handler(msg.Data)
+	})
+}
+
+// PublishMatchTiers asks every matcher to switch to new tier thresholds.
+func (c *NATSClient) PublishMatchTiers(data []byte) error {
+	return c.Publish(SubjectMatchTiers, data)
+}
+
+// SubscribeMatchTiers subscribes to tier threshold updates. Every matcher
+// receives every update, regardless of its match regions.
+func (c *NATSClient) SubscribeMatchTiers(handler func(data []byte)) error {
+	return c.Subscribe(SubjectMatchTiers, func(msg *nats.Msg) {
+		handler(msg.Data)
+	})
+}
+
 // PublishModerationRequest publishes a moderation check request.
 func (c *NATSClient) PublishModerationRequest(data []byte) error {
 	return c.Publish(SubjectModeration, data)
 }
 
-// SubscribeModerationCheck subscribes to moderation check requests.
+// SubscribeModerationCheck subscribes to moderation check requests in the
+// ModerationQueue group, so each request is handled by one moderator however
+// many run.
 func (c *NATSClient) SubscribeModerationCheck(handler func(data []byte)) error {
-	return c.Subscribe(SubjectModeration, func(msg *nats.Msg) {
+	return c.subscribeQueue(SubjectModeration, SubjectModeration, ModerationQueue, func(msg *nats.Msg) {
 		handler(msg.Data)
 	})
 }
 
+// ModerationCheckBacklog reports how many moderation check requests this
+// client has received but not yet handled, and how many the NATS client
+// dropped because that backlog was full. Both are zero when not subscribed.
+func (c *NATSClient) ModerationCheckBacklog() (pending, dropped int) {
+	c.mu.Lock()
+	sub := c.subs[SubjectModeration]
+	c.mu.Unlock()
+	if sub == nil {
+		return 0, 0
+	}
+	pending, _, _ = sub.Pending()
+	dropped, _ = sub.Dropped()
+	return pending, dropped
+}
+
+// Connected reports whether the client currently has a NATS connection.
+func (c *NATSClient) Connected() bool {
+	return c.conn.IsConnected()
+}
+
+// Ping flushes the connection, waiting for the server to answer.
+func (c *NATSClient) Ping(ctx context.Context) error {
+	return c.conn.FlushWithContext(ctx)
+}
+
 // PublishModerationResult publishes a moderation result for a specific session.
 func (c *NATSClient) PublishModerationResult(sessionID string, data []byte) error {
 	return c.Publish(SubjectModerationResult+"."+sessionID, data)
@@ -221,6 +592,62 @@ func (c *NATSClient) UnsubscribeModerationResult(sessionID string) error {
 	return c.unsubscribe(SubjectModerationResult + "." + sessionID)
 }
 
+// PublishServerSend publishes a delivery for a session hosted on the named
+// wsserver.
+func (c *NATSClient) PublishServerSend(serverName string, data []byte) error {
+	return c.Publish(ServerSendSubject(serverName), data)
+}
+
+// SubscribeServerSend subscribes to deliveries for sessions hosted on the
+// named wsserver.
+func (c *NATSClient) SubscribeServerSend(serverName string, handler func(data []byte)) error {
+	return c.Subscribe(ServerSendSubject(serverName), func(msg *nats.Msg) {
+		handler(msg.Data)
+	})
+}
+
+// PublishBlocklistUpdated notifies all services that the dynamic blocklist
+// changed and should be reloaded.
+func (c *NATSClient) PublishBlocklistUpdated() error {
+	return c.Publish(SubjectBlocklistUpdated, nil)
+}
+
+// SubscribeBlocklistUpdated subscribes to dynamic blocklist change
+// notifications.
+func (c *NATSClient) SubscribeBlocklistUpdated(handler func()) error {
+	return c.Subscribe(SubjectBlocklistUpdated, func(_ *nats.Msg) {
+		handler()
+	})
+}
+
+// PublishFeatureFlagsUpdated notifies all services that a feature flag
+// rollout changed and the flags should be reloaded.
+func (c *NATSClient) PublishFeatureFlagsUpdated() error {
+	return c.Publish(SubjectFeatureFlagsUpdated, nil)
+}
+
+// SubscribeFeatureFlagsUpdated subscribes to feature flag change
+// notifications.
+func (c *NATSClient) SubscribeFeatureFlagsUpdated(handler func()) error {
+	return c.Subscribe(SubjectFeatureFlagsUpdated, func(_ *nats.Msg) {
+		handler()
+	})
+}
+
+// PublishMaintenanceUpdated notifies all services that maintenance mode was
+// switched on or off and should be reloaded.
+func (c *NATSClient) PublishMaintenanceUpdated() error {
+	return c.Publish(SubjectMaintenanceUpdated, nil)
+}
+
+// SubscribeMaintenanceUpdated subscribes to maintenance mode change
+// notifications.
+func (c *NATSClient) SubscribeMaintenanceUpdated(handler func()) error {
+	return c.Subscribe(SubjectMaintenanceUpdated, func(_ *nats.Msg) {
+		handler()
+	})
+}
+
 // Close drains all active subscriptions and closes the NATS connection.
 func (c *NATSClient) Close() {
 	c.mu.Lock()
@@ -232,6 +659,11 @@ func (c *NATSClient) Close() {
 		}
 	}
 	c.subs = make(map[string]*nats.Subscription)
+	c.handlers = make(map[string]nats.MsgHandler)
+
+	if c.retry != nil {
+		c.retry.Stop()
+	}
 
 	if err := c.conn.Drain(); err != nil {
 		log.Printf("[nats] connection drain: %v", err)
@@ -249,6 +681,8 @@ func (c *NATSClient) unsubscribe(subject string) error {
 		return fmt.Errorf("nats: no subscription for subject %s", subject)
 	}
 	delete(c.subs, subject)
+	delete(c.handlers, subject)
+	delete(c.lastResub, subject)
 	c.mu.Unlock()
 
 	if err := sub.Unsubscribe(); err != nil {
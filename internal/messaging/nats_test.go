@@ -0,0 +1,134 @@
+package messaging
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestSubjectPattern(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    string
+	}{
+		{"chat.3f2a", "chat.*"},
+		{"chat.eu-west.3f2a", "chat.*"},
+		{"match.found.sess-1", "match.found.*"},
+		{"match.notify.sess-1", "match.notify.*"},
+		{"moderation.result.sess-1", "moderation.result.*"},
+		{SubjectMatchRequest, SubjectMatchRequest},
+		{SubjectBlocklistUpdated, SubjectBlocklistUpdated},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := SubjectPattern(tt.subject); got != tt.want {
+			t.Errorf("SubjectPattern(%q) = %q, want %q", tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestAsyncErrorKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nats.ErrSlowConsumer, "slow_consumer"},
+		{nats.ErrPermissionViolation, "permission_violation"},
+		{errors.New(`nats: permissions violation for subscription to "chat.x"`), "permission_violation"},
+		{errors.New("nats: something else"), "other"},
+	}
+	for _, tt := range tests {
+		if got := asyncErrorKind(tt.err); got != tt.want {
+			t.Errorf("asyncErrorKind(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRegionalSubjects(t *testing.T) {
+	if got := RegionalSubject(SubjectMatchRequest, ""); got != "match.request" {
+		t.Errorf("unpartitioned match subject = %q", got)
+	}
+	if got := RegionalSubject(SubjectMatchRequest, "eu-west"); got != "match.request.eu-west" {
+		t.Errorf("regional match subject = %q", got)
+	}
+	if got := ChatSubject("", "c1"); got != "chat.c1" {
+		t.Errorf("unpartitioned chat subject = %q", got)
+	}
+	if got := ChatSubject("us-east", "c1"); got != "chat.us-east.c1" {
+		t.Errorf("regional chat subject = %q", got)
+	}
+	if got := ServerSendSubject("ws-2"); got != "server.ws-2.send" {
+		t.Errorf("server send subject = %q", got)
+	}
+}
+
+func TestMatchSubjects(t *testing.T) {
+	all := &NATSClient{}
+	want := []string{"match.request", "match.request.*"}
+	if got := all.matchSubjects(SubjectMatchRequest); !reflect.DeepEqual(got, want) {
+		t.Errorf("unfiltered matchSubjects = %v, want %v", got, want)
+	}
+
+	filtered := &NATSClient{matchRegions: []string{"eu-west", "eu-central"}}
+	want = []string{"match.cancel.eu-west", "match.cancel.eu-central"}
+	if got := filtered.matchSubjects(SubjectMatchCancel); !reflect.DeepEqual(got, want) {
+		t.Errorf("filtered matchSubjects = %v, want %v", got, want)
+	}
+}
+
+func TestNATSClient_OneChatSubscriptionPerServer(t *testing.T) {
+	config := DefaultNATSConfig()
+	config.URL = testutil.NATSURL(t)
+	c, err := NewNATSClient(config)
+	if err != nil {
+		t.Fatalf("NewNATSClient: %v", err)
+	}
+	t.Cleanup(c.Close)
+
+	got := make(chan string, 4)
+	for _, sid := range []string{"s1", "s2"} {
+		sid := sid
+		if err := c.SubscribeToChat("c1", sid, func(data []byte) { got <- sid }); err != nil {
+			t.Fatalf("SubscribeToChat(%s): %v", sid, err)
+		}
+	}
+	c.mu.Lock()
+	subs := len(c.subs)
+	c.mu.Unlock()
+	if subs != 1 {
+		t.Fatalf("%d subscriptions for one chat, want 1", subs)
+	}
+
+	if err := c.PublishChatMessage("c1", []byte("hi")); err != nil {
+		t.Fatalf("PublishChatMessage: %v", err)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case sid := <-got:
+			seen[sid] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("delivered to %v, want s1 and s2", seen)
+		}
+	}
+
+	_ = c.UnsubscribeFromChat("s1")
+	c.mu.Lock()
+	subs = len(c.subs)
+	c.mu.Unlock()
+	if subs != 1 {
+		t.Fatalf("subscription dropped while s2 is still in the chat")
+	}
+	_ = c.UnsubscribeFromChat("s2")
+	c.mu.Lock()
+	subs = len(c.subs)
+	c.mu.Unlock()
+	if subs != 0 {
+		t.Errorf("%d subscriptions left after both sessions left", subs)
+	}
+}
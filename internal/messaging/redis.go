@@ -0,0 +1,553 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/whisper/chat-app/internal/metrics"
+)
+
+// RedisBusConfig holds Redis Pub/Sub bus settings.
+type RedisBusConfig struct {
+	// Region and MatchRegions partition subjects as in NATSConfig.
+	Region       string
+	MatchRegions []string
+
+	// PendingLimit caps how many messages a subscription holds while its
+	// handler is busy. Further messages are dropped and counted, as NATS
+	// does for a slow consumer.
+	PendingLimit int
+}
+
+// DefaultRedisBusConfig returns sensible defaults.
+func DefaultRedisBusConfig() RedisBusConfig {
+	return RedisBusConfig{PendingLimit: 65536}
+}
+
+// RedisBus is the Redis Pub/Sub implementation of Bus, for deployments that
+// run a single Redis and no NATS. Subjects are used as channel names and a
+// subject containing "*" becomes a pattern subscription. All subscriptions
+// share one Pub/Sub connection; each has its own pending queue and handler
+// goroutine, so a slow handler delays only its own subscription.
+//
+// Redis Pub/Sub is fire-and-forget: messages published while a subscriber
+// is reconnecting are lost, and failed publishes are not retried.
+type RedisBus struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+
+	region       string
+	matchRegions []string
+	pendingLimit int
+
+	mu       sync.Mutex
+	subs     map[string]*redisSub     // keyed like NATSClient.subs
+	channels map[string]*redisChannel // by channel or pattern
+}
+
+// redisChannel is a Redis channel or pattern and the subscriptions on it.
+type redisChannel struct {
+	subs  map[string]*redisSub // by key
+	ready chan struct{}        // closed once Redis confirms the subscription
+}
+
+// subscribeTimeout bounds the wait for Redis to confirm a subscription.
+const subscribeTimeout = 5 * time.Second
+
+// NewRedisBus starts a bus on client. The client stays owned by the caller
+// and is not closed by Close.
+func NewRedisBus(client *redis.Client, config RedisBusConfig) *RedisBus {
+	if config.PendingLimit <= 0 {
+		config.PendingLimit = DefaultRedisBusConfig().PendingLimit
+	}
+	b := &RedisBus{
+		client:       client,
+		pubsub:       client.Subscribe(context.Background()),
+		region:       config.Region,
+		matchRegions: config.MatchRegions,
+		pendingLimit: config.PendingLimit,
+		subs:         make(map[string]*redisSub),
+		channels:     make(map[string]*redisChannel),
+	}
+	go b.receive(b.pubsub.ChannelWithSubscriptions())
+	log.Printf("[bus] using Redis Pub/Sub on %s", client.Options().Addr)
+	return b
+}
+
+// receive hands every message from the Pub/Sub connection to the
+// subscriptions on its channel or pattern, and marks channels ready as Redis
+// confirms them.
+func (b *RedisBus) receive(ch <-chan interface{}) {
+	for v := range ch {
+		switch msg := v.(type) {
+		case *redis.Subscription:
+			if msg.Kind != "subscribe" && msg.Kind != "psubscribe" {
+				continue
+			}
+			b.mu.Lock()
+			if c := b.channels[msg.Channel]; c != nil {
+				select {
+				case <-c.ready: // confirmed again after a reconnect
+				default:
+					close(c.ready)
+				}
+			}
+			b.mu.Unlock()
+		case *redis.Message:
+			name := msg.Channel
+			if msg.Pattern != "" {
+				name = msg.Pattern
+			}
+			b.mu.Lock()
+			if c := b.channels[name]; c != nil {
+				for _, sub := range c.subs {
+					sub.deliver([]byte(msg.Payload))
+				}
+			}
+			b.mu.Unlock()
+		}
+	}
+}
+
+// Region returns the region this bus publishes match and chat traffic in,
+// or "" when subjects are not partitioned.
+func (b *RedisBus) Region() string {
+	return b.region
+}
+
+// Connected reports whether Redis answers a ping.
+func (b *RedisBus) Connected() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return b.client.Ping(ctx).Err() == nil
+}
+
+// Ping pings Redis.
+func (b *RedisBus) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+// Publish sends data to the channel named subject.
+func (b *RedisBus) Publish(subject string, data []byte) error {
+	if err := b.client.Publish(context.Background(), subject, data).Err(); err != nil {
+		return fmt.Errorf("redis publish %s: %w", subject, err)
+	}
+	return nil
+}
+
+// subscribe subscribes handler to subject and stores the subscription under
+// key, replacing any earlier subscription with that key. It returns once
+// Redis has confirmed the subscription: publishes travel on another
+// connection, so without the wait a message published right after
+// subscribing could be missed.
+func (b *RedisBus) subscribe(key, subject string, handler func(data []byte)) error {
+	sub := &redisSub{
+		subject: subject,
+		handler: handler,
+		limit:   b.pendingLimit,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	if err := b.removeLocked(key); err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	c := b.channels[subject]
+	if c == nil {
+		var err error
+		if strings.Contains(subject, "*") {
+			err = b.pubsub.PSubscribe(context.Background(), subject)
+		} else {
+			err = b.pubsub.Subscribe(context.Background(), subject)
+		}
+		if err != nil {
+			b.mu.Unlock()
+			return fmt.Errorf("redis subscribe %s: %w", subject, err)
+		}
+		c = &redisChannel{subs: make(map[string]*redisSub), ready: make(chan struct{})}
+		b.channels[subject] = c
+	}
+	c.subs[key] = sub
+	b.subs[key] = sub
+	go sub.run()
+	b.mu.Unlock()
+
+	select {
+	case <-c.ready:
+		return nil
+	case <-time.After(subscribeTimeout):
+	}
+	b.mu.Lock()
+	if b.subs[key] == sub {
+		_ = b.removeLocked(key)
+	}
+	b.mu.Unlock()
+	return fmt.Errorf("redis subscribe %s: not confirmed within %s", subject, subscribeTimeout)
+}
+
+// unsubscribe removes the subscription stored under key.
+func (b *RedisBus) unsubscribe(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[key]; !ok {
+		return fmt.Errorf("redis: no subscription for subject %s", key)
+	}
+	return b.removeLocked(key)
+}
+
+// removeLocked stops the subscription under key, if any, and leaves the
+// Redis channel once no subscription uses it. b.mu must be held.
+func (b *RedisBus) removeLocked(key string) error {
+	sub, ok := b.subs[key]
+	if !ok {
+		return nil
+	}
+	delete(b.subs, key)
+	close(sub.stop)
+
+	c := b.channels[sub.subject]
+	delete(c.subs, key)
+	if len(c.subs) > 0 {
+		return nil
+	}
+	delete(b.channels, sub.subject)
+	var err error
+	if strings.Contains(sub.subject, "*") {
+		err = b.pubsub.PUnsubscribe(context.Background(), sub.subject)
+	} else {
+		err = b.pubsub.Unsubscribe(context.Background(), sub.subject)
+	}
+	if err != nil {
+		return fmt.Errorf("redis unsubscribe %s: %w", sub.subject, err)
+	}
+	return nil
+}
+
+// SubscribeToChat subscribes to the chat subject of this bus's region for a
+// specific session. Sessions in the same chat share the Redis channel
+// subscription.
+func (b *RedisBus) SubscribeToChat(chatID, sessionID string, handler func(data []byte)) error {
+	return b.subscribe("chatsub:"+sessionID, ChatSubject(b.region, chatID), handler)
+}
+
+// UnsubscribeFromChat unsubscribes a session's chat subscription and the
+// cross-region bridge started for it, if any.
+func (b *RedisBus) UnsubscribeFromChat(sessionID string) error {
+	_ = b.unsubscribe("chatbridge:" + sessionID)
+	return b.unsubscribe("chatsub:" + sessionID)
+}
+
+// BridgeChat makes a session's chat subscription also receive events for a
+// cross-region chat from the partner's region subject. Every region shares
+// the one Redis, so unlike NATSClient it subscribes the session's handler to
+// the remote channel directly instead of copying events. SubscribeToChat
+// must be called first.
+func (b *RedisBus) BridgeChat(chatID, sessionID, remoteRegion string) error {
+	if remoteRegion == b.region {
+		return nil
+	}
+	b.mu.Lock()
+	local, ok := b.subs["chatsub:"+sessionID]
+	b.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("redis: session %s is not subscribed to a chat", sessionID)
+	}
+	return b.subscribe("chatbridge:"+sessionID, ChatSubject(remoteRegion, chatID), local.handler)
+}
+
+// PublishChatMessage publishes data to the chat subject of this bus's
+// region.
+func (b *RedisBus) PublishChatMessage(chatID string, data []byte) error {
+	return b.Publish(ChatSubject(b.region, chatID), data)
+}
+
+// PublishMatchRequest publishes data to the match.request subject of this
+// bus's region.
+func (b *RedisBus) PublishMatchRequest(data []byte) error {
+	return b.Publish(RegionalSubject(SubjectMatchRequest, b.region), data)
+}
+
+// subscribeMatch subscribes handler to the match subjects of the configured
+// match regions.
+func (b *RedisBus) subscribeMatch(subject string, handler func(data []byte)) error {
+	for _, s := range regionSubjects(subject, b.matchRegions) {
+		if err := b.subscribe(s, s, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubscribeMatchRequest subscribes to match requests in the configured match
+// regions.
+func (b *RedisBus) SubscribeMatchRequest(handler func(data []byte)) error {
+	return b.subscribeMatch(SubjectMatchRequest, handler)
+}
+
+// PublishMatchCancel publishes a match cancellation request in this bus's
+// region.
+func (b *RedisBus) PublishMatchCancel(data []byte) error {
+	return b.Publish(RegionalSubject(SubjectMatchCancel, b.region), data)
+}
+
+// SubscribeMatchCancel subscribes to match cancellations in the configured
+// match regions.
+func (b *RedisBus) SubscribeMatchCancel(handler func(data []byte)) error {
+	return b.subscribeMatch(SubjectMatchCancel, handler)
+}
+
+// PublishMatchResume publishes a matching-state resumption request.
+func (b *RedisBus) PublishMatchResume(data []byte) error {
+	return b.Publish(RegionalSubject(SubjectMatchResume, b.region), data)
+}
+
+// SubscribeMatchResume subscribes to matching-state resumption requests in
+// the configured match regions.
+func (b *RedisBus) SubscribeMatchResume(handler func(data []byte)) error {
+	return b.subscribeMatch(SubjectMatchResume, handler)
+}
+
+// SubscribeMatchFound subscribes to match.found.<sessionID>.
+func (b *RedisBus) SubscribeMatchFound(sessionID string, handler func(data []byte)) error {
+	subject := SubjectMatchFound + "." + sessionID
+	return b.subscribe(subject, subject, handler)
+}
+
+// UnsubscribeMatchFound unsubscribes from match.found.<sessionID>.
+func (b *RedisBus) UnsubscribeMatchFound(sessionID string) error {
+	return b.unsubscribe(SubjectMatchFound + "." + sessionID)
+}
+
+// PublishMatchNotify publishes a match lifecycle notification to a session.
+func (b *RedisBus) PublishMatchNotify(sessionID string, data []byte) error {
+	return b.Publish(SubjectMatchNotify+"."+sessionID, data)
+}
+
+// SubscribeMatchNotify subscribes to match lifecycle notifications for a
+// session.
+func (b *RedisBus) SubscribeMatchNotify(sessionID string, handler func(data []byte)) error {
+	subject := SubjectMatchNotify + "." + sessionID
+	return b.subscribe(subject, subject, handler)
+}
+
+// UnsubscribeMatchNotify unsubscribes from match lifecycle notifications.
+func (b *RedisBus) UnsubscribeMatchNotify(sessionID string) error {
+	return b.unsubscribe(SubjectMatchNotify + "." + sessionID)
+}
+
+// PublishMatchStats publishes the matcher's periodic queue statistics.
+func (b *RedisBus) PublishMatchStats(data []byte) error {
+	return b.Publish(SubjectMatchStats, data)
+}
+
+// SubscribeMatchStats subscribes to the matcher's periodic queue statistics.
+func (b *RedisBus) SubscribeMatchStats(handler func(data []byte)) error {
+	return b.subscribe(SubjectMatchStats, SubjectMatchStats, handler)
+}
+
+// PublishMatchTiers asks every matcher to switch to new tier thresholds.
+func (b *RedisBus) PublishMatchTiers(data []byte) error {
+	return b.Publish(SubjectMatchTiers, data)
+}
+
+// SubscribeMatchTiers subscribes to tier threshold updates.
+func (b *RedisBus) SubscribeMatchTiers(handler func(data []byte)) error {
+	return b.subscribe(SubjectMatchTiers, SubjectMatchTiers, handler)
+}
+
+// PublishModerationRequest publishes a moderation check request.
+func (b *RedisBus) PublishModerationRequest(data []byte) error {
+	return b.Publish(SubjectModeration, data)
+}
+
+// SubscribeModerationCheck subscribes to moderation check requests. Redis
+// Pub/Sub has no queue groups, so every moderator on a RedisBus handles
+// every request; run one.
+func (b *RedisBus) SubscribeModerationCheck(handler func(data []byte)) error {
+	return b.subscribe(SubjectModeration, SubjectModeration, handler)
+}
+
+// ModerationCheckBacklog reports the moderation check subscription's queued
+// and dropped messages. Both are zero when not subscribed.
+func (b *RedisBus) ModerationCheckBacklog() (pending, dropped int) {
+	b.mu.Lock()
+	sub := b.subs[SubjectModeration]
+	b.mu.Unlock()
+	if sub == nil {
+		return 0, 0
+	}
+	return sub.backlog()
+}
+
+// PublishModerationResult publishes a moderation result for a session.
+func (b *RedisBus) PublishModerationResult(sessionID string, data []byte) error {
+	return b.Publish(SubjectModerationResult+"."+sessionID, data)
+}
+
+// SubscribeModerationResult subscribes to moderation results for a session.
+func (b *RedisBus) SubscribeModerationResult(sessionID string, handler func(data []byte)) error {
+	subject := SubjectModerationResult + "." + sessionID
+	return b.subscribe(subject, subject, handler)
+}
+
+// UnsubscribeModerationResult unsubscribes from moderation results for a
+// session.
+func (b *RedisBus) UnsubscribeModerationResult(sessionID string) error {
+	return b.unsubscribe(SubjectModerationResult + "." + sessionID)
+}
+
+// PublishServerSend publishes a delivery for a session hosted on the named
+// wsserver.
+func (b *RedisBus) PublishServerSend(serverName string, data []byte) error {
+	return b.Publish(ServerSendSubject(serverName), data)
+}
+
+// SubscribeServerSend subscribes to deliveries for sessions hosted on the
+// named wsserver.
+func (b *RedisBus) SubscribeServerSend(serverName string, handler func(data []byte)) error {
+	subject := ServerSendSubject(serverName)
+	return b.subscribe(subject, subject, handler)
+}
+
+// PublishBlocklistUpdated notifies all services that the dynamic blocklist
+// changed and should be reloaded.
+func (b *RedisBus) PublishBlocklistUpdated() error {
+	return b.Publish(SubjectBlocklistUpdated, nil)
+}
+
+// SubscribeBlocklistUpdated subscribes to dynamic blocklist change
+// notifications.
+func (b *RedisBus) SubscribeBlocklistUpdated(handler func()) error {
+	return b.subscribe(SubjectBlocklistUpdated, SubjectBlocklistUpdated, func(_ []byte) {
+		handler()
+	})
+}
+
+// PublishFeatureFlagsUpdated notifies all services that a feature flag
+// rollout changed and the flags should be reloaded.
+func (b *RedisBus) PublishFeatureFlagsUpdated() error {
+	return b.Publish(SubjectFeatureFlagsUpdated, nil)
+}
+
+// SubscribeFeatureFlagsUpdated subscribes to feature flag change
+// notifications.
+func (b *RedisBus) SubscribeFeatureFlagsUpdated(handler func()) error {
+	return b.subscribe(SubjectFeatureFlagsUpdated, SubjectFeatureFlagsUpdated, func(_ []byte) {
+		handler()
+	})
+}
+
+// PublishMaintenanceUpdated notifies all services that maintenance mode was
+// switched on or off and should be reloaded.
+func (b *RedisBus) PublishMaintenanceUpdated() error {
+	return b.Publish(SubjectMaintenanceUpdated, nil)
+}
+
+// SubscribeMaintenanceUpdated subscribes to maintenance mode change
+// notifications.
+func (b *RedisBus) SubscribeMaintenanceUpdated(handler func()) error {
+	return b.subscribe(SubjectMaintenanceUpdated, SubjectMaintenanceUpdated, func(_ []byte) {
+		handler()
+	})
+}
+
+// Close drops all subscriptions and closes the Pub/Sub connection. Queued
+// messages that were not handled yet are discarded.
+func (b *RedisBus) Close() {
+	b.mu.Lock()
+	for _, sub := range b.subs {
+		close(sub.stop)
+	}
+	b.subs = make(map[string]*redisSub)
+	b.channels = make(map[string]*redisChannel)
+	b.mu.Unlock()
+
+	if err := b.pubsub.Close(); err != nil {
+		log.Printf("[bus] close pubsub: %v", err)
+	}
+	log.Printf("[bus] redis bus closed")
+}
+
+// redisSub is one subscription: a bounded queue drained by its own handler
+// goroutine.
+type redisSub struct {
+	subject string
+	handler func(data []byte)
+	limit   int
+
+	mu      sync.Mutex
+	queue   [][]byte
+	dropped int
+	slow    bool // dropping since the queue last drained
+
+	wake chan struct{}
+	stop chan struct{}
+}
+
+// deliver queues data for the handler, dropping it when the queue is full.
+func (s *redisSub) deliver(data []byte) {
+	s.mu.Lock()
+	if len(s.queue) >= s.limit {
+		s.dropped++
+		first := !s.slow
+		s.slow = true
+		s.mu.Unlock()
+		if first {
+			metrics.NATSAsyncErrorsTotal.WithLabelValues("slow_consumer", SubjectPattern(s.subject)).Inc()
+			log.Printf("[bus] slow consumer subject=%s pending_msgs=%d: dropping messages", s.subject, s.limit)
+		}
+		return
+	}
+	s.queue = append(s.queue, data)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run calls the handler for queued messages in order until stop is closed.
+func (s *redisSub) run() {
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		}
+		for {
+			select {
+			case <-s.stop:
+				return
+			default:
+			}
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				s.queue = nil // release the backing array after a burst
+				s.slow = false
+				s.mu.Unlock()
+				break
+			}
+			data := s.queue[0]
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			s.handler(data)
+		}
+	}
+}
+
+// backlog returns the number of queued and dropped messages.
+func (s *redisSub) backlog() (pending, dropped int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue), s.dropped
+}
@@ -0,0 +1,180 @@
+package messaging
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// receiver collects handler calls for assertions.
+type receiver chan string
+
+func (r receiver) handle(data []byte) { r <- string(data) }
+
+func (r receiver) expect(t *testing.T, want string) {
+	t.Helper()
+	select {
+	case got := <-r:
+		if got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %q", want)
+	}
+}
+
+func (r receiver) expectNothing(t *testing.T) {
+	t.Helper()
+	select {
+	case got := <-r:
+		t.Errorf("unexpected message %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func newTestRedisBus(t *testing.T, config RedisBusConfig) *RedisBus {
+	t.Helper()
+	b := NewRedisBus(testutil.Redis(t), config)
+	t.Cleanup(b.Close)
+	return b
+}
+
+func TestRedisBus_ChatFanOut(t *testing.T) {
+	b := newTestRedisBus(t, DefaultRedisBusConfig())
+	alice, bob := make(receiver, 4), make(receiver, 4)
+	if err := b.SubscribeToChat("c1", "alice", alice.handle); err != nil {
+		t.Fatalf("subscribe alice: %v", err)
+	}
+	if err := b.SubscribeToChat("c1", "bob", bob.handle); err != nil {
+		t.Fatalf("subscribe bob: %v", err)
+	}
+
+	if err := b.PublishChatMessage("c1", []byte("hi")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	alice.expect(t, "hi")
+	bob.expect(t, "hi")
+
+	// Alice leaving must not end Bob's subscription to the shared channel.
+	if err := b.UnsubscribeFromChat("alice"); err != nil {
+		t.Fatalf("unsubscribe alice: %v", err)
+	}
+	if err := b.PublishChatMessage("c1", []byte("still there?")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	bob.expect(t, "still there?")
+	alice.expectNothing(t)
+
+	if err := b.UnsubscribeFromChat("alice"); err == nil {
+		t.Error("second unsubscribe succeeded")
+	}
+}
+
+func TestRedisBus_MatchRegions(t *testing.T) {
+	publisher := newTestRedisBus(t, RedisBusConfig{Region: "eu-west"})
+	all := newTestRedisBus(t, DefaultRedisBusConfig())
+	us := newTestRedisBus(t, RedisBusConfig{MatchRegions: []string{"us-east"}})
+
+	allReqs, usReqs := make(receiver, 4), make(receiver, 4)
+	if err := all.SubscribeMatchRequest(allReqs.handle); err != nil {
+		t.Fatalf("subscribe all: %v", err)
+	}
+	if err := us.SubscribeMatchRequest(usReqs.handle); err != nil {
+		t.Fatalf("subscribe us: %v", err)
+	}
+
+	if err := publisher.PublishMatchRequest([]byte("eu")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	allReqs.expect(t, "eu") // via the match.request.* pattern
+	usReqs.expectNothing(t)
+}
+
+func TestRedisBus_BridgeChat(t *testing.T) {
+	eu := newTestRedisBus(t, RedisBusConfig{Region: "eu-west"})
+	us := newTestRedisBus(t, RedisBusConfig{Region: "us-east"})
+
+	got := make(receiver, 4)
+	if err := eu.SubscribeToChat("c1", "alice", got.handle); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := eu.BridgeChat("c1", "alice", "us-east"); err != nil {
+		t.Fatalf("bridge: %v", err)
+	}
+
+	if err := us.PublishChatMessage("c1", []byte("from bob")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	got.expect(t, "from bob")
+
+	if err := eu.UnsubscribeFromChat("alice"); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if err := us.PublishChatMessage("c1", []byte("gone")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	got.expectNothing(t)
+
+	if err := eu.BridgeChat("c2", "nobody", "us-east"); err == nil {
+		t.Error("bridge without a chat subscription succeeded")
+	}
+}
+
+func TestRedisBus_SlowConsumerDrops(t *testing.T) {
+	b := newTestRedisBus(t, RedisBusConfig{PendingLimit: 2})
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	handled := make(receiver, 8)
+	if err := b.SubscribeModerationCheck(func(data []byte) {
+		once.Do(func() { close(started) })
+		<-release
+		handled.handle(data)
+	}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	// Block the handler on "1", then queue "2" and "3" and overflow with "4".
+	if err := b.PublishModerationRequest([]byte("1")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler not called")
+	}
+	for _, m := range []string{"2", "3", "4"} {
+		if err := b.PublishModerationRequest([]byte(m)); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pending, dropped := b.ModerationCheckBacklog()
+		if pending == 2 && dropped == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backlog = %d pending, %d dropped; want 2, 1", pending, dropped)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	handled.expect(t, "1")
+	handled.expect(t, "2")
+	handled.expect(t, "3")
+	handled.expectNothing(t)
+}
+
+func TestParseBusKind(t *testing.T) {
+	for in, want := range map[string]string{"": BusNATS, "nats": BusNATS, " Redis ": BusRedis} {
+		if got, err := ParseBusKind(in); err != nil || got != want {
+			t.Errorf("ParseBusKind(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseBusKind("kafka"); err == nil {
+		t.Error("ParseBusKind accepted kafka")
+	}
+}
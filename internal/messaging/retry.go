@@ -0,0 +1,258 @@
+package messaging
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/whisper/chat-app/internal/metrics"
+)
+
+// ErrPublishDropped is returned when a publish failed and the retry queue is
+// full, so the message was discarded.
+var ErrPublishDropped = errors.New("nats: publish dropped, retry queue full")
+
+// PublishRetryConfig controls how publishes that fail while NATS is
+// unavailable are held in memory and retried.
+type PublishRetryConfig struct {
+	QueueSize      int           // max messages held for retry; 0 disables retries
+	MaxAge         time.Duration // queued messages older than this are dropped instead of delivered late
+	InitialBackoff time.Duration // delay before the first retry
+	MaxBackoff     time.Duration // cap on the exponential retry delay
+}
+
+// DefaultPublishRetryConfig returns sensible defaults: up to 1024 messages
+// are held for at most 30s, retried every 100ms backing off to 5s.
+func DefaultPublishRetryConfig() PublishRetryConfig {
+	return PublishRetryConfig{
+		QueueSize:      1024,
+		MaxAge:         30 * time.Second,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+	}
+}
+
+// retryablePublishError reports whether a publish error is transient, i.e.
+// the same publish may succeed once the connection recovers.
+func retryablePublishError(err error) bool {
+	switch {
+	case errors.Is(err, nats.ErrReconnectBufExceeded),
+		errors.Is(err, nats.ErrConnectionReconnecting),
+		errors.Is(err, nats.ErrNoServers),
+		errors.Is(err, nats.ErrStaleConnection),
+		errors.Is(err, nats.ErrTimeout):
+		return true
+	default:
+		return false
+	}
+}
+
+// pendingPublish is a message waiting in the dead-letter queue.
+type pendingPublish struct {
+	subject  string
+	data     []byte
+	queuedAt time.Time
+}
+
+// retryPublisher wraps a publish function with a bounded in-memory
+// dead-letter queue. Publishes that fail with a transient error are queued
+// and delivered in order by a background flusher. While the queue is not
+// empty, new publishes are queued behind it so per-subject ordering (e.g.
+// chat messages) is preserved.
+//
+// No publish runs under mu: a flusher stuck on a slow NATS does not hold
+// up callers, which queue behind it. The flusher leaves a message at the
+// head of the queue until it was delivered, so the queue stays non-empty,
+// and ordered, while a retry is in flight.
+type retryPublisher struct {
+	config  PublishRetryConfig
+	publish func(subject string, data []byte) error
+
+	mu    sync.Mutex // guards queue
+	queue []pendingPublish
+
+	wake    chan struct{} // signalled when the queue becomes non-empty
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// newRetryPublisher creates a retryPublisher and starts its flusher.
+func newRetryPublisher(config PublishRetryConfig, publish func(subject string, data []byte) error) *retryPublisher {
+	r := &retryPublisher{
+		config:  config,
+		publish: publish,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Publish sends data to subject, or queues it for retry if NATS is
+// temporarily unavailable. A queued message counts as accepted and Publish
+// returns nil; it returns ErrPublishDropped if the queue is full and the
+// original error for non-transient failures.
+func (r *retryPublisher) Publish(subject string, data []byte) error {
+	r.mu.Lock()
+	backlog := len(r.queue) > 0
+	r.mu.Unlock()
+
+	if !backlog {
+		err := r.publish(subject, data)
+		if err == nil {
+			return nil
+		}
+		if !retryablePublishError(err) {
+			metrics.NATSPublishRetriesTotal.WithLabelValues("dropped", SubjectPattern(subject)).Inc()
+			return err
+		}
+		log.Printf("[nats] publish %s failed, queueing for retry: %v", subject, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enqueueLocked(subject, data)
+}
+
+// enqueueLocked appends a message to the queue. The caller must hold r.mu.
+func (r *retryPublisher) enqueueLocked(subject string, data []byte) error {
+	if len(r.queue) >= r.config.QueueSize {
+		metrics.NATSPublishRetriesTotal.WithLabelValues("dropped", SubjectPattern(subject)).Inc()
+		return ErrPublishDropped
+	}
+
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	r.queue = append(r.queue, pendingPublish{subject: subject, data: buf, queuedAt: time.Now()})
+	metrics.NATSPublishRetriesTotal.WithLabelValues("queued", SubjectPattern(subject)).Inc()
+	metrics.NATSRetryQueueSize.Set(float64(len(r.queue)))
+
+	if len(r.queue) == 1 {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// run is the background flusher. It drains the queue whenever it becomes
+// non-empty, backing off exponentially while NATS keeps failing.
+func (r *retryPublisher) run() {
+	defer close(r.stopped)
+
+	backoff := r.config.InitialBackoff
+	timer := time.NewTimer(backoff)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-r.wake:
+		case <-timer.C:
+		}
+
+		if r.flush() {
+			backoff = r.config.InitialBackoff
+			continue
+		}
+		timer.Reset(backoff)
+		backoff *= 2
+		if backoff > r.config.MaxBackoff {
+			backoff = r.config.MaxBackoff
+		}
+	}
+}
+
+// flush delivers queued messages in order until the queue is empty or a
+// publish fails transiently. Messages older than MaxAge and messages that
+// fail permanently are dropped. It reports whether the queue was emptied.
+// Only the flusher calls it, so the head of the queue is its own to remove.
+func (r *retryPublisher) flush() bool {
+	recovered, expired := 0, 0
+	defer func() {
+		r.mu.Lock()
+		remaining := len(r.queue)
+		r.mu.Unlock()
+		metrics.NATSRetryQueueSize.Set(float64(remaining))
+		if recovered > 0 || expired > 0 {
+			log.Printf("[nats] retry queue: recovered=%d expired=%d remaining=%d", recovered, expired, remaining)
+		}
+	}()
+
+	for {
+		p, ok := r.head()
+		if !ok {
+			return true
+		}
+		pattern := SubjectPattern(p.subject)
+
+		if time.Since(p.queuedAt) > r.config.MaxAge {
+			metrics.NATSPublishRetriesTotal.WithLabelValues("dropped", pattern).Inc()
+			expired++
+			r.pop()
+			continue
+		}
+
+		if err := r.publish(p.subject, p.data); err != nil {
+			if retryablePublishError(err) {
+				return false
+			}
+			log.Printf("[nats] retry publish %s failed permanently: %v", p.subject, err)
+			metrics.NATSPublishRetriesTotal.WithLabelValues("dropped", pattern).Inc()
+			r.pop()
+			continue
+		}
+
+		metrics.NATSPublishRetriesTotal.WithLabelValues("recovered", pattern).Inc()
+		recovered++
+		r.pop()
+	}
+}
+
+// head returns the oldest queued message, if any.
+func (r *retryPublisher) head() (pendingPublish, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.queue) == 0 {
+		return pendingPublish{}, false
+	}
+	return r.queue[0], true
+}
+
+// pop removes the oldest queued message, releasing the backing array once
+// the queue is empty.
+func (r *retryPublisher) pop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queue = r.queue[1:]
+	if len(r.queue) == 0 {
+		r.queue = nil
+	}
+}
+
+// Stop stops the flusher, makes a final delivery attempt and drops whatever
+// is still queued.
+func (r *retryPublisher) Stop() {
+	close(r.done)
+	<-r.stopped
+
+	if r.flush() {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.queue {
+		metrics.NATSPublishRetriesTotal.WithLabelValues("dropped", SubjectPattern(p.subject)).Inc()
+	}
+	log.Printf("[nats] retry queue: dropped %d undelivered publishes on close", len(r.queue))
+	r.queue = nil
+	metrics.NATSRetryQueueSize.Set(0)
+}
@@ -0,0 +1,203 @@
+package messaging
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// fakePublisher records delivered messages and fails with err while set.
+type fakePublisher struct {
+	mu        sync.Mutex
+	err       error
+	delivered []string
+}
+
+func (f *fakePublisher) publish(subject string, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.delivered = append(f.delivered, subject+":"+string(data))
+	return nil
+}
+
+func (f *fakePublisher) setErr(err error) {
+	f.mu.Lock()
+	f.err = err
+	f.mu.Unlock()
+}
+
+func (f *fakePublisher) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.delivered...)
+}
+
+func testRetryConfig() PublishRetryConfig {
+	return PublishRetryConfig{
+		QueueSize:      3,
+		MaxAge:         time.Minute,
+		InitialBackoff: 5 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+	}
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRetryPublisher_RecoversInOrder(t *testing.T) {
+	f := &fakePublisher{}
+	r := newRetryPublisher(testRetryConfig(), f.publish)
+	defer r.Stop()
+
+	f.setErr(nats.ErrReconnectBufExceeded)
+	for _, msg := range []string{"1", "2"} {
+		if err := r.Publish("chat.a", []byte(msg)); err != nil {
+			t.Fatalf("expected publish to be queued, got %v", err)
+		}
+	}
+	f.setErr(nil)
+
+	// Published after recovery but must stay behind the queued messages.
+	if err := r.Publish("chat.a", []byte("3")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitFor(t, func() bool { return len(f.messages()) == 3 })
+	got := f.messages()
+	want := []string{"chat.a:1", "chat.a:2", "chat.a:3"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestRetryPublisher_SlowRetryDoesNotBlockPublish(t *testing.T) {
+	f := &fakePublisher{err: nats.ErrTimeout}
+	retrying := make(chan struct{})
+	release := make(chan struct{})
+	var calls int
+	var once sync.Once
+	publish := func(subject string, data []byte) error {
+		f.mu.Lock()
+		calls++
+		first := calls == 1
+		f.mu.Unlock()
+		if first {
+			return f.publish(subject, data)
+		}
+		// The flusher's retry hangs on a slow NATS until released.
+		once.Do(func() {
+			close(retrying)
+			<-release
+		})
+		f.setErr(nil)
+		return f.publish(subject, data)
+	}
+	r := newRetryPublisher(testRetryConfig(), publish)
+	defer r.Stop()
+
+	if err := r.Publish("chat.a", []byte("1")); err != nil {
+		t.Fatalf("expected publish to be queued, got %v", err)
+	}
+	<-retrying
+
+	published := make(chan error, 1)
+	go func() { published <- r.Publish("chat.a", []byte("2")) }()
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked behind the flusher's retry")
+	}
+	close(release)
+
+	waitFor(t, func() bool { return len(f.messages()) == 2 })
+	if got := f.messages(); got[0] != "chat.a:1" || got[1] != "chat.a:2" {
+		t.Errorf("expected [chat.a:1 chat.a:2], got %v", got)
+	}
+}
+
+func TestRetryPublisher_QueueFull(t *testing.T) {
+	f := &fakePublisher{err: nats.ErrReconnectBufExceeded}
+	r := newRetryPublisher(testRetryConfig(), f.publish)
+	defer r.Stop()
+
+	for i := 0; i < 3; i++ {
+		if err := r.Publish("chat.a", []byte("x")); err != nil {
+			t.Fatalf("publish %d: expected queued, got %v", i, err)
+		}
+	}
+	if err := r.Publish("chat.a", []byte("x")); !errors.Is(err, ErrPublishDropped) {
+		t.Errorf("expected ErrPublishDropped, got %v", err)
+	}
+}
+
+func TestRetryPublisher_PermanentErrorNotQueued(t *testing.T) {
+	f := &fakePublisher{err: nats.ErrMaxPayload}
+	r := newRetryPublisher(testRetryConfig(), f.publish)
+	defer r.Stop()
+
+	if err := r.Publish("chat.a", []byte("x")); !errors.Is(err, nats.ErrMaxPayload) {
+		t.Errorf("expected ErrMaxPayload, got %v", err)
+	}
+	r.mu.Lock()
+	queued := len(r.queue)
+	r.mu.Unlock()
+	if queued != 0 {
+		t.Errorf("expected nothing queued, got %d", queued)
+	}
+}
+
+func TestRetryPublisher_DropsExpired(t *testing.T) {
+	f := &fakePublisher{err: nats.ErrNoServers}
+	config := testRetryConfig()
+	config.MaxAge = 10 * time.Millisecond
+	r := newRetryPublisher(config, f.publish)
+	defer r.Stop()
+
+	if err := r.Publish("chat.a", []byte("stale")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	f.setErr(nil)
+
+	waitFor(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.queue) == 0
+	})
+	if got := f.messages(); len(got) != 0 {
+		t.Errorf("expected expired message to be dropped, got %v", got)
+	}
+}
+
+func TestRetryablePublishError(t *testing.T) {
+	for _, err := range []error{nats.ErrReconnectBufExceeded, nats.ErrNoServers, nats.ErrTimeout} {
+		if !retryablePublishError(err) {
+			t.Errorf("expected %v to be retryable", err)
+		}
+	}
+	for _, err := range []error{nats.ErrMaxPayload, nats.ErrBadSubject, nats.ErrConnectionClosed} {
+		if retryablePublishError(err) {
+			t.Errorf("expected %v not to be retryable", err)
+		}
+	}
+}
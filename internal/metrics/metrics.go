@@ -18,24 +18,346 @@ var (
 	})
 
 	// MessagesTotal counts the total number of messages processed, labeled by
-	// type: "sent", "received", or "blocked".
+	// type: "sent", "received", "blocked", or "muted" (refused because the
+	// sender is muted by filter enforcement).
 	MessagesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "whisper_messages_total",
 		Help: "Total number of messages processed",
-	}, []string{"type"}) // type = "sent", "received", "blocked"
+	}, []string{"type"}) // type = "sent", "received", "blocked", "muted"
 
-	// MessageLatency records message processing latency in seconds.
-	MessageLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+	// TranslationsTotal counts chat messages sent through the translation
+	// provider, labeled by outcome: "translated" or "failed". Failed
+	// messages are still delivered, untranslated.
+	TranslationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_translations_total",
+		Help: "Total number of chat message translations, by outcome",
+	}, []string{"outcome"})
+
+	// ReportsTotal counts abuse reports accepted by the wsserver, labeled by
+	// report category.
+	ReportsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_reports_total",
+		Help: "Total number of abuse reports filed, by category",
+	}, []string{"category"})
+
+	// DirectDeliveriesTotal counts frames addressed to a single session
+	// outside its chat, labeled by how they were delivered: "local",
+	// "relayed" (published to the hosting server), "received" (relayed
+	// from another server and written locally) or "not_found".
+	DirectDeliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_direct_deliveries_total",
+		Help: "Frames delivered to a specific session, by delivery path",
+	}, []string{"result"})
+
+	// MessagesRetractedTotal counts delivered messages retracted after async
+	// moderation flagged them, labeled by moderation reason.
+	MessagesRetractedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_messages_retracted_total",
+		Help: "Total number of delivered messages retracted by async moderation",
+	}, []string{"reason"})
+
+	// ChatEventAnomaliesTotal counts chat events received out of sequence,
+	// labeled by kind: "duplicate" (dropped), "late" (delivered out of
+	// order) or "lost" (never arrived within the gap grace period).
+	ChatEventAnomaliesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_chat_event_anomalies_total",
+		Help: "Total number of chat events received duplicated, late or not at all",
+	}, []string{"kind"})
+
+	// MessageLatency records the time from reading a chat message from the
+	// sender to writing it to the partner's socket, labeled by path:
+	// "same_server" when both users are on one wsserver, "cross_server"
+	// otherwise. Cross-server values compare two servers' clocks, so they are
+	// only as accurate as the servers' clock sync.
+	MessageLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "whisper_message_latency_seconds",
-		Help:    "Message processing latency in seconds",
+		Help:    "Time from receiving a chat message to writing it to the partner, in seconds",
 		Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"path"}) // path = "same_server", "cross_server"
+
+	// ClientRTT records WebSocket round-trip times measured from heartbeat
+	// ping/pong frames.
+	ClientRTT = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "whisper_client_rtt_seconds",
+		Help:    "Client round-trip time measured from heartbeat ping/pong frames",
+		Buckets: []float64{.01, .025, .05, .1, .2, .3, .5, 1, 2, 5},
+	})
+
+	// HeartbeatPingsTotal counts heartbeat ping decisions by result: "sent",
+	// "skipped" (the connection sent data recently) or "failed" (the write
+	// failed and the connection was removed).
+	HeartbeatPingsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_heartbeat_pings_total",
+		Help: "Total number of heartbeat pings by result",
+	}, []string{"result"})
+
+	// HeartbeatPongsTotal counts pongs by result: "answered" (echoing a
+	// heartbeat ping, observed in ClientRTT), "unsolicited" (any other
+	// payload) or "missed" (a ping was due while the previous one was still
+	// unanswered).
+	HeartbeatPongsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_heartbeat_pongs_total",
+		Help: "Total number of heartbeat pongs by result",
+	}, []string{"result"})
+
+	// HeartbeatTimeoutsTotal counts connections evicted by the heartbeat
+	// because nothing was read from them within interval + timeout.
+	HeartbeatTimeoutsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "whisper_heartbeat_timeouts_total",
+		Help: "Total number of connections closed for missing heartbeats",
+	})
+
+	// DispatchWait records how long a ready connection waited in the
+	// dispatch queue before a read worker picked it up.
+	DispatchWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "whisper_ws_dispatch_wait_seconds",
+		Help:    "Time a ready connection waited for a read worker",
+		Buckets: []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1},
+	})
+
+	// FrameHandleDuration records how long a read worker spent on one frame,
+	// including the message handler.
+	FrameHandleDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "whisper_ws_frame_handle_seconds",
+		Help:    "Time a read worker spent reading and handling one frame",
+		Buckets: []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	})
+
+	// WorkerSaturation is the fraction of read workers busy handling a
+	// frame, from 0 to 1.
+	WorkerSaturation = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "whisper_ws_worker_saturation",
+		Help: "Fraction of read workers currently busy",
+	})
+
+	// DispatchQueueDepth tracks ready connections waiting for a read worker.
+	DispatchQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "whisper_ws_dispatch_queue_depth",
+		Help: "Ready connections waiting for a read worker",
 	})
 
-	// MatchDuration records the time from match request to match found.
-	MatchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	// BatchSize records the number of messages per batch frame, labeled by
+	// direction: "inbound" (sent by a client) or "outbound" (events the
+	// server coalesced because writes to the client were backed up).
+	BatchSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "whisper_ws_batch_size",
+		Help:    "Messages per batch frame",
+		Buckets: []float64{2, 4, 8, 16, 32},
+	}, []string{"direction"})
+
+	// FramesDroppedTotal counts data frames discarded with a server_busy
+	// error because the worker pool was overloaded (drop policy only).
+	FramesDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "whisper_ws_frames_dropped_total",
+		Help: "Total number of frames dropped because the worker pool was overloaded",
+	})
+
+	// ReadBufferPoolTotal counts data frame payloads read, by result: "hit"
+	// reused a pooled read buffer, "miss" allocated one for the pool and
+	// "bypass" allocated outside it because the frame was part of a
+	// fragmented message or larger than a pooled buffer.
+	ReadBufferPoolTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_ws_read_buffer_pool_total",
+		Help: "Total number of data frame payloads read, by read buffer pool result",
+	}, []string{"result"})
+
+	// FloodFramesDroppedTotal counts data frames dropped unparsed because
+	// their connection exceeded the frame rate limit.
+	FloodFramesDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "whisper_ws_flood_frames_dropped_total",
+		Help: "Total number of frames dropped because a connection exceeded the frame rate limit",
+	})
+
+	// FloodClosesTotal counts connections closed for flooding frames.
+	FloodClosesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "whisper_ws_flood_closes_total",
+		Help: "Total number of connections closed for exceeding the frame rate limit",
+	})
+
+	// WaitingRoomDepth tracks connections held in the waiting room while the
+	// server is at MAX_CONNECTIONS.
+	WaitingRoomDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "whisper_ws_waiting_room_depth",
+		Help: "Current number of connections waiting for a connection slot",
+	})
+
+	// WaitingRoomAdmissionSeconds measures how long admitted connections
+	// waited in the waiting room.
+	WaitingRoomAdmissionSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "whisper_ws_waiting_room_admission_seconds",
+		Help:    "Time connections spent in the waiting room before being admitted",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600},
+	})
+
+	// BotsFlaggedTotal counts fingerprints flagged as probable bots, labeled
+	// by the BOT_DETECTION action taken ("flag" or "ban").
+	BotsFlaggedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_bots_flagged_total",
+		Help: "Total number of fingerprints flagged as probable bots, by action",
+	}, []string{"action"})
+
+	// BotSignalsTotal counts the signals behind each bot flag.
+	BotSignalsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_bot_signals_total",
+		Help: "Total number of bot detection signals behind flagged fingerprints, by signal",
+	}, []string{"signal"})
+
+	// ClientSessionsTotal counts sessions that sent client_info, labeled by
+	// platform and app version reduced to "major.minor".
+	ClientSessionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_client_sessions_total",
+		Help: "Total number of sessions that declared their client, by platform and app version",
+	}, []string{"platform", "version"})
+
+	// ConnectionsByCountryTotal counts accepted connections, labeled by the
+	// ISO country code of the client IP ("unknown" if not in the GeoIP
+	// database). Only counted when a GeoIP database is configured.
+	ConnectionsByCountryTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_connections_by_country_total",
+		Help: "Total number of accepted connections, by country of the client IP",
+	}, []string{"country"})
+
+	// GeoIPLookupsTotal counts GeoIP lookups by result: cached, found,
+	// not_found or error.
+	GeoIPLookupsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_geoip_lookups_total",
+		Help: "Total number of GeoIP lookups, by result",
+	}, []string{"result"})
+
+	// MatchDuration records, per matched user, the time from match request
+	// to match found, labeled by the tier that produced the match. Set by the
+	// matcher.
+	MatchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "whisper_match_duration_seconds",
 		Help:    "Time from match request to match found",
 		Buckets: []float64{1, 2, 5, 10, 15, 20, 25, 30},
+	}, []string{"tier"})
+
+	// MatchesTotal counts matches made by the matcher, labeled by tier:
+	// "exact", "overlap", "single" or "random".
+	MatchesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_matches_total",
+		Help: "Total number of matches made, by matching tier",
+	}, []string{"tier"})
+
+	// MatchTimeoutsTotal counts users the matcher gave up on after the match
+	// timeout. Timeout rate is this over timeouts plus 2 * matches.
+	MatchTimeoutsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "whisper_match_timeouts_total",
+		Help: "Total number of users removed from the queue without a match",
+	})
+
+	// MatchReRollsTotal counts declines with re_roll, labeled by whether a
+	// re-roll credit gave the user priority ("true") or the hourly limit
+	// was spent and they were queued normally ("false").
+	MatchReRollsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_match_rerolls_total",
+		Help: "Total number of re-roll declines, by whether priority was granted",
+	}, []string{"priority"})
+
+	// MatchTierThresholdSeconds exposes the matcher's current tier
+	// thresholds, labeled "tier1", "tier2", "tier3" and "timeout", so
+	// runtime tuning shows up next to the wait histograms.
+	MatchTierThresholdSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "whisper_match_tier_threshold_seconds",
+		Help: "Wait after which the matcher widens to the next tier or gives up",
+	}, []string{"threshold"})
+
+	// MatchLoopDuration records how long one pass of the matcher over the
+	// queue takes. Passes run every 2s, so sustained values near that mean
+	// the matcher is falling behind.
+	MatchLoopDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "whisper_match_loop_duration_seconds",
+		Help:    "Time taken by one matcher pass over the queue",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2, 5},
+	})
+
+	// RateLimitDecisionsTotal counts rate limit checks, labeled by rule
+	// name (as in ratelimit.Named) and decision: "allowed", "rejected", or
+	// "error" when Redis failed and the check failed open.
+	RateLimitDecisionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_rate_limit_decisions_total",
+		Help: "Total number of rate limit checks, by rule and decision",
+	}, []string{"rule", "decision"})
+
+	// FilterBlocksTotal counts content the wsserver's content filter
+	// rejected, labeled by what was checked ("message", "nickname",
+	// "chat_meta", "share_card"), filter reason and term category (see
+	// moderation.FilterResult.TermCategory). Dry-run flags are counted in
+	// ModerationDryRunTotal instead.
+	FilterBlocksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_filter_blocks_total",
+		Help: "Total number of client content rejected by the content filter, by kind, reason and term category",
+	}, []string{"kind", "reason", "category"})
+
+	// FilterEnforcementsTotal counts the action taken on a session for a
+	// blocked chat message ("warn", "mute" or "ban"; see
+	// moderation.Enforcer).
+	FilterEnforcementsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_filter_enforcements_total",
+		Help: "Total number of actions taken on sessions for messages blocked by the content filter, by action",
+	}, []string{"action"})
+
+	// AbuseAlertsTotal counts abuse velocity alerts raised by the wsserver's
+	// alert monitor, labeled by rule and trigger ("threshold" or "spike").
+	// Only the instance that sends an alert counts it.
+	AbuseAlertsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_abuse_alerts_total",
+		Help: "Total number of abuse velocity alerts raised, by rule and trigger",
+	}, []string{"rule", "trigger"})
+
+	// ModerationChecksTotal counts messages checked by the moderator,
+	// labeled by result: "clean" or "flagged".
+	ModerationChecksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_moderation_checks_total",
+		Help: "Total number of messages checked by the moderator, by result",
+	}, []string{"result"})
+
+	// ModerationFlaggedTotal counts messages the moderator flagged, labeled
+	// by filter reason ("blocked_keyword", "spam_pattern") and term category
+	// ("word" or "phrase" for keywords, the spam check name for spam).
+	ModerationFlaggedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_moderation_flagged_total",
+		Help: "Total number of messages flagged by the moderator, by reason and term category",
+	}, []string{"reason", "category"})
+
+	// ModerationDryRunTotal counts content the filter flagged but did not
+	// enforce because moderation runs in dry-run mode, labeled by path
+	// ("sync" for the wsserver filter, "async" for the moderator) and reason.
+	ModerationDryRunTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_moderation_dry_run_flagged_total",
+		Help: "Total number of messages flagged but not enforced in moderation dry-run mode, by path and reason",
+	}, []string{"path", "reason"})
+
+	// ModerationCheckDuration records how long the content filter took to
+	// check one message.
+	ModerationCheckDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "whisper_moderation_check_duration_seconds",
+		Help:    "Time taken by the content filter to check one message",
+		Buckets: []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05},
+	})
+
+	// ModerationRequestAge records how old a check request was when the
+	// moderator picked it up, at the one-second resolution of its
+	// timestamp. Growing ages mean the moderator is falling behind.
+	ModerationRequestAge = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "whisper_moderation_request_age_seconds",
+		Help:    "Age of moderation check requests when the moderator handled them",
+		Buckets: []float64{1, 2, 5, 10, 30, 60, 120},
+	})
+
+	// ModerationPendingChecks tracks check requests received from NATS but
+	// not yet handled by the moderator.
+	ModerationPendingChecks = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "whisper_moderation_pending_checks",
+		Help: "Moderation check requests waiting to be handled",
+	})
+
+	// ModerationDroppedChecks tracks check requests the NATS client dropped
+	// because the moderator's backlog was full, since it subscribed.
+	ModerationDroppedChecks = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "whisper_moderation_dropped_checks",
+		Help: "Moderation check requests dropped by the NATS client as a slow consumer",
 	})
 
 	// ActiveChats tracks the current number of active chat sessions.
@@ -44,21 +366,145 @@ var (
 		Help: "Current number of active chat sessions",
 	})
 
-	// MatchQueueSize tracks the current number of users in the matching queue.
+	// MatchQueueSize tracks the current number of users in the matching
+	// queue, as seen by the matcher at the start of each pass.
 	MatchQueueSize = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "whisper_match_queue_size",
 		Help: "Current number of users in matching queue",
 	})
+
+	// MatchGateRejectionsTotal counts find_match requests refused because
+	// matchmaking was closed, labeled by reason: "closed_hours",
+	// "maintenance" or "capacity".
+	MatchGateRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_match_gate_rejections_total",
+		Help: "Total number of find_match requests refused while matchmaking was closed",
+	}, []string{"reason"})
+
+	// MaintenanceActive is 1 while maintenance mode is on, as last loaded by
+	// this instance.
+	MaintenanceActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "whisper_maintenance_active",
+		Help: "Whether maintenance mode is on (1) or off (0)",
+	})
+
+	// ChatSubscriptions tracks the chat subjects this server is subscribed
+	// to on NATS: one per chat with a local participant, however many.
+	ChatSubscriptions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "whisper_nats_chat_subscriptions",
+		Help: "Current number of NATS chat subscriptions, one per chat with a local participant",
+	})
+
+	// NATSAsyncErrorsTotal counts asynchronous NATS errors reported through
+	// the connection error handler, labeled by kind ("slow_consumer",
+	// "permission_violation", "other") and subject pattern.
+	NATSAsyncErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_nats_async_errors_total",
+		Help: "Total number of asynchronous NATS errors such as slow consumers",
+	}, []string{"kind", "subject"})
+
+	// NATSPublishRetriesTotal counts publishes that failed while NATS was
+	// unavailable, labeled by outcome ("queued", "recovered", "dropped") and
+	// subject pattern.
+	NATSPublishRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_nats_publish_retries_total",
+		Help: "Total number of failed NATS publishes by retry outcome",
+	}, []string{"outcome", "subject"})
+
+	// NATSRetryQueueSize tracks publishes waiting in the retry queue.
+	NATSRetryQueueSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "whisper_nats_retry_queue_size",
+		Help: "Current number of NATS publishes waiting to be retried",
+	})
+
+	// BlocksTotal counts personal blocks placed by users.
+	BlocksTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "whisper_blocks_total",
+		Help: "Total number of personal blocks placed by users",
+	})
+
+	// ChatOutcomesTotal counts chat outcomes by the matching tier that paired
+	// the users. outcome is "started", "short" (ended within 30s of
+	// starting) or "reported".
+	ChatOutcomesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_chat_outcomes_total",
+		Help: "Total chat outcomes by matching tier",
+	}, []string{"tier", "outcome"})
+
+	// ConfigReloadsTotal counts configuration reloads, labeled by result:
+	// "success", "invalid" (rejected, previous config kept) or "apply_error".
+	ConfigReloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "whisper_config_reloads_total",
+		Help: "Total number of configuration reloads by result",
+	}, []string{"result"})
+
+	// ConfigInfo is set to 1 for the hash of the configuration currently in
+	// effect, so dashboards can spot instances running a stale config.
+	ConfigInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "whisper_config_info",
+		Help: "Hash of the reloadable configuration currently in effect",
+	}, []string{"hash"})
 )
 
 func init() {
 	prometheus.MustRegister(
 		ConnectionsTotal,
 		MessagesTotal,
+		TranslationsTotal,
+		ReportsTotal,
+		DirectDeliveriesTotal,
+		MessagesRetractedTotal,
+		ChatEventAnomaliesTotal,
 		MessageLatency,
+		ClientRTT,
+		HeartbeatPingsTotal,
+		HeartbeatPongsTotal,
+		HeartbeatTimeoutsTotal,
+		DispatchWait,
+		FrameHandleDuration,
+		WorkerSaturation,
+		DispatchQueueDepth,
+		FramesDroppedTotal,
+		ReadBufferPoolTotal,
+		FloodFramesDroppedTotal,
+		FloodClosesTotal,
+		WaitingRoomDepth,
+		WaitingRoomAdmissionSeconds,
+		BotsFlaggedTotal,
+		BotSignalsTotal,
+		ClientSessionsTotal,
+		ConnectionsByCountryTotal,
+		GeoIPLookupsTotal,
+		BatchSize,
 		MatchDuration,
+		MatchesTotal,
+		MatchTimeoutsTotal,
+		MatchReRollsTotal,
+		MatchTierThresholdSeconds,
+		MatchLoopDuration,
+		RateLimitDecisionsTotal,
+		FilterBlocksTotal,
+		FilterEnforcementsTotal,
+		AbuseAlertsTotal,
+		ModerationChecksTotal,
+		ModerationFlaggedTotal,
+		ModerationDryRunTotal,
+		ModerationCheckDuration,
+		ModerationRequestAge,
+		ModerationPendingChecks,
+		ModerationDroppedChecks,
 		ActiveChats,
 		MatchQueueSize,
+		MatchGateRejectionsTotal,
+		MaintenanceActive,
+		ChatSubscriptions,
+		NATSAsyncErrorsTotal,
+		NATSPublishRetriesTotal,
+		NATSRetryQueueSize,
+		BlocksTotal,
+		ChatOutcomesTotal,
+		ConfigReloadsTotal,
+		ConfigInfo,
 	)
 }
 
@@ -0,0 +1,453 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/whisper/chat-app/internal/messaging"
+)
+
+const (
+	// BlocklistAddedKey is the Redis set of terms added at runtime on top of
+	// the compiled-in default blocklist.
+	BlocklistAddedKey = "moderation:blocklist:added"
+
+	// BlocklistRemovedKey is the Redis set of default terms that operators
+	// have disabled at runtime.
+	BlocklistRemovedKey = "moderation:blocklist:removed"
+
+	// BlocklistOptionsKey is the Redis hash of per-term match options,
+	// keyed by term, with JSON TermOptions values.
+	BlocklistOptionsKey = "moderation:blocklist:options"
+
+	// BlocklistLanguagesKey is the Redis set of language codes with terms
+	// added or removed at runtime. Each language has its own added set,
+	// removed set and options hash: the keys above with ":" + <lang>
+	// appended, e.g. moderation:blocklist:added:es.
+	BlocklistLanguagesKey = "moderation:blocklist:languages"
+
+	// AllowlistKey is the Redis set of words and phrases that are never
+	// blocked, even when they contain a blocked term.
+	AllowlistKey = "moderation:allowlist"
+
+	// blocklistPollInterval is the fallback reload period in case a bus
+	// change notification was missed (e.g. during a reconnect).
+	blocklistPollInterval = 10 * time.Second
+)
+
+// Checker is the content-checking interface shared by Filter and
+// DynamicFilter.
+type Checker interface {
+	Check(text string) FilterResult
+	CheckLanguage(text, lang string) FilterResult
+	CheckInterests(interests []string) []string
+}
+
+// DynamicFilter is a Filter whose blocklist can be changed at runtime. The
+// effective term list is the default blocklist plus the terms in
+// BlocklistAddedKey, minus the terms in BlocklistRemovedKey, matched with
+// the options in BlocklistOptionsKey and never blocking the words and phrases
+// in AllowlistKey. Each language's blocklist is built the same way from its
+// compiled-in and file-loaded terms (see SetLanguageLists) and its own added
+// set, removed set and options. Every instance rebuilds its Filter when a
+// change is announced on the bus and additionally polls Redis, so all
+// services converge within seconds.
+//
+// Check and CheckInterests are lock-free: the current Filter is swapped
+// atomically on reload.
+type DynamicFilter struct {
+	rdb       *redis.Client
+	bus       messaging.Bus
+	languages map[string][]string // base per-language lists; see SetLanguageLists
+	current   atomic.Pointer[Filter]
+}
+
+// NewDynamicFilter creates a DynamicFilter initially loaded with the default
+// blocklist. Call Start to load runtime changes and begin watching for
+// updates.
+func NewDynamicFilter(rdb *redis.Client, bus messaging.Bus) *DynamicFilter {
+	d := &DynamicFilter{rdb: rdb, bus: bus, languages: defaultLanguageBlocklists}
+	d.current.Store(NewFilter())
+	return d
+}
+
+// SetLanguageLists adds per-language blocklists, keyed by language code, to
+// the compiled-in ones, e.g. those read by LoadBlocklistDir. Terms from these
+// lists count as default terms: removing one records it in the removed set.
+// It must be called before Start.
+func (d *DynamicFilter) SetLanguageLists(lists map[string][]string) {
+	extra := make(map[string][]string, len(lists))
+	for lang, terms := range lists {
+		if lang = normalizeLanguage(lang); lang != "" {
+			extra[lang] = append(extra[lang], terms...)
+		}
+	}
+	d.languages = mergeLanguageLists(defaultLanguageBlocklists, extra)
+	d.current.Store(NewFilterWithLanguages(defaultBlocklist, d.languages, nil, nil))
+}
+
+// Start loads the runtime blocklist from Redis, subscribes to change
+// notifications and starts the fallback poll loop, which exits when ctx is
+// cancelled. A failed initial load is logged and the default blocklist stays
+// in effect.
+func (d *DynamicFilter) Start(ctx context.Context) error {
+	if err := d.Reload(ctx); err != nil {
+		log.Printf("[moderation] initial blocklist load failed, using defaults: %v", err)
+	}
+
+	if err := d.bus.SubscribeBlocklistUpdated(func() {
+		reloadCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := d.Reload(reloadCtx); err != nil {
+			log.Printf("[moderation] blocklist reload failed: %v", err)
+		}
+	}); err != nil {
+		return fmt.Errorf("moderation: subscribe blocklist updates: %w", err)
+	}
+
+	go d.pollLoop(ctx)
+	return nil
+}
+
+// pollLoop periodically reloads the blocklist from Redis.
+func (d *DynamicFilter) pollLoop(ctx context.Context) {
+	ticker := time.NewTicker(blocklistPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Reload(ctx); err != nil {
+				log.Printf("[moderation] blocklist poll failed: %v", err)
+			}
+		}
+	}
+}
+
+// Lists is the runtime state of the blocklist: the changes applied on top of
+// the default blocklist and its per-term options, the same per language,
+// and the allowlist.
+type Lists struct {
+	Added     []string                   `json:"added"`
+	Removed   []string                   `json:"removed"`
+	Options   map[string]TermOptions     `json:"options"`
+	Languages map[string]LanguageChanges `json:"languages"`
+	Allowed   []string                   `json:"allowed"`
+}
+
+// LanguageChanges are the runtime changes to the blocklist of one language.
+type LanguageChanges struct {
+	Added   []string               `json:"added,omitempty"`
+	Removed []string               `json:"removed,omitempty"`
+	Options map[string]TermOptions `json:"options,omitempty"`
+}
+
+// Reload reads the runtime changes from Redis and atomically replaces the
+// active Filter.
+func (d *DynamicFilter) Reload(ctx context.Context) error {
+	lists, err := d.Lists(ctx)
+	if err != nil {
+		return err
+	}
+
+	terms := effectiveTerms(defaultBlocklist, lists.Added, lists.Removed, lists.Options)
+	languages := make(map[string]languageList, len(d.languages)+len(lists.Languages))
+	for lang, base := range d.languages {
+		ch := lists.Languages[lang]
+		languages[lang] = languageList{effectiveTerms(base, ch.Added, ch.Removed, ch.Options), ch.Options}
+	}
+	for lang, ch := range lists.Languages {
+		if _, ok := languages[lang]; !ok {
+			languages[lang] = languageList{effectiveTerms(nil, ch.Added, ch.Removed, ch.Options), ch.Options}
+		}
+	}
+	d.current.Store(newFilter(terms, lists.Options, languages, lists.Allowed))
+	return nil
+}
+
+// effectiveTerms returns base plus added minus removed. Case-sensitive
+// terms are stored as written, so they are kept out of mergeTerms, which
+// lowercases.
+func effectiveTerms(base, added, removed []string, options map[string]TermOptions) []string {
+	var plain, cased []string
+	for _, t := range added {
+		if options[t].CaseSensitive {
+			cased = append(cased, t)
+		} else {
+			plain = append(plain, t)
+		}
+	}
+	return append(mergeTerms(base, plain, removed), cased...)
+}
+
+// Lists returns the runtime blocklist state, with the term lists sorted.
+// Unreadable options are logged and skipped.
+func (d *DynamicFilter) Lists(ctx context.Context) (Lists, error) {
+	langs, err := d.rdb.SMembers(ctx, BlocklistLanguagesKey).Result()
+	if err != nil {
+		return Lists{}, fmt.Errorf("moderation: load blocklist languages: %w", err)
+	}
+
+	// The default blocklist is read as language "".
+	type listCmds struct {
+		added, removed *redis.StringSliceCmd
+		options        *redis.MapStringStringCmd
+	}
+	pipe := d.rdb.Pipeline()
+	cmds := make(map[string]listCmds, len(langs)+1)
+	for _, lang := range append([]string{""}, langs...) {
+		cmds[lang] = listCmds{
+			added:   pipe.SMembers(ctx, addedKey(lang)),
+			removed: pipe.SMembers(ctx, removedKey(lang)),
+			options: pipe.HGetAll(ctx, optionsKey(lang)),
+		}
+	}
+	allowedCmd := pipe.SMembers(ctx, AllowlistKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Lists{}, fmt.Errorf("moderation: load blocklist: %w", err)
+	}
+
+	lists := Lists{
+		Languages: make(map[string]LanguageChanges, len(langs)),
+		Allowed:   allowedCmd.Val(),
+	}
+	for lang, c := range cmds {
+		ch := LanguageChanges{
+			Added:   c.added.Val(),
+			Removed: c.removed.Val(),
+			Options: make(map[string]TermOptions, len(c.options.Val())),
+		}
+		for term, data := range c.options.Val() {
+			var opts TermOptions
+			if err := json.Unmarshal([]byte(data), &opts); err != nil {
+				log.Printf("[moderation] skipping unreadable options for %q: %v", term, err)
+				continue
+			}
+			ch.Options[term] = opts
+		}
+		sort.Strings(ch.Added)
+		sort.Strings(ch.Removed)
+		switch {
+		case lang == "":
+			lists.Added, lists.Removed, lists.Options = ch.Added, ch.Removed, ch.Options
+		case len(ch.Added) > 0 || len(ch.Removed) > 0 || len(ch.Options) > 0:
+			lists.Languages[lang] = ch
+		}
+	}
+	sort.Strings(lists.Allowed)
+	return lists, nil
+}
+
+// AddTerm adds a term to the blocklist of every instance, matched according
+// to opts. If the term was previously removed it is re-enabled. A
+// case-sensitive term is stored as written; others are lowercased.
+func (d *DynamicFilter) AddTerm(ctx context.Context, term string, opts TermOptions) error {
+	return d.AddLanguageTerm(ctx, "", term, opts)
+}
+
+// AddLanguageTerm is AddTerm for the blocklist of one language, which only
+// applies to messages in that language. An empty lang adds to the default
+// blocklist.
+func (d *DynamicFilter) AddLanguageTerm(ctx context.Context, lang, term string, opts TermOptions) error {
+	lang = normalizeLanguage(lang)
+	if opts.CaseSensitive {
+		term = strings.TrimSpace(term)
+	} else {
+		term = normalizeTerm(term)
+	}
+	if term == "" {
+		return fmt.Errorf("moderation: empty term")
+	}
+
+	pipe := d.rdb.TxPipeline()
+	pipe.SAdd(ctx, addedKey(lang), term)
+	if lang != "" {
+		pipe.SAdd(ctx, BlocklistLanguagesKey, lang)
+	}
+	pipe.SRem(ctx, removedKey(lang), normalizeTerm(term))
+	if opts == (TermOptions{}) {
+		pipe.HDel(ctx, optionsKey(lang), term)
+	} else {
+		data, err := json.Marshal(opts)
+		if err != nil {
+			return fmt.Errorf("moderation: marshal term options: %w", err)
+		}
+		pipe.HSet(ctx, optionsKey(lang), term, data)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("moderation: add term: %w", err)
+	}
+	return d.announce(ctx)
+}
+
+// RemoveTerm removes a term and its options from the blocklist of every
+// instance. Default terms are recorded in the removed set so they stay
+// disabled after a restart.
+func (d *DynamicFilter) RemoveTerm(ctx context.Context, term string) error {
+	return d.RemoveLanguageTerm(ctx, "", term)
+}
+
+// RemoveLanguageTerm is RemoveTerm for the blocklist of one language; the
+// other lists are unaffected. An empty lang removes from the default
+// blocklist.
+func (d *DynamicFilter) RemoveLanguageTerm(ctx context.Context, lang, term string) error {
+	lang = normalizeLanguage(lang)
+	written, term := strings.TrimSpace(term), normalizeTerm(term)
+	if term == "" {
+		return fmt.Errorf("moderation: empty term")
+	}
+
+	pipe := d.rdb.TxPipeline()
+	pipe.SRem(ctx, addedKey(lang), term, written)
+	pipe.HDel(ctx, optionsKey(lang), term, written)
+	if d.isBaseTerm(lang, term) {
+		pipe.SAdd(ctx, removedKey(lang), term)
+		if lang != "" {
+			pipe.SAdd(ctx, BlocklistLanguagesKey, lang)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("moderation: remove term: %w", err)
+	}
+	return d.announce(ctx)
+}
+
+// AllowTerm adds a word or phrase to the allowlist of every instance.
+func (d *DynamicFilter) AllowTerm(ctx context.Context, term string) error {
+	term = normalizeTerm(term)
+	if term == "" {
+		return fmt.Errorf("moderation: empty term")
+	}
+	if err := d.rdb.SAdd(ctx, AllowlistKey, term).Err(); err != nil {
+		return fmt.Errorf("moderation: allow term: %w", err)
+	}
+	return d.announce(ctx)
+}
+
+// DisallowTerm removes a word or phrase from the allowlist of every
+// instance.
+func (d *DynamicFilter) DisallowTerm(ctx context.Context, term string) error {
+	term = normalizeTerm(term)
+	if term == "" {
+		return fmt.Errorf("moderation: empty term")
+	}
+	if err := d.rdb.SRem(ctx, AllowlistKey, term).Err(); err != nil {
+		return fmt.Errorf("moderation: disallow term: %w", err)
+	}
+	return d.announce(ctx)
+}
+
+// announce reloads the local filter and notifies the other instances.
+func (d *DynamicFilter) announce(ctx context.Context) error {
+	if err := d.Reload(ctx); err != nil {
+		return err
+	}
+	if err := d.bus.PublishBlocklistUpdated(); err != nil {
+		return fmt.Errorf("moderation: publish blocklist update: %w", err)
+	}
+	return nil
+}
+
+// Check runs the text through the currently active Filter.
+func (d *DynamicFilter) Check(text string) FilterResult {
+	return d.current.Load().Check(text)
+}
+
+// CheckLanguage runs the text through the currently active Filter with the
+// blocklist of the declared language lang.
+func (d *DynamicFilter) CheckLanguage(text, lang string) FilterResult {
+	return d.current.Load().CheckLanguage(text, lang)
+}
+
+// CheckInterests filters interest tags through the currently active Filter.
+func (d *DynamicFilter) CheckInterests(interests []string) []string {
+	return d.current.Load().CheckInterests(interests)
+}
+
+// mergeTerms returns defaults plus added minus removed. Terms are compared
+// after normalization and duplicates are dropped.
+func mergeTerms(defaults, added, removed []string) []string {
+	skip := make(map[string]bool, len(removed))
+	for _, t := range removed {
+		skip[normalizeTerm(t)] = true
+	}
+
+	seen := make(map[string]bool, len(defaults)+len(added))
+	terms := make([]string, 0, len(defaults)+len(added))
+	for _, list := range [][]string{defaults, added} {
+		for _, t := range list {
+			t = normalizeTerm(t)
+			if t == "" || skip[t] || seen[t] {
+				continue
+			}
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// isDefaultTerm reports whether term is part of the compiled-in blocklist.
+func isDefaultTerm(term string) bool {
+	return containsTerm(defaultBlocklist, term)
+}
+
+// isBaseTerm reports whether term is part of the compiled-in or file-loaded
+// blocklist of lang, or of the default blocklist when lang is empty.
+func (d *DynamicFilter) isBaseTerm(lang, term string) bool {
+	if lang == "" {
+		return isDefaultTerm(term)
+	}
+	return containsTerm(d.languages[lang], term)
+}
+
+// containsTerm reports whether the normalized term is in list.
+func containsTerm(list []string, term string) bool {
+	for _, t := range list {
+		if normalizeTerm(t) == term {
+			return true
+		}
+	}
+	return false
+}
+
+// addedKey returns the Redis set of terms added at runtime to the blocklist
+// of lang, or to the default blocklist when lang is empty.
+func addedKey(lang string) string {
+	return languageKey(BlocklistAddedKey, lang)
+}
+
+// removedKey returns the Redis set of base terms disabled at runtime in the
+// blocklist of lang, or in the default blocklist when lang is empty.
+func removedKey(lang string) string {
+	return languageKey(BlocklistRemovedKey, lang)
+}
+
+// optionsKey returns the Redis hash of term options for the blocklist of
+// lang, or for the default blocklist when lang is empty.
+func optionsKey(lang string) string {
+	return languageKey(BlocklistOptionsKey, lang)
+}
+
+func languageKey(key, lang string) string {
+	if lang == "" {
+		return key
+	}
+	return key + ":" + lang
+}
+
+// normalizeTerm lowercases and trims a blocklist term, matching the
+// normalization applied by NewFilterWithTerms.
+func normalizeTerm(term string) string {
+	return strings.ToLower(strings.TrimSpace(term))
+}
@@ -0,0 +1,164 @@
+package moderation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestMergeTerms(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults []string
+		added    []string
+		removed  []string
+		want     []string
+	}{
+		{"defaults only", []string{"a", "b"}, nil, nil, []string{"a", "b"}},
+		{"added appended", []string{"a"}, []string{"c"}, nil, []string{"a", "c"}},
+		{"removed default", []string{"a", "b"}, nil, []string{"a"}, []string{"b"}},
+		{"removed wins over added", []string{"a"}, []string{"c"}, []string{"c"}, []string{"a"}},
+		{"duplicates dropped", []string{"a"}, []string{"A", " a "}, nil, []string{"a"}},
+		{"empty terms dropped", []string{"a", " "}, []string{""}, nil, []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeTerms(tt.defaults, tt.added, tt.removed)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeTerms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeTerms_FilterUsesMergedList(t *testing.T) {
+	f := NewFilterWithTerms(mergeTerms([]string{"badword"}, []string{"newterm"}, []string{"badword"}))
+
+	if f.Check("badword").Blocked {
+		t.Error("removed default term should not be blocked")
+	}
+	if !f.Check("this is a newterm").Blocked {
+		t.Error("added term should be blocked")
+	}
+}
+
+func TestIsDefaultTerm(t *testing.T) {
+	if !isDefaultTerm("kys") {
+		t.Error("expected kys to be a default term")
+	}
+	if isDefaultTerm("definitely-not-blocked") {
+		t.Error("unexpected default term")
+	}
+}
+
+func TestDynamicFilter_ImplementsChecker(t *testing.T) {
+	var _ Checker = (*Filter)(nil)
+	var _ Checker = (*DynamicFilter)(nil)
+}
+
+func newTestDynamicFilter(t *testing.T) *DynamicFilter {
+	t.Helper()
+	rdb := testutil.Redis(t)
+	bus := messaging.NewRedisBus(rdb, messaging.DefaultRedisBusConfig())
+	t.Cleanup(bus.Close)
+	d := NewDynamicFilter(rdb, bus)
+	d.SetLanguageLists(map[string][]string{"es": {"palabrota", "kys"}})
+	return d
+}
+
+func TestDynamicFilter_AddLanguageTerm(t *testing.T) {
+	d := newTestDynamicFilter(t)
+	ctx := context.Background()
+
+	if err := d.AddTerm(ctx, "Mist", TermOptions{CaseSensitive: true}); err != nil {
+		t.Fatalf("AddTerm: %v", err)
+	}
+	if err := d.AddLanguageTerm(ctx, "ES", "Mist", TermOptions{}); err != nil {
+		t.Fatalf("AddLanguageTerm: %v", err)
+	}
+	if err := d.AddLanguageTerm(ctx, "es", "  ", TermOptions{}); err == nil {
+		t.Error("expected an error for an empty term")
+	}
+
+	if !d.CheckLanguage("der mist", "es").Blocked {
+		t.Error("a term added to es without options should match in any case")
+	}
+	if d.Check("der mist").Blocked {
+		t.Error("the es term's options should not change the default list's case-sensitive term")
+	}
+	if !d.Check("so ein Mist").Blocked {
+		t.Error("the case-sensitive default term should match as written")
+	}
+
+	lists, err := d.Lists(ctx)
+	if err != nil {
+		t.Fatalf("Lists: %v", err)
+	}
+	if got := lists.Languages["es"].Added; !reflect.DeepEqual(got, []string{"mist"}) {
+		t.Errorf("es added = %v, want [mist]", got)
+	}
+	if got := lists.Options["Mist"]; !got.CaseSensitive {
+		t.Errorf("default options = %+v, want case-sensitive Mist", lists.Options)
+	}
+}
+
+func TestDynamicFilter_RemoveLanguageTerm(t *testing.T) {
+	d := newTestDynamicFilter(t)
+	ctx := context.Background()
+
+	if err := d.AddTerm(ctx, "shared phrase", TermOptions{WordBoundary: true}); err != nil {
+		t.Fatalf("AddTerm: %v", err)
+	}
+	if err := d.AddLanguageTerm(ctx, "es", "shared phrase", TermOptions{WordBoundary: true}); err != nil {
+		t.Fatalf("AddLanguageTerm: %v", err)
+	}
+
+	// kys is in both the default list and the es list; removing it from es
+	// must leave the default list alone, and the same for options.
+	if err := d.RemoveLanguageTerm(ctx, "es", "KYS"); err != nil {
+		t.Fatalf("RemoveLanguageTerm kys: %v", err)
+	}
+	if err := d.RemoveLanguageTerm(ctx, "es", "shared phrase"); err != nil {
+		t.Fatalf("RemoveLanguageTerm phrase: %v", err)
+	}
+
+	lists, err := d.Lists(ctx)
+	if err != nil {
+		t.Fatalf("Lists: %v", err)
+	}
+	if len(lists.Removed) != 0 {
+		t.Errorf("default removed = %v, want none", lists.Removed)
+	}
+	if got := lists.Languages["es"].Removed; !reflect.DeepEqual(got, []string{"kys"}) {
+		t.Errorf("es removed = %v, want [kys]", got)
+	}
+	if !lists.Options["shared phrase"].WordBoundary {
+		t.Error("removing the es term deleted the default list's options")
+	}
+	if !d.Check("kys").Blocked {
+		t.Error("kys should stay blocked by the default list")
+	}
+	if !d.CheckLanguage("kys", "es").Blocked {
+		t.Error("the default list still applies to es messages")
+	}
+	if d.CheckLanguage("una palabrota", "pt").Blocked {
+		t.Error("the es list should not apply to pt messages")
+	}
+
+	if err := d.RemoveLanguageTerm(ctx, "es", "palabrota"); err != nil {
+		t.Fatalf("RemoveLanguageTerm palabrota: %v", err)
+	}
+	if d.CheckLanguage("una palabrota", "es").Blocked {
+		t.Error("a removed es base term should not be blocked")
+	}
+	if err := d.AddLanguageTerm(ctx, "es", "palabrota", TermOptions{}); err != nil {
+		t.Fatalf("AddLanguageTerm: %v", err)
+	}
+	if !d.CheckLanguage("una palabrota", "es").Blocked {
+		t.Error("re-adding a removed es term should block it again")
+	}
+}
@@ -0,0 +1,125 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Enforcement actions for a message the content filter blocked, by how many
+// of the session's messages were blocked within the offense window.
+const (
+	EnforceWarn = "warn" // the sender is told which category was blocked
+	EnforceMute = "mute" // the session may not send messages for a while
+	EnforceBan  = "ban"  // persistent offender; the caller bans the fingerprint
+)
+
+const (
+	keyOffensesPrefix = "filter:offenses:"
+	keyMutedPrefix    = "filter:muted:"
+)
+
+// EnforcementConfig holds the progressive enforcement thresholds.
+type EnforcementConfig struct {
+	// Window is how long blocked messages count against a session. It
+	// starts at the first block and does not slide, like ban.ReportsTTL.
+	Window time.Duration
+
+	// MuteAfter is the number of blocks within Window from which each block
+	// mutes the session for MuteDuration. Earlier blocks only warn.
+	MuteAfter    int
+	MuteDuration time.Duration
+
+	// BanAfter is the number of blocks within Window from which the
+	// session's fingerprint is banned.
+	BanAfter int
+}
+
+// DefaultEnforcementConfig returns the built-in thresholds: a warning, then
+// a one minute mute per block, and a ban on the fifth block in ten minutes.
+func DefaultEnforcementConfig() EnforcementConfig {
+	return EnforcementConfig{
+		Window:       10 * time.Minute,
+		MuteAfter:    2,
+		MuteDuration: time.Minute,
+		BanAfter:     5,
+	}
+}
+
+// Enforcement is the outcome of a blocked message.
+type Enforcement struct {
+	Action   string        // EnforceWarn, EnforceMute or EnforceBan
+	Offenses int64         // blocks within the window, this one included
+	MuteFor  time.Duration // how long the session is muted; zero for a warning
+}
+
+// Enforcer escalates the response to a session's blocked messages from a
+// warning to a temporary mute to a ban. The counters live in Redis, so the
+// mute holds on every wsserver:
+//
+//	Key:   filter:offenses:<session_id>   (block counter, TTL Window)
+//	Key:   filter:muted:<session_id>      (present while muted, TTL MuteDuration)
+type Enforcer struct {
+	rdb    *redis.Client
+	config EnforcementConfig
+}
+
+// NewEnforcer creates an Enforcer with the given thresholds.
+func NewEnforcer(rdb *redis.Client, config EnforcementConfig) *Enforcer {
+	return &Enforcer{rdb: rdb, config: config}
+}
+
+// Offend counts a blocked message of sessionID and returns the action to
+// take. From MuteAfter blocks on the session is muted, also when the action
+// is EnforceBan, so it stays muted if the ban cannot be applied.
+func (e *Enforcer) Offend(ctx context.Context, sessionID string) (Enforcement, error) {
+	count, err := offendScript.Run(ctx, e.rdb,
+		[]string{keyOffensesPrefix + sessionID, keyMutedPrefix + sessionID},
+		e.config.Window.Milliseconds(), e.config.MuteAfter, e.config.MuteDuration.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return Enforcement{}, fmt.Errorf("moderation: offend: %w", err)
+	}
+
+	en := Enforcement{Action: EnforceWarn, Offenses: count}
+	if count >= int64(e.config.MuteAfter) {
+		en.Action = EnforceMute
+		en.MuteFor = e.config.MuteDuration
+	}
+	if count >= int64(e.config.BanAfter) {
+		en.Action = EnforceBan
+	}
+	return en, nil
+}
+
+// Muted returns how much longer sessionID is muted, or zero if it is not.
+func (e *Enforcer) Muted(ctx context.Context, sessionID string) (time.Duration, error) {
+	ttl, err := e.rdb.PTTL(ctx, keyMutedPrefix+sessionID).Result()
+	if err != nil {
+		return 0, fmt.Errorf("moderation: muted: %w", err)
+	}
+	// PTTL answers negative durations for missing keys and keys without a
+	// TTL; mute keys always have one.
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+var offendScript = redis.NewScript(offendLua)
+
+// offendLua increments the offense counter KEYS[1], giving it a TTL of
+// ARGV[1] milliseconds when new, and from ARGV[2] offenses on sets the mute
+// key KEYS[2] for ARGV[3] milliseconds. It returns the count.
+const offendLua = `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+    redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if count >= tonumber(ARGV[2]) then
+    redis.call('SET', KEYS[2], '1', 'PX', ARGV[3])
+end
+return count
+`
@@ -0,0 +1,88 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestEnforcer_Escalates(t *testing.T) {
+	rdb := testutil.Redis(t)
+	config := DefaultEnforcementConfig()
+	e := NewEnforcer(rdb, config)
+	ctx := context.Background()
+
+	want := []string{EnforceWarn, EnforceMute, EnforceMute, EnforceMute, EnforceBan, EnforceBan}
+	for i, action := range want {
+		en, err := e.Offend(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if en.Action != action || en.Offenses != int64(i+1) {
+			t.Errorf("block %d = %+v, want %s", i+1, en, action)
+		}
+		if en.Action == EnforceWarn && en.MuteFor != 0 {
+			t.Errorf("a warning mutes for %v", en.MuteFor)
+		}
+		if en.Action != EnforceWarn && en.MuteFor != config.MuteDuration {
+			t.Errorf("block %d mutes for %v, want %v", i+1, en.MuteFor, config.MuteDuration)
+		}
+	}
+
+	// The window starts at the first block and does not slide.
+	if ttl := rdb.PTTL(ctx, keyOffensesPrefix+"alice").Val(); ttl <= 0 || ttl > config.Window {
+		t.Errorf("offense counter TTL = %v, want up to %v", ttl, config.Window)
+	}
+
+	// Other sessions are counted separately.
+	if en, _ := e.Offend(ctx, "bob"); en.Action != EnforceWarn {
+		t.Errorf("bob's first block = %+v, want a warning", en)
+	}
+}
+
+func TestEnforcer_Muted(t *testing.T) {
+	rdb := testutil.Redis(t)
+	e := NewEnforcer(rdb, EnforcementConfig{
+		Window:       time.Minute,
+		MuteAfter:    2,
+		MuteDuration: 200 * time.Millisecond,
+		BanAfter:     3,
+	})
+	ctx := context.Background()
+
+	muted := func() time.Duration {
+		t.Helper()
+		d, err := e.Muted(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	e.Offend(ctx, "alice")
+	if d := muted(); d != 0 {
+		t.Errorf("muted for %v after a warning", d)
+	}
+	e.Offend(ctx, "alice")
+	if d := muted(); d <= 0 || d > 200*time.Millisecond {
+		t.Errorf("muted for %v after the second block, want up to 200ms", d)
+	}
+	if d, _ := e.Muted(ctx, "bob"); d != 0 {
+		t.Errorf("bob muted for %v", d)
+	}
+
+	rdb.Del(ctx, keyMutedPrefix+"alice") // as when the mute expires
+	if d := muted(); d != 0 {
+		t.Errorf("still muted for %v after the mute expired", d)
+	}
+
+	// A ban also mutes, in case the ban cannot be applied.
+	if en, _ := e.Offend(ctx, "alice"); en.Action != EnforceBan {
+		t.Errorf("third block = %+v, want a ban", en)
+	}
+	if d := muted(); d <= 0 {
+		t.Error("not muted alongside the ban")
+	}
+}
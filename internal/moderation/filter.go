@@ -15,52 +15,181 @@ type FilterResult struct {
 	Term    string // the specific term/pattern that matched
 }
 
+// TermCategory groups the term that flagged a message for metric labels
+// without exporting the term itself: "word" or "phrase" for blocklist
+// keywords, and the check name ("url", "phone", ...) for spam patterns.
+func (r FilterResult) TermCategory() string {
+	switch {
+	case r.Reason == "spam_pattern":
+		return r.Term
+	case strings.ContainsRune(r.Term, ' '):
+		return "phrase"
+	default:
+		return "word"
+	}
+}
+
+// TermOptions adjust how a single blocked term is matched.
+type TermOptions struct {
+	// WordBoundary makes a phrase match only whole words, so "gas the" no
+	// longer matches "gas there". Single words always match whole words.
+	WordBoundary bool `json:"word_boundary,omitempty"`
+
+	// CaseSensitive matches the term only as written, e.g. an acronym that
+	// is an ordinary word in lowercase.
+	CaseSensitive bool `json:"case_sensitive,omitempty"`
+}
+
 // Filter performs in-memory content filtering against a blocklist of terms.
 // It is safe for concurrent use by multiple goroutines — all state is
 // read-only after construction.
 type Filter struct {
+	// termSet holds the case-insensitive terms, matched against the
+	// lowercased message.
+	termSet
+
+	// cased holds the case-sensitive terms, matched against the message as
+	// written.
+	cased termSet
+
+	// languages holds the terms that only apply to messages in one
+	// language, keyed by language code.
+	languages map[string]*languageTerms
+
+	// allow and allowLeet hold the allowlisted words and phrases as token
+	// sequences, for the plain and leetspeak passes. They are removed from
+	// a message before its tokens are checked.
+	allow     [][]string
+	allowLeet [][]string
+}
+
+// termSet is a group of blocked terms checked against the same tokens.
+type termSet struct {
 	// words contains single-word blocked terms for O(1) lookup.
 	words map[string]struct{}
 
 	// phrases contains multi-word blocked terms checked via substring match
 	// against the token-joined message.
-	phrases []string
+	phrases []phrase
 }
 
-// NewFilter creates a Filter loaded with the default blocklist. All terms are
-// normalized to lowercase. Single-word terms are stored in a hash set for fast
-// lookup; multi-word terms are stored in a slice for substring matching.
+// languageTerms are the case-insensitive and case-sensitive terms of one
+// language's blocklist.
+type languageTerms struct {
+	plain termSet
+	cased termSet
+}
+
+// phrase is a multi-word blocked term. Unless bounded, it may start or end
+// inside a word of the message.
+type phrase struct {
+	text    string
+	bounded bool
+}
+
+// NewFilter creates a Filter loaded with the default blocklist and the
+// default per-language blocklists. All terms are normalized the same way as
+// message text (see normalizeText). Single-word terms are stored in a hash
+// set for fast lookup; multi-word terms are stored in a slice for substring
+// matching.
 func NewFilter() *Filter {
-	return NewFilterWithTerms(defaultBlocklist)
+	return NewFilterWithLanguages(defaultBlocklist, defaultLanguageBlocklists, nil, nil)
 }
 
 // NewFilterWithTerms creates a Filter from the provided term list. This is
 // useful for testing or for loading a custom blocklist.
 func NewFilterWithTerms(terms []string) *Filter {
-	f := &Filter{
-		words: make(map[string]struct{}, len(terms)),
+	return NewFilterWithOptions(terms, nil, nil)
+}
+
+// NewFilterWithOptions creates a Filter from the provided term list,
+// matching each term according to its entry in options, if any. Words and
+// phrases in allow are never blocked; they are compared case-insensitively.
+func NewFilterWithOptions(terms []string, options map[string]TermOptions, allow []string) *Filter {
+	return NewFilterWithLanguages(terms, nil, options, allow)
+}
+
+// NewFilterWithLanguages is NewFilterWithOptions with additional blocklists
+// keyed by language code, which CheckLanguage applies only to messages in
+// that language. The options and allowlist apply to every list.
+func NewFilterWithLanguages(terms []string, languages map[string][]string, options map[string]TermOptions, allow []string) *Filter {
+	lists := make(map[string]languageList, len(languages))
+	for lang, list := range languages {
+		lists[lang] = languageList{terms: list, options: options}
+	}
+	return newFilter(terms, options, lists, allow)
+}
+
+// languageList is the blocklist of one language with its own term options.
+type languageList struct {
+	terms   []string
+	options map[string]TermOptions
+}
+
+// newFilter is NewFilterWithLanguages with options per language list.
+func newFilter(terms []string, options map[string]TermOptions, languages map[string]languageList, allow []string) *Filter {
+	f := &Filter{}
+	f.termSet, f.cased = newTermSets(terms, options)
+
+	for lang, list := range languages {
+		lang = normalizeLanguage(lang)
+		if lang == "" {
+			continue
+		}
+		plain, cased := newTermSets(list.terms, list.options)
+		if f.languages == nil {
+			f.languages = make(map[string]*languageTerms, len(languages))
+		}
+		f.languages[lang] = &languageTerms{plain: plain, cased: cased}
 	}
 
+	for _, a := range allow {
+		tokens := tokenizePlain(normalizeText(a))
+		if len(tokens) == 0 {
+			continue
+		}
+		leet := make([]string, len(tokens))
+		for i, t := range tokens {
+			leet[i] = normalizeLeet(t)
+		}
+		f.allow = append(f.allow, tokens)
+		f.allowLeet = append(f.allowLeet, leet)
+	}
+
+	return f
+}
+
+// newTermSets sorts terms into the case-insensitive and case-sensitive term
+// sets, according to their options.
+func newTermSets(terms []string, options map[string]TermOptions) (plain, cased termSet) {
+	plain = termSet{words: make(map[string]struct{}, len(terms))}
+	cased = termSet{words: make(map[string]struct{})}
+
 	for _, term := range terms {
-		normalized := strings.ToLower(strings.TrimSpace(term))
+		opts := options[term]
+		set, normalized := &plain, normalizeText(strings.TrimSpace(term))
+		if opts.CaseSensitive {
+			set, normalized = &cased, normalizeCased(strings.TrimSpace(term))
+		}
 		if normalized == "" {
 			continue
 		}
 		if strings.ContainsRune(normalized, ' ') {
-			f.phrases = append(f.phrases, normalized)
+			set.phrases = append(set.phrases, phrase{text: normalized, bounded: opts.WordBoundary})
 		} else {
-			f.words[normalized] = struct{}{}
+			set.words[normalized] = struct{}{}
 		}
 	}
-
-	return f
+	return plain, cased
 }
 
 // Check examines the provided text for prohibited content. It returns a
 // FilterResult indicating whether the message should be blocked. The check
-// is case-insensitive and applies basic leetspeak normalization.
+// is case-insensitive and applies Unicode and basic leetspeak normalization.
 //
-// The check runs two passes:
+// The text is first normalized (see normalizeText) so that homoglyphs,
+// accents and zero-width characters do not hide a blocked term. The token
+// check then runs two passes:
 //  1. Plain pass: tokenize on word boundaries (letters/digits), check each
 //     token against the blocklist. This correctly handles "badword!" by
 //     stripping trailing punctuation.
@@ -69,24 +198,38 @@ func NewFilterWithTerms(terms []string) *Filter {
 //     like "b@dw0rd".
 //
 // For multi-word phrases, the space-joined token sequence is checked via
-// substring matching in both passes.
+// substring matching in both passes. Allowlisted words and phrases are cut
+// out of the token sequence first, and no phrase matches across the cut.
+// Case-sensitive terms get the same two passes over the text with its case
+// kept.
+//
+// Check applies the language blocklists of the scripts the text is written
+// in; see CheckLanguage.
 func (f *Filter) Check(text string) FilterResult {
-	lower := strings.ToLower(text)
+	return f.CheckLanguage(text, "")
+}
 
-	// --- Pass 1: plain word matching ---
-	plainTokens := tokenizePlain(lower)
-	if result := f.checkTokens(plainTokens); result.Blocked {
+// CheckLanguage is Check with the blocklist of the declared language lang,
+// e.g. the sender's chat language, applied on top of the default blocklist.
+// The lists of languages identified by the message's script (Cyrillic,
+// Arabic, Hangul, ...) apply whatever the declared language; Latin-script
+// languages are only checked when declared. An empty lang declares none.
+func (f *Filter) CheckLanguage(text, lang string) FilterResult {
+	// --- Passes 1 and 2: plain and leetspeak-aware matching ---
+	plain, cased := normalizeText(text), ""
+	if result := f.checkSets(&f.termSet, &f.cased, plain, &cased, text); result.Blocked {
 		return result
 	}
-
-	// --- Pass 2: leetspeak-aware matching ---
-	leetTokens := tokenizeLeet(lower)
-	normalized := make([]string, len(leetTokens))
-	for i, t := range leetTokens {
-		normalized[i] = normalizeLeet(t)
-	}
-	if result := f.checkTokens(normalized); result.Blocked {
-		return result
+	if len(f.languages) > 0 {
+		for _, l := range messageLanguages(lang, text) {
+			terms, ok := f.languages[l]
+			if !ok {
+				continue
+			}
+			if result := f.checkSets(&terms.plain, &terms.cased, plain, &cased, text); result.Blocked {
+				return result
+			}
+		}
 	}
 
 	// --- Pass 3: regex-based spam pattern detection ---
@@ -97,27 +240,67 @@ func (f *Filter) Check(text string) FilterResult {
 	return FilterResult{Blocked: false}
 }
 
-// checkTokens checks a token slice against the word set and phrase list.
-func (f *Filter) checkTokens(tokens []string) FilterResult {
+// checkSets runs the token passes of a case-insensitive and a case-sensitive
+// term set over text. The case-kept normalization is computed into *cased
+// the first time a case-sensitive set needs it.
+func (f *Filter) checkSets(plainSet, casedSet *termSet, plain string, cased *string, text string) FilterResult {
+	if result := f.checkText(plainSet, plain); result.Blocked {
+		return result
+	}
+	if len(casedSet.words) == 0 && len(casedSet.phrases) == 0 {
+		return FilterResult{Blocked: false}
+	}
+	if *cased == "" {
+		*cased = normalizeCased(text)
+	}
+	return f.checkText(casedSet, *cased)
+}
+
+// checkText runs the plain and leetspeak passes of terms over normalized
+// text.
+func (f *Filter) checkText(terms *termSet, text string) FilterResult {
+	if result := terms.check(cutAllowed(tokenizePlain(text), f.allow)); result.Blocked {
+		return result
+	}
+
+	leetTokens := tokenizeLeet(text)
+	normalized := make([]string, len(leetTokens))
+	for i, t := range leetTokens {
+		normalized[i] = normalizeLeet(t)
+	}
+	return terms.check(cutAllowed(normalized, f.allowLeet))
+}
+
+// check checks token segments against the word set and phrase list.
+func (s *termSet) check(segments [][]string) FilterResult {
 	// Check individual words.
-	for _, w := range tokens {
-		if _, blocked := f.words[w]; blocked {
-			return FilterResult{
-				Blocked: true,
-				Reason:  "blocked_keyword",
-				Term:    w,
+	for _, tokens := range segments {
+		for _, w := range tokens {
+			if _, blocked := s.words[w]; blocked {
+				return FilterResult{
+					Blocked: true,
+					Reason:  "blocked_keyword",
+					Term:    w,
+				}
 			}
 		}
 	}
 
 	// Check multi-word phrases.
-	joined := strings.Join(tokens, " ")
-	for _, phrase := range f.phrases {
-		if strings.Contains(joined, phrase) {
-			return FilterResult{
-				Blocked: true,
-				Reason:  "blocked_keyword",
-				Term:    phrase,
+	if len(s.phrases) == 0 {
+		return FilterResult{Blocked: false}
+	}
+	for _, tokens := range segments {
+		joined := strings.Join(tokens, " ")
+		bounded := " " + joined + " "
+		for _, p := range s.phrases {
+			if (p.bounded && strings.Contains(bounded, " "+p.text+" ")) ||
+				(!p.bounded && strings.Contains(joined, p.text)) {
+				return FilterResult{
+					Blocked: true,
+					Reason:  "blocked_keyword",
+					Term:    p.text,
+				}
 			}
 		}
 	}
@@ -125,6 +308,55 @@ func (f *Filter) checkTokens(tokens []string) FilterResult {
 	return FilterResult{Blocked: false}
 }
 
+// cutAllowed removes the allowlisted token sequences from tokens and returns
+// the segments left between them. Tokens are compared case-insensitively.
+func cutAllowed(tokens []string, allow [][]string) [][]string {
+	if len(allow) == 0 {
+		return [][]string{tokens}
+	}
+
+	var segments [][]string
+	start := 0
+	for i := 0; i < len(tokens); {
+		n := allowedAt(tokens[i:], allow)
+		if n == 0 {
+			i++
+			continue
+		}
+		if i > start {
+			segments = append(segments, tokens[start:i])
+		}
+		i += n
+		start = i
+	}
+	if start < len(tokens) {
+		segments = append(segments, tokens[start:])
+	}
+	return segments
+}
+
+// allowedAt returns the length of the longest allowlisted sequence tokens
+// start with, or 0.
+func allowedAt(tokens []string, allow [][]string) int {
+	longest := 0
+	for _, a := range allow {
+		if len(a) <= longest || len(a) > len(tokens) {
+			continue
+		}
+		match := true
+		for j, t := range a {
+			if !strings.EqualFold(tokens[j], t) {
+				match = false
+				break
+			}
+		}
+		if match {
+			longest = len(a)
+		}
+	}
+	return longest
+}
+
 // CheckInterests filters a slice of interest tags and returns a clean list
 // with any blocked terms removed. This prevents offensive custom tags from
 // being used in matching.
@@ -108,6 +108,161 @@ func TestCheck_Leetspeak(t *testing.T) {
 	}
 }
 
+func TestCheck_Homoglyphs(t *testing.T) {
+	f := NewFilterWithTerms([]string{"badword", "very bad"})
+
+	tests := []struct {
+		name    string
+		input   string
+		blocked bool
+	}{
+		{"cyrillic a and o", "b\u0430dw\u043erd", true},
+		{"greek omicron", "badw\u03bfrd", true},
+		{"uppercase cyrillic", "B\u0410DWORD", true},
+		{"zero-width space", "bad\u200bword", true},
+		{"zero-width joiner", "b\u200dadword", true},
+		{"soft hyphen", "bad\u00adword", true},
+		{"diacritics", "b\u00e1dw\u00f6rd", true},
+		{"combining marks", "ba\u0301dwo\u0308rd", true},
+		{"fullwidth", "\uff42\uff41\uff44\uff57\uff4f\uff52\uff44", true},
+		{"mathematical bold", "\U0001d41b\U0001d41a\U0001d41d\U0001d430\U0001d428\U0001d42b\U0001d41d", true},
+		{"homoglyphs with leet", "b\u0430dw0rd", true},
+		{"phrase with zero-width", "very\u200b bad", true},
+		{"plain russian", "\u043f\u0440\u0438\u0432\u0435\u0442", false},
+		{"accented clean", "caf\u00e9 na\u00efve", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := f.Check(tt.input)
+			if result.Blocked != tt.blocked {
+				t.Errorf("Check(%q).Blocked = %v, want %v", tt.input, result.Blocked, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestNewFilterWithTerms_NormalizesTerms(t *testing.T) {
+	f := NewFilterWithTerms([]string{"B\u00c1DWORD"})
+	if !f.Check("badword").Blocked {
+		t.Error("expected an accented uppercase term to match its plain form")
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Hello World", "hello world"},
+		{"", ""},
+		{"\u0441\u0430t", "cat"},
+		{"n\u00e4\u00efve", "naive"},
+		{"a\u200b\u200c\u200d\u2060\ufeffb", "ab"},
+		{"\uff28\uff29", "hi"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeText(tt.input); got != tt.want {
+			t.Errorf("normalizeText(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCased(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Hello World", "Hello World"},
+		{"\u0421\u0430T", "CaT"},
+		{"N\u00c4\u00efve", "NAive"},
+		{"A\u200bB", "AB"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeCased(tt.input); got != tt.want {
+			t.Errorf("normalizeCased(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCheck_Allowlist(t *testing.T) {
+	f := NewFilterWithOptions([]string{"badword", "bad phrase"}, nil, []string{"badword cafe", "phrase"})
+
+	tests := []struct {
+		name    string
+		input   string
+		blocked bool
+	}{
+		{"allowlisted phrase", "meet me at the Badword Cafe", false},
+		{"term outside allowlisted phrase", "badword at the badword cafe", true},
+		{"term without rest of phrase", "badword", true},
+		{"leetspeak allowlisted phrase", "the b@dw0rd cafe", false},
+		{"allowlisted word splits phrase", "bad phrase", false},
+		{"phrase does not join across cut", "bad phrase example", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Check(tt.input).Blocked; got != tt.blocked {
+				t.Errorf("Check(%q).Blocked = %v, want %v", tt.input, got, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestCheck_WordBoundaryPhrase(t *testing.T) {
+	f := NewFilterWithOptions([]string{"gas the", "hate you"},
+		map[string]TermOptions{"gas the": {WordBoundary: true}}, nil)
+
+	tests := []struct {
+		name    string
+		input   string
+		blocked bool
+	}{
+		{"bounded whole words", "gas the room", true},
+		{"bounded inside word", "gas there", false},
+		{"bounded prefix", "vegas the city", false},
+		{"unbounded inside word", "i hate your hat", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Check(tt.input).Blocked; got != tt.blocked {
+				t.Errorf("Check(%q).Blocked = %v, want %v", tt.input, got, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestCheck_CaseSensitiveTerm(t *testing.T) {
+	f := NewFilterWithOptions([]string{"KYS", "Bad Phrase", "badword"},
+		map[string]TermOptions{"KYS": {CaseSensitive: true}, "Bad Phrase": {CaseSensitive: true}}, nil)
+
+	tests := []struct {
+		name    string
+		input   string
+		blocked bool
+		term    string
+	}{
+		{"exact case", "just KYS", true, "KYS"},
+		{"other case", "kys means keys", false, ""},
+		{"phrase exact case", "a Bad Phrase here", true, "Bad Phrase"},
+		{"phrase other case", "a bad phrase here", false, ""},
+		{"insensitive term unaffected", "BADWORD", true, "badword"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := f.Check(tt.input)
+			if result.Blocked != tt.blocked || result.Term != tt.term {
+				t.Errorf("Check(%q) = %+v, want blocked=%v term=%q", tt.input, result, tt.blocked, tt.term)
+			}
+		})
+	}
+}
+
 func TestCheck_CleanMessages(t *testing.T) {
 	f := NewFilter()
 
@@ -315,28 +470,46 @@ func BenchmarkCheck_LongMessage(b *testing.B) {
 	}
 }
 
-// TestPerformance verifies the filter meets the < 0.1ms latency requirement.
-func TestPerformance(t *testing.T) {
+// BenchmarkCheck_Unicode measures performance on non-ASCII messages, which
+// take the full normalization path.
+func BenchmarkCheck_Unicode(b *testing.B) {
 	f := NewFilter()
-	msg := "hey how are you doing today? I love chatting about music and movies. What are your favorite hobbies?"
+	msg := "h\u0435y h\u043ew \u0430re y\u043eu d\u043eing t\u043ed\u0430y? I l\u043ev\u0435 caf\u00e9s \u0430nd m\u00fcsic\u200b. Wh\u0430t \u0430re y\u043eur f\u0430v\u043erite h\u043ebbies?"
 
-	const iterations = 1000
-	start := time.Now()
-	for i := 0; i < iterations; i++ {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
 		f.Check(msg)
 	}
-	elapsed := time.Since(start)
-	avgNs := elapsed.Nanoseconds() / int64(iterations)
-	avgUs := float64(avgNs) / 1000.0
-
-	t.Logf("average Check latency: %.2f µs (%.4f ms)", avgUs, avgUs/1000.0)
+}
 
-	// 0.1ms = 100µs = 100,000ns (relaxed to 1ms under race detector).
-	maxNs := int64(100_000)
-	if raceDetectorEnabled {
-		maxNs = 1_000_000 // race detector adds ~10-50x overhead
+// TestPerformance verifies the filter meets the < 0.1ms latency requirement.
+// Non-ASCII messages are held to the same budget as ASCII ones.
+func TestPerformance(t *testing.T) {
+	f := NewFilter()
+	messages := map[string]string{
+		"ascii":   "hey how are you doing today? I love chatting about music and movies. What are your favorite hobbies?",
+		"unicode": "h\u0435y h\u043ew \u0430re y\u043eu d\u043eing t\u043ed\u0430y? I l\u043ev\u0435 caf\u00e9s \u0430nd m\u00fcsic\u200b. Wh\u0430t \u0430re y\u043eur f\u0430v\u043erite h\u043ebbies?",
 	}
-	if avgNs > maxNs {
-		t.Errorf("Check latency %.2f µs exceeds %d µs limit", avgUs, maxNs/1000)
+
+	for name, msg := range messages {
+		const iterations = 1000
+		start := time.Now()
+		for i := 0; i < iterations; i++ {
+			f.Check(msg)
+		}
+		elapsed := time.Since(start)
+		avgNs := elapsed.Nanoseconds() / int64(iterations)
+		avgUs := float64(avgNs) / 1000.0
+
+		t.Logf("%s: average Check latency: %.2f µs (%.4f ms)", name, avgUs, avgUs/1000.0)
+
+		// 0.1ms = 100µs = 100,000ns (relaxed to 1ms under race detector).
+		maxNs := int64(100_000)
+		if raceDetectorEnabled {
+			maxNs = 1_000_000 // race detector adds ~10-50x overhead
+		}
+		if avgNs > maxNs {
+			t.Errorf("%s: Check latency %.2f µs exceeds %d µs limit", name, avgUs, maxNs/1000)
+		}
 	}
 }
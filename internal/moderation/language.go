@@ -0,0 +1,200 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// defaultLanguageBlocklists holds the compiled-in blocked terms that only
+// apply to messages in one language, keyed by ISO 639-1 code. The English
+// defaultBlocklist applies to every message; these lists are added on top
+// for the chat's declared language and for the scripts a message is written
+// in (see messageLanguages).
+//
+// Like defaultBlocklist these are starter lists, expected to be extended per
+// deployment with BLOCKLIST_DIR files or the admin API.
+var defaultLanguageBlocklists = map[string][]string{
+	"es": {
+		"maricón", "maricones", "sudaca", "sudacas",
+		"mátate", "suicídate", "te voy a matar", "sé dónde vives",
+		"pornografía infantil", "manda fotos desnuda", "pásame tu dirección",
+	},
+	"pt": {
+		"viado", "viados",
+		"se mata", "vou te matar", "sei onde você mora",
+		"pornografia infantil", "manda nudes", "me passa seu endereço",
+	},
+	"fr": {
+		"pédé", "pédés", "bougnoule", "bougnoules", "youpin", "youpins",
+		"suicide-toi", "va te pendre", "je vais te tuer", "je sais où tu habites",
+		"pédopornographie", "envoie des nudes", "donne ton adresse",
+	},
+	"de": {
+		"kanake", "kanaken", "schwuchtel", "schwuchteln",
+		"bring dich um", "häng dich auf", "ich bring dich um", "ich weiß wo du wohnst",
+		"kinderpornografie", "schick nacktbilder", "sieg heil",
+	},
+	"ru": {
+		"хохол", "хохлы", "жид", "жиды", "пидор", "пидоры",
+		"убей себя", "повесься", "я тебя убью", "я знаю где ты живешь",
+		"детское порно", "скинь нюдсы",
+	},
+}
+
+// scriptLanguages maps the Unicode scripts that identify a language on
+// their own to that language. Latin is not listed: a Latin-script message
+// can be in any of dozens of languages, so its lists are only selected by
+// the chat's declared language. Han is treated as Chinese unless the message
+// also contains kana.
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+}
+
+// detectLanguages returns the languages suggested by the scripts the letters
+// of text are written in, in scriptLanguages order without duplicates.
+func detectLanguages(text string) []string {
+	found := make([]bool, len(scriptLanguages))
+	hit := false
+	for _, r := range text {
+		if r < 0x0370 || !unicode.IsLetter(r) {
+			continue // ASCII and Latin-1: nothing to detect
+		}
+		for i, s := range scriptLanguages {
+			if unicode.Is(s.table, r) {
+				found[i], hit = true, true
+				break
+			}
+		}
+	}
+	if !hit {
+		return nil
+	}
+
+	var langs []string
+	seen := make(map[string]bool, 2)
+	kana := false
+	for i, s := range scriptLanguages {
+		if !found[i] {
+			continue
+		}
+		if s.lang == "ja" {
+			kana = true
+		}
+		if s.table == unicode.Han && kana {
+			continue // kanji in a Japanese message
+		}
+		if !seen[s.lang] {
+			seen[s.lang] = true
+			langs = append(langs, s.lang)
+		}
+	}
+	return langs
+}
+
+// normalizeLanguage reduces a language tag to its lowercase primary subtag,
+// so "pt-BR" and "pt_br" both select the "pt" lists.
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// messageLanguages returns the languages whose lists apply to a message: the
+// declared language, if any, followed by those detected from its scripts.
+func messageLanguages(declared, text string) []string {
+	langs := detectLanguages(text)
+	declared = normalizeLanguage(declared)
+	if declared == "" {
+		return langs
+	}
+	for _, l := range langs {
+		if l == declared {
+			return langs
+		}
+	}
+	return append([]string{declared}, langs...)
+}
+
+// LoadBlocklistDir reads per-language blocklists from dir. Each file is named
+// after its language ("es.txt", "pt-BR.txt" selects "pt") and lists one term
+// per line; blank lines and lines starting with "#" are ignored. Files
+// without a .txt extension are skipped. Lists for the same language are
+// concatenated.
+func LoadBlocklistDir(dir string) (map[string][]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("moderation: read blocklist dir: %w", err)
+	}
+
+	lists := make(map[string][]string)
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || filepath.Ext(name) != ".txt" {
+			continue
+		}
+		lang := normalizeLanguage(strings.TrimSuffix(name, ".txt"))
+		if lang == "" {
+			continue
+		}
+		terms, err := readTermFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		lists[lang] = append(lists[lang], terms...)
+	}
+	return lists, nil
+}
+
+// readTermFile returns the terms listed in a blocklist file.
+func readTermFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("moderation: open blocklist: %w", err)
+	}
+	defer f.Close()
+
+	var terms []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		terms = append(terms, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("moderation: read blocklist %s: %w", filepath.Base(path), err)
+	}
+	return terms, nil
+}
+
+// mergeLanguageLists returns base with the lists of extra appended per
+// language. Neither argument is modified.
+func mergeLanguageLists(base, extra map[string][]string) map[string][]string {
+	merged := make(map[string][]string, len(base)+len(extra))
+	for lang, terms := range base {
+		merged[lang] = append([]string(nil), terms...)
+	}
+	for lang, terms := range extra {
+		merged[lang] = append(merged[lang], terms...)
+	}
+	return merged
+}
@@ -0,0 +1,149 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectLanguages(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"hello there", nil},
+		{"¿qué tal, señor?", nil},
+		{"привет", []string{"ru"}},
+		{"hi привет", []string{"ru"}},
+		{"안녕하세요", []string{"ko"}},
+		{"你好", []string{"zh"}},
+		{"こんにちは世界", []string{"ja"}},
+		{"مرحبا", []string{"ar"}},
+		{"γεια σου", []string{"el"}},
+		{"привет 你好", []string{"ru", "zh"}},
+	}
+	for _, tt := range tests {
+		if got := detectLanguages(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("detectLanguages(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMessageLanguages(t *testing.T) {
+	tests := []struct {
+		declared, text string
+		want           []string
+	}{
+		{"", "hola", nil},
+		{"es", "hola", []string{"es"}},
+		{"pt-BR", "oi", []string{"pt"}},
+		{"ru", "привет", []string{"ru"}},
+		{"en", "привет", []string{"en", "ru"}},
+	}
+	for _, tt := range tests {
+		if got := messageLanguages(tt.declared, tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("messageLanguages(%q, %q) = %v, want %v", tt.declared, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCheckLanguage(t *testing.T) {
+	f := NewFilterWithLanguages([]string{"badword"}, map[string][]string{
+		"es": {"palabrota", "te voy a matar"},
+		"ru": {"плохое"},
+	}, nil, nil)
+
+	tests := []struct {
+		name, text, lang string
+		blocked          bool
+	}{
+		{"default list in any language", "badword", "es", true},
+		{"declared language list", "eres una palabrota", "es", true},
+		{"declared phrase with accents", "Té voy a matar", "es", true},
+		{"region subtag", "palabrota", "es-MX", true},
+		{"other language not applied", "palabrota", "pt", false},
+		{"undeclared latin language not applied", "palabrota", "", false},
+		{"script selects list", "это плохое слово", "", true},
+		{"script selects list despite declared language", "это плохое слово", "en", true},
+		{"clean", "hola amigo", "es", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.CheckLanguage(tt.text, tt.lang); got.Blocked != tt.blocked {
+				t.Errorf("CheckLanguage(%q, %q) = %+v, want blocked=%v", tt.text, tt.lang, got, tt.blocked)
+			}
+		})
+	}
+
+	if !f.Check("это плохое слово").Blocked {
+		t.Error("Check should apply the lists of detected scripts")
+	}
+}
+
+func TestCheckLanguage_OptionsAndAllowlist(t *testing.T) {
+	f := NewFilterWithLanguages(nil, map[string][]string{"de": {"Mist", "du bist"}},
+		map[string]TermOptions{"Mist": {CaseSensitive: true}, "du bist": {WordBoundary: true}},
+		[]string{"du bistro"})
+
+	if !f.CheckLanguage("so ein Mist", "de").Blocked {
+		t.Error("case-sensitive language term as written should be blocked")
+	}
+	if f.CheckLanguage("der mist", "de").Blocked {
+		t.Error("case-sensitive language term in lowercase should not be blocked")
+	}
+	if f.CheckLanguage("du bistro", "de").Blocked {
+		t.Error("allowlisted phrase should not be blocked")
+	}
+}
+
+func TestNewFilter_DefaultLanguageLists(t *testing.T) {
+	f := NewFilter()
+	if !f.CheckLanguage("te voy a matar", "es").Blocked {
+		t.Error("expected the Spanish starter list to apply")
+	}
+	if !f.Check("я тебя убью").Blocked {
+		t.Error("expected the Russian starter list to apply to Cyrillic text")
+	}
+}
+
+func TestLoadBlocklistDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"es.txt":    "# Spanish\npalabrota\n\n  otra palabra  \n",
+		"pt-BR.txt": "palavrão\n",
+		"pt.txt":    "outra\n",
+		"README.md": "not a list\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lists, err := LoadBlocklistDir(dir)
+	if err != nil {
+		t.Fatalf("LoadBlocklistDir: %v", err)
+	}
+	if want := []string{"palabrota", "otra palabra"}; !reflect.DeepEqual(lists["es"], want) {
+		t.Errorf("es = %v, want %v", lists["es"], want)
+	}
+	if len(lists["pt"]) != 2 {
+		t.Errorf("pt = %v, want both pt files", lists["pt"])
+	}
+	if len(lists) != 2 {
+		t.Errorf("got languages %v, want es and pt", lists)
+	}
+
+	if _, err := LoadBlocklistDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestEffectiveTerms(t *testing.T) {
+	got := effectiveTerms([]string{"old", "base"}, []string{"New", "ABC"}, []string{"old"},
+		map[string]TermOptions{"ABC": {CaseSensitive: true}})
+	if want := []string{"base", "new", "ABC"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("effectiveTerms = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps lowercase non-Latin letters that render like a Latin
+// letter to that letter, so "ѕех" (Cyrillic) is checked as "sex". It is
+// deliberately small: only letters that are near-identical in common fonts,
+// since a looser mapping would start blocking ordinary Greek and Cyrillic.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j',
+	'к': 'k', 'о': 'o', 'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'у': 'y', 'ԝ': 'w',
+	'х': 'x', 'ӏ': 'l',
+	// Greek
+	'α': 'a', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'υ': 'u', 'χ': 'x',
+	// Latin variants that NFKD leaves alone
+	'ı': 'i', 'ɑ': 'a', 'ɡ': 'g', 'ɩ': 'i', 'ɪ': 'i', 'ʀ': 'r', 'ᴏ': 'o',
+}
+
+// normalizeText lowercases text and undoes Unicode tricks used to slip
+// blocked terms past the tokenizers:
+//   - NFKD decomposition folds compatibility forms (fullwidth "ｂａｄ",
+//     mathematical "𝐛𝐚𝐝", ligatures) to plain letters and splits accented
+//     letters into base letter plus combining mark;
+//   - combining marks are dropped, so "bádwörd" reads as "badword";
+//   - format characters (zero-width space and joiners, soft hyphen, BOM)
+//     are dropped, so they no longer split a word into harmless tokens;
+//   - confusable Cyrillic and Greek letters map to their Latin lookalikes.
+//
+// ASCII input, the common case, is only lowercased.
+func normalizeText(text string) string {
+	return normalize(text, true)
+}
+
+// normalizeCased is normalizeText without the lowercasing, for terms that
+// are matched case-sensitively. Confusables keep the case they were
+// written in.
+func normalizeCased(text string) string {
+	return normalize(text, false)
+}
+
+func normalize(text string, lower bool) string {
+	if isASCII(text) {
+		if lower {
+			return strings.ToLower(text)
+		}
+		return text
+	}
+
+	decomposed := norm.NFKD.String(text)
+	var b strings.Builder
+	b.Grow(len(decomposed))
+	for _, r := range decomposed {
+		if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		upper := !lower && unicode.IsUpper(r)
+		r = unicode.ToLower(r)
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isASCII reports whether s contains only ASCII bytes.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package moderation
+
+// ReasonStrictPolicy is the FilterResult reason of a StrictFilter block.
+const ReasonStrictPolicy = "strict_policy"
+
+// strictBlocklist holds the profanity and crude language that the default
+// blocklist allows but chats under the strict content policy block.
+var strictBlocklist = []string{
+	// --- Profanity ---
+	"fuck",
+	"fucks",
+	"fucked",
+	"fucking",
+	"fucker",
+	"motherfucker",
+	"wtf",
+	"stfu",
+	"gtfo",
+	"shit",
+	"shits",
+	"shitty",
+	"bullshit",
+	"bitch",
+	"bitches",
+	"son of a bitch",
+	"asshole",
+	"assholes",
+	"bastard",
+	"bastards",
+	"dickhead",
+	"cunt",
+	"cunts",
+	"twat",
+	"wanker",
+	"prick",
+	"piss off",
+	"pissed off",
+
+	// --- Crude sexual language ---
+	"dick",
+	"cock",
+	"pussy",
+	"tits",
+	"boobs",
+	"horny",
+	"blowjob",
+	"handjob",
+	"dildo",
+	"porn",
+	"slut",
+	"sluts",
+	"whore",
+	"whores",
+	"sexting",
+}
+
+// StrictFilter checks messages against the strict blocklist, on top of the
+// default filter, in chats under the strict content policy.
+type StrictFilter struct {
+	filter *Filter
+}
+
+// NewStrictFilter creates a StrictFilter loaded with the strict blocklist.
+func NewStrictFilter() *StrictFilter {
+	return &StrictFilter{filter: NewFilterWithTerms(strictBlocklist)}
+}
+
+// Check examines text for strict blocklist terms with the same
+// normalization and leetspeak passes as Filter.Check. Spam patterns and
+// language blocklists are left to the default filter. A block has reason
+// ReasonStrictPolicy.
+func (s *StrictFilter) Check(text string) FilterResult {
+	cased := ""
+	result := s.filter.checkSets(&s.filter.termSet, &s.filter.cased, normalizeText(text), &cased, text)
+	if result.Blocked {
+		result.Reason = ReasonStrictPolicy
+	}
+	return result
+}
@@ -0,0 +1,26 @@
+package moderation
+
+import "testing"
+
+func TestStrictFilter(t *testing.T) {
+	f := NewStrictFilter()
+	blocked := []string{"what the FUCK", "this is sh1t", "son of a b1tch", "you are a d!ck"}
+	for _, text := range blocked {
+		if r := f.Check(text); !r.Blocked || r.Reason != ReasonStrictPolicy {
+			t.Errorf("Check(%q) = %+v, want a strict_policy block", text, r)
+		}
+	}
+	allowed := []string{"hello there", "my cockatoo says hi", "scunthorpe is a town", "check out www.example.com"}
+	for _, text := range allowed {
+		if r := f.Check(text); r.Blocked {
+			t.Errorf("Check(%q) blocked: %+v", text, r)
+		}
+	}
+}
+
+func TestStrictFilter_DefaultFilterAllows(t *testing.T) {
+	// The strict terms are exactly what standard chats let through.
+	if r := NewFilter().Check("what the fuck"); r.Blocked {
+		t.Errorf("default filter blocked profanity: %+v", r)
+	}
+}
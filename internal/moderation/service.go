@@ -0,0 +1,176 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/internal/session"
+)
+
+// Service is the asynchronous moderation worker (MOD-2). It consumes
+// moderation.check requests published by the WS servers, runs each message
+// through the content filter and publishes flagged results back on
+// moderation.result.<session_id>.
+type Service struct {
+	bus    messaging.Bus
+	filter Checker
+	audit  *audit.Logger
+	rdb    *redis.Client // resolves session fingerprints for audit events
+	dryRun atomic.Bool   // flag without enforcing; see SetDryRun
+	stop   chan struct{}
+}
+
+// backlogSampleInterval is how often the subscription backlog is copied into
+// the pending and dropped check gauges.
+const backlogSampleInterval = 5 * time.Second
+
+// NewService creates a moderation service that checks messages with filter,
+// which may be a static Filter or a DynamicFilter.
+func NewService(bus messaging.Bus, filter Checker) *Service {
+	return &Service{
+		bus:    bus,
+		filter: filter,
+		stop:   make(chan struct{}),
+	}
+}
+
+// EnableAudit records every flagged message in the audit log. The sender's
+// fingerprint is looked up from its session in rdb. It must be called before
+// Start.
+func (s *Service) EnableAudit(logger *audit.Logger, rdb *redis.Client) {
+	s.audit = logger
+	s.rdb = rdb
+}
+
+// SetDryRun switches dry-run mode, in which flagged messages are logged,
+// counted and published with enforce=false so the WS server leaves them
+// delivered, and nothing is written to the audit log. It is safe to call
+// while the service is running.
+func (s *Service) SetDryRun(dryRun bool) {
+	s.dryRun.Store(dryRun)
+}
+
+// Start subscribes to moderation check requests. Messages are handled on the
+// bus subscription goroutine; Start returns once the subscription is active.
+func (s *Service) Start() error {
+	if err := s.bus.SubscribeModerationCheck(s.handleCheck); err != nil {
+		return err
+	}
+	go s.sampleBacklog()
+	log.Println("[moderator] service started")
+	return nil
+}
+
+// Stop ends backlog sampling. The subscription is closed with the bus.
+func (s *Service) Stop() {
+	close(s.stop)
+}
+
+// sampleBacklog exports the check subscription's backlog until Stop, so a
+// moderator that falls behind shows up before the bus starts dropping checks.
+func (s *Service) sampleBacklog() {
+	ticker := time.NewTicker(backlogSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			pending, dropped := s.bus.ModerationCheckBacklog()
+			metrics.ModerationPendingChecks.Set(float64(pending))
+			metrics.ModerationDroppedChecks.Set(float64(dropped))
+		}
+	}
+}
+
+// handleCheck runs a single moderation request through the filter and
+// publishes a ModerationResult when the message is flagged.
+func (s *Service) handleCheck(data []byte) {
+	var req ModerationRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		log.Printf("[moderator] failed to unmarshal request: %v", err)
+		return
+	}
+
+	start := time.Now()
+	if req.Ts > 0 {
+		metrics.ModerationRequestAge.Observe(max(start.Sub(time.Unix(req.Ts, 0)).Seconds(), 0))
+	}
+	result := s.filter.CheckLanguage(req.Text, req.Lang)
+	metrics.ModerationCheckDuration.Observe(time.Since(start).Seconds())
+
+	if !result.Blocked {
+		metrics.ModerationChecksTotal.WithLabelValues("clean").Inc()
+		log.Printf("[moderator] CLEAN session=%s chat=%s",
+			req.SessionID, req.ChatID)
+		return
+	}
+	metrics.ModerationChecksTotal.WithLabelValues("flagged").Inc()
+	metrics.ModerationFlaggedTotal.WithLabelValues(result.Reason, result.TermCategory()).Inc()
+
+	resp := ModerationResult{
+		SessionID: req.SessionID,
+		ChatID:    req.ChatID,
+		MessageID: req.MessageID,
+		Blocked:   true,
+		Reason:    result.Reason,
+		Term:      result.Term,
+	}
+	if s.dryRun.Load() {
+		metrics.ModerationDryRunTotal.WithLabelValues("async", result.Reason).Inc()
+		log.Printf("[moderator] FLAGGED (dry run) session=%s chat=%s reason=%s term=%q",
+			req.SessionID, req.ChatID, result.Reason, result.Term)
+		enforce := false
+		resp.Enforce = &enforce
+	} else {
+		log.Printf("[moderator] FLAGGED session=%s chat=%s reason=%s term=%q",
+			req.SessionID, req.ChatID, result.Reason, result.Term)
+		s.recordBlocked(req, result)
+	}
+
+	respData, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("[moderator] failed to marshal result: %v", err)
+		return
+	}
+	if err := s.bus.PublishModerationResult(req.SessionID, respData); err != nil {
+		log.Printf("[moderator] failed to publish result: %v", err)
+	}
+}
+
+// recordBlocked writes a message_blocked audit event for a flagged request.
+// It is a no-op unless EnableAudit was called.
+func (s *Service) recordBlocked(req ModerationRequest, result FilterResult) {
+	if s.audit == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fp, err := s.rdb.HGet(ctx, session.SessionPrefix+req.SessionID, "fingerprint").Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("[moderator] audit fingerprint lookup session=%s: %v", req.SessionID, err)
+	}
+	event := &audit.Event{
+		Action:            audit.ActionMessageBlocked,
+		Actor:             audit.ActorModerator,
+		TargetFingerprint: fp,
+		Reason:            result.Reason,
+		Context: map[string]interface{}{
+			"session_id": req.SessionID,
+			"chat_id":    req.ChatID,
+			"message_id": req.MessageID,
+			"term":       result.Term,
+		},
+	}
+	if err := s.audit.Record(ctx, event); err != nil {
+		log.Printf("[audit] failed to record message_blocked session=%s: %v", req.SessionID, err)
+	}
+}
@@ -0,0 +1,75 @@
+package moderation
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/messaging"
+)
+
+func TestTermCategory(t *testing.T) {
+	f := NewFilterWithTerms([]string{"badword", "bad phrase"})
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"you badword", "word"},
+		{"what a bad phrase", "phrase"},
+		{"visit https://example.com now", "url"},
+		{"aaaaaaaaaaaaaaaaaaaa", "char_flood"},
+	}
+	for _, tt := range tests {
+		result := f.Check(tt.text)
+		if !result.Blocked {
+			t.Fatalf("Check(%q) not blocked", tt.text)
+		}
+		if got := result.TermCategory(); got != tt.want {
+			t.Errorf("Check(%q).TermCategory() = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// resultBus records moderation results; every other Bus method is unused.
+type resultBus struct {
+	messaging.Bus
+	results []ModerationResult
+}
+
+func (b *resultBus) PublishModerationResult(sessionID string, data []byte) error {
+	var r ModerationResult
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+	b.results = append(b.results, r)
+	return nil
+}
+
+func TestHandleCheck_DryRunPublishesUnenforced(t *testing.T) {
+	bus := &resultBus{}
+	svc := NewService(bus, NewFilterWithTerms([]string{"badword"}))
+	req, _ := json.Marshal(ModerationRequest{SessionID: "s1", ChatID: "c1", MessageID: "m1", Text: "you badword"})
+
+	svc.handleCheck(req)
+	svc.SetDryRun(true)
+	svc.handleCheck(req)
+
+	if len(bus.results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(bus.results))
+	}
+	if !bus.results[0].Enforced() {
+		t.Error("expected the first result to be enforced")
+	}
+	if got := bus.results[1]; !got.Blocked || got.Enforced() {
+		t.Errorf("expected a blocked, unenforced dry-run result, got %+v", got)
+	}
+}
+
+func TestModerationResult_EnforcedByDefault(t *testing.T) {
+	var r ModerationResult
+	if err := json.Unmarshal([]byte(`{"session_id":"s1","blocked":true}`), &r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Enforced() {
+		t.Error("expected a result without enforce to be enforced")
+	}
+}
@@ -1,19 +1,32 @@
 package moderation
 
 // ModerationRequest is published to moderation.check by the WS server
-// when a message needs async content review.
+// when a message needs async content review. Lang is the sender's declared
+// chat language, which selects the language blocklist to apply.
 type ModerationRequest struct {
 	SessionID string `json:"session_id"`
 	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id,omitempty"`
 	Text      string `json:"text"`
+	Lang      string `json:"lang,omitempty"`
 	Ts        int64  `json:"ts"`
 }
 
 // ModerationResult is published back to the WS server with the review outcome.
+// Enforce is false when the moderator runs in dry-run mode: the WS server
+// then only logs the flag and leaves the message delivered. Results from
+// moderators that predate dry-run mode omit it and are enforced.
 type ModerationResult struct {
 	SessionID string `json:"session_id"`
 	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id,omitempty"`
 	Blocked   bool   `json:"blocked"`
 	Reason    string `json:"reason"`
 	Term      string `json:"term"`
+	Enforce   *bool  `json:"enforce,omitempty"`
+}
+
+// Enforced reports whether the WS server should act on a blocked result.
+func (r ModerationResult) Enforced() bool {
+	return r.Enforce == nil || *r.Enforce
 }
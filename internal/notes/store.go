@@ -0,0 +1,151 @@
+// Package notes provides PostgreSQL-backed storage for private moderator
+// notes and tags attached to a fingerprint. Notes are never shown to users;
+// they exist so moderators on different shifts reach consistent decisions.
+package notes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Limits on moderator input, enforced before insertion.
+const (
+	MaxNoteLength = 2000
+	MaxTags       = 10
+	MaxTagLength  = 50
+)
+
+// ErrNotFound is returned by Delete when no note has the given ID.
+var ErrNotFound = errors.New("notes: not found")
+
+// Store manages moderator notes in PostgreSQL.
+type Store struct {
+	db *sql.DB
+}
+
+// Note is a single moderator note about a fingerprint.
+type Note struct {
+	ID          int64     `json:"id"`
+	Fingerprint string    `json:"fingerprint"`
+	Author      string    `json:"author"`
+	Text        string    `json:"note"`
+	Tags        []string  `json:"tags"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewStore creates a new notes store backed by the given database handle.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Create validates and inserts a note, filling in its ID and CreatedAt.
+// Tags are lowercased, trimmed and de-duplicated. A note must carry either
+// text or at least one tag.
+func (s *Store) Create(ctx context.Context, note *Note) error {
+	note.Text = strings.TrimSpace(note.Text)
+	note.Tags = NormalizeTags(note.Tags)
+
+	if note.Fingerprint == "" {
+		return fmt.Errorf("notes: fingerprint is required")
+	}
+	if note.Author == "" {
+		return fmt.Errorf("notes: author is required")
+	}
+	if note.Text == "" && len(note.Tags) == 0 {
+		return fmt.Errorf("notes: note or tags required")
+	}
+	if len(note.Text) > MaxNoteLength {
+		return fmt.Errorf("notes: note exceeds %d characters", MaxNoteLength)
+	}
+	if len(note.Tags) > MaxTags {
+		return fmt.Errorf("notes: at most %d tags allowed", MaxTags)
+	}
+	for _, tag := range note.Tags {
+		if len(tag) > MaxTagLength {
+			return fmt.Errorf("notes: tag %q exceeds %d characters", tag, MaxTagLength)
+		}
+	}
+
+	const query = `
+		INSERT INTO fingerprint_notes (fingerprint, author, note, tags)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := s.db.QueryRowContext(ctx, query,
+		note.Fingerprint,
+		note.Author,
+		note.Text,
+		pq.Array(note.Tags),
+	).Scan(&note.ID, &note.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("notes: insert: %w", err)
+	}
+	return nil
+}
+
+// ListByFingerprint returns every note attached to a fingerprint, newest
+// first.
+func (s *Store) ListByFingerprint(ctx context.Context, fingerprint string) ([]Note, error) {
+	const query = `
+		SELECT id, fingerprint, author, note, tags, created_at
+		FROM fingerprint_notes
+		WHERE fingerprint = $1
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("notes: list: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []Note{}
+	for rows.Next() {
+		var n Note
+		if err := rows.Scan(&n.ID, &n.Fingerprint, &n.Author, &n.Text, pq.Array(&n.Tags), &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("notes: scan: %w", err)
+		}
+		notes = append(notes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("notes: list: %w", err)
+	}
+	return notes, nil
+}
+
+// Delete removes a note by ID. It returns ErrNotFound if no such note exists.
+func (s *Store) Delete(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM fingerprint_notes WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("notes: delete: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("notes: delete: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// NormalizeTags lowercases and trims tags, dropping empty entries and
+// duplicates while preserving the original order.
+func NormalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
+}
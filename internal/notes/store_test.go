@@ -0,0 +1,14 @@
+package notes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{" Ban-Evader ", "", "verified", "ban-evader", "VERIFIED"})
+	want := []string{"ban-evader", "verified"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTags = %v, want %v", got, want)
+	}
+}
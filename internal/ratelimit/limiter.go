@@ -6,10 +6,14 @@ package ratelimit
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/whisper/chat-app/internal/metrics"
 )
 
 // Rule defines a rate limiting policy: the Redis key prefix, maximum number of
@@ -25,13 +29,147 @@ var (
 	// RuleMessage allows 5 messages per 10 seconds per session.
 	RuleMessage = Rule{Key: "rl:msg:", Limit: 5, Window: 10 * time.Second}
 
+	// RuleMessageBytes allows one maximum-size frame's worth of message text
+	// per 10 seconds per session: 4 KB with the default frame size limit, or
+	// as sized by SetMessageBytesBudget. Its Limit is in bytes; it is checked
+	// together with RuleMessage by AllowCost so a few maximum-size messages
+	// cannot flood a chat.
+	RuleMessageBytes = Rule{Key: "rl:msgbytes:", Limit: 4096, Window: 10 * time.Second}
+
 	// RuleMatch allows 10 match requests per minute per fingerprint/session.
 	RuleMatch = Rule{Key: "rl:match:", Limit: 10, Window: 1 * time.Minute}
 
+	// RuleChatMeta allows 5 chat_meta updates per 30 seconds per session.
+	RuleChatMeta = Rule{Key: "rl:meta:", Limit: 5, Window: 30 * time.Second}
+
+	// RuleReport allows 3 abuse reports per 5 minutes per session. Each report
+	// writes to PostgreSQL and Redis, so it must not be spammable.
+	RuleReport = Rule{Key: "rl:report:", Limit: 3, Window: 5 * time.Minute}
+
+	// RuleFingerprint allows 3 set_fingerprint submissions per minute per
+	// session. Each submission writes the session and performs a ban lookup.
+	RuleFingerprint = Rule{Key: "rl:fp:", Limit: 3, Window: 1 * time.Minute}
+
+	// RuleClientInfo allows 3 client_info submissions per minute per session.
+	RuleClientInfo = Rule{Key: "rl:client:", Limit: 3, Window: 1 * time.Minute}
+
+	// RuleBlock allows 5 personal blocks per 5 minutes per session.
+	RuleBlock = Rule{Key: "rl:block:", Limit: 5, Window: 5 * time.Minute}
+
+	// RuleShareCard allows 3 profile card shares per minute per session.
+	RuleShareCard = Rule{Key: "rl:card:", Limit: 3, Window: 1 * time.Minute}
+
+	// RuleReaction allows 10 reactions per 10 seconds per session in each
+	// chat; the identifier is "<chat_id>:<session_id>".
+	RuleReaction = Rule{Key: "rl:react:", Limit: 10, Window: 10 * time.Second}
+
+	// RuleTranscript allows 3 transcript requests per minute per session.
+	RuleTranscript = Rule{Key: "rl:transcript:", Limit: 3, Window: 1 * time.Minute}
+
+	// RuleReRoll allows 3 priority re-rolls (decline with re_roll) per hour
+	// per fingerprint/session. Further re-rolls re-queue without priority.
+	RuleReRoll = Rule{Key: "rl:reroll:", Limit: 3, Window: 1 * time.Hour}
+
 	// RuleConnect allows 5 WebSocket connections per minute per IP.
 	RuleConnect = Rule{Key: "rl:conn:", Limit: 5, Window: 1 * time.Minute}
 )
 
+// Named maps the configuration name of each standard rule to the rule. It is
+// used to resolve per-rule overrides from the reloadable config file.
+var Named = map[string]Rule{
+	"message":       RuleMessage,
+	"message_bytes": RuleMessageBytes,
+	"match":         RuleMatch,
+	"chat_meta":     RuleChatMeta,
+	"report":        RuleReport,
+	"fingerprint":   RuleFingerprint,
+	"client_info":   RuleClientInfo,
+	"block":         RuleBlock,
+	"share_card":    RuleShareCard,
+	"reaction":      RuleReaction,
+	"transcript":    RuleTranscript,
+	"re_roll":       RuleReRoll,
+	"connect":       RuleConnect,
+}
+
+// SetMessageBytesBudget sizes RuleMessageBytes to the server's maximum frame
+// size in bytes. It must be called at startup, before the limiter is used;
+// zero or less, meaning no frame limit, keeps the default. A "message_bytes"
+// override from the config file still takes precedence.
+func SetMessageBytesBudget(maxFrameSize int64) {
+	if maxFrameSize <= 0 {
+		return
+	}
+	RuleMessageBytes.Limit = int(maxFrameSize)
+	Named["message_bytes"] = RuleMessageBytes
+}
+
+// NameOf returns the configuration name of a standard rule, or "" for rules
+// not in Named. It is reported to clients so they can tell which limit hit.
+func NameOf(rule Rule) string {
+	for name, r := range Named {
+		if r.Key == rule.Key {
+			return name
+		}
+	}
+	return ""
+}
+
+// overrides holds the active limit/window overrides keyed by Rule.Key. It is
+// swapped atomically so a config reload never races with Allow.
+var overrides atomic.Pointer[map[string]Rule]
+
+// SetOverrides atomically replaces the active rule overrides. Keys are rule
+// names from Named; rules without an override keep their defaults. All
+// overrides are validated before any is applied.
+func SetOverrides(byName map[string]Rule) error {
+	next := make(map[string]Rule, len(byName))
+	for name, o := range byName {
+		base, ok := Named[name]
+		if !ok {
+			return fmt.Errorf("ratelimit: unknown rule %q", name)
+		}
+		if o.Limit <= 0 || o.Window <= 0 {
+			return fmt.Errorf("ratelimit: rule %q needs a positive limit and window", name)
+		}
+		next[base.Key] = Rule{Key: base.Key, Limit: o.Limit, Window: o.Window}
+	}
+	overrides.Store(&next)
+	return nil
+}
+
+// Effective returns rule with any active override applied. Callers that
+// report the window to clients (e.g. RetryAfter) should use it so the hint
+// matches the limit actually enforced.
+func Effective(rule Rule) Rule {
+	if m := overrides.Load(); m != nil {
+		if o, ok := (*m)[rule.Key]; ok {
+			return o
+		}
+	}
+	return rule
+}
+
+// Decisions recorded in metrics.RateLimitDecisionsTotal.
+const (
+	decisionAllowed  = "allowed"
+	decisionRejected = "rejected"
+	decisionError    = "error" // Redis failed; the check failed open
+)
+
+// metricName returns the rule label for rule: its name in Named, or "other"
+// for rules that are not standard.
+func metricName(rule Rule) string {
+	if name := NameOf(rule); name != "" {
+		return name
+	}
+	return "other"
+}
+
+func recordDecision(rule, decision string) {
+	metrics.RateLimitDecisionsTotal.WithLabelValues(rule, decision).Inc()
+}
+
 // Limiter performs rate limiting checks against Redis.
 type Limiter struct {
 	client *redis.Client
@@ -49,11 +187,14 @@ func NewLimiter(client *redis.Client) *Limiter {
 // errors the method fails open (returns true) so that a Redis outage does not
 // block legitimate traffic.
 func (l *Limiter) Allow(ctx context.Context, identifier string, rule Rule) (bool, error) {
+	name := metricName(rule)
+	rule = Effective(rule)
 	key := rule.Key + identifier
 
 	count, err := l.client.Incr(ctx, key).Result()
 	if err != nil {
 		log.Printf("[ratelimit] redis INCR error key=%s: %v (failing open)", key, err)
+		recordDecision(name, decisionError)
 		return true, err
 	}
 
@@ -64,21 +205,99 @@ func (l *Limiter) Allow(ctx context.Context, identifier string, rule Rule) (bool
 			// The key exists but has no TTL — it will persist. Best effort: try
 			// to delete it so it doesn't block the identifier forever.
 			l.client.Del(ctx, key)
+			recordDecision(name, decisionError)
 			return true, err
 		}
 	}
 
 	if int(count) > rule.Limit {
+		recordDecision(name, decisionRejected)
 		return false, nil
 	}
 
+	recordDecision(name, decisionAllowed)
 	return true, nil
 }
 
+// Result is the outcome of a combined AllowCost check.
+type Result struct {
+	Allowed    bool
+	Exceeded   Rule          // the rule that rejected the request; zero if allowed
+	RetryAfter time.Duration // until the exceeded rule's window resets
+}
+
+// allowCostLua checks a count limit (KEYS[1]) and a cost budget (KEYS[2])
+// together and only consumes from both when both have room. ARGV holds the
+// count limit and window, the budget limit and window (windows in ms) and
+// the cost. It returns {0, 0} when allowed, or {1 or 2, pttl} naming the
+// exhausted key and its remaining window.
+const allowCostLua = `
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+local spent = tonumber(redis.call('GET', KEYS[2]) or '0')
+local cost = tonumber(ARGV[5])
+if count + 1 > tonumber(ARGV[1]) then
+    return {1, redis.call('PTTL', KEYS[1])}
+end
+if spent + cost > tonumber(ARGV[3]) then
+    return {2, redis.call('PTTL', KEYS[2])}
+end
+if redis.call('INCR', KEYS[1]) == 1 then
+    redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+if redis.call('INCRBY', KEYS[2], cost) == cost then
+    redis.call('PEXPIRE', KEYS[2], ARGV[4])
+end
+return {0, 0}
+`
+
+var allowCostScript = redis.NewScript(allowCostLua)
+
+// AllowCost checks a count rule and a budget rule for the same identifier in
+// one atomic step: the request counts once against count and cost units
+// (e.g. bytes) against budget. Unlike Allow, a rejected request consumes
+// nothing, so a client cannot lock itself out for longer than the window.
+// On Redis errors it fails open.
+func (l *Limiter) AllowCost(ctx context.Context, identifier string, count, budget Rule, cost int) (Result, error) {
+	countName, budgetName := metricName(count), metricName(budget)
+	count, budget = Effective(count), Effective(budget)
+
+	res, err := allowCostScript.Run(ctx, l.client,
+		[]string{count.Key + identifier, budget.Key + identifier},
+		count.Limit, count.Window.Milliseconds(), budget.Limit, budget.Window.Milliseconds(), cost,
+	).Int64Slice()
+	if err != nil {
+		log.Printf("[ratelimit] redis script error id=%s: %v (failing open)", identifier, err)
+		recordDecision(countName, decisionError)
+		recordDecision(budgetName, decisionError)
+		return Result{Allowed: true}, err
+	}
+
+	// A rejection consumes nothing, so only the exceeded rule records it.
+	var exceeded Rule
+	switch res[0] {
+	case 0:
+		recordDecision(countName, decisionAllowed)
+		recordDecision(budgetName, decisionAllowed)
+		return Result{Allowed: true}, nil
+	case 1:
+		exceeded = count
+		recordDecision(countName, decisionRejected)
+	default:
+		exceeded = budget
+		recordDecision(budgetName, decisionRejected)
+	}
+	retry := time.Duration(res[1]) * time.Millisecond
+	if retry <= 0 {
+		retry = exceeded.Window
+	}
+	return Result{Exceeded: exceeded, RetryAfter: retry}, nil
+}
+
 // Remaining returns the number of requests the identifier has left in the
 // current window for the given rule. Returns the full limit if the key does not
 // exist yet. On Redis errors it returns the full limit (fail open).
 func (l *Limiter) Remaining(ctx context.Context, identifier string, rule Rule) (int, error) {
+	rule = Effective(rule)
 	key := rule.Key + identifier
 
 	count, err := l.client.Get(ctx, key).Int()
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestSetOverrides_Effective(t *testing.T) {
+	defer SetOverrides(nil)
+
+	if err := SetOverrides(map[string]Rule{"message": {Limit: 20, Window: time.Minute}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := Effective(RuleMessage)
+	if got.Key != RuleMessage.Key || got.Limit != 20 || got.Window != time.Minute {
+		t.Errorf("unexpected effective rule: %+v", got)
+	}
+	if Effective(RuleMatch) != RuleMatch {
+		t.Error("rules without an override should keep their defaults")
+	}
+
+	if err := SetOverrides(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if Effective(RuleMessage) != RuleMessage {
+		t.Error("clearing overrides should restore defaults")
+	}
+}
+
+func TestSetOverrides_Invalid(t *testing.T) {
+	defer SetOverrides(nil)
+
+	if err := SetOverrides(map[string]Rule{"message": {Limit: 20, Window: time.Minute}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cases := map[string]map[string]Rule{
+		"unknown rule": {"nope": {Limit: 1, Window: time.Second}},
+		"zero limit":   {"match": {Limit: 0, Window: time.Second}},
+		"zero window":  {"match": {Limit: 1}},
+	}
+	for name, in := range cases {
+		if err := SetOverrides(in); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if Effective(RuleMessage).Limit != 20 {
+		t.Error("a rejected update must leave the previous overrides in place")
+	}
+}
+
+func TestSetMessageBytesBudget(t *testing.T) {
+	def := RuleMessageBytes
+	t.Cleanup(func() {
+		RuleMessageBytes = def
+		Named["message_bytes"] = def
+	})
+
+	SetMessageBytesBudget(0)
+	if RuleMessageBytes.Limit != def.Limit {
+		t.Errorf("budget without a frame limit = %d, want the default %d", RuleMessageBytes.Limit, def.Limit)
+	}
+	SetMessageBytesBudget(16 << 10)
+	if RuleMessageBytes.Limit != 16<<10 || Named["message_bytes"].Limit != 16<<10 {
+		t.Errorf("budget = %d (named %d), want the frame size", RuleMessageBytes.Limit, Named["message_bytes"].Limit)
+	}
+	if RuleMessageBytes.Key != def.Key || RuleMessageBytes.Window != def.Window {
+		t.Errorf("SetMessageBytesBudget changed more than the limit: %+v", RuleMessageBytes)
+	}
+}
+
+func TestNameOf(t *testing.T) {
+	if got := NameOf(RuleMessageBytes); got != "message_bytes" {
+		t.Errorf("NameOf(RuleMessageBytes) = %q", got)
+	}
+	// Overrides keep the key, so the name still resolves.
+	if got := NameOf(Rule{Key: RuleMessage.Key, Limit: 99}); got != "message" {
+		t.Errorf("NameOf(overridden message rule) = %q", got)
+	}
+	if got := NameOf(Rule{Key: "rl:nope:"}); got != "" {
+		t.Errorf("NameOf(unknown) = %q, want empty", got)
+	}
+}
+
+func TestAllowCost(t *testing.T) {
+	client := testutil.Redis(t)
+	ctx := context.Background()
+
+	count := Rule{Key: "rl:test:count:", Limit: 3, Window: 10 * time.Second}
+	budget := Rule{Key: "rl:test:bytes:", Limit: 100, Window: 10 * time.Second}
+	l := NewLimiter(client)
+
+	if res, err := l.AllowCost(ctx, "s1", count, budget, 60); err != nil || !res.Allowed {
+		t.Fatalf("first request = %+v, %v; want allowed", res, err)
+	}
+
+	// Over budget: rejected, and nothing is consumed.
+	res, err := l.AllowCost(ctx, "s1", count, budget, 60)
+	if err != nil || res.Allowed || res.Exceeded.Key != budget.Key {
+		t.Fatalf("second request = %+v, %v; want budget exceeded", res, err)
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > budget.Window {
+		t.Errorf("RetryAfter = %s, want within the window", res.RetryAfter)
+	}
+	if n, _ := client.Get(ctx, count.Key+"s1").Int(); n != 1 {
+		t.Errorf("rejected request consumed the count: %d", n)
+	}
+
+	// Small requests fit the budget until the count limit is hit.
+	for i := 0; i < 2; i++ {
+		if res, _ := l.AllowCost(ctx, "s1", count, budget, 10); !res.Allowed {
+			t.Fatalf("small request %d rejected: %+v", i, res)
+		}
+	}
+	res, _ = l.AllowCost(ctx, "s1", count, budget, 1)
+	if res.Allowed || res.Exceeded.Key != count.Key {
+		t.Errorf("fourth request = %+v, want count exceeded", res)
+	}
+}
+
+func decisions(rule, decision string) float64 {
+	return promtestutil.ToFloat64(metrics.RateLimitDecisionsTotal.WithLabelValues(rule, decision))
+}
+
+func TestAllow_RecordsDecisions(t *testing.T) {
+	client := testutil.Redis(t)
+	ctx := context.Background()
+
+	l := NewLimiter(client)
+
+	allowed, rejected := decisions("block", "allowed"), decisions("block", "rejected")
+	for i := 0; i < RuleBlock.Limit+2; i++ {
+		l.Allow(ctx, "s1", RuleBlock)
+	}
+	if got := decisions("block", "allowed") - allowed; got != float64(RuleBlock.Limit) {
+		t.Errorf("allowed decisions = %v, want %d", got, RuleBlock.Limit)
+	}
+	if got := decisions("block", "rejected") - rejected; got != 2 {
+		t.Errorf("rejected decisions = %v, want 2", got)
+	}
+}
+
+func TestAllow_RecordsFailOpen(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { client.Close() })
+	l := NewLimiter(client)
+
+	before := decisions("other", "error")
+	if ok, err := l.Allow(context.Background(), "s1", Rule{Key: "rl:test:", Limit: 1, Window: time.Second}); !ok || err == nil {
+		t.Fatalf("Allow = %v, %v; want failed open", ok, err)
+	}
+	if got := decisions("other", "error") - before; got != 1 {
+		t.Errorf("error decisions = %v, want 1", got)
+	}
+}
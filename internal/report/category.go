@@ -0,0 +1,76 @@
+package report
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Category is the structured reason a user gives when reporting a partner.
+type Category string
+
+// Report categories, matching the CHECK constraint on abuse_reports.category.
+const (
+	CategoryHarassment    Category = "harassment"
+	CategorySpam          Category = "spam"
+	CategorySexualContent Category = "sexual_content"
+	CategoryUnderage      Category = "underage"
+	CategoryOther         Category = "other"
+)
+
+// MaxDetailsChars limits the free-text details attached to a report.
+const MaxDetailsChars = 500
+
+// categoryPolicy controls how a report of a category counts toward an
+// auto-ban and whether it goes straight to the moderator review queue.
+type categoryPolicy struct {
+	weight int64
+	review bool
+}
+
+var categoryPolicies = map[Category]categoryPolicy{
+	CategoryHarassment:    {weight: 1},
+	CategorySpam:          {weight: 1},
+	CategorySexualContent: {weight: 2},
+	CategoryUnderage:      {weight: 1, review: true},
+	CategoryOther:         {weight: 1},
+}
+
+// legacyCategories maps reason values accepted before the taxonomy existed.
+var legacyCategories = map[string]Category{
+	"explicit": CategorySexualContent,
+}
+
+// ParseCategory validates a report reason sent by a client.
+func ParseCategory(reason string) (Category, error) {
+	if c, ok := legacyCategories[reason]; ok {
+		return c, nil
+	}
+	c := Category(reason)
+	if _, ok := categoryPolicies[c]; !ok {
+		return "", fmt.Errorf("unknown report reason %q", reason)
+	}
+	return c, nil
+}
+
+// ValidateDetails checks the optional free-text details of a report.
+func ValidateDetails(details string) error {
+	if !utf8.ValidString(details) {
+		return fmt.Errorf("details contain invalid UTF-8")
+	}
+	if utf8.RuneCountInString(details) > MaxDetailsChars {
+		return fmt.Errorf("details exceed %d character limit", MaxDetailsChars)
+	}
+	return nil
+}
+
+// Weight is how much one report of this category counts toward the
+// auto-ban threshold.
+func (c Category) Weight() int64 {
+	return categoryPolicies[c].weight
+}
+
+// NeedsReview reports whether a single report of this category is escalated
+// to moderator review.
+func (c Category) NeedsReview() bool {
+	return categoryPolicies[c].review
+}
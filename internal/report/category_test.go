@@ -0,0 +1,57 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCategory(t *testing.T) {
+	tests := []struct {
+		reason  string
+		want    Category
+		wantErr bool
+	}{
+		{"harassment", CategoryHarassment, false},
+		{"sexual_content", CategorySexualContent, false},
+		{"underage", CategoryUnderage, false},
+		{"explicit", CategorySexualContent, false}, // legacy reason
+		{"rude", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCategory(tt.reason)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCategory(%q) = %q, %v; want %q, err=%v", tt.reason, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCategoryPolicy(t *testing.T) {
+	if !CategoryUnderage.NeedsReview() {
+		t.Error("underage reports should go to review")
+	}
+	if CategorySpam.NeedsReview() {
+		t.Error("spam reports should not go to review")
+	}
+	if CategorySexualContent.Weight() <= CategorySpam.Weight() {
+		t.Error("sexual_content should weigh more than spam")
+	}
+	if Category("bogus").Weight() != 0 {
+		t.Error("unknown category should have no weight")
+	}
+}
+
+func TestValidateDetails(t *testing.T) {
+	if err := ValidateDetails("kept asking where I live"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateDetails(strings.Repeat("é", MaxDetailsChars)); err != nil {
+		t.Errorf("details at the limit rejected: %v", err)
+	}
+	if err := ValidateDetails(strings.Repeat("a", MaxDetailsChars+1)); err == nil {
+		t.Error("expected error for overlong details")
+	}
+	if err := ValidateDetails("\xff"); err == nil {
+		t.Error("expected error for invalid UTF-8")
+	}
+}
@@ -0,0 +1,366 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Transcripts (the messages of reports and chat evidence) are encrypted at
+// rest with envelope encryption: each transcript is sealed with AES-256-GCM
+// under its own random data key, and the data key is stored alongside it
+// wrapped by a master key. Rotating the master key only re-wraps data keys.
+//
+// A sealed transcript is stored in the messages column as a JSON object,
+//
+//	{"v": 1, "kid": "2024-06", "key": "<wrapped data key>", "data": "<nonce and ciphertext>"}
+//
+// so the column type is unchanged and transcripts stored before encryption
+// was enabled, JSON arrays, are still read as they are.
+
+// dataKeySize is the size of a transcript's data key (AES-256).
+const dataKeySize = 32
+
+// sealVersion is the version of the sealed transcript format.
+const sealVersion = 1
+
+// ErrNoKeys is returned when reading an encrypted transcript from a store
+// without encryption keys.
+var ErrNoKeys = errors.New("report: transcript is encrypted but no encryption keys are configured")
+
+// KeyWrapper wraps and unwraps transcript data keys with a master key.
+// StaticKeys holds the master keys in the process; an implementation backed
+// by a KMS keeps them out of it.
+type KeyWrapper interface {
+	// CurrentKeyID names the master key new data keys are wrapped with.
+	CurrentKeyID() string
+	// Wrap encrypts a data key with the current master key and returns
+	// that key's ID with the result.
+	Wrap(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap decrypts a data key wrapped with the named master key.
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// StaticKeys is a KeyWrapper over AES-256 master keys held in memory, the
+// first of which wraps new data keys.
+type StaticKeys struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeys parses a REPORT_ENCRYPTION_KEYS setting: comma-separated
+// "<id>:<base64 key>" pairs of 32-byte keys. The first key wraps new data
+// keys; the others are only used to read transcripts wrapped before a
+// rotation.
+func ParseKeys(spec string) (*StaticKeys, error) {
+	k := &StaticKeys{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("report: key %q is not <id>:<base64 key>", entry)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("report: duplicate key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != dataKeySize {
+			return nil, fmt.Errorf("report: key %q must be %d base64-encoded bytes", id, dataKeySize)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+		if k.current == "" {
+			k.current = id
+		}
+	}
+	if k.current == "" {
+		return nil, errors.New("report: no encryption keys given")
+	}
+	return k, nil
+}
+
+// CurrentKeyID implements KeyWrapper.
+func (k *StaticKeys) CurrentKeyID() string {
+	return k.current
+}
+
+// Wrap implements KeyWrapper. The key ID is authenticated along with the
+// data key, so a wrapped key cannot be relabelled.
+func (k *StaticKeys) Wrap(_ context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return "", nil, err
+	}
+	return k.current, wrapped, nil
+}
+
+// Unwrap implements KeyWrapper.
+func (k *StaticKeys) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("report: unknown encryption key %q", keyID)
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+// NewKey returns a random master key in the form ParseKeys accepts.
+func NewKey(id string) (string, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// sealedTranscript is the stored form of an encrypted transcript.
+type sealedTranscript struct {
+	Version int    `json:"v"`
+	KeyID   string `json:"kid"`
+	Key     []byte `json:"key"`  // data key wrapped with master key KeyID
+	Data    []byte `json:"data"` // nonce followed by the sealed messages JSON
+}
+
+// transcriptAAD binds a sealed transcript to its table and chat, so it
+// cannot be moved to another row and read as that row's.
+func transcriptAAD(table, chatID string) []byte {
+	return []byte(table + "\x00" + chatID)
+}
+
+// isSealed reports whether stored messages are an encrypted transcript
+// rather than a plaintext JSON array.
+func isSealed(stored []byte) bool {
+	stored = bytes.TrimSpace(stored)
+	return len(stored) > 0 && stored[0] == '{'
+}
+
+// sealTranscript encrypts the messages JSON of a transcript under a new data
+// key wrapped by keys.
+func sealTranscript(ctx context.Context, keys KeyWrapper, aad, messages []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("report: generate data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := seal(aead, messages, aad)
+	if err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := keys.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("report: wrap data key: %w", err)
+	}
+	return json.Marshal(sealedTranscript{Version: sealVersion, KeyID: keyID, Key: wrapped, Data: data})
+}
+
+// openTranscript returns the messages JSON of a stored transcript,
+// decrypting it if it is sealed. keys may be nil if it is not.
+func openTranscript(ctx context.Context, keys KeyWrapper, aad, stored []byte) ([]byte, error) {
+	if !isSealed(stored) {
+		return stored, nil
+	}
+	if keys == nil {
+		return nil, ErrNoKeys
+	}
+	var st sealedTranscript
+	if err := json.Unmarshal(stored, &st); err != nil {
+		return nil, fmt.Errorf("report: unreadable sealed transcript: %w", err)
+	}
+	if st.Version != sealVersion {
+		return nil, fmt.Errorf("report: unsupported sealed transcript version %d", st.Version)
+	}
+	dataKey, err := keys.Unwrap(ctx, st.KeyID, st.Key)
+	if err != nil {
+		return nil, fmt.Errorf("report: unwrap data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := open(aead, st.Data, aad)
+	if err != nil {
+		return nil, fmt.Errorf("report: decrypt transcript: %w", err)
+	}
+	return messages, nil
+}
+
+// rewrapTranscript re-wraps the data key of a sealed transcript with the
+// current master key, leaving the sealed messages as they are. It reports
+// false if the transcript was already wrapped with the current key.
+func rewrapTranscript(ctx context.Context, keys KeyWrapper, stored []byte) ([]byte, bool, error) {
+	var st sealedTranscript
+	if err := json.Unmarshal(stored, &st); err != nil {
+		return nil, false, fmt.Errorf("report: unreadable sealed transcript: %w", err)
+	}
+	if st.KeyID == keys.CurrentKeyID() {
+		return stored, false, nil
+	}
+	dataKey, err := keys.Unwrap(ctx, st.KeyID, st.Key)
+	if err != nil {
+		return nil, false, fmt.Errorf("report: unwrap data key: %w", err)
+	}
+	if st.KeyID, st.Key, err = keys.Wrap(ctx, dataKey); err != nil {
+		return nil, false, fmt.Errorf("report: wrap data key: %w", err)
+	}
+	rewrapped, err := json.Marshal(st)
+	return rewrapped, true, err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("report: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("report: %w", err)
+	}
+	return aead, nil
+}
+
+// seal encrypts plaintext under a random nonce, which it prepends.
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("report: generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open decrypts the output of seal.
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("report: sealed data too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+// Tables holding transcripts.
+const (
+	tableReports  = "abuse_reports"
+	tableEvidence = "chat_evidence"
+)
+
+// rotateBatchSize is how many transcripts RotateKeys reads at a time.
+const rotateBatchSize = 500
+
+// sealMessages seals the messages JSON of a transcript in table if the
+// store has encryption keys, and returns it unchanged otherwise.
+func (s *sqlStore) sealMessages(ctx context.Context, table, chatID string, messages []byte) ([]byte, error) {
+	if s.keys == nil {
+		return messages, nil
+	}
+	return sealTranscript(ctx, s.keys, transcriptAAD(table, chatID), messages)
+}
+
+// openMessages returns the messages JSON of a transcript read from table.
+func (s *sqlStore) openMessages(ctx context.Context, table, chatID string, stored []byte) ([]byte, error) {
+	return openTranscript(ctx, s.keys, transcriptAAD(table, chatID), stored)
+}
+
+// RotateResult counts the transcripts RotateKeys looked at.
+type RotateResult struct {
+	Encrypted int `json:"encrypted"` // plaintext transcripts sealed
+	Rewrapped int `json:"rewrapped"` // data keys re-wrapped with the current master key
+	Current   int `json:"current"`   // already wrapped with the current master key
+}
+
+// RotateKeys brings every transcript up to the current master key: it seals
+// plaintext transcripts and re-wraps the data keys of sealed ones wrapped
+// with an older master key. Every master key in use must still be known to
+// the store's KeyWrapper. Rows are updated one at a time, so an interrupted
+// rotation can simply be run again; once it has finished, older master keys
+// can be retired.
+func (s *sqlStore) RotateKeys(ctx context.Context) (RotateResult, error) {
+	var result RotateResult
+	if s.keys == nil {
+		return result, errors.New("report: no encryption keys configured")
+	}
+	for _, table := range []string{tableReports, tableEvidence} {
+		if err := s.rotateTable(ctx, table, &result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// rotateTable runs RotateKeys over the transcripts in one table, in ID
+// order.
+func (s *sqlStore) rotateTable(ctx context.Context, table string, result *RotateResult) error {
+	type transcript struct {
+		id       int64
+		chatID   string
+		messages []byte
+	}
+	selectQuery := s.query(`
+		SELECT id, chat_id, messages
+		FROM ` + table + `
+		WHERE id > $1 AND messages IS NOT NULL
+		ORDER BY id
+		LIMIT $2`)
+	updateQuery := s.query(`UPDATE ` + table + ` SET messages = $1 WHERE id = $2`)
+
+	var lastID int64
+	for {
+		rows, err := s.db.QueryContext(ctx, selectQuery, lastID, rotateBatchSize)
+		if err != nil {
+			return fmt.Errorf("report: rotate %s: %w", table, err)
+		}
+		var batch []transcript
+		for rows.Next() {
+			var t transcript
+			if err := rows.Scan(&t.id, &t.chatID, &t.messages); err != nil {
+				rows.Close()
+				return fmt.Errorf("report: rotate %s: scan: %w", table, err)
+			}
+			batch = append(batch, t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("report: rotate %s: %w", table, err)
+		}
+
+		for _, t := range batch {
+			lastID = t.id
+			var updated []byte
+			if isSealed(t.messages) {
+				var changed bool
+				if updated, changed, err = rewrapTranscript(ctx, s.keys, t.messages); err != nil {
+					return fmt.Errorf("report: rotate %s %d: %w", table, t.id, err)
+				}
+				if !changed {
+					result.Current++
+					continue
+				}
+				result.Rewrapped++
+			} else {
+				if updated, err = sealTranscript(ctx, s.keys, transcriptAAD(table, t.chatID), t.messages); err != nil {
+					return fmt.Errorf("report: rotate %s %d: %w", table, t.id, err)
+				}
+				result.Encrypted++
+			}
+			if _, err := s.db.ExecContext(ctx, updateQuery, string(updated), t.id); err != nil {
+				return fmt.Errorf("report: rotate %s %d: update: %w", table, t.id, err)
+			}
+		}
+		if len(batch) < rotateBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// testKeys returns StaticKeys over freshly generated keys, the first current.
+func testKeys(t *testing.T, ids ...string) (*StaticKeys, []string) {
+	t.Helper()
+	specs := make([]string, len(ids))
+	for i, id := range ids {
+		spec, err := NewKey(id)
+		if err != nil {
+			t.Fatalf("NewKey: %v", err)
+		}
+		specs[i] = spec
+	}
+	keys, err := ParseKeys(strings.Join(specs, ","))
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	return keys, specs
+}
+
+func TestParseKeys(t *testing.T) {
+	_, specs := testKeys(t, "k2", "k1")
+	keys, err := ParseKeys(" " + specs[0] + " , " + specs[1])
+	if err != nil || keys.CurrentKeyID() != "k2" {
+		t.Fatalf("ParseKeys = %v, %v; want k2 current", keys, err)
+	}
+
+	for _, spec := range []string{
+		"",
+		"k1",
+		":" + specs[0][3:],
+		"k1:not-base64!",
+		"k1:c2hvcnQ=",
+		specs[1] + "," + specs[1],
+	} {
+		if _, err := ParseKeys(spec); err == nil {
+			t.Errorf("ParseKeys(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestTranscript_SealOpenRewrap(t *testing.T) {
+	ctx := context.Background()
+	oldKeys, specs := testKeys(t, "k1")
+	messages := []byte(`[{"from":"user_b","text":"hi","ts":1700000000}]`)
+	aad := transcriptAAD(tableReports, "chat-1")
+
+	sealed, err := sealTranscript(ctx, oldKeys, aad, messages)
+	if err != nil {
+		t.Fatalf("sealTranscript: %v", err)
+	}
+	if !isSealed(sealed) || bytes.Contains(sealed, []byte("hi")) {
+		t.Fatalf("sealed transcript %s is not opaque", sealed)
+	}
+	if got, err := openTranscript(ctx, oldKeys, aad, sealed); err != nil || !bytes.Equal(got, messages) {
+		t.Fatalf("openTranscript = %s, %v", got, err)
+	}
+	if _, err := openTranscript(ctx, oldKeys, transcriptAAD(tableReports, "chat-2"), sealed); err == nil {
+		t.Error("a transcript opened as another chat's")
+	}
+	if _, err := openTranscript(ctx, nil, aad, sealed); err != ErrNoKeys {
+		t.Errorf("open without keys = %v, want ErrNoKeys", err)
+	}
+	if got, err := openTranscript(ctx, nil, aad, messages); err != nil || !bytes.Equal(got, messages) {
+		t.Errorf("plaintext transcript read as %s, %v", got, err)
+	}
+
+	newSpec, _ := NewKey("k2")
+	keys, err := ParseKeys(newSpec + "," + specs[0])
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	rewrapped, changed, err := rewrapTranscript(ctx, keys, sealed)
+	if err != nil || !changed {
+		t.Fatalf("rewrapTranscript = %t, %v", changed, err)
+	}
+	if _, changed, _ := rewrapTranscript(ctx, keys, rewrapped); changed {
+		t.Error("a transcript wrapped with the current key was re-wrapped")
+	}
+	newOnly, _ := ParseKeys(newSpec)
+	if got, err := openTranscript(ctx, newOnly, aad, rewrapped); err != nil || !bytes.Equal(got, messages) {
+		t.Errorf("rewrapped transcript opened as %s, %v", got, err)
+	}
+}
@@ -0,0 +1,105 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/whisper/chat-app/internal/database"
+)
+
+// Evidence is the transcript of a chat captured when a moderator terminated
+// it. Messages are anonymised as "user_a" and "user_b", matching
+// FingerprintA and FingerprintB.
+type Evidence struct {
+	ID                int64          `json:"id"`
+	ChatID            string         `json:"chat_id"`
+	Actor             string         `json:"actor"` // moderator who terminated the chat
+	Reason            string         `json:"reason"`
+	FingerprintA      string         `json:"fingerprint_a"`
+	FingerprintB      string         `json:"fingerprint_b"`
+	BannedFingerprint string         `json:"banned_fingerprint,omitempty"`
+	Messages          []MessageEntry `json:"messages"`
+	CreatedAt         time.Time      `json:"created_at"`
+}
+
+// CreateEvidence inserts captured chat evidence and sets its ID.
+func (s *sqlStore) CreateEvidence(ctx context.Context, e *Evidence) error {
+	if e.ChatID == "" || e.Actor == "" {
+		return fmt.Errorf("report: evidence needs a chat ID and an actor")
+	}
+	messages := e.Messages
+	if messages == nil {
+		messages = []MessageEntry{}
+	}
+	messagesJSON, err := json.Marshal(messages)
+	if err != nil {
+		return fmt.Errorf("report: marshal evidence: %w", err)
+	}
+	if messagesJSON, err = s.sealMessages(ctx, tableEvidence, e.ChatID, messagesJSON); err != nil {
+		return err
+	}
+
+	const query = `
+		INSERT INTO chat_evidence (chat_id, actor, reason, fingerprint_a, fingerprint_b,
+		                           banned_fingerprint, messages)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	args := []interface{}{
+		e.ChatID, e.Actor, e.Reason, e.FingerprintA, e.FingerprintB,
+		e.BannedFingerprint, string(messagesJSON),
+	}
+
+	// MySQL has no RETURNING; it and SQLite report the new row's ID instead.
+	if s.dialect == database.Postgres {
+		err = s.db.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&e.ID)
+	} else {
+		var res sql.Result
+		if res, err = s.db.ExecContext(ctx, s.query(query), args...); err == nil {
+			e.ID, err = res.LastInsertId()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("report: insert evidence: %w", err)
+	}
+	return nil
+}
+
+// ListEvidence returns the evidence captured for a chat, oldest first.
+func (s *sqlStore) ListEvidence(ctx context.Context, chatID string) ([]Evidence, error) {
+	const query = `
+		SELECT id, chat_id, actor, reason, fingerprint_a, fingerprint_b,
+		       banned_fingerprint, messages, created_at
+		FROM chat_evidence
+		WHERE chat_id = $1
+		ORDER BY created_at, id`
+
+	rows, err := s.db.QueryContext(ctx, s.query(query), chatID)
+	if err != nil {
+		return nil, fmt.Errorf("report: list evidence: %w", err)
+	}
+	defer rows.Close()
+
+	list := []Evidence{}
+	for rows.Next() {
+		var e Evidence
+		var messagesJSON []byte
+		if err := rows.Scan(&e.ID, &e.ChatID, &e.Actor, &e.Reason, &e.FingerprintA,
+			&e.FingerprintB, &e.BannedFingerprint, &messagesJSON, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("report: scan evidence: %w", err)
+		}
+		messagesJSON, err := s.openMessages(ctx, tableEvidence, e.ChatID, messagesJSON)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(messagesJSON, &e.Messages); err != nil {
+			return nil, fmt.Errorf("report: unmarshal evidence: %w", err)
+		}
+		list = append(list, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("report: list evidence: %w", err)
+	}
+	return list, nil
+}
@@ -0,0 +1,69 @@
+package report
+
+import (
+	"context"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// testEvidence stores two pieces of evidence for chat-1 in s and checks they
+// come back in order with their transcripts.
+func testEvidence(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	first := &Evidence{
+		ChatID:            "chat-1",
+		Actor:             "admin:alice",
+		Reason:            "threats",
+		FingerprintA:      "fp-a",
+		FingerprintB:      "fp-b",
+		BannedFingerprint: "fp-b",
+		Messages: []MessageEntry{
+			{From: "user_a", Text: "hi", Ts: 1700000000},
+			{From: "user_b", Text: "go away", Ts: 1700000005},
+		},
+	}
+	if err := s.CreateEvidence(ctx, first); err != nil {
+		t.Fatalf("CreateEvidence: %v", err)
+	}
+	second := &Evidence{ChatID: "chat-1", Actor: "admin:bob"}
+	if err := s.CreateEvidence(ctx, second); err != nil {
+		t.Fatalf("CreateEvidence (no messages): %v", err)
+	}
+	if first.ID == 0 || second.ID <= first.ID {
+		t.Fatalf("IDs = %d, %d; want increasing non-zero", first.ID, second.ID)
+	}
+	if err := s.CreateEvidence(ctx, &Evidence{ChatID: "chat-1"}); err == nil {
+		t.Error("expected evidence without an actor to be rejected")
+	}
+
+	list, err := s.ListEvidence(ctx, "chat-1")
+	if err != nil {
+		t.Fatalf("ListEvidence: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID {
+		t.Fatalf("unexpected evidence: %+v", list)
+	}
+	got := list[0]
+	if got.BannedFingerprint != "fp-b" || got.FingerprintA != "fp-a" || len(got.Messages) != 2 ||
+		got.Messages[1].Text != "go away" || got.CreatedAt.IsZero() {
+		t.Errorf("unexpected evidence: %+v", got)
+	}
+	if list[1].Messages == nil || len(list[1].Messages) != 0 {
+		t.Errorf("messages of empty transcript = %#v, want empty", list[1].Messages)
+	}
+
+	if list, err := s.ListEvidence(ctx, "chat-2"); err != nil || len(list) != 0 {
+		t.Errorf("ListEvidence(chat-2) = %+v, %v; want none", list, err)
+	}
+}
+
+func TestStore_Evidence(t *testing.T) {
+	testEvidence(t, NewStore(testutil.Postgres(t)))
+}
+
+func TestSQLiteStore_Evidence(t *testing.T) {
+	testEvidence(t, sqliteStore(t))
+}
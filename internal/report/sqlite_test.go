@@ -0,0 +1,151 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/database"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// sqliteDB returns a freshly migrated SQLite database.
+func sqliteDB(t *testing.T) (*sql.DB, database.Dialect) {
+	t.Helper()
+	url := "sqlite://" + filepath.Join(t.TempDir(), "whisper.db")
+	if err := database.RunMigrations(url, testutil.MigrationsDir()); err != nil {
+		t.Fatalf("RunMigrations: %v", err)
+	}
+	db, dialect, err := database.Open(url)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, dialect
+}
+
+// sqliteStore returns a store on a freshly migrated SQLite database.
+func sqliteStore(t *testing.T) Store {
+	t.Helper()
+	return NewDialectStore(sqliteDB(t))
+}
+
+func TestSQLiteStore(t *testing.T) {
+	s := sqliteStore(t)
+	ctx := context.Background()
+
+	for _, reason := range []string{"spam", "explicit", "underage"} {
+		err := s.Create(ctx, &Report{
+			ReporterFingerprint: "fp-reporter",
+			ReportedFingerprint: "fp-target",
+			ChatID:              "chat-1",
+			Reason:              reason,
+			Messages:            []MessageEntry{{From: "user_b", Text: "hi", Ts: 1700000000}},
+		})
+		if err != nil {
+			t.Fatalf("Create(%s): %v", reason, err)
+		}
+	}
+
+	if n, err := s.CountRecent(ctx, "fp-target", time.Hour); err != nil || n != 3 {
+		t.Errorf("CountRecent = %d, %v; want 3", n, err)
+	}
+	if w, err := s.WeightRecent(ctx, "fp-target", time.Hour); err != nil || w != 4 {
+		t.Errorf("WeightRecent = %d, %v; want 4", w, err)
+	}
+
+	records, err := s.ListAgainst(ctx, "fp-target", 10)
+	if err != nil {
+		t.Fatalf("ListAgainst: %v", err)
+	}
+	if len(records) != 3 || records[0].Category != CategoryUnderage || len(records[0].Messages) != 1 || records[0].CreatedAt.IsZero() {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	if recent, err := s.ListRecent(ctx, 2); err != nil || len(recent) != 2 || recent[0].ID != records[0].ID || recent[1].ID != records[1].ID {
+		t.Errorf("ListRecent = %+v, %v; want the newest 2", recent, err)
+	}
+
+	queue, err := s.ListNeedsReview(ctx, 10)
+	if err != nil || len(queue) != 1 {
+		t.Fatalf("ListNeedsReview = %+v, %v", queue, err)
+	}
+	if err := s.ResolveReview(ctx, queue[0].ID); err != nil {
+		t.Fatalf("ResolveReview: %v", err)
+	}
+	if err := s.ResolveReview(ctx, queue[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second ResolveReview = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteStore_EncryptedTranscripts(t *testing.T) {
+	db, dialect := sqliteDB(t)
+	ctx := context.Background()
+	messages := []MessageEntry{{From: "user_b", Text: "secret words", Ts: 1700000000}}
+
+	// A report filed before encryption was enabled stays plaintext.
+	if err := NewDialectStore(db, dialect).Create(ctx, &Report{ReportedFingerprint: "fp-target", ChatID: "chat-1", Reason: "spam", Messages: messages}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	oldKeys, specs := testKeys(t, "k1")
+	s := NewEncryptedStore(db, dialect, oldKeys)
+	if err := s.Create(ctx, &Report{ReportedFingerprint: "fp-target", ChatID: "chat-2", Reason: "spam", Messages: messages}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.CreateEvidence(ctx, &Evidence{ChatID: "chat-2", Actor: "alice", Messages: messages}); err != nil {
+		t.Fatalf("CreateEvidence: %v", err)
+	}
+	var raw string
+	if err := db.QueryRow(`SELECT messages FROM chat_evidence`).Scan(&raw); err != nil || strings.Contains(raw, "secret") {
+		t.Fatalf("evidence stored as %q, %v; want it sealed", raw, err)
+	}
+
+	records, err := s.ListAgainst(ctx, "fp-target", 10)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("ListAgainst = %+v, %v", records, err)
+	}
+	for _, rec := range records {
+		if len(rec.Messages) != 1 || rec.Messages[0].Text != "secret words" {
+			t.Errorf("report %d messages = %+v", rec.ID, rec.Messages)
+		}
+	}
+	unkeyed, err := NewDialectStore(db, dialect).ListAgainst(ctx, "fp-target", 10)
+	if err != nil || len(unkeyed) != 2 || unkeyed[0].MessagesError != ErrNoKeys.Error() || unkeyed[1].Messages[0].Text != "secret words" {
+		t.Errorf("reading sealed transcripts without keys = %+v, %v; want the sealed one flagged", unkeyed, err)
+	}
+
+	// Rotate to a new key, then drop the old one.
+	newSpec, err := NewKey("k2")
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+	keys, err := ParseKeys(newSpec + "," + specs[0])
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	result, err := NewEncryptedStore(db, dialect, keys).RotateKeys(ctx)
+	if err != nil || result != (RotateResult{Encrypted: 1, Rewrapped: 2}) {
+		t.Fatalf("RotateKeys = %+v, %v; want 1 encrypted and 2 rewrapped", result, err)
+	}
+	newOnly, _ := ParseKeys(newSpec)
+	s = NewEncryptedStore(db, dialect, newOnly)
+	if result, err := s.RotateKeys(ctx); err != nil || result != (RotateResult{Current: 3}) {
+		t.Errorf("second RotateKeys = %+v, %v; want all current", result, err)
+	}
+	if records, err := s.ListAgainst(ctx, "fp-target", 10); err != nil || len(records) != 2 || records[1].Messages[0].Text != "secret words" {
+		t.Errorf("after rotation ListAgainst = %+v, %v", records, err)
+	}
+	if evidence, err := s.ListEvidence(ctx, "chat-2"); err != nil || len(evidence) != 1 || evidence[0].Messages[0].Text != "secret words" {
+		t.Errorf("after rotation ListEvidence = %+v, %v", evidence, err)
+	}
+}
+
+func TestSQLiteStore_UnreadableTranscripts(t *testing.T) {
+	db, dialect := sqliteDB(t)
+	testUnreadableTranscripts(t, db, dialect)
+}
@@ -1,28 +1,63 @@
-// Package report provides PostgreSQL-backed storage for abuse reports.
-// Each report captures who reported whom, the chat context, and the last
-// few messages exchanged (for moderator review).
+// Package report provides SQL storage for abuse reports in PostgreSQL,
+// MySQL or SQLite. Each report captures who reported whom, the chat
+// context, and the last few messages exchanged (for moderator review).
+// Transcripts of chats terminated by a moderator are kept as evidence.
+// Given encryption keys, the store encrypts transcripts at rest (see
+// KeyWrapper).
 package report
 
 import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/whisper/chat-app/internal/database"
 )
 
-// validReasons is the set of allowed reason values, matching the CHECK
-// constraint on the abuse_reports table.
-var validReasons = map[string]bool{
-	"harassment": true,
-	"spam":       true,
-	"explicit":   true,
-	"other":      true,
+// ErrNotFound is returned when a report does not exist.
+var ErrNotFound = errors.New("report: not found")
+
+// Store persists abuse reports.
+type Store interface {
+	// Create validates and inserts a report.
+	Create(ctx context.Context, report *Report) error
+	// CountRecent returns the number of reports filed against a
+	// fingerprint within window.
+	CountRecent(ctx context.Context, reportedFingerprint string, window time.Duration) (int, error)
+	// WeightRecent returns the summed category weight of reports filed
+	// against a fingerprint within window.
+	WeightRecent(ctx context.Context, reportedFingerprint string, window time.Duration) (int, error)
+	// ListAgainst returns up to limit reports filed against a fingerprint,
+	// newest first.
+	ListAgainst(ctx context.Context, reportedFingerprint string, limit int) ([]Record, error)
+	// ListRecent returns up to limit reports, newest first.
+	ListRecent(ctx context.Context, limit int) ([]Record, error)
+	// ListNeedsReview returns up to limit unresolved reports escalated to
+	// moderator review, oldest first.
+	ListNeedsReview(ctx context.Context, limit int) ([]Record, error)
+	// ResolveReview removes a report from the review queue, returning
+	// ErrNotFound if it is not awaiting review.
+	ResolveReview(ctx context.Context, id int64) error
+	// CreateEvidence stores the transcript of a chat a moderator
+	// terminated, setting its ID.
+	CreateEvidence(ctx context.Context, evidence *Evidence) error
+	// ListEvidence returns the evidence captured for a chat, oldest first.
+	ListEvidence(ctx context.Context, chatID string) ([]Evidence, error)
+	// RotateKeys encrypts plaintext transcripts and re-wraps the data keys
+	// of those wrapped with an older master key.
+	RotateKeys(ctx context.Context) (RotateResult, error)
 }
 
-// Store manages abuse reports in PostgreSQL.
-type Store struct {
-	db *sql.DB
+// sqlStore implements Store for every supported dialect. The queries are
+// written for PostgreSQL and rebound for the others; only the time window
+// arithmetic differs.
+type sqlStore struct {
+	db      *sql.DB
+	dialect database.Dialect
+	keys    KeyWrapper // nil stores transcripts in plaintext
 }
 
 // Report represents a single abuse report to be persisted.
@@ -30,7 +65,9 @@ type Report struct {
 	ReporterFingerprint string
 	ReportedFingerprint string
 	ChatID              string
-	Reason              string
+	Reason              string         // reason as sent by the client
+	Category            Category       // parsed from Reason when empty
+	Details             string         // optional free text
 	Messages            []MessageEntry // last N messages from the chat buffer
 }
 
@@ -41,38 +78,91 @@ type MessageEntry struct {
 	Ts   int64  `json:"ts"`
 }
 
-// NewStore creates a new report store backed by the given database handle.
-func NewStore(db *sql.DB) *Store {
-	return &Store{db: db}
+// NewStore creates a report store backed by the given PostgreSQL handle.
+func NewStore(db *sql.DB) Store {
+	return NewDialectStore(db, database.Postgres)
 }
 
-// Create inserts an abuse report into PostgreSQL.
-// Messages are marshalled to JSONB. The reason is validated against the
-// allowed set before insertion.
-func (s *Store) Create(ctx context.Context, report *Report) error {
-	if !validReasons[report.Reason] {
-		return fmt.Errorf("report: invalid reason %q", report.Reason)
+// NewDialectStore creates a report store for a database of the given
+// dialect, whose schema was created by that dialect's migrations.
+func NewDialectStore(db *sql.DB, dialect database.Dialect) Store {
+	return &sqlStore{db: db, dialect: dialect}
+}
+
+// NewEncryptedStore is NewDialectStore with transcripts encrypted under
+// data keys wrapped by keys. Transcripts stored in plaintext before are
+// still read; RotateKeys encrypts them.
+func NewEncryptedStore(db *sql.DB, dialect database.Dialect, keys KeyWrapper) Store {
+	return &sqlStore{db: db, dialect: dialect, keys: keys}
+}
+
+// query rebinds a PostgreSQL query for the store's dialect.
+func (s *sqlStore) query(q string) string {
+	return database.Rebind(s.dialect, q)
+}
+
+// since returns the SQL expression for "now minus window", using the
+// placeholder $n, and the argument to bind to it. The cutoff is computed by
+// the database so it agrees with the created_at defaults.
+func (s *sqlStore) since(n int, window time.Duration) (string, interface{}) {
+	switch s.dialect {
+	case database.MySQL:
+		return fmt.Sprintf("NOW(6) - INTERVAL $%d MICROSECOND", n), window.Microseconds()
+	case database.SQLite:
+		return fmt.Sprintf("datetime('now', $%d)", n), fmt.Sprintf("-%d seconds", int64(window.Seconds()))
+	default:
+		return fmt.Sprintf("NOW() - $%d::interval", n), window.String()
 	}
+}
 
-	var messagesJSON []byte
-	if len(report.Messages) > 0 {
+// Create inserts an abuse report.
+// Messages are marshalled to JSON, and sealed if the store has encryption
+// keys. The category's weight and review flag
+// are stored with the report so later counts do not depend on the current
+// policy.
+func (s *sqlStore) Create(ctx context.Context, report *Report) error {
+	category := report.Category
+	if category == "" {
 		var err error
-		messagesJSON, err = json.Marshal(report.Messages)
+		if category, err = ParseCategory(report.Reason); err != nil {
+			return fmt.Errorf("report: %w", err)
+		}
+	}
+	if category.Weight() == 0 {
+		return fmt.Errorf("report: invalid category %q", category)
+	}
+	if err := ValidateDetails(report.Details); err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+
+	var messages interface{}
+	if len(report.Messages) > 0 {
+		messagesJSON, err := json.Marshal(report.Messages)
 		if err != nil {
 			return fmt.Errorf("report: marshal messages: %w", err)
 		}
+		if messagesJSON, err = s.sealMessages(ctx, tableReports, report.ChatID, messagesJSON); err != nil {
+			return err
+		}
+		// MySQL rejects binary strings for JSON columns.
+		messages = string(messagesJSON)
 	}
 
 	const query = `
-		INSERT INTO abuse_reports (reporter_fingerprint, reported_fingerprint, chat_id, reason, messages)
-		VALUES ($1, $2, $3, $4, $5)`
+		INSERT INTO abuse_reports (reporter_fingerprint, reported_fingerprint, chat_id, reason,
+		                           category, details, weight, needs_review, messages)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
-	_, err := s.db.ExecContext(ctx, query,
+	_, err := s.db.ExecContext(ctx, s.query(query),
 		report.ReporterFingerprint,
 		report.ReportedFingerprint,
 		report.ChatID,
 		report.Reason,
-		messagesJSON,
+		string(category),
+		report.Details,
+		category.Weight(),
+		category.NeedsReview(),
+		messages,
 	)
 	if err != nil {
 		return fmt.Errorf("report: insert: %w", err)
@@ -83,17 +173,164 @@ func (s *Store) Create(ctx context.Context, report *Report) error {
 // CountRecent returns the number of reports filed against a fingerprint
 // within the given time window. This is useful for auto-ban logic
 // (e.g. 3 reports in 24 hours triggers a ban).
-func (s *Store) CountRecent(ctx context.Context, reportedFingerprint string, window time.Duration) (int, error) {
-	const query = `
+func (s *sqlStore) CountRecent(ctx context.Context, reportedFingerprint string, window time.Duration) (int, error) {
+	cutoff, windowArg := s.since(2, window)
+	query := `
 		SELECT COUNT(*)
 		FROM abuse_reports
 		WHERE reported_fingerprint = $1
-		  AND created_at >= NOW() - $2::interval`
+		  AND created_at >= ` + cutoff
 
 	var count int
-	err := s.db.QueryRowContext(ctx, query, reportedFingerprint, window.String()).Scan(&count)
+	err := s.db.QueryRowContext(ctx, s.query(query), reportedFingerprint, windowArg).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("report: count recent: %w", err)
 	}
 	return count, nil
 }
+
+// WeightRecent returns the summed category weight of reports filed against
+// a fingerprint within the given time window, the weighted counterpart of
+// CountRecent used for the auto-ban cross-check.
+func (s *sqlStore) WeightRecent(ctx context.Context, reportedFingerprint string, window time.Duration) (int, error) {
+	cutoff, windowArg := s.since(2, window)
+	query := `
+		SELECT COALESCE(SUM(weight), 0)
+		FROM abuse_reports
+		WHERE reported_fingerprint = $1
+		  AND created_at >= ` + cutoff
+
+	var weight int
+	err := s.db.QueryRowContext(ctx, s.query(query), reportedFingerprint, windowArg).Scan(&weight)
+	if err != nil {
+		return 0, fmt.Errorf("report: weight recent: %w", err)
+	}
+	return weight, nil
+}
+
+// Record is a persisted abuse report as returned to moderators.
+type Record struct {
+	ID                  int64          `json:"id"`
+	ReporterFingerprint string         `json:"reporter_fingerprint"`
+	ReportedFingerprint string         `json:"reported_fingerprint"`
+	ChatID              string         `json:"chat_id"`
+	Reason              string         `json:"reason"`
+	Category            Category       `json:"category"`
+	Details             string         `json:"details,omitempty"`
+	NeedsReview         bool           `json:"needs_review"`
+	Messages            []MessageEntry `json:"messages,omitempty"`
+	CreatedAt           time.Time      `json:"created_at"`
+
+	// MessagesError is set, and Messages left empty, when the stored
+	// transcript could not be read, e.g. because it was sealed with a
+	// master key the store no longer has.
+	MessagesError string `json:"messages_error,omitempty"`
+}
+
+// recordColumns is the column list scanned by scanRecords.
+const recordColumns = `id, reporter_fingerprint, reported_fingerprint, chat_id, reason,
+		       category, details, needs_review, messages, created_at`
+
+// ListAgainst returns up to limit reports filed against a fingerprint,
+// newest first.
+func (s *sqlStore) ListAgainst(ctx context.Context, reportedFingerprint string, limit int) ([]Record, error) {
+	query := `
+		SELECT ` + recordColumns + `
+		FROM abuse_reports
+		WHERE reported_fingerprint = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, s.query(query), reportedFingerprint, limit)
+	if err != nil {
+		return nil, fmt.Errorf("report: list: %w", err)
+	}
+	return s.scanRecords(ctx, rows)
+}
+
+// ListRecent returns up to limit reports, newest first.
+func (s *sqlStore) ListRecent(ctx context.Context, limit int) ([]Record, error) {
+	query := `
+		SELECT ` + recordColumns + `
+		FROM abuse_reports
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1`
+
+	rows, err := s.db.QueryContext(ctx, s.query(query), limit)
+	if err != nil {
+		return nil, fmt.Errorf("report: list recent: %w", err)
+	}
+	return s.scanRecords(ctx, rows)
+}
+
+// ListNeedsReview returns up to limit reports escalated to moderator review
+// that have not been resolved, oldest first.
+func (s *sqlStore) ListNeedsReview(ctx context.Context, limit int) ([]Record, error) {
+	query := `
+		SELECT ` + recordColumns + `
+		FROM abuse_reports
+		WHERE needs_review
+		ORDER BY created_at, id
+		LIMIT $1`
+
+	rows, err := s.db.QueryContext(ctx, s.query(query), limit)
+	if err != nil {
+		return nil, fmt.Errorf("report: list review: %w", err)
+	}
+	return s.scanRecords(ctx, rows)
+}
+
+// ResolveReview removes a report from the review queue. It returns
+// ErrNotFound if no report with that ID is awaiting review.
+func (s *sqlStore) ResolveReview(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, s.query(`UPDATE abuse_reports SET needs_review = FALSE WHERE id = $1 AND needs_review`), id)
+	if err != nil {
+		return fmt.Errorf("report: resolve review: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanRecords reads recordColumns rows, decrypting sealed messages. A
+// transcript that cannot be opened or decoded is reported in the record's
+// MessagesError rather than failing the whole list.
+func (s *sqlStore) scanRecords(ctx context.Context, rows *sql.Rows) ([]Record, error) {
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var rec Record
+		var messagesJSON []byte
+		if err := rows.Scan(&rec.ID, &rec.ReporterFingerprint, &rec.ReportedFingerprint,
+			&rec.ChatID, &rec.Reason, &rec.Category, &rec.Details, &rec.NeedsReview,
+			&messagesJSON, &rec.CreatedAt); err != nil {
+			return nil, fmt.Errorf("report: scan: %w", err)
+		}
+		if len(messagesJSON) > 0 {
+			if err := s.readMessages(ctx, &rec, messagesJSON); err != nil {
+				rec.Messages = nil
+				rec.MessagesError = err.Error()
+			}
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("report: list: %w", err)
+	}
+	return records, nil
+}
+
+// readMessages opens and decodes a report's stored transcript into
+// rec.Messages.
+func (s *sqlStore) readMessages(ctx context.Context, rec *Record, stored []byte) error {
+	messagesJSON, err := s.openMessages(ctx, tableReports, rec.ChatID, stored)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(messagesJSON, &rec.Messages); err != nil {
+		return fmt.Errorf("report: unmarshal messages: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/database"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestStore_WeightRecent(t *testing.T) {
+	s := NewStore(testutil.Postgres(t))
+	ctx := context.Background()
+
+	for _, reason := range []string{"spam", "explicit", "harassment"} {
+		err := s.Create(ctx, &Report{
+			ReporterFingerprint: "fp-reporter",
+			ReportedFingerprint: "fp-target",
+			ChatID:              "chat-1",
+			Reason:              reason,
+		})
+		if err != nil {
+			t.Fatalf("Create(%s): %v", reason, err)
+		}
+	}
+
+	if n, err := s.CountRecent(ctx, "fp-target", time.Hour); err != nil || n != 3 {
+		t.Errorf("CountRecent = %d, %v; want 3", n, err)
+	}
+	// The legacy "explicit" reason counts as sexual_content (weight 2).
+	if w, err := s.WeightRecent(ctx, "fp-target", time.Hour); err != nil || w != 4 {
+		t.Errorf("WeightRecent = %d, %v; want 4", w, err)
+	}
+	if w, err := s.WeightRecent(ctx, "fp-other", time.Hour); err != nil || w != 0 {
+		t.Errorf("WeightRecent(other) = %d, %v; want 0", w, err)
+	}
+}
+
+func TestStore_ReviewQueue(t *testing.T) {
+	s := NewStore(testutil.Postgres(t))
+	ctx := context.Background()
+
+	err := s.Create(ctx, &Report{
+		ReporterFingerprint: "fp-reporter",
+		ReportedFingerprint: "fp-target",
+		ChatID:              "chat-1",
+		Reason:              string(CategoryUnderage),
+		Details:             "said they were 14",
+		Messages:            []MessageEntry{{From: "user_b", Text: "hi", Ts: 1700000000}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.Create(ctx, &Report{ReportedFingerprint: "fp-target", ChatID: "chat-2", Reason: "spam"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	queue, err := s.ListNeedsReview(ctx, 10)
+	if err != nil {
+		t.Fatalf("ListNeedsReview: %v", err)
+	}
+	if len(queue) != 1 || queue[0].Category != CategoryUnderage || queue[0].Details != "said they were 14" || len(queue[0].Messages) != 1 {
+		t.Fatalf("unexpected review queue: %+v", queue)
+	}
+
+	if err := s.ResolveReview(ctx, queue[0].ID); err != nil {
+		t.Fatalf("ResolveReview: %v", err)
+	}
+	if err := s.ResolveReview(ctx, queue[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second ResolveReview = %v, want ErrNotFound", err)
+	}
+	if queue, _ := s.ListNeedsReview(ctx, 10); len(queue) != 0 {
+		t.Errorf("review queue not empty after resolve: %+v", queue)
+	}
+}
+
+// testUnreadableTranscripts checks that ListAgainst and ListRecent flag a
+// report whose transcript cannot be read instead of failing the list.
+func testUnreadableTranscripts(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	t.Helper()
+	ctx := context.Background()
+	messages := []MessageEntry{{From: "user_b", Text: "hi", Ts: 1700000000}}
+
+	retired, _ := testKeys(t, "k1")
+	if err := NewEncryptedStore(db, dialect, retired).Create(ctx, &Report{ReportedFingerprint: "fp-target", ChatID: "chat-sealed", Reason: "spam", Messages: messages}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	plain := NewDialectStore(db, dialect)
+	for _, chatID := range []string{"chat-plain", "chat-corrupt"} {
+		if err := plain.Create(ctx, &Report{ReportedFingerprint: "fp-target", ChatID: chatID, Reason: "spam", Messages: messages}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	// Valid JSON, so every dialect's column accepts it, but not a list.
+	if _, err := db.ExecContext(ctx, database.Rebind(dialect, `UPDATE abuse_reports SET messages = $1 WHERE chat_id = $2`), `{"from":"user_b"}`, "chat-corrupt"); err != nil {
+		t.Fatalf("corrupt transcript: %v", err)
+	}
+
+	current, _ := testKeys(t, "k2")
+	s := NewEncryptedStore(db, dialect, current)
+	against, err := s.ListAgainst(ctx, "fp-target", 10)
+	if err != nil {
+		t.Fatalf("ListAgainst: %v", err)
+	}
+	recent, err := s.ListRecent(ctx, 10)
+	if err != nil {
+		t.Fatalf("ListRecent: %v", err)
+	}
+	for name, records := range map[string][]Record{"ListAgainst": against, "ListRecent": recent} {
+		if len(records) != 3 {
+			t.Fatalf("%s returned %d records, want 3", name, len(records))
+		}
+		for _, rec := range records {
+			readable := rec.ChatID == "chat-plain"
+			if readable && (len(rec.Messages) != 1 || rec.MessagesError != "") {
+				t.Errorf("%s: %s = %+v, want its messages", name, rec.ChatID, rec)
+			}
+			if !readable && (rec.Messages != nil || rec.MessagesError == "") {
+				t.Errorf("%s: %s = %+v, want it flagged", name, rec.ChatID, rec)
+			}
+		}
+	}
+}
+
+func TestStore_UnreadableTranscripts(t *testing.T) {
+	testUnreadableTranscripts(t, testutil.Postgres(t), database.Postgres)
+}
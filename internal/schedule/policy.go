@@ -0,0 +1,263 @@
+// Package schedule decides whether matchmaking is open. A Policy combines
+// closed windows (recurring maintenance or legal quiet hours, and one-off
+// maintenance periods) with a cap on concurrently active chats. When the
+// policy closes matchmaking, find_match is answered with service_unavailable
+// and the time at which the client may try again.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CapacityRetryAfter is the reopen hint returned when matchmaking is closed
+// because the active chat cap has been reached. Capacity frees up as chats
+// end, so there is no fixed reopen time.
+const CapacityRetryAfter = 30 * time.Second
+
+// maxChainedWindows bounds how many back-to-back windows Check follows when
+// computing a reopen time.
+const maxChainedWindows = 16
+
+// Reasons reported in a closed Decision.
+const (
+	ReasonClosedHours = "closed_hours"
+	ReasonMaintenance = "maintenance"
+	ReasonCapacity    = "capacity"
+)
+
+// Window is a period during which matchmaking is closed.
+type Window interface {
+	// Contains reports whether t falls in the window and, if so, when the
+	// window ends.
+	Contains(t time.Time) (bool, time.Time)
+	// Reason is the Decision reason reported while the window is active.
+	Reason() string
+}
+
+// Policy is a set of closed windows plus an optional active chat cap.
+// The zero value is always open.
+type Policy struct {
+	Windows        []Window
+	MaxActiveChats int64 // 0 disables the capacity gate
+}
+
+// Decision is the outcome of Policy.Check.
+type Decision struct {
+	Open     bool
+	Reason   string
+	ReopenAt time.Time
+}
+
+// Check evaluates the policy at now with the given number of active chats.
+// Closed windows take precedence over the capacity gate. The reopen time of
+// a window follows any windows that start exactly when it ends.
+func (p *Policy) Check(now time.Time, activeChats int64) Decision {
+	if p == nil {
+		return Decision{Open: true}
+	}
+
+	if reason, reopen, closed := p.closedAt(now); closed {
+		for i := 0; i < maxChainedWindows; i++ {
+			_, next, stillClosed := p.closedAt(reopen)
+			if !stillClosed {
+				break
+			}
+			reopen = next
+		}
+		return Decision{Reason: reason, ReopenAt: reopen}
+	}
+
+	if p.MaxActiveChats > 0 && activeChats >= p.MaxActiveChats {
+		return Decision{Reason: ReasonCapacity, ReopenAt: now.Add(CapacityRetryAfter)}
+	}
+
+	return Decision{Open: true}
+}
+
+// HasCapacityGate reports whether the policy limits active chats.
+func (p *Policy) HasCapacityGate() bool {
+	return p != nil && p.MaxActiveChats > 0
+}
+
+// closedAt returns the first window containing t.
+func (p *Policy) closedAt(t time.Time) (string, time.Time, bool) {
+	for _, w := range p.Windows {
+		if in, end := w.Contains(t); in {
+			return w.Reason(), end, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// FixedWindow is a one-off closed period, typically planned maintenance.
+type FixedWindow struct {
+	Start, End time.Time
+}
+
+// Contains implements Window.
+func (w FixedWindow) Contains(t time.Time) (bool, time.Time) {
+	if !t.Before(w.Start) && t.Before(w.End) {
+		return true, w.End
+	}
+	return false, time.Time{}
+}
+
+// Reason implements Window.
+func (w FixedWindow) Reason() string { return ReasonMaintenance }
+
+// DailyWindow is a recurring closed period in a given time zone, e.g. legal
+// quiet hours for a region. Windows whose end is not after their start wrap
+// past midnight; the weekday filter applies to the day the window starts.
+type DailyWindow struct {
+	Days     [7]bool       // indexed by time.Weekday
+	Start    time.Duration // offset from local midnight
+	End      time.Duration // offset from local midnight
+	Location *time.Location
+}
+
+// Contains implements Window.
+func (w DailyWindow) Contains(t time.Time) (bool, time.Time) {
+	local := t.In(w.Location)
+	length := w.End - w.Start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+
+	// A window containing t started either today or, when it wraps past
+	// midnight, yesterday.
+	for _, back := range []int{0, -1} {
+		day := time.Date(local.Year(), local.Month(), local.Day()+back, 0, 0, 0, 0, w.Location)
+		if !w.Days[day.Weekday()] {
+			continue
+		}
+		start := clockOn(day, w.Start)
+		end := clockOn(day, w.Start+length)
+		if !local.Before(start) && local.Before(end) {
+			return true, end
+		}
+	}
+	return false, time.Time{}
+}
+
+// Reason implements Window.
+func (w DailyWindow) Reason() string { return ReasonClosedHours }
+
+// clockOn returns the wall-clock time offset past midnight of day. Offsets
+// of 24h or more roll over into the following days. Building the result with
+// time.Date keeps wall-clock semantics across DST changes.
+func clockOn(day time.Time, offset time.Duration) time.Time {
+	days := int(offset / (24 * time.Hour))
+	rem := offset % (24 * time.Hour)
+	return time.Date(day.Year(), day.Month(), day.Day()+days,
+		int(rem/time.Hour), int(rem%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// weekdays maps the accepted day names to time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWindows parses a semicolon-separated list of closed windows. Each
+// entry is either a recurring window
+//
+//	<days> HH:MM-HH:MM [IANA time zone]
+//
+// where days is "*" or a comma-separated list such as "sat,sun" (UTC when
+// the zone is omitted), or a one-off RFC 3339 interval
+//
+//	2024-05-01T02:00:00Z/2024-05-01T04:00:00Z
+//
+// For example: "* 23:00-06:00 Asia/Seoul; 2024-05-01T02:00:00Z/2024-05-01T04:00:00Z".
+func ParseWindows(spec string) ([]Window, error) {
+	var windows []Window
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		w, err := parseWindow(entry)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func parseWindow(entry string) (Window, error) {
+	// Recurring windows always contain a space; zone names may contain "/".
+	if start, end, ok := strings.Cut(entry, "/"); ok && !strings.ContainsAny(entry, " \t") {
+		s, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			return nil, fmt.Errorf("schedule: window %q: invalid start: %w", entry, err)
+		}
+		e, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			return nil, fmt.Errorf("schedule: window %q: invalid end: %w", entry, err)
+		}
+		if !e.After(s) {
+			return nil, fmt.Errorf("schedule: window %q: end must be after start", entry)
+		}
+		return FixedWindow{Start: s, End: e}, nil
+	}
+
+	fields := strings.Fields(entry)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("schedule: window %q: expected \"<days> HH:MM-HH:MM [zone]\"", entry)
+	}
+
+	w := DailyWindow{Location: time.UTC}
+	if fields[0] == "*" {
+		for i := range w.Days {
+			w.Days[i] = true
+		}
+	} else {
+		for _, name := range strings.Split(strings.ToLower(fields[0]), ",") {
+			day, ok := weekdays[name]
+			if !ok {
+				return nil, fmt.Errorf("schedule: window %q: unknown day %q", entry, name)
+			}
+			w.Days[day] = true
+		}
+	}
+
+	start, end, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return nil, fmt.Errorf("schedule: window %q: expected HH:MM-HH:MM", entry)
+	}
+	var err error
+	if w.Start, err = parseClock(start); err != nil {
+		return nil, fmt.Errorf("schedule: window %q: %w", entry, err)
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return nil, fmt.Errorf("schedule: window %q: %w", entry, err)
+	}
+	if w.Start == w.End {
+		return nil, fmt.Errorf("schedule: window %q: start and end are equal", entry)
+	}
+
+	if len(fields) == 3 {
+		loc, err := time.LoadLocation(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("schedule: window %q: %w", entry, err)
+		}
+		w.Location = loc
+	}
+	return w, nil
+}
+
+// parseClock parses "HH:MM" into an offset from midnight. "24:00" is
+// accepted as the end of the day.
+func parseClock(s string) (time.Duration, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
@@ -0,0 +1,120 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, spec string) []Window {
+	t.Helper()
+	w, err := ParseWindows(spec)
+	if err != nil {
+		t.Fatalf("ParseWindows(%q): %v", spec, err)
+	}
+	return w
+}
+
+func TestParseWindows_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"mon",
+		"xyz 01:00-02:00",
+		"* 25:00-02:00",
+		"* 01:00-01:00",
+		"* 01:00-02:00 Not/AZone",
+		"2024-05-01T04:00:00Z/2024-05-01T02:00:00Z",
+	} {
+		if _, err := ParseWindows(spec); err == nil {
+			t.Errorf("ParseWindows(%q): expected error", spec)
+		}
+	}
+}
+
+func TestPolicy_ZeroValueOpen(t *testing.T) {
+	var p Policy
+	if d := p.Check(time.Now(), 1000); !d.Open {
+		t.Errorf("zero policy should be open, got %+v", d)
+	}
+	var nilPolicy *Policy
+	if d := nilPolicy.Check(time.Now(), 0); !d.Open {
+		t.Errorf("nil policy should be open, got %+v", d)
+	}
+}
+
+func TestPolicy_DailyWindowWrapsMidnight(t *testing.T) {
+	p := Policy{Windows: mustParse(t, "* 23:00-06:00")}
+
+	tests := []struct {
+		at     string
+		open   bool
+		reopen string
+	}{
+		{"2024-05-01T22:59:00Z", true, ""},
+		{"2024-05-01T23:00:00Z", false, "2024-05-02T06:00:00Z"},
+		{"2024-05-02T03:30:00Z", false, "2024-05-02T06:00:00Z"},
+		{"2024-05-02T06:00:00Z", true, ""},
+	}
+	for _, tt := range tests {
+		now, _ := time.Parse(time.RFC3339, tt.at)
+		d := p.Check(now, 0)
+		if d.Open != tt.open {
+			t.Errorf("at %s: expected open=%v, got %+v", tt.at, tt.open, d)
+			continue
+		}
+		if !tt.open {
+			want, _ := time.Parse(time.RFC3339, tt.reopen)
+			if !d.ReopenAt.Equal(want) {
+				t.Errorf("at %s: expected reopen %s, got %s", tt.at, want, d.ReopenAt)
+			}
+			if d.Reason != ReasonClosedHours {
+				t.Errorf("at %s: expected reason %q, got %q", tt.at, ReasonClosedHours, d.Reason)
+			}
+		}
+	}
+}
+
+func TestPolicy_DailyWindowDaysAndZone(t *testing.T) {
+	// Saturdays 02:00-04:00 in Tokyo (UTC+9) is Friday 17:00-19:00 UTC.
+	p := Policy{Windows: mustParse(t, "sat 02:00-04:00 Asia/Tokyo")}
+
+	fri := time.Date(2024, 5, 3, 18, 0, 0, 0, time.UTC) // Sat 03:00 JST
+	if d := p.Check(fri, 0); d.Open {
+		t.Fatalf("expected closed at %s", fri)
+	} else if want := time.Date(2024, 5, 3, 19, 0, 0, 0, time.UTC); !d.ReopenAt.Equal(want) {
+		t.Errorf("expected reopen %s, got %s", want, d.ReopenAt)
+	}
+
+	sunday := fri.AddDate(0, 0, 1) // Sun 03:00 JST
+	if d := p.Check(sunday, 0); !d.Open {
+		t.Errorf("expected open at %s, got %+v", sunday, d)
+	}
+}
+
+func TestPolicy_ChainedWindows(t *testing.T) {
+	p := Policy{Windows: mustParse(t,
+		"2024-05-01T02:00:00Z/2024-05-01T04:00:00Z; * 04:00-05:00")}
+
+	now := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	d := p.Check(now, 0)
+	if d.Open || d.Reason != ReasonMaintenance {
+		t.Fatalf("expected maintenance closure, got %+v", d)
+	}
+	if want := time.Date(2024, 5, 1, 5, 0, 0, 0, time.UTC); !d.ReopenAt.Equal(want) {
+		t.Errorf("expected reopen %s, got %s", want, d.ReopenAt)
+	}
+}
+
+func TestPolicy_CapacityGate(t *testing.T) {
+	p := Policy{MaxActiveChats: 100}
+	now := time.Now()
+
+	if d := p.Check(now, 99); !d.Open {
+		t.Errorf("expected open below cap, got %+v", d)
+	}
+	d := p.Check(now, 100)
+	if d.Open || d.Reason != ReasonCapacity {
+		t.Fatalf("expected capacity closure, got %+v", d)
+	}
+	if !d.ReopenAt.Equal(now.Add(CapacityRetryAfter)) {
+		t.Errorf("expected reopen after %s, got %s", CapacityRetryAfter, d.ReopenAt.Sub(now))
+	}
+}
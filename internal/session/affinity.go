@@ -0,0 +1,31 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Delivery is a frame addressed to a session hosted on another wsserver. It
+// is published on that server's server.<name>.send subject, and the hosting
+// server writes Data to the connection, closing it afterwards if Disconnect
+// is set, with CloseCode and CloseReason in the close frame.
+type Delivery struct {
+	SessionID   string          `json:"session_id"`
+	Data        json.RawMessage `json:"data"`
+	Disconnect  bool            `json:"disconnect,omitempty"`
+	CloseCode   uint16          `json:"close_code,omitempty"` // WebSocket close code; 0 means 1000
+	CloseReason string          `json:"close_reason,omitempty"`
+}
+
+// ServerOf returns the name of the wsserver hosting a session, or "" if the
+// session does not exist.
+func (s *Store) ServerOf(ctx context.Context, sessionID string) (string, error) {
+	server, err := s.client.HGet(ctx, SessionPrefix+sessionID, "server").Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return server, err
+}
@@ -0,0 +1,27 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestStore_ServerOf(t *testing.T) {
+	client := testutil.Redis(t)
+	ctx := context.Background()
+
+	s := &Store{client: client, serverName: "ws-2", region: "eu-west"}
+	if _, err := s.Create(ctx, "sess-1"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got, err := s.ServerOf(ctx, "sess-1"); err != nil || got != "ws-2" {
+		t.Fatalf("ServerOf(sess-1) = %q, %v; want ws-2", got, err)
+	}
+	if got, err := s.ServerOf(ctx, "missing"); err != nil || got != "" {
+		t.Fatalf("ServerOf(missing) = %q, %v; want empty", got, err)
+	}
+	if sess, _ := s.Get(ctx, "sess-1"); sess == nil || sess.Region != "eu-west" {
+		t.Fatalf("expected region eu-west on the session, got %+v", sess)
+	}
+}
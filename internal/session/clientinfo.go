@@ -0,0 +1,242 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Platforms a client may declare in client_info. Anything else is recorded
+// as PlatformOther.
+const (
+	PlatformWeb     = "web"
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformDesktop = "desktop"
+	PlatformOther   = "other"
+)
+
+const (
+	MaxAppVersionBytes = 32
+	MaxLocaleBytes     = 35
+)
+
+// ClientInfo describes the app a session is connected with, as declared by
+// the client. It is informational: it helps correlate bug reports and lets
+// protocol features be gated by app version, but is never trusted for abuse
+// decisions.
+type ClientInfo struct {
+	Platform   string `json:"platform"`
+	AppVersion string `json:"app_version"`
+	Locale     string `json:"locale"`
+}
+
+// NormalizeClientInfo checks a client_info payload and returns it in
+// canonical form: the platform lower-cased (PlatformOther if unknown), the
+// version without a leading "v" and the locale as "ll" or "ll-RR". The
+// version must be dotted numbers with an optional "-" or "+" suffix, as in
+// "2.4.1" or "2.5.0-beta.1". Empty fields stay empty.
+func NormalizeClientInfo(platform, appVersion, locale string) (ClientInfo, error) {
+	info := ClientInfo{Platform: strings.ToLower(strings.TrimSpace(platform))}
+	switch info.Platform {
+	case "", PlatformWeb, PlatformIOS, PlatformAndroid, PlatformDesktop:
+	default:
+		info.Platform = PlatformOther
+	}
+
+	if v := strings.TrimPrefix(strings.TrimSpace(appVersion), "v"); v != "" {
+		if len(v) > MaxAppVersionBytes {
+			return ClientInfo{}, fmt.Errorf("app_version is too long")
+		}
+		if _, ok := parseVersion(v); !ok {
+			return ClientInfo{}, fmt.Errorf("app_version %q is not a version number", appVersion)
+		}
+		info.AppVersion = v
+	}
+
+	if l := strings.TrimSpace(locale); l != "" {
+		norm, ok := normalizeLocale(l)
+		if !ok {
+			return ClientInfo{}, fmt.Errorf("locale %q is not a language tag", locale)
+		}
+		info.Locale = norm
+	}
+	return info, nil
+}
+
+// VersionLabel reduces an app version to "major.minor" for use as a metric
+// label, so patch releases and pre-release builds do not multiply series.
+// The version is client input, so only the releases in known (as returned
+// by ParseKnownVersions) get a label of their own; the rest are "other",
+// and versions that do not parse are "unknown".
+func VersionLabel(appVersion string, known []string) string {
+	parts, ok := parseVersion(appVersion)
+	if !ok {
+		return "unknown"
+	}
+	label := fmt.Sprintf("%d.%d", parts[0], parts[1])
+	if !slices.Contains(known, label) {
+		return "other"
+	}
+	return label
+}
+
+// ParseKnownVersions parses a comma-separated list of released app versions
+// into the "major.minor" labels VersionLabel reports.
+func ParseKnownVersions(list string) ([]string, error) {
+	var known []string
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+		if v == "" {
+			continue
+		}
+		parts, ok := parseVersion(v)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a version number", v)
+		}
+		known = append(known, fmt.Sprintf("%d.%d", parts[0], parts[1]))
+	}
+	return known, nil
+}
+
+// ValidVersion reports whether v is an app version as accepted in
+// client_info.
+func ValidVersion(v string) bool {
+	_, ok := parseVersion(v)
+	return ok
+}
+
+// CompareVersions compares two app versions by their numeric parts, ignoring
+// pre-release and build suffixes. It returns -1, 0 or 1. A version that does
+// not parse sorts before every version that does.
+func CompareVersions(a, b string) int {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// ClientVersionAtLeast reports whether the session declared an app version
+// of at least min. Sessions that declared none are treated as older than
+// every version.
+func (s *Session) ClientVersionAtLeast(min string) bool {
+	return s.AppVersion != "" && CompareVersions(s.AppVersion, min) >= 0
+}
+
+// SetClientInfo stores what the client declared about itself and reports
+// whether it is the session's first declaration. It returns ErrNotFound if
+// the session has expired, rather than recreating it without a TTL.
+func (s *Store) SetClientInfo(ctx context.Context, sessionID string, info ClientInfo) (bool, error) {
+	res, err := setClientInfoScript.Run(ctx, s.client, []string{SessionPrefix + sessionID},
+		info.Platform, info.AppVersion, info.Locale,
+	).Int()
+	if err != nil {
+		return false, fmt.Errorf("session: set client info: %w", err)
+	}
+	if res < 0 {
+		return false, ErrNotFound
+	}
+	return res == 1, nil
+}
+
+var setClientInfoScript = redis.NewScript(setClientInfoLua)
+
+// setClientInfoLua stores platform ARGV[1], app_version ARGV[2] and locale
+// ARGV[3] on the session hash KEYS[1] if it exists. It returns -1 if it does
+// not, 1 if the session had not declared its client before (app_version is
+// only ever written here) and 0 otherwise.
+const setClientInfoLua = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+    return -1
+end
+local first = redis.call('HEXISTS', KEYS[1], 'app_version') == 0
+redis.call('HSET', KEYS[1], 'platform', ARGV[1], 'app_version', ARGV[2], 'locale', ARGV[3])
+if first then
+    return 1
+end
+return 0
+`
+
+// parseVersion parses up to three dot-separated numbers, missing ones being
+// zero, followed by an optional "-" or "+" suffix of letters, digits, dots
+// and hyphens.
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	core, suffix := v, ""
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		core, suffix = v[:i], v[i+1:]
+		if suffix == "" {
+			return parts, false
+		}
+	}
+	for _, r := range suffix {
+		if !isAlnum(r) && r != '.' && r != '-' && r != '+' {
+			return parts, false
+		}
+	}
+	fields := strings.Split(core, ".")
+	if len(fields) > len(parts) {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// normalizeLocale accepts a language tag such as "en", "pt_BR" or
+// "zh-Hant-TW" and returns it hyphenated, with the language lower-cased and
+// a two-letter region upper-cased.
+func normalizeLocale(tag string) (string, bool) {
+	if len(tag) > MaxLocaleBytes {
+		return "", false
+	}
+	subtags := strings.Split(strings.ReplaceAll(tag, "_", "-"), "-")
+	for i, st := range subtags {
+		if st == "" || len(st) > 8 {
+			return "", false
+		}
+		for _, r := range st {
+			if !isAlnum(r) || (i == 0 && r <= '9') {
+				return "", false
+			}
+		}
+		switch {
+		case i == 0:
+			if len(st) < 2 || len(st) > 3 {
+				return "", false
+			}
+			subtags[i] = strings.ToLower(st)
+		case len(st) == 2:
+			subtags[i] = strings.ToUpper(st)
+		}
+	}
+	return strings.Join(subtags, "-"), true
+}
+
+func isAlnum(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
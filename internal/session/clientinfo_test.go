@@ -0,0 +1,138 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestNormalizeClientInfo(t *testing.T) {
+	tests := []struct {
+		platform, version, locale string
+		want                      ClientInfo
+		wantErr                   bool
+	}{
+		{"web", "2.4.1", "en-US", ClientInfo{"web", "2.4.1", "en-US"}, false},
+		{" iOS ", "v3.0", "pt_br", ClientInfo{"ios", "3.0", "pt-BR"}, false},
+		{"smart-fridge", "1", "zh-Hant-TW", ClientInfo{"other", "1", "zh-Hant-TW"}, false},
+		{"android", "2.5.0-beta.1", "", ClientInfo{"android", "2.5.0-beta.1", ""}, false},
+		{"", "", "", ClientInfo{}, false},
+		{"web", "latest", "en", ClientInfo{}, true},
+		{"web", "1.2.3.4", "en", ClientInfo{}, true},
+		{"web", "1.2-", "en", ClientInfo{}, true},
+		{"web", "1.2.3-beta 1", "en", ClientInfo{}, true},
+		{"web", "1.0", "english please", ClientInfo{}, true},
+		{"web", "1.0", "e", ClientInfo{}, true},
+		{"web", "1.0", "12-US", ClientInfo{}, true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeClientInfo(tt.platform, tt.version, tt.locale)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeClientInfo(%q, %q, %q) error = %v, want error %v", tt.platform, tt.version, tt.locale, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeClientInfo(%q, %q, %q) = %+v, want %+v", tt.platform, tt.version, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestVersionLabel(t *testing.T) {
+	known := []string{"2.0", "2.4", "2.5"}
+	tests := map[string]string{
+		"2.4.1":        "2.4",
+		"2":            "2.0",
+		"2.5.0-beta.1": "2.5",
+		"99999.1":      "other",
+		"2.6":          "other",
+		"":             "unknown",
+		"nightly":      "unknown",
+	}
+	for in, want := range tests {
+		if got := VersionLabel(in, known); got != want {
+			t.Errorf("VersionLabel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseKnownVersions(t *testing.T) {
+	got, err := ParseKnownVersions(" 2.4.1, v2.5 ,,3")
+	if err != nil {
+		t.Fatalf("ParseKnownVersions: %v", err)
+	}
+	if want := []string{"2.4", "2.5", "3.0"}; !slices.Equal(got, want) {
+		t.Errorf("ParseKnownVersions = %v, want %v", got, want)
+	}
+	if _, err := ParseKnownVersions("2.4,latest"); err == nil {
+		t.Error("expected an error for a list with a non-version")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2.4.1", "2.4.1", 0},
+		{"2.4", "2.4.0", 0},
+		{"2.10", "2.9", 1},
+		{"1.9.9", "2", -1},
+		{"2.5.0-beta.1", "2.5.0", 0},
+		{"junk", "0.0.1", -1},
+		{"0.0.1", "junk", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	if (&Session{}).ClientVersionAtLeast("0.0.1") {
+		t.Error("a session without a declared version passed a version gate")
+	}
+	if !(&Session{AppVersion: "2.5.0"}).ClientVersionAtLeast("2.5") {
+		t.Error("2.5.0 did not pass a 2.5 gate")
+	}
+}
+
+func TestStore_SetClientInfo(t *testing.T) {
+	client := testutil.Redis(t)
+	ctx := context.Background()
+
+	s := &Store{client: client, serverName: "ws-1"}
+	if _, err := s.Create(ctx, "s1"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	info := ClientInfo{Platform: PlatformIOS, AppVersion: "3.1.0", Locale: "de-DE"}
+	if first, err := s.SetClientInfo(ctx, "s1", ClientInfo{Platform: PlatformIOS}); err != nil || !first {
+		t.Fatalf("first SetClientInfo = %v, %v; want true", first, err)
+	}
+	if first, err := s.SetClientInfo(ctx, "s1", info); err != nil || first {
+		t.Fatalf("second SetClientInfo = %v, %v; want false", first, err)
+	}
+
+	sess, err := s.Get(ctx, "s1")
+	if err != nil || sess == nil {
+		t.Fatalf("Get = %v, %v", sess, err)
+	}
+	if got := (ClientInfo{sess.Platform, sess.AppVersion, sess.Locale}); got != info {
+		t.Errorf("stored %+v, want %+v", got, info)
+	}
+}
+
+func TestStore_SetClientInfoExpiredSession(t *testing.T) {
+	client := testutil.Redis(t)
+	ctx := context.Background()
+
+	s := &Store{client: client, serverName: "ws-1"}
+	_, err := s.SetClientInfo(ctx, "gone", ClientInfo{Platform: PlatformWeb, AppVersion: "1.0"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("SetClientInfo on a missing session = %v, want ErrNotFound", err)
+	}
+	if n := client.Exists(ctx, SessionPrefix+"gone").Val(); n != 0 {
+		t.Error("SetClientInfo recreated an expired session")
+	}
+}
@@ -0,0 +1,93 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// expiryClaimPrefix marks an expired session whose cluster-wide cleanup
+	// has been claimed by one wsserver. It deliberately does not start with
+	// SessionPrefix so the claim's own expiry is not mistaken for a session.
+	expiryClaimPrefix = "session_expired:"
+
+	// expiryClaimTTL only needs to outlive the notification fan-out.
+	expiryClaimTTL = 5 * time.Minute
+)
+
+// ExpiryHandler is called for every session key that expired in Redis.
+// claimed is true on exactly one subscriber per session, which should do the
+// cleanup that affects other users (dequeue, partner_left, chat delete);
+// every subscriber may still release state it holds locally.
+type ExpiryHandler func(ctx context.Context, sessionID string, claimed bool)
+
+// ExpiryWatcher reports session keys that expired on their TTL, using Redis
+// keyspace notifications. Explicit deletes are not reported; those go
+// through the normal disconnect path.
+type ExpiryWatcher struct {
+	client *redis.Client
+}
+
+// NewExpiryWatcher creates a watcher on the given Redis client.
+func NewExpiryWatcher(client *redis.Client) *ExpiryWatcher {
+	return &ExpiryWatcher{client: client}
+}
+
+// EnableNotifications makes sure Redis publishes expired-key events
+// (notify-keyspace-events must include "E" and "x"). Managed Redis services
+// often disable CONFIG; in that case the setting has to be applied by the
+// operator and the error can be logged and ignored.
+func (w *ExpiryWatcher) EnableNotifications(ctx context.Context) error {
+	cfg, err := w.client.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return fmt.Errorf("session: read notify-keyspace-events: %w", err)
+	}
+	current := cfg["notify-keyspace-events"]
+	if strings.Contains(current, "E") && (strings.Contains(current, "x") || strings.Contains(current, "A")) {
+		return nil
+	}
+	flags := current
+	if !strings.Contains(flags, "E") {
+		flags += "E"
+	}
+	flags += "x"
+	if err := w.client.ConfigSet(ctx, "notify-keyspace-events", flags).Err(); err != nil {
+		return fmt.Errorf("session: set notify-keyspace-events: %w", err)
+	}
+	return nil
+}
+
+// Run subscribes to expired-key events and calls handle for every expired
+// session until ctx is cancelled. Each session is claimed with SET NX so
+// only one subscriber cluster-wide gets claimed=true.
+func (w *ExpiryWatcher) Run(ctx context.Context, handle ExpiryHandler) {
+	channel := fmt.Sprintf("__keyevent@%d__:expired", w.client.Options().DB)
+	pubsub := w.client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			sessionID, ok := strings.CutPrefix(msg.Payload, SessionPrefix)
+			if !ok || sessionID == "" {
+				continue
+			}
+			claimed, err := w.client.SetNX(ctx, expiryClaimPrefix+sessionID, 1, expiryClaimTTL).Result()
+			if err != nil {
+				log.Printf("session: claim expired session %s: %v", sessionID, err)
+			}
+			handle(ctx, sessionID, claimed)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestExpiryWatcher_ClaimsOnce(t *testing.T) {
+	client := testutil.Redis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The setting is server-wide; put it back for whoever else uses the
+	// server.
+	cfg, err := client.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		t.Skipf("cannot read keyspace notifications: %v", err)
+	}
+	t.Cleanup(func() {
+		client.ConfigSet(context.Background(), "notify-keyspace-events", cfg["notify-keyspace-events"])
+	})
+	w := NewExpiryWatcher(client)
+	if err := w.EnableNotifications(ctx); err != nil {
+		t.Skipf("cannot enable keyspace notifications: %v", err)
+	}
+
+	type event struct {
+		sid     string
+		claimed bool
+	}
+	events := make(chan event, 4)
+	for i := 0; i < 2; i++ {
+		go w.Run(ctx, func(_ context.Context, sid string, claimed bool) {
+			events <- event{sid, claimed}
+		})
+	}
+	time.Sleep(100 * time.Millisecond) // let both subscriptions register
+
+	client.Set(ctx, "unrelated:key", 1, 50*time.Millisecond)
+	client.HSet(ctx, SessionPrefix+"expiring", "status", StatusChatting)
+	client.PExpire(ctx, SessionPrefix+"expiring", 50*time.Millisecond)
+
+	claims := 0
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-events:
+			if ev.sid != "expiring" {
+				t.Fatalf("unexpected session %q", ev.sid)
+			}
+			if ev.claimed {
+				claims++
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("expiry was not reported to both watchers")
+		}
+	}
+	if claims != 1 {
+		t.Errorf("expected exactly one claim, got %d", claims)
+	}
+	client.FlushDB(ctx)
+}
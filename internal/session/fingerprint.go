@@ -0,0 +1,58 @@
+package session
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FingerprintSessionsPrefix is the Redis key prefix for the set of sessions
+// claimed by a browser fingerprint: fp_sessions:<fingerprint>.
+const FingerprintSessionsPrefix = "fp_sessions:"
+
+// ClaimFingerprint adds sessionID to the sessions of fingerprint unless the
+// fingerprint already has limit live sessions, in which case it returns
+// false. Members whose session is gone or whose server has stopped
+// heart-beating are pruned first, so a crashed server does not lock users
+// out. Claiming again for a session that already holds a claim succeeds.
+func (s *Store) ClaimFingerprint(ctx context.Context, sessionID, fingerprint string, limit int) (bool, error) {
+	n, err := claimFingerprintScript.Run(ctx, s.client, []string{FingerprintSessionsPrefix + fingerprint},
+		sessionID, limit, int(SessionTTL.Seconds()), SessionPrefix, ServerAlivePrefix).Int()
+	if err != nil {
+		return false, fmt.Errorf("session: claim fingerprint: %w", err)
+	}
+	return n == 1, nil
+}
+
+// ReleaseFingerprint removes sessionID from the sessions of fingerprint.
+func (s *Store) ReleaseFingerprint(ctx context.Context, sessionID, fingerprint string) error {
+	return s.client.SRem(ctx, FingerprintSessionsPrefix+fingerprint, sessionID).Err()
+}
+
+var claimFingerprintScript = redis.NewScript(claimFingerprintLua)
+
+// claimFingerprintLua counts the live sessions in the set KEYS[1] other than
+// ARGV[1], pruning dead ones, and adds ARGV[1] if fewer than ARGV[2] remain.
+// A session is live while its hash (ARGV[4] prefix) exists and names a
+// server whose liveness key (ARGV[5] prefix) exists. The set expires ARGV[3]
+// seconds after the last claim, like the sessions in it.
+const claimFingerprintLua = `
+local live = 0
+for _, sid in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+    if sid ~= ARGV[1] then
+        local server = redis.call('HGET', ARGV[4] .. sid, 'server')
+        if server and redis.call('EXISTS', ARGV[5] .. server) == 1 then
+            live = live + 1
+        else
+            redis.call('SREM', KEYS[1], sid)
+        end
+    end
+end
+if live >= tonumber(ARGV[2]) then
+    return 0
+end
+redis.call('SADD', KEYS[1], ARGV[1])
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+return 1
+`
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestStore_ClaimFingerprint(t *testing.T) {
+	client := testutil.Redis(t)
+	ctx := context.Background()
+
+	s := &Store{client: client, serverName: "ws-1"}
+	client.Set(ctx, ServerAlivePrefix+"ws-1", 1, ServerAliveTTL)
+	for _, sid := range []string{"s1", "s2", "s3"} {
+		if _, err := s.Create(ctx, sid); err != nil {
+			t.Fatalf("Create(%s): %v", sid, err)
+		}
+	}
+
+	claim := func(sid string) bool {
+		t.Helper()
+		ok, err := s.ClaimFingerprint(ctx, sid, "fp-1", 2)
+		if err != nil {
+			t.Fatalf("ClaimFingerprint(%s): %v", sid, err)
+		}
+		return ok
+	}
+	if !claim("s1") || !claim("s2") {
+		t.Fatal("expected the first two sessions to be admitted")
+	}
+	if claim("s3") {
+		t.Fatal("expected the third session to be rejected at the limit")
+	}
+	if !claim("s1") {
+		t.Fatal("expected a repeated claim by an admitted session to succeed")
+	}
+
+	if err := s.ReleaseFingerprint(ctx, "s2", "fp-1"); err != nil {
+		t.Fatalf("ReleaseFingerprint: %v", err)
+	}
+	if !claim("s3") {
+		t.Fatal("expected a session to be admitted after a release")
+	}
+}
+
+func TestStore_ClaimFingerprintPrunesDeadSessions(t *testing.T) {
+	client := testutil.Redis(t)
+	ctx := context.Background()
+
+	dead := &Store{client: client, serverName: "ws-dead"} // no liveness key
+	live := &Store{client: client, serverName: "ws-1"}
+	client.Set(ctx, ServerAlivePrefix+"ws-1", 1, ServerAliveTTL)
+
+	_, _ = dead.Create(ctx, "orphan")
+	client.SAdd(ctx, FingerprintSessionsPrefix+"fp-1", "orphan", "deleted")
+	_, _ = live.Create(ctx, "fresh")
+
+	ok, err := live.ClaimFingerprint(ctx, "fresh", "fp-1", 1)
+	if err != nil || !ok {
+		t.Fatalf("ClaimFingerprint = %v, %v; want sessions on dead servers and deleted sessions ignored", ok, err)
+	}
+	if members := client.SMembers(ctx, FingerprintSessionsPrefix+"fp-1").Val(); len(members) != 1 || members[0] != "fresh" {
+		t.Errorf("expected only the new session to remain, got %v", members)
+	}
+}
@@ -0,0 +1,164 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Session handoff keeps a chatting session alive through a dropped
+// connection, such as a mobile app being backgrounded. Instead of being
+// cleaned up the session is detached with a short TTL. The client can keep
+// it alive with Heartbeat and take it back over from a new connection with
+// Attach, presenting the token it was given at Create. A detached session
+// that is not taken back expires, and expiry cleanup ends its chat.
+
+var (
+	// ErrNotFound is returned for a session that does not exist or expired.
+	ErrNotFound = errors.New("session: not found")
+	// ErrInvalidToken is returned when the token does not match the session.
+	ErrInvalidToken = errors.New("session: invalid token")
+	// ErrAttached is returned by Attach while the session still has a live
+	// connection.
+	ErrAttached = errors.New("session: still attached to a connection")
+)
+
+// tokenBytes is the number of random bytes in a session token.
+const tokenBytes = 16
+
+// newToken returns a random hex session token.
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("session: generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Detach marks the session as held by no connection and lets it expire
+// after window unless Heartbeat or Attach is called first.
+func (s *Store) Detach(ctx context.Context, sessionID string, window time.Duration) error {
+	key := SessionPrefix + sessionID
+	pipe := s.client.Pipeline()
+	pipe.HSet(ctx, key, "detached_at", time.Now().Unix())
+	pipe.Expire(ctx, key, window)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Heartbeat keeps a session alive without a connection. A detached session
+// gets another window before it expires; an attached one gets the full
+// SessionTTL. It returns the session after the refresh.
+func (s *Store) Heartbeat(ctx context.Context, sessionID, token string, window time.Duration) (*Session, error) {
+	code, err := heartbeatScript.Run(ctx, s.client, []string{SessionPrefix + sessionID},
+		token, int(window.Seconds()), int(SessionTTL.Seconds())).Int()
+	if err != nil {
+		return nil, fmt.Errorf("session: heartbeat: %w", err)
+	}
+	if err := handoffError(code); err != nil {
+		return nil, err
+	}
+	sess, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess == nil {
+		return nil, ErrNotFound
+	}
+	return sess, nil
+}
+
+// Attach hands a detached session to a new connection on this server. The
+// token must match and the session must be detached; the session is then
+// recorded on this server with the full SessionTTL. It returns the session.
+func (s *Store) Attach(ctx context.Context, sessionID, token string) (*Session, error) {
+	code, err := attachScript.Run(ctx, s.client, []string{SessionPrefix + sessionID},
+		token, s.serverName, s.region, time.Now().Unix(), int(SessionTTL.Seconds())).Int()
+	if err != nil {
+		return nil, fmt.Errorf("session: attach: %w", err)
+	}
+	if err := handoffError(code); err != nil {
+		return nil, err
+	}
+	sess, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess == nil {
+		return nil, ErrNotFound
+	}
+	return sess, nil
+}
+
+// Authenticate returns the session if token is the one it was given at
+// Create, so an HTTP request can prove it speaks for the session. It
+// returns ErrNotFound if the session expired and ErrInvalidToken on a
+// token mismatch.
+func (s *Store) Authenticate(ctx context.Context, sessionID, token string) (*Session, error) {
+	sess, err := s.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess == nil {
+		return nil, ErrNotFound
+	}
+	if sess.Token == "" || subtle.ConstantTimeCompare([]byte(sess.Token), []byte(token)) != 1 {
+		return nil, ErrInvalidToken
+	}
+	return sess, nil
+}
+
+// handoffError maps the status codes of the handoff scripts to errors.
+func handoffError(code int) error {
+	switch code {
+	case -1:
+		return ErrNotFound
+	case -2:
+		return ErrInvalidToken
+	case -3:
+		return ErrAttached
+	}
+	return nil
+}
+
+var (
+	heartbeatScript = redis.NewScript(heartbeatLua)
+	attachScript    = redis.NewScript(attachLua)
+)
+
+// heartbeatLua refreshes the session hash KEYS[1] if its token is ARGV[1]:
+// to ARGV[2] seconds while detached, ARGV[3] seconds otherwise. It returns
+// 1, -1 if the session is gone or -2 on a token mismatch.
+const heartbeatLua = `
+local token = redis.call('HGET', KEYS[1], 'token')
+if not token then return -1 end
+if token == '' or token ~= ARGV[1] then return -2 end
+local detached = tonumber(redis.call('HGET', KEYS[1], 'detached_at') or '0') or 0
+if detached > 0 then
+    redis.call('EXPIRE', KEYS[1], ARGV[2])
+else
+    redis.call('EXPIRE', KEYS[1], ARGV[3])
+end
+return 1
+`
+
+// attachLua moves the detached session hash KEYS[1] with token ARGV[1] to
+// server ARGV[2] in region ARGV[3] at unix time ARGV[4], restoring its TTL
+// to ARGV[5] seconds. It returns 1, -1 if the session is gone, -2 on a
+// token mismatch or -3 if the session is not detached.
+const attachLua = `
+local token = redis.call('HGET', KEYS[1], 'token')
+if not token then return -1 end
+if token == '' or token ~= ARGV[1] then return -2 end
+local detached = tonumber(redis.call('HGET', KEYS[1], 'detached_at') or '0') or 0
+if detached == 0 then return -3 end
+redis.call('HSET', KEYS[1], 'detached_at', 0, 'server', ARGV[2], 'region', ARGV[3], 'last_active', ARGV[4])
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+return 1
+`
@@ -0,0 +1,106 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// maxHeartbeatBytes bounds the body of a session heartbeat.
+const maxHeartbeatBytes = 4 << 10
+
+// heartbeatRequest is the body accepted by the heartbeat endpoint.
+type heartbeatRequest struct {
+	SessionID    string `json:"session_id"`
+	SessionToken string `json:"session_token"`
+}
+
+// heartbeatResponse tells the client what it is holding on to. ExpiresIn is
+// the seconds left before a detached session expires, 0 while attached.
+type heartbeatResponse struct {
+	Status    string `json:"status"`
+	ChatID    string `json:"chat_id,omitempty"`
+	Detached  bool   `json:"detached"`
+	ExpiresIn int    `json:"expires_in,omitempty"`
+}
+
+// HeartbeatHandler serves the public session heartbeat endpoint:
+//
+//	POST /api/session/heartbeat  {"session_id", "session_token"} keep the session alive
+//
+// A backgrounded app calls it to keep a detached session, and the chat it
+// is in, from expiring until it reconnects with ?resume=. The response is
+// 200 with {"status", "chat_id", "detached", "expires_in"}, 404 once the
+// session has expired and 403 if the token does not match.
+type HeartbeatHandler struct {
+	store  *Store
+	window time.Duration
+}
+
+// NewHeartbeatHandler creates the heartbeat endpoint. Each heartbeat gives a
+// detached session another window before it expires.
+func NewHeartbeatHandler(store *Store, window time.Duration) *HeartbeatHandler {
+	return &HeartbeatHandler{store: store, window: window}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *HeartbeatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req heartbeatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHeartbeatBytes)).Decode(&req); err != nil ||
+		req.SessionID == "" || req.SessionToken == "" {
+		writeError(w, http.StatusBadRequest, "session_id and session_token are required")
+		return
+	}
+
+	sess, err := h.store.Heartbeat(r.Context(), req.SessionID, req.SessionToken, h.window)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "session expired")
+		return
+	case errors.Is(err, ErrInvalidToken):
+		writeError(w, http.StatusForbidden, "invalid session token")
+		return
+	case err != nil:
+		log.Printf("[handoff] heartbeat session=%s: %v", req.SessionID, err)
+		writeError(w, http.StatusInternalServerError, "heartbeat failed")
+		return
+	}
+
+	resp := heartbeatResponse{Status: sess.Status, ChatID: sess.ChatID, Detached: sess.DetachedAt > 0}
+	if resp.Detached {
+		resp.ExpiresIn = int(h.window.Seconds())
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[handoff] encode response: %v", err)
+	}
+}
+
+// writeError sends a JSON error body of the form {"error": "..."}.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{Error: message})
+}
@@ -0,0 +1,119 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestStore_DetachHeartbeatAttach(t *testing.T) {
+	client := testutil.Redis(t)
+	ctx := context.Background()
+	old := &Store{client: client, serverName: "ws-1"}
+	next := &Store{client: client, serverName: "ws-2", region: "eu"}
+
+	token, err := old.Create(ctx, "sess-1")
+	if err != nil || token == "" {
+		t.Fatalf("Create: token=%q err=%v", token, err)
+	}
+	if _, err := next.Attach(ctx, "sess-1", token); !errors.Is(err, ErrAttached) {
+		t.Fatalf("Attach while attached: err=%v, want ErrAttached", err)
+	}
+
+	window := 2 * time.Minute
+	if err := old.Detach(ctx, "sess-1", window); err != nil {
+		t.Fatalf("Detach: %v", err)
+	}
+	if ttl := client.TTL(ctx, SessionPrefix+"sess-1").Val(); ttl > window {
+		t.Errorf("detached TTL = %s, want at most %s", ttl, window)
+	}
+
+	if _, err := next.Heartbeat(ctx, "sess-1", "wrong", window); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Heartbeat with wrong token: err=%v, want ErrInvalidToken", err)
+	}
+	sess, err := next.Heartbeat(ctx, "sess-1", token, window)
+	if err != nil || sess.DetachedAt == 0 {
+		t.Fatalf("Heartbeat: sess=%+v err=%v", sess, err)
+	}
+
+	if _, err := next.Attach(ctx, "sess-1", "wrong"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Attach with wrong token: err=%v, want ErrInvalidToken", err)
+	}
+	sess, err = next.Attach(ctx, "sess-1", token)
+	if err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	if sess.Server != "ws-2" || sess.Region != "eu" || sess.DetachedAt != 0 {
+		t.Errorf("attached session = %+v, want server ws-2 region eu", sess)
+	}
+	if ttl := client.TTL(ctx, SessionPrefix+"sess-1").Val(); ttl <= window {
+		t.Errorf("attached TTL = %s, want the full session TTL", ttl)
+	}
+	if _, err := next.Attach(ctx, "sess-1", token); !errors.Is(err, ErrAttached) {
+		t.Errorf("second Attach: err=%v, want ErrAttached", err)
+	}
+	if _, err := next.Attach(ctx, "missing", token); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Attach missing: err=%v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_Authenticate(t *testing.T) {
+	s := &Store{client: testutil.Redis(t), serverName: "ws-1"}
+	ctx := context.Background()
+	token, err := s.Create(ctx, "sess-1")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if sess, err := s.Authenticate(ctx, "sess-1", token); err != nil || sess.ID != "sess-1" {
+		t.Errorf("Authenticate = %+v, %v; want the session", sess, err)
+	}
+	if _, err := s.Authenticate(ctx, "sess-1", "wrong"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Authenticate with wrong token: err=%v, want ErrInvalidToken", err)
+	}
+	if _, err := s.Authenticate(ctx, "sess-gone", token); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Authenticate unknown session: err=%v, want ErrNotFound", err)
+	}
+}
+
+func TestHeartbeatHandler(t *testing.T) {
+	client := testutil.Redis(t)
+	ctx := context.Background()
+	s := &Store{client: client, serverName: "ws-1"}
+	token, err := s.Create(ctx, "sess-1")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	_ = s.SetChatID(ctx, "sess-1", "chat-1")
+	_ = s.Detach(ctx, "sess-1", time.Minute)
+	h := NewHeartbeatHandler(s, time.Minute)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"ok", `{"session_id":"sess-1","session_token":"` + token + `"}`, http.StatusOK},
+		{"wrong token", `{"session_id":"sess-1","session_token":"nope"}`, http.StatusForbidden},
+		{"expired", `{"session_id":"gone","session_token":"` + token + `"}`, http.StatusNotFound},
+		{"missing token", `{"session_id":"sess-1"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/session/heartbeat", strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusOK && !strings.Contains(rec.Body.String(), `"chat_id":"chat-1"`) {
+				t.Errorf("expected the chat in the response, got %s", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// ServerAlivePrefix is the Redis key prefix for wsserver liveness keys.
+	// Each running wsserver keeps server:alive:<server_name> refreshed; the key
+	// disappears ServerAliveTTL after the server stops heart-beating.
+	ServerAlivePrefix = "server:alive:"
+
+	// ServerAliveTTL is how long a liveness key survives without a refresh.
+	ServerAliveTTL = 15 * time.Second
+
+	// serverHeartbeatInterval is how often a wsserver refreshes its key.
+	serverHeartbeatInterval = 5 * time.Second
+)
+
+// ServerName returns the identifier of the WS server instance that owns
+// sessions created by this store.
+func (s *Store) ServerName() string {
+	return s.serverName
+}
+
+// StartServerHeartbeat keeps this server's liveness key refreshed until ctx
+// is cancelled, at which point the key is deleted so other services notice
+// the shutdown immediately. It returns after the first heartbeat is written.
+func (s *Store) StartServerHeartbeat(ctx context.Context) {
+	key := ServerAlivePrefix + s.serverName
+	beat := func() {
+		if err := s.client.Set(ctx, key, time.Now().Unix(), ServerAliveTTL).Err(); err != nil && ctx.Err() == nil {
+			log.Printf("session: server heartbeat failed for %s: %v", s.serverName, err)
+		}
+	}
+
+	beat()
+	go func() {
+		ticker := time.NewTicker(serverHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				delCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				_ = s.client.Del(delCtx, key).Err()
+				cancel()
+				return
+			case <-ticker.C:
+				beat()
+			}
+		}
+	}()
+}
+
+// ServerAlive reports whether the named wsserver has refreshed its liveness
+// key within ServerAliveTTL.
+func ServerAlive(ctx context.Context, client *redis.Client, serverName string) (bool, error) {
+	n, err := client.Exists(ctx, ServerAlivePrefix+serverName).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -15,6 +15,10 @@ const (
 	// SessionTTL is the time-to-live for session keys in Redis.
 	SessionTTL = 1 * time.Hour
 
+	// AppealWindow is how long a banned user's session is kept after the
+	// ban closed its connection, so the ban can be appealed with the token.
+	AppealWindow = 30 * time.Minute
+
 	// Status constants for the session state machine.
 	StatusIdle     = "idle"
 	StatusMatching = "matching"
@@ -27,16 +31,27 @@ type Session struct {
 	Status      string `redis:"status"`      // idle | matching | chatting
 	ChatID      string `redis:"chat_id"`     // empty if not in chat
 	Server      string `redis:"server"`      // which WS server instance
+	Region      string `redis:"region"`      // server region, empty if unpartitioned
 	Interests   string `redis:"interests"`   // comma-separated
 	Fingerprint string `redis:"fingerprint"` // browser fingerprint hash
+	Language    string `redis:"language"`    // declared language for translation, empty if not opted in
+	Policy      string `redis:"policy"`      // content policy preference from find_match, empty if none
 	CreatedAt   int64  `redis:"created_at"`  // unix timestamp
 	LastActive  int64  `redis:"last_active"` // unix timestamp
+	Token       string `redis:"token"`       // secret proving ownership, for handoff
+	DetachedAt  int64  `redis:"detached_at"` // unix time the connection was lost; 0 while attached
+	Platform    string `redis:"platform"`    // declared in client_info, empty if not sent
+	AppVersion  string `redis:"app_version"` // declared in client_info, empty if not sent
+	Locale      string `redis:"locale"`      // declared in client_info, empty if not sent
+	Country     string `redis:"country"`     // ISO country of the client IP from GeoIP, empty if unknown
+	GeoRegion   string `redis:"geo_region"`  // ISO subdivision of the client IP from GeoIP, empty if unknown
 }
 
 // Store manages session state in Redis.
 type Store struct {
 	client     *redis.Client
 	serverName string // identifier for this WS server instance
+	region     string // region recorded on new sessions
 }
 
 // NewStore creates a new session store connected to Redis.
@@ -55,27 +70,49 @@ func NewStore(redisAddr string, serverName string) (*Store, error) {
 	return &Store{client: client, serverName: serverName}, nil
 }
 
-// Create stores a new session in Redis with idle status and 1h TTL.
-func (s *Store) Create(ctx context.Context, sessionID string) error {
+// NewStoreWithClient creates a session store on an existing Redis client.
+func NewStoreWithClient(client *redis.Client, serverName string) *Store {
+	return &Store{client: client, serverName: serverName}
+}
+
+// SetRegion sets the region recorded on sessions created by this store, so
+// servers in other regions can find where a chat partner is connected.
+func (s *Store) SetRegion(region string) {
+	s.region = region
+}
+
+// Create stores a new session in Redis with idle status and 1h TTL. It
+// returns the session token, which the client presents to take the session
+// over from a new connection (see Attach).
+func (s *Store) Create(ctx context.Context, sessionID string) (string, error) {
 	key := SessionPrefix + sessionID
 	now := time.Now().Unix()
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
 
 	session := map[string]interface{}{
 		"id":          sessionID,
 		"status":      StatusIdle,
 		"chat_id":     "",
 		"server":      s.serverName,
+		"region":      s.region,
 		"interests":   "",
 		"fingerprint": "",
 		"created_at":  now,
 		"last_active": now,
+		"token":       token,
+		"detached_at": 0,
 	}
 
 	pipe := s.client.Pipeline()
 	pipe.HSet(ctx, key, session)
 	pipe.Expire(ctx, key, SessionTTL)
-	_, err := pipe.Exec(ctx)
-	return err
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
 }
 
 // Get retrieves a session from Redis. Returns nil if not found.
@@ -108,6 +145,28 @@ func (s *Store) SetInterests(ctx context.Context, sessionID string, interests st
 	return s.client.HSet(ctx, key, "interests", interests, "last_active", time.Now().Unix()).Err()
 }
 
+// SetLanguage stores the language the user opted in to translation with.
+// An empty language opts out.
+func (s *Store) SetLanguage(ctx context.Context, sessionID string, language string) error {
+	key := SessionPrefix + sessionID
+	return s.client.HSet(ctx, key, "language", language).Err()
+}
+
+// SetLocation stores where the client IP is located, which the matcher
+// snapshots when the session is queued. It is set again when a session is
+// resumed from another address.
+func (s *Store) SetLocation(ctx context.Context, sessionID, country, region string) error {
+	key := SessionPrefix + sessionID
+	return s.client.HSet(ctx, key, "country", country, "geo_region", region).Err()
+}
+
+// SetPolicy stores the content policy the user asked for in find_match,
+// which the matcher snapshots when the session is queued.
+func (s *Store) SetPolicy(ctx context.Context, sessionID string, policy string) error {
+	key := SessionPrefix + sessionID
+	return s.client.HSet(ctx, key, "policy", policy).Err()
+}
+
 // SetChatID sets the active chat ID for the session and marks status as chatting.
 func (s *Store) SetChatID(ctx context.Context, sessionID string, chatID string) error {
 	key := SessionPrefix + sessionID
@@ -132,6 +191,13 @@ func (s *Store) RefreshTTL(ctx context.Context, sessionID string) error {
 	return s.client.Expire(ctx, key, SessionTTL).Err()
 }
 
+// Expire lets the session expire after ttl, e.g. to keep a closed session
+// for a while instead of deleting it.
+func (s *Store) Expire(ctx context.Context, sessionID string, ttl time.Duration) error {
+	key := SessionPrefix + sessionID
+	return s.client.Expire(ctx, key, ttl).Err()
+}
+
 // Delete removes a session from Redis.
 func (s *Store) Delete(ctx context.Context, sessionID string) error {
 	key := SessionPrefix + sessionID
@@ -0,0 +1,341 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/whisper/chat-app/internal/audit"
+)
+
+const (
+	// MaxDailyDays caps the range of days the stats endpoints return.
+	MaxDailyDays = 366
+
+	// BackfillDays is how far back Refresh aggregates days that have no
+	// summary yet, e.g. after the job was first deployed. Older days are
+	// left alone: their reports may already have been deleted by retention.
+	BackfillDays = 7
+
+	// DefaultDailyDays is the range the stats endpoints return without a
+	// days parameter.
+	DefaultDailyDays = 30
+
+	// dailyLockKey makes one instance per interval run the aggregation, so
+	// the raw tables are scanned once however many wsservers there are.
+	dailyLockKey = "stats:daily:lock"
+)
+
+// Day is one UTC day's summary from the daily_stats tables.
+//
+//	Chats, ShortChats, ReportedChats  chats started, ended within
+//	                                  ShortChatThreshold and reported (TierStore)
+//	Reports, ReportsByCategory        abuse reports filed
+//	AutoBans, AdminBans               automatic and admin API bans (audit log)
+//	MessagesBlocked                   messages blocked by the content filter
+type Day struct {
+	Day               string           `json:"day"`
+	Chats             int64            `json:"chats"`
+	ShortChats        int64            `json:"short_chats"`
+	ReportedChats     int64            `json:"reported_chats"`
+	Reports           int64            `json:"reports"`
+	ReportsByCategory map[string]int64 `json:"reports_by_category"`
+	AutoBans          int64            `json:"auto_bans"`
+	AdminBans         int64            `json:"admin_bans"`
+	MessagesBlocked   int64            `json:"messages_blocked"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+}
+
+// PublicDay is the part of a Day that is safe to publish: totals only, with
+// no breakdown that could hint at how moderation works.
+type PublicDay struct {
+	Day             string `json:"day"`
+	Chats           int64  `json:"chats"`
+	Reports         int64  `json:"reports"`
+	Bans            int64  `json:"bans"`
+	MessagesBlocked int64  `json:"messages_blocked"`
+}
+
+// Public returns the publishable totals of d.
+func (d Day) Public() PublicDay {
+	return PublicDay{
+		Day:             d.Day,
+		Chats:           d.Chats,
+		Reports:         d.Reports,
+		Bans:            d.AutoBans + d.AdminBans,
+		MessagesBlocked: d.MessagesBlocked,
+	}
+}
+
+// DailyStore rolls reports, bans, blocked messages and chats up into one
+// PostgreSQL row per UTC day (tables daily_stats and
+// daily_report_categories), so dashboards don't query abuse_reports and
+// audit_log directly. Chat counts come from the TierStore's Redis buckets,
+// which expire after 90 days; the summaries are kept.
+type DailyStore struct {
+	db    *sql.DB
+	tiers *TierStore
+}
+
+// NewDailyStore creates a daily summary store. tiers supplies the chat
+// counts and the Redis client used to coordinate Run across instances.
+func NewDailyStore(db *sql.DB, tiers *TierStore) *DailyStore {
+	return &DailyStore{db: db, tiers: tiers}
+}
+
+// Aggregate recomputes the summary of the UTC day containing day from the
+// raw tables and stores it, replacing any earlier one.
+func (s *DailyStore) Aggregate(ctx context.Context, day time.Time) (*Day, error) {
+	start := truncateDay(day)
+	end := start.AddDate(0, 0, 1)
+	d := &Day{Day: start.Format(dayLayout), ReportsByCategory: map[string]int64{}}
+
+	chats, err := s.tiers.DayOutcome(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	d.Chats, d.ShortChats, d.ReportedChats = chats.Chats, chats.ShortChats, chats.Reported
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT category, COUNT(*)
+		FROM abuse_reports
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY category`, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("stats: count reports: %w", err)
+	}
+	err = scanCounts(rows, func(category string, n int64) {
+		d.ReportsByCategory[category] = n
+		d.Reports += n
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stats: count reports: %w", err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT action, COUNT(*)
+		FROM audit_log
+		WHERE action IN ($3, $4, $5) AND created_at >= $1 AND created_at < $2
+		GROUP BY action`, start, end,
+		audit.ActionBanApplied, audit.ActionAdminBan, audit.ActionMessageBlocked)
+	if err != nil {
+		return nil, fmt.Errorf("stats: count audit events: %w", err)
+	}
+	err = scanCounts(rows, func(action string, n int64) {
+		switch action {
+		case audit.ActionBanApplied:
+			d.AutoBans = n
+		case audit.ActionAdminBan:
+			d.AdminBans = n
+		case audit.ActionMessageBlocked:
+			d.MessagesBlocked = n
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stats: count audit events: %w", err)
+	}
+
+	if err := s.store(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// store upserts d and replaces its report categories in one transaction.
+// Days are passed to PostgreSQL as YYYY-MM-DD strings, so the session time
+// zone can't shift them.
+func (s *DailyStore) store(ctx context.Context, d *Day) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("stats: store day: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO daily_stats (day, chats, short_chats, reported_chats, reports,
+		                         auto_bans, admin_bans, messages_blocked, updated_at)
+		VALUES ($1::date, $2, $3, $4, $5, $6, $7, $8, NOW())
+		ON CONFLICT (day) DO UPDATE SET
+			chats = EXCLUDED.chats,
+			short_chats = EXCLUDED.short_chats,
+			reported_chats = EXCLUDED.reported_chats,
+			reports = EXCLUDED.reports,
+			auto_bans = EXCLUDED.auto_bans,
+			admin_bans = EXCLUDED.admin_bans,
+			messages_blocked = EXCLUDED.messages_blocked,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`,
+		d.Day, d.Chats, d.ShortChats, d.ReportedChats, d.Reports,
+		d.AutoBans, d.AdminBans, d.MessagesBlocked,
+	).Scan(&d.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("stats: store day: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM daily_report_categories WHERE day = $1::date`, d.Day); err != nil {
+		return fmt.Errorf("stats: store categories: %w", err)
+	}
+	for category, n := range d.ReportsByCategory {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO daily_report_categories (day, category, reports)
+			VALUES ($1::date, $2, $3)`, d.Day, category, n)
+		if err != nil {
+			return fmt.Errorf("stats: store categories: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("stats: store day: %w", err)
+	}
+	return nil
+}
+
+// Days returns the stored summaries of the UTC days from through to,
+// oldest first. Days that were never aggregated are missing.
+func (s *DailyStore) Days(ctx context.Context, from, to time.Time) ([]Day, error) {
+	fromDay, toDay := truncateDay(from).Format(dayLayout), truncateDay(to).Format(dayLayout)
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT day, chats, short_chats, reported_chats, reports,
+		       auto_bans, admin_bans, messages_blocked, updated_at
+		FROM daily_stats
+		WHERE day BETWEEN $1::date AND $2::date
+		ORDER BY day`, fromDay, toDay)
+	if err != nil {
+		return nil, fmt.Errorf("stats: list days: %w", err)
+	}
+	defer rows.Close()
+
+	days := []Day{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var d Day
+		var day time.Time
+		if err := rows.Scan(&day, &d.Chats, &d.ShortChats, &d.ReportedChats, &d.Reports,
+			&d.AutoBans, &d.AdminBans, &d.MessagesBlocked, &d.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("stats: scan day: %w", err)
+		}
+		d.Day = day.UTC().Format(dayLayout)
+		d.ReportsByCategory = map[string]int64{}
+		index[d.Day] = len(days)
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("stats: list days: %w", err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT day, category, reports
+		FROM daily_report_categories
+		WHERE day BETWEEN $1::date AND $2::date`, fromDay, toDay)
+	if err != nil {
+		return nil, fmt.Errorf("stats: list categories: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day time.Time
+		var category string
+		var n int64
+		if err := rows.Scan(&day, &category, &n); err != nil {
+			return nil, fmt.Errorf("stats: scan category: %w", err)
+		}
+		if i, ok := index[day.UTC().Format(dayLayout)]; ok {
+			days[i].ReportsByCategory[category] = n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("stats: list categories: %w", err)
+	}
+	return days, nil
+}
+
+// Refresh aggregates today and yesterday, whose late events may still be
+// coming in, and any of the last BackfillDays without a summary. It
+// reports false without doing anything if another instance refreshed less
+// than interval ago.
+func (s *DailyStore) Refresh(ctx context.Context, now time.Time, interval time.Duration) (bool, error) {
+	// The lock expires a little early so the next tick anywhere can take it.
+	ok, err := s.tiers.rdb.SetNX(ctx, dailyLockKey, 1, interval*9/10).Result()
+	if err != nil {
+		return false, fmt.Errorf("stats: refresh lock: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	today := truncateDay(now)
+	stored, err := s.Days(ctx, today.AddDate(0, 0, -BackfillDays), today)
+	if err != nil {
+		return true, err
+	}
+	have := make(map[string]bool, len(stored))
+	for _, d := range stored {
+		have[d.Day] = true
+	}
+	for i := BackfillDays; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i)
+		if i > 1 && have[day.Format(dayLayout)] {
+			continue
+		}
+		if _, err := s.Aggregate(ctx, day); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Run refreshes the summaries every interval until ctx is cancelled.
+// Failures are logged and retried on the next tick.
+func (s *DailyStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Refresh(ctx, time.Now(), interval); err != nil && ctx.Err() == nil {
+			log.Printf("[stats] daily aggregation failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scanCounts reads (key, count) rows into fn and closes rows.
+func scanCounts(rows *sql.Rows, fn func(key string, n int64)) error {
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var n int64
+		if err := rows.Scan(&key, &n); err != nil {
+			return err
+		}
+		fn(key, n)
+	}
+	return rows.Err()
+}
+
+// DayRange returns the UTC days covered by the last days days up to now,
+// for a days query parameter: empty means DefaultDailyDays, anything else
+// must be between 1 and MaxDailyDays.
+func DayRange(days string, now time.Time) (from, to time.Time, err error) {
+	n := DefaultDailyDays
+	if days != "" {
+		n, err = strconv.Atoi(days)
+		if err != nil || n < 1 || n > MaxDailyDays {
+			return time.Time{}, time.Time{}, fmt.Errorf("days must be between 1 and %d", MaxDailyDays)
+		}
+	}
+	to = truncateDay(now)
+	return to.AddDate(0, 0, -(n - 1)), to, nil
+}
+
+// truncateDay returns the start of t's UTC day.
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,108 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/audit"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/matching"
+	"github.com/whisper/chat-app/internal/report"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestDayRange(t *testing.T) {
+	now := time.Date(2024, 3, 10, 23, 30, 0, 0, time.FixedZone("", -5*3600))
+
+	from, to, err := DayRange("", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 23:30 at UTC-5 is already March 11 in UTC.
+	if got := from.Format(dayLayout) + ".." + to.Format(dayLayout); got != "2024-02-11..2024-03-11" {
+		t.Errorf("default range = %s, want the last %d UTC days", got, DefaultDailyDays)
+	}
+	if from, to, _ := DayRange("1", now); !from.Equal(to) {
+		t.Errorf("days=1 gave %v..%v, want a single day", from, to)
+	}
+	for _, v := range []string{"0", "-3", "x", "367"} {
+		if _, _, err := DayRange(v, now); err == nil {
+			t.Errorf("DayRange(%q) succeeded, want an error", v)
+		}
+	}
+}
+
+func TestDay_PublicMergesBans(t *testing.T) {
+	d := Day{Day: "2024-03-10", Chats: 9, Reports: 3, ReportsByCategory: map[string]int64{"spam": 3}, AutoBans: 2, AdminBans: 1, MessagesBlocked: 4}
+	want := PublicDay{Day: "2024-03-10", Chats: 9, Reports: 3, Bans: 3, MessagesBlocked: 4}
+	if got := d.Public(); got != want {
+		t.Errorf("Public() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDailyStore_RefreshSkipsWhileLocked(t *testing.T) {
+	rdb := testutil.Redis(t)
+	ctx := context.Background()
+	rdb.Set(ctx, dailyLockKey, 1, time.Minute)
+
+	// The lock is checked first, so no database is needed.
+	ran, err := NewDailyStore(nil, NewTierStore(rdb)).Refresh(ctx, time.Now(), time.Minute)
+	if err != nil || ran {
+		t.Errorf("Refresh = %v, %v while another instance holds the lock, want false, nil", ran, err)
+	}
+}
+
+func TestDailyStore_AggregateAndDays(t *testing.T) {
+	db := testutil.Postgres(t)
+	tiers := NewTierStore(testutil.Redis(t))
+	s := NewDailyStore(db, tiers)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i, tier := range []string{matching.TierExact, matching.TierRandom} {
+		cs := &chat.ChatSession{ChatID: "chat-" + tier, Tier: tier, ActivatedAt: now.Unix()}
+		if err := tiers.RecordStarted(ctx, cs, now); err != nil {
+			t.Fatalf("RecordStarted: %v", err)
+		}
+		if i == 0 {
+			if err := tiers.RecordReported(ctx, cs, now); err != nil {
+				t.Fatalf("RecordReported: %v", err)
+			}
+		}
+	}
+	reports := report.NewStore(db)
+	for _, reason := range []string{"spam", "spam", "harassment"} {
+		if err := reports.Create(ctx, &report.Report{ReporterFingerprint: "fp-a", ReportedFingerprint: "fp-b", ChatID: "chat-1", Reason: reason}); err != nil {
+			t.Fatalf("Create report: %v", err)
+		}
+	}
+	auditLog := audit.NewLogger(db)
+	for _, action := range []string{audit.ActionBanApplied, audit.ActionBanApplied, audit.ActionAdminBan, audit.ActionMessageBlocked, audit.ActionAdminUnban} {
+		if err := auditLog.Record(ctx, &audit.Event{Action: action, Actor: audit.ActorSystem}); err != nil {
+			t.Fatalf("Record %s: %v", action, err)
+		}
+	}
+
+	d, err := s.Aggregate(ctx, now)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	if d.Chats != 2 || d.ReportedChats != 1 || d.Reports != 3 || d.ReportsByCategory["spam"] != 2 ||
+		d.AutoBans != 2 || d.AdminBans != 1 || d.MessagesBlocked != 1 {
+		t.Errorf("Aggregate = %+v", d)
+	}
+
+	// A second run replaces the day rather than adding to it.
+	if _, err := s.Aggregate(ctx, now); err != nil {
+		t.Fatalf("Aggregate again: %v", err)
+	}
+	days, err := s.Days(ctx, now.AddDate(0, 0, -1), now)
+	if err != nil {
+		t.Fatalf("Days: %v", err)
+	}
+	if len(days) != 1 || days[0].Day != d.Day || days[0].Reports != 3 ||
+		days[0].ReportsByCategory["harassment"] != 1 || days[0].AutoBans != 2 {
+		t.Errorf("Days = %+v, want the aggregated day once", days)
+	}
+}
@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// DailyReport is the response of the daily stats endpoints.
+type DailyReport[T Day | PublicDay] struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Days []T    `json:"days"`
+}
+
+// PublicHandler serves the public statistics endpoint:
+//
+//	GET /api/stats?days=N  daily totals of chats, reports, bans and blocked
+//	                       messages (default 30 days)
+//
+// It reads only the daily summaries, never the raw tables, and responses
+// may be cached for a few minutes. Days not aggregated yet are missing.
+type PublicHandler struct {
+	store *DailyStore
+}
+
+// NewPublicHandler creates the public statistics endpoint.
+func NewPublicHandler(store *DailyStore) *PublicHandler {
+	return &PublicHandler{store: store}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *PublicHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	from, to, err := DayRange(r.URL.Query().Get("days"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days, err := h.store.Days(r.Context(), from, to)
+	if err != nil {
+		log.Printf("[stats] public stats: %v", err)
+		http.Error(w, "failed to load stats", http.StatusInternalServerError)
+		return
+	}
+
+	report := DailyReport[PublicDay]{From: from.Format(dayLayout), To: to.Format(dayLayout), Days: make([]PublicDay, 0, len(days))}
+	for _, d := range days {
+		report.Days = append(report.Days, d.Public())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(w).Encode(report)
+}
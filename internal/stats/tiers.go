@@ -0,0 +1,220 @@
+// Package stats aggregates product statistics in Redis. Counters are kept
+// in daily buckets so trends can be compared across releases without a
+// separate analytics pipeline.
+package stats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/matching"
+	"github.com/whisper/chat-app/internal/metrics"
+)
+
+const (
+	// tierStatsPrefix is followed by <YYYY-MM-DD>:<tier>. Each key is a hash
+	// with the fields chats, short and reported.
+	tierStatsPrefix = "stats:tiers:"
+
+	// tierStatsTTL is how long daily buckets are kept.
+	tierStatsTTL = 90 * 24 * time.Hour
+
+	// ShortChatThreshold is the duration under which an ended chat counts
+	// as short, i.e. one of the users bailed almost immediately.
+	ShortChatThreshold = 30 * time.Second
+
+	// MaxSummaryDays caps how many daily buckets Summary reads.
+	MaxSummaryDays = 90
+
+	// Marker fields set on the chat hash so each chat's end and report are
+	// counted once, even when both participants trigger them.
+	endedMarker    = "stats_ended"
+	reportedMarker = "stats_reported"
+
+	dayLayout = "2006-01-02"
+)
+
+// TierStore records chat outcomes per matching tier: how many chats each
+// tier started, how many of those ended within ShortChatThreshold, and how
+// many led to a report. It backs the decision whether random (tier-4)
+// matching should remain on by default.
+type TierStore struct {
+	rdb   *redis.Client
+	chats *chat.Store
+}
+
+// NewTierStore creates a tier statistics store backed by Redis.
+func NewTierStore(rdb *redis.Client) *TierStore {
+	return &TierStore{rdb: rdb, chats: chat.NewStore(rdb)}
+}
+
+// RecordStarted counts a chat that both users accepted.
+func (s *TierStore) RecordStarted(ctx context.Context, cs *chat.ChatSession, now time.Time) error {
+	if cs.Tier == "" {
+		return nil
+	}
+	metrics.ChatOutcomesTotal.WithLabelValues(cs.Tier, "started").Inc()
+	return s.incr(ctx, cs.Tier, "chats", now)
+}
+
+// RecordEnded counts the chat as short if it ended within
+// ShortChatThreshold of becoming active. Only the first call per chat has
+// any effect, so it must run before the chat hash is deleted.
+func (s *TierStore) RecordEnded(ctx context.Context, cs *chat.ChatSession, now time.Time) error {
+	if cs.Tier == "" || cs.ActivatedAt == 0 {
+		return nil
+	}
+	first, err := s.chats.MarkOnce(ctx, cs.ChatID, endedMarker)
+	if err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+	if !first || now.Sub(time.Unix(cs.ActivatedAt, 0)) >= ShortChatThreshold {
+		return nil
+	}
+	metrics.ChatOutcomesTotal.WithLabelValues(cs.Tier, "short").Inc()
+	return s.incr(ctx, cs.Tier, "short", now)
+}
+
+// RecordReported counts a chat that led to an abuse report. Only the first
+// report per chat is counted, and none once the chat hash is deleted, so it
+// must run before a ban the report triggers ends the chat.
+func (s *TierStore) RecordReported(ctx context.Context, cs *chat.ChatSession, now time.Time) error {
+	if cs.Tier == "" {
+		return nil
+	}
+	first, err := s.chats.MarkOnce(ctx, cs.ChatID, reportedMarker)
+	if err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+	if !first {
+		return nil
+	}
+	metrics.ChatOutcomesTotal.WithLabelValues(cs.Tier, "reported").Inc()
+	return s.incr(ctx, cs.Tier, "reported", now)
+}
+
+func (s *TierStore) incr(ctx context.Context, tier, field string, now time.Time) error {
+	key := tierKey(now, tier)
+	pipe := s.rdb.Pipeline()
+	pipe.HIncrBy(ctx, key, field, 1)
+	pipe.Expire(ctx, key, tierStatsTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("stats: incr %s %s: %w", tier, field, err)
+	}
+	return nil
+}
+
+func tierKey(day time.Time, tier string) string {
+	return tierStatsPrefix + day.UTC().Format(dayLayout) + ":" + tier
+}
+
+// TierOutcome aggregates the outcomes of one tier (or group of tiers).
+type TierOutcome struct {
+	Tier       string  `json:"tier"`
+	Chats      int64   `json:"chats"`
+	ShortChats int64   `json:"short_chats"`
+	Reported   int64   `json:"reported"`
+	ShortRate  float64 `json:"short_rate"`
+	ReportRate float64 `json:"report_rate"`
+}
+
+func (o *TierOutcome) add(other TierOutcome) {
+	o.Chats += other.Chats
+	o.ShortChats += other.ShortChats
+	o.Reported += other.Reported
+}
+
+func (o *TierOutcome) computeRates() {
+	if o.Chats == 0 {
+		o.ShortRate, o.ReportRate = 0, 0
+		return
+	}
+	o.ShortRate = float64(o.ShortChats) / float64(o.Chats)
+	o.ReportRate = float64(o.Reported) / float64(o.Chats)
+}
+
+// TierSummary compares tier outcomes over a range of UTC days. Interest
+// combines every tier except random, for a direct comparison with it.
+type TierSummary struct {
+	From     string        `json:"from"`
+	To       string        `json:"to"`
+	Tiers    []TierOutcome `json:"tiers"`
+	Interest TierOutcome   `json:"interest_based"`
+	Random   TierOutcome   `json:"random"`
+}
+
+// Summary aggregates the last days UTC days, including today. days is
+// clamped to [1, MaxSummaryDays].
+func (s *TierStore) Summary(ctx context.Context, days int, now time.Time) (*TierSummary, error) {
+	if days < 1 {
+		days = 1
+	}
+	if days > MaxSummaryDays {
+		days = MaxSummaryDays
+	}
+	now = now.UTC()
+
+	pipe := s.rdb.Pipeline()
+	cmds := make(map[string][]*redis.MapStringStringCmd, len(matching.Tiers))
+	for _, tier := range matching.Tiers {
+		for d := 0; d < days; d++ {
+			cmds[tier] = append(cmds[tier], pipe.HGetAll(ctx, tierKey(now.AddDate(0, 0, -d), tier)))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("stats: summary: %w", err)
+	}
+
+	summary := &TierSummary{
+		From:     now.AddDate(0, 0, -(days - 1)).Format(dayLayout),
+		To:       now.Format(dayLayout),
+		Tiers:    make([]TierOutcome, 0, len(matching.Tiers)),
+		Interest: TierOutcome{Tier: "interest_based"},
+	}
+	for _, tier := range matching.Tiers {
+		outcome := TierOutcome{Tier: tier}
+		for _, cmd := range cmds[tier] {
+			outcome.add(parseOutcome(cmd.Val()))
+		}
+		outcome.computeRates()
+		summary.Tiers = append(summary.Tiers, outcome)
+		if tier == matching.TierRandom {
+			summary.Random = outcome
+		} else {
+			summary.Interest.add(outcome)
+		}
+	}
+	summary.Interest.computeRates()
+	return summary, nil
+}
+
+func parseOutcome(fields map[string]string) TierOutcome {
+	var o TierOutcome
+	o.Chats, _ = strconv.ParseInt(fields["chats"], 10, 64)
+	o.ShortChats, _ = strconv.ParseInt(fields["short"], 10, 64)
+	o.Reported, _ = strconv.ParseInt(fields["reported"], 10, 64)
+	return o
+}
+
+// DayOutcome sums the outcomes of every tier on one UTC day.
+func (s *TierStore) DayOutcome(ctx context.Context, day time.Time) (TierOutcome, error) {
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, 0, len(matching.Tiers))
+	for _, tier := range matching.Tiers {
+		cmds = append(cmds, pipe.HGetAll(ctx, tierKey(day, tier)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return TierOutcome{}, fmt.Errorf("stats: day outcome: %w", err)
+	}
+
+	outcome := TierOutcome{Tier: "all"}
+	for _, cmd := range cmds {
+		outcome.add(parseOutcome(cmd.Val()))
+	}
+	outcome.computeRates()
+	return outcome, nil
+}
@@ -0,0 +1,128 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/whisper/chat-app/internal/chat"
+	"github.com/whisper/chat-app/internal/matching"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// setupTestStore creates a TierStore on the package's test Redis.
+func setupTestStore(t *testing.T) (*TierStore, *redis.Client, context.Context) {
+	t.Helper()
+	rdb := testutil.Redis(t)
+	return NewTierStore(rdb), rdb, context.Background()
+}
+
+func TestParseOutcome(t *testing.T) {
+	o := parseOutcome(map[string]string{"chats": "10", "short": "4", "reported": "1"})
+	o.computeRates()
+	if o.Chats != 10 || o.ShortChats != 4 || o.Reported != 1 {
+		t.Fatalf("unexpected counts: %+v", o)
+	}
+	if o.ShortRate != 0.4 || o.ReportRate != 0.1 {
+		t.Errorf("unexpected rates: short=%v report=%v", o.ShortRate, o.ReportRate)
+	}
+
+	empty := parseOutcome(nil)
+	empty.computeRates()
+	if empty.ShortRate != 0 || empty.ReportRate != 0 {
+		t.Errorf("expected zero rates for empty bucket, got %+v", empty)
+	}
+}
+
+func TestTierStore_Summary(t *testing.T) {
+	s, rdb, ctx := setupTestStore(t)
+	now := time.Now()
+
+	newChat := func(id, tier string, activatedAgo time.Duration) *chat.ChatSession {
+		rdb.HSet(ctx, chat.ChatPrefix+id, "status", "active")
+		return &chat.ChatSession{ChatID: id, Tier: tier, ActivatedAt: now.Add(-activatedAgo).Unix()}
+	}
+
+	quick := newChat("c1", matching.TierRandom, 5*time.Second)
+	long := newChat("c2", matching.TierRandom, 10*time.Minute)
+	exact := newChat("c3", matching.TierExact, 5*time.Second)
+
+	for _, cs := range []*chat.ChatSession{quick, long, exact} {
+		if err := s.RecordStarted(ctx, cs, now); err != nil {
+			t.Fatalf("RecordStarted: %v", err)
+		}
+	}
+	// Both participants end and report the quick chat; each counts once.
+	for i := 0; i < 2; i++ {
+		if err := s.RecordEnded(ctx, quick, now); err != nil {
+			t.Fatalf("RecordEnded: %v", err)
+		}
+		if err := s.RecordReported(ctx, quick, now); err != nil {
+			t.Fatalf("RecordReported: %v", err)
+		}
+	}
+	if err := s.RecordEnded(ctx, long, now); err != nil {
+		t.Fatalf("RecordEnded: %v", err)
+	}
+	if err := s.RecordEnded(ctx, exact, now); err != nil {
+		t.Fatalf("RecordEnded: %v", err)
+	}
+
+	summary, err := s.Summary(ctx, 7, now)
+	if err != nil {
+		t.Fatalf("Summary: %v", err)
+	}
+	if summary.Random.Chats != 2 || summary.Random.ShortChats != 1 || summary.Random.Reported != 1 {
+		t.Errorf("unexpected random outcome: %+v", summary.Random)
+	}
+	if summary.Random.ShortRate != 0.5 {
+		t.Errorf("expected random short rate 0.5, got %v", summary.Random.ShortRate)
+	}
+	if summary.Interest.Chats != 1 || summary.Interest.ShortChats != 1 || summary.Interest.Reported != 0 {
+		t.Errorf("unexpected interest outcome: %+v", summary.Interest)
+	}
+	if len(summary.Tiers) != len(matching.Tiers) {
+		t.Errorf("expected %d tiers, got %d", len(matching.Tiers), len(summary.Tiers))
+	}
+}
+
+func TestTierStore_IgnoresUntieredChats(t *testing.T) {
+	s, _, ctx := setupTestStore(t)
+	now := time.Now()
+
+	cs := &chat.ChatSession{ChatID: "legacy", ActivatedAt: now.Unix()}
+	if err := s.RecordStarted(ctx, cs, now); err != nil {
+		t.Fatalf("RecordStarted: %v", err)
+	}
+	summary, err := s.Summary(ctx, 1, now)
+	if err != nil {
+		t.Fatalf("Summary: %v", err)
+	}
+	if summary.Interest.Chats != 0 || summary.Random.Chats != 0 {
+		t.Errorf("expected no chats counted, got %+v", summary)
+	}
+}
+
+func TestTierStore_DeletedChatNotRecreated(t *testing.T) {
+	s, rdb, ctx := setupTestStore(t)
+	now := time.Now()
+
+	cs := &chat.ChatSession{ChatID: "gone", Tier: matching.TierRandom, ActivatedAt: now.Unix()}
+	if err := s.RecordReported(ctx, cs, now); err != nil {
+		t.Fatalf("RecordReported: %v", err)
+	}
+	if err := s.RecordEnded(ctx, cs, now); err != nil {
+		t.Fatalf("RecordEnded: %v", err)
+	}
+	if n := rdb.Exists(ctx, chat.ChatPrefix+"gone").Val(); n != 0 {
+		t.Error("recording a deleted chat recreated its hash")
+	}
+	summary, err := s.Summary(ctx, 1, now)
+	if err != nil {
+		t.Fatalf("Summary: %v", err)
+	}
+	if summary.Random.Reported != 0 || summary.Random.ShortChats != 0 {
+		t.Errorf("counted a deleted chat: %+v", summary.Random)
+	}
+}
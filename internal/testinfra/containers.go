@@ -0,0 +1,96 @@
+package testinfra
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// Container images and credentials are shared with testutil, which mirrors
+// the versions pinned in docker-compose.yml.
+const (
+	redisImage    = testutil.RedisImage
+	natsImage     = testutil.NATSImage
+	postgresImage = testutil.PostgresImage
+
+	postgresDB       = testutil.PostgresDB
+	postgresUser     = testutil.PostgresUser
+	postgresPassword = testutil.PostgresPassword
+
+	containerStartTimeout = 60 * time.Second
+)
+
+// startContainer launches a generic container, registers its termination
+// with t.Cleanup and returns the host:port mapped to exposedPort.
+func startContainer(t *testing.T, req testcontainers.ContainerRequest, exposedPort string) string {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), containerStartTimeout)
+	defer cancel()
+
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	testcontainers.CleanupContainer(t, ctr)
+	if err != nil {
+		t.Fatalf("testinfra: start %s: %v", req.Image, err)
+	}
+
+	host, err := ctr.Host(ctx)
+	if err != nil {
+		t.Fatalf("testinfra: %s host: %v", req.Image, err)
+	}
+	port, err := ctr.MappedPort(ctx, nat.Port(exposedPort))
+	if err != nil {
+		t.Fatalf("testinfra: %s mapped port: %v", req.Image, err)
+	}
+	return fmt.Sprintf("%s:%s", host, port.Port())
+}
+
+// startRedis starts a Redis container and returns its host:port address.
+func startRedis(t *testing.T) string {
+	t.Helper()
+	return startContainer(t, testcontainers.ContainerRequest{
+		Image:        redisImage,
+		ExposedPorts: []string{"6379/tcp"},
+		WaitingFor:   wait.ForLog("Ready to accept connections"),
+	}, "6379/tcp")
+}
+
+// startNATS starts a NATS container and returns its nats:// URL.
+func startNATS(t *testing.T) string {
+	t.Helper()
+	addr := startContainer(t, testcontainers.ContainerRequest{
+		Image:        natsImage,
+		ExposedPorts: []string{"4222/tcp"},
+		WaitingFor:   wait.ForLog("Server is ready"),
+	}, "4222/tcp")
+	return "nats://" + addr
+}
+
+// startPostgres starts a PostgreSQL container and returns a connection URL
+// using the same credentials as the docker-compose stack.
+func startPostgres(t *testing.T) string {
+	t.Helper()
+	addr := startContainer(t, testcontainers.ContainerRequest{
+		Image:        postgresImage,
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_DB":       postgresDB,
+			"POSTGRES_USER":     postgresUser,
+			"POSTGRES_PASSWORD": postgresPassword,
+		},
+		// Postgres restarts once after running init scripts, so wait for the
+		// second "ready" line before handing out the address.
+		WaitingFor: wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+	}, "5432/tcp")
+	return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", postgresUser, postgresPassword, addr, postgresDB)
+}
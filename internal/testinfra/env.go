@@ -0,0 +1,231 @@
+// Package testinfra provides a full-stack integration test fixture. It starts
+// Redis, NATS and PostgreSQL in throwaway containers via testcontainers-go and
+// boots the Whisper services against them, so end-to-end tests run under a
+// plain `go test` without a pre-existing docker-compose stack.
+//
+// Tests using the fixture are skipped when Docker is unavailable or when
+// `go test -short` is used.
+package testinfra
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/whisper/chat-app/internal/matching"
+	"github.com/whisper/chat-app/internal/messaging"
+	"github.com/whisper/chat-app/internal/moderation"
+)
+
+// wsserverStartTimeout bounds how long Start waits for the wsserver /health
+// endpoint to answer after launching the binary.
+const wsserverStartTimeout = 30 * time.Second
+
+// testAdminToken is the ADMIN_TOKEN passed to the wsserver under test.
+const testAdminToken = "testinfra-admin-token"
+
+// Env describes a running stack. All resources are torn down automatically
+// through t.Cleanup when the test that created the Env finishes.
+type Env struct {
+	RedisAddr   string // host:port of the Redis container
+	NATSURL     string // nats:// URL of the NATS container
+	DatabaseURL string // postgres:// URL of the PostgreSQL container
+
+	HTTPAddr string // host:port the wsserver listens on
+	WSURL    string // ws:// URL of the wsserver upgrade endpoint
+
+	AdminToken string // bearer token accepted by the wsserver /admin/ API
+
+	Redis *redis.Client // client connected to RedisAddr for test assertions
+}
+
+// Start brings up the infrastructure containers, then the matcher and
+// moderator services in-process and the wsserver as a child process. It
+// fails the test if any component does not become ready.
+func Start(t *testing.T) *Env {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("testinfra: skipping full-stack test in -short mode")
+	}
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	env := &Env{
+		RedisAddr:   startRedis(t),
+		NATSURL:     startNATS(t),
+		DatabaseURL: startPostgres(t),
+		AdminToken:  testAdminToken,
+	}
+
+	env.Redis = redis.NewClient(&redis.Options{Addr: env.RedisAddr})
+	t.Cleanup(func() { env.Redis.Close() })
+
+	env.startMatcher(t)
+	env.startModerator(t)
+	env.startWSServer(t)
+
+	return env
+}
+
+// newNATSClient connects a NATS client for an in-process service and closes
+// it on cleanup.
+func (e *Env) newNATSClient(t *testing.T, name string) *messaging.NATSClient {
+	t.Helper()
+
+	config := messaging.DefaultNATSConfig()
+	config.URL = e.NATSURL
+	config.Name = name
+
+	client, err := messaging.NewNATSClient(config)
+	if err != nil {
+		t.Fatalf("testinfra: connect %s to NATS: %v", name, err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// startMatcher runs the matching service in-process.
+func (e *Env) startMatcher(t *testing.T) {
+	t.Helper()
+
+	svc := matching.NewService(e.Redis, e.newNATSClient(t, "whisper-matcher-test"), matching.DefaultTierConfig())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("testinfra: start matcher: %v", err)
+	}
+	t.Cleanup(svc.Stop)
+}
+
+// startModerator runs the async moderation service in-process.
+func (e *Env) startModerator(t *testing.T) {
+	t.Helper()
+
+	natsClient := e.newNATSClient(t, "whisper-moderator-test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	filter := moderation.NewDynamicFilter(e.Redis, natsClient)
+	if err := filter.Start(ctx); err != nil {
+		t.Fatalf("testinfra: start content filter: %v", err)
+	}
+
+	svc := moderation.NewService(natsClient, filter)
+	if err := svc.Start(); err != nil {
+		t.Fatalf("testinfra: start moderator: %v", err)
+	}
+}
+
+// startWSServer builds cmd/wsserver and runs it against the containers. The
+// wsserver wires its message handlers in package main, so unlike the matcher
+// and moderator it cannot be constructed from a test and runs as a child
+// process instead. The process is killed on cleanup.
+func (e *Env) startWSServer(t *testing.T) {
+	t.Helper()
+
+	root := repoRoot(t)
+	bin := filepath.Join(t.TempDir(), "wsserver")
+
+	build := exec.Command("go", "build", "-o", bin, "./cmd/wsserver")
+	build.Dir = root
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("testinfra: build wsserver: %v\n%s", err, out)
+	}
+
+	e.HTTPAddr = freeAddr(t)
+	e.WSURL = "ws://" + e.HTTPAddr + "/ws"
+
+	cmd := exec.Command(bin)
+	cmd.Dir = root // migrations are resolved relative to the working directory
+	cmd.Env = append(os.Environ(),
+		"LISTEN_ADDR="+e.HTTPAddr,
+		"REDIS_ADDR="+e.RedisAddr,
+		"NATS_URL="+e.NATSURL,
+		"DATABASE_URL="+e.DatabaseURL,
+		"SERVER_NAME=ws-test",
+		"ADMIN_TOKEN="+e.AdminToken,
+	)
+	cmd.Stdout = testWriter{t}
+	cmd.Stderr = testWriter{t}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("testinfra: start wsserver: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	if err := waitHealthy("http://"+e.HTTPAddr+"/health", wsserverStartTimeout); err != nil {
+		t.Fatalf("testinfra: wsserver not healthy: %v", err)
+	}
+}
+
+// waitHealthy polls url until it answers 200 OK or the timeout elapses.
+func waitHealthy(url string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w (last error: %v)", url, ctx.Err(), lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// freeAddr reserves an ephemeral localhost port and returns it as host:port.
+// The listener is closed immediately so the wsserver can bind the port.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("testinfra: reserve port: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// repoRoot returns the module root directory, derived from this source
+// file's location.
+func repoRoot(t *testing.T) string {
+	t.Helper()
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("testinfra: cannot determine source location")
+	}
+	return filepath.Join(filepath.Dir(file), "..", "..")
+}
+
+// testWriter forwards child process output to the test log.
+type testWriter struct {
+	t *testing.T
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Logf("%s", p)
+	return len(p), nil
+}
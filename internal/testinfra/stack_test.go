@@ -0,0 +1,52 @@
+package testinfra
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+func TestStack_SessionCreated(t *testing.T) {
+	env := Start(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, _, err := ws.Dial(ctx, env.WSURL)
+	if err != nil {
+		t.Fatalf("dial %s: %v", env.WSURL, err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	data, err := wsutil.ReadServerText(conn)
+	if err != nil {
+		t.Fatalf("read session_created: %v", err)
+	}
+
+	var msg protocol.SessionCreatedMsg
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("unmarshal session_created: %v", err)
+	}
+	if msg.Type != protocol.TypeSessionCreated {
+		t.Fatalf("expected type %q, got %q", protocol.TypeSessionCreated, msg.Type)
+	}
+	if msg.SessionID == "" {
+		t.Fatal("expected non-empty session_id")
+	}
+
+	// The wsserver registers the session in Redis before replying.
+	exists, err := env.Redis.Exists(ctx, "session:"+msg.SessionID).Result()
+	if err != nil {
+		t.Fatalf("redis exists: %v", err)
+	}
+	if exists != 1 {
+		t.Errorf("expected session:%s to exist in Redis", msg.SessionID)
+	}
+}
@@ -0,0 +1,368 @@
+// Package testutil provides per-package integration test dependencies. A
+// package's TestMain calls Main with the services it needs; Main starts one
+// throwaway Redis, NATS and/or PostgreSQL container for the whole test
+// binary via testcontainers-go, and tests get isolated handles through
+// Redis, NATSURL and Postgres.
+//
+// Because every package is its own test binary, packages never share a
+// container. Within a package, Redis is flushed and PostgreSQL tables are
+// truncated at the start of each test that asks for them.
+//
+// Existing servers can be used instead of containers by setting
+// WHISPER_TEST_REDIS_ADDR, WHISPER_TEST_NATS_URL or WHISPER_TEST_DATABASE_URL.
+// Without Docker, Redis falls back to localhost:6379 and tests needing an
+// unavailable service are skipped. On such a shared Redis each test binary
+// claims a database of its own among DBs 1-15, so packages running in
+// parallel never flush each other's keys.
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/whisper/chat-app/internal/database"
+)
+
+// Service selects the containers Main starts.
+type Service int
+
+const (
+	ServiceRedis Service = 1 << iota
+	ServiceNATS
+	ServicePostgres
+)
+
+// Container images mirror the versions pinned in docker-compose.yml.
+const (
+	RedisImage    = "redis:7-alpine"
+	NATSImage     = "nats:2-alpine"
+	PostgresImage = "postgres:16-alpine"
+
+	PostgresDB       = "whisper"
+	PostgresUser     = "whisper"
+	PostgresPassword = "whisper_dev"
+
+	startTimeout = 60 * time.Second
+
+	// firstSharedRedisDB and lastSharedRedisDB bound the databases claimed on
+	// a shared Redis. DB 0 is left alone as the default database of a
+	// developer's local Redis.
+	firstSharedRedisDB = 1
+	lastSharedRedisDB  = 15
+
+	// redisOwnerKey marks a claimed database. It expires after
+	// redisOwnerTTL unless refreshed, so a killed test binary does not hold
+	// its database for good.
+	redisOwnerKey = "testutil:owner"
+	redisOwnerTTL = time.Minute
+)
+
+// env holds the endpoints resolved by Main. Empty fields mean the service
+// was not requested or is unavailable.
+var env struct {
+	redisAddr   string
+	redisDB     int
+	redisOwner  string // owner token of the claimed database; empty on a private Redis
+	natsURL     string
+	databaseURL string
+}
+
+// Main starts the requested services, runs the package's tests and tears the
+// containers down. It does not return. Use it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		testutil.Main(m, testutil.ServiceRedis)
+//	}
+func Main(m *testing.M, services Service) {
+	flag.Parse()
+
+	var containers []testcontainers.Container
+	start := func(req testcontainers.ContainerRequest, port string) string {
+		ctr, addr, err := startContainer(req, port)
+		if ctr != nil {
+			containers = append(containers, ctr)
+		}
+		if err != nil {
+			log.Printf("testutil: %v", err)
+			return ""
+		}
+		return addr
+	}
+
+	// Containers are skipped in -short mode and when Docker is unavailable.
+	docker := !testing.Short() && dockerAvailable()
+
+	var releaseRedis func()
+	if services&ServiceRedis != 0 {
+		shared := true
+		switch {
+		case os.Getenv("WHISPER_TEST_REDIS_ADDR") != "":
+			env.redisAddr = os.Getenv("WHISPER_TEST_REDIS_ADDR")
+		case docker:
+			env.redisAddr = start(testcontainers.ContainerRequest{
+				Image:        RedisImage,
+				ExposedPorts: []string{"6379/tcp"},
+				WaitingFor:   wait.ForLog("Ready to accept connections"),
+			}, "6379/tcp")
+			shared = env.redisAddr == ""
+		}
+		if env.redisAddr == "" {
+			env.redisAddr = "localhost:6379"
+		}
+		if shared {
+			var err error
+			if env.redisDB, env.redisOwner, releaseRedis, err = claimRedisDB(env.redisAddr); err != nil {
+				// Redis skips every test on an unreachable server.
+				log.Printf("testutil: %v", err)
+			}
+		}
+	}
+
+	if services&ServiceNATS != 0 {
+		switch {
+		case os.Getenv("WHISPER_TEST_NATS_URL") != "":
+			env.natsURL = os.Getenv("WHISPER_TEST_NATS_URL")
+		case docker:
+			if addr := start(testcontainers.ContainerRequest{
+				Image:        NATSImage,
+				ExposedPorts: []string{"4222/tcp"},
+				WaitingFor:   wait.ForLog("Server is ready"),
+			}, "4222/tcp"); addr != "" {
+				env.natsURL = "nats://" + addr
+			}
+		}
+	}
+
+	if services&ServicePostgres != 0 {
+		switch {
+		case os.Getenv("WHISPER_TEST_DATABASE_URL") != "":
+			env.databaseURL = os.Getenv("WHISPER_TEST_DATABASE_URL")
+		case docker:
+			if addr := start(testcontainers.ContainerRequest{
+				Image:        PostgresImage,
+				ExposedPorts: []string{"5432/tcp"},
+				Env: map[string]string{
+					"POSTGRES_DB":       PostgresDB,
+					"POSTGRES_USER":     PostgresUser,
+					"POSTGRES_PASSWORD": PostgresPassword,
+				},
+				// Postgres restarts once after running init scripts.
+				WaitingFor: wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+			}, "5432/tcp"); addr != "" {
+				env.databaseURL = fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable",
+					PostgresUser, PostgresPassword, addr, PostgresDB)
+			}
+		}
+		if env.databaseURL != "" {
+			if err := database.RunMigrations(env.databaseURL, MigrationsDir()); err != nil {
+				log.Printf("testutil: %v", err)
+				env.databaseURL = ""
+			}
+		}
+	}
+
+	code := m.Run()
+
+	if releaseRedis != nil {
+		releaseRedis()
+	}
+	for _, ctr := range containers {
+		if err := testcontainers.TerminateContainer(ctr); err != nil {
+			log.Printf("testutil: terminate container: %v", err)
+		}
+	}
+	os.Exit(code)
+}
+
+// Redis returns a client for the package's Redis with an empty database. It
+// skips the test if Redis is unreachable. The client is closed on cleanup.
+//
+// On a shared Redis the database's owner key survives the flush, so tests
+// that count every key in the database see it.
+func Redis(t testing.TB) *redis.Client {
+	t.Helper()
+	if env.redisAddr == "" {
+		t.Fatal("testutil: Redis not requested in TestMain")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: env.redisAddr, DB: env.redisDB})
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skipf("redis not available: %v", err)
+	}
+	if env.redisOwner == "" {
+		client.FlushDB(ctx)
+	} else {
+		client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.FlushDB(ctx)
+			pipe.Set(ctx, redisOwnerKey, env.redisOwner, redisOwnerTTL)
+			return nil
+		})
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// claimRedisDB claims a free database of the shared Redis at addr for this
+// test binary, waiting up to startTimeout for one when every database is in
+// use. It returns the database, the owner token and a function that flushes
+// the database and gives it up.
+func claimRedisDB(addr string) (int, string, func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	owner := fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+	for {
+		for db := firstSharedRedisDB; db <= lastSharedRedisDB; db++ {
+			client := redis.NewClient(&redis.Options{Addr: addr, DB: db})
+			ok, err := client.SetNX(ctx, redisOwnerKey, owner, redisOwnerTTL).Result()
+			if err != nil {
+				client.Close()
+				if db == firstSharedRedisDB {
+					return 0, "", nil, fmt.Errorf("claim redis database: %w", err)
+				}
+				// The server has fewer databases; wait for a claimed one.
+				break
+			}
+			if !ok {
+				client.Close()
+				continue
+			}
+			return db, owner, holdRedisDB(client, owner), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, "", nil, fmt.Errorf("claim redis database: every database from %d to %d is in use", firstSharedRedisDB, lastSharedRedisDB)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// holdRedisDB keeps client's database claimed by owner until the returned
+// function is called, which flushes the database and closes client.
+func holdRedisDB(client *redis.Client, owner string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(redisOwnerTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				client.Set(context.Background(), redisOwnerKey, owner, redisOwnerTTL)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		client.FlushDB(context.Background())
+		client.Close()
+	}
+}
+
+// NATSURL returns the package's NATS URL, skipping the test if NATS is
+// unavailable.
+func NATSURL(t *testing.T) string {
+	t.Helper()
+	if env.natsURL == "" {
+		t.Skip("nats not available")
+	}
+	return env.natsURL
+}
+
+// Postgres returns a connection to the package's migrated database with all
+// application tables truncated. It skips the test if PostgreSQL is
+// unavailable. The connection is closed on cleanup.
+func Postgres(t *testing.T) *sql.DB {
+	t.Helper()
+	if env.databaseURL == "" {
+		t.Skip("postgres not available")
+	}
+
+	db, err := sql.Open("postgres", env.databaseURL)
+	if err != nil {
+		t.Fatalf("testutil: open postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`DO $$
+DECLARE r record;
+BEGIN
+    FOR r IN SELECT tablename FROM pg_tables
+             WHERE schemaname = 'public' AND tablename <> 'schema_migrations' LOOP
+        EXECUTE 'TRUNCATE TABLE ' || quote_ident(r.tablename) || ' RESTART IDENTITY CASCADE';
+    END LOOP;
+END $$`)
+	if err != nil {
+		t.Fatalf("testutil: truncate tables: %v", err)
+	}
+	return db
+}
+
+// MigrationsDir returns the absolute path of the repository's migrations.
+func MigrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "migrations")
+}
+
+// startContainer launches a container and returns it with the host:port
+// mapped to exposedPort. A non-nil container is returned even on error so it
+// can be terminated.
+func startContainer(req testcontainers.ContainerRequest, exposedPort string) (testcontainers.Container, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return ctr, "", fmt.Errorf("start %s: %w", req.Image, err)
+	}
+	host, err := ctr.Host(ctx)
+	if err != nil {
+		return ctr, "", fmt.Errorf("%s host: %w", req.Image, err)
+	}
+	port, err := ctr.MappedPort(ctx, nat.Port(exposedPort))
+	if err != nil {
+		return ctr, "", fmt.Errorf("%s mapped port: %w", req.Image, err)
+	}
+	return ctr, fmt.Sprintf("%s:%s", host, port.Port()), nil
+}
+
+// dockerAvailable reports whether testcontainers can reach a Docker daemon.
+// The provider panics on some misconfigurations, which is treated as
+// unavailable, as testcontainers.SkipIfProviderIsNotHealthy does.
+func dockerAvailable() (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		return false
+	}
+	defer provider.Close()
+	return provider.Health(context.Background()) == nil
+}
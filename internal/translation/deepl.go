@@ -0,0 +1,65 @@
+package translation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DeepL API endpoints. Free-plan keys end in ":fx" and must use the free
+// endpoint.
+const (
+	deepLURL     = "https://api.deepl.com/v2/translate"
+	deepLFreeURL = "https://api-free.deepl.com/v2/translate"
+)
+
+// deepLTargets maps languages DeepL only accepts as regional targets.
+var deepLTargets = map[string]string{
+	"en": "EN-US",
+	"pt": "PT-BR",
+}
+
+type deepL struct {
+	client *http.Client
+	key    string
+	url    string
+}
+
+func newDeepL(client *http.Client, key, url string) *deepL {
+	if url == "" {
+		url = deepLURL
+		if strings.HasSuffix(key, ":fx") {
+			url = deepLFreeURL
+		}
+	}
+	return &deepL{client: client, key: key, url: url}
+}
+
+func (d *deepL) Name() string { return "deepl" }
+
+func (d *deepL) Translate(ctx context.Context, text, source, target string) (string, error) {
+	tgt, ok := deepLTargets[target]
+	if !ok {
+		tgt = strings.ToUpper(target)
+	}
+	body := struct {
+		Text       []string `json:"text"`
+		SourceLang string   `json:"source_lang"`
+		TargetLang string   `json:"target_lang"`
+	}{[]string{text}, strings.ToUpper(source), tgt}
+
+	var resp struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	header := http.Header{"Authorization": {"DeepL-Auth-Key " + d.key}}
+	if err := postJSON(ctx, d.client, d.url, header, body, &resp); err != nil {
+		return "", fmt.Errorf("translation: deepl: %w", err)
+	}
+	if len(resp.Translations) == 0 {
+		return "", fmt.Errorf("translation: deepl: empty response")
+	}
+	return resp.Translations[0].Text, nil
+}
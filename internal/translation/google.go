@@ -0,0 +1,51 @@
+package translation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// googleURL is the Cloud Translation Basic (v2) endpoint.
+const googleURL = "https://translation.googleapis.com/language/translate/v2"
+
+type google struct {
+	client *http.Client
+	key    string
+	url    string
+}
+
+func newGoogle(client *http.Client, key, endpoint string) *google {
+	if endpoint == "" {
+		endpoint = googleURL
+	}
+	return &google{client: client, key: key, url: endpoint}
+}
+
+func (g *google) Name() string { return "google" }
+
+func (g *google) Translate(ctx context.Context, text, source, target string) (string, error) {
+	body := struct {
+		Q      string `json:"q"`
+		Source string `json:"source"`
+		Target string `json:"target"`
+		Format string `json:"format"`
+	}{text, source, target, "text"}
+
+	var resp struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	endpoint := g.url + "?key=" + url.QueryEscape(g.key)
+	if err := postJSON(ctx, g.client, endpoint, nil, body, &resp); err != nil {
+		return "", fmt.Errorf("translation: google: %w", err)
+	}
+	if len(resp.Data.Translations) == 0 {
+		return "", fmt.Errorf("translation: google: empty response")
+	}
+	return resp.Data.Translations[0].TranslatedText, nil
+}
@@ -0,0 +1,40 @@
+package translation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// libreTranslate talks to a LibreTranslate instance, typically self-hosted
+// so message text never leaves the deployment.
+type libreTranslate struct {
+	client *http.Client
+	key    string
+	url    string
+}
+
+func newLibreTranslate(client *http.Client, key, baseURL string) *libreTranslate {
+	return &libreTranslate{client: client, key: key, url: strings.TrimSuffix(baseURL, "/") + "/translate"}
+}
+
+func (l *libreTranslate) Name() string { return "libretranslate" }
+
+func (l *libreTranslate) Translate(ctx context.Context, text, source, target string) (string, error) {
+	body := struct {
+		Q      string `json:"q"`
+		Source string `json:"source"`
+		Target string `json:"target"`
+		Format string `json:"format"`
+		APIKey string `json:"api_key,omitempty"`
+	}{text, source, target, "text", l.key}
+
+	var resp struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := postJSON(ctx, l.client, l.url, nil, body, &resp); err != nil {
+		return "", fmt.Errorf("translation: libretranslate: %w", err)
+	}
+	return resp.TranslatedText, nil
+}
@@ -0,0 +1,106 @@
+// Package translation translates chat messages between users who declared
+// different languages. Providers wrap an external machine translation API
+// (DeepL, Google Cloud Translation or a LibreTranslate instance) behind the
+// Provider interface so wsserver does not depend on any one of them.
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Provider translates text from one language to another. Languages are
+// lower-case ISO 639 codes as returned by NormalizeLanguage.
+type Provider interface {
+	// Name identifies the provider in logs and metrics.
+	Name() string
+	// Translate returns text translated from source to target.
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
+
+// Config selects and configures a provider.
+type Config struct {
+	Provider string // "deepl", "google" or "libretranslate"
+	APIKey   string // required by deepl and google; optional for libretranslate
+	URL      string // endpoint override; required for libretranslate
+}
+
+// requestTimeout bounds a single provider HTTP request. Callers usually set
+// a shorter deadline on the context.
+const requestTimeout = 10 * time.Second
+
+// New creates the provider named in cfg.
+func New(cfg Config) (Provider, error) {
+	client := &http.Client{Timeout: requestTimeout}
+	switch cfg.Provider {
+	case "deepl":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("translation: deepl needs an API key")
+		}
+		return newDeepL(client, cfg.APIKey, cfg.URL), nil
+	case "google":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("translation: google needs an API key")
+		}
+		return newGoogle(client, cfg.APIKey, cfg.URL), nil
+	case "libretranslate":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("translation: libretranslate needs a URL")
+		}
+		return newLibreTranslate(client, cfg.APIKey, cfg.URL), nil
+	default:
+		return nil, fmt.Errorf("translation: unknown provider %q", cfg.Provider)
+	}
+}
+
+// NormalizeLanguage reduces a language tag such as "en-US" or "pt_BR" to its
+// lower-case primary subtag ("en", "pt"). Regional variants are not
+// distinguished, so two users who only differ in region are never
+// translated between.
+func NormalizeLanguage(tag string) (string, error) {
+	primary, _, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+	if len(primary) < 2 || len(primary) > 3 {
+		return "", fmt.Errorf("translation: invalid language %q", tag)
+	}
+	for _, r := range primary {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return "", fmt.Errorf("translation: invalid language %q", tag)
+		}
+	}
+	return strings.ToLower(primary), nil
+}
+
+// postJSON sends body as JSON to url and decodes a JSON response into out.
+// Non-2xx responses are returned as errors including the start of the body.
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,127 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := map[string]string{
+		"en":    "en",
+		"en-US": "en",
+		"pt_BR": "pt",
+		" DE ":  "de",
+		"fil":   "fil",
+	}
+	for in, want := range tests {
+		got, err := NormalizeLanguage(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeLanguage(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "e", "english", "e1", "-US"} {
+		if _, err := NormalizeLanguage(bad); err == nil {
+			t.Errorf("NormalizeLanguage(%q) expected error", bad)
+		}
+	}
+}
+
+func TestNew_RequiresSettings(t *testing.T) {
+	for _, cfg := range []Config{
+		{Provider: "deepl"},
+		{Provider: "google"},
+		{Provider: "libretranslate"},
+		{Provider: "babelfish", APIKey: "k", URL: "http://x"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) expected error", cfg)
+		}
+	}
+}
+
+// fakeAPI serves one provider endpoint, recording the decoded request body.
+func fakeAPI(t *testing.T, check func(r *http.Request), response interface{}) (*httptest.Server, *map[string]interface{}) {
+	t.Helper()
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		check(r)
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+func TestProviders(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("deepl", func(t *testing.T) {
+		srv, got := fakeAPI(t, func(r *http.Request) {
+			if h := r.Header.Get("Authorization"); h != "DeepL-Auth-Key secret" {
+				t.Errorf("Authorization = %q", h)
+			}
+		}, map[string]interface{}{"translations": []map[string]string{{"text": "Hello"}}})
+
+		p, _ := New(Config{Provider: "deepl", APIKey: "secret", URL: srv.URL})
+		out, err := p.Translate(ctx, "Hallo", "de", "en")
+		if err != nil || out != "Hello" {
+			t.Fatalf("Translate() = %q, %v", out, err)
+		}
+		if (*got)["source_lang"] != "DE" || (*got)["target_lang"] != "EN-US" {
+			t.Errorf("request = %v", *got)
+		}
+	})
+
+	t.Run("google", func(t *testing.T) {
+		srv, got := fakeAPI(t, func(r *http.Request) {
+			if k := r.URL.Query().Get("key"); k != "secret" {
+				t.Errorf("key = %q", k)
+			}
+		}, map[string]interface{}{"data": map[string]interface{}{
+			"translations": []map[string]string{{"translatedText": "Hello"}},
+		}})
+
+		p, _ := New(Config{Provider: "google", APIKey: "secret", URL: srv.URL})
+		out, err := p.Translate(ctx, "Hola", "es", "en")
+		if err != nil || out != "Hello" {
+			t.Fatalf("Translate() = %q, %v", out, err)
+		}
+		if (*got)["q"] != "Hola" || (*got)["source"] != "es" || (*got)["target"] != "en" {
+			t.Errorf("request = %v", *got)
+		}
+	})
+
+	t.Run("libretranslate", func(t *testing.T) {
+		srv, got := fakeAPI(t, func(r *http.Request) {
+			if r.URL.Path != "/translate" {
+				t.Errorf("path = %q", r.URL.Path)
+			}
+		}, map[string]string{"translatedText": "Hello"})
+
+		p, _ := New(Config{Provider: "libretranslate", URL: srv.URL + "/"})
+		out, err := p.Translate(ctx, "Bonjour", "fr", "en")
+		if err != nil || out != "Hello" {
+			t.Fatalf("Translate() = %q, %v", out, err)
+		}
+		if _, ok := (*got)["api_key"]; ok {
+			t.Errorf("api_key sent without a key: %v", *got)
+		}
+	})
+}
+
+func TestTranslate_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	p, _ := New(Config{Provider: "libretranslate", URL: srv.URL})
+	if _, err := p.Translate(context.Background(), "Bonjour", "fr", "en"); err == nil {
+		t.Fatal("expected error for 429 response")
+	}
+}
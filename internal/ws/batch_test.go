@@ -0,0 +1,225 @@
+package ws
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws/wsutil"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// readFrames reads server text frames from the client side of a test
+// connection until it is closed.
+func readFrames(t *testing.T, client net.Conn) <-chan []byte {
+	t.Helper()
+	frames := make(chan []byte, 64)
+	go func() {
+		defer close(frames)
+		for {
+			data, err := wsutil.ReadServerText(client)
+			if err != nil {
+				return
+			}
+			frames <- data
+		}
+	}()
+	return frames
+}
+
+func TestDispatch_BatchHandlesMessagesInOrder(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	frames := readFrames(t, client)
+	d := NewMessageDispatcher(s)
+	var got []string
+	d.Register(protocol.TypeMessage, func(_ *Connection, msg interface{}) {
+		got = append(got, msg.(protocol.ChatMsg).Text)
+	})
+
+	d.Dispatch(c, []byte(`{"type":"batch","messages":[
+		{"type":"message","chat_id":"c","text":"one"},
+		{"type":"nope"},
+		{"type":"batch","messages":[{"type":"ping"}]},
+		{"type":"message","chat_id":"c","text":"two"}
+	]}`))
+
+	if len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Fatalf("handled %q, want [one two]", got)
+	}
+	if !c.batching.Load() {
+		t.Error("expected a client that sent a batch to receive batches")
+	}
+	// The unknown type and the nested batch are each answered with an error.
+	for i := 0; i < 2; i++ {
+		select {
+		case data := <-frames:
+			if !isError(data, "unsupported_type") && !isError(data, "parse_error") {
+				t.Errorf("unexpected reply %s", data)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected an error reply per rejected message")
+		}
+	}
+}
+
+func TestDispatch_RejectsOversizedBatch(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	frames := readFrames(t, client)
+	d := NewMessageDispatcher(s)
+	handled := 0
+	d.Register(protocol.TypeTyping, func(*Connection, interface{}) { handled++ })
+
+	msgs := make([]json.RawMessage, protocol.MaxBatchSize+1)
+	for i := range msgs {
+		msgs[i] = json.RawMessage(`{"type":"typing","chat_id":"c","is_typing":true}`)
+	}
+	data, _ := json.Marshal(protocol.BatchMsg{Type: protocol.TypeBatch, Messages: msgs})
+	d.Dispatch(c, data)
+
+	if handled != 0 {
+		t.Errorf("handled %d messages of an oversized batch", handled)
+	}
+	select {
+	case reply := <-frames:
+		if !isError(reply, "parse_error") {
+			t.Errorf("reply = %s, want parse_error", reply)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a parse_error reply")
+	}
+}
+
+func TestWriteMessage_CoalescesBackedUpWrites(t *testing.T) {
+	_, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	c.batching.Store(true)
+
+	// Hold the write mutex as a slow write would, queue messages behind it,
+	// then let them go: they must arrive as one batch.
+	c.writeMu.Lock()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, _ := json.Marshal(map[string]interface{}{"type": "typing", "n": i})
+			_ = c.WriteMessage(data)
+		}(i)
+	}
+	for {
+		c.pendingMu.Lock()
+		n := len(c.pending)
+		c.pendingMu.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	frames := readFrames(t, client)
+	c.writeMu.Unlock()
+	wg.Wait()
+
+	var batch protocol.BatchMsg
+	select {
+	case data := <-frames:
+		if err := json.Unmarshal(data, &batch); err != nil || batch.Type != protocol.TypeBatch {
+			t.Fatalf("frame = %s, want a batch", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no frame written")
+	}
+	if len(batch.Messages) != 3 {
+		t.Fatalf("batch carried %d messages, want 3", len(batch.Messages))
+	}
+}
+
+func TestWriteMessageTracked_ReportsFrameWrite(t *testing.T) {
+	_, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	c.batching.Store(true)
+
+	// Queue messages behind a held write mutex: none is written, so none
+	// may be reported, until the batch carrying them goes out.
+	c.writeMu.Lock()
+	var mu sync.Mutex
+	var written []time.Time
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = c.WriteMessageTracked([]byte(`{"type":"typing"}`), func(at time.Time) {
+				mu.Lock()
+				written = append(written, at)
+				mu.Unlock()
+			})
+		}()
+	}
+	for {
+		c.pendingMu.Lock()
+		n := len(c.pending)
+		c.pendingMu.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	if len(written) != 0 {
+		t.Fatalf("%d messages reported written while queued", len(written))
+	}
+	mu.Unlock()
+
+	frames := readFrames(t, client)
+	released := time.Now()
+	c.writeMu.Unlock()
+	wg.Wait()
+	<-frames
+
+	if len(written) != 3 {
+		t.Fatalf("%d messages reported written, want 3", len(written))
+	}
+	for _, at := range written {
+		if at.Before(released) || !at.Equal(written[0]) {
+			t.Errorf("written at %v, want the batch's write time after %v", at, released)
+		}
+	}
+}
+
+func TestWriteMessageTracked_NotReportedOnFailure(t *testing.T) {
+	_, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	client.Close()
+
+	called := false
+	if err := c.WriteMessageTracked([]byte(`{"type":"pong"}`), func(time.Time) { called = true }); err == nil {
+		t.Fatal("write to a closed peer succeeded")
+	}
+	if called {
+		t.Error("a failed write was reported written")
+	}
+}
+
+func TestWriteMessage_NoBatchesUnlessAccepted(t *testing.T) {
+	_, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	frames := readFrames(t, client)
+
+	go func() {
+		_ = c.WriteMessage([]byte(`{"type":"pong"}`))
+		_ = c.WriteMessage([]byte(`{"type":"pong"}`))
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case data := <-frames:
+			if string(data) != `{"type":"pong"}` {
+				t.Fatalf("frame %d = %s, want a single pong", i, data)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("missing frame")
+		}
+	}
+}
+
+func isError(data []byte, code protocol.ErrorCode) bool {
+	var msg protocol.ErrorMsg
+	return json.Unmarshal(data, &msg) == nil && msg.Type == protocol.TypeError && msg.Code == code
+}
@@ -0,0 +1,37 @@
+package ws
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIP returns the address of the client that made r. Behind HAProxy
+// (option forwardfor) the peer is the proxy, so with trustForwarded the
+// last X-Forwarded-For entry, the one appended by the proxy, is used
+// instead. Earlier entries are client-supplied and never trusted. It
+// returns the zero Addr if no address can be parsed.
+func ClientIP(r *http.Request, trustForwarded bool) netip.Addr {
+	if trustForwarded {
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			last := xff[len(xff)-1]
+			if i := strings.LastIndexByte(last, ','); i >= 0 {
+				last = last[i+1:]
+			}
+			if addr, err := netip.ParseAddr(strings.TrimSpace(last)); err == nil {
+				return addr.Unmap()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
@@ -0,0 +1,33 @@
+package ws
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		trust  bool
+		want   string
+	}{
+		{"remote addr", "192.0.2.1:5000", nil, false, "192.0.2.1"},
+		{"xff ignored when untrusted", "192.0.2.1:5000", []string{"198.51.100.7"}, false, "192.0.2.1"},
+		{"last xff entry", "10.0.0.2:5000", []string{"203.0.113.9, 198.51.100.7"}, true, "198.51.100.7"},
+		{"last xff header", "10.0.0.2:5000", []string{"203.0.113.9", "198.51.100.7"}, true, "198.51.100.7"},
+		{"invalid xff falls back", "10.0.0.2:5000", []string{"garbage"}, true, "10.0.0.2"},
+		{"mapped ipv4", "[::ffff:192.0.2.1]:5000", nil, false, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		r.RemoteAddr = tt.remote
+		for _, v := range tt.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := ClientIP(r, tt.trust); got.String() != tt.want {
+			t.Errorf("%s: ClientIP() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
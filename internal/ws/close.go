@@ -0,0 +1,83 @@
+package ws
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+// CloseCode is the status code sent in a WebSocket close frame so clients
+// can tell why they were disconnected. Codes below 4000 are defined by
+// RFC 6455 and the IANA registry; 4000-4999 are private to Whisper.
+type CloseCode uint16
+
+const (
+	CloseNormal           CloseCode = 1000 // client asked to close, or nothing more specific applies
+	CloseGoingAway        CloseCode = 1001 // server shutting down; reconnect elsewhere
+	CloseProtocolError    CloseCode = 1002 // unreadable or malformed frame
+	ClosePolicyViolation  CloseCode = 1008 // banned, or disconnected by an operator
+	CloseTryAgainLater    CloseCode = 1013 // connection limit reached or draining; retry with backoff
+	CloseHeartbeatTimeout CloseCode = 4000 // no frame within the heartbeat deadline
+	CloseSessionExpired   CloseCode = 4001 // session state expired; reconnect for a new session
+	CloseTooManySessions  CloseCode = 4002 // fingerprint already has the maximum number of sessions open
+	CloseIdleTimeout      CloseCode = 4003 // idle session sent nothing but pings for the idle timeout
+	CloseFlood            CloseCode = 4004 // kept sending frames beyond the frame rate limit
+)
+
+// closeWriteTimeout bounds writing a close frame, so closing a connection
+// whose peer stopped reading does not stall the caller.
+const closeWriteTimeout = time.Second
+
+// maxCloseReason is the longest reason that fits a close frame: control
+// payloads are limited to 125 bytes, two of which hold the code.
+const maxCloseReason = 123
+
+// writeCloseFrame writes a close frame with code and reason to conn. The
+// reason is truncated to fit the frame.
+func writeCloseFrame(conn net.Conn, code CloseCode, reason string) error {
+	if len(reason) > maxCloseReason {
+		reason = reason[:maxCloseReason]
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(closeWriteTimeout))
+	return ws.WriteFrame(conn, ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusCode(code), reason)))
+}
+
+// CloseWithCode sends a close frame carrying code and reason, then closes
+// the network connection. Only the first close of a connection has any
+// effect, so racing removals send at most one close frame.
+func (c *Connection) CloseWithCode(code CloseCode, reason string) error {
+	if !c.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	c.writeMu.Lock()
+	_ = writeCloseFrame(c.Conn, code, reason)
+	c.writeMu.Unlock()
+	return c.Conn.Close()
+}
+
+// closeCodeFor picks the close code for a failed frame read: protocol
+// violations are reported as such; anything else (EOF, reset) means the
+// peer is gone and the code is moot.
+func closeCodeFor(err error) (CloseCode, string) {
+	var perr ws.ProtocolError
+	if errors.As(err, &perr) {
+		return CloseProtocolError, perr.Error()
+	}
+	return CloseNormal, ""
+}
+
+// rejectUpgrade completes the WebSocket handshake only to close the new
+// connection straight away with code and reason. Browsers hide the HTTP
+// status of a failed handshake from scripts, but they do expose close
+// codes, so this lets clients tell a full server from a ban.
+func rejectUpgrade(w http.ResponseWriter, r *http.Request, code CloseCode, reason string) {
+	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	if err != nil {
+		return
+	}
+	_ = writeCloseFrame(conn, code, reason)
+	_ = conn.Close()
+}
@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+
+	"github.com/whisper/chat-app/internal/appeal"
+	"github.com/whisper/chat-app/internal/ban"
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+// readClose reads frames from the client side of conn until a close frame
+// arrives and returns its code and reason. Frames are read from r, which
+// may buffer conn.
+func readClose(t *testing.T, conn net.Conn, r io.Reader) (ws.StatusCode, string) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		frame, err := ws.ReadFrame(r)
+		if err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		if frame.Header.OpCode == ws.OpClose {
+			return ws.ParseCloseFrameData(frame.Payload)
+		}
+	}
+}
+
+func TestCloseWithCode_SendsFrameOnce(t *testing.T) {
+	server, client := net.Pipe()
+	c := &Connection{ID: "s1", Conn: server}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = c.CloseWithCode(ClosePolicyViolation, "banned")
+		_ = c.CloseWithCode(CloseNormal, "") // no second frame, no panic
+	}()
+
+	code, reason := readClose(t, client, client)
+	if CloseCode(code) != ClosePolicyViolation || reason != "banned" {
+		t.Errorf("close frame = %d %q, want %d %q", code, reason, ClosePolicyViolation, "banned")
+	}
+	<-done
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Error("expected the connection to be closed after the close frame")
+	}
+}
+
+func TestWriteCloseFrame_TruncatesReason(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() { _ = writeCloseFrame(server, CloseGoingAway, strings.Repeat("x", 200)) }()
+
+	code, reason := readClose(t, client, client)
+	if CloseCode(code) != CloseGoingAway || len(reason) != maxCloseReason {
+		t.Errorf("close frame = %d with %d-byte reason, want %d with %d", code, len(reason), CloseGoingAway, maxCloseReason)
+	}
+}
+
+func TestRejectUpgrade_ClosesWithCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rejectUpgrade(w, r, CloseTryAgainLater, "too many connections")
+	}))
+	defer srv.Close()
+
+	conn, br, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// The close frame may arrive with the handshake response and sit in
+	// the dialer's buffer.
+	var r io.Reader = conn
+	if br != nil {
+		r = io.MultiReader(br, conn)
+	}
+	code, reason := readClose(t, conn, r)
+	if CloseCode(code) != CloseTryAgainLater || reason != "too many connections" {
+		t.Errorf("close frame = %d %q, want %d %q", code, reason, CloseTryAgainLater, "too many connections")
+	}
+}
+
+func TestCloseCodeFor(t *testing.T) {
+	if code, _ := closeCodeFor(ws.ErrProtocolMaskRequired); code != CloseProtocolError {
+		t.Errorf("protocol error -> %d, want %d", code, CloseProtocolError)
+	}
+	if code, _ := closeCodeFor(net.ErrClosed); code != CloseNormal {
+		t.Errorf("closed conn -> %d, want %d", code, CloseNormal)
+	}
+}
+
+// closeWithSession closes a connection whose session is in Redis with code
+// and returns the session store and the session's token.
+func closeWithSession(t *testing.T, code CloseCode, setup func(ctx context.Context, sessions *session.Store, sid string)) (*session.Store, string) {
+	t.Helper()
+	rdb := testutil.Redis(t)
+	sessions := session.NewStoreWithClient(rdb, "ws-test")
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	epoll, err := NewEpoll()
+	if err != nil {
+		t.Skipf("epoll unavailable: %v", err)
+	}
+	t.Cleanup(func() { epoll.Close() })
+	s.epoll = epoll
+	s.sessionStore = sessions
+
+	ctx := context.Background()
+	token, err := sessions.Create(ctx, c.ID)
+	if err != nil {
+		t.Fatalf("Create session: %v", err)
+	}
+	if setup != nil {
+		setup(ctx, sessions, c.ID)
+	}
+	s.conns.Add(c)
+	go io.Copy(io.Discard, client)
+	s.CloseConnection(c, code, "")
+	return sessions, token
+}
+
+func TestCloseConnection_BanKeepsSessionForAppealWindow(t *testing.T) {
+	tests := []struct {
+		code CloseCode
+		kept bool
+	}{
+		{ClosePolicyViolation, true},
+		{CloseNormal, false},
+		{CloseFlood, false},
+	}
+	for _, tt := range tests {
+		rdb := testutil.Redis(t)
+		sessions, token := closeWithSession(t, tt.code, nil)
+
+		_, err := sessions.Authenticate(context.Background(), "conn-1", token)
+		if kept := err == nil; kept != tt.kept {
+			t.Errorf("close %d: Authenticate err = %v, want kept=%v", tt.code, err, tt.kept)
+		}
+		if !tt.kept {
+			continue
+		}
+		if ttl := rdb.TTL(context.Background(), session.SessionPrefix+"conn-1").Val(); ttl <= 0 || ttl > session.AppealWindow {
+			t.Errorf("close %d: session TTL = %v, want at most %v", tt.code, ttl, session.AppealWindow)
+		}
+	}
+}
+
+func TestCloseConnection_BannedUserCanAppeal(t *testing.T) {
+	store := appeal.NewStore(testutil.Postgres(t))
+	var bans *ban.Store
+	sessions, token := closeWithSession(t, ClosePolicyViolation, func(ctx context.Context, sessions *session.Store, sid string) {
+		if err := sessions.SetFingerprint(ctx, sid, "fp-banned"); err != nil {
+			t.Fatalf("SetFingerprint: %v", err)
+		}
+		bans = ban.NewStore(sessions.Client())
+		if err := bans.Ban(ctx, "fp-banned", time.Hour, "spam"); err != nil {
+			t.Fatalf("Ban: %v", err)
+		}
+	})
+
+	body := `{"session_id":"conn-1","session_token":"` + token +
+		`","fingerprint":"fp-banned","statement":"I was reported by mistake."}`
+	rec := httptest.NewRecorder()
+	appeal.NewHandler(store, bans, sessions, nil).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/api/appeals", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("appeal after the ban's close: status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+}
@@ -3,10 +3,14 @@ package ws
 import (
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsutil"
+	"github.com/whisper/chat-app/internal/geoip"
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/pkg/protocol"
 )
 
 // Connection represents a single WebSocket client connection with its
@@ -16,21 +20,151 @@ type Connection struct {
 	Conn       net.Conn  // underlying TCP connection
 	Fd         int       // file descriptor for epoll lookups
 	CreatedAt  time.Time // when the connection was established
-	LastPing   time.Time // last heartbeat received from the client
+	LastPing   time.Time // last frame of any kind, pongs included, read from the client
+	Location   geoip.Location // where the client IP is located; empty without GeoIP
 	writeMu    sync.Mutex // serializes writes to this connection
-	processing int32      // atomic flag: 0 = idle, 1 = being read by handleConn
+	processing int32      // atomic flag: 0 = idle, 1 = queued or being read by a worker
+	rtt        atomic.Int64 // latest heartbeat round-trip time in nanoseconds
+	pingSent   atomic.Int64 // unix nanos of the latest heartbeat ping
+	lastPong   atomic.Int64 // unix nanos of the latest pong read
+	closed     atomic.Bool  // set by the first Close or CloseWithCode
+	lastActive atomic.Int64 // unix nanos of the last client message other than ping
+	batching   atomic.Bool  // the client accepts batch frames; see WriteMessage
+
+	// Messages waiting for the write mutex, coalesced into one batch frame
+	// by whichever writer gets it next. Only used when batching.
+	pendingMu sync.Mutex
+	pending   []outbound
+
+	// Fragmented message assembly. Only the worker holding the connection
+	// (see claim) touches these.
+	fragmented bool   // a data frame with FIN=0 was read; continuations follow
+	message    []byte // payload of the fragments read so far
+	dropping   bool   // the message in progress was rejected; discard the rest
+	receivedAt time.Time // when the message being handled was read
+	frames     frameBucket // inbound frame rate limit, see ServerConfig.MaxFrameRate
+	reader     wsutil.Reader // frame reader reused by handleFrame
+}
+
+// outbound is a message waiting in Connection.pending.
+type outbound struct {
+	data    []byte
+	written func(time.Time) // see WriteMessageTracked; may be nil
 }
 
 // WriteMessage sends a WebSocket text frame to this connection. The write
 // mutex ensures that concurrent goroutines do not interleave frame bytes.
+//
+// For a client that accepts batches, messages that queue up behind a slow
+// write are sent together in one batch frame by the next writer. A caller
+// whose message another writer sent gets nil even if that write failed;
+// the failure surfaces on the connection's next write or read.
 func (c *Connection) WriteMessage(data []byte) error {
+	return c.WriteMessageTracked(data, nil)
+}
+
+// WriteMessageTracked is WriteMessage that also calls written with the time
+// the frame carrying data was written to the socket, which for a batched
+// message may be another writer's frame. written is not called if that
+// write fails.
+func (c *Connection) WriteMessageTracked(data []byte, written func(time.Time)) error {
+	if !c.batching.Load() {
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		if err := wsutil.WriteServerMessage(c.Conn, ws.OpText, data); err != nil {
+			return err
+		}
+		if written != nil {
+			written(time.Now())
+		}
+		return nil
+	}
+
+	c.pendingMu.Lock()
+	c.pending = append(c.pending, outbound{data: data, written: written})
+	c.pendingMu.Unlock()
+
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
-	return wsutil.WriteServerMessage(c.Conn, ws.OpText, data)
+	c.pendingMu.Lock()
+	msgs := c.pending
+	c.pending = nil
+	c.pendingMu.Unlock()
+
+	var batch [][]byte
+	for len(msgs) > 0 {
+		n := min(len(msgs), protocol.MaxBatchSize)
+		frame := msgs[0].data
+		if n > 1 {
+			batch = batch[:0]
+			for _, m := range msgs[:n] {
+				batch = append(batch, m.data)
+			}
+			frame = protocol.AppendBatch(nil, batch)
+			metrics.BatchSize.WithLabelValues("outbound").Observe(float64(n))
+		}
+		if err := wsutil.WriteServerMessage(c.Conn, ws.OpText, frame); err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, m := range msgs[:n] {
+			if m.written != nil {
+				m.written(now)
+			}
+		}
+		msgs = msgs[n:]
+	}
+	return nil
+}
+
+// ReceivedAt returns when the message being handled was read from the
+// client. It is only meaningful inside the message callback, where
+// messages of a batch all share the time their frame was read.
+func (c *Connection) ReceivedAt() time.Time {
+	return c.receivedAt
+}
+
+// touch records that the client sent a message other than a ping.
+func (c *Connection) touch() {
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+// LastActive returns when the client last sent a message other than a
+// ping, or when it connected if it has sent none.
+func (c *Connection) LastActive() time.Time {
+	if ns := c.lastActive.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return c.CreatedAt
 }
 
-// Close closes the underlying network connection.
+// claim marks the connection as being read. It returns false if another
+// worker already holds it, which happens because epoll is level-triggered
+// and keeps reporting the fd until the frame has been consumed.
+func (c *Connection) claim() bool {
+	return atomic.CompareAndSwapInt32(&c.processing, 0, 1)
+}
+
+// readState is the frame-reader state for the next frame: continuation
+// frames are only valid while a fragmented message is in progress.
+func (c *Connection) readState() ws.State {
+	if c.fragmented {
+		return ws.StateServerSide | ws.StateFragmented
+	}
+	return ws.StateServerSide
+}
+
+// release makes the connection available for the next dispatch.
+func (c *Connection) release() {
+	atomic.StoreInt32(&c.processing, 0)
+}
+
+// Close closes the underlying network connection without a close frame.
+// Use CloseWithCode to tell the client why.
 func (c *Connection) Close() error {
+	if !c.closed.CompareAndSwap(false, true) {
+		return nil
+	}
 	return c.Conn.Close()
 }
 
@@ -63,18 +197,26 @@ func (cm *ConnectionManager) Add(conn *Connection) {
 // connection, and removes it from both lookup maps. Returns true if the
 // connection was found and removed, false if it was already gone.
 func (cm *ConnectionManager) Remove(id string) bool {
-	cm.mu.Lock()
-	conn, ok := cm.byID[id]
-	if ok {
-		delete(cm.byID, id)
-		delete(cm.byFd, conn.Fd)
+	conn := cm.detach(id)
+	if conn != nil {
+		conn.Close()
 	}
-	cm.mu.Unlock()
+	return conn != nil
+}
 
-	if ok {
-		conn.Close()
+// detach removes a connection by session ID from both lookup maps without
+// closing it, so the caller can close it with a code. It returns nil if the
+// connection was already gone.
+func (cm *ConnectionManager) detach(id string) *Connection {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	conn, ok := cm.byID[id]
+	if !ok {
+		return nil
 	}
-	return ok
+	delete(cm.byID, id)
+	delete(cm.byFd, conn.Fd)
+	return conn
 }
 
 // RemoveByFd removes a connection by file descriptor, closes the underlying
@@ -0,0 +1,143 @@
+package ws
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// epollStats accumulates how long the event loop spends in epoll.Wait. A
+// loop that rarely blocks is saturated.
+type epollStats struct {
+	waits   atomic.Int64 // completed Wait calls
+	totalNs atomic.Int64 // time spent in Wait
+	lastNs  atomic.Int64 // duration of the latest Wait
+	lastAt  atomic.Int64 // unix nanos when the latest Wait returned
+}
+
+func (e *epollStats) record(d time.Duration, now time.Time) {
+	e.waits.Add(1)
+	e.totalNs.Add(int64(d))
+	e.lastNs.Store(int64(d))
+	e.lastAt.Store(now.UnixNano())
+}
+
+// RuntimeStats is the body of /debug/runtime.
+type RuntimeStats struct {
+	Goroutines int         `json:"goroutines"`
+	Epoll      EpollStats  `json:"epoll"`
+	Workers    WorkerStats `json:"workers"`
+	GC         GCStats     `json:"gc"`
+	Uptime     string      `json:"uptime"`
+}
+
+// EpollStats describes the event loop's epoll.Wait calls. Durations are in
+// milliseconds.
+type EpollStats struct {
+	Waits         int64   `json:"waits"`
+	AvgWaitMs     float64 `json:"avg_wait_ms"`
+	LastWaitMs    float64 `json:"last_wait_ms"`
+	SinceReturnMs float64 `json:"since_return_ms"` // time since the latest Wait returned; large when no client sends anything
+}
+
+// WorkerStats describes the read-worker pool and its dispatch queue.
+type WorkerStats struct {
+	Size        int     `json:"size"`
+	Busy        int64   `json:"busy"`
+	Utilization float64 `json:"utilization"`
+	QueueDepth  int     `json:"queue_depth"`
+	QueueSize   int     `json:"queue_size"`
+}
+
+// GCStats summarizes the heap and the garbage collector.
+type GCStats struct {
+	NumGC        uint32  `json:"num_gc"`
+	PauseTotalMs float64 `json:"pause_total_ms"`
+	LastPauseMs  float64 `json:"last_pause_ms"`
+	HeapAllocMB  float64 `json:"heap_alloc_mb"`
+	HeapObjects  uint64  `json:"heap_objects"`
+	NextGCMB     float64 `json:"next_gc_mb"`
+}
+
+// registerDebug adds /debug/pprof/ and /debug/runtime to mux behind the
+// DebugToken bearer token. The pprof handlers are mounted explicitly rather
+// than through the package's DefaultServeMux side effect.
+func (s *Server) registerDebug(mux *http.ServeMux) {
+	guard := func(h http.HandlerFunc) http.Handler {
+		return requireToken(s.config.DebugToken, h)
+	}
+	mux.Handle("/debug/pprof/", guard(pprof.Index))
+	mux.Handle("/debug/pprof/cmdline", guard(pprof.Cmdline))
+	mux.Handle("/debug/pprof/profile", guard(pprof.Profile))
+	mux.Handle("/debug/pprof/symbol", guard(pprof.Symbol))
+	mux.Handle("/debug/pprof/trace", guard(pprof.Trace))
+	mux.Handle("/debug/runtime", guard(s.handleRuntime))
+}
+
+// handleRuntime reports goroutine, event loop, worker pool and GC stats.
+func (s *Server) handleRuntime(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.RuntimeStats(time.Now()))
+}
+
+// RuntimeStats samples the server's runtime state. Reading GC stats stops
+// the world briefly, so this is for on-demand debugging, not scraping.
+func (s *Server) RuntimeStats(now time.Time) RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := RuntimeStats{
+		Goroutines: runtime.NumGoroutine(),
+		Workers: WorkerStats{
+			Size:       s.config.WorkerPoolSize,
+			Busy:       s.busyWorkers.Load(),
+			QueueDepth: len(s.dispatchQueue),
+			QueueSize:  cap(s.dispatchQueue),
+		},
+		GC: GCStats{
+			NumGC:        mem.NumGC,
+			PauseTotalMs: ms(time.Duration(mem.PauseTotalNs)),
+			LastPauseMs:  ms(time.Duration(mem.PauseNs[(mem.NumGC+255)%256])),
+			HeapAllocMB:  float64(mem.HeapAlloc) / (1 << 20),
+			HeapObjects:  mem.HeapObjects,
+			NextGCMB:     float64(mem.NextGC) / (1 << 20),
+		},
+	}
+	if !s.startedAt.IsZero() {
+		stats.Uptime = now.Sub(s.startedAt).Round(time.Second).String()
+	}
+	if s.config.WorkerPoolSize > 0 {
+		stats.Workers.Utilization = float64(stats.Workers.Busy) / float64(s.config.WorkerPoolSize)
+	}
+	if waits := s.epollStats.waits.Load(); waits > 0 {
+		stats.Epoll = EpollStats{
+			Waits:         waits,
+			AvgWaitMs:     ms(time.Duration(s.epollStats.totalNs.Load() / waits)),
+			LastWaitMs:    ms(time.Duration(s.epollStats.lastNs.Load())),
+			SinceReturnMs: ms(now.Sub(time.Unix(0, s.epollStats.lastAt.Load()))),
+		}
+	}
+	return stats
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// requireToken serves h only for requests carrying "Authorization: Bearer
+// <token>", compared in constant time.
+func requireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,56 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRegisterDebug_RequiresToken(t *testing.T) {
+	s := NewServer(ServerConfig{WorkerPoolSize: 4, DispatchQueue: 8, DebugToken: "secret"}, nil, nil)
+	mux := http.NewServeMux()
+	s.registerDebug(mux)
+
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", auth, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("pprof index with token: status %d, want 200", rec.Code)
+	}
+}
+
+func TestHandleRuntime(t *testing.T) {
+	s := NewServer(ServerConfig{WorkerPoolSize: 4, DispatchQueue: 8, DebugToken: "secret"}, nil, nil)
+	s.busyWorkers.Store(1)
+	s.epollStats.record(4*time.Millisecond, time.Now())
+	s.epollStats.record(2*time.Millisecond, time.Now())
+
+	rec := httptest.NewRecorder()
+	s.handleRuntime(rec, httptest.NewRequest(http.MethodGet, "/debug/runtime", nil))
+
+	var stats RuntimeStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if stats.Goroutines == 0 || stats.Workers.Size != 4 || stats.Workers.Utilization != 0.25 || stats.Workers.QueueSize != 8 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if stats.Epoll.Waits != 2 || stats.Epoll.AvgWaitMs != 3 || stats.Epoll.LastWaitMs != 2 {
+		t.Errorf("epoll stats = %+v, want 2 waits averaging 3ms, last 2ms", stats.Epoll)
+	}
+}
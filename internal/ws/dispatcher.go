@@ -1,15 +1,19 @@
 package ws
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"time"
 
-	"github.com/whisper/chat-app/internal/protocol"
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/pkg/protocol"
 )
 
 // MessageHandler is the callback signature for handling a parsed client message.
 // The msg parameter is the concrete struct returned by protocol.ParseClientMessage
-// (e.g., protocol.FindMatchMsg, protocol.ChatMsg, etc.).
+// (e.g., protocol.FindMatchMsg, protocol.ChatMsg, etc.), or a json.RawMessage
+// holding the whole message for a type the protocol package doesn't define.
 type MessageHandler func(conn *Connection, msg interface{})
 
 // MessageDispatcher routes incoming WebSocket messages to registered handlers
@@ -38,7 +42,9 @@ func (d *MessageDispatcher) SetServer(server *Server) {
 }
 
 // Register associates a MessageHandler with a message type. If a handler was
-// already registered for the given type, it is silently replaced.
+// already registered for the given type, it is silently replaced. Types the
+// protocol package doesn't define can be registered too, e.g. by an embedding
+// application; their handler receives the raw message.
 func (d *MessageDispatcher) Register(msgType string, handler MessageHandler) {
 	d.handlers[msgType] = handler
 }
@@ -46,15 +52,71 @@ func (d *MessageDispatcher) Register(msgType string, handler MessageHandler) {
 // Dispatch is the onMessage callback implementation. It parses the raw bytes
 // into a typed message, handles ping internally, and routes all other types to
 // the registered handler. Parse errors and unregistered types result in an
-// error message sent back to the client.
+// error message sent back to the client. Frames beyond the connection's
+// frame rate limit are dropped unparsed.
+//
+// data is a reused read buffer (see NewServer). Parsing copies everything
+// handlers receive, so handlers never see data itself.
 func (d *MessageDispatcher) Dispatch(conn *Connection, data []byte) {
-	msgType, msg, err := protocol.ParseClientMessage(data)
+	if !d.allowFrame(conn) {
+		return
+	}
+	msgType, msg, err := d.parse(data)
 	if err != nil {
 		log.Printf("ws: dispatch parse error session=%s: %v", conn.ID, err)
-		d.sendError(conn, "parse_error", "invalid message format")
+		d.sendError(conn, protocol.ErrParseError, "invalid message format")
 		return
 	}
+	if batch, ok := msg.(protocol.BatchMsg); ok {
+		d.dispatchBatch(conn, batch)
+		return
+	}
+	d.route(conn, msgType, msg)
+}
+
+// parse decodes one client message. A type unknown to the protocol package
+// but registered with a handler is passed on as a copy of the raw message,
+// since data may be a reused buffer.
+func (d *MessageDispatcher) parse(data []byte) (string, interface{}, error) {
+	msgType, msg, err := protocol.ParseClientMessage(data)
+	if errors.Is(err, protocol.ErrUnknownType) {
+		if _, ok := d.handlers[msgType]; ok {
+			return msgType, json.RawMessage(append([]byte(nil), data...)), nil
+		}
+	}
+	return msgType, msg, err
+}
+
+// dispatchBatch handles the messages of a batch frame in order, as if each
+// had arrived in its own frame: one that fails to parse is answered with an
+// error and the rest still run. Each message beyond the first is charged to
+// the frame rate limit like a frame of its own, and the rest of the batch is
+// dropped once the limit is hit. Batches do not nest. A client that sends a
+// batch accepts batches, so its outbound events are coalesced from now on.
+func (d *MessageDispatcher) dispatchBatch(conn *Connection, batch protocol.BatchMsg) {
+	conn.batching.Store(true)
+	metrics.BatchSize.WithLabelValues("inbound").Observe(float64(len(batch.Messages)))
+	for i, raw := range batch.Messages {
+		// The frame itself paid for the first message.
+		if i > 0 && !d.allowFrame(conn) {
+			return
+		}
+		msgType, msg, err := d.parse(raw)
+		if err != nil {
+			log.Printf("ws: dispatch parse error session=%s in batch: %v", conn.ID, err)
+			d.sendError(conn, protocol.ErrParseError, "invalid message format")
+			continue
+		}
+		if msgType == protocol.TypeBatch {
+			d.sendError(conn, protocol.ErrParseError, "batches cannot be nested")
+			continue
+		}
+		d.route(conn, msgType, msg)
+	}
+}
 
+// route hands one parsed message to its handler.
+func (d *MessageDispatcher) route(conn *Connection, msgType string, msg interface{}) {
 	// Built-in ping handler — respond immediately without requiring registration.
 	if msgType == protocol.TypePing {
 		d.sendPong(conn)
@@ -64,20 +126,18 @@ func (d *MessageDispatcher) Dispatch(conn *Connection, data []byte) {
 	handler, ok := d.handlers[msgType]
 	if !ok {
 		log.Printf("ws: unsupported message type=%q session=%s", msgType, conn.ID)
-		d.sendError(conn, "unsupported_type", "unsupported message type")
+		d.sendError(conn, protocol.ErrUnsupportedType, "unsupported message type")
 		return
 	}
 
+	conn.touch()
 	handler(conn, msg)
 }
 
 // sendError sends a structured error message back to the client. Errors during
 // message construction or transmission are logged but not propagated.
-func (d *MessageDispatcher) sendError(conn *Connection, code string, message string) {
-	data, err := protocol.NewServerMessage(protocol.TypeError, protocol.ErrorMsg{
-		Code:    code,
-		Message: message,
-	})
+func (d *MessageDispatcher) sendError(conn *Connection, code protocol.ErrorCode, message string) {
+	data, err := protocol.NewServerMessage(protocol.TypeError, protocol.NewError(code, message))
 	if err != nil {
 		log.Printf("ws: failed to build error message session=%s: %v", conn.ID, err)
 		return
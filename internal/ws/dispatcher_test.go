@@ -0,0 +1,41 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDispatch_CustomTypeGetsRawMessage(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	frames := readFrames(t, client)
+	d := NewMessageDispatcher(s)
+	var got []string
+	d.Register("poll_vote", func(_ *Connection, msg interface{}) {
+		raw, ok := msg.(json.RawMessage)
+		if !ok {
+			t.Fatalf("custom type handler got %T, want json.RawMessage", msg)
+		}
+		got = append(got, string(raw))
+	})
+
+	data := []byte(`{"type":"poll_vote","option":2}`)
+	d.Dispatch(c, data)
+	copy(data, "xxxxxxxx") // the read buffer is reused after Dispatch returns
+	d.Dispatch(c, []byte(`{"type":"batch","messages":[{"type":"poll_vote","option":3}]}`))
+
+	if len(got) != 2 || got[0] != `{"type":"poll_vote","option":2}` || got[1] != `{"type":"poll_vote","option":3}` {
+		t.Fatalf("handled %q, want both raw messages", got)
+	}
+
+	// Types that are neither defined nor registered are still rejected.
+	d.Dispatch(c, []byte(`{"type":"poll_close"}`))
+	select {
+	case data := <-frames:
+		if !isError(data, "parse_error") {
+			t.Errorf("unexpected reply %s", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected an error reply for an unregistered type")
+	}
+}
@@ -0,0 +1,83 @@
+package ws
+
+import (
+	"log"
+	"time"
+
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// shutdownCountdownInterval is how often the connections selected by
+// SetDrainCountdown are reminded of the shutdown deadline while draining.
+const shutdownCountdownInterval = 10 * time.Second
+
+// SetDrainCountdown selects the connections that are sent server_shutdown
+// again every shutdownCountdownInterval while the server drains, e.g. those
+// in a chat. Every connection gets the notice when the drain starts. It
+// must be called before Start.
+func (s *Server) SetDrainCountdown(fn func(c *Connection) bool) {
+	s.drainCountdown = fn
+}
+
+// drain tells every client that the server is shutting down and waits up
+// to timeout for the connections to close, sending the countdown selection
+// a reminder every interval. Connections keep being served meanwhile, so
+// chats carry on until the client leaves or is closed at the deadline.
+func (s *Server) drain(timeout, interval time.Duration) {
+	deadline := time.Now().Add(timeout)
+	log.Printf("ws: draining %d connections (%s timeout)...", s.conns.Count(), timeout)
+	s.sendShutdownNotice(s.conns.All(), deadline)
+
+	drainDeadline := time.NewTimer(timeout)
+	defer drainDeadline.Stop()
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	countdown := time.NewTicker(interval)
+	defer countdown.Stop()
+
+	for {
+		select {
+		case <-drainDeadline.C:
+			if remaining := s.conns.Count(); remaining > 0 {
+				log.Printf("ws: drain timeout, force-closing %d connections", remaining)
+			}
+			return
+		case <-countdown.C:
+			if s.drainCountdown == nil {
+				continue
+			}
+			var conns []*Connection
+			for _, c := range s.conns.All() {
+				if s.drainCountdown(c) {
+					conns = append(conns, c)
+				}
+			}
+			s.sendShutdownNotice(conns, deadline)
+		case <-ticker.C:
+			remaining := s.conns.Count()
+			if remaining == 0 {
+				log.Println("ws: all connections drained successfully")
+				return
+			}
+			log.Printf("ws: draining... %d connections remaining", remaining)
+		}
+	}
+}
+
+// sendShutdownNotice sends server_shutdown to conns, announcing that their
+// connections are closed at deadline.
+func (s *Server) sendShutdownNotice(conns []*Connection, deadline time.Time) {
+	if len(conns) == 0 {
+		return
+	}
+	data, err := protocol.NewServerMessage(protocol.TypeServerShutdown, protocol.ServerShutdownMsg{
+		Deadline:         deadline.Unix(),
+		SecondsRemaining: max(0, int(time.Until(deadline).Round(time.Second).Seconds())),
+	})
+	if err != nil {
+		return
+	}
+	for _, c := range conns {
+		_ = c.WriteMessage(data)
+	}
+}
@@ -0,0 +1,80 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// shutdownNotices collects the server_shutdown messages among frames until
+// it is closed or quiet for wait.
+func shutdownNotices(t *testing.T, frames <-chan []byte, wait time.Duration) []protocol.ServerShutdownMsg {
+	t.Helper()
+	var notices []protocol.ServerShutdownMsg
+	for {
+		select {
+		case data, ok := <-frames:
+			if !ok {
+				return notices
+			}
+			var msg protocol.ServerShutdownMsg
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatalf("invalid frame %s: %v", data, err)
+			}
+			if msg.Type == protocol.TypeServerShutdown {
+				notices = append(notices, msg)
+			}
+		case <-time.After(wait):
+			return notices
+		}
+	}
+}
+
+func TestDrain_SendsNoticeAndCountdown(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	frames := readFrames(t, client)
+	s.conns.Add(c)
+	s.SetDrainCountdown(func(*Connection) bool { return true })
+
+	start := time.Now()
+	s.drain(250*time.Millisecond, 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("drain returned after %s with a connection still open", elapsed)
+	}
+
+	notices := shutdownNotices(t, frames, 50*time.Millisecond)
+	if len(notices) < 2 {
+		t.Fatalf("expected the notice and a countdown, got %d notices", len(notices))
+	}
+	deadline := start.Add(250 * time.Millisecond).Unix()
+	for _, n := range notices {
+		if n.Deadline < deadline-1 || n.Deadline > deadline+1 {
+			t.Errorf("deadline = %d, want about %d", n.Deadline, deadline)
+		}
+	}
+}
+
+func TestDrain_CountdownOnlyToSelectedConnections(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	frames := readFrames(t, client)
+	s.conns.Add(c)
+	s.SetDrainCountdown(func(*Connection) bool { return false })
+
+	s.drain(250*time.Millisecond, 50*time.Millisecond)
+
+	if notices := shutdownNotices(t, frames, 50*time.Millisecond); len(notices) != 1 {
+		t.Errorf("expected only the drain notice, got %d notices", len(notices))
+	}
+}
+
+func TestDrain_ReturnsOnceConnectionsLeave(t *testing.T) {
+	s, _, _ := newTestWorkerServer(t, ServerConfig{}, nil)
+
+	start := time.Now()
+	s.drain(5*time.Second, time.Second)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("drain with no connections took %s", elapsed)
+	}
+}
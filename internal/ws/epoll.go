@@ -100,7 +100,11 @@ func (e *Epoll) Close() error {
 // socketFD extracts the file descriptor from a net.Conn using the
 // SyscallConn interface. This avoids duplicating the file descriptor
 // (which File() does), keeping the original fd valid for epoll registration.
+// TLS connections are unwrapped to the underlying TCP socket.
 func socketFD(conn net.Conn) int {
+	if tc, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = tc.NetConn()
+	}
 	sc, ok := conn.(syscall.Conn)
 	if !ok {
 		return -1
@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"log"
+	"time"
+
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// floodCloseSeconds is how many seconds' worth of frames at the allowed rate
+// a connection may have dropped in one flood before it is closed.
+const floodCloseSeconds = 5
+
+// frameBucket is the token bucket behind ServerConfig.MaxFrameRate. It
+// refills at the rate and holds up to one second's worth of frames. Only
+// the worker holding the connection (see claim) touches it.
+type frameBucket struct {
+	tokens  float64
+	last    time.Time
+	limited bool // a frame was dropped and the bucket has not refilled since
+	dropped int  // frames dropped since limited was set
+}
+
+// take spends a token on a frame arriving at now, returning false if the
+// bucket is empty. A flood ends once the bucket has refilled completely.
+func (b *frameBucket) take(rate int, now time.Time) bool {
+	burst := float64(rate)
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*burst)
+	}
+	b.last = now
+
+	if b.limited && b.tokens >= burst {
+		b.limited, b.dropped = false, 0
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+	b.limited = true
+	b.dropped++
+	return false
+}
+
+// allowFrame applies ServerConfig.MaxFrameRate to a data frame before it is
+// parsed, so a flood of tiny frames costs neither JSON parsing nor Redis
+// round trips. The first frame dropped in a flood is answered with
+// rate_limited; the rest are dropped silently rather than answered in kind.
+// A connection that keeps flooding is closed with CloseFlood.
+func (d *MessageDispatcher) allowFrame(conn *Connection) bool {
+	if d.server == nil || d.server.config.MaxFrameRate <= 0 {
+		return true
+	}
+	rate := d.server.config.MaxFrameRate
+	if conn.frames.take(rate, time.Now()) {
+		return true
+	}
+	metrics.FloodFramesDroppedTotal.Inc()
+
+	switch dropped := conn.frames.dropped; {
+	case dropped == 1:
+		log.Printf("ws: frame rate limit hit session=%s (max %d/s)", conn.ID, rate)
+		data, err := protocol.NewServerMessage(protocol.TypeRateLimited, protocol.RateLimitedMsg{
+			RetryAfter: 1,
+			Limit:      "frames",
+		})
+		if err == nil {
+			_ = conn.WriteMessage(data)
+		}
+	case dropped >= rate*floodCloseSeconds:
+		log.Printf("ws: closing flooding session=%s after %d dropped frames", conn.ID, dropped)
+		metrics.FloodClosesTotal.Inc()
+		d.server.CloseConnection(conn, CloseFlood, "too many frames")
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+func TestFrameBucket_AllowsBurstThenRefills(t *testing.T) {
+	var b frameBucket
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if !b.take(5, now) {
+			t.Fatalf("frame %d of the burst was dropped", i+1)
+		}
+	}
+	if b.take(5, now) {
+		t.Fatal("expected a frame beyond the burst to be dropped")
+	}
+	if !b.limited || b.dropped != 1 {
+		t.Fatalf("limited=%v dropped=%d, want true 1", b.limited, b.dropped)
+	}
+
+	// 200ms at 5/s refills one frame, which does not end the flood.
+	now = now.Add(200 * time.Millisecond)
+	if !b.take(5, now) {
+		t.Fatal("expected a refilled token to be spent")
+	}
+	if !b.limited {
+		t.Fatal("expected the flood to last until the bucket refills")
+	}
+
+	now = now.Add(time.Second)
+	if !b.take(5, now) {
+		t.Fatal("expected a frame after a full refill")
+	}
+	if b.limited || b.dropped != 0 {
+		t.Errorf("limited=%v dropped=%d after a full refill, want false 0", b.limited, b.dropped)
+	}
+}
+
+func TestDispatch_DropsFramesBeyondRateLimit(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{MaxFrameRate: 2}, nil)
+	frames := readFrames(t, client)
+	d := NewMessageDispatcher(s)
+	handled := 0
+	d.Register(protocol.TypeFindMatch, func(*Connection, interface{}) { handled++ })
+
+	for i := 0; i < 4; i++ {
+		d.Dispatch(c, []byte(`{"type":"find_match","interests":[]}`))
+	}
+	if handled != 2 {
+		t.Fatalf("handled %d frames, want 2", handled)
+	}
+
+	select {
+	case data := <-frames:
+		var env struct {
+			Type  string `json:"type"`
+			Limit string `json:"limit"`
+		}
+		if err := json.Unmarshal(data, &env); err != nil || env.Type != protocol.TypeRateLimited || env.Limit != "frames" {
+			t.Fatalf("expected a frames rate_limited reply, got %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a rate_limited reply")
+	}
+	select {
+	case data := <-frames:
+		t.Fatalf("expected one reply per flood, also got %s", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDispatch_ChargesEachBatchedMessage(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{MaxFrameRate: 3}, nil)
+	readFrames(t, client)
+	d := NewMessageDispatcher(s)
+	handled := 0
+	d.Register(protocol.TypeFindMatch, func(*Connection, interface{}) { handled++ })
+
+	msg := `{"type":"find_match","interests":[]}`
+	d.Dispatch(c, []byte(`{"type":"batch","messages":[`+msg+`,`+msg+`,`+msg+`,`+msg+`,`+msg+`]}`))
+	if handled != 3 {
+		t.Fatalf("handled %d batched messages, want the 3 the frame rate allows", handled)
+	}
+	d.Dispatch(c, []byte(msg))
+	if handled != 3 {
+		t.Error("expected the bucket to be spent by the batch")
+	}
+}
+
+func TestDispatch_ClosesFloodingConnection(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{MaxFrameRate: 1}, nil)
+	epoll, err := NewEpoll()
+	if err != nil {
+		t.Skipf("epoll unavailable: %v", err)
+	}
+	t.Cleanup(func() { epoll.Close() })
+	s.epoll = epoll
+	s.conns.Add(c)
+	d := NewMessageDispatcher(s)
+	d.Register(protocol.TypeFindMatch, func(*Connection, interface{}) {})
+
+	// One frame spends the burst; the rest are dropped until the close.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1+floodCloseSeconds; i++ {
+			d.Dispatch(c, []byte(`{"type":"find_match","interests":[]}`))
+		}
+	}()
+
+	code, _ := readClose(t, client, client)
+	if CloseCode(code) != CloseFlood {
+		t.Errorf("close code = %d, want %d", code, CloseFlood)
+	}
+	<-done
+	if s.conns.Count() != 0 {
+		t.Error("expected the flooding connection to be removed")
+	}
+}
@@ -0,0 +1,131 @@
+package ws
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// writeFrames writes masked client frames to conn in the background. The
+// pipe is synchronous, so each write completes as the server reads it.
+func writeFrames(conn net.Conn, frames ...ws.Frame) {
+	go func() {
+		for _, f := range frames {
+			if err := ws.WriteFrame(conn, ws.MaskFrameInPlace(f)); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// readControlFrames reads frames sent by the server to conn in the
+// background, so the server's replies to control frames do not block.
+func readControlFrames(conn net.Conn) <-chan ws.Frame {
+	frames := make(chan ws.Frame, 8)
+	go func() {
+		defer close(frames)
+		for {
+			f, err := ws.ReadFrame(conn)
+			if err != nil {
+				return
+			}
+			frames <- f
+		}
+	}()
+	return frames
+}
+
+func TestHandleFrame_AssemblesFragmentedMessage(t *testing.T) {
+	var got []string
+	s, c, client := newTestWorkerServer(t, ServerConfig{MaxFrameSize: 64}, func(_ *Connection, data []byte) {
+		got = append(got, string(data))
+	})
+
+	pongs := readControlFrames(client)
+	writeFrames(client,
+		ws.NewFrame(ws.OpText, false, []byte(`{"type":`)),
+		ws.NewPingFrame([]byte("mid-message ping")), // control frames may interleave
+		ws.NewFrame(ws.OpContinuation, false, []byte(`"ping"`)),
+		ws.NewFrame(ws.OpContinuation, true, []byte(`}`)),
+		ws.NewTextFrame([]byte("next")),
+	)
+	for i := 0; i < 5; i++ {
+		s.handleFrame(c, false)
+	}
+
+	if len(got) != 2 || got[0] != `{"type":"ping"}` || got[1] != "next" {
+		t.Fatalf("delivered %q, want the assembled message then %q", got, "next")
+	}
+	if f := <-pongs; f.Header.OpCode != ws.OpPong || string(f.Payload) != "mid-message ping" {
+		t.Errorf("expected the ping to be answered with an echoing pong, got %v %q", f.Header.OpCode, f.Payload)
+	}
+}
+
+func TestHandleFrame_FragmentedMessageTooLarge(t *testing.T) {
+	var got []string
+	s, c, client := newTestWorkerServer(t, ServerConfig{MaxFrameSize: 8}, func(_ *Connection, data []byte) {
+		got = append(got, string(data))
+	})
+
+	// Each fragment fits MaxFrameSize, the message does not.
+	writeFrames(client,
+		ws.NewFrame(ws.OpText, false, []byte("12345")),
+		ws.NewFrame(ws.OpContinuation, false, []byte("67890")),
+		ws.NewFrame(ws.OpContinuation, true, []byte("x")),
+		ws.NewTextFrame([]byte("ok")),
+	)
+	s.handleFrame(c, false)
+
+	errReply := make(chan []byte, 1)
+	go func() {
+		data, _ := wsutil.ReadServerText(client)
+		errReply <- data
+	}()
+	s.handleFrame(c, false) // exceeds the limit; answered with one error
+	select {
+	case data := <-errReply:
+		var msg protocol.ErrorMsg
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Code != "frame_too_large" {
+			t.Fatalf("expected frame_too_large error, got %s", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no error reply for the oversized message")
+	}
+
+	s.handleFrame(c, false) // rest of the rejected message is discarded
+	s.handleFrame(c, false)
+	if len(got) != 1 || got[0] != "ok" {
+		t.Fatalf("delivered %q, want only %q", got, "ok")
+	}
+}
+
+func TestHandleFrame_UnexpectedContinuationClosesConnection(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, func(*Connection, []byte) {
+		t.Error("a stray continuation must not be delivered")
+	})
+	epoll, err := NewEpoll()
+	if err != nil {
+		t.Skipf("epoll unavailable: %v", err)
+	}
+	t.Cleanup(func() { epoll.Close() })
+	s.epoll = epoll
+	s.conns.Add(c)
+
+	writeFrames(client, ws.NewFrame(ws.OpContinuation, true, []byte("orphan")))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.handleFrame(c, false)
+	}()
+
+	code, _ := readClose(t, client, client)
+	if CloseCode(code) != CloseProtocolError {
+		t.Errorf("close code = %d, want %d", code, CloseProtocolError)
+	}
+	<-done
+}
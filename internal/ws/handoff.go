@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Handoff lets a session outlive its connection, so a client whose socket
+// was killed (e.g. a backgrounded mobile app) can come back as the same
+// session. A client resumes by connecting with
+// ?resume=<session_id>&token=<session_token>.
+type Handoff struct {
+	// Detach is called when a connection is lost (a read or write error or
+	// a heartbeat timeout) or closed by Shutdown, before the disconnect
+	// callback. Returning true
+	// means the session was kept for a later resume: the disconnect callback
+	// is skipped and the Redis session is left in place. Connections the
+	// server closes deliberately (bans, operator disconnects, floods, idle
+	// timeouts) always end the session.
+	Detach func(sessionID string) bool
+
+	// Claim is called for a connection opened with ?resume=. Returning true
+	// gives the new connection that session instead of a fresh one.
+	Claim func(ctx context.Context, sessionID, token string) bool
+
+	// Resumed is called once session_created has been sent on a resumed
+	// connection, to restore whatever the session was doing.
+	Resumed func(c *Connection)
+}
+
+// claimTimeout bounds the Claim callback during an upgrade.
+const claimTimeout = 3 * time.Second
+
+// SetHandoff enables session handoff. It must be called before Start.
+func (s *Server) SetHandoff(h *Handoff) {
+	s.handoff = h
+}
+
+// claimResume returns the session a new connection resumes, or "" when it
+// asked for none or the claim was refused.
+func (s *Server) claimResume(r *http.Request) (sessionID, token string) {
+	if s.handoff == nil || s.handoff.Claim == nil {
+		return "", ""
+	}
+	q := r.URL.Query()
+	sessionID, token = q.Get("resume"), q.Get("token")
+	if sessionID == "" || token == "" || s.conns.Get(sessionID) != nil {
+		return "", ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), claimTimeout)
+	defer cancel()
+	if !s.handoff.Claim(ctx, sessionID, token) {
+		return "", ""
+	}
+	return sessionID, token
+}
@@ -0,0 +1,116 @@
+package ws
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCloseConnection_DetachedSkipsDisconnect(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	epoll, err := NewEpoll()
+	if err != nil {
+		t.Skipf("epoll unavailable: %v", err)
+	}
+	t.Cleanup(func() { epoll.Close() })
+	s.epoll = epoll
+
+	disconnected := false
+	s.SetOnDisconnect(func(string) { disconnected = true })
+	s.SetHandoff(&Handoff{Detach: func(sid string) bool { return sid == c.ID }})
+	s.conns.Add(c)
+
+	go io.Copy(io.Discard, client) // drain the close frame
+	s.dropConnection(c, CloseHeartbeatTimeout, "heartbeat timeout")
+
+	if disconnected {
+		t.Error("expected the disconnect callback to be skipped for a detached session")
+	}
+	if s.conns.Count() != 0 {
+		t.Error("expected the connection to be removed")
+	}
+}
+
+func TestCloseConnection_DeliberateCloseNeverDetaches(t *testing.T) {
+	for _, code := range []CloseCode{ClosePolicyViolation, CloseFlood, CloseIdleTimeout, CloseNormal} {
+		s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+		epoll, err := NewEpoll()
+		if err != nil {
+			t.Skipf("epoll unavailable: %v", err)
+		}
+		t.Cleanup(func() { epoll.Close() })
+		s.epoll = epoll
+
+		detached, disconnected := false, false
+		s.SetOnDisconnect(func(string) { disconnected = true })
+		s.SetHandoff(&Handoff{Detach: func(string) bool { detached = true; return true }})
+		s.conns.Add(c)
+
+		go io.Copy(io.Discard, client)
+		s.CloseConnection(c, code, "")
+
+		if detached || !disconnected {
+			t.Errorf("close %d: detached=%v disconnected=%v, want the session ended", code, detached, disconnected)
+		}
+	}
+}
+
+func TestShutdown_HandsOffRemainingSessions(t *testing.T) {
+	s := NewServer(ServerConfig{DrainTimeout: 50 * time.Millisecond}, nil, nil)
+	s.httpServer = &http.Server{}
+	epoll, err := NewEpoll()
+	if err != nil {
+		t.Skipf("epoll unavailable: %v", err)
+	}
+	s.epoll = epoll
+
+	serverSide, clientSide := net.Pipe()
+	t.Cleanup(func() { clientSide.Close() })
+	c := &Connection{ID: "conn-1", Conn: serverSide}
+	s.conns.Add(c)
+	go io.Copy(io.Discard, clientSide)
+
+	var detached []string
+	disconnected := false
+	s.SetOnDisconnect(func(string) { disconnected = true })
+	s.SetHandoff(&Handoff{Detach: func(sid string) bool { detached = append(detached, sid); return true }})
+
+	if err := s.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if len(detached) != 1 || detached[0] != "conn-1" || disconnected {
+		t.Errorf("detached=%v disconnected=%v, want the session handed off", detached, disconnected)
+	}
+	if s.conns.Count() != 0 {
+		t.Error("expected the connection to be closed")
+	}
+}
+
+func TestClaimResume(t *testing.T) {
+	s, c, _ := newTestWorkerServer(t, ServerConfig{}, nil)
+	s.conns.Add(c)
+	s.SetHandoff(&Handoff{Claim: func(_ context.Context, sid, token string) bool {
+		return token == "secret"
+	}})
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"no resume", "/ws", ""},
+		{"claimed", "/ws?resume=sess-1&token=secret", "sess-1"},
+		{"refused", "/ws?resume=sess-1&token=nope", ""},
+		{"missing token", "/ws?resume=sess-1", ""},
+		{"still connected here", "/ws?resume=" + c.ID + "&token=secret", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := s.claimResume(httptest.NewRequest("GET", tt.target, nil)); got != tt.want {
+			t.Errorf("%s: claimResume = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
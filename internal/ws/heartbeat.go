@@ -1,10 +1,13 @@
 package ws
 
 import (
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/gobwas/ws"
+
+	"github.com/whisper/chat-app/internal/metrics"
 )
 
 // HeartbeatConfig holds heartbeat tuning parameters.
@@ -21,56 +24,161 @@ func DefaultHeartbeatConfig() HeartbeatConfig {
 	}
 }
 
+// Validate reports whether the config can drive the heartbeat loop.
+func (c HeartbeatConfig) Validate() error {
+	if c.Interval <= 0 || c.Timeout <= 0 {
+		return fmt.Errorf("ws: heartbeat interval and timeout must be positive (got %s/%s)", c.Interval, c.Timeout)
+	}
+	return nil
+}
+
+// HeartbeatConfig returns the heartbeat parameters currently in effect.
+func (s *Server) HeartbeatConfig() HeartbeatConfig {
+	return *s.heartbeat.Load()
+}
+
+// SetHeartbeatConfig swaps the heartbeat parameters. It is safe to call while
+// the server is running; a changed interval takes effect on the next tick.
+func (s *Server) SetHeartbeatConfig(config HeartbeatConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	s.heartbeat.Store(&config)
+	return nil
+}
+
+// heartbeatSlots is how many rounds each heartbeat interval is split into.
+// Every round handles the connections whose fd falls in its slot, so pings
+// go out in ten small bursts per interval instead of one burst to every
+// connection at once.
+const heartbeatSlots = 10
+
 // StartHeartbeat begins a background goroutine that periodically sends
 // WebSocket ping frames to all connections and closes those that have gone
-// stale (no successful reads within Interval + Timeout). It returns
-// immediately; the goroutine exits when the server's done channel is closed.
-func StartHeartbeat(server *Server, config HeartbeatConfig) {
+// stale (no successful reads within Interval + Timeout). Each connection is
+// visited once per Interval, spread over heartbeatSlots rounds. The
+// parameters are re-read on every round so SetHeartbeatConfig applies
+// without a restart. It returns immediately; the goroutine exits when the
+// server's done channel is closed.
+func StartHeartbeat(server *Server) {
 	go func() {
-		ticker := time.NewTicker(config.Interval)
+		interval := server.HeartbeatConfig().Interval
+		ticker := time.NewTicker(heartbeatRound(interval))
 		defer ticker.Stop()
 
+		slot := 0
 		for {
 			select {
 			case <-server.done:
 				return
-			case <-ticker.C:
-				checkConnections(server, config)
+			case now := <-ticker.C:
+				config := server.HeartbeatConfig()
+				checkConnections(server, config, slot, now)
+				slot = (slot + 1) % heartbeatSlots
+				if config.Interval != interval {
+					interval = config.Interval
+					ticker.Reset(heartbeatRound(interval))
+				}
 			}
 		}
 	}()
 }
 
-// checkConnections iterates over all active connections. Connections that have
-// not had a successful read within Interval + Timeout are considered dead and
-// are removed. All other connections receive a WebSocket-level ping frame
-// (opcode 0x9) which the browser answers automatically with a pong.
-func checkConnections(server *Server, config HeartbeatConfig) {
+// heartbeatRound returns the time between heartbeat rounds for interval.
+func heartbeatRound(interval time.Duration) time.Duration {
+	return max(interval/heartbeatSlots, time.Millisecond)
+}
+
+// checkConnections handles the connections in one heartbeat slot, those
+// whose fd modulo heartbeatSlots equals slot. Connections that have not had
+// a successful read within Interval + Timeout are considered dead and are
+// removed. Connections that sent something within the last Timeout are
+// skipped: that read already proves the client is alive, and the next round
+// still pings them well before the deadline. All others receive a
+// WebSocket-level ping frame (opcode 0x9) which the browser answers
+// automatically with a pong, so clients need not send application pings to
+// stay connected. A ping whose predecessor was never answered is counted as
+// a missed pong: such clients are kept alive only by the data they send.
+func checkConnections(server *Server, config HeartbeatConfig, slot int, now time.Time) {
 	deadline := config.Interval + config.Timeout
-	now := time.Now()
 
 	for _, c := range server.Connections().All() {
-		if now.Sub(c.LastPing) > deadline {
+		if uint(c.Fd)%heartbeatSlots != uint(slot) {
+			continue
+		}
+		quiet := now.Sub(c.LastPing)
+		if quiet > deadline {
 			log.Printf("ws: heartbeat timeout session=%s last_activity=%s ago",
-				c.ID, now.Sub(c.LastPing).Round(time.Second))
-			server.RemoveConnection(c)
+				c.ID, quiet.Round(time.Second))
+			metrics.HeartbeatTimeoutsTotal.Inc()
+			server.dropConnection(c, CloseHeartbeatTimeout, "heartbeat timeout")
+			continue
+		}
+		if quiet <= config.Timeout {
+			metrics.HeartbeatPingsTotal.WithLabelValues("skipped").Inc()
 			continue
 		}
 
+		if c.pongMissed() {
+			metrics.HeartbeatPongsTotal.WithLabelValues("missed").Inc()
+		}
+
 		// Send a WebSocket protocol-level ping frame. The write mutex on the
 		// connection serializes this with any concurrent application writes.
 		if err := c.WritePing(); err != nil {
 			log.Printf("ws: heartbeat ping failed session=%s: %v", c.ID, err)
-			server.RemoveConnection(c)
+			metrics.HeartbeatPingsTotal.WithLabelValues("failed").Inc()
+			server.dropConnection(c, CloseNormal, "")
+			continue
 		}
+		metrics.HeartbeatPingsTotal.WithLabelValues("sent").Inc()
 	}
 }
 
 // WritePing sends a WebSocket protocol-level ping frame (opcode 0x9) on the
-// connection. The write mutex ensures this does not interleave with other
-// outbound frames.
+// connection. The payload carries the send time so the RTT can be computed
+// when the client echoes it back in its pong. The write mutex ensures this
+// does not interleave with other outbound frames.
 func (c *Connection) WritePing() error {
+	now := time.Now()
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.pingSent.Store(now.UnixNano())
+	return ws.WriteFrame(c.Conn, ws.NewPingFrame(pingPayload(now)))
+}
+
+// WritePong answers a ping frame from the client, echoing its payload.
+func (c *Connection) WritePong(payload []byte) error {
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
-	return ws.WriteFrame(c.Conn, ws.NewPingFrame(nil))
+	return ws.WriteFrame(c.Conn, ws.NewPongFrame(payload))
+}
+
+// recordPong notes a pong read at now. A pong echoing a heartbeat ping
+// yields an RTT sample; others are unsolicited, which RFC 6455 allows as a
+// one-way keepalive.
+func (c *Connection) recordPong(payload []byte, now time.Time) {
+	c.lastPong.Store(now.UnixNano())
+	if rtt, ok := rttFromPong(payload, now); ok {
+		c.recordRTT(rtt)
+		metrics.HeartbeatPongsTotal.WithLabelValues("answered").Inc()
+		return
+	}
+	metrics.HeartbeatPongsTotal.WithLabelValues("unsolicited").Inc()
+}
+
+// pongMissed reports whether the latest heartbeat ping was sent and no pong
+// has been read since.
+func (c *Connection) pongMissed() bool {
+	sent := c.pingSent.Load()
+	return sent != 0 && c.lastPong.Load() < sent
+}
+
+// LastPong returns when the latest pong was read from the connection, or
+// the zero time if none has been.
+func (c *Connection) LastPong() time.Time {
+	if ns := c.lastPong.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
 }
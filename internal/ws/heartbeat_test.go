@@ -0,0 +1,166 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+func TestNewServer_HeartbeatFromConfig(t *testing.T) {
+	want := HeartbeatConfig{Interval: 45 * time.Second, Timeout: 15 * time.Second}
+	s, _, _ := newTestWorkerServer(t, ServerConfig{Heartbeat: want}, nil)
+	if got := s.HeartbeatConfig(); got != want {
+		t.Errorf("HeartbeatConfig() = %+v, want %+v", got, want)
+	}
+
+	s, _, _ = newTestWorkerServer(t, ServerConfig{}, nil)
+	if got := s.HeartbeatConfig(); got != DefaultHeartbeatConfig() {
+		t.Errorf("unset heartbeat = %+v, want defaults", got)
+	}
+}
+
+func TestCheckConnections_PingsQuietConnection(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	config := DefaultHeartbeatConfig()
+	now := time.Now()
+	c.LastPing = now.Add(-config.Interval)
+	s.conns.Add(c)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		checkConnections(s, config, 0, now)
+	}()
+
+	_ = client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frame, err := ws.ReadFrame(client)
+	if err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if frame.Header.OpCode != ws.OpPing {
+		t.Errorf("opcode = %v, want ping", frame.Header.OpCode)
+	}
+	<-done
+	if s.conns.Count() != 1 {
+		t.Error("expected the pinged connection to be kept")
+	}
+}
+
+func TestCheckConnections_SkipsRecentlyActiveConnection(t *testing.T) {
+	s, c, _ := newTestWorkerServer(t, ServerConfig{}, nil)
+	config := DefaultHeartbeatConfig()
+	now := time.Now()
+	c.LastPing = now.Add(-config.Timeout / 2)
+	s.conns.Add(c)
+
+	// Nobody reads the client side of the pipe, so a ping would block.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		checkConnections(s, config, 0, now)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected no ping to a connection that just sent data")
+	}
+}
+
+func TestCheckConnections_OnlyHandlesItsSlot(t *testing.T) {
+	s, c, _ := newTestWorkerServer(t, ServerConfig{}, nil)
+	config := DefaultHeartbeatConfig()
+	now := time.Now()
+	c.Fd = 3
+	c.LastPing = now.Add(-time.Hour)
+	s.conns.Add(c)
+
+	checkConnections(s, config, 4, now)
+	if s.conns.Count() != 1 {
+		t.Error("expected a connection outside the slot to be left alone")
+	}
+}
+
+func TestCheckConnections_EvictsStaleConnection(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	epoll, err := NewEpoll()
+	if err != nil {
+		t.Skipf("epoll unavailable: %v", err)
+	}
+	t.Cleanup(func() { epoll.Close() })
+	s.epoll = epoll
+
+	config := DefaultHeartbeatConfig()
+	now := time.Now()
+	c.LastPing = now.Add(-config.Interval - config.Timeout - time.Second)
+	s.conns.Add(c)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		checkConnections(s, config, 0, now)
+	}()
+
+	code, _ := readClose(t, client, client)
+	if CloseCode(code) != CloseHeartbeatTimeout {
+		t.Errorf("close code = %d, want %d", code, CloseHeartbeatTimeout)
+	}
+	<-done
+	if s.conns.Count() != 0 {
+		t.Error("expected the stale connection to be removed")
+	}
+}
+
+func TestHeartbeatRound(t *testing.T) {
+	if got := heartbeatRound(30 * time.Second); got != 3*time.Second {
+		t.Errorf("heartbeatRound(30s) = %s, want 3s", got)
+	}
+	if got := heartbeatRound(time.Nanosecond); got != time.Millisecond {
+		t.Errorf("heartbeatRound(1ns) = %s, want 1ms", got)
+	}
+}
+
+func TestCheckConnections_CountsMissedPong(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	config := DefaultHeartbeatConfig()
+	now := time.Now()
+	c.LastPing = now.Add(-config.Interval)
+	s.conns.Add(c)
+	go func() {
+		for {
+			if _, err := ws.ReadFrame(client); err != nil {
+				return
+			}
+		}
+	}()
+
+	checkConnections(s, config, 0, now)
+	if !c.pongMissed() {
+		t.Fatal("expected the ping to be outstanding")
+	}
+	c.recordPong(pingPayload(now), now.Add(30*time.Millisecond))
+	if c.pongMissed() {
+		t.Error("expected the pong to answer the ping")
+	}
+	if got := c.RTT(); got <= 0 {
+		t.Errorf("RTT = %s, want a sample from the pong", got)
+	}
+	if c.LastPong().IsZero() {
+		t.Error("expected LastPong to be set")
+	}
+}
+
+func TestHandleFrame_PongCountsAsActivity(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	c.LastPing = time.Now().Add(-time.Hour)
+
+	writeFrames(client, ws.NewPongFrame(pingPayload(time.Now().Add(-20*time.Millisecond))))
+	s.handleFrame(c, false)
+
+	if time.Since(c.LastPing) > time.Minute {
+		t.Error("expected a pong to refresh LastPing")
+	}
+	if c.RTT() <= 0 || c.LastPong().IsZero() {
+		t.Error("expected the pong to be recorded")
+	}
+}
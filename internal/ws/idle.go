@@ -0,0 +1,83 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// maxIdleCheckInterval bounds how long an idle connection can outlive the
+// idle timeout before the reaper notices it.
+const maxIdleCheckInterval = time.Minute
+
+// StartIdleReaper begins a background goroutine that closes connections
+// whose session is idle (not matching or chatting) and that have sent no
+// message other than a ping for ServerConfig.IdleTimeout. The heartbeat keeps
+// such connections alive indefinitely, so without it a tab left open holds a
+// connection slot and its Redis session until the session TTL. It does
+// nothing if IdleTimeout is zero; otherwise the goroutine exits when the
+// server's done channel is closed.
+func StartIdleReaper(server *Server) {
+	timeout := server.config.IdleTimeout
+	if timeout <= 0 {
+		return
+	}
+	interval := timeout / 2
+	if interval > maxIdleCheckInterval {
+		interval = maxIdleCheckInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-server.done:
+				return
+			case <-ticker.C:
+				reapIdle(server, timeout, time.Now())
+			}
+		}
+	}()
+}
+
+// reapIdle closes every connection that has been inactive for longer than
+// timeout and whose session is idle, after telling the client why with an
+// idle_timeout error. Only connections past the timeout are looked up in
+// Redis; without a session store they all count as idle.
+func reapIdle(server *Server, timeout time.Duration, now time.Time) {
+	for _, c := range server.Connections().All() {
+		inactive := now.Sub(c.LastActive())
+		if inactive <= timeout || !server.sessionIdle(c.ID) {
+			continue
+		}
+
+		log.Printf("ws: idle timeout session=%s inactive=%s", c.ID, inactive.Round(time.Second))
+		data, err := protocol.NewServerMessage(protocol.TypeError, protocol.NewError(protocol.ErrIdleTimeout, fmt.Sprintf("Disconnected after %s without activity", timeout)))
+		if err == nil {
+			_ = c.WriteMessage(data)
+		}
+		server.CloseConnection(c, CloseIdleTimeout, "idle timeout")
+	}
+}
+
+// sessionIdle reports whether the session is neither matching nor chatting.
+// Lookup errors count as busy so a Redis hiccup never disconnects anyone.
+func (s *Server) sessionIdle(sessionID string) bool {
+	if s.sessionStore == nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	sess, err := s.sessionStore.Get(ctx, sessionID)
+	if err != nil {
+		log.Printf("ws: idle check failed session=%s: %v", sessionID, err)
+		return false
+	}
+	return sess == nil || sess.Status == "" || sess.Status == session.StatusIdle
+}
@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws/wsutil"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+func TestReapIdle_ClosesInactiveConnection(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	epoll, err := NewEpoll()
+	if err != nil {
+		t.Skipf("epoll unavailable: %v", err)
+	}
+	t.Cleanup(func() { epoll.Close() })
+	s.epoll = epoll
+
+	now := time.Now()
+	c.CreatedAt = now.Add(-10 * time.Minute)
+	s.conns.Add(c)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reapIdle(s, 5*time.Minute, now)
+	}()
+
+	data, err := wsutil.ReadServerText(client)
+	if err != nil {
+		t.Fatalf("read notice: %v", err)
+	}
+	var msg protocol.ErrorMsg
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Code != "idle_timeout" {
+		t.Fatalf("expected idle_timeout error, got %s", data)
+	}
+	code, _ := readClose(t, client, client)
+	if CloseCode(code) != CloseIdleTimeout {
+		t.Errorf("close code = %d, want %d", code, CloseIdleTimeout)
+	}
+	<-done
+	if s.conns.Count() != 0 {
+		t.Error("expected the idle connection to be removed")
+	}
+}
+
+func TestReapIdle_KeepsRecentlyActiveConnection(t *testing.T) {
+	s, c, _ := newTestWorkerServer(t, ServerConfig{}, nil)
+	now := time.Now()
+	c.CreatedAt = now.Add(-10 * time.Minute)
+	c.touch() // a message just now; pings alone would not count
+	s.conns.Add(c)
+
+	reapIdle(s, 5*time.Minute, now.Add(time.Minute))
+	if s.conns.Count() != 1 {
+		t.Error("expected a recently active connection to be kept")
+	}
+}
+
+func TestDispatch_PingDoesNotCountAsActivity(t *testing.T) {
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, nil)
+	d := NewMessageDispatcher(s)
+	d.Register(protocol.TypeFindMatch, func(*Connection, interface{}) {})
+	go func() {
+		for {
+			if _, err := wsutil.ReadServerText(client); err != nil {
+				return
+			}
+		}
+	}()
+
+	d.Dispatch(c, []byte(`{"type":"ping"}`))
+	if !c.LastActive().Equal(c.CreatedAt) {
+		t.Fatal("expected a ping to leave LastActive unchanged")
+	}
+	d.Dispatch(c, []byte(`{"type":"find_match","interests":[]}`))
+	if c.LastActive().Equal(c.CreatedAt) {
+		t.Fatal("expected a handled message to update LastActive")
+	}
+}
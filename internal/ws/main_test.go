@@ -0,0 +1,11 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/whisper/chat-app/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m, testutil.ServiceRedis|testutil.ServicePostgres)
+}
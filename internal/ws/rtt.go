@@ -0,0 +1,148 @@
+package ws
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/whisper/chat-app/internal/metrics"
+)
+
+// maxPlausibleRTT bounds accepted RTT samples. Anything larger comes from a
+// pong answering a ping from an earlier heartbeat round or a bogus payload.
+const maxPlausibleRTT = time.Minute
+
+// pingPayload encodes t as the 8-byte big-endian UnixNano payload the server
+// embeds in heartbeat pings. Clients echo the payload in their pong.
+func pingPayload(t time.Time) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(t.UnixNano()))
+	return buf
+}
+
+// rttFromPong decodes a pong payload produced by pingPayload and returns the
+// round-trip time relative to now. ok is false for payloads the server did
+// not send or implausible values.
+func rttFromPong(payload []byte, now time.Time) (time.Duration, bool) {
+	if len(payload) != 8 {
+		return 0, false
+	}
+	sent := time.Unix(0, int64(binary.BigEndian.Uint64(payload)))
+	rtt := now.Sub(sent)
+	if rtt < 0 || rtt > maxPlausibleRTT {
+		return 0, false
+	}
+	return rtt, true
+}
+
+// recordRTT stores the latest RTT sample on the connection and observes it
+// in the whisper_client_rtt_seconds histogram.
+func (c *Connection) recordRTT(rtt time.Duration) {
+	c.rtt.Store(int64(rtt))
+	metrics.ClientRTT.Observe(rtt.Seconds())
+}
+
+// RTT returns the most recent heartbeat round-trip time measured for the
+// connection, or 0 if no pong has been received yet.
+func (c *Connection) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
+
+// RTTSummary aggregates the latest RTT of every connection that has one.
+type RTTSummary struct {
+	Samples int     `json:"samples"`
+	AvgMs   float64 `json:"avg_ms"`
+	P50Ms   float64 `json:"p50_ms"`
+	P95Ms   float64 `json:"p95_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// rttBucketGrowth is the ratio between the upper bounds of consecutive
+// buckets of rttHistogram, so a reported percentile overstates the true one
+// by at most 5%.
+const rttBucketGrowth = 1.05
+
+// rttBuckets is the number of buckets needed to cover 1ms to maxPlausibleRTT.
+var rttBuckets = rttBucket(maxPlausibleRTT) + 1
+
+// rttBucket returns the histogram bucket of d: bucket k counts samples up
+// to rttBucketGrowth^k milliseconds.
+func rttBucket(d time.Duration) int {
+	ms := float64(d) / float64(time.Millisecond)
+	if ms <= 1 {
+		return 0
+	}
+	return int(math.Ceil(math.Log(ms) / math.Log(rttBucketGrowth)))
+}
+
+// rttHistogram summarizes RTT samples in constant memory, so /health can
+// cover every connection without collecting and sorting their samples.
+type rttHistogram struct {
+	counts []int
+	n      int
+	sum    time.Duration
+	max    time.Duration
+}
+
+func newRTTHistogram() *rttHistogram {
+	return &rttHistogram{counts: make([]int, rttBuckets)}
+}
+
+// add counts one sample. Zero samples (connections without a measurement)
+// are ignored.
+func (h *rttHistogram) add(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	h.counts[min(rttBucket(d), len(h.counts)-1)]++
+	h.n++
+	h.sum += d
+	h.max = max(h.max, d)
+}
+
+// summary returns the samples' count, average and maximum, and their
+// percentiles as the upper bound of the bucket holding them.
+func (h *rttHistogram) summary() RTTSummary {
+	if h.n == 0 {
+		return RTTSummary{}
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	quantile := func(q float64) float64 {
+		rank := int(math.Ceil(float64(h.n) * q))
+		seen := 0
+		for k, c := range h.counts[:len(h.counts)-1] {
+			if seen += c; seen >= rank {
+				return math.Min(math.Pow(rttBucketGrowth, float64(k)), ms(h.max))
+			}
+		}
+		// The last bucket also holds implausibly large samples.
+		return ms(h.max)
+	}
+	return RTTSummary{
+		Samples: h.n,
+		AvgMs:   ms(h.sum / time.Duration(h.n)),
+		P50Ms:   quantile(0.5),
+		P95Ms:   quantile(0.95),
+		MaxMs:   ms(h.max),
+	}
+}
+
+// summarizeRTT computes an RTTSummary from per-connection samples.
+func summarizeRTT(samples []time.Duration) RTTSummary {
+	h := newRTTHistogram()
+	for _, d := range samples {
+		h.add(d)
+	}
+	return h.summary()
+}
+
+// RTTSummary summarizes the current RTT of all connections.
+func (cm *ConnectionManager) RTTSummary() RTTSummary {
+	h := newRTTHistogram()
+	cm.mu.RLock()
+	for _, c := range cm.byID {
+		h.add(c.RTT())
+	}
+	cm.mu.RUnlock()
+	return h.summary()
+}
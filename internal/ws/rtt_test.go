@@ -0,0 +1,63 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTTFromPong_RoundTrip(t *testing.T) {
+	sent := time.Now()
+	rtt, ok := rttFromPong(pingPayload(sent), sent.Add(42*time.Millisecond))
+	if !ok {
+		t.Fatal("expected payload to decode")
+	}
+	if rtt != 42*time.Millisecond {
+		t.Errorf("expected 42ms, got %s", rtt)
+	}
+}
+
+func TestRTTFromPong_Rejects(t *testing.T) {
+	now := time.Now()
+	cases := map[string][]byte{
+		"empty":      nil,
+		"short":      {1, 2, 3},
+		"future":     pingPayload(now.Add(time.Second)),
+		"too old":    pingPayload(now.Add(-2 * maxPlausibleRTT)),
+		"long bytes": make([]byte, 16),
+	}
+	for name, payload := range cases {
+		if _, ok := rttFromPong(payload, now); ok {
+			t.Errorf("%s: expected payload to be rejected", name)
+		}
+	}
+}
+
+func TestSummarizeRTT(t *testing.T) {
+	if s := summarizeRTT([]time.Duration{0, 0}); s.Samples != 0 {
+		t.Errorf("expected no samples, got %+v", s)
+	}
+
+	samples := make([]time.Duration, 0, 101)
+	samples = append(samples, 0) // no measurement yet
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	s := summarizeRTT(samples)
+	if s.Samples != 100 {
+		t.Fatalf("expected 100 samples, got %d", s.Samples)
+	}
+	// Percentiles are bucket bounds, at most 5% above the true value.
+	if s.P50Ms < 50 || s.P50Ms > 52.5 || s.P95Ms < 95 || s.P95Ms > 99.75 || s.MaxMs != 100 {
+		t.Errorf("unexpected percentiles: %+v", s)
+	}
+	if s.AvgMs != 50.5 {
+		t.Errorf("expected avg 50.5ms, got %v", s.AvgMs)
+	}
+
+	// Percentiles never exceed the largest sample, and samples beyond the
+	// last bucket land in it.
+	s = summarizeRTT([]time.Duration{3 * time.Millisecond, 2 * maxPlausibleRTT})
+	if s.P50Ms < 3 || s.P50Ms > 3.15 || s.P95Ms != s.MaxMs {
+		t.Errorf("unexpected percentiles: %+v", s)
+	}
+}
@@ -5,6 +5,7 @@ package ws
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,22 +17,45 @@ import (
 	"time"
 
 	"github.com/gobwas/ws"
-	"github.com/gobwas/ws/wsutil"
 	"github.com/google/uuid"
 
+	"github.com/whisper/chat-app/internal/geoip"
+	"github.com/whisper/chat-app/internal/logging"
 	"github.com/whisper/chat-app/internal/metrics"
-	"github.com/whisper/chat-app/internal/protocol"
 	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/pkg/protocol"
 )
 
 // ServerConfig holds tunable parameters for the WebSocket server.
 type ServerConfig struct {
 	ListenAddr     string        // address to listen on, e.g. ":8080"
-	WorkerPoolSize int           // max concurrent read-worker goroutines
+	WorkerPoolSize int           // number of read-worker goroutines
+	DispatchQueue  int           // ready connections buffered for busy workers
+	OverloadPolicy OverloadPolicy // what to do when workers and queue are full
 	MaxConnections int           // hard cap on total connections
+	WaitingRoomSize int          // connections held waiting for a slot at MaxConnections; 0 rejects them
 	ReadTimeout    time.Duration // timeout for WebSocket read operations
 	WriteTimeout   time.Duration // timeout for WebSocket write operations
 	MaxFrameSize   int64         // maximum allowed WebSocket frame payload in bytes
+	IdleTimeout    time.Duration // close idle sessions sending nothing but pings this long; 0 disables
+	MaxFrameRate   int           // inbound data frames per second per connection; 0 disables
+	DrainTimeout   time.Duration // how long Shutdown waits for clients to leave before force-closing them
+	DebugToken     string        // bearer token for /debug/pprof/ and /debug/runtime; empty disables them
+	Heartbeat      HeartbeatConfig // initial ping interval and timeout; SetHeartbeatConfig changes them later
+
+	// InternalAddr, when set, moves /health, /metrics, /debug/ and routes
+	// added with HandleInternal to a second plain-HTTP listener, e.g.
+	// "127.0.0.1:9090", leaving only /ws, /api/online and Handle routes on
+	// ListenAddr. Empty serves everything on ListenAddr.
+	InternalAddr string
+
+	// Native TLS, for deployments without a terminating proxy. Set either
+	// the certificate and key files or AutocertDomains.
+	TLSCertFile      string   // PEM certificate chain
+	TLSKeyFile       string   // PEM private key
+	AutocertDomains  []string // hosts to obtain Let's Encrypt certificates for
+	AutocertCacheDir string   // where autocert stores certificates across restarts
+	HTTPRedirectAddr string   // optional plain-HTTP listener redirecting to https, e.g. ":80"
 }
 
 // DefaultServerConfig returns a ServerConfig with sensible production defaults.
@@ -39,49 +63,81 @@ func DefaultServerConfig() ServerConfig {
 	return ServerConfig{
 		ListenAddr:     ":8080",
 		WorkerPoolSize: 256,
+		DispatchQueue:  1024,
+		OverloadPolicy: OverloadBlock,
 		MaxConnections: 100000,
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxFrameSize:   4096,
+		IdleTimeout:    10 * time.Minute,
+		MaxFrameRate:   20,
+		DrainTimeout:   30 * time.Second,
+		Heartbeat:      DefaultHeartbeatConfig(),
 	}
 }
 
 // Server is the high-performance WebSocket server built on gobwas/ws and Linux
 // epoll. It upgrades HTTP connections to WebSocket, registers them with an
 // epoll instance for I/O readiness notifications, and dispatches ready
-// connections to a bounded worker pool for frame reading.
+// connections through a bounded queue to a fixed pool of workers for frame
+// reading.
 type Server struct {
 	config       ServerConfig
 	epoll        *Epoll
 	conns        *ConnectionManager
 	sessionStore *session.Store                        // Redis-backed session state
-	workerPool   chan struct{}                         // semaphore limiting concurrent read workers
+	dispatchQueue chan dispatchJob                     // ready connections waiting for a worker
+	shedQueue    chan *Connection                      // connections waiting for a shed responder; nil unless OverloadDrop
+	busyWorkers  atomic.Int64                          // workers currently handling a frame
 	onMessage    func(conn *Connection, data []byte)  // message handler callback
 	onDisconnect func(connID string)                  // called when a connection is removed
+	admit        func(r *http.Request) bool           // optional check run before each upgrade
+	locate       func(r *http.Request) geoip.Location // optional GeoIP lookup of each upgrade request
+	clientConfig func() []byte                        // optional config message sent after session_created
+	onConnect    func(c *Connection)                  // optional callback after the config message is sent
+	handoff      *Handoff                             // optional session handoff across reconnects
+	waiting      *waitingRoom                         // connections waiting for a slot; nil when disabled
+	drainCountdown func(c *Connection) bool           // optional selection of connections reminded while draining
 	httpServer   *http.Server
-	bufPool      sync.Pool // pool of reusable read buffers
+	redirectServer *http.Server // plain-HTTP redirect listener when TLS is enabled
+	internalServer *http.Server // metrics/health/admin listener when InternalAddr is set
+	routes       map[string]http.Handler // extra HTTP routes registered via Handle
+	internalRoutes map[string]http.Handler // extra HTTP routes registered via HandleInternal
+	bufPool      sync.Pool // pool of reusable *[]byte read buffers; see readBuffer
 	done         chan struct{}
 	startedAt    time.Time    // server start time for uptime calculation
 	draining     atomic.Bool  // true when server is draining connections during shutdown
+	heartbeat    atomic.Pointer[HeartbeatConfig] // swappable heartbeat parameters
+	epollStats   epollStats                      // time the event loop spends in epoll.Wait
 }
 
 // NewServer creates a Server with the given configuration, session store, and
 // message callback. The onMessage function is called from a worker goroutine
-// whenever a complete WebSocket text frame is received from a client.
+// whenever a complete WebSocket text frame is received from a client. The data
+// slice is only valid until onMessage returns: it is usually a pooled buffer
+// that is reused for the next message, so a callback that retains any of it
+// must copy it.
 func NewServer(config ServerConfig, sessionStore *session.Store, onMessage func(conn *Connection, data []byte)) *Server {
 	s := &Server{
 		config:       config,
 		conns:        NewConnectionManager(),
 		sessionStore: sessionStore,
-		workerPool:   make(chan struct{}, config.WorkerPoolSize),
+		dispatchQueue: make(chan dispatchJob, config.DispatchQueue),
 		onMessage:    onMessage,
+		routes:       make(map[string]http.Handler),
+		internalRoutes: make(map[string]http.Handler),
 		done:         make(chan struct{}),
-		bufPool: sync.Pool{
-			New: func() interface{} {
-				buf := make([]byte, 4096)
-				return &buf
-			},
-		},
+	}
+	if config.OverloadPolicy == OverloadDrop {
+		s.shedQueue = make(chan *Connection, shedQueueSize)
+	}
+	hb := config.Heartbeat
+	if hb.Validate() != nil {
+		hb = DefaultHeartbeatConfig()
+	}
+	s.heartbeat.Store(&hb)
+	if config.WaitingRoomSize > 0 {
+		s.waiting = newWaitingRoom(config.WaitingRoomSize, config.WriteTimeout)
 	}
 
 	return s
@@ -89,8 +145,13 @@ func NewServer(config ServerConfig, sessionStore *session.Store, onMessage func(
 
 // Start initializes the epoll instance, configures the HTTP server, and begins
 // accepting WebSocket connections. It starts the epoll event loop in a
-// background goroutine and blocks on http.Server.ListenAndServe.
+// background goroutine and blocks on http.Server.ListenAndServe, or
+// ListenAndServeTLS when TLS is configured.
 func (s *Server) Start() error {
+	if err := s.config.validateTLS(); err != nil {
+		return err
+	}
+
 	var err error
 	s.epoll, err = NewEpoll()
 	if err != nil {
@@ -99,48 +160,147 @@ func (s *Server) Start() error {
 
 	s.startedAt = time.Now()
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/ws", s.handleUpgrade)
-	mux.HandleFunc("/health", s.handleHealth)
-	mux.HandleFunc("/api/online", s.handleOnlineCount)
-	mux.Handle("/metrics", metrics.Handler())
-
+	mux, internalMux := s.muxes()
 	s.httpServer = &http.Server{
 		Addr:    s.config.ListenAddr,
 		Handler: mux,
 	}
 
-	// Start the epoll event loop in the background.
+	if s.config.InternalAddr != "" {
+		// Bind before serving so a taken port fails Start rather than
+		// leaving the server without metrics and health checks.
+		ln, err := net.Listen("tcp", s.config.InternalAddr)
+		if err != nil {
+			return fmt.Errorf("ws: internal listener: %w", err)
+		}
+		s.internalServer = &http.Server{
+			Handler:           internalMux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			if err := s.internalServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+				log.Printf("ws: internal listener error: %v", err)
+			}
+		}()
+		log.Printf("ws: internal endpoints listening on %s", s.config.InternalAddr)
+	}
+
+	scheme := "ws"
+	if s.config.TLSEnabled() {
+		scheme = "wss"
+		tlsConfig, redirect := s.tlsSetup()
+		s.httpServer.TLSConfig = tlsConfig
+		// WebSocket upgrades need HTTP/1.1 hijacking, so never negotiate h2.
+		s.httpServer.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+
+		if s.config.HTTPRedirectAddr != "" {
+			s.redirectServer = &http.Server{
+				Addr:              s.config.HTTPRedirectAddr,
+				Handler:           redirect,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			go func() {
+				if err := s.redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Printf("ws: http redirect listener error: %v", err)
+				}
+			}()
+		}
+	}
+
+	// Start the read workers and the epoll event loop in the background.
+	s.startWorkers()
 	go s.startEventLoop()
 
 	// Start the heartbeat monitor to detect and close dead connections.
-	StartHeartbeat(s, DefaultHeartbeatConfig())
-
-	log.Printf("ws: server listening on %s (workers=%d, max_conns=%d)",
-		s.config.ListenAddr, s.config.WorkerPoolSize, s.config.MaxConnections)
-
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	StartHeartbeat(s)
+	StartIdleReaper(s)
+	StartWaitingRoom(s)
+
+	log.Printf("ws: server listening on %s (%s, workers=%d, queue=%d, overload=%s, max_conns=%d, waiting_room=%d)",
+		s.config.ListenAddr, scheme, s.config.WorkerPoolSize, s.config.DispatchQueue,
+		s.config.OverloadPolicy, s.config.MaxConnections, s.config.WaitingRoomSize)
+
+	if s.config.TLSEnabled() {
+		// With autocert the certificate comes from TLSConfig.GetCertificate
+		// and both file arguments are empty.
+		err = s.httpServer.ListenAndServeTLS(s.config.TLSCertFile, s.config.TLSKeyFile)
+	} else {
+		err = s.httpServer.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("ws: http server error: %w", err)
 	}
 	return nil
 }
 
+// Handle registers an additional HTTP handler on the server's public
+// listener. It must be called before Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.routes[pattern] = handler
+}
+
+// HandleInternal registers an additional HTTP handler meant for operators
+// only, such as the admin API. It is served on InternalAddr when that is
+// set and on the public listener otherwise. It must be called before Start.
+func (s *Server) HandleInternal(pattern string, handler http.Handler) {
+	s.internalRoutes[pattern] = handler
+}
+
+// muxes builds the handlers for the public and internal listeners. Without
+// an InternalAddr both are the same mux.
+func (s *Server) muxes() (public, internal *http.ServeMux) {
+	public = http.NewServeMux()
+	public.HandleFunc("/ws", s.handleUpgrade)
+	public.HandleFunc("/api/online", s.handleOnlineCount)
+	for pattern, handler := range s.routes {
+		public.Handle(pattern, handler)
+	}
+
+	internal = public
+	if s.config.InternalAddr != "" {
+		internal = http.NewServeMux()
+	}
+	internal.HandleFunc("/health", s.handleHealth)
+	internal.Handle("/metrics", metrics.Handler())
+	if s.config.DebugToken != "" {
+		s.registerDebug(internal)
+	}
+	for pattern, handler := range s.internalRoutes {
+		internal.Handle(pattern, handler)
+	}
+	return public, internal
+}
+
 // handleUpgrade upgrades an HTTP request to a WebSocket connection using
-// gobwas/ws zero-copy upgrader. On success it creates a Connection, registers
-// it with the connection manager and epoll instance.
+// gobwas/ws zero-copy upgrader and accepts it. At MaxConnections, or while
+// others are already waiting, the connection goes to the waiting room
+// instead, or is rejected if that is disabled or full.
 func (s *Server) handleUpgrade(w http.ResponseWriter, r *http.Request) {
 	// Reject new connections during graceful shutdown drain.
 	if s.draining.Load() {
-		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		rejectUpgrade(w, r, CloseTryAgainLater, "server shutting down")
 		return
 	}
 
 	// Enforce maximum connection limit.
-	if s.conns.Count() >= s.config.MaxConnections {
-		http.Error(w, "too many connections", http.StatusServiceUnavailable)
+	wait := s.conns.Count() >= s.config.MaxConnections || s.waiting.Len() > 0
+	if wait && s.waiting.full() {
+		rejectUpgrade(w, r, CloseTryAgainLater, "too many connections")
 		return
 	}
 
+	if s.admit != nil && !s.admit(r) {
+		rejectUpgrade(w, r, ClosePolicyViolation, "forbidden")
+		return
+	}
+
+	// Locate the client before upgrading, so the lookup runs on the HTTP
+	// goroutine rather than a read worker.
+	var loc geoip.Location
+	if s.locate != nil {
+		loc = s.locate(r)
+	}
+
 	// Upgrade the HTTP connection to WebSocket.
 	conn, _, _, err := ws.UpgradeHTTP(r, w)
 	if err != nil {
@@ -148,8 +308,30 @@ func (s *Server) handleUpgrade(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if wait {
+		if !s.waiting.join(conn, r, loc, time.Now()) {
+			_ = writeCloseFrame(conn, CloseTryAgainLater, "too many connections")
+			_ = conn.Close()
+			return
+		}
+		// A slot may have freed up since the check above.
+		s.waiting.notify()
+		return
+	}
+	s.accept(conn, r, loc)
+}
+
+// accept creates a Connection for an upgraded connection, resuming the
+// session r asks for if it can, registers it with the connection manager
+// and epoll instance and sends session_created. loc is where the client is
+// located, recorded on the session when known.
+func (s *Server) accept(conn net.Conn, r *http.Request, loc geoip.Location) {
 	fd := socketFD(conn)
-	sessionID := uuid.New().String()
+	sessionID, token := s.claimResume(r)
+	resumed := sessionID != ""
+	if !resumed {
+		sessionID = uuid.New().String()
+	}
 
 	c := &Connection{
 		ID:        sessionID,
@@ -157,53 +339,100 @@ func (s *Server) handleUpgrade(w http.ResponseWriter, r *http.Request) {
 		Fd:        fd,
 		CreatedAt: time.Now(),
 		LastPing:  time.Now(),
+		Location:  loc,
 	}
+	c.batching.Store(r.URL.Query().Get("batch") == "1")
 
-	// Register the connection in the manager and epoll.
+	// Register the connection in the manager and epoll. TLS connections
+	// are read by their own goroutine instead (see serveTLSConn).
 	s.conns.Add(c)
 	metrics.ConnectionsTotal.Set(float64(s.conns.Count()))
-	if err := s.epoll.Add(conn); err != nil {
+	if isTLSConn(conn) {
+		go s.serveTLSConn(c)
+	} else if err := s.epoll.Add(conn); err != nil {
 		log.Printf("ws: epoll add failed for session %s: %v", sessionID, err)
 		s.conns.Remove(sessionID)
 		return
 	}
 
-	// Create session in Redis.
-	if s.sessionStore != nil {
+	// Create session in Redis. A resumed session already exists.
+	if s.sessionStore != nil && !resumed {
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 		defer cancel()
-		if err := s.sessionStore.Create(ctx, sessionID); err != nil {
+		var err error
+		if token, err = s.sessionStore.Create(ctx, sessionID); err != nil {
 			log.Printf("ws: failed to create redis session for %s: %v", sessionID, err)
 		}
 	}
+	if s.locate != nil {
+		country := loc.Country
+		if country == "" {
+			country = "unknown"
+		}
+		metrics.ConnectionsByCountryTotal.WithLabelValues(country).Inc()
+		// A resumed session may come from a new address.
+		if s.sessionStore != nil && (loc.Known() || resumed) {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			if err := s.sessionStore.SetLocation(ctx, sessionID, loc.Country, loc.Region); err != nil {
+				log.Printf("ws: failed to store location for %s: %v", sessionID, err)
+			}
+			cancel()
+		}
+	}
 
 	// Send session_created to the client.
 	sessionMsg, err := protocol.NewServerMessage(protocol.TypeSessionCreated, protocol.SessionCreatedMsg{
-		SessionID: sessionID,
+		SessionID:    sessionID,
+		SessionToken: token,
+		Resumed:      resumed,
 	})
 	if err != nil {
 		log.Printf("ws: failed to build session_created for session %s: %v", sessionID, err)
 	} else if err := c.WriteMessage(sessionMsg); err != nil {
 		log.Printf("ws: failed to send session_created for session %s: %v", sessionID, err)
 	}
+	if s.clientConfig != nil {
+		if msg := s.clientConfig(); msg != nil {
+			if err := c.WriteMessage(msg); err != nil {
+				log.Printf("ws: failed to send config for session %s: %v", sessionID, err)
+			}
+		}
+	}
+	if s.onConnect != nil {
+		s.onConnect(c)
+	}
+
+	if resumed && s.handoff.Resumed != nil {
+		s.handoff.Resumed(c)
+	}
 
-	log.Printf("ws: new connection session=%s fd=%d (total=%d)", sessionID, fd, s.conns.Count())
+	logging.Debugf("ws: new connection session=%s fd=%d resumed=%t (total=%d)", sessionID, fd, resumed, s.conns.Count())
 }
 
 // handleHealth responds with the server's health status as JSON, including the
-// current connection count and uptime. It is used by HAProxy for health checks.
+// current connection count, uptime and a summary of client heartbeat RTTs. It
+// is used by HAProxy for health checks.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	status := "ok"
+	if s.draining.Load() {
+		// Fail the load balancer check so no new clients are routed here.
+		status = "draining"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
 
 	resp := struct {
-		Status      string `json:"status"`
-		Connections int    `json:"connections"`
-		Uptime      string `json:"uptime"`
+		Status      string     `json:"status"`
+		Connections int        `json:"connections"`
+		Uptime      string     `json:"uptime"`
+		RTT         RTTSummary `json:"rtt"`
 	}{
-		Status:      "ok",
+		Status:      status,
 		Connections: s.conns.Count(),
 		Uptime:      time.Since(s.startedAt).Round(time.Second).String(),
+		RTT:         s.conns.RTTSummary(),
 	}
 
 	_ = json.NewEncoder(w).Encode(resp)
@@ -222,9 +451,9 @@ func (s *Server) handleOnlineCount(w http.ResponseWriter, r *http.Request) {
 	}{Count: s.conns.Count()})
 }
 
-// startEventLoop runs the epoll wait loop. For each batch of ready
-// connections, it dispatches each to a worker goroutine (bounded by the
-// worker pool semaphore) that reads and processes the WebSocket frame.
+// startEventLoop runs the epoll wait loop. Each ready connection is claimed
+// and handed to the worker pool; connections already claimed by an earlier
+// wakeup are skipped.
 func (s *Server) startEventLoop() {
 	for {
 		select {
@@ -233,7 +462,10 @@ func (s *Server) startEventLoop() {
 		default:
 		}
 
+		waitStart := time.Now()
 		conns, err := s.epoll.Wait()
+		now := time.Now()
+		s.epollStats.record(now.Sub(waitStart), now)
 		if err != nil {
 			select {
 			case <-s.done:
@@ -249,48 +481,56 @@ func (s *Server) startEventLoop() {
 		}
 
 		for _, conn := range conns {
-			conn := conn // capture for goroutine
-
-			// Acquire a worker slot (blocks if pool is full).
-			s.workerPool <- struct{}{}
-
-			go func() {
-				defer func() { <-s.workerPool }()
-				s.handleConn(conn)
-			}()
+			c := s.conns.GetByConn(conn)
+			if c == nil || !c.claim() {
+				continue
+			}
+			s.dispatch(c)
 		}
 	}
 }
 
-// handleConn reads a single WebSocket frame from a ready connection using
-// wsutil.NextReader so that control frames (ping, pong) are handled without
-// blocking on a data frame that may never arrive. If the read fails
+// handleFrame reads a single WebSocket frame from a claimed connection
+// using a wsutil.Reader so that control frames (ping, pong) are handled
+// without blocking on a data frame that may never arrive. If the read fails
 // (connection closed, protocol error, etc.) the connection is removed from
-// epoll and the connection manager.
-func (s *Server) handleConn(netConn net.Conn) {
-	c := s.conns.GetByConn(netConn)
-	if c == nil {
-		return
-	}
-
-	// Guard against duplicate dispatch from level-triggered epoll.
-	if !atomic.CompareAndSwapInt32(&c.processing, 0, 1) {
-		return
-	}
-	defer atomic.StoreInt32(&c.processing, 0)
+// epoll and the connection manager. When shed is true the worker pool is
+// overloaded: data frames are discarded and answered with server_busy.
+//
+// A message fragmented over several frames (FIN=0 followed by continuation
+// frames) is assembled on the connection one frame per call, so a slow
+// sender never holds a worker between fragments. MaxFrameSize applies to
+// the whole message.
+func (s *Server) handleFrame(c *Connection, shed bool) {
+	netConn := c.Conn
 
 	if s.config.ReadTimeout > 0 {
 		_ = netConn.SetReadDeadline(time.Now().Add(s.config.ReadTimeout))
 	}
 
-	header, reader, err := wsutil.NextReader(netConn, ws.StateServerSide)
+	// Each frame is read by a reader reset to see it as unfragmented, so
+	// reading its payload never runs on into the next frame; whether a
+	// continuation is expected is checked against the connection's own state
+	// instead. The reader is kept on the connection so its unmasking state
+	// isn't allocated again for every frame.
+	reader := &c.reader
+	reader.Source, reader.State, reader.SkipHeaderCheck = netConn, ws.StateServerSide, true
+	header, err := reader.NextFrame()
+	if err == nil {
+		err = ws.CheckHeader(header, c.readState())
+	}
 	if err != nil {
 		// A read timeout means no data was available (stale epoll dispatch).
 		// Don't kill the connection — the heartbeat handles dead connections.
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			return
 		}
-		s.RemoveConnection(c)
+		code, reason := closeCodeFor(err)
+		if code == CloseProtocolError {
+			s.CloseConnection(c, code, reason)
+			return
+		}
+		s.dropConnection(c, CloseNormal, "")
 		return
 	}
 
@@ -303,50 +543,158 @@ func (s *Server) handleConn(netConn net.Conn) {
 	// Handle control frames without removing the connection.
 	if header.OpCode.IsControl() {
 		if header.OpCode == ws.OpClose {
-			s.RemoveConnection(c)
+			// Echo the client's code, as RFC 6455 section 5.5.1 asks.
+			payload, _ := io.ReadAll(io.LimitReader(reader, 125))
+			code, _ := ws.ParseCloseFrameData(payload)
+			if code.Empty() || code.IsNotUsed() || code.IsProtocolReserved() {
+				code = ws.StatusNormalClosure
+			}
+			s.CloseConnection(c, CloseCode(code), "")
+			return
+		}
+		// Control payloads are at most 125 bytes; consume them so the next
+		// frame starts at a frame boundary.
+		payload, err := io.ReadAll(io.LimitReader(reader, 125))
+		if err != nil {
+			s.dropConnection(c, CloseNormal, "")
+			return
+		}
+		switch header.OpCode {
+		case ws.OpPong:
+			c.recordPong(payload, time.Now())
+		case ws.OpPing:
+			// Some clients and proxies keep the connection alive with their
+			// own pings; RFC 6455 section 5.5.2 requires a pong in answer.
+			if err := c.WritePong(payload); err != nil {
+				s.dropConnection(c, CloseNormal, "")
+			}
+		}
+		return
+	}
+
+	// A data frame either starts a message or continues the one in
+	// progress; NextReader has already rejected any other order.
+	if header.OpCode != ws.OpContinuation {
+		c.message, c.dropping = nil, false
+	}
+	c.fragmented = !header.Fin
+
+	// Discard the rest of a message already rejected below.
+	if c.dropping {
+		s.dropMessage(c, reader, header)
+		return
+	}
+
+	// Shed data frames while overloaded; the client may retry.
+	if shed {
+		s.dropMessage(c, reader, header)
+		metrics.FramesDroppedTotal.Inc()
+
+		errMsg, marshalErr := protocol.NewServerMessage(protocol.TypeError, protocol.NewError(protocol.ErrServerBusy, "Server is busy, please try again"))
+		if marshalErr == nil {
+			_ = c.WriteMessage(errMsg)
 		}
-		// Pong/ping: connection is alive, nothing else to do.
 		return
 	}
 
-	// Reject oversized frames before reading the payload.
-	if s.config.MaxFrameSize > 0 && header.Length > s.config.MaxFrameSize {
-		log.Printf("ws: frame too large from session=%s: %d bytes (max %d)",
-			c.ID, header.Length, s.config.MaxFrameSize)
+	// Reject oversized messages before reading the payload.
+	if size := int64(len(c.message)) + header.Length; s.config.MaxFrameSize > 0 && size > s.config.MaxFrameSize {
+		log.Printf("ws: message too large from session=%s: %d bytes (max %d)",
+			c.ID, size, s.config.MaxFrameSize)
 
 		// Drain the reader so the connection stays usable for subsequent frames.
-		_, _ = io.Copy(io.Discard, reader)
+		s.dropMessage(c, reader, header)
 
 		// Send an error back to the client.
-		errMsg, marshalErr := protocol.NewServerMessage(protocol.TypeError, protocol.ErrorMsg{
-			Code:    "frame_too_large",
-			Message: "Message exceeds 4KB limit",
-		})
+		errMsg, marshalErr := protocol.NewServerMessage(protocol.TypeError, protocol.NewError(protocol.ErrFrameTooLarge, "Message exceeds 4KB limit"))
 		if marshalErr == nil {
 			_ = c.WriteMessage(errMsg)
 		}
 		return
 	}
 
-	// Read data frame payload.
-	data := make([]byte, header.Length)
+	// Read data frame payload. A message in a single frame, the common case,
+	// is read into a pooled buffer that is returned once onMessage is done
+	// with it; fragments are appended to the message in progress.
+	n := len(c.message)
+	var data []byte
+	if n == 0 && header.Fin {
+		var pooled *[]byte
+		data, pooled = s.readBuffer(header.Length)
+		defer s.releaseReadBuffer(pooled)
+	} else {
+		data = append(c.message, make([]byte, header.Length)...)
+		readBufferBypass.Inc()
+	}
 	if header.Length > 0 {
-		_, err = io.ReadFull(reader, data)
+		_, err = io.ReadFull(reader, data[n:])
 		if err != nil {
-			s.RemoveConnection(c)
+			s.dropConnection(c, CloseNormal, "")
 			return
 		}
 	}
+	if !header.Fin {
+		c.message = data
+		return
+	}
+	c.message = nil
 
 	if len(data) == 0 {
 		return
 	}
 
+	c.receivedAt = time.Now()
 	if s.onMessage != nil {
 		s.onMessage(c, data)
 	}
 }
 
+// readBufferSize is the capacity of pooled read buffers. It matches the
+// default MaxFrameSize, so with the default configuration every unfragmented
+// message fits.
+const readBufferSize = 4096
+
+// Pool results, resolved once so the read path doesn't look up labels.
+var (
+	readBufferHits   = metrics.ReadBufferPoolTotal.WithLabelValues("hit")
+	readBufferMisses = metrics.ReadBufferPoolTotal.WithLabelValues("miss")
+	readBufferBypass = metrics.ReadBufferPoolTotal.WithLabelValues("bypass")
+)
+
+// readBuffer returns an n-byte buffer taken from bufPool, and the pool entry
+// to hand to releaseReadBuffer. A message larger than readBufferSize gets a
+// buffer of its own and a nil entry.
+func (s *Server) readBuffer(n int64) ([]byte, *[]byte) {
+	if n > readBufferSize {
+		readBufferBypass.Inc()
+		return make([]byte, n), nil
+	}
+	pooled, _ := s.bufPool.Get().(*[]byte)
+	if pooled == nil {
+		buf := make([]byte, readBufferSize)
+		pooled = &buf
+		readBufferMisses.Inc()
+	} else {
+		readBufferHits.Inc()
+	}
+	return (*pooled)[:n], pooled
+}
+
+// releaseReadBuffer returns a buffer from readBuffer to the pool. A nil entry
+// is ignored.
+func (s *Server) releaseReadBuffer(pooled *[]byte) {
+	if pooled != nil {
+		s.bufPool.Put(pooled)
+	}
+}
+
+// dropMessage discards the current frame's payload and the rest of its
+// message: later fragments are discarded as they arrive until the final one.
+func (s *Server) dropMessage(c *Connection, reader io.Reader, header ws.Header) {
+	_, _ = io.CopyN(io.Discard, reader, header.Length)
+	c.message, c.dropping = nil, !header.Fin
+}
+
 // SetOnDisconnect registers a callback invoked when a connection is removed
 // (due to read error, heartbeat timeout, or graceful close). It is called
 // before the Redis session is deleted, so the handler can inspect session state.
@@ -354,40 +702,117 @@ func (s *Server) SetOnDisconnect(fn func(connID string)) {
 	s.onDisconnect = fn
 }
 
+// SetAdmission registers a check run for every upgrade request before the
+// connection is accepted, e.g. an IP ban lookup. Requests for which it
+// returns false are closed with ClosePolicyViolation. It must be called before
+// Start.
+func (s *Server) SetAdmission(fn func(r *http.Request) bool) {
+	s.admit = fn
+}
+
+// SetLocate registers a GeoIP lookup run for every admitted upgrade
+// request, e.g. of its X-Forwarded-For address. The location is kept on the
+// Connection and the Redis session and counted in
+// whisper_connections_by_country_total. It must be called before Start.
+func (s *Server) SetLocate(fn func(r *http.Request) geoip.Location) {
+	s.locate = fn
+}
+
+// SetClientConfig registers a function building the config message sent to
+// every client right after session_created. It is called per connection, so
+// the message reflects limits changed since startup. It must be called before
+// Start.
+func (s *Server) SetClientConfig(fn func() []byte) {
+	s.clientConfig = fn
+}
+
+// SetOnConnect registers a callback invoked for every accepted connection,
+// new or resumed, once session_created and the config message were sent,
+// e.g. to tell it about maintenance in progress. It must be called before
+// Start.
+func (s *Server) SetOnConnect(fn func(c *Connection)) {
+	s.onConnect = fn
+}
+
+// Broadcast sends data to every connected client, e.g. a config message after
+// the limits changed.
+func (s *Server) Broadcast(data []byte) {
+	s.conns.Broadcast(data)
+}
+
 // RemoveConnection removes a connection from both epoll and the connection
-// manager, and closes the underlying network connection. It is exported so
-// that the heartbeat monitor can evict dead connections.
+// manager, and closes it with CloseNormal.
 func (s *Server) RemoveConnection(c *Connection) {
+	s.CloseConnection(c, CloseNormal, "")
+}
+
+// CloseConnection is RemoveConnection with an explicit close code and
+// reason, e.g. ClosePolicyViolation for a banned user. The session always
+// ends: a deliberate close is never handed off for a resume. With
+// ClosePolicyViolation the Redis session expires after session.AppealWindow
+// rather than being deleted, so a banned user can still appeal.
+func (s *Server) CloseConnection(c *Connection, code CloseCode, reason string) {
+	s.closeConnection(c, code, reason, false)
+}
+
+// dropConnection closes a connection whose transport was lost (a failed read
+// or write, or a missed heartbeat) or that Shutdown closes. Unlike
+// CloseConnection it lets the handoff keep the session for the client to
+// resume.
+func (s *Server) dropConnection(c *Connection, code CloseCode, reason string) {
+	s.closeConnection(c, code, reason, true)
+}
+
+func (s *Server) closeConnection(c *Connection, code CloseCode, reason string, lost bool) {
 	_ = s.epoll.Remove(c.Conn)
 
 	// Guard: only proceed if the connection was actually in the manager.
 	// This prevents double cleanup when multiple goroutines race to remove
 	// the same connection (e.g., read error + heartbeat timeout).
-	if !s.conns.Remove(c.ID) {
+	if s.conns.detach(c.ID) == nil {
 		return
 	}
+	_ = c.CloseWithCode(code, reason)
 	metrics.ConnectionsTotal.Set(float64(s.conns.Count()))
+	s.waiting.notify()
+
+	// A handed-off session stays in Redis for the client to resume.
+	if lost && s.handoff != nil && s.handoff.Detach != nil && s.handoff.Detach(c.ID) {
+		logging.Debugf("ws: connection closed session=%s detached (total=%d)", c.ID, s.conns.Count())
+		return
+	}
 
 	// Notify application layer before deleting session.
 	if s.onDisconnect != nil {
 		s.onDisconnect(c.ID)
 	}
 
-	// Delete session from Redis.
+	// Delete session from Redis. A banned user's session is kept for the
+	// appeal window instead, since an appeal authenticates with its token.
 	if s.sessionStore != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 		defer cancel()
-		if err := s.sessionStore.Delete(ctx, c.ID); err != nil {
+		if code == ClosePolicyViolation {
+			if err := s.sessionStore.Expire(ctx, c.ID, session.AppealWindow); err != nil {
+				log.Printf("ws: failed to expire redis session for %s: %v", c.ID, err)
+			}
+		} else if err := s.sessionStore.Delete(ctx, c.ID); err != nil {
 			log.Printf("ws: failed to delete redis session for %s: %v", c.ID, err)
 		}
 	}
 
-	log.Printf("ws: connection closed session=%s (total=%d)", c.ID, s.conns.Count())
+	logging.Debugf("ws: connection closed session=%s (total=%d)", c.ID, s.conns.Count())
 }
 
 // SendMessage writes a WebSocket text frame to the connection identified by
 // connID. It is goroutine-safe thanks to the per-connection write mutex.
 func (s *Server) SendMessage(connID string, data []byte) error {
+	return s.SendMessageTracked(connID, data, nil)
+}
+
+// SendMessageTracked is SendMessage that calls written with the time the
+// frame carrying data was written (see Connection.WriteMessageTracked).
+func (s *Server) SendMessageTracked(connID string, data []byte, written func(time.Time)) error {
 	c := s.conns.Get(connID)
 	if c == nil {
 		return fmt.Errorf("ws: connection %s not found", connID)
@@ -397,7 +822,7 @@ func (s *Server) SendMessage(connID string, data []byte) error {
 		_ = c.Conn.SetWriteDeadline(time.Now().Add(s.config.WriteTimeout))
 	}
 
-	err := c.WriteMessage(data)
+	err := c.WriteMessageTracked(data, written)
 
 	// Clear write deadline so it doesn't affect future writes (e.g., heartbeat pings).
 	_ = c.Conn.SetWriteDeadline(time.Time{})
@@ -417,14 +842,27 @@ func (s *Server) SessionStore() *session.Store {
 	return s.sessionStore
 }
 
+// StopAccepting rejects new WebSocket upgrades and makes /health report
+// "draining" with a 503, while existing connections keep being served. It is
+// the first stage of a two-stage shutdown; Shutdown does the rest.
+func (s *Server) StopAccepting() {
+	s.draining.Store(true)
+	s.waiting.notify()
+}
+
 // Shutdown performs a graceful shutdown of the server. It first stops
-// accepting new connections, then drains existing connections with a
-// 30-second timeout before force-closing any that remain.
+// accepting new connections, then sends every client server_shutdown and
+// drains existing connections for up to DrainTimeout before force-closing
+// any that remain. The internal
+// listener stays up until the end so /health keeps reporting "draining"
+// and the drain shows up in /metrics.
 func (s *Server) Shutdown() error {
 	log.Println("ws: initiating graceful shutdown...")
 
-	// Phase 1: Stop accepting new connections.
+	// Phase 1: Stop accepting new connections and turn away the waiting
+	// room.
 	s.draining.Store(true)
+	s.waiting.notify()
 
 	// Stop the HTTP listener (no new upgrades).
 	httpCtx, httpCancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -432,54 +870,24 @@ func (s *Server) Shutdown() error {
 	if err := s.httpServer.Shutdown(httpCtx); err != nil {
 		log.Printf("ws: http shutdown error: %v", err)
 	}
-
-	// Phase 2: Notify all connected clients that the server is shutting down.
-	// The onDisconnect callback triggers partner_left notifications so paired
-	// users know their partner is gone before the TCP socket closes.
-	connCount := s.conns.Count()
-	log.Printf("ws: draining %d connections (30s timeout)...", connCount)
-
-	for _, c := range s.conns.All() {
-		if s.onDisconnect != nil {
-			s.onDisconnect(c.ID)
+	if s.redirectServer != nil {
+		if err := s.redirectServer.Shutdown(httpCtx); err != nil {
+			log.Printf("ws: http redirect shutdown error: %v", err)
 		}
 	}
 
-	// Phase 3: Wait for connections to close gracefully, up to 30 seconds.
-	drainDeadline := time.After(30 * time.Second)
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+	// Phase 2: Tell all connected clients that the server is shutting down
+	// and wait for them to leave, up to DrainTimeout.
+	s.drain(s.config.DrainTimeout, shutdownCountdownInterval)
 
-drainLoop:
-	for {
-		select {
-		case <-drainDeadline:
-			remaining := s.conns.Count()
-			if remaining > 0 {
-				log.Printf("ws: drain timeout, force-closing %d connections", remaining)
-			}
-			break drainLoop
-		case <-ticker.C:
-			remaining := s.conns.Count()
-			if remaining == 0 {
-				log.Println("ws: all connections drained successfully")
-				break drainLoop
-			}
-			log.Printf("ws: draining... %d connections remaining", remaining)
-		}
-	}
-
-	// Phase 4: Force-close any remaining connections.
+	// Phase 3: Force-close any remaining connections with a going-away close
+	// frame. They are closed like a lost connection, so a chatting session
+	// is kept for a resume elsewhere (see Handoff) or its partner is told it
+	// left.
 	close(s.done) // Stop the event loop.
 
 	for _, c := range s.conns.All() {
-		if s.sessionStore != nil {
-			delCtx, delCancel := context.WithTimeout(context.Background(), 2*time.Second)
-			_ = s.sessionStore.Delete(delCtx, c.ID)
-			delCancel()
-		}
-		_ = s.epoll.Remove(c.Conn)
-		c.Close()
+		s.dropConnection(c, CloseGoingAway, "server shutting down")
 	}
 
 	// Close the epoll instance.
@@ -487,6 +895,14 @@ drainLoop:
 		_ = s.epoll.Close()
 	}
 
+	if s.internalServer != nil {
+		internalCtx, internalCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer internalCancel()
+		if err := s.internalServer.Shutdown(internalCtx); err != nil {
+			log.Printf("ws: internal listener shutdown error: %v", err)
+		}
+	}
+
 	log.Printf("ws: server stopped, all connections closed")
 	return nil
 }
@@ -0,0 +1,55 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// routeStatus returns the status mux answers path with. Handlers that are
+// not mounted answer 404.
+func routeStatus(mux http.Handler, path string) int {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code
+}
+
+func TestMuxes_InternalAddrSplitsRoutes(t *testing.T) {
+	s := NewServer(ServerConfig{InternalAddr: "127.0.0.1:0", DebugToken: "secret"}, nil, nil)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	s.Handle("/api/public", ok)
+	s.HandleInternal("/admin/", ok)
+	public, internal := s.muxes()
+
+	for _, path := range []string{"/health", "/metrics", "/debug/runtime", "/admin/x"} {
+		if code := routeStatus(public, path); code != http.StatusNotFound {
+			t.Errorf("public %s: status %d, want 404", path, code)
+		}
+		if code := routeStatus(internal, path); code == http.StatusNotFound {
+			t.Errorf("internal %s: not mounted", path)
+		}
+	}
+	for _, path := range []string{"/api/online", "/api/public"} {
+		if code := routeStatus(public, path); code == http.StatusNotFound {
+			t.Errorf("public %s: not mounted", path)
+		}
+		if code := routeStatus(internal, path); code != http.StatusNotFound {
+			t.Errorf("internal %s: status %d, want 404", path, code)
+		}
+	}
+}
+
+func TestMuxes_SharedWithoutInternalAddr(t *testing.T) {
+	s := NewServer(ServerConfig{}, nil, nil)
+	s.HandleInternal("/admin/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	public, internal := s.muxes()
+
+	if public != internal {
+		t.Fatal("expected one mux without InternalAddr")
+	}
+	for _, path := range []string{"/health", "/metrics", "/admin/x", "/api/online"} {
+		if code := routeStatus(public, path); code == http.StatusNotFound {
+			t.Errorf("%s: not mounted", path)
+		}
+	}
+}
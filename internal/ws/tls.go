@@ -0,0 +1,92 @@
+package ws
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSEnabled reports whether the server terminates TLS itself, either with a
+// certificate from disk or one obtained through autocert.
+func (c ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" || len(c.AutocertDomains) > 0
+}
+
+// validateTLS checks that the TLS settings are complete and not ambiguous.
+func (c ServerConfig) validateTLS() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("ws: TLS needs both a certificate and a key file")
+	}
+	if c.TLSCertFile != "" && len(c.AutocertDomains) > 0 {
+		return errors.New("ws: TLS certificate files and autocert domains are mutually exclusive")
+	}
+	if c.HTTPRedirectAddr != "" && !c.TLSEnabled() {
+		return errors.New("ws: HTTP redirect needs TLS to be enabled")
+	}
+	return nil
+}
+
+// tlsSetup builds the listener TLS configuration and the handler for the
+// plain-HTTP redirect listener. With autocert the redirect handler also
+// answers ACME http-01 challenges.
+func (s *Server) tlsSetup() (*tls.Config, http.Handler) {
+	redirect := http.HandlerFunc(s.redirectToHTTPS)
+
+	if len(s.config.AutocertDomains) == 0 {
+		return &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			NextProtos: []string{"http/1.1"},
+		}, redirect
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(s.config.AutocertDomains...),
+	}
+	if s.config.AutocertCacheDir != "" {
+		m.Cache = autocert.DirCache(s.config.AutocertCacheDir)
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{"http/1.1", acme.ALPNProto},
+	}, m.HTTPHandler(redirect)
+}
+
+// redirectToHTTPS sends plain-HTTP requests to the same path on the TLS
+// listener.
+func (s *Server) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(s.config.ListenAddr); err == nil && port != "443" && port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+// isTLSConn reports whether an upgraded connection is a TLS connection.
+func isTLSConn(conn net.Conn) bool {
+	_, ok := conn.(*tls.Conn)
+	return ok
+}
+
+// serveTLSConn reads frames from a TLS connection until it is removed.
+// tls.Conn buffers decrypted records that epoll cannot see, so instead of
+// being registered with epoll each TLS connection gets its own reader. The
+// worker pool and overload policy do not apply to these connections.
+func (s *Server) serveTLSConn(c *Connection) {
+	for s.conns.Get(c.ID) == c {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		s.handleFrame(c, false)
+	}
+}
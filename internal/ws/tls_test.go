@@ -0,0 +1,52 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerConfig_ValidateTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ServerConfig
+		wantErr bool
+	}{
+		{"plain", ServerConfig{}, false},
+		{"files", ServerConfig{TLSCertFile: "c.pem", TLSKeyFile: "k.pem"}, false},
+		{"autocert", ServerConfig{AutocertDomains: []string{"chat.example.com"}, HTTPRedirectAddr: ":80"}, false},
+		{"cert without key", ServerConfig{TLSCertFile: "c.pem"}, true},
+		{"files and autocert", ServerConfig{TLSCertFile: "c.pem", TLSKeyFile: "k.pem", AutocertDomains: []string{"a"}}, true},
+		{"redirect without tls", ServerConfig{HTTPRedirectAddr: ":80"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.validateTLS(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateTLS() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		listen string
+		host   string
+		want   string
+	}{
+		{":443", "chat.example.com", "https://chat.example.com/ws?x=1"},
+		{":443", "chat.example.com:80", "https://chat.example.com/ws?x=1"},
+		{":8443", "chat.example.com:8080", "https://chat.example.com:8443/ws?x=1"},
+	}
+	for _, tt := range tests {
+		s := &Server{config: ServerConfig{ListenAddr: tt.listen}}
+		req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/ws?x=1", nil)
+		rec := httptest.NewRecorder()
+		s.redirectToHTTPS(rec, req)
+
+		if rec.Code != http.StatusMovedPermanently {
+			t.Errorf("listen=%s host=%s: status %d", tt.listen, tt.host, rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != tt.want {
+			t.Errorf("listen=%s host=%s: Location = %q, want %q", tt.listen, tt.host, got, tt.want)
+		}
+	}
+}
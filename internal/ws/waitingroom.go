@@ -0,0 +1,222 @@
+package ws
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"github.com/whisper/chat-app/internal/geoip"
+	"github.com/whisper/chat-app/internal/metrics"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// waitingRoomUpdateInterval is how often waiting clients are sent their
+// position. The write also finds clients that have gone away, since
+// waiting connections are not read.
+const waitingRoomUpdateInterval = 5 * time.Second
+
+// waiter is an upgraded connection waiting for a connection slot.
+type waiter struct {
+	conn     net.Conn
+	r        *http.Request // the upgrade request, for its resume and batch parameters
+	loc      geoip.Location
+	joinedAt time.Time
+}
+
+// waitingRoom holds upgraded connections, first come first served, while the
+// server is at MaxConnections. They are not registered with epoll or given a
+// session until admitted. A nil waitingRoom is a disabled one.
+type waitingRoom struct {
+	size         int
+	writeTimeout time.Duration
+	wake         chan struct{} // signalled when a slot may have freed up
+
+	mu      sync.Mutex
+	waiters []*waiter
+}
+
+func newWaitingRoom(size int, writeTimeout time.Duration) *waitingRoom {
+	return &waitingRoom{
+		size:         size,
+		writeTimeout: writeTimeout,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Len returns the number of waiting connections.
+func (wr *waitingRoom) Len() int {
+	if wr == nil {
+		return 0
+	}
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	return len(wr.waiters)
+}
+
+// full reports whether the room cannot take another connection. A disabled
+// room is always full.
+func (wr *waitingRoom) full() bool {
+	return wr == nil || wr.Len() >= wr.size
+}
+
+// join sends conn its position and adds it to the back of the room. It
+// reports false, leaving conn to the caller, if the room filled up or the
+// position could not be sent.
+func (wr *waitingRoom) join(conn net.Conn, r *http.Request, loc geoip.Location, now time.Time) bool {
+	wr.mu.Lock()
+	position := len(wr.waiters) + 1
+	wr.mu.Unlock()
+	if position > wr.size || wr.sendPosition(conn, position, position) != nil {
+		return false
+	}
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	if len(wr.waiters) >= wr.size {
+		return false
+	}
+	wr.waiters = append(wr.waiters, &waiter{conn: conn, r: r, loc: loc, joinedAt: now})
+	metrics.WaitingRoomDepth.Set(float64(len(wr.waiters)))
+	return true
+}
+
+// next removes and returns the connection that has waited longest, or nil
+// if the room is empty.
+func (wr *waitingRoom) next() *waiter {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	if len(wr.waiters) == 0 {
+		return nil
+	}
+	w := wr.waiters[0]
+	wr.waiters[0] = nil
+	wr.waiters = wr.waiters[1:]
+	metrics.WaitingRoomDepth.Set(float64(len(wr.waiters)))
+	return w
+}
+
+// notify wakes the admission loop, e.g. after a connection closed.
+func (wr *waitingRoom) notify() {
+	if wr == nil {
+		return
+	}
+	select {
+	case wr.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sendPositions sends every waiting connection its position and drops
+// those the write fails on.
+func (wr *waitingRoom) sendPositions() {
+	wr.mu.Lock()
+	waiters := append([]*waiter(nil), wr.waiters...)
+	wr.mu.Unlock()
+
+	gone := make(map[*waiter]bool)
+	for i, w := range waiters {
+		if err := wr.sendPosition(w.conn, i+1, len(waiters)); err != nil {
+			gone[w] = true
+			_ = w.conn.Close()
+		}
+	}
+	if len(gone) == 0 {
+		return
+	}
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	kept := wr.waiters[:0]
+	for _, w := range wr.waiters {
+		if !gone[w] {
+			kept = append(kept, w)
+		}
+	}
+	clear(wr.waiters[len(kept):])
+	wr.waiters = kept
+	metrics.WaitingRoomDepth.Set(float64(len(wr.waiters)))
+	log.Printf("ws: waiting room dropped %d closed connections (%d waiting)", len(gone), len(kept))
+}
+
+// sendPosition writes a waiting_room message to conn.
+func (wr *waitingRoom) sendPosition(conn net.Conn, position, waiting int) error {
+	data, err := protocol.NewServerMessage(protocol.TypeWaitingRoom, protocol.WaitingRoomMsg{
+		Position: position,
+		Waiting:  waiting,
+	})
+	if err != nil {
+		return err
+	}
+	if wr.writeTimeout > 0 {
+		_ = conn.SetWriteDeadline(time.Now().Add(wr.writeTimeout))
+		defer conn.SetWriteDeadline(time.Time{})
+	}
+	return wsutil.WriteServerMessage(conn, ws.OpText, data)
+}
+
+// closeAll closes every waiting connection with code and reason.
+func (wr *waitingRoom) closeAll(code CloseCode, reason string) {
+	wr.mu.Lock()
+	waiters := wr.waiters
+	wr.waiters = nil
+	wr.mu.Unlock()
+	metrics.WaitingRoomDepth.Set(0)
+
+	for _, w := range waiters {
+		_ = writeCloseFrame(w.conn, code, reason)
+		_ = w.conn.Close()
+	}
+}
+
+// StartWaitingRoom begins a background goroutine that admits waiting
+// connections as connection slots free up and sends the rest their position
+// every waitingRoomUpdateInterval. It does nothing if
+// ServerConfig.WaitingRoomSize is zero; otherwise the goroutine exits when
+// the server's done channel is closed, turning away anyone still waiting.
+func StartWaitingRoom(server *Server) {
+	wr := server.waiting
+	if wr == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(waitingRoomUpdateInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-server.done:
+				wr.closeAll(CloseTryAgainLater, "server shutting down")
+				return
+			case <-wr.wake:
+				server.admitWaiting(time.Now())
+			case <-ticker.C:
+				server.admitWaiting(time.Now())
+				wr.sendPositions()
+			}
+		}
+	}()
+}
+
+// admitWaiting accepts waiting connections, longest waiting first, while
+// the server is below MaxConnections. Once the server is draining it turns
+// them all away instead.
+func (s *Server) admitWaiting(now time.Time) {
+	if s.draining.Load() {
+		s.waiting.closeAll(CloseTryAgainLater, "server shutting down")
+		return
+	}
+	for s.conns.Count() < s.config.MaxConnections {
+		w := s.waiting.next()
+		if w == nil {
+			return
+		}
+		metrics.WaitingRoomAdmissionSeconds.Observe(now.Sub(w.joinedAt).Seconds())
+		s.accept(w.conn, w.r, w.loc)
+	}
+}
@@ -0,0 +1,132 @@
+package ws
+
+import (
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/whisper/chat-app/internal/geoip"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// newWaitingClient returns the server side of a pipe whose client side is
+// read in the background, and the channel of frames the client receives.
+func newWaitingClient(t *testing.T) (net.Conn, net.Conn, <-chan []byte) {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	t.Cleanup(func() {
+		serverSide.Close()
+		clientSide.Close()
+	})
+	return serverSide, clientSide, readFrames(t, clientSide)
+}
+
+// nextPosition waits for the next waiting_room message among frames.
+func nextPosition(t *testing.T, frames <-chan []byte) protocol.WaitingRoomMsg {
+	t.Helper()
+	select {
+	case data, ok := <-frames:
+		if !ok {
+			t.Fatal("connection closed, want a waiting_room message")
+		}
+		var msg protocol.WaitingRoomMsg
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Type != protocol.TypeWaitingRoom {
+			t.Fatalf("got %s, want a waiting_room message", data)
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no waiting_room message")
+		return protocol.WaitingRoomMsg{}
+	}
+}
+
+func TestWaitingRoom_JoinSendsPositionAndAdmitsInOrder(t *testing.T) {
+	wr := newWaitingRoom(2, time.Second)
+	r := httptest.NewRequest("GET", "/ws", nil)
+	a, _, framesA := newWaitingClient(t)
+	b, _, framesB := newWaitingClient(t)
+	c, _, _ := newWaitingClient(t)
+
+	if !wr.join(a, r, geoip.Location{}, time.Now()) || !wr.join(b, r, geoip.Location{}, time.Now()) {
+		t.Fatal("join failed with room to spare")
+	}
+	if msg := nextPosition(t, framesA); msg.Position != 1 || msg.Waiting != 1 {
+		t.Errorf("first client got %+v, want position 1 of 1", msg)
+	}
+	if msg := nextPosition(t, framesB); msg.Position != 2 || msg.Waiting != 2 {
+		t.Errorf("second client got %+v, want position 2 of 2", msg)
+	}
+	if wr.join(c, r, geoip.Location{}, time.Now()) {
+		t.Error("join succeeded with the room full")
+	}
+	if !wr.full() {
+		t.Error("full = false with the room full")
+	}
+
+	if w := wr.next(); w == nil || w.conn != a {
+		t.Fatal("next did not return the longest waiting connection")
+	}
+	if w := wr.next(); w == nil || w.conn != b {
+		t.Fatal("next did not return the second connection")
+	}
+	if wr.next() != nil || wr.Len() != 0 {
+		t.Error("room not empty after admitting everyone")
+	}
+}
+
+func TestWaitingRoom_SendPositionsDropsClosedConnections(t *testing.T) {
+	wr := newWaitingRoom(10, time.Second)
+	r := httptest.NewRequest("GET", "/ws", nil)
+	a, clientA, framesA := newWaitingClient(t)
+	b, _, framesB := newWaitingClient(t)
+	wr.join(a, r, geoip.Location{}, time.Now())
+	wr.join(b, r, geoip.Location{}, time.Now())
+	nextPosition(t, framesA)
+	nextPosition(t, framesB)
+
+	clientA.Close()
+	wr.sendPositions()
+
+	if wr.Len() != 1 {
+		t.Fatalf("Len = %d after the first client left, want 1", wr.Len())
+	}
+	// The departure is only noticed by this round's writes, so the second
+	// client moves up on the next one.
+	nextPosition(t, framesB)
+	wr.sendPositions()
+	if msg := nextPosition(t, framesB); msg.Position != 1 || msg.Waiting != 1 {
+		t.Errorf("second client got %+v, want position 1 of 1", msg)
+	}
+}
+
+func TestAdmitWaiting_TurnsAwayWhileDraining(t *testing.T) {
+	s, _, _ := newTestWorkerServer(t, ServerConfig{MaxConnections: 1, WaitingRoomSize: 5}, nil)
+	conn, _, frames := newWaitingClient(t)
+	s.waiting.join(conn, httptest.NewRequest("GET", "/ws", nil), geoip.Location{}, time.Now())
+	nextPosition(t, frames)
+
+	s.StopAccepting()
+	s.admitWaiting(time.Now())
+
+	if s.waiting.Len() != 0 {
+		t.Errorf("Len = %d while draining, want 0", s.waiting.Len())
+	}
+	select {
+	case data, ok := <-frames:
+		if ok {
+			t.Errorf("got %s, want the connection closed", data)
+		}
+	case <-time.After(time.Second):
+		t.Error("waiting connection not closed while draining")
+	}
+}
+
+func TestWaitingRoom_DisabledIsFull(t *testing.T) {
+	s := NewServer(ServerConfig{MaxConnections: 1}, nil, nil)
+	if s.waiting != nil || !s.waiting.full() || s.waiting.Len() != 0 {
+		t.Error("a server without WaitingRoomSize has a usable waiting room")
+	}
+	s.waiting.notify()
+}
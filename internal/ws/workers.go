@@ -0,0 +1,141 @@
+package ws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/whisper/chat-app/internal/metrics"
+)
+
+// OverloadPolicy decides what the event loop does with a ready connection
+// when every worker is busy and the dispatch queue is full.
+type OverloadPolicy string
+
+const (
+	// OverloadBlock waits for a free queue slot. No frame is lost, but the
+	// event loop (and with it every other connection) stalls until a worker
+	// catches up.
+	OverloadBlock OverloadPolicy = "block"
+
+	// OverloadDrop hands the frame to a small pool of shed responders that
+	// read it, discard it and answer with a server_busy error, so the event
+	// loop keeps serving other connections. Control frames are still
+	// handled. Only once the responders fall behind as well does the event
+	// loop wait, as with OverloadBlock.
+	OverloadDrop OverloadPolicy = "drop"
+)
+
+const (
+	// shedWorkers is the number of shed responders under OverloadDrop.
+	// Shedding reads one frame and writes a short error, so a few keep up
+	// with the overflow of a saturated worker pool.
+	shedWorkers = 4
+
+	// shedQueueSize bounds the connections waiting for a shed responder.
+	shedQueueSize = 256
+)
+
+// ParseOverloadPolicy parses a WORKER_OVERLOAD_POLICY value.
+func ParseOverloadPolicy(s string) (OverloadPolicy, error) {
+	switch p := OverloadPolicy(s); p {
+	case OverloadBlock, OverloadDrop:
+		return p, nil
+	default:
+		return "", fmt.Errorf("ws: unknown overload policy %q (want %q or %q)", s, OverloadBlock, OverloadDrop)
+	}
+}
+
+// dispatchJob is a connection with a readable frame, claimed by the event
+// loop and waiting for a worker.
+type dispatchJob struct {
+	c        *Connection
+	queuedAt time.Time
+}
+
+// startWorkers launches WorkerPoolSize goroutines that serve the dispatch
+// queue until the server is shut down.
+func (s *Server) startWorkers() {
+	for i := 0; i < s.config.WorkerPoolSize; i++ {
+		go s.runWorker()
+	}
+	s.startShedders()
+}
+
+// startShedders launches the shed responders when the overload policy is
+// OverloadDrop.
+func (s *Server) startShedders() {
+	if s.shedQueue == nil {
+		return
+	}
+	for i := 0; i < shedWorkers; i++ {
+		go s.runShedder()
+	}
+}
+
+func (s *Server) runShedder() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case c := <-s.shedQueue:
+			s.handleFrame(c, true)
+			c.release()
+		}
+	}
+}
+
+func (s *Server) runWorker() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case job := <-s.dispatchQueue:
+			metrics.DispatchQueueDepth.Set(float64(len(s.dispatchQueue)))
+			metrics.DispatchWait.Observe(time.Since(job.queuedAt).Seconds())
+
+			s.setBusyWorkers(s.busyWorkers.Add(1))
+			start := time.Now()
+			s.handleFrame(job.c, false)
+			metrics.FrameHandleDuration.Observe(time.Since(start).Seconds())
+			s.setBusyWorkers(s.busyWorkers.Add(-1))
+
+			job.c.release()
+		}
+	}
+}
+
+func (s *Server) setBusyWorkers(n int64) {
+	if s.config.WorkerPoolSize > 0 {
+		metrics.WorkerSaturation.Set(float64(n) / float64(s.config.WorkerPoolSize))
+	}
+}
+
+// dispatch hands a claimed connection to the worker pool, applying the
+// configured OverloadPolicy when the dispatch queue is full. The connection
+// is released once its frame has been handled or shed.
+func (s *Server) dispatch(c *Connection) {
+	job := dispatchJob{c: c, queuedAt: time.Now()}
+
+	select {
+	case s.dispatchQueue <- job:
+		metrics.DispatchQueueDepth.Set(float64(len(s.dispatchQueue)))
+		return
+	default:
+	}
+
+	select {
+	case s.shedQueue <- c: // nil, and never ready, unless OverloadDrop
+		return
+	default:
+	}
+
+	// Wait for a worker, or with OverloadDrop for whichever of a worker
+	// and a shed responder frees up first.
+	select {
+	case s.dispatchQueue <- job:
+		metrics.DispatchQueueDepth.Set(float64(len(s.dispatchQueue)))
+	case s.shedQueue <- c:
+	case <-s.done:
+		c.release()
+	}
+}
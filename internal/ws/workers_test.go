@@ -0,0 +1,212 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// newTestWorkerServer returns an unstarted server and one piped connection.
+// onMessage receives data frames handled by a worker.
+func newTestWorkerServer(t *testing.T, config ServerConfig, onMessage func(*Connection, []byte)) (*Server, *Connection, net.Conn) {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	s := NewServer(config, nil, onMessage)
+	t.Cleanup(func() {
+		close(s.done)
+		serverSide.Close()
+		clientSide.Close()
+	})
+	return s, &Connection{ID: "conn-1", Conn: serverSide}, clientSide
+}
+
+func TestParseOverloadPolicy(t *testing.T) {
+	for _, v := range []string{"block", "drop"} {
+		if p, err := ParseOverloadPolicy(v); err != nil || string(p) != v {
+			t.Errorf("ParseOverloadPolicy(%q) = %q, %v", v, p, err)
+		}
+	}
+	if _, err := ParseOverloadPolicy("queue"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestDispatch_BlockPolicyHandsFrameToWorker(t *testing.T) {
+	received := make(chan string, 1)
+	config := ServerConfig{WorkerPoolSize: 1, OverloadPolicy: OverloadBlock}
+	s, c, client := newTestWorkerServer(t, config, func(_ *Connection, data []byte) {
+		received <- string(data)
+	})
+	s.startWorkers()
+
+	go wsutil.WriteClientText(client, []byte("hello"))
+
+	if !c.claim() {
+		t.Fatal("expected to claim idle connection")
+	}
+	s.dispatch(c)
+
+	select {
+	case got := <-received:
+		if got != "hello" {
+			t.Errorf("expected hello, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker did not handle the frame")
+	}
+}
+
+func TestHandleFrame_StampsReceivedAt(t *testing.T) {
+	var receivedAt time.Time
+	s, c, client := newTestWorkerServer(t, ServerConfig{}, func(c *Connection, _ []byte) {
+		receivedAt = c.ReceivedAt()
+	})
+	go wsutil.WriteClientText(client, []byte("hello"))
+
+	before := time.Now()
+	s.handleFrame(c, false)
+	if receivedAt.Before(before) || receivedAt.After(time.Now()) {
+		t.Errorf("ReceivedAt = %v in the message callback, want the time the frame was read", receivedAt)
+	}
+}
+
+func TestHandleFrame_ReadBufferPool(t *testing.T) {
+	var got []string
+	s, c, client := newTestWorkerServer(t, ServerConfig{MaxFrameSize: 2 * readBufferSize}, func(_ *Connection, data []byte) {
+		got = append(got, string(data))
+	})
+	large := strings.Repeat("x", readBufferSize+1)
+	bypass := testutil.ToFloat64(readBufferBypass)
+
+	// A shorter message after a longer one must not see the longer one's
+	// tail in a reused buffer.
+	writeFrames(client,
+		ws.NewTextFrame([]byte(`{"type":"ping"}`)),
+		ws.NewTextFrame([]byte("ok")),
+		ws.NewTextFrame([]byte(large)),
+	)
+	for i := 0; i < 3; i++ {
+		s.handleFrame(c, false)
+	}
+
+	if len(got) != 3 || got[0] != `{"type":"ping"}` || got[1] != "ok" || got[2] != large {
+		t.Fatalf("delivered %d messages %.40q, want the three messages intact", len(got), got)
+	}
+	if n := testutil.ToFloat64(readBufferBypass) - bypass; n != 1 {
+		t.Errorf("bypass count grew by %v, want 1 for the message larger than a pooled buffer", n)
+	}
+}
+
+func TestDispatch_DropPolicyBoundsShedResponders(t *testing.T) {
+	config := ServerConfig{WorkerPoolSize: 1, OverloadPolicy: OverloadDrop}
+	s, _, _ := newTestWorkerServer(t, config, nil)
+	// Without workers or shed responders, every dispatch beyond the shed
+	// queue must wait instead of starting a goroutine per frame.
+	for i := 0; i < shedQueueSize; i++ {
+		c := &Connection{ID: "shed"}
+		c.claim()
+		s.dispatch(c)
+	}
+	if len(s.shedQueue) != shedQueueSize {
+		t.Fatalf("shed queue holds %d connections, want %d", len(s.shedQueue), shedQueueSize)
+	}
+
+	dispatched := make(chan struct{})
+	go func() {
+		c := &Connection{ID: "overflow"}
+		c.claim()
+		s.dispatch(c)
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+		t.Fatal("dispatch returned with every shed responder busy")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A free shed slot lets the waiting dispatch through.
+	<-s.shedQueue
+	select {
+	case <-dispatched:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dispatch still waiting after a shed slot freed up")
+	}
+}
+
+func TestDispatch_DropPolicyShedsFrame(t *testing.T) {
+	config := ServerConfig{WorkerPoolSize: 1, OverloadPolicy: OverloadDrop}
+	s, c, client := newTestWorkerServer(t, config, func(*Connection, []byte) {
+		t.Error("shed frame must not reach the message handler")
+	})
+	// No workers are started and the queue is unbuffered, so the pool is
+	// saturated; only the shed responders run.
+	s.startShedders()
+
+	go wsutil.WriteClientText(client, []byte("hello"))
+
+	if !c.claim() {
+		t.Fatal("expected to claim idle connection")
+	}
+	s.dispatch(c)
+
+	_ = client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	data, err := wsutil.ReadServerText(client)
+	if err != nil {
+		t.Fatalf("expected server_busy error, got read error: %v", err)
+	}
+	var msg protocol.ErrorMsg
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("invalid server message: %v", err)
+	}
+	if msg.Type != protocol.TypeError || msg.Code != "server_busy" {
+		t.Errorf("expected server_busy error, got %s", data)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !c.claim() {
+		if time.Now().After(deadline) {
+			t.Fatal("connection was not released after shedding")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// BenchmarkHandleFrame reads a stream of typical chat messages to measure
+// the allocations made per frame on the read path.
+func BenchmarkHandleFrame(b *testing.B) {
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+	s := NewServer(ServerConfig{MaxFrameSize: readBufferSize}, nil, func(*Connection, []byte) {})
+	c := &Connection{ID: "conn-1", Conn: serverSide}
+
+	payload := []byte(`{"type":"message","content":"` + strings.Repeat("hello ", 20) + `"}`)
+	var frame bytes.Buffer
+	if err := ws.WriteFrame(&frame, ws.MaskFrameInPlace(ws.NewTextFrame(payload))); err != nil {
+		b.Fatal(err)
+	}
+	raw := frame.Bytes()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			if _, err := clientSide.Write(raw); err != nil {
+				return
+			}
+		}
+	}()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.handleFrame(c, false)
+	}
+}
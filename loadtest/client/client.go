@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gobwas/ws"
@@ -17,7 +18,7 @@ import (
 )
 
 // ---------------------------------------------------------------------------
-// Protocol message types (local equivalents of internal/protocol constants)
+// Protocol message types (local equivalents of pkg/protocol constants)
 // ---------------------------------------------------------------------------
 
 // Client -> Server message types.
@@ -73,7 +74,10 @@ type Client struct {
 	mu        sync.Mutex
 	metrics   Metrics
 	handlers  map[string]func(json.RawMessage)
+	onAny     func(msgType string, raw json.RawMessage)
 	done      chan struct{}
+	readDone  chan struct{} // closed when readLoop returns
+	readDelay atomic.Int64  // nanoseconds to stall before the next read
 	closeOnce sync.Once
 	firstMsg  time.Time
 }
@@ -94,6 +98,7 @@ func New(ctx context.Context, url string) (*Client, error) {
 		conn:     conn,
 		handlers: make(map[string]func(json.RawMessage)),
 		done:     make(chan struct{}),
+		readDone: make(chan struct{}),
 	}
 	c.metrics.ConnectLatency = time.Since(start)
 
@@ -115,6 +120,22 @@ func (c *Client) Send(msg interface{}) error {
 	return wsutil.WriteClientMessage(c.conn, ws.OpText, data)
 }
 
+// SendRaw sends data as a text frame without encoding it, so callers can
+// send malformed or hand-built JSON. It is goroutine-safe.
+func (c *Client) SendRaw(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics.MessagesSent++
+	return wsutil.WriteClientMessage(c.conn, ws.OpText, data)
+}
+
+// OnAny registers a handler invoked for every text frame before the
+// per-type handler. msgType is empty when the frame is not a JSON object
+// with a type field. Like On, it must be registered before traffic flows.
+func (c *Client) OnAny(handler func(msgType string, raw json.RawMessage)) {
+	c.onAny = handler
+}
+
 // On registers a handler for a specific server message type. The handler
 // receives the full raw JSON of the message for flexible decoding.
 // Handlers are invoked from the read loop goroutine so they should not block
@@ -155,6 +176,20 @@ func (c *Client) Close() error {
 	return err
 }
 
+// Done returns a channel that is closed once the read loop has stopped,
+// whether because Close was called or because the server closed the
+// connection.
+func (c *Client) Done() <-chan struct{} {
+	return c.readDone
+}
+
+// DelayReads stalls the read loop for d before it reads the next frame,
+// simulating a client that stops draining its socket. Frames keep arriving
+// in the kernel buffers meanwhile.
+func (c *Client) DelayReads(d time.Duration) {
+	c.readDelay.Store(int64(d))
+}
+
 // SessionID returns the session ID assigned by the server, or an empty string
 // if the handshake has not completed yet.
 func (c *Client) SessionID() string {
@@ -170,6 +205,7 @@ func (c *Client) GetMetrics() Metrics {
 // them to registered handlers. It runs until the connection is closed or an
 // unrecoverable error occurs.
 func (c *Client) readLoop() {
+	defer close(c.readDone)
 	for {
 		select {
 		case <-c.done:
@@ -177,6 +213,14 @@ func (c *Client) readLoop() {
 		default:
 		}
 
+		if d := time.Duration(c.readDelay.Swap(0)); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-c.done:
+				return
+			}
+		}
+
 		data, err := wsutil.ReadServerText(c.conn)
 		if err != nil {
 			select {
@@ -200,7 +244,11 @@ func (c *Client) readLoop() {
 		var envelope struct {
 			Type string `json:"type"`
 		}
-		if err := json.Unmarshal(data, &envelope); err != nil {
+		err = json.Unmarshal(data, &envelope)
+		if c.onAny != nil {
+			c.onAny(envelope.Type, json.RawMessage(data))
+		}
+		if err != nil {
 			continue
 		}
 
@@ -0,0 +1,439 @@
+// Package main implements a protocol conformance fuzz bot for staging. It
+// keeps a handful of connections open and sends randomized-but-structured
+// sequences of valid and invalid protocol messages: malformed JSON, unknown
+// types, out-of-order accepts, messages into foreign chat IDs, oversized
+// frames and giant interests arrays.
+//
+// After every probe it asserts that the server is still alive (the
+// connection answers ping with pong and /health responds), that every frame
+// it sent back is a JSON object with a type, that error frames carry a code
+// and a message, and that probes the server must reject were answered with
+// a structured error. Failures are printed with the seed, bot and payload so
+// they can be replayed.
+//
+// Usage:
+//
+//	go run ./cmd/fuzzbot/ [-url ws://staging:8080/ws] [-api http://staging:8080] [-bots 4] [-duration 5m] [-seed N]
+//
+// Exit code 0 if no invariant was violated, 1 otherwise.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/whisper/chat-app/loadtest/client"
+)
+
+// ---------------------------------------------------------------------------
+// Probes
+// ---------------------------------------------------------------------------
+
+// botState is what a bot has learned from the server so far. Probes use it
+// to send plausible-but-wrong messages, e.g. accepting a chat twice.
+type botState struct {
+	sessionID string
+	chatID    string // last chat ID seen in match_found or match_accepted
+}
+
+// probe is one fuzz step. build returns the frame to send and whether the
+// server must answer it with a structured error.
+type probe struct {
+	name  string
+	build func(r *rand.Rand, st *botState) (frame []byte, wantError bool)
+}
+
+// maxFrameSize mirrors the server's frame size limit.
+const maxFrameSize = 4096
+
+var probes = []probe{
+	{"malformed_json", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		valid := mustJSON(map[string]interface{}{"type": "find_match", "interests": []string{"music"}})
+		return valid[:1+r.Intn(len(valid)-1)], true
+	}},
+	{"not_an_object", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		return []byte(pick(r, `[]`, `"find_match"`, `42`, `null`, `true`)), true
+	}},
+	{"missing_type", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		return mustJSON(map[string]interface{}{"chat_id": randomID(r), "text": randomText(r, 20)}), true
+	}},
+	{"unknown_type", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		return mustJSON(map[string]interface{}{"type": randomText(r, 12)}), true
+	}},
+	{"wrong_field_types", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		return []byte(pick(r,
+			`{"type":"find_match","interests":"music"}`,
+			`{"type":"message","chat_id":42,"text":["hi"]}`,
+			`{"type":"typing","chat_id":"x","is_typing":"yes"}`,
+			`{"type":"accept_match","chat_id":{"nested":true}}`,
+		)), true
+	}},
+	{"oversized_frame", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		return mustJSON(map[string]interface{}{"type": "message", "chat_id": randomID(r), "text": strings.Repeat("a", maxFrameSize+r.Intn(4096))}), true
+	}},
+	{"giant_interests", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		interests := make([]string, 2000+r.Intn(8000))
+		for i := range interests {
+			interests[i] = randomText(r, 1+r.Intn(8))
+		}
+		return mustJSON(map[string]interface{}{"type": "find_match", "interests": interests}), true
+	}},
+	{"many_interests", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		// Just under the frame limit, so the handler sees the whole array.
+		interests := make([]string, 0, 600)
+		for size := 40; size < maxFrameSize-200; {
+			tag := randomText(r, 1+r.Intn(6))
+			interests = append(interests, tag)
+			size += len(tag) + 3
+		}
+		return mustJSON(map[string]interface{}{"type": "find_match", "interests": interests}), false
+	}},
+	{"find_then_cancel", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		if r.Intn(2) == 0 {
+			return mustJSON(map[string]interface{}{"type": "cancel_match"}), false
+		}
+		return mustJSON(map[string]interface{}{"type": "find_match", "interests": []string{"fuzz", randomText(r, 5)}}), false
+	}},
+	{"foreign_accept", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		return mustJSON(map[string]interface{}{"type": pick(r, "accept_match", "decline_match"), "chat_id": randomID(r)}), false
+	}},
+	{"out_of_order_accept", func(r *rand.Rand, st *botState) ([]byte, bool) {
+		// Accept (or decline) the last chat again, possibly long after it
+		// was resolved.
+		chatID := st.chatID
+		if chatID == "" {
+			chatID = randomID(r)
+		}
+		return mustJSON(map[string]interface{}{"type": pick(r, "accept_match", "decline_match"), "chat_id": chatID}), false
+	}},
+	{"foreign_message", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		return mustJSON(map[string]interface{}{"type": "message", "chat_id": randomID(r), "text": randomText(r, 1+r.Intn(100))}), true
+	}},
+	{"foreign_chat_action", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		msg := map[string]interface{}{"type": pick(r, "typing", "end_chat", "report", "block", "chat_meta", "share_card", "request_transcript"), "chat_id": randomID(r)}
+		if r.Intn(2) == 0 {
+			msg["reason"] = randomText(r, 30)
+			msg["is_typing"] = true
+		}
+		return mustJSON(msg), false
+	}},
+	{"hostile_strings", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		text := pick(r, "\x00\x01\x02", "\u202e\u200b\ufeff", "💥"+strings.Repeat("\u0301", 200), `"}]}{"type":"end_chat"`, "\xff\xfe")
+		return mustJSON(map[string]interface{}{"type": pick(r, "set_fingerprint", "resume_session", "message"), "fingerprint": text, "previous_session_id": text, "chat_id": text, "text": text}), false
+	}},
+	{"deep_nesting", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		depth := 100 + r.Intn(900)
+		return []byte(`{"type":"find_match","interests":` + strings.Repeat("[", depth) + strings.Repeat("]", depth) + `}`), true
+	}},
+	{"huge_numbers", func(r *rand.Rand, _ *botState) ([]byte, bool) {
+		return []byte(`{"type":"typing","chat_id":"` + randomID(r) + `","is_typing":1e999999}`), true
+	}},
+}
+
+// ---------------------------------------------------------------------------
+// Invariants
+// ---------------------------------------------------------------------------
+
+// errorTypes are server frames that count as a structured rejection.
+var errorTypes = map[string]bool{
+	client.TypeError:       true,
+	client.TypeRateLimited: true,
+	"service_unavailable":  true,
+}
+
+// report collects probe outcomes and invariant violations across bots.
+type report struct {
+	mu         sync.Mutex
+	probes     map[string]int
+	silent     map[string]int // probes answered with nothing (allowed)
+	violations []string
+	sent       atomic.Int64
+}
+
+func (rep *report) violation(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	rep.mu.Lock()
+	rep.violations = append(rep.violations, msg)
+	rep.mu.Unlock()
+	fmt.Println("[FAIL] " + msg)
+}
+
+func (rep *report) record(name string, answered bool) {
+	rep.mu.Lock()
+	rep.probes[name]++
+	if !answered {
+		rep.silent[name]++
+	}
+	rep.mu.Unlock()
+}
+
+// checkFrame validates the shape of a server frame.
+func checkFrame(msgType string, raw json.RawMessage) error {
+	if msgType == "" {
+		return fmt.Errorf("frame is not a typed JSON object: %s", truncate(raw, 200))
+	}
+	if msgType == client.TypeError {
+		var e struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(raw, &e); err != nil || e.Code == "" || e.Message == "" {
+			return fmt.Errorf("error frame without code or message: %s", truncate(raw, 200))
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Bot
+// ---------------------------------------------------------------------------
+
+type botConfig struct {
+	url          string
+	interval     time.Duration
+	replyTimeout time.Duration
+}
+
+// runBot keeps one connection fuzzing until ctx is done, reconnecting after
+// a violation closes it.
+func runBot(ctx context.Context, id int, seed int64, cfg botConfig, rep *report) {
+	r := rand.New(rand.NewSource(seed))
+	for ctx.Err() == nil {
+		if err := fuzzConnection(ctx, id, seed, r, cfg, rep); err != nil {
+			rep.violation("bot=%d seed=%d: %v", id, seed, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+func fuzzConnection(ctx context.Context, id int, seed int64, r *rand.Rand, cfg botConfig, rep *report) error {
+	c, err := client.New(ctx, cfg.url)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer c.Close()
+
+	st := &botState{}
+	var stMu sync.Mutex
+	errorsCh := make(chan string, 64)
+	pongs := make(chan struct{}, 8)
+
+	c.OnAny(func(msgType string, raw json.RawMessage) {
+		if err := checkFrame(msgType, raw); err != nil {
+			rep.violation("bot=%d seed=%d: %v", id, seed, err)
+			return
+		}
+		switch {
+		case errorTypes[msgType]:
+			select {
+			case errorsCh <- msgType:
+			default:
+			}
+		case msgType == client.TypePong:
+			select {
+			case pongs <- struct{}{}:
+			default:
+			}
+		case msgType == client.TypeMatchFound || msgType == client.TypeMatchAccepted:
+			var m struct {
+				ChatID string `json:"chat_id"`
+			}
+			if json.Unmarshal(raw, &m) == nil && m.ChatID != "" {
+				stMu.Lock()
+				st.chatID = m.ChatID
+				stMu.Unlock()
+			}
+		}
+	})
+
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	err = c.WaitForSession(waitCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+	st.sessionID = c.SessionID()
+
+	for ctx.Err() == nil {
+		p := probes[r.Intn(len(probes))]
+		stMu.Lock()
+		frame, wantError := p.build(r, st)
+		stMu.Unlock()
+
+		drain(errorsCh)
+		if err := c.SendRaw(frame); err != nil {
+			return fmt.Errorf("probe %s: send: %w (payload %s)", p.name, err, truncate(frame, 200))
+		}
+		rep.sent.Add(1)
+
+		answered := false
+		select {
+		case <-errorsCh:
+			answered = true
+		case <-time.After(cfg.replyTimeout):
+		case <-ctx.Done():
+			return nil
+		}
+		rep.record(p.name, answered)
+		if wantError && !answered {
+			rep.violation("bot=%d seed=%d: probe %s got no structured error within %s (payload %s)",
+				id, seed, p.name, cfg.replyTimeout, truncate(frame, 200))
+		}
+
+		// The connection must survive every probe.
+		drain(pongs)
+		if err := c.Send(map[string]string{"type": client.TypePing}); err != nil {
+			return fmt.Errorf("probe %s: connection lost: %w (payload %s)", p.name, err, truncate(frame, 200))
+		}
+		select {
+		case <-pongs:
+		case <-time.After(cfg.replyTimeout):
+			return fmt.Errorf("probe %s: no pong after probe (payload %s)", p.name, truncate(frame, 200))
+		case <-ctx.Done():
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(cfg.interval):
+		}
+	}
+	return nil
+}
+
+// watchHealth polls /health until ctx is done and reports any failure.
+func watchHealth(ctx context.Context, apiBase string, rep *report) {
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		resp, err := httpClient.Get(apiBase + "/health")
+		if err != nil {
+			if ctx.Err() == nil {
+				rep.violation("health: %v", err)
+			}
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			rep.violation("health: status %d", resp.StatusCode)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Main
+// ---------------------------------------------------------------------------
+
+func main() {
+	wsURL := flag.String("url", "ws://localhost:8080/ws", "WebSocket server URL")
+	apiBase := flag.String("api", "http://localhost:8080", "HTTP API base URL (for /health)")
+	bots := flag.Int("bots", 4, "Number of concurrent fuzzing connections")
+	duration := flag.Duration("duration", time.Minute, "How long to fuzz")
+	seed := flag.Int64("seed", 0, "Random seed (0 = time-based); bot i uses seed+i")
+	interval := flag.Duration("interval", 100*time.Millisecond, "Pause between probes per bot")
+	replyTimeout := flag.Duration("reply-timeout", 3*time.Second, "How long to wait for an error reply or pong")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	fmt.Println("=== Whisper Protocol Fuzz Bot ===")
+	fmt.Printf("Server: %s  bots=%d  duration=%s  seed=%d\n\n", *wsURL, *bots, *duration, *seed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	rep := &report{probes: make(map[string]int), silent: make(map[string]int)}
+	cfg := botConfig{url: *wsURL, interval: *interval, replyTimeout: *replyTimeout}
+
+	go watchHealth(ctx, *apiBase, rep)
+
+	var wg sync.WaitGroup
+	for i := 0; i < *bots; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			runBot(ctx, id, *seed+int64(id), cfg, rep)
+		}(i)
+	}
+	wg.Wait()
+
+	// ---------------------------------------------------------------------------
+	// Summary
+	// ---------------------------------------------------------------------------
+	fmt.Printf("\n%-22s %8s %8s\n", "probe", "sent", "silent")
+	for _, p := range probes {
+		fmt.Printf("%-22s %8d %8d\n", p.name, rep.probes[p.name], rep.silent[p.name])
+	}
+	fmt.Printf("\n=== %d probes, %d violations (seed %d) ===\n", rep.sent.Load(), len(rep.violations), *seed)
+	if len(rep.violations) > 0 {
+		os.Exit(1)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+func mustJSON(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func pick(r *rand.Rand, options ...string) string {
+	return options[r.Intn(len(options))]
+}
+
+func randomID(r *rand.Rand) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", r.Uint32(), r.Intn(1<<16), r.Intn(1<<16), r.Intn(1<<16), r.Int63n(1<<48))
+}
+
+const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789 -_éß日本"
+
+func randomText(r *rand.Rand, n int) string {
+	runes := []rune(alphabet)
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteRune(runes[r.Intn(len(runes))])
+	}
+	return b.String()
+}
+
+func drain[T any](ch chan T) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
+
+func truncate(b []byte, n int) string {
+	if len(b) <= n {
+		return string(b)
+	}
+	return fmt.Sprintf("%s... (%d bytes)", b[:n], len(b))
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/whisper/chat-app/loadtest/client"
+	"github.com/whisper/chat-app/loadtest/stats"
+)
+
+// chaosMaxFrameSize mirrors the server's frame size limit.
+const chaosMaxFrameSize = 4096
+
+// chaosFault is one kind of misbehaviour the chaos test injects into a
+// chatting client (the victim). terminal faults end the victim's connection
+// on purpose; any other fault may still get it disconnected by the server.
+type chaosFault struct {
+	name     string
+	terminal bool
+	inject   func(r *rand.Rand, victim *client.Client, chatID string) error
+}
+
+var chaosFaults = []chaosFault{
+	{"kill", true, func(_ *rand.Rand, victim *client.Client, _ string) error {
+		// Drop the TCP connection without end_chat or a close frame, as a
+		// crashed browser or a lost network would.
+		return victim.Close()
+	}},
+	{"slow_read", false, func(r *rand.Rand, victim *client.Client, _ string) error {
+		victim.DelayReads(500*time.Millisecond + time.Duration(r.Int63n(int64(2500*time.Millisecond))))
+		return nil
+	}},
+	{"malformed_json", false, func(r *rand.Rand, victim *client.Client, chatID string) error {
+		valid, _ := json.Marshal(map[string]string{"type": client.TypeMessage, "chat_id": chatID, "text": "chaos"})
+		return victim.SendRaw(valid[:1+r.Intn(len(valid)-1)])
+	}},
+	{"oversized_frame", false, func(r *rand.Rand, victim *client.Client, chatID string) error {
+		text := strings.Repeat("x", chaosMaxFrameSize+r.Intn(chaosMaxFrameSize))
+		return victim.Send(map[string]string{"type": client.TypeMessage, "chat_id": chatID, "text": text})
+	}},
+	{"out_of_order", false, func(r *rand.Rand, victim *client.Client, chatID string) error {
+		// Protocol messages that are valid on their own but wrong mid-chat.
+		switch r.Intn(4) {
+		case 0:
+			return victim.Send(map[string]string{"type": client.TypeAcceptMatch, "chat_id": chatID})
+		case 1:
+			return victim.Send(map[string]interface{}{"type": client.TypeFindMatch, "interests": []string{}})
+		case 2:
+			return victim.Send(map[string]string{"type": client.TypeDeclineMatch, "chat_id": chatID})
+		default:
+			return victim.Send(map[string]string{"type": client.TypeMessage, "chat_id": fmt.Sprintf("chaos-%08x", r.Uint32()), "text": "hi"})
+		}
+	}},
+}
+
+// chaosOutcome is how the server reacted to a fault within the response
+// window.
+type chaosOutcome int
+
+const (
+	outcomeSilent     chaosOutcome = iota // no reply, connection still open
+	outcomeError                          // error or rate_limited frame
+	outcomeDisconnect                     // server (or the fault) closed the connection
+)
+
+// chaosReport aggregates fault outcomes and partner_left observations
+// across pairs.
+type chaosReport struct {
+	mu       sync.Mutex
+	outcomes map[string]*[3]int // fault name -> count per outcome
+	errCodes map[string]int     // error codes received in reply to faults
+
+	disconnects    atomic.Int64 // victim disconnects that should produce partner_left
+	partnerLeft    atomic.Int64 // partner_left received after a disconnect
+	missingLeft    atomic.Int64 // partner_left not received in time
+	pairsMatched   atomic.Int64
+	pairsCompleted atomic.Int64
+	msgSent        atomic.Int64
+	msgRecv        atomic.Int64
+}
+
+func (rep *chaosReport) record(fault string, outcome chaosOutcome, code string) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	counts, ok := rep.outcomes[fault]
+	if !ok {
+		counts = new([3]int)
+		rep.outcomes[fault] = counts
+	}
+	counts[outcome]++
+	if code != "" {
+		rep.errCodes[fault+"/"+code]++
+	}
+}
+
+// chaosConfig holds the parsed flags shared by every chaos pair.
+type chaosConfig struct {
+	url                string
+	chatDuration       time.Duration
+	msgInterval        time.Duration
+	faultInterval      time.Duration
+	responseWindow     time.Duration
+	partnerLeftTimeout time.Duration
+	matchTimeout       time.Duration
+	faults             []chaosFault
+}
+
+// runChaos implements the chaos test. Pairs of clients match and chat while
+// one side of each pair (the victim) injects faults at random: abrupt
+// disconnects, stalled reads, malformed JSON, oversized frames and
+// out-of-order protocol messages. For every fault it records whether the
+// server answered with an error, disconnected the victim or stayed silent,
+// and for every disconnect it measures how long the partner waits for
+// partner_left. A partner that never hears partner_left points at a cleanup
+// race the happy-path tests miss.
+//
+// Exit code 1 if any partner_left went missing or an -assert-* threshold is
+// violated.
+func runChaos(args []string) {
+	fs := flag.NewFlagSet("chaos", flag.ExitOnError)
+	url := fs.String("url", "ws://localhost:8080/ws", "WebSocket server URL")
+	pairs := fs.Int("pairs", 20, "Number of chatting pairs")
+	chatDuration := fs.Duration("chat-duration", time.Minute, "How long each pair chats unless a fault ends it")
+	msgInterval := fs.Duration("msg-interval", time.Second, "Interval between messages from the partner")
+	faultInterval := fs.Duration("fault-interval", 5*time.Second, "Mean interval between faults per pair (exponentially distributed)")
+	faultList := fs.String("faults", "kill,slow_read,malformed_json,oversized_frame,out_of_order", "Comma-separated faults to inject")
+	responseWindow := fs.Duration("response-window", 2*time.Second, "How long to wait for the server's reaction to a fault")
+	partnerLeftTimeout := fs.Duration("partner-left-timeout", 10*time.Second, "How long the partner waits for partner_left after a disconnect")
+	matchTimeout := fs.Duration("match-timeout", 30*time.Second, "Timeout waiting for match completion")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "Random seed, printed so a run can be replayed")
+	metricsURL := fs.String("metrics-url", "http://localhost:8080/metrics", "Prometheus metrics endpoint URL")
+	scrapeInterval := fs.Duration("scrape-interval", 2*time.Second, "Interval between metrics scrapes")
+	var slo stats.Thresholds
+	slo.RegisterFlags(fs)
+	var out stats.Output
+	out.RegisterFlags(fs, "chaos")
+	fs.Parse(args)
+
+	faults, err := parseChaosFaults(*faultList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cfg := chaosConfig{
+		url:                *url,
+		chatDuration:       *chatDuration,
+		msgInterval:        *msgInterval,
+		faultInterval:      *faultInterval,
+		responseWindow:     *responseWindow,
+		partnerLeftTimeout: *partnerLeftTimeout,
+		matchTimeout:       *matchTimeout,
+		faults:             faults,
+	}
+
+	fmt.Printf("Chaos test: %d pairs to %s (chat=%s, fault-interval=%s, faults=%s, seed=%d)\n",
+		*pairs, *url, *chatDuration, *faultInterval, *faultList, *seed)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	collector := stats.NewCollector()
+	scraper := stats.NewScraper(*metricsURL, *scrapeInterval)
+	collector.SetScraper(scraper)
+	scraper.Start(ctx)
+
+	rep := &chaosReport{outcomes: map[string]*[3]int{}, errCodes: map[string]int{}}
+
+	progressStop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Printf("  [chaos] matched: %d  completed: %d/%d  disconnects: %d  partner_left: %d  missing: %d\n",
+					rep.pairsMatched.Load(), rep.pairsCompleted.Load(), *pairs,
+					rep.disconnects.Load(), rep.partnerLeft.Load(), rep.missingLeft.Load())
+			case <-progressStop:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < *pairs; i++ {
+		r := rand.New(rand.NewSource(*seed + int64(i)))
+		stagger := time.Duration(i) * 100 * time.Millisecond
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer rep.pairsCompleted.Add(1)
+			select {
+			case <-time.After(stagger):
+			case <-ctx.Done():
+				return
+			}
+			runChaosPair(ctx, cfg, i, r, collector, rep)
+		}()
+	}
+	wg.Wait()
+	close(progressStop)
+	scraper.Stop()
+
+	printChaosReport(rep)
+	collector.Report()
+
+	missing := rep.missingLeft.Load()
+	if missing > 0 {
+		fmt.Printf("%d partner(s) never received partner_left after a disconnect (seed=%d)\n\n", missing, *seed)
+	}
+	finishRun("chaos", collector, slo, out, partnerLeftCheck(missing))
+}
+
+// partnerLeftCheck is the chaos run's correctness check: the partner of
+// every disconnected user must have received partner_left. It fails the run,
+// and its results file, like a violated SLO.
+func partnerLeftCheck(missing int64) stats.Result {
+	return stats.Result{
+		SLO:    "missing partner_left",
+		Limit:  "0",
+		Actual: strconv.FormatInt(missing, 10),
+		Pass:   missing == 0,
+	}
+}
+
+// parseChaosFaults resolves a comma-separated list of fault names.
+func parseChaosFaults(list string) ([]chaosFault, error) {
+	var out []chaosFault
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, f := range chaosFaults {
+			if f.name == name {
+				out = append(out, f)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown fault %q", name)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no faults selected")
+	}
+	return out, nil
+}
+
+// runChaosPair connects two clients, matches them and runs the chat while
+// the victim injects faults. It returns once the chat duration is over or
+// the victim has been disconnected and the partner's partner_left is
+// accounted for.
+func runChaosPair(ctx context.Context, cfg chaosConfig, pair int, r *rand.Rand, collector *stats.Collector, rep *chaosReport) {
+	victim, partner := chaosConnect(ctx, cfg.url, collector), chaosConnect(ctx, cfg.url, collector)
+	if victim == nil || partner == nil {
+		if victim != nil {
+			victim.Close()
+		}
+		if partner != nil {
+			partner.Close()
+		}
+		return
+	}
+	defer victim.Close()
+	defer partner.Close()
+
+	// Error frames the victim receives, for attributing them to faults.
+	victimErrs := make(chan string, 16)
+	victim.OnAny(func(msgType string, raw json.RawMessage) {
+		if msgType != client.TypeError && msgType != client.TypeRateLimited {
+			return
+		}
+		var e struct {
+			Code string `json:"code"`
+		}
+		_ = json.Unmarshal(raw, &e)
+		if e.Code == "" {
+			e.Code = msgType
+		}
+		select {
+		case victimErrs <- e.Code:
+		default:
+		}
+	})
+	partnerLeft := make(chan time.Time, 1)
+	partner.On(client.TypePartnerLeft, func(json.RawMessage) {
+		select {
+		case partnerLeft <- time.Now():
+		default:
+		}
+	})
+	partner.On(client.TypeMessage, func(json.RawMessage) { rep.msgRecv.Add(1) })
+	victim.On(client.TypeMessage, func(json.RawMessage) { rep.msgRecv.Add(1) })
+
+	matchStart := time.Now()
+	chatID, err := chaosMatch(ctx, cfg.matchTimeout, fmt.Sprintf("chaos-%d", pair), victim, partner)
+	if err != nil {
+		collector.AddError()
+		return
+	}
+	collector.AddMatchLatency(time.Since(matchStart))
+	rep.pairsMatched.Add(1)
+
+	chatCtx, chatCancel := context.WithTimeout(ctx, cfg.chatDuration)
+	defer chatCancel()
+
+	// The partner keeps talking throughout, so faults hit a busy chat.
+	go func() {
+		ticker := time.NewTicker(cfg.msgInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-chatCtx.Done():
+				return
+			case <-ticker.C:
+				if partner.Send(map[string]string{"type": client.TypeMessage, "chat_id": chatID, "text": "chaos partner"}) != nil {
+					return
+				}
+				rep.msgSent.Add(1)
+			}
+		}
+	}()
+
+	for {
+		wait := time.Duration(r.ExpFloat64() * float64(cfg.faultInterval))
+		select {
+		case <-chatCtx.Done():
+			if ctx.Err() == nil {
+				_ = victim.Send(map[string]string{"type": client.TypeEndChat, "chat_id": chatID})
+			}
+			return
+		case <-victim.Done():
+			// The server dropped the victim between faults.
+			chaosAwaitPartnerLeft(cfg, collector, rep, partnerLeft, time.Now())
+			return
+		case <-time.After(wait):
+		}
+
+		for len(victimErrs) > 0 {
+			<-victimErrs
+		}
+		fault := cfg.faults[r.Intn(len(cfg.faults))]
+		injectedAt := time.Now()
+		if err := fault.inject(r, victim, chatID); err != nil && !fault.terminal {
+			// The connection was already gone; attribute it to the fault.
+			rep.record(fault.name, outcomeDisconnect, "")
+			chaosAwaitPartnerLeft(cfg, collector, rep, partnerLeft, injectedAt)
+			return
+		}
+
+		select {
+		case code := <-victimErrs:
+			rep.record(fault.name, outcomeError, code)
+		case <-victim.Done():
+			rep.record(fault.name, outcomeDisconnect, "")
+			chaosAwaitPartnerLeft(cfg, collector, rep, partnerLeft, injectedAt)
+			return
+		case <-time.After(cfg.responseWindow):
+			rep.record(fault.name, outcomeSilent, "")
+		}
+	}
+}
+
+// chaosAwaitPartnerLeft waits for the partner to be told that the victim,
+// disconnected at since, is gone.
+func chaosAwaitPartnerLeft(cfg chaosConfig, collector *stats.Collector, rep *chaosReport, partnerLeft <-chan time.Time, since time.Time) {
+	rep.disconnects.Add(1)
+	select {
+	case at := <-partnerLeft:
+		rep.partnerLeft.Add(1)
+		collector.AddCleanupLatency(at.Sub(since))
+	case <-time.After(cfg.partnerLeftTimeout):
+		rep.missingLeft.Add(1)
+		collector.AddError()
+	}
+}
+
+// chaosConnect opens a client and waits for its session, returning nil on
+// failure.
+func chaosConnect(ctx context.Context, url string, collector *stats.Collector) *client.Client {
+	connCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	c, err := client.New(connCtx, url)
+	if err != nil {
+		collector.AddError()
+		return nil
+	}
+	if err := c.WaitForSession(connCtx); err != nil {
+		collector.AddError()
+		c.Close()
+		return nil
+	}
+	collector.AddConnect(c.GetMetrics().ConnectLatency)
+	return c
+}
+
+// chaosMatch queues both clients with a pair-specific interest and accepts
+// the proposed match on both sides, returning the chat ID. It fails if the
+// two were matched with other clients instead of each other.
+func chaosMatch(ctx context.Context, timeout time.Duration, interest string, a, b *client.Client) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	sides := []*client.Client{a, b}
+	found := make([]chan string, len(sides))
+	accepted := make([]chan struct{}, len(sides))
+	for i, c := range sides {
+		found[i] = make(chan string, 1)
+		accepted[i] = make(chan struct{}, 1)
+		foundCh, acceptedCh := found[i], accepted[i]
+		c.On(client.TypeMatchFound, func(raw json.RawMessage) {
+			var msg struct {
+				ChatID string `json:"chat_id"`
+			}
+			if json.Unmarshal(raw, &msg) == nil && msg.ChatID != "" {
+				select {
+				case foundCh <- msg.ChatID:
+				default:
+				}
+			}
+		})
+		c.On(client.TypeMatchAccepted, func(json.RawMessage) {
+			select {
+			case acceptedCh <- struct{}{}:
+			default:
+			}
+		})
+	}
+
+	for _, c := range sides {
+		if err := c.Send(map[string]interface{}{"type": client.TypeFindMatch, "interests": []string{interest}}); err != nil {
+			return "", err
+		}
+	}
+
+	ids := make([]string, len(sides))
+	for i, c := range sides {
+		select {
+		case ids[i] = <-found[i]:
+		case <-ctx.Done():
+			return "", fmt.Errorf("timed out waiting for match_found")
+		}
+		if err := c.Send(map[string]string{"type": client.TypeAcceptMatch, "chat_id": ids[i]}); err != nil {
+			return "", err
+		}
+	}
+	if ids[0] != ids[1] {
+		return "", fmt.Errorf("pair matched with other clients")
+	}
+	for i := range sides {
+		select {
+		case <-accepted[i]:
+		case <-ctx.Done():
+			return "", fmt.Errorf("timed out waiting for match_accepted")
+		}
+	}
+	return ids[0], nil
+}
+
+// printChaosReport prints the per-fault outcome table and partner_left
+// accounting.
+func printChaosReport(rep *chaosReport) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	fmt.Println("\n--- Chaos Results ---")
+	fmt.Printf("Pairs matched:     %d\n", rep.pairsMatched.Load())
+	fmt.Printf("Messages sent:     %d\n", rep.msgSent.Load())
+	fmt.Printf("Messages recv:     %d\n", rep.msgRecv.Load())
+
+	names := make([]string, 0, len(rep.outcomes))
+	for name := range rep.outcomes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("\n%-16s %9s %7s %11s %7s\n", "Fault", "injected", "error", "disconnect", "silent")
+	for _, name := range names {
+		c := rep.outcomes[name]
+		fmt.Printf("%-16s %9d %7d %11d %7d\n", name, c[0]+c[1]+c[2], c[outcomeError], c[outcomeDisconnect], c[outcomeSilent])
+	}
+
+	if len(rep.errCodes) > 0 {
+		codes := make([]string, 0, len(rep.errCodes))
+		for code := range rep.errCodes {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		fmt.Println("\nError replies:")
+		for _, code := range codes {
+			fmt.Printf("  %-36s %d\n", code, rep.errCodes[code])
+		}
+	}
+
+	fmt.Printf("\nDisconnects:       %d\n", rep.disconnects.Load())
+	fmt.Printf("partner_left:      %d\n", rep.partnerLeft.Load())
+	fmt.Printf("Missing:           %d\n", rep.missingLeft.Load())
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestPartnerLeftCheck(t *testing.T) {
+	tests := []struct {
+		missing    int64
+		wantPass   bool
+		wantActual string
+	}{
+		{0, true, "0"},
+		{2, false, "2"},
+	}
+	for _, tt := range tests {
+		r := partnerLeftCheck(tt.missing)
+		if r.Pass != tt.wantPass || r.Actual != tt.wantActual {
+			t.Errorf("partnerLeftCheck(%d) = %+v, want Pass=%v Actual=%q", tt.missing, r, tt.wantPass, tt.wantActual)
+		}
+	}
+}
@@ -42,6 +42,10 @@ func runChat(args []string) {
 	matchTimeout := fs.Duration("match-timeout", 30*time.Second, "Timeout waiting for match completion")
 	metricsURL := fs.String("metrics-url", "http://localhost:8080/metrics", "Prometheus metrics endpoint URL")
 	scrapeInterval := fs.Duration("scrape-interval", 2*time.Second, "Interval between metrics scrapes")
+	var slo stats.Thresholds
+	slo.RegisterFlags(fs)
+	var out stats.Output
+	out.RegisterFlags(fs, "chat")
 	fs.Parse(args)
 
 	totalClients := *pairs * 2
@@ -54,6 +58,7 @@ func runChat(args []string) {
 	defer stop()
 
 	collector := stats.NewCollector()
+	defer finishRun("chat", collector, slo, out)
 
 	// Set up metrics scraper.
 	scraper := stats.NewScraper(*metricsURL, *scrapeInterval)
@@ -521,7 +526,7 @@ func runPair(
 	matchLatency := time.Since(matchStart)
 	result.matched = true
 	result.matchLatency = matchLatency
-	collector.AddMsgLatency(matchLatency)
+	collector.AddMatchLatency(matchLatency)
 
 	// --- Phase 3: Chat ---
 
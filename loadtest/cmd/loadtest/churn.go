@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/whisper/chat-app/loadtest/client"
+	"github.com/whisper/chat-app/loadtest/stats"
+)
+
+// churnCounters holds the running totals reported during a churn test.
+type churnCounters struct {
+	opened      atomic.Int64 // connections that completed the handshake
+	closed      atomic.Int64 // connections closed at the end of their lifetime
+	live        atomic.Int64 // connections currently open
+	matching    atomic.Int64 // sessions that entered the matching queue
+	chats       atomic.Int64 // chats accepted (counted once per side)
+	msgSent     atomic.Int64
+	msgRecv     atomic.Int64
+	errors      atomic.Int64
+	skipped     atomic.Int64 // launches dropped because -max-inflight was reached
+	partnerLeft atomic.Int64 // partner_left received
+}
+
+// churnConfig holds the parsed flags shared by every churn session.
+type churnConfig struct {
+	url           string
+	lifetime      time.Duration
+	matchFraction float64
+	msgInterval   time.Duration
+	msgPayload    string
+	matchTimeout  time.Duration
+}
+
+// runChurn implements the connection churn test. Instead of ramping to a
+// fixed number of connections and holding them, it continuously opens new
+// connections at a fixed rate and closes each one after a randomised
+// lifetime, so the server sees a realistic steady state of arrivals and
+// departures. A fraction of sessions enter matching and chat until their
+// lifetime ends, at which point they drop the connection without end_chat.
+//
+// Besides the usual connect latency, the test measures disconnect cleanup
+// latency: the time from one side of a chat dropping its connection to the
+// partner receiving partner_left. After the run, it also measures how long
+// the server takes to drain its connection gauge back to the baseline.
+func runChurn(args []string) {
+	fs := flag.NewFlagSet("churn", flag.ExitOnError)
+	url := fs.String("url", "ws://localhost:8080/ws", "WebSocket server URL")
+	rate := fs.Float64("rate", 200, "New connections per second")
+	lifetime := fs.Duration("lifetime", 90*time.Second, "Mean session lifetime (exponentially distributed)")
+	duration := fs.Duration("duration", 5*time.Minute, "How long to keep generating new connections")
+	matchFraction := fs.Float64("match-fraction", 0.3, "Fraction of sessions that find a match and chat (0-1)")
+	msgInterval := fs.Duration("msg-interval", 3*time.Second, "Interval between messages per chatting user")
+	msgSize := fs.Int("msg-size", 64, "Size of each message payload in bytes")
+	matchTimeout := fs.Duration("match-timeout", 30*time.Second, "Timeout waiting for match completion")
+	maxInflight := fs.Int("max-inflight", 500, "Maximum simultaneous connection attempts; launches beyond this are skipped")
+	drainTimeout := fs.Duration("drain-timeout", 60*time.Second, "How long to wait for the server connection gauge to return to baseline")
+	metricsURL := fs.String("metrics-url", "http://localhost:8080/metrics", "Prometheus metrics endpoint URL")
+	scrapeInterval := fs.Duration("scrape-interval", 5*time.Second, "Interval between metrics scrapes")
+	var slo stats.Thresholds
+	slo.RegisterFlags(fs)
+	var out stats.Output
+	out.RegisterFlags(fs, "churn")
+	fs.Parse(args)
+
+	if *rate <= 0 {
+		fmt.Println("churn: -rate must be positive")
+		return
+	}
+
+	fmt.Printf("Churn test: %.0f conn/s to %s for %s (lifetime=%s, match-fraction=%.2f, msg-interval=%s)\n",
+		*rate, *url, *duration, *lifetime, *matchFraction, *msgInterval)
+	fmt.Printf("Expected steady state: ~%.0f concurrent connections\n", *rate*lifetime.Seconds())
+
+	// Set up signal handling for graceful shutdown.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	collector := stats.NewCollector()
+	defer finishRun("churn", collector, slo, out)
+
+	scraper := stats.NewScraper(*metricsURL, *scrapeInterval)
+	collector.SetScraper(scraper)
+	baseline, baselineErr := scraper.Connections()
+	scraper.Start(ctx)
+
+	msgPayload := strings.Repeat("abcdefgh", (*msgSize/8)+1)
+	cfg := churnConfig{
+		url:           *url,
+		lifetime:      *lifetime,
+		matchFraction: *matchFraction,
+		msgInterval:   *msgInterval,
+		msgPayload:    msgPayload[:*msgSize],
+		matchTimeout:  *matchTimeout,
+	}
+
+	var counters churnCounters
+
+	// closedAt records when a chatting session dropped its connection, keyed
+	// by chat ID, so the partner can compute the cleanup latency when its
+	// partner_left arrives.
+	var closedAt sync.Map
+
+	// sessionCtx is cancelled when generation ends so live sessions close
+	// early instead of waiting out their full lifetime.
+	sessionCtx, cancelSessions := context.WithCancel(ctx)
+	defer cancelSessions()
+
+	// Progress reporting every 5 seconds.
+	progressStop := make(chan struct{})
+	var progressWg sync.WaitGroup
+	progressWg.Add(1)
+	go func() {
+		defer progressWg.Done()
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		lastOpened := int64(0)
+		lastTime := time.Now()
+		for {
+			select {
+			case <-ticker.C:
+				now := time.Now()
+				opened := counters.opened.Load()
+				achieved := float64(opened-lastOpened) / now.Sub(lastTime).Seconds()
+				fmt.Printf("  [churn] live: %d  opened: %d  closed: %d  chats: %d  rate: %.1f conn/s  errors: %d  skipped: %d\n",
+					counters.live.Load(), opened, counters.closed.Load(), counters.chats.Load()/2,
+					achieved, counters.errors.Load(), counters.skipped.Load())
+				lastOpened = opened
+				lastTime = now
+			case <-progressStop:
+				return
+			}
+		}
+	}()
+
+	// -----------------------------------------------------------------------
+	// Generation phase
+	// -----------------------------------------------------------------------
+	fmt.Println("\n--- Generation phase ---")
+
+	sem := make(chan struct{}, *maxInflight)
+	var wg sync.WaitGroup
+
+	interval := time.Duration(float64(time.Second) / *rate)
+	if interval <= 0 {
+		interval = time.Microsecond
+	}
+	genTicker := time.NewTicker(interval)
+	genTimer := time.NewTimer(*duration)
+
+genLoop:
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("\nInterrupted during generation.")
+			break genLoop
+		case <-genTimer.C:
+			break genLoop
+		case <-genTicker.C:
+			select {
+			case sem <- struct{}{}:
+			default:
+				// The server is not keeping up with the connect rate; skip
+				// rather than queue so the offered load stays constant.
+				counters.skipped.Add(1)
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				runChurnSession(sessionCtx, cfg, sem, collector, &counters, &closedAt)
+			}()
+		}
+	}
+	genTicker.Stop()
+	genTimer.Stop()
+
+	// -----------------------------------------------------------------------
+	// Wind-down phase
+	// -----------------------------------------------------------------------
+	fmt.Printf("\n--- Wind-down: closing %d live sessions ---\n", counters.live.Load())
+	cancelSessions()
+	wg.Wait()
+
+	close(progressStop)
+	progressWg.Wait()
+
+	// -----------------------------------------------------------------------
+	// Drain phase — how quickly does the server release all connections?
+	// -----------------------------------------------------------------------
+	if baselineErr == nil {
+		drainStart := time.Now()
+		drained := false
+		var current float64
+		for time.Since(drainStart) < *drainTimeout {
+			var err error
+			current, err = scraper.Connections()
+			if err == nil && current <= baseline {
+				drained = true
+				break
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+		if drained {
+			fmt.Printf("Server drained to baseline (%.0f connections) in %s\n",
+				baseline, time.Since(drainStart).Round(time.Millisecond))
+		} else {
+			fmt.Printf("Server did NOT drain within %s: %.0f connections (baseline %.0f)\n",
+				*drainTimeout, current, baseline)
+		}
+	} else {
+		fmt.Printf("Skipping drain measurement: metrics unavailable (%v)\n", baselineErr)
+	}
+
+	// -----------------------------------------------------------------------
+	// Final report
+	// -----------------------------------------------------------------------
+	fmt.Printf("\n--- Churn Results ---\n")
+	fmt.Printf("Sessions opened:    %d\n", counters.opened.Load())
+	fmt.Printf("Sessions closed:    %d\n", counters.closed.Load())
+	fmt.Printf("Entered matching:   %d\n", counters.matching.Load())
+	fmt.Printf("Chats started:      %d\n", counters.chats.Load()/2)
+	fmt.Printf("Partner left seen:  %d\n", counters.partnerLeft.Load())
+	fmt.Printf("Total msg sent:     %d\n", counters.msgSent.Load())
+	fmt.Printf("Total msg recv:     %d\n", counters.msgRecv.Load())
+	fmt.Printf("Launches skipped:   %d\n", counters.skipped.Load())
+
+	scraper.Stop()
+	collector.Report()
+}
+
+// runChurnSession runs a single churn session: connect, optionally match and
+// chat, then drop the connection when its lifetime expires or ctx is done.
+// The sem slot is released once the handshake finishes so that -max-inflight
+// bounds only pending connection attempts.
+func runChurnSession(
+	ctx context.Context,
+	cfg churnConfig,
+	sem chan struct{},
+	collector *stats.Collector,
+	counters *churnCounters,
+	closedAt *sync.Map,
+) {
+	connCtx, connCancel := context.WithTimeout(ctx, 10*time.Second)
+	c, err := client.New(connCtx, cfg.url)
+	if err == nil {
+		err = c.WaitForSession(connCtx)
+		if err != nil {
+			c.Close()
+		}
+	}
+	connCancel()
+	<-sem
+	if err != nil {
+		if ctx.Err() == nil {
+			counters.errors.Add(1)
+			collector.AddError()
+		}
+		return
+	}
+
+	collector.AddConnect(c.GetMetrics().ConnectLatency)
+	counters.opened.Add(1)
+	counters.live.Add(1)
+	defer counters.live.Add(-1)
+
+	// Exponentially distributed lifetime, capped to avoid extreme outliers.
+	life := time.Duration(rand.ExpFloat64() * float64(cfg.lifetime))
+	if limit := 5 * cfg.lifetime; life > limit {
+		life = limit
+	}
+	lifeCtx, lifeCancel := context.WithTimeout(ctx, life)
+	defer lifeCancel()
+
+	var chatID atomic.Value // string; set once the chat is accepted
+	chatID.Store("")
+
+	if rand.Float64() < cfg.matchFraction {
+		runChurnChat(lifeCtx, cfg, c, collector, counters, closedAt, &chatID)
+	}
+
+	<-lifeCtx.Done()
+
+	// Abrupt disconnect, as when a user closes the tab mid-chat.
+	if id := chatID.Load().(string); id != "" {
+		closedAt.Store(id, time.Now())
+	}
+	c.Close()
+	counters.closed.Add(1)
+}
+
+// runChurnChat enters matching and, once matched, exchanges messages until
+// ctx is done. When the partner disconnects first, the session re-enters
+// matching for the rest of its lifetime.
+func runChurnChat(
+	ctx context.Context,
+	cfg churnConfig,
+	c *client.Client,
+	collector *stats.Collector,
+	counters *churnCounters,
+	closedAt *sync.Map,
+	chatID *atomic.Value,
+) {
+	matchFound := make(chan string, 1)
+	accepted := make(chan struct{}, 1)
+	partnerLeft := make(chan struct{}, 1)
+	requeue := make(chan struct{}, 1)
+
+	c.On(client.TypeMatchFound, func(raw json.RawMessage) {
+		var msg struct {
+			ChatID string `json:"chat_id"`
+		}
+		if err := json.Unmarshal(raw, &msg); err == nil && msg.ChatID != "" {
+			select {
+			case matchFound <- msg.ChatID:
+			default:
+			}
+		}
+	})
+	c.On(client.TypeMatchAccepted, func(raw json.RawMessage) {
+		select {
+		case accepted <- struct{}{}:
+		default:
+		}
+	})
+	c.On(client.TypeMatchTimeout, func(raw json.RawMessage) {
+		select {
+		case requeue <- struct{}{}:
+		default:
+		}
+	})
+	c.On(client.TypeMatchDeclined, func(raw json.RawMessage) {
+		select {
+		case requeue <- struct{}{}:
+		default:
+		}
+	})
+	c.On(client.TypeMessage, func(raw json.RawMessage) {
+		counters.msgRecv.Add(1)
+	})
+	c.On(client.TypePartnerLeft, func(raw json.RawMessage) {
+		counters.partnerLeft.Add(1)
+		if id := chatID.Load().(string); id != "" {
+			if v, ok := closedAt.LoadAndDelete(id); ok {
+				collector.AddCleanupLatency(time.Since(v.(time.Time)))
+			}
+		}
+		select {
+		case partnerLeft <- struct{}{}:
+		default:
+		}
+	})
+
+	for ctx.Err() == nil {
+		counters.matching.Add(1)
+		if err := c.Send(map[string]interface{}{
+			"type":      client.TypeFindMatch,
+			"interests": []string{},
+		}); err != nil {
+			counters.errors.Add(1)
+			collector.AddError()
+			return
+		}
+
+		// Wait for a proposal, accept it, and wait for the partner to accept.
+		matchCtx, matchCancel := context.WithTimeout(ctx, cfg.matchTimeout)
+		var id string
+		select {
+		case id = <-matchFound:
+		case <-requeue:
+			matchCancel()
+			continue
+		case <-matchCtx.Done():
+			matchCancel()
+			continue
+		}
+
+		if err := c.Send(map[string]string{
+			"type":    client.TypeAcceptMatch,
+			"chat_id": id,
+		}); err != nil {
+			matchCancel()
+			counters.errors.Add(1)
+			collector.AddError()
+			return
+		}
+
+		select {
+		case <-accepted:
+		case <-requeue:
+			matchCancel()
+			continue
+		case <-matchCtx.Done():
+			matchCancel()
+			continue
+		}
+		matchCancel()
+
+		chatID.Store(id)
+		counters.chats.Add(1)
+
+		// Chat until the lifetime ends or the partner leaves.
+		ticker := time.NewTicker(cfg.msgInterval)
+	chatLoop:
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			case <-partnerLeft:
+				break chatLoop
+			case <-ticker.C:
+				if err := c.Send(map[string]string{
+					"type":    client.TypeMessage,
+					"chat_id": id,
+					"text":    cfg.msgPayload,
+				}); err != nil {
+					ticker.Stop()
+					counters.errors.Add(1)
+					collector.AddError()
+					return
+				}
+				counters.msgSent.Add(1)
+			}
+		}
+		ticker.Stop()
+		chatID.Store("")
+	}
+}
@@ -4,6 +4,8 @@
 //   - saturate: Connection saturation test (LOAD-2)
 //   - match:    Matching flow load test (LOAD-3)
 //   - chat:     Full chat lifecycle load test (LOAD-4)
+//   - churn:    Continuous connect/disconnect churn test
+//   - chaos:    Fault injection into live chats
 //
 // Usage:
 //
@@ -13,6 +15,8 @@ package main
 import (
 	"fmt"
 	"os"
+
+	"github.com/whisper/chat-app/loadtest/stats"
 )
 
 func main() {
@@ -28,6 +32,10 @@ func main() {
 		runMatch(os.Args[2:])
 	case "chat":
 		runChat(os.Args[2:])
+	case "churn":
+		runChurn(os.Args[2:])
+	case "chaos":
+		runChaos(os.Args[2:])
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -44,6 +52,43 @@ func printUsage() {
 	fmt.Println("  saturate    Connection saturation test — opens N idle connections")
 	fmt.Println("  match       Matching flow load test — pairs of users find and accept matches")
 	fmt.Println("  chat        Full chat lifecycle load test — connect, match, exchange messages, end")
+	fmt.Println("  churn       Connection churn test — steady connects/disconnects with random lifetimes")
+	fmt.Println("  chaos       Fault injection — kills, stalls and malformed frames in live chats")
 	fmt.Println()
 	fmt.Println("Run 'loadtest <command> -h' for command-specific options.")
+	fmt.Println()
+	fmt.Println("Every command accepts -assert-* and -fail-if SLO thresholds (e.g. -assert-p95-msg=250ms")
+	fmt.Println("-fail-if 'p99_connect>500ms,error_rate>1%') and exits with status 1 if any is")
+	fmt.Println("violated. -output json|csv also writes the results to -output-file.")
+}
+
+// finishRun evaluates the -assert-* and -fail-if thresholds once the final
+// report has been printed and writes the -output results file. checks are
+// the command's own pass/fail results, reported and gated with the
+// thresholds. It exits with status 1 if any threshold or check fails or the
+// file cannot be written, so the load test can be used as an automated
+// performance gate.
+func finishRun(command string, collector *stats.Collector, slo stats.Thresholds, out stats.Output, checks ...stats.Result) {
+	var results []stats.Result
+	if slo.Enabled() {
+		results = collector.Check(slo)
+	}
+	results = append(results, checks...)
+
+	failed := false
+	if out.Enabled() {
+		path, err := out.WriteSummary(collector.Summary(command, results))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Writing results: %v\n", err)
+			failed = true
+		} else {
+			fmt.Printf("Results written to %s\n\n", path)
+		}
+	}
+	if stats.ReportAssertions(results) > 0 {
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
 }
@@ -30,6 +30,10 @@ func runMatch(args []string) {
 	concurrency := fs.Int("concurrency", 50, "Maximum simultaneous connection attempts during ramp-up")
 	metricsURL := fs.String("metrics-url", "http://localhost:8080/metrics", "Prometheus metrics endpoint URL")
 	scrapeInterval := fs.Duration("scrape-interval", 2*time.Second, "Interval between metrics scrapes")
+	var slo stats.Thresholds
+	slo.RegisterFlags(fs)
+	var out stats.Output
+	out.RegisterFlags(fs, "match")
 	fs.Parse(args)
 
 	totalClients := *pairs * 2
@@ -56,6 +60,7 @@ func runMatch(args []string) {
 	defer stop()
 
 	collector := stats.NewCollector()
+	defer finishRun("match", collector, slo, out)
 
 	// Set up metrics scraper.
 	scraper := stats.NewScraper(*metricsURL, *scrapeInterval)
@@ -209,7 +214,7 @@ func runMatch(args []string) {
 		// Register match_found handler.
 		c.On(client.TypeMatchFound, func(raw json.RawMessage) {
 			latency := time.Since(matchStart)
-			collector.AddMsgLatency(latency)
+			collector.AddMatchLatency(latency)
 			matchedCount.Add(1)
 
 			// Extract chat_id and send accept_match.
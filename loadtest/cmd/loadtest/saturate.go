@@ -26,6 +26,10 @@ func runSaturate(args []string) {
 	rampUp := fs.Duration("ramp", 10*time.Second, "Ramp-up duration")
 	hold := fs.Duration("hold", 30*time.Second, "Hold duration after all connections are open")
 	concurrency := fs.Int("concurrency", 50, "Maximum simultaneous connection attempts during ramp-up")
+	var slo stats.Thresholds
+	slo.RegisterFlags(fs)
+	var out stats.Output
+	out.RegisterFlags(fs, "saturate")
 	fs.Parse(args)
 
 	fmt.Printf("Saturate test: %d connections to %s (ramp=%s, hold=%s, concurrency=%d)\n",
@@ -36,6 +40,7 @@ func runSaturate(args []string) {
 	defer stop()
 
 	collector := stats.NewCollector()
+	defer finishRun("saturate", collector, slo, out)
 
 	// Slice to track all open connections for cleanup.
 	var mu sync.Mutex
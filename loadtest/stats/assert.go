@@ -0,0 +1,299 @@
+package stats
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Thresholds are service level objectives evaluated against a Collector at
+// the end of a run. Zero values disable the corresponding check; FailIf adds
+// free-form conditions on any reported metric.
+type Thresholds struct {
+	ConnectP95 time.Duration
+	ConnectP99 time.Duration
+	MsgP95     time.Duration
+	MsgP99     time.Duration
+	MatchP95   time.Duration
+	MatchP99   time.Duration
+	CleanupP95 time.Duration
+	ErrorRate  Rate
+	FailIf     Conditions
+}
+
+// RegisterFlags adds the -assert-* flags to fs, storing their values in t.
+func (t *Thresholds) RegisterFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&t.ConnectP95, "assert-p95-connect", 0, "Fail if p95 connect latency exceeds this (0 = off)")
+	fs.DurationVar(&t.ConnectP99, "assert-p99-connect", 0, "Fail if p99 connect latency exceeds this (0 = off)")
+	fs.DurationVar(&t.MsgP95, "assert-p95-msg", 0, "Fail if p95 message latency exceeds this (0 = off)")
+	fs.DurationVar(&t.MsgP99, "assert-p99-msg", 0, "Fail if p99 message latency exceeds this (0 = off)")
+	fs.DurationVar(&t.MatchP95, "assert-p95-match", 0, "Fail if p95 match latency exceeds this (0 = off)")
+	fs.DurationVar(&t.MatchP99, "assert-p99-match", 0, "Fail if p99 match latency exceeds this (0 = off)")
+	fs.DurationVar(&t.CleanupP95, "assert-p95-cleanup", 0, "Fail if p95 disconnect cleanup latency exceeds this (0 = off)")
+	fs.Var(&t.ErrorRate, "assert-error-rate", "Fail if the error rate exceeds this, e.g. 1% or 0.01 (empty = off)")
+	fs.Var(&t.FailIf, "fail-if", "Fail if a metric exceeds a limit, e.g. p99_connect>500ms or error_rate>1% (repeatable, comma-separated)")
+}
+
+// Enabled reports whether any threshold is set.
+func (t Thresholds) Enabled() bool {
+	return t.ConnectP95 > 0 || t.ConnectP99 > 0 || t.MsgP95 > 0 || t.MsgP99 > 0 ||
+		t.MatchP95 > 0 || t.MatchP99 > 0 || t.CleanupP95 > 0 ||
+		t.ErrorRate.IsSet() || len(t.FailIf) > 0
+}
+
+// Rate is a fraction parsed from either a percentage ("1%") or a plain
+// number ("0.01"). It implements flag.Value.
+type Rate struct {
+	Value float64
+	set   bool
+}
+
+// IsSet reports whether the rate was given on the command line.
+func (r Rate) IsSet() bool {
+	return r.set
+}
+
+// String formats the rate as a percentage.
+func (r *Rate) String() string {
+	if r == nil || !r.set {
+		return ""
+	}
+	return strconv.FormatFloat(r.Value*100, 'f', -1, 64) + "%"
+}
+
+// Set parses "1%", "0.5%" or "0.01".
+func (r *Rate) Set(s string) error {
+	s = strings.TrimSpace(s)
+	pct := strings.HasSuffix(s, "%")
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return fmt.Errorf("invalid rate %q", s)
+	}
+	if pct {
+		v /= 100
+	}
+	if v < 0 || v > 1 {
+		return fmt.Errorf("rate %q out of range", s)
+	}
+	r.Value, r.set = v, true
+	return nil
+}
+
+// Condition is one -fail-if check: the run fails if Metric exceeds Limit.
+// Metric is error_rate, errors or a latency statistic named <stat>_<kind>,
+// where stat is avg, p50, p95, p99 or max and kind is connect, msg, match or
+// cleanup. Limit is in seconds for latencies, a fraction for error_rate and
+// a count for errors.
+type Condition struct {
+	Metric string
+	Limit  float64
+	limit  string // as given, for reporting
+}
+
+// String formats the condition as it is written on the command line.
+func (c Condition) String() string {
+	return c.Metric + ">" + c.limit
+}
+
+// ParseCondition parses "<metric>><limit>", e.g. "p99_connect>500ms" or
+// "error_rate>1%".
+func ParseCondition(s string) (Condition, error) {
+	metric, limit, ok := strings.Cut(s, ">")
+	metric, limit = strings.TrimSpace(metric), strings.TrimSpace(limit)
+	if !ok || metric == "" || limit == "" {
+		return Condition{}, fmt.Errorf("invalid condition %q (want <metric>><limit>)", s)
+	}
+	c := Condition{Metric: metric, limit: limit}
+	switch {
+	case metric == "error_rate":
+		var r Rate
+		if err := r.Set(limit); err != nil {
+			return Condition{}, err
+		}
+		c.Limit = r.Value
+	case metric == "errors":
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return Condition{}, fmt.Errorf("invalid count %q in %q", limit, s)
+		}
+		c.Limit = float64(n)
+	default:
+		if _, _, ok := latencyMetric(metric); !ok {
+			return Condition{}, fmt.Errorf("unknown metric %q in %q", metric, s)
+		}
+		d, err := time.ParseDuration(limit)
+		if err != nil || d <= 0 {
+			return Condition{}, fmt.Errorf("invalid duration %q in %q", limit, s)
+		}
+		c.Limit = d.Seconds()
+	}
+	return c, nil
+}
+
+// latencyMetric splits a latency metric name such as "p99_connect" into its
+// statistic and the sample kind it applies to.
+func latencyMetric(name string) (stat, kind string, ok bool) {
+	stat, kind, ok = strings.Cut(name, "_")
+	if !ok {
+		return "", "", false
+	}
+	switch stat {
+	case "avg", "p50", "p95", "p99", "max":
+	default:
+		return "", "", false
+	}
+	switch kind {
+	case "connect", "msg", "match", "cleanup":
+		return stat, kind, true
+	}
+	return "", "", false
+}
+
+// Conditions is the list of -fail-if checks. It implements flag.Value; the
+// flag may be repeated and each value may hold several comma-separated
+// conditions.
+type Conditions []Condition
+
+// String joins the conditions with commas.
+func (cs *Conditions) String() string {
+	if cs == nil {
+		return ""
+	}
+	parts := make([]string, len(*cs))
+	for i, c := range *cs {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses and appends one or more comma-separated conditions.
+func (cs *Conditions) Set(s string) error {
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		c, err := ParseCondition(part)
+		if err != nil {
+			return err
+		}
+		*cs = append(*cs, c)
+	}
+	return nil
+}
+
+// Result is the outcome of one SLO check.
+type Result struct {
+	SLO    string `json:"slo"` // e.g. "p95 message latency"
+	Limit  string `json:"limit"`
+	Actual string `json:"actual"`
+	Pass   bool   `json:"pass"`
+}
+
+// Check evaluates t against the collected stats and returns one Result per
+// enabled threshold. A latency threshold with no samples fails, since a gate
+// that measured nothing must not pass.
+func (c *Collector) Check(t Thresholds) []Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var results []Result
+	latency := func(name string, samples []time.Duration, p float64, limit time.Duration) {
+		if limit <= 0 {
+			return
+		}
+		r := Result{
+			SLO:   fmt.Sprintf("p%d %s latency", int(p*100), name),
+			Limit: limit.String(),
+		}
+		if len(samples) == 0 {
+			r.Actual = "no samples"
+		} else {
+			sorted := make([]time.Duration, len(samples))
+			copy(sorted, samples)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			actual := percentile(sorted, p)
+			r.Actual = actual.Round(time.Microsecond).String()
+			r.Pass = actual <= limit
+		}
+		results = append(results, r)
+	}
+
+	latency("connect", c.connectLatencies, 0.95, t.ConnectP95)
+	latency("connect", c.connectLatencies, 0.99, t.ConnectP99)
+	latency("message", c.msgLatencies, 0.95, t.MsgP95)
+	latency("message", c.msgLatencies, 0.99, t.MsgP99)
+	latency("match", c.matchLatencies, 0.95, t.MatchP95)
+	latency("match", c.matchLatencies, 0.99, t.MatchP99)
+	latency("cleanup", c.cleanupLatencies, 0.95, t.CleanupP95)
+
+	if t.ErrorRate.IsSet() {
+		rate := c.errorRate()
+		results = append(results, Result{
+			SLO:    "error rate",
+			Limit:  t.ErrorRate.String(),
+			Actual: strconv.FormatFloat(rate*100, 'f', 2, 64) + "%",
+			Pass:   rate <= t.ErrorRate.Value,
+		})
+	}
+
+	for _, cond := range t.FailIf {
+		results = append(results, c.checkCondition(cond))
+	}
+	return results
+}
+
+// checkCondition evaluates one -fail-if condition. As with the -assert-*
+// latency flags, a latency condition with no samples fails. The caller must
+// hold c.mu.
+func (c *Collector) checkCondition(cond Condition) Result {
+	r := Result{SLO: cond.String(), Limit: cond.limit}
+	switch cond.Metric {
+	case "error_rate":
+		rate := c.errorRate()
+		r.Actual = strconv.FormatFloat(rate*100, 'f', 2, 64) + "%"
+		r.Pass = rate <= cond.Limit
+		return r
+	case "errors":
+		r.Actual = strconv.Itoa(c.errors)
+		r.Pass = float64(c.errors) <= cond.Limit
+		return r
+	}
+
+	stat, kind, _ := latencyMetric(cond.Metric)
+	samples := c.samples(kind)
+	if len(samples) == 0 {
+		r.Actual = "no samples"
+		return r
+	}
+	actual := summarize(samples).stat(stat)
+	r.Actual = actual.Round(time.Microsecond).String()
+	r.Pass = actual.Seconds() <= cond.Limit
+	return r
+}
+
+// ReportAssertions prints the SLO results and returns the number of
+// violations.
+func ReportAssertions(results []Result) int {
+	if len(results) == 0 {
+		return 0
+	}
+	failed := 0
+	fmt.Println("=== SLO Assertions ===")
+	for _, r := range results {
+		status := "PASS"
+		if !r.Pass {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("  [%s] %-24s limit: %-10s actual: %s\n", status, r.SLO, r.Limit, r.Actual)
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d SLO assertions failed\n\n", failed, len(results))
+	} else {
+		fmt.Printf("All %d SLO assertions passed\n\n", len(results))
+	}
+	return failed
+}
@@ -0,0 +1,231 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Result file formats accepted by -output.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Output selects the structured results file written at the end of a run,
+// in addition to the text report printed to stdout.
+type Output struct {
+	Format  string
+	Path    string
+	command string
+}
+
+// RegisterFlags adds -output and -output-file to fs, storing their values in
+// o. command names the default results file.
+func (o *Output) RegisterFlags(fs *flag.FlagSet, command string) {
+	o.command = command
+	o.Format = FormatText
+	fs.Func("output", "Also write results to a file as json or csv (text = stdout report only)", func(s string) error {
+		switch s {
+		case FormatText, FormatJSON, FormatCSV:
+			o.Format = s
+			return nil
+		}
+		return fmt.Errorf("unknown format %q (want text, json or csv)", s)
+	})
+	fs.StringVar(&o.Path, "output-file", "", "Results file for -output (default <command>-results.<format>)")
+}
+
+// Enabled reports whether a results file is to be written.
+func (o Output) Enabled() bool {
+	return o.Format != "" && o.Format != FormatText
+}
+
+// path returns the results file, defaulting to <command>-results.<format>.
+func (o Output) path() string {
+	if o.Path != "" {
+		return o.Path
+	}
+	return o.command + "-results." + o.Format
+}
+
+// Summary is the structured result of a run. Latencies are keyed by sample
+// kind (connect, msg, match, cleanup) and hold only kinds with samples.
+type Summary struct {
+	Command         string                    `json:"command"`
+	StartedAt       time.Time                 `json:"started_at"`
+	DurationSeconds float64                   `json:"duration_seconds"`
+	Connections     int                       `json:"connections"`
+	Errors          int                       `json:"errors"`
+	ErrorRate       float64                   `json:"error_rate"`
+	Latencies       map[string]LatencySummary `json:"latencies"`
+	Server          *ServerSummary            `json:"server,omitempty"`
+	Assertions      []Result                  `json:"assertions,omitempty"`
+	Passed          bool                      `json:"passed"`
+}
+
+// LatencySummary is the distribution of one kind of latency sample, in
+// milliseconds.
+type LatencySummary struct {
+	Count int     `json:"count"`
+	AvgMs float64 `json:"avg_ms"`
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+// Summary returns the structured form of Report, including server metric
+// deltas if a scraper is attached, and the given SLO results.
+func (c *Collector) Summary(command string, results []Result) Summary {
+	c.mu.Lock()
+	sum := Summary{
+		Command:         command,
+		StartedAt:       c.startTime,
+		DurationSeconds: time.Since(c.startTime).Seconds(),
+		Connections:     c.connections,
+		Errors:          c.errors,
+		ErrorRate:       c.errorRate(),
+		Latencies:       map[string]LatencySummary{},
+		Assertions:      results,
+		Passed:          true,
+	}
+	for _, kind := range []string{"connect", "msg", "match", "cleanup"} {
+		samples := c.samples(kind)
+		if len(samples) == 0 {
+			continue
+		}
+		s := summarize(samples)
+		sum.Latencies[kind] = LatencySummary{
+			Count: s.n,
+			AvgMs: millis(s.avg),
+			P50Ms: millis(s.p50),
+			P95Ms: millis(s.p95),
+			P99Ms: millis(s.p99),
+			MaxMs: millis(s.max),
+		}
+	}
+	scraper := c.scraper
+	c.mu.Unlock()
+
+	if scraper != nil {
+		sum.Server = scraper.Summary()
+	}
+	for _, r := range results {
+		if !r.Pass {
+			sum.Passed = false
+		}
+	}
+	return sum
+}
+
+// millis converts d to fractional milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// WriteSummary writes sum to the results file selected by o and returns its
+// path. It does nothing and returns "" when o is not enabled.
+func (o Output) WriteSummary(sum Summary) (string, error) {
+	if !o.Enabled() {
+		return "", nil
+	}
+	path := o.path()
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if o.Format == FormatJSON {
+		err = writeJSON(f, sum)
+	} else {
+		err = writeCSV(f, sum)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// writeJSON writes sum as an indented JSON document.
+func writeJSON(w io.Writer, sum Summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(sum)
+}
+
+// writeCSV writes sum as rows of section,name,field,value, one value per
+// row, so results from many runs can be concatenated and filtered.
+func writeCSV(w io.Writer, sum Summary) error {
+	cw := csv.NewWriter(w)
+	row := func(section, name, field, value string) {
+		_ = cw.Write([]string{section, name, field, value})
+	}
+	num := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	row("section", "name", "field", "value")
+	row("run", sum.Command, "started_at", sum.StartedAt.UTC().Format(time.RFC3339))
+	row("run", sum.Command, "duration_seconds", num(sum.DurationSeconds))
+	row("run", sum.Command, "connections", strconv.Itoa(sum.Connections))
+	row("run", sum.Command, "errors", strconv.Itoa(sum.Errors))
+	row("run", sum.Command, "error_rate", num(sum.ErrorRate))
+	row("run", sum.Command, "passed", strconv.FormatBool(sum.Passed))
+
+	for _, kind := range sortedKeys(sum.Latencies) {
+		l := sum.Latencies[kind]
+		row("latency", kind, "count", strconv.Itoa(l.Count))
+		row("latency", kind, "avg_ms", num(l.AvgMs))
+		row("latency", kind, "p50_ms", num(l.P50Ms))
+		row("latency", kind, "p95_ms", num(l.P95Ms))
+		row("latency", kind, "p99_ms", num(l.P99Ms))
+		row("latency", kind, "max_ms", num(l.MaxMs))
+	}
+
+	if srv := sum.Server; srv != nil {
+		row("server", "scrape", "snapshots", strconv.Itoa(srv.Snapshots))
+		row("server", "scrape", "window_seconds", num(srv.WindowSeconds))
+		for _, name := range sortedKeys(srv.Metrics) {
+			m := srv.Metrics[name]
+			row("server", name, "initial", num(m.Initial))
+			row("server", name, "final", num(m.Final))
+			row("server", name, "delta", num(m.Delta))
+			row("server", name, "peak", num(m.Peak))
+		}
+		for _, name := range sortedKeys(srv.Histograms) {
+			h := srv.Histograms[name]
+			row("server", name, "avg_seconds", num(h.AvgSeconds))
+			row("server", name, "observations", num(h.Observations))
+		}
+	}
+
+	for _, r := range sum.Assertions {
+		row("assertion", r.SLO, "limit", r.Limit)
+		row("assertion", r.SLO, "actual", r.Actual)
+		row("assertion", r.SLO, "pass", strconv.FormatBool(r.Pass))
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+package stats
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSummary_Passed(t *testing.T) {
+	pass := Result{SLO: "p95 message latency", Limit: "250ms", Actual: "40ms", Pass: true}
+	fail := Result{SLO: "missing partner_left", Limit: "0", Actual: "2", Pass: false}
+
+	tests := []struct {
+		name    string
+		results []Result
+		want    bool
+	}{
+		{"no results", nil, true},
+		{"all pass", []Result{pass}, true},
+		{"one fails", []Result{pass, fail}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum := NewCollector().Summary("chaos", tt.results)
+			if sum.Passed != tt.want {
+				t.Errorf("Passed = %v, want %v", sum.Passed, tt.want)
+			}
+			if len(sum.Assertions) != len(tt.results) {
+				t.Errorf("Assertions = %d, want %d", len(sum.Assertions), len(tt.results))
+			}
+		})
+	}
+}
+
+func TestWriteSummary_JSONRecordsFailure(t *testing.T) {
+	out := Output{Format: FormatJSON, Path: filepath.Join(t.TempDir(), "results.json")}
+	sum := NewCollector().Summary("chaos", []Result{{SLO: "missing partner_left", Limit: "0", Actual: "1"}})
+
+	path, err := out.WriteSummary(sum)
+	if err != nil {
+		t.Fatalf("WriteSummary: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read results: %v", err)
+	}
+	var got Summary
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode results: %v", err)
+	}
+	if got.Passed {
+		t.Error("results file reports passed for a failed check")
+	}
+	if len(got.Assertions) != 1 || got.Assertions[0].SLO != "missing partner_left" {
+		t.Errorf("Assertions = %+v, want the partner_left check", got.Assertions)
+	}
+}
@@ -92,6 +92,16 @@ func (s *Scraper) Stop() {
 	}
 }
 
+// Connections fetches the metrics endpoint once and returns the current
+// whisper_connections_total value without recording a snapshot.
+func (s *Scraper) Connections() (float64, error) {
+	snap, err := s.fetch()
+	if err != nil {
+		return 0, err
+	}
+	return snap.connections, nil
+}
+
 // scrapeOnce fetches the metrics endpoint and records a snapshot.
 func (s *Scraper) scrapeOnce() {
 	snap, err := s.fetch()
@@ -141,9 +151,10 @@ func (s *Scraper) fetch() (metricSnapshot, error) {
 		case "whisper_match_queue_size":
 			snap.queueSize = value
 		case "whisper_message_latency_seconds_sum":
-			snap.latencySum = value
+			// One line per delivery path; sum them like the counter above.
+			snap.latencySum += value
 		case "whisper_message_latency_seconds_count":
-			snap.latencyCount = value
+			snap.latencyCount += value
 		case "whisper_match_duration_seconds_sum":
 			snap.matchSum = value
 		case "whisper_match_duration_seconds_count":
@@ -274,3 +285,68 @@ func peakValue(snaps []metricSnapshot, extract func(metricSnapshot) float64) flo
 	}
 	return peak
 }
+
+// ServerSummary is the structured form of the scraper report.
+type ServerSummary struct {
+	Snapshots     int                     `json:"snapshots"`
+	WindowSeconds float64                 `json:"window_seconds"`
+	Metrics       map[string]MetricDelta  `json:"metrics"`
+	Histograms    map[string]HistogramAvg `json:"histograms"`
+}
+
+// MetricDelta is how a server metric moved over the run.
+type MetricDelta struct {
+	Initial float64 `json:"initial"`
+	Final   float64 `json:"final"`
+	Delta   float64 `json:"delta"`
+	Peak    float64 `json:"peak"`
+}
+
+// HistogramAvg is the average of a server histogram over the run, computed
+// from its _sum and _count deltas.
+type HistogramAvg struct {
+	AvgSeconds   float64 `json:"avg_seconds"`
+	Observations float64 `json:"observations"`
+}
+
+// Summary returns the metrics Report prints, or nil if nothing was scraped.
+// Histograms without observations during the run are omitted.
+func (s *Scraper) Summary() *ServerSummary {
+	s.mu.Lock()
+	snaps := make([]metricSnapshot, len(s.snapshots))
+	copy(snaps, s.snapshots)
+	s.mu.Unlock()
+
+	if len(snaps) == 0 {
+		return nil
+	}
+	first := snaps[0]
+	last := snaps[len(snaps)-1]
+
+	delta := func(extract func(metricSnapshot) float64) MetricDelta {
+		return MetricDelta{
+			Initial: extract(first),
+			Final:   extract(last),
+			Delta:   extract(last) - extract(first),
+			Peak:    peakValue(snaps, extract),
+		}
+	}
+	sum := &ServerSummary{
+		Snapshots:     len(snaps),
+		WindowSeconds: last.timestamp.Sub(first.timestamp).Seconds(),
+		Metrics: map[string]MetricDelta{
+			"connections":    delta(func(s metricSnapshot) float64 { return s.connections }),
+			"active_chats":   delta(func(s metricSnapshot) float64 { return s.activeChats }),
+			"queue_size":     delta(func(s metricSnapshot) float64 { return s.queueSize }),
+			"messages_total": delta(func(s metricSnapshot) float64 { return s.messagesTotal }),
+		},
+		Histograms: map[string]HistogramAvg{},
+	}
+	if n := last.latencyCount - first.latencyCount; n > 0 {
+		sum.Histograms["msg_latency"] = HistogramAvg{(last.latencySum - first.latencySum) / n, n}
+	}
+	if n := last.matchCount - first.matchCount; n > 0 {
+		sum.Histograms["match_duration"] = HistogramAvg{(last.matchSum - first.matchSum) / n, n}
+	}
+	return sum
+}
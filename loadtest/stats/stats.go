@@ -1,6 +1,6 @@
 // Package stats provides a goroutine-safe metrics collector that aggregates
 // performance data from multiple load test clients and prints a summary report
-// with percentile distributions.
+// with percentile distributions, optionally also written as JSON or CSV.
 package stats
 
 import (
@@ -18,6 +18,8 @@ type Collector struct {
 	mu               sync.Mutex
 	connectLatencies []time.Duration
 	msgLatencies     []time.Duration
+	matchLatencies   []time.Duration
+	cleanupLatencies []time.Duration
 	errors           int
 	connections      int
 	startTime        time.Time
@@ -52,6 +54,21 @@ func (c *Collector) AddMsgLatency(d time.Duration) {
 	c.mu.Unlock()
 }
 
+// AddMatchLatency records the time from find_match to match_found.
+func (c *Collector) AddMatchLatency(d time.Duration) {
+	c.mu.Lock()
+	c.matchLatencies = append(c.matchLatencies, d)
+	c.mu.Unlock()
+}
+
+// AddCleanupLatency records how long the server took to notify a chat partner
+// (partner_left) after the other side disconnected.
+func (c *Collector) AddCleanupLatency(d time.Duration) {
+	c.mu.Lock()
+	c.cleanupLatencies = append(c.cleanupLatencies, d)
+	c.mu.Unlock()
+}
+
 // AddError increments the error counter.
 func (c *Collector) AddError() {
 	c.mu.Lock()
@@ -88,8 +105,7 @@ func (c *Collector) Report() {
 	fmt.Printf("Errors:       %d\n", c.errors)
 
 	if c.connections > 0 {
-		errorRate := float64(c.errors) / float64(c.connections) * 100
-		fmt.Printf("Error rate:   %.2f%%\n", errorRate)
+		fmt.Printf("Error rate:   %.2f%%\n", c.errorRate()*100)
 	}
 
 	if len(c.connectLatencies) > 0 {
@@ -102,6 +118,16 @@ func (c *Collector) Report() {
 		printPercentiles(c.msgLatencies)
 	}
 
+	if len(c.matchLatencies) > 0 {
+		fmt.Println("\n--- Match Latency ---")
+		printPercentiles(c.matchLatencies)
+	}
+
+	if len(c.cleanupLatencies) > 0 {
+		fmt.Println("\n--- Disconnect Cleanup Latency ---")
+		printPercentiles(c.cleanupLatencies)
+	}
+
 	if c.scraper != nil {
 		c.scraper.Report()
 	}
@@ -109,28 +135,94 @@ func (c *Collector) Report() {
 	fmt.Println()
 }
 
-// printPercentiles sorts the given durations and prints avg, p50, p95, p99,
-// and max values along with the sample count.
-func printPercentiles(durations []time.Duration) {
-	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+// errorRate returns errors per successful connection as a fraction. A run
+// with errors but no connections has an error rate of 1. The caller must
+// hold c.mu.
+func (c *Collector) errorRate() float64 {
+	if c.connections == 0 {
+		if c.errors > 0 {
+			return 1
+		}
+		return 0
+	}
+	return float64(c.errors) / float64(c.connections)
+}
+
+// percentile returns the p-th percentile (0 < p <= 1) of durations, which
+// must be sorted and non-empty.
+func percentile(durations []time.Duration, p float64) time.Duration {
+	return durations[int(math.Ceil(float64(len(durations))*p))-1]
+}
+
+// samples returns the latency samples of the given kind: connect, msg,
+// match or cleanup. The caller must hold c.mu.
+func (c *Collector) samples(kind string) []time.Duration {
+	switch kind {
+	case "connect":
+		return c.connectLatencies
+	case "msg":
+		return c.msgLatencies
+	case "match":
+		return c.matchLatencies
+	case "cleanup":
+		return c.cleanupLatencies
+	}
+	return nil
+}
+
+// latencyStats is the distribution of a non-empty set of latency samples.
+type latencyStats struct {
+	n                       int
+	avg, p50, p95, p99, max time.Duration
+}
 
-	n := len(durations)
-	p50 := durations[n/2]
-	p95 := durations[int(math.Ceil(float64(n)*0.95))-1]
-	p99 := durations[int(math.Ceil(float64(n)*0.99))-1]
+// summarize computes the distribution of durations, which must be non-empty,
+// without reordering them.
+func summarize(durations []time.Duration) latencyStats {
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
 
+	n := len(sorted)
 	var sum time.Duration
-	for _, d := range durations {
+	for _, d := range sorted {
 		sum += d
 	}
-	avg := sum / time.Duration(n)
+	return latencyStats{
+		n:   n,
+		avg: sum / time.Duration(n),
+		p50: sorted[n/2],
+		p95: percentile(sorted, 0.95),
+		p99: percentile(sorted, 0.99),
+		max: sorted[n-1],
+	}
+}
 
+// stat returns the named statistic: avg, p50, p95, p99 or max.
+func (s latencyStats) stat(name string) time.Duration {
+	switch name {
+	case "avg":
+		return s.avg
+	case "p50":
+		return s.p50
+	case "p95":
+		return s.p95
+	case "p99":
+		return s.p99
+	}
+	return s.max
+}
+
+// printPercentiles prints avg, p50, p95, p99, and max values of the given
+// durations along with the sample count.
+func printPercentiles(durations []time.Duration) {
+	s := summarize(durations)
 	fmt.Printf("  avg: %v  p50: %v  p95: %v  p99: %v  max: %v  (n=%d)\n",
-		avg.Round(time.Microsecond),
-		p50.Round(time.Microsecond),
-		p95.Round(time.Microsecond),
-		p99.Round(time.Microsecond),
-		durations[n-1].Round(time.Microsecond),
-		n,
+		s.avg.Round(time.Microsecond),
+		s.p50.Round(time.Microsecond),
+		s.p95.Round(time.Microsecond),
+		s.p99.Round(time.Microsecond),
+		s.max.Round(time.Microsecond),
+		s.n,
 	)
 }
@@ -0,0 +1,4 @@
+-- 002_create_fingerprint_notes.down.sql
+-- Drops the fingerprint_notes table and its indexes.
+
+DROP TABLE IF EXISTS fingerprint_notes;
@@ -0,0 +1,21 @@
+-- 002_create_fingerprint_notes.up.sql
+-- Creates the fingerprint_notes table for private moderator notes and tags
+-- attached to a fingerprint (e.g. "suspected ban evader"). Notes are shown
+-- next to reports in the admin API so decisions stay consistent across shifts.
+
+CREATE TABLE IF NOT EXISTS fingerprint_notes (
+    id           BIGSERIAL    PRIMARY KEY,
+    fingerprint  TEXT         NOT NULL,
+    author       TEXT         NOT NULL,
+    note         TEXT         NOT NULL DEFAULT '',
+    tags         TEXT[]       NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+-- Index for listing a fingerprint's notes newest-first.
+CREATE INDEX idx_fingerprint_notes_fingerprint_created
+    ON fingerprint_notes (fingerprint, created_at);
+
+-- Index for finding every fingerprint carrying a given tag.
+CREATE INDEX idx_fingerprint_notes_tags
+    ON fingerprint_notes USING GIN (tags);
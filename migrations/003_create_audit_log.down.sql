@@ -0,0 +1,4 @@
+-- 003_create_audit_log.down.sql
+-- Drops the audit_log table and its indexes.
+
+DROP TABLE IF EXISTS audit_log;
@@ -0,0 +1,26 @@
+-- 003_create_audit_log.up.sql
+-- Creates the audit_log table: an append-only record of every moderation and
+-- ban action (ban applied, report filed, message blocked, admin unban), kept
+-- so a contested ban can be traced back to the events that caused it.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id                  BIGSERIAL    PRIMARY KEY,
+    action              TEXT         NOT NULL,
+    actor               TEXT         NOT NULL,
+    target_fingerprint  TEXT         NOT NULL DEFAULT '',
+    reason              TEXT         NOT NULL DEFAULT '',
+    context             JSONB        NOT NULL DEFAULT '{}',
+    created_at          TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT chk_audit_action CHECK (
+        action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban')
+    )
+);
+
+-- Index for a fingerprint's audit trail, newest first.
+CREATE INDEX idx_audit_log_target_created
+    ON audit_log (target_fingerprint, created_at);
+
+-- Index for browsing recent events of one kind.
+CREATE INDEX idx_audit_log_action_created
+    ON audit_log (action, created_at);
@@ -0,0 +1,20 @@
+-- 004_add_report_categories.down.sql
+-- Drops the report category columns and restores the original reason check.
+
+DROP INDEX IF EXISTS idx_abuse_reports_needs_review;
+
+ALTER TABLE abuse_reports DROP CONSTRAINT IF EXISTS chk_abuse_reports_category;
+
+ALTER TABLE abuse_reports
+    DROP COLUMN IF EXISTS needs_review,
+    DROP COLUMN IF EXISTS weight,
+    DROP COLUMN IF EXISTS details,
+    DROP COLUMN IF EXISTS category;
+
+UPDATE abuse_reports
+SET reason = 'other'
+WHERE reason NOT IN ('harassment', 'spam', 'explicit', 'other');
+
+ALTER TABLE abuse_reports ADD CONSTRAINT abuse_reports_reason_check CHECK (
+    reason IN ('harassment', 'spam', 'explicit', 'other')
+);
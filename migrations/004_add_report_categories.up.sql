@@ -0,0 +1,26 @@
+-- 004_add_report_categories.up.sql
+-- Adds a structured category to abuse_reports. The free-text reason column is
+-- kept as sent by the client; category is the validated taxonomy value used
+-- for auto-ban weighting. weight and needs_review record the category policy
+-- at the time of the report, so a policy change does not rewrite history.
+
+ALTER TABLE abuse_reports DROP CONSTRAINT IF EXISTS abuse_reports_reason_check;
+
+ALTER TABLE abuse_reports
+    ADD COLUMN IF NOT EXISTS category      TEXT     NOT NULL DEFAULT 'other',
+    ADD COLUMN IF NOT EXISTS details       TEXT     NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS weight        INTEGER  NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS needs_review  BOOLEAN  NOT NULL DEFAULT FALSE;
+
+-- Existing reports used the old reason set; "explicit" became sexual_content.
+UPDATE abuse_reports
+SET category = CASE reason WHEN 'explicit' THEN 'sexual_content' ELSE reason END,
+    weight   = CASE reason WHEN 'explicit' THEN 2 ELSE 1 END;
+
+ALTER TABLE abuse_reports ADD CONSTRAINT chk_abuse_reports_category CHECK (
+    category IN ('harassment', 'spam', 'sexual_content', 'underage', 'other')
+);
+
+-- Index for the moderator review queue.
+CREATE INDEX IF NOT EXISTS idx_abuse_reports_needs_review
+    ON abuse_reports (created_at) WHERE needs_review;
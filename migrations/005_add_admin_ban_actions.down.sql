@@ -0,0 +1,10 @@
+-- 005_add_admin_ban_actions.down.sql
+-- Removes the admin ban actions and restores the original action check.
+
+DELETE FROM audit_log WHERE action IN ('admin_ban', 'admin_ban_import');
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS chk_audit_action;
+
+ALTER TABLE audit_log ADD CONSTRAINT chk_audit_action CHECK (
+    action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban')
+);
@@ -0,0 +1,11 @@
+-- 005_add_admin_ban_actions.up.sql
+-- Allows audit events for network bans placed and ban lists imported through
+-- the admin API. Network bans have no fingerprint; the network is recorded in
+-- context.
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS chk_audit_action;
+
+ALTER TABLE audit_log ADD CONSTRAINT chk_audit_action CHECK (
+    action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban',
+               'admin_ban', 'admin_ban_import')
+);
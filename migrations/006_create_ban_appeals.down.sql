@@ -0,0 +1,13 @@
+-- 006_create_ban_appeals.down.sql
+-- Drops the ban_appeals table and removes the appeal audit actions.
+
+DROP TABLE IF EXISTS ban_appeals;
+
+DELETE FROM audit_log WHERE action IN ('appeal_filed', 'appeal_decided');
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS chk_audit_action;
+
+ALTER TABLE audit_log ADD CONSTRAINT chk_audit_action CHECK (
+    action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban',
+               'admin_ban', 'admin_ban_import')
+);
@@ -0,0 +1,40 @@
+-- 006_create_ban_appeals.up.sql
+-- Creates the ban_appeals table: a banned user's statement asking for the ban
+-- to be lifted, and the moderator's decision. A fingerprint can have at most
+-- one pending appeal. Also allows the appeal audit actions.
+
+CREATE TABLE IF NOT EXISTS ban_appeals (
+    id             BIGSERIAL    PRIMARY KEY,
+    fingerprint    TEXT         NOT NULL,
+    ban_reason     TEXT         NOT NULL DEFAULT '',
+    statement      TEXT         NOT NULL,
+    status         TEXT         NOT NULL DEFAULT 'pending',
+    decided_by     TEXT         NOT NULL DEFAULT '',
+    decision_note  TEXT         NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    decided_at     TIMESTAMPTZ,
+    notified_at    TIMESTAMPTZ,
+
+    CONSTRAINT chk_ban_appeals_status CHECK (
+        status IN ('pending', 'lifted', 'upheld')
+    )
+);
+
+-- At most one pending appeal per fingerprint.
+CREATE UNIQUE INDEX idx_ban_appeals_pending_fingerprint
+    ON ban_appeals (fingerprint) WHERE status = 'pending';
+
+-- Index for the moderation queue, oldest first.
+CREATE INDEX idx_ban_appeals_status_created
+    ON ban_appeals (status, created_at);
+
+-- Index for a fingerprint's appeals, including undelivered decisions.
+CREATE INDEX idx_ban_appeals_fingerprint_created
+    ON ban_appeals (fingerprint, created_at);
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS chk_audit_action;
+
+ALTER TABLE audit_log ADD CONSTRAINT chk_audit_action CHECK (
+    action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban',
+               'admin_ban', 'admin_ban_import', 'appeal_filed', 'appeal_decided')
+);
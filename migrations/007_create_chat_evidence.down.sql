@@ -0,0 +1,13 @@
+-- 007_create_chat_evidence.down.sql
+-- Drops the chat_evidence table and removes the terminate audit action.
+
+DROP TABLE IF EXISTS chat_evidence;
+
+DELETE FROM audit_log WHERE action = 'admin_terminate_chat';
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS chk_audit_action;
+
+ALTER TABLE audit_log ADD CONSTRAINT chk_audit_action CHECK (
+    action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban',
+               'admin_ban', 'admin_ban_import', 'appeal_filed', 'appeal_decided')
+);
@@ -0,0 +1,29 @@
+-- 007_create_chat_evidence.up.sql
+-- Creates the chat_evidence table: the transcript of a chat captured when a
+-- moderator terminated it through the admin API, with both participants'
+-- fingerprints and the one banned, if any. Also allows the terminate audit
+-- action.
+
+CREATE TABLE IF NOT EXISTS chat_evidence (
+    id                  BIGSERIAL    PRIMARY KEY,
+    chat_id             TEXT         NOT NULL,
+    actor               TEXT         NOT NULL,
+    reason              TEXT         NOT NULL DEFAULT '',
+    fingerprint_a       TEXT         NOT NULL DEFAULT '',
+    fingerprint_b       TEXT         NOT NULL DEFAULT '',
+    banned_fingerprint  TEXT         NOT NULL DEFAULT '',
+    messages            JSONB        NOT NULL,
+    created_at          TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+-- Index for looking up the evidence of a chat.
+CREATE INDEX idx_chat_evidence_chat_id
+    ON chat_evidence (chat_id, created_at);
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS chk_audit_action;
+
+ALTER TABLE audit_log ADD CONSTRAINT chk_audit_action CHECK (
+    action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban',
+               'admin_ban', 'admin_ban_import', 'appeal_filed', 'appeal_decided',
+               'admin_terminate_chat')
+);
@@ -0,0 +1,12 @@
+-- 008_add_bot_flagged_action.down.sql
+-- Removes the bot detection audit action.
+
+DELETE FROM audit_log WHERE action = 'bot_flagged';
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS chk_audit_action;
+
+ALTER TABLE audit_log ADD CONSTRAINT chk_audit_action CHECK (
+    action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban',
+               'admin_ban', 'admin_ban_import', 'appeal_filed', 'appeal_decided',
+               'admin_terminate_chat')
+);
@@ -0,0 +1,12 @@
+-- 009_add_admin_disconnect_action.down.sql
+-- Removes the operator disconnect audit action.
+
+DELETE FROM audit_log WHERE action = 'admin_disconnect';
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS chk_audit_action;
+
+ALTER TABLE audit_log ADD CONSTRAINT chk_audit_action CHECK (
+    action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban',
+               'admin_ban', 'admin_ban_import', 'appeal_filed', 'appeal_decided',
+               'admin_terminate_chat', 'bot_flagged')
+);
@@ -0,0 +1,10 @@
+-- 009_add_admin_disconnect_action.up.sql
+-- Allows the audit action recorded when an operator disconnects a session.
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS chk_audit_action;
+
+ALTER TABLE audit_log ADD CONSTRAINT chk_audit_action CHECK (
+    action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban',
+               'admin_ban', 'admin_ban_import', 'appeal_filed', 'appeal_decided',
+               'admin_terminate_chat', 'bot_flagged', 'admin_disconnect')
+);
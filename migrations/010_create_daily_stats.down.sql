@@ -0,0 +1,5 @@
+-- 010_create_daily_stats.down.sql
+-- Drops the daily summary tables.
+
+DROP TABLE IF EXISTS daily_report_categories;
+DROP TABLE IF EXISTS daily_stats;
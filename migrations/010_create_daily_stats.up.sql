@@ -0,0 +1,25 @@
+-- 010_create_daily_stats.up.sql
+-- Creates the daily summary tables filled by the stats aggregation job: one
+-- row per UTC day with chat, report, ban and filter block totals, and the
+-- day's reports per category. Dashboards read these instead of scanning
+-- abuse_reports and audit_log, and the totals outlive report retention.
+
+CREATE TABLE IF NOT EXISTS daily_stats (
+    day               DATE         PRIMARY KEY,
+    chats             BIGINT       NOT NULL DEFAULT 0,
+    short_chats       BIGINT       NOT NULL DEFAULT 0,
+    reported_chats    BIGINT       NOT NULL DEFAULT 0,
+    reports           BIGINT       NOT NULL DEFAULT 0,
+    auto_bans         BIGINT       NOT NULL DEFAULT 0,
+    admin_bans        BIGINT       NOT NULL DEFAULT 0,
+    messages_blocked  BIGINT       NOT NULL DEFAULT 0,
+    updated_at        TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS daily_report_categories (
+    day       DATE    NOT NULL REFERENCES daily_stats (day) ON DELETE CASCADE,
+    category  TEXT    NOT NULL,
+    reports   BIGINT  NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (day, category)
+);
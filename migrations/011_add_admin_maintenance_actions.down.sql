@@ -0,0 +1,12 @@
+-- 011_add_admin_maintenance_actions.down.sql
+-- Removes the maintenance mode audit actions.
+
+DELETE FROM audit_log WHERE action IN ('admin_maintenance_on', 'admin_maintenance_off');
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS chk_audit_action;
+
+ALTER TABLE audit_log ADD CONSTRAINT chk_audit_action CHECK (
+    action IN ('ban_applied', 'report_filed', 'message_blocked', 'admin_unban',
+               'admin_ban', 'admin_ban_import', 'appeal_filed', 'appeal_decided',
+               'admin_terminate_chat', 'bot_flagged', 'admin_disconnect')
+);
@@ -0,0 +1,4 @@
+-- 001_create_abuse_reports.down.sql
+-- Drops the abuse_reports table and its indexes.
+
+DROP TABLE IF EXISTS abuse_reports;
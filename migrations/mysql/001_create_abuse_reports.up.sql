@@ -0,0 +1,29 @@
+-- 001_create_abuse_reports.up.sql
+-- MySQL schema for abuse_reports, equivalent to PostgreSQL migrations 001
+-- and 004. Only reports are stored on MySQL; moderator notes and the audit
+-- log need PostgreSQL. Requires MySQL 8.0.16+ for the CHECK constraint.
+
+CREATE TABLE IF NOT EXISTS abuse_reports (
+    id                    BIGINT        NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    reporter_fingerprint  VARCHAR(255)  NOT NULL,
+    reported_fingerprint  VARCHAR(255)  NOT NULL,
+    chat_id               VARCHAR(255)  NOT NULL,
+    reason                VARCHAR(64)   NOT NULL,
+    category              VARCHAR(32)   NOT NULL DEFAULT 'other',
+    details               VARCHAR(2000) NOT NULL DEFAULT '',
+    weight                INT           NOT NULL DEFAULT 1,
+    needs_review          BOOLEAN       NOT NULL DEFAULT FALSE,
+    messages              JSON          NULL,
+    created_at            TIMESTAMP(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    CONSTRAINT chk_abuse_reports_category CHECK (
+        category IN ('harassment', 'spam', 'sexual_content', 'underage', 'other')
+    ),
+
+    -- Index for auto-ban lookup: 3 reports against same fingerprint in 24h.
+    INDEX idx_abuse_reports_reported_fingerprint_created (reported_fingerprint, created_at),
+    -- Index for 30-day retention cleanup job.
+    INDEX idx_abuse_reports_created_at (created_at),
+    -- Index for the moderator review queue.
+    INDEX idx_abuse_reports_needs_review (needs_review, created_at)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,4 @@
+-- 002_create_chat_evidence.down.sql
+-- Drops the chat_evidence table and its index.
+
+DROP TABLE IF EXISTS chat_evidence;
@@ -0,0 +1,18 @@
+-- 002_create_chat_evidence.up.sql
+-- MySQL schema for chat_evidence, equivalent to PostgreSQL migration 007:
+-- transcripts of chats terminated by a moderator.
+
+CREATE TABLE IF NOT EXISTS chat_evidence (
+    id                  BIGINT        NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    chat_id             VARCHAR(255)  NOT NULL,
+    actor               VARCHAR(255)  NOT NULL,
+    reason              VARCHAR(2000) NOT NULL DEFAULT '',
+    fingerprint_a       VARCHAR(255)  NOT NULL DEFAULT '',
+    fingerprint_b       VARCHAR(255)  NOT NULL DEFAULT '',
+    banned_fingerprint  VARCHAR(255)  NOT NULL DEFAULT '',
+    messages            JSON          NOT NULL,
+    created_at          TIMESTAMP(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    -- Index for looking up the evidence of a chat.
+    INDEX idx_chat_evidence_chat_id (chat_id, created_at)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,34 @@
+-- 001_create_abuse_reports.up.sql
+-- SQLite schema for abuse_reports, equivalent to PostgreSQL migrations 001
+-- and 004. Only reports are stored on SQLite; moderator notes and the audit
+-- log need PostgreSQL.
+
+CREATE TABLE IF NOT EXISTS abuse_reports (
+    id                    INTEGER   PRIMARY KEY AUTOINCREMENT,
+    reporter_fingerprint  TEXT      NOT NULL,
+    reported_fingerprint  TEXT      NOT NULL,
+    chat_id               TEXT      NOT NULL,
+    reason                TEXT      NOT NULL,
+    category              TEXT      NOT NULL DEFAULT 'other',
+    details               TEXT      NOT NULL DEFAULT '',
+    weight                INTEGER   NOT NULL DEFAULT 1,
+    needs_review          BOOLEAN   NOT NULL DEFAULT FALSE,
+    messages              TEXT,
+    created_at            DATETIME  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_abuse_reports_category CHECK (
+        category IN ('harassment', 'spam', 'sexual_content', 'underage', 'other')
+    )
+);
+
+-- Index for auto-ban lookup: 3 reports against same fingerprint in 24h.
+CREATE INDEX IF NOT EXISTS idx_abuse_reports_reported_fingerprint_created
+    ON abuse_reports (reported_fingerprint, created_at);
+
+-- Index for 30-day retention cleanup job.
+CREATE INDEX IF NOT EXISTS idx_abuse_reports_created_at
+    ON abuse_reports (created_at);
+
+-- Index for the moderator review queue.
+CREATE INDEX IF NOT EXISTS idx_abuse_reports_needs_review
+    ON abuse_reports (created_at) WHERE needs_review;
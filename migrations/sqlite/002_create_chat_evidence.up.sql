@@ -0,0 +1,19 @@
+-- 002_create_chat_evidence.up.sql
+-- SQLite schema for chat_evidence, equivalent to PostgreSQL migration 007:
+-- transcripts of chats terminated by a moderator.
+
+CREATE TABLE IF NOT EXISTS chat_evidence (
+    id                  INTEGER   PRIMARY KEY AUTOINCREMENT,
+    chat_id             TEXT      NOT NULL,
+    actor               TEXT      NOT NULL,
+    reason              TEXT      NOT NULL DEFAULT '',
+    fingerprint_a       TEXT      NOT NULL DEFAULT '',
+    fingerprint_b       TEXT      NOT NULL DEFAULT '',
+    banned_fingerprint  TEXT      NOT NULL DEFAULT '',
+    messages            TEXT      NOT NULL,
+    created_at          DATETIME  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for looking up the evidence of a chat.
+CREATE INDEX IF NOT EXISTS idx_chat_evidence_chat_id
+    ON chat_evidence (chat_id, created_at);
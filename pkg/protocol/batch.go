@@ -0,0 +1,44 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MaxBatchSize is the most messages one batch frame may carry, in either
+// direction.
+const MaxBatchSize = 32
+
+// BatchMsg carries several protocol messages in one frame. Clients send it
+// to cut frame overhead; the server unpacks it and handles the messages in
+// order as if they had arrived one per frame. Clients that send a batch, or
+// connect with ?batch=1, may receive batches too: the server coalesces
+// events queued for the same connection into one frame.
+type BatchMsg struct {
+	Type     string            `json:"type"`
+	Messages []json.RawMessage `json:"messages"`
+}
+
+// validate rejects empty and oversized batches.
+func (m BatchMsg) validate() error {
+	if len(m.Messages) == 0 {
+		return fmt.Errorf("batch is empty")
+	}
+	if len(m.Messages) > MaxBatchSize {
+		return fmt.Errorf("batch has %d messages, limit is %d", len(m.Messages), MaxBatchSize)
+	}
+	return nil
+}
+
+// AppendBatch appends a batch frame carrying msgs, each already an encoded
+// server message, to dst and returns the extended slice.
+func AppendBatch(dst []byte, msgs [][]byte) []byte {
+	dst = append(dst, `{"type":"batch","messages":[`...)
+	for i, m := range msgs {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, m...)
+	}
+	return append(dst, "]}"...)
+}
@@ -0,0 +1,119 @@
+package protocol
+
+import "maps"
+
+// ErrorCode identifies the condition reported by an error message. Clients
+// should branch on the code, or on its category and retryability, rather
+// than on the human-readable message.
+type ErrorCode string
+
+// Error codes sent in ErrorMsg.Code.
+const (
+	ErrParseError      ErrorCode = "parse_error"      // the frame is not a valid message
+	ErrUnsupportedType ErrorCode = "unsupported_type" // no handler for the message type
+	ErrFrameTooLarge   ErrorCode = "frame_too_large"  // the frame exceeds the size limit
+
+	ErrInvalidMessage    ErrorCode = "invalid_message"     // chat text failed validation; see Reason and Limits
+	ErrInvalidNickname   ErrorCode = "invalid_nickname"    // the nickname failed validation or the filter
+	ErrInvalidInterests  ErrorCode = "invalid_interests"   // see Rejected
+	ErrInvalidMeta       ErrorCode = "invalid_meta"        // chat_meta icebreaker or mood
+	ErrInvalidReaction   ErrorCode = "invalid_reaction"    // unknown emoji or message ID
+	ErrInvalidCard       ErrorCode = "invalid_card"        // share_card fields
+	ErrInvalidReason     ErrorCode = "invalid_reason"      // report reason or details
+	ErrInvalidClientInfo ErrorCode = "invalid_client_info" // client_info fields
+
+	ErrInvalidChat           ErrorCode = "invalid_chat"           // the session is not in that active chat
+	ErrRematchUnavailable    ErrorCode = "rematch_unavailable"    // the rematch window passed or a user is busy
+	ErrIdleTimeout           ErrorCode = "idle_timeout"           // the connection is closed for inactivity
+	ErrFeatureDisabled       ErrorCode = "feature_disabled"       // the feature flag is off for the user
+	ErrTranscriptUnavailable ErrorCode = "transcript_unavailable" // chat history is not enabled
+	ErrBlockUnavailable      ErrorCode = "block_unavailable"      // the partner cannot be blocked
+
+	ErrMessageBlocked ErrorCode = "message_blocked" // refused by the content filter; Reason is the category
+	ErrMuted          ErrorCode = "muted"           // repeated filter blocks; see RetryAfter
+	ErrContentWarning ErrorCode = "content_warning" // a delivered message was flagged afterwards
+	ErrDisconnected   ErrorCode = "disconnected"    // an operator closed the connection
+
+	ErrServerBusy      ErrorCode = "server_busy"       // the frame was dropped under load
+	ErrTooManySessions ErrorCode = "too_many_sessions" // the browser has too many open sessions
+)
+
+// Error categories, grouping codes a client handles the same way.
+const (
+	CategoryProtocol    = "protocol"    // the client sent something malformed
+	CategoryValidation  = "validation"  // a field failed validation; fix the input
+	CategoryState       = "state"       // the request does not fit the session's state
+	CategoryUnavailable = "unavailable" // the server does not offer this to the user
+	CategoryModeration  = "moderation"  // content was refused or flagged
+	CategoryCapacity    = "capacity"    // a server or per-user limit was reached
+)
+
+// ErrorInfo describes an error code: its category, an HTTP-style status for
+// clients and logs that think in those terms, and whether repeating the same
+// request later may succeed.
+type ErrorInfo struct {
+	Category  string
+	Status    int
+	Retryable bool
+}
+
+// errorRegistry holds the ErrorInfo of every ErrorCode.
+var errorRegistry = map[ErrorCode]ErrorInfo{
+	ErrParseError:      {CategoryProtocol, 400, false},
+	ErrUnsupportedType: {CategoryProtocol, 400, false},
+	ErrFrameTooLarge:   {CategoryProtocol, 413, false},
+
+	ErrInvalidMessage:    {CategoryValidation, 400, false},
+	ErrInvalidNickname:   {CategoryValidation, 400, false},
+	ErrInvalidInterests:  {CategoryValidation, 400, false},
+	ErrInvalidMeta:       {CategoryValidation, 400, false},
+	ErrInvalidReaction:   {CategoryValidation, 400, false},
+	ErrInvalidCard:       {CategoryValidation, 400, false},
+	ErrInvalidReason:     {CategoryValidation, 400, false},
+	ErrInvalidClientInfo: {CategoryValidation, 400, false},
+
+	ErrInvalidChat:        {CategoryState, 409, false},
+	ErrRematchUnavailable: {CategoryState, 409, false},
+	ErrIdleTimeout:        {CategoryState, 408, true},
+
+	ErrFeatureDisabled:       {CategoryUnavailable, 403, false},
+	ErrTranscriptUnavailable: {CategoryUnavailable, 501, false},
+	ErrBlockUnavailable:      {CategoryUnavailable, 503, false},
+
+	ErrMessageBlocked: {CategoryModeration, 422, false},
+	ErrMuted:          {CategoryModeration, 429, true},
+	ErrContentWarning: {CategoryModeration, 422, false},
+	ErrDisconnected:   {CategoryModeration, 403, false},
+
+	ErrServerBusy:      {CategoryCapacity, 503, true},
+	ErrTooManySessions: {CategoryCapacity, 429, false},
+}
+
+// LookupError returns the ErrorInfo of code and whether it is registered.
+func LookupError(code ErrorCode) (ErrorInfo, bool) {
+	info, ok := errorRegistry[code]
+	return info, ok
+}
+
+// ErrorCodes returns every registered error code with its ErrorInfo.
+func ErrorCodes() map[ErrorCode]ErrorInfo {
+	return maps.Clone(errorRegistry)
+}
+
+// NewError returns an ErrorMsg for code with its registered category,
+// status and retryability filled in. An unregistered code is sent with
+// status 500 and no category.
+func NewError(code ErrorCode, message string) ErrorMsg {
+	info, ok := errorRegistry[code]
+	if !ok {
+		info.Status = 500
+	}
+	return ErrorMsg{
+		Type:      TypeError,
+		Code:      code,
+		Message:   message,
+		Category:  info.Category,
+		Status:    info.Status,
+		Retryable: info.Retryable,
+	}
+}
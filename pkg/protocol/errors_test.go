@@ -0,0 +1,65 @@
+package protocol
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestErrorRegistry_Complete(t *testing.T) {
+	for code, info := range ErrorCodes() {
+		if info.Category == "" {
+			t.Errorf("%s: no category", code)
+		}
+		if info.Status < 400 || info.Status > 599 {
+			t.Errorf("%s: status %d is not an error status", code, info.Status)
+		}
+	}
+}
+
+func TestNewError_JSON(t *testing.T) {
+	data, err := NewServerMessage(TypeError, NewError(ErrServerBusy, "Server is busy"))
+	if err != nil {
+		t.Fatalf("NewServerMessage: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	want := map[string]interface{}{
+		"type":      TypeError,
+		"code":      "server_busy",
+		"message":   "Server is busy",
+		"category":  CategoryCapacity,
+		"status":    float64(503),
+		"retryable": true,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestNewError_NotRetryable(t *testing.T) {
+	data, _ := NewServerMessage(TypeError, NewError(ErrInvalidChat, "not in an active chat"))
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	// retryable is always present so clients need not default it.
+	if r, ok := got["retryable"]; !ok || r != false {
+		t.Errorf("retryable = %v (present %v), want false", r, ok)
+	}
+}
+
+func TestNewError_Unregistered(t *testing.T) {
+	msg := NewError("made_up", "x")
+	if msg.Status != 500 || msg.Category != "" || msg.Retryable {
+		t.Errorf("got status=%d category=%q retryable=%v, want 500, none, false", msg.Status, msg.Category, msg.Retryable)
+	}
+	if _, ok := LookupError("made_up"); ok {
+		t.Error("LookupError found an unregistered code")
+	}
+}
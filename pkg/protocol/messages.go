@@ -0,0 +1,839 @@
+// Package protocol defines the WebSocket message types and structures used for
+// communication between the client and server. All messages are serialized as
+// JSON and follow a consistent envelope format with a type discriminator.
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownType is returned by ParseClientMessage for a message whose type
+// is not a client message type.
+var ErrUnknownType = errors.New("protocol: unknown client message type")
+
+// ---------------------------------------------------------------------------
+// Message type constants
+// ---------------------------------------------------------------------------
+
+// Client -> Server message types.
+const (
+	TypeSetFingerprint    = "set_fingerprint"
+	TypeClientInfo        = "client_info"
+	TypeFindMatch         = "find_match"
+	TypeCancelMatch       = "cancel_match"
+	TypeAcceptMatch       = "accept_match"
+	TypeDeclineMatch      = "decline_match"
+	TypeMessage           = "message"
+	TypeTyping            = "typing"
+	TypeEndChat           = "end_chat"
+	TypeReport            = "report"
+	TypePing              = "ping"
+	TypeResumeSession     = "resume_session"
+	TypeChatMeta          = "chat_meta"
+	TypeRequestTranscript = "request_transcript"
+	TypeBlock             = "block"
+	TypeShareCard         = "share_card"
+	TypeRematchRequest    = "rematch_request"
+	TypeRematchAccept     = "rematch_accept"
+	TypeBatch             = "batch" // also sent by the server; see BatchMsg
+	TypeReact             = "react"
+)
+
+// Server -> Client message types.
+const (
+	TypeSessionCreated      = "session_created"
+	TypeConfig              = "config"
+	TypeMatchingStarted     = "matching_started"
+	TypeMatchingStatus      = "matching_status"
+	TypeMatchFound          = "match_found"
+	TypePartnerReady        = "partner_ready"
+	TypeMatchAccepted       = "match_accepted"
+	TypeMatchDeclined       = "match_declined"
+	TypeMatchTimeout        = "match_timeout"
+	TypePartnerLeft         = "partner_left"
+	TypeRateLimited         = "rate_limited"
+	TypeBanned              = "banned"
+	TypeError               = "error"
+	TypePong                = "pong"
+	TypeMessageRetracted    = "message_retracted"
+	TypeServiceUnavailable  = "service_unavailable"
+	TypeServerShutdown      = "server_shutdown"
+	TypeTranscript          = "transcript"
+	TypeTranscriptPending   = "transcript_pending"
+	TypeTranscriptRequested = "transcript_requested"
+	TypeBlockConfirmed      = "block_confirmed"
+	TypePartnerCard         = "partner_card"
+	TypeInactivityWarning   = "inactivity_warning"
+	TypeChatSummary         = "chat_summary"
+	TypeChatGap             = "chat_gap"
+	TypeRematchRequested    = "rematch_requested"
+	TypeReaction            = "reaction"
+	TypeAppealDecision      = "appeal_decision"
+	TypeWaitingRoom         = "waiting_room"
+	TypeMaintenance         = "maintenance"
+)
+
+// ---------------------------------------------------------------------------
+// Envelope — used for initial JSON parsing to extract the type discriminator.
+// ---------------------------------------------------------------------------
+
+// Envelope holds the message type and the raw JSON payload for deferred
+// parsing into a concrete struct.
+type Envelope struct {
+	Type string          `json:"type"`
+	Raw  json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It captures the
+// full raw bytes and extracts only the "type" field so that the rest of the
+// payload can be decoded later into the appropriate concrete struct.
+func (e *Envelope) UnmarshalJSON(data []byte) error {
+	// Capture the full raw message for deferred parsing.
+	e.Raw = make(json.RawMessage, len(data))
+	copy(e.Raw, data)
+
+	// Extract only the type field.
+	var partial struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &partial); err != nil {
+		return fmt.Errorf("protocol: failed to unmarshal envelope: %w", err)
+	}
+	if partial.Type == "" {
+		return fmt.Errorf("protocol: missing or empty \"type\" field")
+	}
+	e.Type = partial.Type
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Client -> Server message structs
+// ---------------------------------------------------------------------------
+
+// SetFingerprintMsg is sent by the client to associate a browser fingerprint
+// hash with the current session for ban enforcement.
+type SetFingerprintMsg struct {
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// ClientInfoMsg is sent by the client after session_created to describe
+// itself: the platform ("web", "ios", "android", "desktop"), the app
+// version and the user's locale. It is stored on the session for bug
+// reports and version-gated features.
+type ClientInfoMsg struct {
+	Type       string `json:"type"`
+	Platform   string `json:"platform"`
+	AppVersion string `json:"app_version"`
+	Locale     string `json:"locale,omitempty"`
+}
+
+// FindMatchMsg is sent by the client to enter the matching queue with optional
+// interest tags.
+type FindMatchMsg struct {
+	Type      string   `json:"type"`
+	Interests []string `json:"interests"`
+	Language  string   `json:"language,omitempty"` // opt-in to translation, e.g. "en" or "pt-BR"
+	Policy    string   `json:"policy,omitempty"`   // content policy preference: "strict" or "standard"
+}
+
+// CancelMatchMsg is sent by the client to leave the matching queue.
+type CancelMatchMsg struct {
+	Type string `json:"type"`
+}
+
+// AcceptMatchMsg is sent by the client to accept a proposed match. Nickname
+// optionally picks the name shown to the partner for this chat; the server
+// generates one when it is empty or rejected.
+type AcceptMatchMsg struct {
+	Type     string `json:"type"`
+	ChatID   string `json:"chat_id"`
+	Nickname string `json:"nickname,omitempty"`
+}
+
+// DeclineMatchMsg is sent by the client to decline a proposed match. With
+// ReRoll set the client is re-queued with the same interests right away.
+type DeclineMatchMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+	ReRoll bool   `json:"re_roll,omitempty"`
+}
+
+// ChatMsg is a text message sent by the client within a chat session.
+type ChatMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// TypingMsg indicates whether the client is currently typing.
+type TypingMsg struct {
+	Type     string `json:"type"`
+	ChatID   string `json:"chat_id"`
+	IsTyping bool   `json:"is_typing"`
+}
+
+// ChatMetaMsg lets a client share small structured metadata with its chat
+// partner: a selected icebreaker prompt and/or an emoji mood. The server
+// relays it as ServerChatMetaMsg with the same type.
+type ChatMetaMsg struct {
+	Type       string `json:"type"`
+	ChatID     string `json:"chat_id"`
+	Icebreaker string `json:"icebreaker,omitempty"`
+	Mood       string `json:"mood,omitempty"`
+}
+
+// ShareCardMsg is an opt-in profile card the sender shares with its chat
+// partner. At least one field must be set; the server validates and filters
+// the card, relays it as PartnerCardMsg, and never stores it.
+type ShareCardMsg struct {
+	Type     string `json:"type"`
+	ChatID   string `json:"chat_id"`
+	Nickname string `json:"nickname,omitempty"`
+	Pronouns string `json:"pronouns,omitempty"`
+	About    string `json:"about,omitempty"`
+}
+
+// ReactMsg reacts to a message the partner sent, identified by the ID it
+// arrived with in ServerChatMsg. Emoji must be one of the allowed reactions;
+// an empty Emoji clears the sender's reaction. The server relays it as
+// ReactionMsg.
+type ReactMsg struct {
+	Type      string `json:"type"`
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+	Emoji     string `json:"emoji"`
+}
+
+// RequestTranscriptMsg asks for an export of the active chat. Sending it also
+// records the sender's consent; the transcript is only released once both
+// participants have sent it.
+type RequestTranscriptMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+}
+
+// EndChatMsg is sent by the client to end a chat session.
+type EndChatMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+}
+
+// RematchRequestMsg asks to chat again with the partner of ChatID, a chat
+// that ended within the last two minutes. Once both users have asked (the
+// second typically with rematch_accept) a new chat starts directly with
+// match_accepted, bypassing the queue.
+type RematchRequestMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+}
+
+// RematchAcceptMsg answers rematch_requested. It is equivalent to
+// rematch_request.
+type RematchAcceptMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+}
+
+// ReportMsg is sent by the client to report the chat partner. Reason is one
+// of harassment, spam, sexual_content, underage or other; Details is
+// optional free text, mainly for "other".
+type ReportMsg struct {
+	Type    string `json:"type"`
+	ChatID  string `json:"chat_id"`
+	Reason  string `json:"reason"`
+	Details string `json:"details,omitempty"`
+}
+
+// BlockMsg asks the server never to match the sender with its partner in
+// chat_id again. The block is stored against both fingerprints and expires
+// after 30 days.
+type BlockMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+}
+
+// PingMsg is a client-initiated keepalive ping.
+type PingMsg struct {
+	Type string `json:"type"`
+}
+
+// ResumeSessionMsg is sent by a reconnecting client to claim the session it
+// held before the connection was lost, so server-side state tied to the old
+// session (e.g. a queue entry on a crashed server) can be settled.
+type ResumeSessionMsg struct {
+	Type              string `json:"type"`
+	PreviousSessionID string `json:"previous_session_id"`
+}
+
+// ---------------------------------------------------------------------------
+// Server -> Client message structs
+// ---------------------------------------------------------------------------
+
+// SessionCreatedMsg is sent by the server when a new session is established.
+// SessionToken is a secret the client keeps to resume the session from a new
+// connection (?resume=<session_id>&token=<session_token>) or keep it alive
+// over HTTP while disconnected. Resumed is true when such a resume succeeded
+// and the session is the one the client asked for.
+type SessionCreatedMsg struct {
+	Type         string `json:"type"`
+	SessionID    string `json:"session_id"`
+	SessionToken string `json:"session_token,omitempty"`
+	Resumed      bool   `json:"resumed,omitempty"`
+}
+
+// ConfigMsg carries the limits the server enforces, so clients can respect
+// them instead of hardcoding copies. It is sent right after session_created
+// and again whenever the limits change on a config reload. Features holds
+// the feature flags evaluated for the session; it is sent again once the
+// client sets its fingerprint and whenever a rollout changes.
+type ConfigMsg struct {
+	Type                string                     `json:"type"`
+	MaxMessageChars     int                        `json:"max_message_chars"`               // code points
+	MaxMessageGraphemes int                        `json:"max_message_graphemes,omitempty"` // user-perceived characters; 0 when not limited
+	MaxMessageBytes     int                        `json:"max_message_bytes"`
+	MaxNicknameChars    int                        `json:"max_nickname_chars"`
+	TypingDebounceMs    int                        `json:"typing_debounce_ms"` // idle time before sending is_typing=false
+	AcceptDeadline      int                        `json:"accept_deadline"`    // seconds to accept a match
+	RateLimits          map[string]RateLimitConfig `json:"rate_limits"`        // keyed by rule name, as in rate_limited.limit
+	Features            map[string]bool            `json:"features,omitempty"` // keyed by flag name
+}
+
+// RateLimitConfig is one rate-limit rule as reported in ConfigMsg: at most
+// Limit actions per Window seconds. The message_bytes rule counts bytes.
+type RateLimitConfig struct {
+	Limit  int `json:"limit"`
+	Window int `json:"window"`
+}
+
+// MatchingStartedMsg is sent by the server to confirm the client has entered
+// the matching queue. Timeout is the matcher's current match timeout in
+// seconds.
+// Priority is set when a re-roll credit placed the client at the front of
+// the queue.
+type MatchingStartedMsg struct {
+	Type     string `json:"type"`
+	Timeout  int    `json:"timeout"`
+	Priority bool   `json:"priority,omitempty"`
+}
+
+// MatchingStatusMsg is sent periodically while the client waits in the
+// matching queue. Position is 1-based (1 = longest waiting). EstimatedWait is
+// the median seconds recent users waited for a match, or 0 when unknown.
+// Paused is set while the matcher is not pairing anyone, e.g. during
+// maintenance.
+type MatchingStatusMsg struct {
+	Type          string `json:"type"`
+	Position      int64  `json:"position"`
+	QueueSize     int64  `json:"queue_size"`
+	EstimatedWait int    `json:"estimated_wait"`
+	Paused        bool   `json:"paused,omitempty"`
+}
+
+// MatchFoundMsg is sent by the server when a compatible partner has been found.
+// MatchTier says how the pair was found ("exact", "overlap", "single" or
+// "random"), PartnerWait is how many seconds the partner was queued,
+// PartnerWaitTier the broadest tier that wait reached and PartnerRegion the
+// deployment region they connected through, if any. PartnerOtherInterests
+// counts the partner's interests that are not shared, without naming them.
+// No other partner detail is revealed before both users accept.
+type MatchFoundMsg struct {
+	Type                  string   `json:"type"`
+	ChatID                string   `json:"chat_id"`
+	SharedInterests       []string `json:"shared_interests"`
+	AcceptDeadline        int      `json:"accept_deadline"`
+	MatchTier             string   `json:"match_tier,omitempty"`
+	PartnerWait           int      `json:"partner_wait"`
+	PartnerWaitTier       string   `json:"partner_wait_tier,omitempty"`
+	PartnerRegion         string   `json:"partner_region,omitempty"`
+	PartnerOtherInterests int      `json:"partner_other_interests"`
+	Policy                string   `json:"policy,omitempty"` // content policy the chat is filtered with
+}
+
+// PartnerReadyMsg is sent to a user who has not answered match_found yet
+// when the partner accepts, so they know the chat starts as soon as they do.
+type PartnerReadyMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+}
+
+// MatchAcceptedMsg is sent by the server when both parties have accepted the
+// match and the chat session is ready.
+type MatchAcceptedMsg struct {
+	Type       string `json:"type"`
+	ChatID     string `json:"chat_id"`
+	Icebreaker string `json:"icebreaker,omitempty"` // suggested conversation starter
+
+	// Ephemeral identities for this chat: our own and the partner's.
+	Nickname          string `json:"nickname,omitempty"`
+	AvatarSeed        string `json:"avatar_seed,omitempty"`
+	PartnerNickname   string `json:"partner_nickname,omitempty"`
+	PartnerAvatarSeed string `json:"partner_avatar_seed,omitempty"`
+}
+
+// MatchDeclinedMsg is sent by the server when the partner declined the match.
+type MatchDeclinedMsg struct {
+	Type string `json:"type"`
+}
+
+// MatchTimeoutMsg is sent by the server when the matching queue timed out
+// without finding a partner.
+type MatchTimeoutMsg struct {
+	Type string `json:"type"`
+}
+
+// ServerChatMsg is a text message relayed from the partner by the server.
+// The ID can later be referenced by a MessageRetractedMsg.
+type ServerChatMsg struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+	From string `json:"from"`
+	Text string `json:"text"`
+	Ts   int64  `json:"ts"`
+
+	// Set when both users declared different languages and the text was
+	// translated; Text stays the original.
+	Translated string `json:"translated,omitempty"`
+	Lang       string `json:"lang,omitempty"` // language of Text
+
+	// The sender's nickname and avatar seed for this chat.
+	Nickname   string `json:"nickname,omitempty"`
+	AvatarSeed string `json:"avatar_seed,omitempty"`
+
+	// Position among the chat's events. Messages can arrive out of order
+	// when both users are on different servers; sort by Seq where set.
+	Seq int64 `json:"seq,omitempty"`
+}
+
+// ServerTypingMsg relays the partner's typing indicator to the client.
+type ServerTypingMsg struct {
+	Type     string `json:"type"`
+	IsTyping bool   `json:"is_typing"`
+}
+
+// ServerChatMetaMsg relays the partner's chat metadata to the client.
+type ServerChatMetaMsg struct {
+	Type       string `json:"type"`
+	Icebreaker string `json:"icebreaker,omitempty"`
+	Mood       string `json:"mood,omitempty"`
+}
+
+// ReactionMsg relays the partner's reaction to one of the client's own
+// messages. An empty Emoji means the partner cleared their reaction.
+type ReactionMsg struct {
+	Type      string `json:"type"`
+	MessageID string `json:"message_id"`
+	Emoji     string `json:"emoji"`
+}
+
+// AppealDecisionMsg tells a user that a moderator decided their ban appeal.
+// It is sent after set_fingerprint on the first connection following the
+// decision. Decision is "lifted" or "upheld".
+type AppealDecisionMsg struct {
+	Type     string `json:"type"`
+	AppealID int64  `json:"appeal_id"`
+	Decision string `json:"decision"`
+	Note     string `json:"note,omitempty"`
+}
+
+// PartnerCardMsg relays the profile card the partner chose to share.
+type PartnerCardMsg struct {
+	Type     string `json:"type"`
+	Nickname string `json:"nickname,omitempty"`
+	Pronouns string `json:"pronouns,omitempty"`
+	About    string `json:"about,omitempty"`
+}
+
+// TranscriptEntry is one message in an exported transcript. From is "you" or
+// "partner" relative to the recipient.
+type TranscriptEntry struct {
+	From string `json:"from"`
+	Text string `json:"text"`
+	Ts   int64  `json:"ts"`
+}
+
+// TranscriptMsg carries the conversation so far, oldest message first.
+type TranscriptMsg struct {
+	Type     string            `json:"type"`
+	ChatID   string            `json:"chat_id"`
+	Messages []TranscriptEntry `json:"messages"`
+}
+
+// TranscriptPendingMsg tells the requester that the transcript will be sent
+// once the partner consents.
+type TranscriptPendingMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+}
+
+// TranscriptRequestedMsg tells a client that its partner asked to export the
+// chat. The client consents by sending request_transcript itself.
+type TranscriptRequestedMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+}
+
+// BlockConfirmedMsg acknowledges a block. ExpiresAt is the Unix time the
+// block lapses.
+type BlockConfirmedMsg struct {
+	Type      string `json:"type"`
+	ChatID    string `json:"chat_id"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// PartnerLeftMsg is sent by the server when the chat partner has disconnected
+// or ended the chat. Reason is set when the server ended the chat itself:
+// "inactivity", or "moderated" when a moderator terminated it.
+type PartnerLeftMsg struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ChatSummaryMsg is sent to both users when a chat ends, after partner_left
+// for the user who did not end it. Duration is in seconds from both users
+// accepting to the end; message counts are from the recipient's side.
+type ChatSummaryMsg struct {
+	Type             string   `json:"type"`
+	ChatID           string   `json:"chat_id"`
+	Duration         int      `json:"duration"`
+	MessagesSent     int      `json:"messages_sent"`
+	MessagesReceived int      `json:"messages_received"`
+	SharedInterests  []string `json:"shared_interests"`
+}
+
+// RematchRequestedMsg tells a user that their partner from ChatID wants to
+// chat again; answer with rematch_accept.
+type RematchRequestedMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+}
+
+// InactivityWarningMsg is sent to both users when a chat has been silent for
+// a while. The chat is ended at EndsAt (unix time) unless a message is sent.
+type InactivityWarningMsg struct {
+	Type   string `json:"type"`
+	ChatID string `json:"chat_id"`
+	EndsAt int64  `json:"ends_at"`
+}
+
+// ChatGapMsg warns that Missing events of the chat, such as partner
+// messages, were lost in transit and will not be delivered.
+type ChatGapMsg struct {
+	Type    string `json:"type"`
+	ChatID  string `json:"chat_id"`
+	Missing int    `json:"missing"`
+}
+
+// RateLimitedMsg is sent by the server when the client has been rate-limited.
+// Limit names the exceeded limit where more than one applies, e.g. "message"
+// (count) or "message_bytes" (text volume).
+type RateLimitedMsg struct {
+	Type       string `json:"type"`
+	RetryAfter int    `json:"retry_after"`
+	Limit      string `json:"limit,omitempty"`
+}
+
+// ServiceUnavailableMsg is sent in response to find_match while matchmaking
+// is closed (scheduled hours, maintenance, or capacity). ReopenAt is the unix
+// time at which the client may try again. It is omitted for
+// "shutting_down": matchmaking does not reopen on this server, and the
+// client should reconnect to another one.
+type ServiceUnavailableMsg struct {
+	Type     string `json:"type"`
+	Reason   string `json:"reason"`
+	ReopenAt int64  `json:"reopen_at,omitempty"`
+}
+
+// ServerShutdownMsg is sent when the server starts draining for shutdown,
+// and again periodically to chatting clients. The server closes the
+// connection with close code 1001 at Deadline (unix time); clients should
+// warn the user and reconnect, which reaches another instance.
+type ServerShutdownMsg struct {
+	Type             string `json:"type"`
+	Deadline         int64  `json:"deadline"`
+	SecondsRemaining int    `json:"seconds_remaining"`
+}
+
+// WaitingRoomMsg is sent instead of session_created while the server is at
+// its connection limit, and again every few seconds while the client waits.
+// Position counts from 1. session_created follows once the client is
+// admitted; frames the client sends in the meantime are handled then.
+type WaitingRoomMsg struct {
+	Type     string `json:"type"`
+	Position int    `json:"position"`
+	Waiting  int    `json:"waiting"` // clients in the waiting room, including this one
+}
+
+// MaintenanceMsg is sent after session_created while maintenance mode is on,
+// and to every connected client when it is switched on or off. ETA is the
+// unix time the maintenance is expected to end, or 0 if unknown. Chats in
+// progress continue; find_match is refused until Active is false.
+type MaintenanceMsg struct {
+	Type    string `json:"type"`
+	Active  bool   `json:"active"`
+	Message string `json:"message,omitempty"`
+	ETA     int64  `json:"eta,omitempty"`
+}
+
+// BannedMsg is sent by the server when the client has been banned.
+type BannedMsg struct {
+	Type     string `json:"type"`
+	Duration int    `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+// ErrorMsg is sent by the server to communicate an error condition. Build
+// it with NewError so Category, Status and Retryable match the code.
+type ErrorMsg struct {
+	Type    string    `json:"type"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+
+	// Category, Status and Retryable are the code's ErrorInfo, so clients
+	// can react without knowing every code.
+	Category  string `json:"category,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	Retryable bool   `json:"retryable"`
+
+	// Rejected lists the offending tags of an invalid_interests error.
+	Rejected []RejectedInterest `json:"rejected,omitempty"`
+
+	// Reason and Limits detail an invalid_message error: why the text was
+	// refused ("empty", "blank", "too_many_chars", ...) and the limits it
+	// must fit, the same as in ConfigMsg. For message_blocked and muted,
+	// Reason is the content filter category that was blocked ("word",
+	// "phrase", "url", ...), or "strict_policy" for language only the
+	// chat's strict content policy refuses.
+	Reason string         `json:"reason,omitempty"`
+	Limits *MessageLimits `json:"limits,omitempty"`
+
+	// RetryAfter is the number of seconds until a muted session may send
+	// messages again.
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// MessageLimits are the limits on chat message text. MaxGraphemes is 0 when
+// not limited.
+type MessageLimits struct {
+	MaxChars     int `json:"max_chars"`
+	MaxGraphemes int `json:"max_graphemes,omitempty"`
+	MaxBytes     int `json:"max_bytes"`
+}
+
+// MessageRetractedMsg is sent by the server when a message previously
+// delivered from the partner was flagged by async moderation. Clients should
+// hide or blur the message with the given ID.
+type MessageRetractedMsg struct {
+	Type      string `json:"type"`
+	MessageID string `json:"message_id"`
+	Reason    string `json:"reason"`
+}
+
+// PongMsg is the server's response to a client ping.
+type PongMsg struct {
+	Type string `json:"type"`
+}
+
+// ---------------------------------------------------------------------------
+// Helper functions
+// ---------------------------------------------------------------------------
+
+// ParseClientMessage parses raw WebSocket bytes into a typed client message.
+// It returns the message type string, the decoded struct, and any error
+// encountered during parsing. An error wrapping ErrUnknownType is returned
+// for unknown or server-only message types.
+func ParseClientMessage(data []byte) (string, interface{}, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return "", nil, fmt.Errorf("protocol: failed to parse message: %w", err)
+	}
+
+	var (
+		msg interface{}
+		err error
+	)
+
+	switch env.Type {
+	case TypeSetFingerprint:
+		var m SetFingerprintMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeClientInfo:
+		var m ClientInfoMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeFindMatch:
+		var m FindMatchMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeCancelMatch:
+		var m CancelMatchMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeAcceptMatch:
+		var m AcceptMatchMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeDeclineMatch:
+		var m DeclineMatchMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeMessage:
+		var m ChatMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeTyping:
+		var m TypingMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeChatMeta:
+		var m ChatMetaMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeShareCard:
+		var m ShareCardMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeReact:
+		var m ReactMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeRequestTranscript:
+		var m RequestTranscriptMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeEndChat:
+		var m EndChatMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeRematchRequest:
+		var m RematchRequestMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeRematchAccept:
+		var m RematchAcceptMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeReport:
+		var m ReportMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeBlock:
+		var m BlockMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypePing:
+		var m PingMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeResumeSession:
+		var m ResumeSessionMsg
+		err = json.Unmarshal(env.Raw, &m)
+		msg = m
+	case TypeBatch:
+		var m BatchMsg
+		if err = json.Unmarshal(env.Raw, &m); err == nil {
+			err = m.validate()
+		}
+		msg = m
+	default:
+		return env.Type, nil, fmt.Errorf("%w: %q", ErrUnknownType, env.Type)
+	}
+
+	if err != nil {
+		return env.Type, nil, fmt.Errorf("protocol: failed to decode %q payload: %w", env.Type, err)
+	}
+	return env.Type, msg, nil
+}
+
+// NewServerMessage creates a JSON-encoded byte slice for a server message.
+// The msgType is written into the payload under the "type" key. The payload
+// should be one of the server message structs, which declare Type as their
+// first field: the payload is marshaled once and the encoded type value is
+// replaced in place, so callers can leave Type empty. Any other payload that
+// encodes to a JSON object falls back to decoding it into a map and
+// re-encoding it with the type set.
+func NewServerMessage(msgType string, payload interface{}) ([]byte, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("protocol: failed to marshal payload: %w", err)
+	}
+	if out, ok := spliceType(raw, msgType); ok {
+		return out, nil
+	}
+	return injectType(raw, msgType)
+}
+
+// typeKey is how every server message struct's encoding begins.
+var typeKey = []byte(`{"type":`)
+
+// spliceType replaces the value of a leading "type" key in raw, or adds the
+// key to an empty object. json.Marshal writes each struct field once, so a
+// leading "type" key is the only one. It reports false for anything else.
+func spliceType(raw []byte, msgType string) ([]byte, bool) {
+	var rest []byte
+	switch {
+	case bytes.HasPrefix(raw, typeKey):
+		end := stringEnd(raw, len(typeKey))
+		if end < 0 {
+			return nil, false
+		}
+		rest = raw[end:]
+	case bytes.Equal(raw, []byte("{}")):
+		rest = raw[1:]
+	default:
+		return nil, false
+	}
+
+	quoted, err := json.Marshal(msgType)
+	if err != nil {
+		return nil, false
+	}
+	out := make([]byte, 0, len(typeKey)+len(quoted)+len(rest))
+	out = append(out, typeKey...)
+	out = append(out, quoted...)
+	return append(out, rest...), true
+}
+
+// stringEnd returns the index just past the JSON string starting at raw[i],
+// or -1 if raw[i] does not start a string.
+func stringEnd(raw []byte, i int) int {
+	if i >= len(raw) || raw[i] != '"' {
+		return -1
+	}
+	for i++; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// injectType sets "type" on an arbitrary JSON object by decoding it into a
+// map. It is the slow path for payloads spliceType cannot handle.
+func injectType(raw []byte, msgType string) ([]byte, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("protocol: failed to unmarshal payload into map: %w", err)
+	}
+	if m == nil {
+		return nil, fmt.Errorf("protocol: payload is not a JSON object")
+	}
+	quoted, err := json.Marshal(msgType)
+	if err != nil {
+		return nil, fmt.Errorf("protocol: failed to marshal server message: %w", err)
+	}
+	m["type"] = quoted
+
+	out, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("protocol: failed to marshal server message: %w", err)
+	}
+	return out, nil
+}
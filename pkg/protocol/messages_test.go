@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
 )
 
@@ -111,6 +112,76 @@ func TestNewServerMessage_MatchFound(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// Test: NewServerMessage for a retracted message
+// ---------------------------------------------------------------------------
+
+func TestNewServerMessage_MessageRetracted(t *testing.T) {
+	data, err := NewServerMessage(TypeMessageRetracted, MessageRetractedMsg{
+		MessageID: "msg-1",
+		Reason:    "blocked content",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+
+	if result["type"] != TypeMessageRetracted {
+		t.Errorf("expected type %q, got %v", TypeMessageRetracted, result["type"])
+	}
+	if result["message_id"] != "msg-1" {
+		t.Errorf("expected message_id %q, got %v", "msg-1", result["message_id"])
+	}
+	if result["reason"] != "blocked content" {
+		t.Errorf("expected reason %q, got %v", "blocked content", result["reason"])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Test: NewServerMessage sets the type however the payload carries it
+// ---------------------------------------------------------------------------
+
+func TestNewServerMessage_TypeField(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload interface{}
+		want    string
+	}{
+		{"empty Type", ServerChatMsg{From: "stranger", Text: "hi", Ts: 42},
+			`{"type":"message","from":"stranger","text":"hi","ts":42}`},
+		{"stale Type", &ServerChatMsg{Type: "typing", From: "stranger", Text: "a \"quoted\\\" text", Ts: 42},
+			`{"type":"message","from":"stranger","text":"a \"quoted\\\" text","ts":42}`},
+		{"no Type field", struct {
+			From string `json:"from"`
+		}{"you"}, `{"from":"you","type":"message"}`},
+		{"empty object", struct{}{}, `{"type":"message"}`},
+		{"map", map[string]interface{}{"type": "x", "ts": 1 << 60}, `{"ts":1152921504606846976,"type":"message"}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := NewServerMessage(TypeMessage, tc.payload)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(data) != tc.want {
+				t.Errorf("got %s, want %s", data, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewServerMessage_NotAnObject(t *testing.T) {
+	for _, payload := range []interface{}{nil, "text", []int{1}} {
+		if data, err := NewServerMessage(TypeMessage, payload); err == nil {
+			t.Errorf("payload %#v: expected an error, got %s", payload, data)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Test: Parsing an unknown message type returns an error
 // ---------------------------------------------------------------------------
@@ -119,8 +190,8 @@ func TestParseClientMessage_UnknownType(t *testing.T) {
 	input := []byte(`{"type":"unknown_type","data":"something"}`)
 
 	msgType, msg, err := ParseClientMessage(input)
-	if err == nil {
-		t.Fatal("expected an error for unknown message type, got nil")
+	if !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("expected ErrUnknownType for unknown message type, got %v", err)
 	}
 	if msg != nil {
 		t.Errorf("expected nil message for unknown type, got %v", msg)
@@ -250,6 +321,13 @@ func TestParseClientMessage_AllTypes(t *testing.T) {
 		{"end_chat", `{"type":"end_chat","chat_id":"id1"}`, TypeEndChat},
 		{"report", `{"type":"report","chat_id":"id1","reason":"spam"}`, TypeReport},
 		{"ping", `{"type":"ping"}`, TypePing},
+		{"resume_session", `{"type":"resume_session","previous_session_id":"old"}`, TypeResumeSession},
+		{"chat_meta", `{"type":"chat_meta","chat_id":"id1","mood":"😀"}`, TypeChatMeta},
+		{"request_transcript", `{"type":"request_transcript","chat_id":"id1"}`, TypeRequestTranscript},
+		{"block", `{"type":"block","chat_id":"id1"}`, TypeBlock},
+		{"share_card", `{"type":"share_card","chat_id":"id1","nickname":"Sam"}`, TypeShareCard},
+		{"react", `{"type":"react","chat_id":"id1","message_id":"m1","emoji":"👍"}`, TypeReact},
+		{"client_info", `{"type":"client_info","platform":"web","app_version":"1.2.0","locale":"en-US"}`, TypeClientInfo},
 	}
 
 	for _, tc := range cases {
@@ -267,3 +345,39 @@ func TestParseClientMessage_AllTypes(t *testing.T) {
 		})
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Benchmarks: encoding outbound server messages
+// ---------------------------------------------------------------------------
+
+func BenchmarkNewServerMessage_Chat(b *testing.B) {
+	payload := ServerChatMsg{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", From: "stranger", Text: "hey, how is your day going?", Ts: 1700000000000, Seq: 12}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewServerMessage(TypeMessage, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewServerMessage_MatchFound(b *testing.B) {
+	payload := MatchFoundMsg{ChatID: "0f8fad5b-d9cb-469f-a165-70867728950e", SharedInterests: []string{"music", "gaming", "anime"}, AcceptDeadline: 15}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewServerMessage(TypeMatchFound, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkNewServerMessage_Map measures the map fallback taken by payloads
+// without a leading "type" key, after their first marshal.
+func BenchmarkNewServerMessage_Map(b *testing.B) {
+	raw, _ := json.Marshal(ServerChatMsg{ID: "0f8fad5b-d9cb-469f-a165-70867728950e", From: "stranger", Text: "hey, how is your day going?", Ts: 1700000000000, Seq: 12})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := injectType(raw, TypeMessage); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
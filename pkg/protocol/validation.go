@@ -0,0 +1,119 @@
+package protocol
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxInterests      = 10 // interest tags per find_match
+	MaxInterestLength = 32 // characters per interest tag
+)
+
+// Reasons an interest tag is rejected, as reported in RejectedInterest.
+const (
+	InterestEmpty        = "empty"
+	InterestTooLong      = "too_long"
+	InterestInvalidChars = "invalid_characters"
+	InterestDuplicate    = "duplicate"
+	InterestOverLimit    = "over_limit" // valid, but past the first MaxInterests tags
+)
+
+// RejectedInterest names an interest tag that failed validation and why.
+// Tag is cut to MaxInterestLength characters so the error stays small.
+type RejectedInterest struct {
+	Tag    string `json:"tag"`
+	Reason string `json:"reason"`
+}
+
+// InterestsError lists the tags ValidateInterests rejected.
+type InterestsError struct {
+	Rejected []RejectedInterest
+}
+
+func (e *InterestsError) Error() string {
+	return fmt.Sprintf("protocol: %d invalid interest tags", len(e.Rejected))
+}
+
+// ValidateInterests checks a find_match interest list: at most MaxInterests
+// tags, each 1 to MaxInterestLength characters of lowercase letters, digits
+// and hyphens, without repeats. It returns an *InterestsError listing the
+// rejected tags, or nil if the list is valid.
+func ValidateInterests(tags []string) error {
+	valid, rejected := checkInterests(tags)
+	for _, tag := range valid[min(len(valid), MaxInterests):] {
+		rejected = reject(rejected, tag, InterestOverLimit)
+	}
+	if len(rejected) > 0 {
+		return &InterestsError{Rejected: rejected}
+	}
+	return nil
+}
+
+// SanitizeInterests returns the valid tags of an interest list, at most
+// MaxInterests of them, for consumers that must not trust their producer
+// to have called ValidateInterests.
+func SanitizeInterests(tags []string) []string {
+	valid, _ := checkInterests(tags)
+	return valid[:min(len(valid), MaxInterests)]
+}
+
+// checkInterests splits tags into the valid ones, in order, and the rest.
+func checkInterests(tags []string) (valid []string, rejected []RejectedInterest) {
+	seen := make(map[string]bool, min(len(tags), MaxInterests))
+	for _, tag := range tags {
+		reason := interestProblem(tag)
+		if reason == "" && seen[tag] {
+			reason = InterestDuplicate
+		}
+		if reason != "" {
+			rejected = reject(rejected, tag, reason)
+			continue
+		}
+		seen[tag] = true
+		valid = append(valid, tag)
+	}
+	return valid, rejected
+}
+
+// maxRejected caps how many rejected tags are reported, so a flood of bad
+// tags cannot produce a flood of errors.
+const maxRejected = 2 * MaxInterests
+
+// reject appends tag to rejected unless maxRejected is reached.
+func reject(rejected []RejectedInterest, tag, reason string) []RejectedInterest {
+	if len(rejected) >= maxRejected {
+		return rejected
+	}
+	return append(rejected, RejectedInterest{Tag: truncateTag(tag), Reason: reason})
+}
+
+// interestProblem returns why tag is not a valid interest, or "".
+func interestProblem(tag string) string {
+	if tag == "" {
+		return InterestEmpty
+	}
+	if len(tag) > MaxInterestLength*utf8.UTFMax || utf8.RuneCountInString(tag) > MaxInterestLength {
+		return InterestTooLong
+	}
+	for _, r := range tag {
+		if r == '-' || unicode.IsDigit(r) || (unicode.IsLetter(r) && !unicode.IsUpper(r)) {
+			continue
+		}
+		return InterestInvalidChars
+	}
+	return ""
+}
+
+// truncateTag cuts tag to MaxInterestLength characters.
+func truncateTag(tag string) string {
+	n := 0
+	for i := range tag {
+		if n == MaxInterestLength {
+			return tag[:i]
+		}
+		n++
+	}
+	return tag
+}
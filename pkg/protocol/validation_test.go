@@ -0,0 +1,66 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateInterests_Valid(t *testing.T) {
+	for _, tags := range [][]string{nil, {}, {"music", "tv-shows", "f1"}, {"música"}} {
+		if err := ValidateInterests(tags); err != nil {
+			t.Errorf("ValidateInterests(%q) = %v, want nil", tags, err)
+		}
+	}
+}
+
+func TestValidateInterests_ListsRejectedTags(t *testing.T) {
+	long := strings.Repeat("a", 10*1024)
+	err := ValidateInterests([]string{"music", "", long, "Music", "drop table;", "music"})
+
+	var ie *InterestsError
+	if !errors.As(err, &ie) {
+		t.Fatalf("expected *InterestsError, got %v", err)
+	}
+	want := []RejectedInterest{
+		{"", InterestEmpty},
+		{long[:MaxInterestLength], InterestTooLong},
+		{"Music", InterestInvalidChars},
+		{"drop table;", InterestInvalidChars},
+		{"music", InterestDuplicate},
+	}
+	if len(ie.Rejected) != len(want) {
+		t.Fatalf("rejected %v, want %v", ie.Rejected, want)
+	}
+	for i := range want {
+		if ie.Rejected[i] != want[i] {
+			t.Errorf("rejected[%d] = %+v, want %+v", i, ie.Rejected[i], want[i])
+		}
+	}
+}
+
+func TestValidateInterests_TooMany(t *testing.T) {
+	tags := make([]string, 500)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("tag-%d", i)
+	}
+	var ie *InterestsError
+	if err := ValidateInterests(tags); !errors.As(err, &ie) {
+		t.Fatalf("expected *InterestsError for %d tags, got %v", len(tags), err)
+	}
+	if len(ie.Rejected) != maxRejected || ie.Rejected[0].Reason != InterestOverLimit {
+		t.Errorf("rejected %d tags starting with %+v, want %d over_limit", len(ie.Rejected), ie.Rejected[0], maxRejected)
+	}
+}
+
+func TestSanitizeInterests(t *testing.T) {
+	tags := []string{"music", "BAD", "music"}
+	for i := 0; i < 2*MaxInterests; i++ {
+		tags = append(tags, "tag"+string(rune('a'+i)))
+	}
+	got := SanitizeInterests(tags)
+	if len(got) != MaxInterests || got[0] != "music" || got[1] != "taga" {
+		t.Errorf("SanitizeInterests = %q, want music then the first valid tags, %d in all", got, MaxInterests)
+	}
+}
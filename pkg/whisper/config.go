@@ -0,0 +1,104 @@
+package whisper
+
+import (
+	"time"
+
+	"github.com/whisper/chat-app/internal/ws"
+)
+
+// Config holds the server's tunables: listen address, worker pool, limits,
+// timeouts and TLS. Start from DefaultConfig.
+type Config struct {
+	ListenAddr      string          // address to listen on, e.g. ":8080"
+	WorkerPoolSize  int             // number of read-worker goroutines
+	DispatchQueue   int             // ready connections buffered for busy workers
+	OverloadPolicy  OverloadPolicy  // what to do when workers and queue are full
+	MaxConnections  int             // hard cap on total connections
+	WaitingRoomSize int             // connections held waiting for a slot at MaxConnections; 0 rejects them
+	ReadTimeout     time.Duration   // timeout for WebSocket read operations
+	WriteTimeout    time.Duration   // timeout for WebSocket write operations
+	MaxFrameSize    int64           // maximum allowed WebSocket frame payload in bytes
+	IdleTimeout     time.Duration   // close idle sessions sending nothing but pings this long; 0 disables
+	MaxFrameRate    int             // inbound data frames per second per connection; 0 disables
+	DrainTimeout    time.Duration   // how long Shutdown waits for clients to leave before force-closing them
+	DebugToken      string          // bearer token for /debug/pprof/ and /debug/runtime; empty disables them
+	Heartbeat       HeartbeatConfig // ping interval and timeout
+
+	// InternalAddr, when set, moves /health, /metrics and /debug/ to a
+	// second plain-HTTP listener, e.g. "127.0.0.1:9090", leaving only /ws
+	// and HandleHTTP routes on ListenAddr. Empty serves everything on
+	// ListenAddr.
+	InternalAddr string
+
+	// Native TLS, for deployments without a terminating proxy. Set either
+	// the certificate and key files or AutocertDomains.
+	TLSCertFile      string   // PEM certificate chain
+	TLSKeyFile       string   // PEM private key
+	AutocertDomains  []string // hosts to obtain Let's Encrypt certificates for
+	AutocertCacheDir string   // where autocert stores certificates across restarts
+	HTTPRedirectAddr string   // optional plain-HTTP listener redirecting to https, e.g. ":80"
+}
+
+// HeartbeatConfig sets how often clients are pinged and how long a missing
+// pong is tolerated; see Config.Heartbeat.
+type HeartbeatConfig struct {
+	Interval time.Duration // how often to ping
+	Timeout  time.Duration // max time to wait for activity after a ping
+}
+
+// OverloadPolicy decides what happens to frames while every read worker is
+// busy; see Config.OverloadPolicy.
+type OverloadPolicy string
+
+const (
+	OverloadBlock OverloadPolicy = "block" // wait for a worker; no frame is lost
+	OverloadDrop  OverloadPolicy = "drop"  // discard the frame and answer server_busy
+)
+
+// DefaultConfig returns the production defaults used by cmd/wsserver.
+func DefaultConfig() Config {
+	d := ws.DefaultServerConfig()
+	return Config{
+		ListenAddr:      d.ListenAddr,
+		WorkerPoolSize:  d.WorkerPoolSize,
+		DispatchQueue:   d.DispatchQueue,
+		OverloadPolicy:  OverloadPolicy(d.OverloadPolicy),
+		MaxConnections:  d.MaxConnections,
+		WaitingRoomSize: d.WaitingRoomSize,
+		ReadTimeout:     d.ReadTimeout,
+		WriteTimeout:    d.WriteTimeout,
+		MaxFrameSize:    d.MaxFrameSize,
+		IdleTimeout:     d.IdleTimeout,
+		MaxFrameRate:    d.MaxFrameRate,
+		DrainTimeout:    d.DrainTimeout,
+		DebugToken:      d.DebugToken,
+		Heartbeat:       HeartbeatConfig{Interval: d.Heartbeat.Interval, Timeout: d.Heartbeat.Timeout},
+		InternalAddr:    d.InternalAddr,
+	}
+}
+
+// server converts c to the configuration of the underlying server.
+func (c Config) server() ws.ServerConfig {
+	return ws.ServerConfig{
+		ListenAddr:       c.ListenAddr,
+		WorkerPoolSize:   c.WorkerPoolSize,
+		DispatchQueue:    c.DispatchQueue,
+		OverloadPolicy:   ws.OverloadPolicy(c.OverloadPolicy),
+		MaxConnections:   c.MaxConnections,
+		WaitingRoomSize:  c.WaitingRoomSize,
+		ReadTimeout:      c.ReadTimeout,
+		WriteTimeout:     c.WriteTimeout,
+		MaxFrameSize:     c.MaxFrameSize,
+		IdleTimeout:      c.IdleTimeout,
+		MaxFrameRate:     c.MaxFrameRate,
+		DrainTimeout:     c.DrainTimeout,
+		DebugToken:       c.DebugToken,
+		Heartbeat:        ws.HeartbeatConfig{Interval: c.Heartbeat.Interval, Timeout: c.Heartbeat.Timeout},
+		InternalAddr:     c.InternalAddr,
+		TLSCertFile:      c.TLSCertFile,
+		TLSKeyFile:       c.TLSKeyFile,
+		AutocertDomains:  c.AutocertDomains,
+		AutocertCacheDir: c.AutocertCacheDir,
+		HTTPRedirectAddr: c.HTTPRedirectAddr,
+	}
+}
@@ -0,0 +1,45 @@
+package whisper
+
+import (
+	"net"
+	"time"
+
+	"github.com/whisper/chat-app/internal/ws"
+)
+
+// Conn is one client connection. Its ID is the session ID sent to the client
+// in session_created.
+type Conn struct {
+	ID        string
+	CreatedAt time.Time // when the connection was established
+
+	conn *ws.Connection
+}
+
+// newConn wraps a connection of the underlying server, or returns nil.
+func newConn(c *ws.Connection) *Conn {
+	if c == nil {
+		return nil
+	}
+	return &Conn{ID: c.ID, CreatedAt: c.CreatedAt, conn: c}
+}
+
+// RemoteAddr returns the client's network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.Conn.RemoteAddr()
+}
+
+// LastActive returns when the client last sent a message other than a ping.
+func (c *Conn) LastActive() time.Time {
+	return c.conn.LastActive()
+}
+
+// Close closes the connection without a close frame. The server removes it
+// and calls the WithOnDisconnect function as for any lost connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Handler handles one client message of a registered type. It runs on a read
+// worker, so it should hand slow work off rather than block.
+type Handler func(c *Conn, msg interface{})
@@ -0,0 +1,64 @@
+package whisper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/whisper/chat-app/internal/session"
+)
+
+// Session is the Redis record of a connection, see WithRedisSessions.
+type Session struct {
+	ID         string
+	Status     string // idle, matching or chatting; the application may set its own
+	Server     string // serverName of the instance the client is connected to
+	CreatedAt  time.Time
+	LastActive time.Time
+	DetachedAt time.Time // when the connection was lost, see WithHandoff; zero while connected
+}
+
+// SessionStore reads and updates the sessions of every instance sharing the
+// Redis of WithRedisSessions.
+type SessionStore struct {
+	store *session.Store
+}
+
+// Get returns the session with the given ID, or nil if there is none.
+func (s *SessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	sess, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("whisper: get session: %w", err)
+	}
+	if sess == nil {
+		return nil, nil
+	}
+	out := &Session{
+		ID:         sess.ID,
+		Status:     sess.Status,
+		Server:     sess.Server,
+		CreatedAt:  time.Unix(sess.CreatedAt, 0),
+		LastActive: time.Unix(sess.LastActive, 0),
+	}
+	if sess.DetachedAt != 0 {
+		out.DetachedAt = time.Unix(sess.DetachedAt, 0)
+	}
+	return out, nil
+}
+
+// SetStatus sets the status of the session with the given ID.
+func (s *SessionStore) SetStatus(ctx context.Context, id, status string) error {
+	if err := s.store.UpdateStatus(ctx, id, status); err != nil {
+		return fmt.Errorf("whisper: set session status: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the session with the given ID. The connection stays open;
+// close it with Conn.Close.
+func (s *SessionStore) Delete(ctx context.Context, id string) error {
+	if err := s.store.Delete(ctx, id); err != nil {
+		return fmt.Errorf("whisper: delete session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Package whisper embeds the Whisper WebSocket server in another Go binary.
+// It wires the epoll-based server, the message dispatcher and, optionally,
+// the Redis session store together behind one constructor:
+//
+//	srv, err := whisper.NewServer(
+//		whisper.WithAddr(":8080"),
+//		whisper.WithRedisSessions("localhost:6379", "chat-1"),
+//	)
+//	srv.Handle(protocol.TypeMessage, func(c *whisper.Conn, msg interface{}) {
+//		m := msg.(protocol.ChatMsg)
+//		srv.Send(c.ID, protocol.TypeMessage, protocol.ServerChatMsg{From: "echo", Text: m.Text})
+//	})
+//	go srv.Start()
+//	defer srv.Shutdown()
+//
+// Handlers receive the message structs of package protocol, or the raw JSON
+// for a type that package doesn't define, so an application can add its own
+// message types. With WithRedisSessions, Sessions gives access to the
+// session records shared by every instance, and WithHandoff lets clients
+// resume their session after a dropped connection. Pings, the session
+// handshake, heartbeats, frame limits and graceful shutdown work as in the
+// full service.
+//
+// Matching, chat relay and moderation are not part of this package;
+// cmd/wsserver is the reference wiring of the complete service on top of the
+// same server.
+package whisper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/ws"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// Option configures a Server created by NewServer.
+type Option func(*options)
+
+type options struct {
+	config       Config
+	redisAddr    string
+	serverName   string
+	sessions     *session.Store // set by tests instead of redisAddr
+	handoff      time.Duration
+	onConnect    func(c *Conn)
+	onDisconnect func(connID string)
+}
+
+// WithConfig replaces the whole configuration. Options applied after it
+// still change individual fields.
+func WithConfig(config Config) Option {
+	return func(o *options) { o.config = config }
+}
+
+// WithAddr sets the address the server listens on, e.g. ":8080".
+func WithAddr(addr string) Option {
+	return func(o *options) { o.config.ListenAddr = addr }
+}
+
+// WithMaxConnections caps the number of concurrent connections.
+func WithMaxConnections(n int) Option {
+	return func(o *options) { o.config.MaxConnections = n }
+}
+
+// WithRedisSessions stores a session in Redis for every connection, under
+// serverName, so other instances can find it. Without it sessions live only
+// in memory.
+func WithRedisSessions(addr, serverName string) Option {
+	return func(o *options) { o.redisAddr, o.serverName = addr, serverName }
+}
+
+// WithHandoff keeps the session of a lost connection for window instead of
+// deleting it, so the client can resume it on any instance by reconnecting
+// to /ws?resume=<session_id>&token=<session_token>; the token comes in
+// session_created. POST /api/session/heartbeat {"session_id",
+// "session_token"} keeps a detached session alive for another window. A
+// kept session skips the WithOnDisconnect function. It requires
+// WithRedisSessions.
+func WithHandoff(window time.Duration) Option {
+	return func(o *options) { o.handoff = window }
+}
+
+// WithOnConnect registers fn to be called for every new connection, after
+// session_created has been sent.
+func WithOnConnect(fn func(c *Conn)) Option {
+	return func(o *options) { o.onConnect = fn }
+}
+
+// WithOnDisconnect registers fn to be called when a connection is removed,
+// before its Redis session is deleted. It is not called for a session kept
+// by WithHandoff.
+func WithOnDisconnect(fn func(connID string)) Option {
+	return func(o *options) { o.onDisconnect = fn }
+}
+
+// Server is an embeddable Whisper WebSocket server.
+type Server struct {
+	ws         *ws.Server
+	dispatcher *ws.MessageDispatcher
+	sessions   *SessionStore // nil without WithRedisSessions
+}
+
+// NewServer creates a Server from DefaultConfig and the given options. It
+// connects to Redis when WithRedisSessions is given, and fails if Redis is
+// unreachable.
+func NewServer(opts ...Option) (*Server, error) {
+	o := options{config: DefaultConfig()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	sessions := o.sessions
+	if o.redisAddr != "" {
+		var err error
+		if sessions, err = session.NewStore(o.redisAddr, o.serverName); err != nil {
+			return nil, fmt.Errorf("whisper: %w", err)
+		}
+	}
+
+	if o.handoff > 0 && sessions == nil {
+		return nil, errors.New("whisper: WithHandoff requires WithRedisSessions")
+	}
+
+	dispatcher := ws.NewMessageDispatcher(nil)
+	server := ws.NewServer(o.config.server(), sessions, dispatcher.Dispatch)
+	dispatcher.SetServer(server)
+	if o.onConnect != nil {
+		server.SetOnConnect(func(c *ws.Connection) { o.onConnect(newConn(c)) })
+	}
+	if o.onDisconnect != nil {
+		server.SetOnDisconnect(o.onDisconnect)
+	}
+	if o.handoff > 0 {
+		server.SetHandoff(handoff(sessions, o.handoff))
+		server.Handle("/api/session/heartbeat", session.NewHeartbeatHandler(sessions, o.handoff))
+	}
+	s := &Server{ws: server, dispatcher: dispatcher}
+	if sessions != nil {
+		s.sessions = &SessionStore{store: sessions}
+	}
+	return s, nil
+}
+
+// handoff detaches the session of every lost connection for window and
+// lets a new connection claim it with the session's token.
+func handoff(sessions *session.Store, window time.Duration) *ws.Handoff {
+	return &ws.Handoff{
+		Detach: func(sid string) bool {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			if sess, err := sessions.Get(ctx, sid); err != nil || sess == nil {
+				return false
+			}
+			if err := sessions.Detach(ctx, sid, window); err != nil {
+				log.Printf("whisper: detach session %s: %v", sid, err)
+				return false
+			}
+			return true
+		},
+		Claim: func(ctx context.Context, sid, token string) bool {
+			_, err := sessions.Attach(ctx, sid, token)
+			return err == nil
+		},
+	}
+}
+
+// Handle registers h for client messages of msgType, replacing any earlier
+// handler. It must be called before Start. A message of a type without a
+// handler is answered with an error.
+func (s *Server) Handle(msgType string, h Handler) {
+	s.dispatcher.Register(msgType, func(c *ws.Connection, msg interface{}) {
+		h(newConn(c), msg)
+	})
+}
+
+// HandleHTTP registers an HTTP handler next to /ws on the public listener.
+// It must be called before Start.
+func (s *Server) HandleHTTP(pattern string, h http.Handler) {
+	s.ws.Handle(pattern, h)
+}
+
+// Send encodes payload as a server message of msgType (see
+// protocol.NewServerMessage) and writes it to the connection connID.
+func (s *Server) Send(connID, msgType string, payload interface{}) error {
+	data, err := protocol.NewServerMessage(msgType, payload)
+	if err != nil {
+		return err
+	}
+	return s.ws.SendMessage(connID, data)
+}
+
+// Broadcast encodes payload as a server message of msgType and writes it to
+// every connection.
+func (s *Server) Broadcast(msgType string, payload interface{}) error {
+	data, err := protocol.NewServerMessage(msgType, payload)
+	if err != nil {
+		return err
+	}
+	s.ws.Broadcast(data)
+	return nil
+}
+
+// Conn returns the connection with the given ID, or nil if it is not
+// connected to this server.
+func (s *Server) Conn(connID string) *Conn {
+	return newConn(s.ws.Connections().Get(connID))
+}
+
+// Sessions returns the Redis session store, or nil without
+// WithRedisSessions.
+func (s *Server) Sessions() *SessionStore {
+	return s.sessions
+}
+
+// ConnectionCount returns the number of connections to this server.
+func (s *Server) ConnectionCount() int {
+	return s.ws.Connections().Count()
+}
+
+// Start begins accepting connections and blocks until Shutdown, returning
+// nil, or until the listener fails.
+func (s *Server) Start() error {
+	return s.ws.Start()
+}
+
+// Shutdown stops accepting connections, tells clients the server is going
+// away and waits up to Config.DrainTimeout for them to leave before closing
+// the rest. It then closes the Redis connection of WithRedisSessions.
+func (s *Server) Shutdown() error {
+	err := s.ws.Shutdown()
+	if s.sessions != nil {
+		if cerr := s.sessions.store.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
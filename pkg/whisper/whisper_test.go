@@ -0,0 +1,275 @@
+package whisper
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"github.com/whisper/chat-app/internal/session"
+	"github.com/whisper/chat-app/internal/testutil"
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// freeAddr returns a loopback address with a port that was free a moment ago.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// startServer starts srv and stops it when the test ends.
+func startServer(t *testing.T, srv *Server) {
+	t.Helper()
+	errs := make(chan error, 1)
+	go func() { errs <- srv.Start() }()
+	t.Cleanup(func() {
+		srv.Shutdown()
+		if err := <-errs; err != nil {
+			t.Errorf("Start returned %v", err)
+		}
+	})
+}
+
+// bufferedConn reads what the dialer buffered after the handshake before
+// reading from the connection.
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (b bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// dial connects to addr, retrying while the server starts listening.
+func dial(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	return dialPath(t, addr, "/ws")
+}
+
+// dialPath is dial with the request path and query, e.g. to resume.
+func dialPath(t *testing.T, addr, path string) net.Conn {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, br, _, err := ws.Dial(context.Background(), "ws://"+addr+path)
+		if err == nil {
+			t.Cleanup(func() { conn.Close() })
+			if br != nil {
+				return bufferedConn{Conn: conn, r: io.MultiReader(br, conn)}
+			}
+			return conn
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dial %s: %v", addr, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// read reads the next server message into v.
+func read(t *testing.T, conn net.Conn, v interface{}) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	data, err := wsutil.ReadServerText(conn)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+}
+
+func TestNewServer_Options(t *testing.T) {
+	config := DefaultConfig()
+	config.WorkerPoolSize = 4
+	srv, err := NewServer(WithConfig(config), WithAddr(":9999"), WithMaxConnections(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.ConnectionCount() != 0 || srv.Conn("nobody") != nil {
+		t.Error("new server has connections")
+	}
+
+	if _, err := NewServer(WithRedisSessions("127.0.0.1:1", "test")); err == nil {
+		t.Error("expected an error for unreachable Redis")
+	}
+}
+
+func TestServer_HandlesCustomAndProtocolTypes(t *testing.T) {
+	addr := freeAddr(t)
+	config := DefaultConfig()
+	config.WorkerPoolSize = 2
+	config.DrainTimeout = 0
+	connected := make(chan string, 1)
+	srv, err := NewServer(WithConfig(config), WithAddr(addr), WithOnConnect(func(c *Conn) {
+		connected <- c.ID
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type voteMsg struct {
+		Type   string `json:"type"`
+		Option int    `json:"option"`
+	}
+	srv.Handle("poll_vote", func(c *Conn, msg interface{}) {
+		var vote voteMsg
+		if err := json.Unmarshal(msg.(json.RawMessage), &vote); err != nil {
+			t.Errorf("custom message: %v", err)
+		}
+		srv.Send(c.ID, "poll_result", vote)
+	})
+	srv.Handle(protocol.TypeMessage, func(c *Conn, msg interface{}) {
+		srv.Send(c.ID, protocol.TypeMessage, protocol.ServerChatMsg{From: "echo", Text: msg.(protocol.ChatMsg).Text})
+	})
+	startServer(t, srv)
+
+	conn := dial(t, addr)
+	var created protocol.SessionCreatedMsg
+	read(t, conn, &created)
+	if created.Type != protocol.TypeSessionCreated || created.SessionID == "" {
+		t.Fatalf("got %+v, want session_created", created)
+	}
+	if id := <-connected; id != created.SessionID || srv.Conn(id) == nil {
+		t.Errorf("OnConnect got %q, want the session %q", id, created.SessionID)
+	}
+
+	if err := wsutil.WriteClientText(conn, []byte(`{"type":"poll_vote","option":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	var result voteMsg
+	read(t, conn, &result)
+	if result.Type != "poll_result" || result.Option != 2 {
+		t.Errorf("got %+v, want poll_result for option 2", result)
+	}
+
+	if err := wsutil.WriteClientText(conn, []byte(`{"type":"message","chat_id":"c","text":"hi"}`)); err != nil {
+		t.Fatal(err)
+	}
+	var echo protocol.ServerChatMsg
+	read(t, conn, &echo)
+	if echo.Type != protocol.TypeMessage || echo.Text != "hi" {
+		t.Errorf("got %+v, want the message echoed", echo)
+	}
+}
+
+func TestServer_Sessions(t *testing.T) {
+	rdb := testutil.Redis(t)
+	addr := freeAddr(t)
+	config := DefaultConfig()
+	config.WorkerPoolSize = 2
+	config.DrainTimeout = 0
+	srv, err := NewServer(WithConfig(config), WithAddr(addr), func(o *options) {
+		o.sessions = session.NewStoreWithClient(rdb, "chat-1")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- srv.Start() }()
+
+	conn := dial(t, addr)
+	var created protocol.SessionCreatedMsg
+	read(t, conn, &created)
+
+	ctx := context.Background()
+	sess, err := srv.Sessions().Get(ctx, created.SessionID)
+	if err != nil || sess == nil {
+		t.Fatalf("Get = %+v, %v; want the connection's session", sess, err)
+	}
+	if sess.ID != created.SessionID || sess.Server != "chat-1" || sess.CreatedAt.IsZero() || !sess.DetachedAt.IsZero() {
+		t.Errorf("session = %+v", sess)
+	}
+	if err := srv.Sessions().SetStatus(ctx, sess.ID, "in_lobby"); err != nil {
+		t.Fatal(err)
+	}
+	if sess, _ := srv.Sessions().Get(ctx, sess.ID); sess == nil || sess.Status != "in_lobby" {
+		t.Errorf("after SetStatus: %+v", sess)
+	}
+	if sess, err := srv.Sessions().Get(ctx, "nobody"); sess != nil || err != nil {
+		t.Errorf("Get(nobody) = %+v, %v; want nil, nil", sess, err)
+	}
+
+	if err := srv.Shutdown(); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Start returned %v", err)
+	}
+	if err := rdb.Ping(ctx).Err(); err == nil {
+		t.Error("Shutdown left the session store's Redis connection open")
+	}
+}
+
+func TestServer_Handoff(t *testing.T) {
+	rdb := testutil.Redis(t)
+	addr := freeAddr(t)
+	config := DefaultConfig()
+	config.WorkerPoolSize = 2
+	config.DrainTimeout = 0
+	disconnected := make(chan string, 1)
+	srv, err := NewServer(WithConfig(config), WithAddr(addr), WithHandoff(time.Minute),
+		WithOnDisconnect(func(id string) { disconnected <- id }),
+		func(o *options) { o.sessions = session.NewStoreWithClient(rdb, "chat-1") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	startServer(t, srv)
+
+	conn := dial(t, addr)
+	var created protocol.SessionCreatedMsg
+	read(t, conn, &created)
+	conn.Close()
+
+	ctx := context.Background()
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.ConnectionCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	sess, err := srv.Sessions().Get(ctx, created.SessionID)
+	if err != nil || sess == nil || sess.DetachedAt.IsZero() {
+		t.Fatalf("after the drop: session = %+v, %v; want it detached", sess, err)
+	}
+	select {
+	case id := <-disconnected:
+		t.Errorf("disconnect callback called for detached session %s", id)
+	default:
+	}
+
+	resumed := dialPath(t, addr, "/ws?resume="+created.SessionID+"&token="+created.SessionToken)
+	var again protocol.SessionCreatedMsg
+	read(t, resumed, &again)
+	if !again.Resumed || again.SessionID != created.SessionID {
+		t.Errorf("session_created = %+v, want %s resumed", again, created.SessionID)
+	}
+	if sess, _ := srv.Sessions().Get(ctx, created.SessionID); sess == nil || !sess.DetachedAt.IsZero() {
+		t.Errorf("after the resume: session = %+v, want it attached", sess)
+	}
+}
+
+func TestServer_HandoffRequiresSessions(t *testing.T) {
+	if _, err := NewServer(WithHandoff(time.Minute)); err == nil {
+		t.Error("NewServer accepted WithHandoff without WithRedisSessions")
+	}
+}
+
+func TestServer_NoSessions(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Sessions() != nil {
+		t.Error("Sessions is not nil without WithRedisSessions")
+	}
+}
@@ -0,0 +1,915 @@
+// Package whisperclient is a Go client for the Whisper chat WebSocket
+// protocol. It completes the session handshake, keeps the connection alive
+// with pings, reconnects (resuming a pending match) after the connection
+// drops, and exposes the match lifecycle as typed, context-aware calls:
+//
+//	c, err := whisperclient.Dial(ctx, "ws://localhost:8080/ws", whisperclient.Options{})
+//	c.OnMessage(func(m whisperclient.Message) { fmt.Println(m.Text) })
+//	match, err := c.FindMatch(ctx, []string{"music"})
+//	chat, err := c.Accept(ctx, match.ChatID)
+//	err = c.SendText(chat.ChatID, "hi")
+//
+// Active chats do not survive a reconnect: the server ends them when the
+// old connection goes away, and the partner receives partner_left.
+package whisperclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	mrand "math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// Options configures a Client. The zero value is usable.
+type Options struct {
+	// Fingerprint is sent with set_fingerprint after every handshake. A
+	// random one is generated when empty; it is kept across reconnects so
+	// the previous session can be resumed.
+	Fingerprint string
+
+	// PingInterval is how often an application-level ping is sent
+	// (default 25s). A negative value disables keepalive.
+	PingInterval time.Duration
+
+	// PongTimeout is how long after a missed ping the connection is
+	// considered dead and dropped (default 10s).
+	PongTimeout time.Duration
+
+	// DisableReconnect closes the client when the connection drops instead
+	// of reconnecting.
+	DisableReconnect bool
+
+	// ReconnectMinDelay and ReconnectMaxDelay bound the exponential backoff
+	// between reconnect attempts (defaults 500ms and 30s).
+	ReconnectMinDelay time.Duration
+	ReconnectMaxDelay time.Duration
+
+	// HandshakeTimeout bounds dialing and waiting for session_created
+	// (default 10s).
+	HandshakeTimeout time.Duration
+
+	// Language opts in to translation: it is sent with every find_match,
+	// and messages from a partner who declared a different language arrive
+	// with Message.Translated set, if the server has translation enabled.
+	Language string
+
+	// Nickname is sent with every accept_match as the name shown to the
+	// partner. The server generates one when empty or rejected.
+	Nickname string
+
+	// Platform, AppVersion and Locale describe the client to the server in
+	// client_info after every handshake, so operators can tell which app a
+	// session came from. Nothing is sent when all are empty.
+	Platform   string
+	AppVersion string
+	Locale     string
+
+	// Logf, if set, receives connection lifecycle logs.
+	Logf func(format string, args ...interface{})
+}
+
+func (o *Options) setDefaults() {
+	if o.Fingerprint == "" {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		o.Fingerprint = hex.EncodeToString(b)
+	}
+	if o.PingInterval == 0 {
+		o.PingInterval = 25 * time.Second
+	}
+	if o.PongTimeout <= 0 {
+		o.PongTimeout = 10 * time.Second
+	}
+	if o.ReconnectMinDelay <= 0 {
+		o.ReconnectMinDelay = 500 * time.Millisecond
+	}
+	if o.ReconnectMaxDelay <= 0 {
+		o.ReconnectMaxDelay = 30 * time.Second
+	}
+	if o.HandshakeTimeout <= 0 {
+		o.HandshakeTimeout = 10 * time.Second
+	}
+}
+
+// Client is a connection to a Whisper server. All methods are safe for
+// concurrent use. Handlers registered with On and the typed On* methods run
+// on the read goroutine and must not block.
+type Client struct {
+	url  string
+	opts Options
+
+	ctx    context.Context // cancelled by Close
+	cancel context.CancelFunc
+
+	writeMu sync.Mutex // serializes frames on the current connection
+
+	mu          sync.Mutex
+	conn        net.Conn
+	sessionID   string
+	chatID      string // active chat, if any
+	fatal       error  // set when the server banned us; stops reconnecting
+	limits      Limits // from the latest config message
+	waiters     []*waiter
+	handlers    map[string][]func(Event)
+	onReconnect []func(sessionID string)
+
+	lastSeen atomic.Int64 // Unix nanoseconds of the last frame received
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Dial connects to url (e.g. ws://localhost:8080/ws), waits for the session
+// to be created and sends the fingerprint. The returned client reconnects on
+// its own until Close is called, unless opts.DisableReconnect is set.
+func Dial(ctx context.Context, url string, opts Options) (*Client, error) {
+	opts.setDefaults()
+	c := &Client{
+		url:      url,
+		opts:     opts,
+		handlers: make(map[string][]func(Event)),
+		done:     make(chan struct{}),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	conn, sid, err := c.connect(ctx, "")
+	if err != nil {
+		c.cancel()
+		return nil, err
+	}
+	c.conn, c.sessionID = conn, sid
+	go c.run(conn)
+	return c, nil
+}
+
+// SessionID returns the current session ID. It changes after a reconnect.
+func (c *Client) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
+}
+
+// ChatID returns the active chat, or "" when not chatting.
+func (c *Client) ChatID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.chatID
+}
+
+// Limits returns the limits the server announced. They are refreshed when
+// the server's configuration changes.
+func (c *Client) Limits() Limits {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limits
+}
+
+// Done is closed when the client has shut down, either by Close or because
+// the connection dropped and could not (or must not) be re-established.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client shut down on its own, e.g. a *BannedError, or
+// nil.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fatal
+}
+
+// Close closes the connection and stops reconnecting. Pending calls return
+// ErrClosed. It is safe to call multiple times.
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.cancel()
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+		if conn != nil {
+			err = conn.Close()
+		}
+	})
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Match lifecycle
+// ---------------------------------------------------------------------------
+
+// FindMatch joins the matching queue and waits for a partner. It returns
+// ErrMatchTimeout if the server gives up, or a *RateLimitedError,
+// *UnavailableError or *BannedError if the request is refused. Interests
+// the server would not accept are reported up front as an
+// *InvalidInterestsError. If ctx ends first, the match request is cancelled.
+func (c *Client) FindMatch(ctx context.Context, interests []string) (*Match, error) {
+	if interests == nil {
+		interests = []string{}
+	}
+	var invalid *protocol.InterestsError
+	if err := protocol.ValidateInterests(interests); errors.As(err, &invalid) {
+		rejected := make([]RejectedInterest, len(invalid.Rejected))
+		for i, r := range invalid.Rejected {
+			rejected[i] = RejectedInterest{Tag: r.Tag, Reason: r.Reason}
+		}
+		return nil, &InvalidInterestsError{Rejected: rejected}
+	}
+	return c.awaitMatch(ctx, protocol.FindMatchMsg{Type: protocol.TypeFindMatch, Interests: interests, Language: c.opts.Language})
+}
+
+// ReRoll declines a proposed match and re-joins the queue with the same
+// interests, waiting for the next partner like FindMatch. A limited number
+// of re-rolls per hour are placed ahead of the queue; after that the server
+// queues normally.
+func (c *Client) ReRoll(ctx context.Context, chatID string) (*Match, error) {
+	return c.awaitMatch(ctx, protocol.DeclineMatchMsg{Type: protocol.TypeDeclineMatch, ChatID: chatID, ReRoll: true})
+}
+
+// awaitMatch sends a message that enters the matching queue and waits for
+// its outcome.
+func (c *Client) awaitMatch(ctx context.Context, msg interface{}) (*Match, error) {
+	ev, err := c.request(ctx, msg,
+		protocol.TypeMatchFound, protocol.TypeMatchTimeout,
+		protocol.TypeServiceUnavailable, protocol.TypeRateLimited, protocol.TypeBanned)
+	if err != nil {
+		if ctx.Err() != nil {
+			_ = c.CancelMatch()
+		}
+		return nil, err
+	}
+
+	switch ev.Type {
+	case protocol.TypeMatchFound:
+		var m protocol.MatchFoundMsg
+		if err := ev.Decode(&m); err != nil {
+			return nil, fmt.Errorf("whisperclient: decode match_found: %w", err)
+		}
+		return &Match{
+			ChatID:          m.ChatID,
+			SharedInterests: m.SharedInterests,
+			AcceptDeadline:  time.Now().Add(time.Duration(m.AcceptDeadline) * time.Second),
+			Tier:            m.MatchTier,
+			PartnerWait:     time.Duration(m.PartnerWait) * time.Second,
+			PartnerWaitTier: m.PartnerWaitTier,
+			PartnerRegion:   m.PartnerRegion,
+
+			PartnerOtherInterests: m.PartnerOtherInterests,
+		}, nil
+	case protocol.TypeMatchTimeout:
+		return nil, ErrMatchTimeout
+	default:
+		return nil, refusal(ev)
+	}
+}
+
+// CancelMatch leaves the matching queue.
+func (c *Client) CancelMatch() error {
+	return c.send(protocol.CancelMatchMsg{Type: protocol.TypeCancelMatch})
+}
+
+// Accept accepts a proposed match and waits until the partner accepts too.
+// It returns ErrMatchDeclined if the partner declines or the accept window
+// expires.
+func (c *Client) Accept(ctx context.Context, chatID string) (*Chat, error) {
+	ev, err := c.request(ctx, protocol.AcceptMatchMsg{Type: protocol.TypeAcceptMatch, ChatID: chatID, Nickname: c.opts.Nickname},
+		protocol.TypeMatchAccepted, protocol.TypeMatchDeclined)
+	if err != nil {
+		return nil, err
+	}
+	if ev.Type == protocol.TypeMatchDeclined {
+		return nil, ErrMatchDeclined
+	}
+
+	var m protocol.MatchAcceptedMsg
+	if err := ev.Decode(&m); err != nil {
+		return nil, fmt.Errorf("whisperclient: decode match_accepted: %w", err)
+	}
+	return &Chat{
+		ChatID:            m.ChatID,
+		Icebreaker:        m.Icebreaker,
+		Nickname:          m.Nickname,
+		AvatarSeed:        m.AvatarSeed,
+		PartnerNickname:   m.PartnerNickname,
+		PartnerAvatarSeed: m.PartnerAvatarSeed,
+	}, nil
+}
+
+// Decline declines a proposed match.
+func (c *Client) Decline(chatID string) error {
+	return c.send(protocol.DeclineMatchMsg{Type: protocol.TypeDeclineMatch, ChatID: chatID})
+}
+
+// ---------------------------------------------------------------------------
+// Chat
+// ---------------------------------------------------------------------------
+
+// SendText sends a chat message. Rejections (rate limits, blocked content)
+// arrive asynchronously through OnError and On(rate_limited).
+func (c *Client) SendText(chatID, text string) error {
+	return c.send(protocol.ChatMsg{Type: protocol.TypeMessage, ChatID: chatID, Text: text})
+}
+
+// SetTyping sends a typing indicator.
+func (c *Client) SetTyping(chatID string, typing bool) error {
+	return c.send(protocol.TypingMsg{Type: protocol.TypeTyping, ChatID: chatID, IsTyping: typing})
+}
+
+// React reacts to a partner's message, identified by Message.ID, with one of
+// the server's allowed emoji. An empty emoji clears the reaction.
+func (c *Client) React(chatID, messageID, emoji string) error {
+	return c.send(protocol.ReactMsg{Type: protocol.TypeReact, ChatID: chatID, MessageID: messageID, Emoji: emoji})
+}
+
+// EndChat leaves the chat.
+func (c *Client) EndChat(chatID string) error {
+	err := c.send(protocol.EndChatMsg{Type: protocol.TypeEndChat, ChatID: chatID})
+	c.mu.Lock()
+	if c.chatID == chatID {
+		c.chatID = ""
+	}
+	c.mu.Unlock()
+	return err
+}
+
+// Report reports the partner in chatID for abuse.
+func (c *Client) Report(chatID, reason string) error {
+	return c.send(protocol.ReportMsg{Type: protocol.TypeReport, ChatID: chatID, Reason: reason})
+}
+
+// ---------------------------------------------------------------------------
+// Events
+// ---------------------------------------------------------------------------
+
+// On registers a handler for every server frame of msgType (see the
+// protocol package for type names). Several handlers may be registered
+// for the same type; they run in registration order.
+func (c *Client) On(msgType string, handler func(Event)) {
+	c.mu.Lock()
+	c.handlers[msgType] = append(c.handlers[msgType], handler)
+	c.mu.Unlock()
+}
+
+// OnMessage registers a handler for chat messages from the partner.
+func (c *Client) OnMessage(handler func(Message)) {
+	c.On(protocol.TypeMessage, func(ev Event) {
+		var m protocol.ServerChatMsg
+		if ev.Decode(&m) != nil {
+			return
+		}
+		handler(Message{
+			ID:         m.ID,
+			ChatID:     c.ChatID(),
+			Text:       m.Text,
+			Time:       time.Unix(m.Ts, 0),
+			Translated: m.Translated,
+			Lang:       m.Lang,
+			Nickname:   m.Nickname,
+			AvatarSeed: m.AvatarSeed,
+			Seq:        m.Seq,
+		})
+	})
+}
+
+// OnTyping registers a handler for the partner's typing indicator.
+func (c *Client) OnTyping(handler func(typing bool)) {
+	c.On(protocol.TypeTyping, func(ev Event) {
+		var m protocol.ServerTypingMsg
+		if ev.Decode(&m) == nil {
+			handler(m.IsTyping)
+		}
+	})
+}
+
+// OnReaction registers a handler for the partner's reactions to messages
+// this client sent. emoji is empty when the partner cleared a reaction.
+func (c *Client) OnReaction(handler func(messageID, emoji string)) {
+	c.On(protocol.TypeReaction, func(ev Event) {
+		var m protocol.ReactionMsg
+		if ev.Decode(&m) == nil {
+			handler(m.MessageID, m.Emoji)
+		}
+	})
+}
+
+// OnPartnerLeft registers a handler called when the partner ends the chat
+// or disconnects. chatID is the chat that ended.
+func (c *Client) OnPartnerLeft(handler func(chatID string)) {
+	c.On(protocol.TypePartnerLeft, func(ev Event) {
+		var m struct {
+			ChatID string `json:"chat_id"`
+		}
+		_ = ev.Decode(&m)
+		handler(m.ChatID)
+	})
+}
+
+// OnChatSummary registers a handler called when a chat ends, whoever ended
+// it, with how the chat went.
+func (c *Client) OnChatSummary(handler func(ChatSummary)) {
+	c.On(protocol.TypeChatSummary, func(ev Event) {
+		var m protocol.ChatSummaryMsg
+		if ev.Decode(&m) != nil {
+			return
+		}
+		handler(ChatSummary{
+			ChatID:           m.ChatID,
+			Duration:         time.Duration(m.Duration) * time.Second,
+			MessagesSent:     m.MessagesSent,
+			MessagesReceived: m.MessagesReceived,
+			SharedInterests:  m.SharedInterests,
+		})
+	})
+}
+
+// OnQueueStatus registers a handler for queue position updates sent while
+// FindMatch is waiting.
+func (c *Client) OnQueueStatus(handler func(QueueStatus)) {
+	c.On(protocol.TypeMatchingStatus, func(ev Event) {
+		var m protocol.MatchingStatusMsg
+		if ev.Decode(&m) != nil {
+			return
+		}
+		handler(QueueStatus{
+			Position:      m.Position,
+			QueueSize:     m.QueueSize,
+			EstimatedWait: time.Duration(m.EstimatedWait) * time.Second,
+		})
+	})
+}
+
+// OnError registers a handler for error frames.
+func (c *Client) OnError(handler func(*ServerError)) {
+	c.On(protocol.TypeError, func(ev Event) {
+		var m protocol.ErrorMsg
+		if ev.Decode(&m) == nil {
+			handler(&ServerError{Code: m.Code, Message: m.Message, Category: m.Category, Retryable: m.Retryable})
+		}
+	})
+}
+
+// OnReconnect registers a handler called after the client reconnected and
+// completed the handshake with a new session ID.
+func (c *Client) OnReconnect(handler func(sessionID string)) {
+	c.mu.Lock()
+	c.onReconnect = append(c.onReconnect, handler)
+	c.mu.Unlock()
+}
+
+// WaitFor blocks until the server sends a frame of one of the given types,
+// ctx ends or the client is closed. Only frames received after the call
+// are considered.
+func (c *Client) WaitFor(ctx context.Context, types ...string) (Event, error) {
+	return c.await(ctx, c.expect(types...))
+}
+
+// ---------------------------------------------------------------------------
+// Waiters
+// ---------------------------------------------------------------------------
+
+// waiter receives the first frame matching one of its types.
+type waiter struct {
+	types map[string]bool
+	ch    chan Event
+}
+
+// expect registers a waiter. Registering before sending the request that
+// triggers the reply avoids missing a fast response.
+func (c *Client) expect(types ...string) *waiter {
+	w := &waiter{types: make(map[string]bool, len(types)), ch: make(chan Event, 1)}
+	for _, t := range types {
+		w.types[t] = true
+	}
+	c.mu.Lock()
+	c.waiters = append(c.waiters, w)
+	c.mu.Unlock()
+	return w
+}
+
+func (c *Client) dropWaiter(w *waiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.waiters {
+		if other == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Client) await(ctx context.Context, w *waiter) (Event, error) {
+	select {
+	case ev := <-w.ch:
+		return ev, nil
+	case <-ctx.Done():
+		c.dropWaiter(w)
+		return Event{}, ctx.Err()
+	case <-c.done:
+		c.dropWaiter(w)
+		if err := c.Err(); err != nil {
+			return Event{}, err
+		}
+		return Event{}, ErrClosed
+	}
+}
+
+// request sends msg and waits for a reply of one of the given types.
+func (c *Client) request(ctx context.Context, msg interface{}, replies ...string) (Event, error) {
+	w := c.expect(replies...)
+	if err := c.send(msg); err != nil {
+		c.dropWaiter(w)
+		return Event{}, err
+	}
+	return c.await(ctx, w)
+}
+
+// refusal converts a rejection frame into an error.
+func refusal(ev Event) error {
+	switch ev.Type {
+	case protocol.TypeRateLimited:
+		var m protocol.RateLimitedMsg
+		_ = ev.Decode(&m)
+		return &RateLimitedError{RetryAfter: time.Duration(m.RetryAfter) * time.Second}
+	case protocol.TypeServiceUnavailable:
+		var m protocol.ServiceUnavailableMsg
+		_ = ev.Decode(&m)
+		err := &UnavailableError{Reason: m.Reason}
+		if m.ReopenAt != 0 {
+			err.ReopenAt = time.Unix(m.ReopenAt, 0)
+		}
+		return err
+	case protocol.TypeBanned:
+		var m protocol.BannedMsg
+		_ = ev.Decode(&m)
+		return &BannedError{Duration: time.Duration(m.Duration) * time.Second, Reason: m.Reason}
+	default:
+		var m protocol.ErrorMsg
+		_ = ev.Decode(&m)
+		return &ServerError{Code: m.Code, Message: m.Message, Category: m.Category, Retryable: m.Retryable}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Connection
+// ---------------------------------------------------------------------------
+
+// send writes msg as a JSON text frame on the current connection.
+func (c *Client) send(msg interface{}) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("whisperclient: marshal: %w", err)
+	}
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	return c.write(conn, data)
+}
+
+func (c *Client) write(conn net.Conn, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := wsutil.WriteClientMessage(conn, ws.OpText, data); err != nil {
+		return fmt.Errorf("whisperclient: write: %w", err)
+	}
+	return nil
+}
+
+// lockedWriter lets the control frame handler answer server pings without
+// interleaving with frames written by send.
+type lockedWriter struct {
+	c    *Client
+	conn net.Conn
+}
+
+func (w lockedWriter) Write(p []byte) (int, error) {
+	w.c.writeMu.Lock()
+	defer w.c.writeMu.Unlock()
+	return w.conn.Write(p)
+}
+
+// bufferedConn reads frames the dialer buffered before reading from the
+// connection itself.
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (b bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// readText returns the next text frame, answering control frames on the way.
+func (c *Client) readText(conn net.Conn) ([]byte, error) {
+	control := wsutil.ControlFrameHandler(lockedWriter{c: c, conn: conn}, ws.StateClientSide)
+	rd := &wsutil.Reader{
+		Source:         conn,
+		State:          ws.StateClientSide,
+		CheckUTF8:      true,
+		OnIntermediate: control,
+	}
+	for {
+		hdr, err := rd.NextFrame()
+		if err != nil {
+			return nil, err
+		}
+		c.lastSeen.Store(time.Now().UnixNano())
+		if hdr.OpCode.IsControl() {
+			if err := control(hdr, rd); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if hdr.OpCode&ws.OpText == 0 {
+			if err := rd.Discard(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return io.ReadAll(rd)
+	}
+}
+
+// connect dials, waits for session_created and sends the fingerprint. When
+// prevSessionID is set it also asks the server to resume that session.
+func (c *Client) connect(ctx context.Context, prevSessionID string) (net.Conn, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.HandshakeTimeout)
+	defer cancel()
+
+	conn, br, _, err := ws.Dial(ctx, c.url)
+	if err != nil {
+		return nil, "", fmt.Errorf("whisperclient: dial: %w", err)
+	}
+	if br != nil {
+		// The server's first frames arrived together with the handshake.
+		conn = bufferedConn{Conn: conn, r: br}
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetReadDeadline(deadline)
+
+	data, err := c.readText(conn)
+	if err != nil {
+		conn.Close()
+		if ban := closeRefusal(err); ban != nil {
+			return nil, "", ban
+		}
+		return nil, "", fmt.Errorf("whisperclient: read session_created: %w", err)
+	}
+	var created protocol.SessionCreatedMsg
+	if err := json.Unmarshal(data, &created); err != nil || created.Type != protocol.TypeSessionCreated || created.SessionID == "" {
+		conn.Close()
+		return nil, "", fmt.Errorf("whisperclient: expected session_created, got %s", data)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	msgs := []interface{}{protocol.SetFingerprintMsg{Type: protocol.TypeSetFingerprint, Fingerprint: c.opts.Fingerprint}}
+	if c.opts.Platform != "" || c.opts.AppVersion != "" || c.opts.Locale != "" {
+		msgs = append(msgs, protocol.ClientInfoMsg{
+			Type:       protocol.TypeClientInfo,
+			Platform:   c.opts.Platform,
+			AppVersion: c.opts.AppVersion,
+			Locale:     c.opts.Locale,
+		})
+	}
+	if prevSessionID != "" {
+		msgs = append(msgs, protocol.ResumeSessionMsg{Type: protocol.TypeResumeSession, PreviousSessionID: prevSessionID})
+	}
+	for _, msg := range msgs {
+		data, _ := json.Marshal(msg)
+		if err := c.write(conn, data); err != nil {
+			conn.Close()
+			return nil, "", err
+		}
+	}
+	return conn, created.SessionID, nil
+}
+
+// run reads from conn until it fails, then reconnects, until the client is
+// closed.
+func (c *Client) run(conn net.Conn) {
+	for {
+		c.lastSeen.Store(time.Now().UnixNano())
+		stopPing := make(chan struct{})
+		go c.keepalive(conn, stopPing)
+
+		err := c.readLoop(conn)
+		close(stopPing)
+		conn.Close()
+
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		c.mu.Lock()
+		prevSID := c.sessionID
+		if c.fatal == nil {
+			c.fatal = closeRefusal(err)
+		}
+		fatal := c.fatal
+		c.chatID = ""
+		c.mu.Unlock()
+		c.logf("whisperclient: connection lost session=%s: %v", prevSID, err)
+
+		if fatal != nil || c.opts.DisableReconnect {
+			c.Close()
+			return
+		}
+
+		var sid string
+		conn, sid = c.reconnect(prevSID)
+		if conn == nil {
+			return // closed while reconnecting
+		}
+
+		c.mu.Lock()
+		c.conn, c.sessionID = conn, sid
+		handlers := append([]func(string){}, c.onReconnect...)
+		c.mu.Unlock()
+		c.logf("whisperclient: reconnected session=%s previous=%s", sid, prevSID)
+		for _, h := range handlers {
+			h(sid)
+		}
+	}
+}
+
+// reconnect retries connect with exponential backoff and jitter until it
+// succeeds or the client is closed.
+func (c *Client) reconnect(prevSID string) (net.Conn, string) {
+	for attempt := 0; ; attempt++ {
+		delay := time.Duration(float64(c.opts.ReconnectMinDelay) * math.Pow(2, float64(attempt)))
+		if delay > c.opts.ReconnectMaxDelay || delay <= 0 {
+			delay = c.opts.ReconnectMaxDelay
+		}
+		delay = delay/2 + time.Duration(mrand.Int63n(int64(delay/2)+1))
+
+		select {
+		case <-c.done:
+			return nil, ""
+		case <-time.After(delay):
+		}
+
+		conn, sid, err := c.connect(c.ctx, prevSID)
+		if err == nil {
+			return conn, sid
+		}
+		c.logf("whisperclient: reconnect attempt %d failed: %v", attempt+1, err)
+		var ban *BannedError
+		if errors.As(err, &ban) {
+			c.mu.Lock()
+			c.fatal = ban
+			c.mu.Unlock()
+			c.Close()
+			return nil, ""
+		}
+	}
+}
+
+// closeRefusal returns a *BannedError if err is the server closing the
+// connection with a policy violation (1008), which it sends to banned
+// clients, ErrTooManySessions for the per-fingerprint session limit (4002),
+// or nil otherwise. Reconnecting after either would be refused again.
+func closeRefusal(err error) error {
+	var closed wsutil.ClosedError
+	if !errors.As(err, &closed) {
+		return nil
+	}
+	switch closed.Code {
+	case ws.StatusPolicyViolation:
+		return &BannedError{Reason: closed.Reason}
+	case closeTooManySessions:
+		return ErrTooManySessions
+	}
+	return nil
+}
+
+// closeTooManySessions is the close code for the per-fingerprint session
+// limit; see ARCHITECTURE.md Appendix A.
+const closeTooManySessions ws.StatusCode = 4002
+
+func (c *Client) readLoop(conn net.Conn) error {
+	for {
+		data, err := c.readText(conn)
+		if err != nil {
+			return err
+		}
+		c.dispatch(data)
+	}
+}
+
+// dispatch updates client state from a frame, then hands it to waiters and
+// handlers.
+func (c *Client) dispatch(data []byte) {
+	var env struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(data, &env) != nil || env.Type == "" {
+		return
+	}
+	ev := Event{Type: env.Type, Raw: json.RawMessage(data)}
+
+	c.mu.Lock()
+	switch ev.Type {
+	case protocol.TypeMatchAccepted:
+		var m protocol.MatchAcceptedMsg
+		if ev.Decode(&m) == nil {
+			c.chatID = m.ChatID
+		}
+	case protocol.TypePartnerLeft:
+		c.chatID = ""
+	case protocol.TypeBanned:
+		c.fatal = refusal(ev)
+	case protocol.TypeConfig:
+		var m protocol.ConfigMsg
+		if ev.Decode(&m) == nil {
+			c.limits = limitsFrom(m)
+		}
+	}
+
+	remaining := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.types[ev.Type] {
+			w.ch <- ev // buffered; each waiter fires once
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+	for i := len(remaining); i < len(c.waiters); i++ {
+		c.waiters[i] = nil
+	}
+	c.waiters = remaining
+	handlers := c.handlers[ev.Type]
+	c.mu.Unlock()
+
+	for _, h := range handlers {
+		h(ev)
+	}
+}
+
+// limitsFrom converts a config message to Limits.
+func limitsFrom(m protocol.ConfigMsg) Limits {
+	l := Limits{
+		MaxMessageChars:     m.MaxMessageChars,
+		MaxMessageGraphemes: m.MaxMessageGraphemes,
+		MaxMessageBytes:     m.MaxMessageBytes,
+		MaxNicknameChars:    m.MaxNicknameChars,
+		TypingDebounce:      time.Duration(m.TypingDebounceMs) * time.Millisecond,
+		AcceptDeadline:      time.Duration(m.AcceptDeadline) * time.Second,
+		RateLimits:          make(map[string]RateLimit, len(m.RateLimits)),
+	}
+	for name, rl := range m.RateLimits {
+		l.RateLimits[name] = RateLimit{Limit: rl.Limit, Window: time.Duration(rl.Window) * time.Second}
+	}
+	return l
+}
+
+// keepalive pings the server every PingInterval and drops the connection
+// when nothing was received for PingInterval+PongTimeout.
+func (c *Client) keepalive(conn net.Conn, stop <-chan struct{}) {
+	if c.opts.PingInterval < 0 {
+		return
+	}
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer ticker.Stop()
+
+	ping, _ := json.Marshal(protocol.PingMsg{Type: protocol.TypePing})
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if time.Since(time.Unix(0, c.lastSeen.Load())) > c.opts.PingInterval+c.opts.PongTimeout {
+			c.logf("whisperclient: no traffic for %s, dropping connection", c.opts.PingInterval+c.opts.PongTimeout)
+			conn.Close()
+			return
+		}
+		_ = c.write(conn, ping)
+	}
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.opts.Logf != nil {
+		c.opts.Logf(format, args...)
+	}
+}
@@ -0,0 +1,384 @@
+package whisperclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// fakeServer speaks just enough of the protocol to drive the client: it
+// sends session_created, answers pings, and hands every other client frame
+// to the test.
+type fakeServer struct {
+	srv   *httptest.Server
+	conns chan *fakeConn
+	seq   atomic.Int32
+}
+
+type fakeConn struct {
+	conn   net.Conn
+	sid    string
+	frames chan map[string]interface{}
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	fs := &fakeServer{conns: make(chan *fakeConn, 4)}
+	fs.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _, err := ws.UpgradeHTTP(r, w)
+		if err != nil {
+			return
+		}
+		fc := &fakeConn{conn: conn, sid: fmt.Sprintf("sess-%d", fs.seq.Add(1)), frames: make(chan map[string]interface{}, 16)}
+		fc.send(protocol.SessionCreatedMsg{Type: protocol.TypeSessionCreated, SessionID: fc.sid})
+		fs.conns <- fc
+
+		for {
+			data, err := wsutil.ReadClientText(conn)
+			if err != nil {
+				close(fc.frames)
+				return
+			}
+			var frame map[string]interface{}
+			_ = json.Unmarshal(data, &frame)
+			if frame["type"] == protocol.TypePing {
+				fc.send(protocol.PongMsg{Type: protocol.TypePong})
+				continue
+			}
+			fc.frames <- frame
+		}
+	}))
+	t.Cleanup(fs.srv.Close)
+	return fs
+}
+
+func (fs *fakeServer) url() string {
+	return "ws" + strings.TrimPrefix(fs.srv.URL, "http")
+}
+
+func (fs *fakeServer) accept(t *testing.T) *fakeConn {
+	t.Helper()
+	select {
+	case fc := <-fs.conns:
+		return fc
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not connect")
+		return nil
+	}
+}
+
+func (fc *fakeConn) send(v interface{}) {
+	data, _ := json.Marshal(v)
+	_ = wsutil.WriteServerText(fc.conn, data)
+}
+
+// next returns the next client frame and checks its type.
+func (fc *fakeConn) next(t *testing.T, wantType string) map[string]interface{} {
+	t.Helper()
+	select {
+	case frame, ok := <-fc.frames:
+		if !ok {
+			t.Fatalf("connection closed while waiting for %s", wantType)
+		}
+		if frame["type"] != wantType {
+			t.Fatalf("expected %s frame, got %v", wantType, frame)
+		}
+		return frame
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", wantType)
+		return nil
+	}
+}
+
+func dialTest(t *testing.T, fs *fakeServer, opts Options) (*Client, *fakeConn) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, fs.url(), opts)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	fc := fs.accept(t)
+	if got := fc.next(t, protocol.TypeSetFingerprint)["fingerprint"]; got != c.opts.Fingerprint {
+		t.Fatalf("expected fingerprint %q, got %v", c.opts.Fingerprint, got)
+	}
+	return c, fc
+}
+
+func TestClient_SendsClientInfo(t *testing.T) {
+	fs := newFakeServer(t)
+	_, fc := dialTest(t, fs, Options{Fingerprint: "fp-1", Platform: "desktop", AppVersion: "1.4.0", Locale: "fr-FR"})
+
+	frame := fc.next(t, protocol.TypeClientInfo)
+	if frame["platform"] != "desktop" || frame["app_version"] != "1.4.0" || frame["locale"] != "fr-FR" {
+		t.Errorf("unexpected client_info: %v", frame)
+	}
+}
+
+func TestClient_MatchLifecycle(t *testing.T) {
+	fs := newFakeServer(t)
+	c, fc := dialTest(t, fs, Options{Fingerprint: "fp-1"})
+	if c.SessionID() != fc.sid {
+		t.Fatalf("expected session %s, got %s", fc.sid, c.SessionID())
+	}
+
+	messages := make(chan Message, 1)
+	c.OnMessage(func(m Message) { messages <- m })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	go func() {
+		frame := fc.next(t, protocol.TypeFindMatch)
+		if interests, _ := frame["interests"].([]interface{}); len(interests) != 1 || interests[0] != "music" {
+			t.Errorf("unexpected interests: %v", frame["interests"])
+		}
+		fc.send(protocol.MatchFoundMsg{Type: protocol.TypeMatchFound, ChatID: "chat-1", SharedInterests: []string{"music"}, AcceptDeadline: 15})
+	}()
+	match, err := c.FindMatch(ctx, []string{"music"})
+	if err != nil {
+		t.Fatalf("FindMatch: %v", err)
+	}
+	if match.ChatID != "chat-1" || time.Until(match.AcceptDeadline) < 10*time.Second {
+		t.Fatalf("unexpected match: %+v", match)
+	}
+
+	go func() {
+		fc.next(t, protocol.TypeAcceptMatch)
+		fc.send(protocol.MatchAcceptedMsg{Type: protocol.TypeMatchAccepted, ChatID: "chat-1", Icebreaker: "hi?"})
+	}()
+	chat, err := c.Accept(ctx, match.ChatID)
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if chat.ChatID != "chat-1" || chat.Icebreaker != "hi?" || c.ChatID() != "chat-1" {
+		t.Fatalf("unexpected chat: %+v (active %q)", chat, c.ChatID())
+	}
+
+	if err := c.SendText(chat.ChatID, "hello"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	if frame := fc.next(t, protocol.TypeMessage); frame["text"] != "hello" || frame["chat_id"] != "chat-1" {
+		t.Fatalf("unexpected message frame: %v", frame)
+	}
+
+	fc.send(protocol.ServerChatMsg{Type: protocol.TypeMessage, ID: "m1", From: "partner", Text: "hey", Ts: 1700000000})
+	select {
+	case m := <-messages:
+		if m.Text != "hey" || m.ChatID != "chat-1" || m.ID != "m1" {
+			t.Errorf("unexpected message: %+v", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnMessage was not called")
+	}
+}
+
+func TestClient_Reactions(t *testing.T) {
+	fs := newFakeServer(t)
+	c, fc := dialTest(t, fs, Options{Fingerprint: "fp-1"})
+
+	type reaction struct{ messageID, emoji string }
+	reactions := make(chan reaction, 1)
+	c.OnReaction(func(messageID, emoji string) { reactions <- reaction{messageID, emoji} })
+
+	if err := c.React("chat-1", "m1", "👍"); err != nil {
+		t.Fatalf("React: %v", err)
+	}
+	if frame := fc.next(t, protocol.TypeReact); frame["chat_id"] != "chat-1" || frame["message_id"] != "m1" || frame["emoji"] != "👍" {
+		t.Fatalf("unexpected react frame: %v", frame)
+	}
+
+	fc.send(protocol.ReactionMsg{Type: protocol.TypeReaction, MessageID: "m2", Emoji: "😂"})
+	select {
+	case r := <-reactions:
+		if r.messageID != "m2" || r.emoji != "😂" {
+			t.Errorf("unexpected reaction: %+v", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnReaction was not called")
+	}
+}
+
+func TestClient_FindMatchRefused(t *testing.T) {
+	fs := newFakeServer(t)
+	c, fc := dialTest(t, fs, Options{})
+
+	go func() {
+		fc.next(t, protocol.TypeFindMatch)
+		fc.send(protocol.RateLimitedMsg{Type: protocol.TypeRateLimited, RetryAfter: 60})
+	}()
+	_, err := c.FindMatch(context.Background(), nil)
+	var rl *RateLimitedError
+	if !errors.As(err, &rl) || rl.RetryAfter != time.Minute {
+		t.Fatalf("expected RateLimitedError, got %v", err)
+	}
+
+	go func() {
+		fc.next(t, protocol.TypeFindMatch)
+		fc.send(protocol.ServiceUnavailableMsg{Type: protocol.TypeServiceUnavailable, Reason: "shutting_down"})
+	}()
+	_, err = c.FindMatch(context.Background(), nil)
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) || unavailable.Reason != "shutting_down" || !unavailable.ReopenAt.IsZero() {
+		t.Fatalf("expected UnavailableError without a reopen time, got %v", err)
+	}
+
+	go func() {
+		fc.next(t, protocol.TypeFindMatch)
+		fc.send(protocol.MatchTimeoutMsg{Type: protocol.TypeMatchTimeout})
+	}()
+	if _, err := c.FindMatch(context.Background(), nil); !errors.Is(err, ErrMatchTimeout) {
+		t.Fatalf("expected ErrMatchTimeout, got %v", err)
+	}
+}
+
+func TestClient_FindMatchInvalidInterests(t *testing.T) {
+	fs := newFakeServer(t)
+	c, _ := dialTest(t, fs, Options{})
+
+	_, err := c.FindMatch(context.Background(), []string{"music", "Not Valid"})
+	var invalid *InvalidInterestsError
+	if !errors.As(err, &invalid) || len(invalid.Rejected) != 1 || invalid.Rejected[0].Tag != "Not Valid" {
+		t.Fatalf("expected InvalidInterestsError naming the bad tag, got %v", err)
+	}
+}
+
+func TestClient_FindMatchCancelledByContext(t *testing.T) {
+	fs := newFakeServer(t)
+	c, fc := dialTest(t, fs, Options{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := c.FindMatch(ctx, []string{"music"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	fc.next(t, protocol.TypeFindMatch)
+	fc.next(t, protocol.TypeCancelMatch)
+}
+
+func TestClient_LimitsFromConfig(t *testing.T) {
+	fs := newFakeServer(t)
+	c, fc := dialTest(t, fs, Options{})
+
+	updated := make(chan struct{}, 1)
+	c.On(protocol.TypeConfig, func(Event) { updated <- struct{}{} })
+	fc.send(protocol.ConfigMsg{
+		Type:             protocol.TypeConfig,
+		MaxMessageChars:  500,
+		TypingDebounceMs: 1500,
+		AcceptDeadline:   20,
+		RateLimits:       map[string]protocol.RateLimitConfig{"message": {Limit: 3, Window: 10}},
+	})
+	select {
+	case <-updated:
+	case <-time.After(2 * time.Second):
+		t.Fatal("config handler was not called")
+	}
+
+	l := c.Limits()
+	if l.MaxMessageChars != 500 || l.TypingDebounce != 1500*time.Millisecond || l.AcceptDeadline != 20*time.Second {
+		t.Errorf("unexpected limits: %+v", l)
+	}
+	if rl := l.RateLimits["message"]; rl.Limit != 3 || rl.Window != 10*time.Second {
+		t.Errorf("unexpected message rate limit: %+v", rl)
+	}
+}
+
+func TestClient_ReconnectResumesSession(t *testing.T) {
+	fs := newFakeServer(t)
+	c, first := dialTest(t, fs, Options{ReconnectMinDelay: 10 * time.Millisecond, ReconnectMaxDelay: 20 * time.Millisecond})
+
+	reconnected := make(chan string, 1)
+	c.OnReconnect(func(sid string) { reconnected <- sid })
+
+	first.conn.Close()
+
+	second := fs.accept(t)
+	if got := second.next(t, protocol.TypeSetFingerprint)["fingerprint"]; got != c.opts.Fingerprint {
+		t.Errorf("expected same fingerprint after reconnect, got %v", got)
+	}
+	if got := second.next(t, protocol.TypeResumeSession)["previous_session_id"]; got != first.sid {
+		t.Errorf("expected resume of %s, got %v", first.sid, got)
+	}
+
+	select {
+	case sid := <-reconnected:
+		if sid != second.sid || c.SessionID() != second.sid {
+			t.Errorf("expected session %s, got %s (client %s)", second.sid, sid, c.SessionID())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnReconnect was not called")
+	}
+}
+
+func TestClient_BannedStopsReconnecting(t *testing.T) {
+	fs := newFakeServer(t)
+	c, fc := dialTest(t, fs, Options{ReconnectMinDelay: 10 * time.Millisecond})
+
+	fc.send(protocol.BannedMsg{Type: protocol.TypeBanned, Duration: 3600, Reason: "multiple_reports"})
+	fc.conn.Close()
+
+	select {
+	case <-c.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not shut down after ban")
+	}
+	var banned *BannedError
+	if !errors.As(c.Err(), &banned) || banned.Duration != time.Hour {
+		t.Fatalf("expected BannedError, got %v", c.Err())
+	}
+	if err := c.SendText("chat", "hi"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after shutdown, got %v", err)
+	}
+}
+
+func TestClient_PolicyCloseStopsReconnecting(t *testing.T) {
+	fs := newFakeServer(t)
+	c, fc := dialTest(t, fs, Options{ReconnectMinDelay: 10 * time.Millisecond})
+
+	body := ws.NewCloseFrameBody(ws.StatusPolicyViolation, "banned")
+	_ = ws.WriteFrame(fc.conn, ws.NewCloseFrame(body))
+	fc.conn.Close()
+
+	select {
+	case <-c.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not shut down after a policy-violation close")
+	}
+	var banned *BannedError
+	if !errors.As(c.Err(), &banned) || banned.Reason != "banned" {
+		t.Fatalf("expected BannedError, got %v", c.Err())
+	}
+}
+
+func TestClient_TooManySessionsStopsReconnecting(t *testing.T) {
+	fs := newFakeServer(t)
+	c, fc := dialTest(t, fs, Options{ReconnectMinDelay: 10 * time.Millisecond})
+
+	body := ws.NewCloseFrameBody(closeTooManySessions, "too many sessions")
+	_ = ws.WriteFrame(fc.conn, ws.NewCloseFrame(body))
+	fc.conn.Close()
+
+	select {
+	case <-c.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not shut down after a too-many-sessions close")
+	}
+	if !errors.Is(c.Err(), ErrTooManySessions) {
+		t.Fatalf("expected ErrTooManySessions, got %v", c.Err())
+	}
+}
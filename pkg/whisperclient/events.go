@@ -0,0 +1,194 @@
+package whisperclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/whisper/chat-app/pkg/protocol"
+)
+
+// Errors returned by Client methods.
+var (
+	// ErrClosed is returned once the client has been closed, or the
+	// connection dropped with reconnect disabled.
+	ErrClosed = errors.New("whisperclient: client closed")
+
+	// ErrMatchTimeout is returned by FindMatch when the server gave up
+	// looking for a partner.
+	ErrMatchTimeout = errors.New("whisperclient: no match found")
+
+	// ErrMatchDeclined is returned by Accept when the partner declined or
+	// the accept window expired.
+	ErrMatchDeclined = errors.New("whisperclient: match declined")
+
+	// ErrTooManySessions is returned when the server refused the
+	// connection because this fingerprint already has the maximum number
+	// of sessions open.
+	ErrTooManySessions = errors.New("whisperclient: too many sessions for this fingerprint")
+)
+
+// ServerError is an error frame sent by the server. Category and Retryable
+// come from the server's error registry, so callers can decide whether to
+// retry without knowing every code.
+type ServerError struct {
+	Code      protocol.ErrorCode
+	Message   string
+	Category  string
+	Retryable bool
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("whisperclient: server error %s: %s", e.Code, e.Message)
+}
+
+// InvalidInterestsError is returned by FindMatch for an interest list the
+// server would reject: too many tags, or tags that are empty, too long,
+// repeated or not lowercase letters, digits and hyphens.
+type InvalidInterestsError struct {
+	Rejected []RejectedInterest
+}
+
+// RejectedInterest is an interest tag that failed validation. Reason is
+// "empty", "too_long", "invalid_characters", "duplicate" or "over_limit".
+type RejectedInterest struct {
+	Tag    string
+	Reason string
+}
+
+func (e *InvalidInterestsError) Error() string {
+	return fmt.Sprintf("whisperclient: %d invalid interests", len(e.Rejected))
+}
+
+// RateLimitedError is returned when the server rate-limited a request.
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("whisperclient: rate limited, retry after %s", e.RetryAfter)
+}
+
+// UnavailableError is returned by FindMatch while matchmaking is closed.
+// ReopenAt is zero for "shutting_down", where the client should reconnect
+// to another server instead of waiting.
+type UnavailableError struct {
+	Reason   string
+	ReopenAt time.Time
+}
+
+func (e *UnavailableError) Error() string {
+	if e.ReopenAt.IsZero() {
+		return fmt.Sprintf("whisperclient: matchmaking unavailable (%s)", e.Reason)
+	}
+	return fmt.Sprintf("whisperclient: matchmaking unavailable (%s) until %s", e.Reason, e.ReopenAt.Format(time.RFC3339))
+}
+
+// BannedError is returned when the server refused a request because the
+// client's fingerprint is banned.
+type BannedError struct {
+	Duration time.Duration
+	Reason   string
+}
+
+func (e *BannedError) Error() string {
+	return fmt.Sprintf("whisperclient: banned for %s: %s", e.Duration, e.Reason)
+}
+
+// Event is a raw server frame, as delivered to On handlers and WaitFor.
+type Event struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+// Decode unmarshals the frame into v.
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Raw, v)
+}
+
+// Match is a proposed pairing returned by FindMatch. It must be accepted
+// (or declined) before AcceptDeadline.
+type Match struct {
+	ChatID          string
+	SharedInterests []string
+	AcceptDeadline  time.Time
+	Tier            string        // "exact", "overlap", "single" or "random"
+	PartnerWait     time.Duration // how long the partner was queued
+	PartnerWaitTier string        // the broadest tier the partner's wait reached
+	PartnerRegion   string        // the partner's region, or "" when unpartitioned
+
+	// PartnerOtherInterests counts the partner's interests that are not
+	// shared; the server never says which they are.
+	PartnerOtherInterests int
+}
+
+// Chat is an active chat returned by Accept.
+type Chat struct {
+	ChatID     string
+	Icebreaker string
+
+	// Nickname and AvatarSeed are how this client appears to the partner
+	// in this chat; the Partner fields are how the partner appears.
+	Nickname          string
+	AvatarSeed        string
+	PartnerNickname   string
+	PartnerAvatarSeed string
+}
+
+// Message is a chat message from the partner.
+type Message struct {
+	ID     string
+	ChatID string
+	Text   string
+	Time   time.Time
+
+	// Translated is Text in the language from Options.Language, and Lang
+	// the language of Text. Both are empty when no translation was made.
+	Translated string
+	Lang       string
+
+	// Nickname and AvatarSeed identify the sender within this chat.
+	Nickname   string
+	AvatarSeed string
+
+	// Seq orders the message among the chat's events. Messages can be
+	// delivered out of order when the users are on different servers.
+	// Zero if the server could not assign one.
+	Seq int64
+}
+
+// Limits are the limits the server enforces, as announced in its config
+// message. Fields are zero until the server has sent one.
+type Limits struct {
+	MaxMessageChars     int // code points
+	MaxMessageGraphemes int // user-perceived characters; zero when not limited
+	MaxMessageBytes     int
+	MaxNicknameChars    int
+	TypingDebounce      time.Duration // idle time before SetTyping(false)
+	AcceptDeadline      time.Duration
+	RateLimits          map[string]RateLimit // keyed by rule name, e.g. "message"
+}
+
+// RateLimit allows Limit actions per Window.
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// ChatSummary describes a finished chat from this client's side.
+type ChatSummary struct {
+	ChatID           string
+	Duration         time.Duration
+	MessagesSent     int
+	MessagesReceived int
+	SharedInterests  []string
+}
+
+// QueueStatus is the periodic feedback sent while waiting for a match.
+// EstimatedWait is zero when the server has no estimate.
+type QueueStatus struct {
+	Position      int64
+	QueueSize     int64
+	EstimatedWait time.Duration
+}